@@ -0,0 +1,227 @@
+// Package aclstore provides a Store decorator enforcing per-stream access control.
+// A pluggable StreamACL is consulted on every read, append, and subscription, so
+// rules such as "only the owning customer may read a cart stream" are enforced once
+// at the store instead of in every handler and query.
+//
+// The Store interface carries no caller identity, so a guarded store hands out a
+// view bound to one principal per request:
+//
+//	guarded := aclstore.New(store, acl)
+//	view := guarded.For(principal)
+//	aggregate := cart.NewCartAggregate(view)
+package aclstore
+
+import (
+	"context"
+	"time"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+)
+
+// Access is the kind of stream operation being checked
+type Access string
+
+const (
+	Read      Access = "read"
+	Append    Access = "append"
+	Subscribe Access = "subscribe"
+)
+
+// StreamACL decides whether a principal may access a stream. Returning an error
+// denies the operation; a *common.StreamAccessError is reported to HTTP callers as
+// 403 Forbidden.
+type StreamACL interface {
+	Check(principal bus.Principal, access Access, streamID string) error
+}
+
+// StreamACLFunc adapts a function to the StreamACL interface
+type StreamACLFunc func(principal bus.Principal, access Access, streamID string) error
+
+// Check calls f
+func (f StreamACLFunc) Check(principal bus.Principal, access Access, streamID string) error {
+	return f(principal, access, streamID)
+}
+
+// Deny returns the error an ACL reports for a denied operation
+func Deny(principal bus.Principal, access Access, streamID, reason string) error {
+	return &common.StreamAccessError{Principal: principal.ID, Access: string(access), StreamID: streamID, Reason: reason}
+}
+
+// Guarded wraps a store with an ACL. It is not a Store itself; use For to get a view
+// acting as a principal.
+type Guarded struct {
+	store common.Store
+	acl   StreamACL
+}
+
+// New guards store with acl
+func New(store common.Store, acl StreamACL) *Guarded {
+	return &Guarded{store: store, acl: acl}
+}
+
+// For returns a view of the store that checks every operation against the ACL as
+// principal. The zero Principal is used for anonymous callers, so the ACL decides
+// how they are treated.
+func (g *Guarded) For(principal bus.Principal) *Store {
+	return &Store{store: g.store, acl: g.acl, principal: principal}
+}
+
+// ForContext returns a view acting as the principal carried by ctx
+func (g *Guarded) ForContext(ctx context.Context) *Store {
+	principal, _ := bus.PrincipalFrom(ctx)
+	return g.For(principal)
+}
+
+// Store is a view of a guarded store acting as one principal. Besides common.Store
+// it offers common.BatchAppender and common.Transactor, checking access like the
+// other appends. It deliberately offers neither common.StreamDeleter, as hard
+// deletes are administrative and go through the unguarded store, nor
+// common.CategoryReader, so common.ReadCategory scans the view's filtered log.
+type Store struct {
+	store     common.Store
+	acl       StreamACL
+	principal bus.Principal
+}
+
+var (
+	_ common.Store         = (*Store)(nil)
+	_ common.BatchAppender = (*Store)(nil)
+	_ common.Transactor    = (*Store)(nil)
+)
+
+// Append stores the event if the principal may append to its stream
+func (s *Store) Append(event *common.Event) error {
+	if err := s.acl.Check(s.principal, Append, event.AggregateID); err != nil {
+		return err
+	}
+	return s.store.Append(event)
+}
+
+// AppendBatch stores events together if the principal may append to every one of
+// their streams, so a denied stream leaves the whole batch unstored. The batch is
+// atomic when the guarded store is a common.BatchAppender; otherwise the events
+// are appended one by one, as by common.UnitOfWork.
+func (s *Store) AppendBatch(events []*common.Event) error {
+	for _, event := range events {
+		if err := s.acl.Check(s.principal, Append, event.AggregateID); err != nil {
+			return err
+		}
+	}
+
+	batch, ok := s.store.(common.BatchAppender)
+	if !ok {
+		for _, event := range events {
+			if err := s.store.Append(event); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return batch.AppendBatch(events)
+}
+
+// WithinTransaction runs fn in a transaction of the guarded store, passing it a view
+// of the transaction acting as the principal, so its appends are checked too. The
+// guarded store must implement common.Transactor.
+func (s *Store) WithinTransaction(fn func(tx common.Store) error) error {
+	return common.WithinTransaction(s.store, func(tx common.Store) error {
+		return fn(&Store{store: tx, acl: s.acl, principal: s.principal})
+	})
+}
+
+// GetStream returns the stream if the principal may read it
+func (s *Store) GetStream(aggregateID string) ([]*common.Event, error) {
+	if err := s.acl.Check(s.principal, Read, aggregateID); err != nil {
+		return nil, err
+	}
+	return s.store.GetStream(aggregateID)
+}
+
+// GetStreamVersion returns the version of a stream if the principal may read or
+// append to it, so appends are based on the actual version, and otherwise 0, as for
+// a stream that doesn't exist; Append then reports the access error.
+func (s *Store) GetStreamVersion(aggregateID string) int {
+	if s.acl.Check(s.principal, Read, aggregateID) != nil && s.acl.Check(s.principal, Append, aggregateID) != nil {
+		return 0
+	}
+	return s.store.GetStreamVersion(aggregateID)
+}
+
+// StreamLength returns the number of events in a stream, or 0 if the principal may
+// not read it
+func (s *Store) StreamLength(aggregateID string) int {
+	if s.acl.Check(s.principal, Read, aggregateID) != nil {
+		return 0
+	}
+	return s.store.StreamLength(aggregateID)
+}
+
+// HeadEvent returns the latest event of a stream if the principal may read it
+func (s *Store) HeadEvent(aggregateID string) (*common.Event, error) {
+	if err := s.acl.Check(s.principal, Read, aggregateID); err != nil {
+		return nil, err
+	}
+	return s.store.HeadEvent(aggregateID)
+}
+
+// GetAllEvents returns the events of the streams the principal may read
+func (s *Store) GetAllEvents() []*common.Event {
+	allowed := make(map[string]bool)
+	events := make([]*common.Event, 0)
+	for _, event := range s.store.GetAllEvents() {
+		ok, checked := allowed[event.AggregateID]
+		if !checked {
+			ok = s.acl.Check(s.principal, Read, event.AggregateID) == nil
+			allowed[event.AggregateID] = ok
+		}
+		if ok {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// StreamIDs returns the identifiers of the streams the principal may read, sorted
+func (s *Store) StreamIDs() []string {
+	ids := make([]string, 0)
+	for _, id := range s.store.StreamIDs() {
+		if s.acl.Check(s.principal, Read, id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// SubscribeStream follows a stream like common.SubscribeStream if the principal may
+// subscribe to it. Access is checked again on every poll, and the channel is closed
+// once it is revoked.
+func (s *Store) SubscribeStream(ctx context.Context, streamID string, fromVersion int, interval time.Duration) (<-chan *common.Event, error) {
+	if err := s.acl.Check(s.principal, Subscribe, streamID); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	followed := &subscribedStore{Store: s.store, allowed: func() bool {
+		if s.acl.Check(s.principal, Subscribe, streamID) != nil {
+			cancel()
+			return false
+		}
+		return true
+	}}
+	return common.SubscribeStream(ctx, followed, streamID, fromVersion, interval), nil
+}
+
+// subscribedStore is the store a subscription polls, which sees no new events once
+// allowed reports the subscription revoked
+type subscribedStore struct {
+	common.Store
+	allowed func() bool
+}
+
+// GetStreamVersion is called on every poll of common.SubscribeStream
+func (s *subscribedStore) GetStreamVersion(aggregateID string) int {
+	if !s.allowed() {
+		return 0
+	}
+	return s.Store.GetStreamVersion(aggregateID)
+}
@@ -0,0 +1,211 @@
+package aclstore
+
+import (
+	"context"
+	"errors"
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// ownerACL lets customers access their own cart-<customer> streams, auditors read
+// everything, and nobody else in
+var ownerACL = StreamACLFunc(func(principal bus.Principal, access Access, streamID string) error {
+	if access == Read && principal.HasRole("auditor") {
+		return nil
+	}
+	if principal.ID != "" && streamID == "cart-"+principal.ID {
+		return nil
+	}
+	return Deny(principal, access, streamID, "not the owner")
+})
+
+func guardedStore() *Guarded {
+	store := common.NewEventStore()
+	store.Append(common.NewEvent("CartCreated", "cart-alice", 1, nil, nil))
+	store.Append(common.NewEvent("CartCreated", "cart-bob", 1, nil, nil))
+	return New(store, ownerACL)
+}
+
+func TestStore_ChecksReadsAndAppends(t *testing.T) {
+	alice := guardedStore().For(bus.Principal{ID: "alice"})
+
+	if _, err := alice.GetStream("cart-alice"); err != nil {
+		t.Errorf("Expected alice to read her cart, got %v", err)
+	}
+	var denied *common.StreamAccessError
+	if _, err := alice.GetStream("cart-bob"); !errors.As(err, &denied) || denied.Access != "read" {
+		t.Errorf("Expected StreamAccessError reading bob's cart, got %v", err)
+	}
+	if version := alice.GetStreamVersion("cart-bob"); version != 0 {
+		t.Errorf("Expected version 0 for a denied stream, got %d", version)
+	}
+
+	if err := alice.Append(common.NewEvent("ItemAdded", "cart-alice", 2, nil, nil)); err != nil {
+		t.Errorf("Expected alice to append to her cart, got %v", err)
+	}
+	err := alice.Append(common.NewEvent("ItemAdded", "cart-bob", 2, nil, nil))
+	if !errors.As(err, &denied) || !strings.Contains(err.Error(), "not the owner") {
+		t.Errorf("Expected StreamAccessError appending to bob's cart, got %v", err)
+	}
+}
+
+func TestStore_CommitsUnitsOfWorkAtomically(t *testing.T) {
+	guarded := guardedStore()
+	alice := guarded.For(bus.Principal{ID: "alice"})
+
+	// Bob's cart looks new to alice, who may not read it
+	uow := common.NewUnitOfWork(alice, nil)
+	for _, event := range []*common.Event{
+		common.NewEvent("ItemAdded", "cart-alice", 2, nil, nil),
+		common.NewEvent("CartCreated", "cart-bob", 1, nil, nil),
+	} {
+		if err := uow.Append(event); err != nil {
+			t.Fatalf("Error appending to the unit of work: %v", err)
+		}
+	}
+	var denied *common.StreamAccessError
+	if err := uow.Commit(); !errors.As(err, &denied) || denied.StreamID != "cart-bob" {
+		t.Fatalf("Expected StreamAccessError for bob's cart, got %v", err)
+	}
+	if version := guarded.store.GetStreamVersion("cart-alice"); version != 1 {
+		t.Errorf("Expected nothing written when the batch fails halfway, got alice's cart at version %d", version)
+	}
+
+	uow.Append(common.NewEvent("ItemAdded", "cart-alice", 2, nil, nil))
+	uow.Append(common.NewEvent("ItemAdded", "cart-alice", 3, nil, nil))
+	if err := uow.Commit(); err != nil || guarded.store.GetStreamVersion("cart-alice") != 3 {
+		t.Errorf("Expected both events committed, got %v", err)
+	}
+}
+
+func TestStore_FiltersListings(t *testing.T) {
+	guarded := guardedStore()
+
+	alice := guarded.For(bus.Principal{ID: "alice"})
+	if ids := alice.StreamIDs(); len(ids) != 1 || ids[0] != "cart-alice" {
+		t.Errorf("Expected alice to see only her cart, got %v", ids)
+	}
+	if events := alice.GetAllEvents(); len(events) != 1 {
+		t.Errorf("Expected alice to see 1 event, got %d", len(events))
+	}
+
+	auditor := guarded.ForContext(bus.WithPrincipal(context.Background(), bus.Principal{ID: "carol", Roles: []string{"auditor"}}))
+	if events := auditor.GetAllEvents(); len(events) != 2 {
+		t.Errorf("Expected the auditor to see every event, got %d", len(events))
+	}
+	if err := auditor.Append(common.NewEvent("ItemAdded", "cart-bob", 2, nil, nil)); err == nil {
+		t.Error("Expected the auditor to be denied appends")
+	}
+
+	if ids := guarded.For(bus.Principal{}).StreamIDs(); len(ids) != 0 {
+		t.Errorf("Expected anonymous callers to see nothing, got %v", ids)
+	}
+}
+
+func TestStore_ChecksSubscriptions(t *testing.T) {
+	bob := guardedStore().For(bus.Principal{ID: "bob"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if _, err := bob.SubscribeStream(ctx, "cart-alice", 0, time.Millisecond); err == nil {
+		t.Error("Expected bob to be denied a subscription to alice's cart")
+	}
+	events, err := bob.SubscribeStream(ctx, "cart-bob", 0, time.Millisecond)
+	if err != nil {
+		t.Fatalf("Error subscribing: %v", err)
+	}
+	select {
+	case event := <-events:
+		if event.AggregateID != "cart-bob" {
+			t.Errorf("Unexpected event: %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected bob's cart event to be delivered")
+	}
+}
+
+func TestStore_ReportsVersionsToAppenders(t *testing.T) {
+	// Writers may append to any stream without reading it
+	writerACL := StreamACLFunc(func(principal bus.Principal, access Access, streamID string) error {
+		if access == Append {
+			return nil
+		}
+		return Deny(principal, access, streamID, "write only")
+	})
+	store := common.NewEventStore()
+	store.Append(common.NewEvent("CartCreated", "cart-alice", 1, nil, nil))
+	writer := New(store, writerACL).For(bus.Principal{ID: "importer"})
+
+	if version := writer.GetStreamVersion("cart-alice"); version != 1 {
+		t.Errorf("Expected the writer to see version 1, got %d", version)
+	}
+	if err := writer.Append(common.NewEvent("ItemAdded", "cart-alice", 2, nil, nil)); err != nil {
+		t.Errorf("Expected the writer to append, got %v", err)
+	}
+}
+
+func TestStore_ChecksAppendsInTransactions(t *testing.T) {
+	guarded := guardedStore()
+	alice := guarded.For(bus.Principal{ID: "alice"})
+
+	err := common.WithinTransaction(alice, func(tx common.Store) error {
+		if err := tx.Append(common.NewEvent("ItemAdded", "cart-alice", 2, nil, nil)); err != nil {
+			return err
+		}
+		return tx.Append(common.NewEvent("ItemAdded", "cart-bob", 2, nil, nil))
+	})
+	var denied *common.StreamAccessError
+	if !errors.As(err, &denied) || denied.StreamID != "cart-bob" {
+		t.Fatalf("Expected StreamAccessError for bob's cart, got %v", err)
+	}
+	if version := guarded.store.GetStreamVersion("cart-alice"); version != 1 {
+		t.Errorf("Expected the transaction rolled back, got alice's cart at version %d", version)
+	}
+
+	err = common.WithinTransaction(alice, func(tx common.Store) error {
+		return tx.Append(common.NewEvent("ItemAdded", "cart-alice", 2, nil, nil))
+	})
+	if err != nil || guarded.store.GetStreamVersion("cart-alice") != 2 {
+		t.Errorf("Expected the transaction committed, got %v", err)
+	}
+}
+
+func TestStore_StopsSubscriptionsWhenRevoked(t *testing.T) {
+	var revoked atomic.Bool
+	revocableACL := StreamACLFunc(func(principal bus.Principal, access Access, streamID string) error {
+		if revoked.Load() {
+			return Deny(principal, access, streamID, "revoked")
+		}
+		return ownerACL(principal, access, streamID)
+	})
+	store := common.NewEventStore()
+	store.Append(common.NewEvent("CartCreated", "cart-bob", 1, nil, nil))
+	bob := New(store, revocableACL).For(bus.Principal{ID: "bob"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := bob.SubscribeStream(ctx, "cart-bob", 0, time.Millisecond)
+	if err != nil {
+		t.Fatalf("Error subscribing: %v", err)
+	}
+	select {
+	case <-events:
+	case <-time.After(time.Second):
+		t.Fatal("Expected bob's cart event to be delivered")
+	}
+
+	revoked.Store(true)
+	store.Append(common.NewEvent("ItemAdded", "cart-bob", 2, nil, nil))
+	select {
+	case event, ok := <-events:
+		if ok {
+			t.Errorf("Expected no events after revoking access, got %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected the subscription to end when access is revoked")
+	}
+}
@@ -0,0 +1,296 @@
+// Package admin provides HTTP endpoints for inspecting the event log of a running
+// application. The endpoints are read-only by default so they can be mounted in demos
+// and development servers without exposing a way to mutate the store.
+//
+// Routes:
+//   - GET  /admin/streams[?category=cart]        list streams with their versions
+//   - GET  /admin/streams/{id}                   show the events of a single stream
+//   - GET  /admin/events?type=ItemAdded&after=N  page through the global event log
+//   - GET  /admin/event-catalog[?format=markdown] describe the registered event types
+//   - GET  /admin/projections                    show projection checkpoints, lag, and errors
+//   - POST /admin/streams/{id}                   append an event (only when writes are enabled)
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"simple-event-modeling/catalog"
+	"simple-event-modeling/common"
+	"simple-event-modeling/metrics"
+)
+
+const (
+	streamsPath     = "/admin/streams"
+	eventsPath      = "/admin/events"
+	catalogPath     = "/admin/event-catalog"
+	projectionsPath = "/admin/projections"
+
+	// defaultLimit caps the number of events returned by a single /admin/events request
+	defaultLimit = 100
+)
+
+// Handler serves the admin endpoints for an event store
+type Handler struct {
+	store       common.Store
+	registry    *common.Registry
+	allowWrite  bool
+	projections *metrics.Projections
+	namer       common.StreamNamer
+}
+
+// Option configures a Handler
+type Option func(*Handler)
+
+// WithWrites enables the POST endpoint for appending events to a stream.
+// It is intended for local debugging only.
+func WithWrites() Option {
+	return func(h *Handler) {
+		h.allowWrite = true
+	}
+}
+
+// WithRegistry sets the registry described by the event catalog endpoint.
+// common.DefaultRegistry is used by default.
+func WithRegistry(registry *common.Registry) Option {
+	return func(h *Handler) {
+		h.registry = registry
+	}
+}
+
+// WithProjectionMetrics serves the figures of the projections tracked by projections
+// at /admin/projections
+func WithProjectionMetrics(projections *metrics.Projections) Option {
+	return func(h *Handler) {
+		h.projections = projections
+	}
+}
+
+// WithStreamNamer sets the namer telling the category of streams for the category
+// filter of /admin/streams. common.DefaultStreamNamer is used by default.
+func WithStreamNamer(namer common.StreamNamer) Option {
+	return func(h *Handler) {
+		h.namer = namer
+	}
+}
+
+// NewHandler creates an admin handler for the given store
+func NewHandler(store common.Store, opts ...Option) *Handler {
+	h := &Handler{store: store, registry: common.DefaultRegistry, namer: common.DefaultStreamNamer}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// StreamSummary describes a stream in the stream listing
+type StreamSummary struct {
+	ID      string `json:"id"`
+	Version int    `json:"version"`
+	Length  int    `json:"length"`
+}
+
+// StreamDetail is the response body of /admin/streams/{id}
+type StreamDetail struct {
+	ID      string          `json:"id"`
+	Version int             `json:"version"`
+	Events  []*common.Event `json:"events"`
+}
+
+// PositionedEvent pairs an event with its position in the global log (see
+// common.LogPosition)
+type PositionedEvent struct {
+	Position int           `json:"position"`
+	Event    *common.Event `json:"event"`
+}
+
+// EventPage is the response body of /admin/events
+type EventPage struct {
+	Events []PositionedEvent `json:"events"`
+	// Next is the position to pass as "after" to fetch the following page
+	Next int `json:"next"`
+}
+
+// ServeHTTP routes admin requests
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimSuffix(r.URL.Path, "/")
+
+	switch {
+	case path == streamsPath:
+		if !h.allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		h.listStreams(w, r)
+	case strings.HasPrefix(path, streamsPath+"/"):
+		id := strings.TrimPrefix(path, streamsPath+"/")
+		switch r.Method {
+		case http.MethodGet:
+			h.showStream(w, id)
+		case http.MethodPost:
+			if !h.allowWrite {
+				writeError(w, http.StatusMethodNotAllowed, errors.New("admin endpoints are read-only"))
+				return
+			}
+			h.appendEvent(w, r, id)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		}
+	case path == eventsPath:
+		if !h.allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		h.listEvents(w, r)
+	case path == catalogPath:
+		if !h.allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		h.showCatalog(w, r)
+	case path == projectionsPath:
+		if !h.allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		h.listProjections(w)
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+func (h *Handler) allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return false
+	}
+	return true
+}
+
+func (h *Handler) listStreams(w http.ResponseWriter, r *http.Request) {
+	ids := h.store.StreamIDs()
+	if category := r.URL.Query().Get("category"); category != "" {
+		ids = common.CategoryStreamIDs(h.store, h.namer, category)
+	}
+	summaries := make([]StreamSummary, 0)
+	for _, id := range ids {
+		length := h.store.StreamLength(id)
+		if length == 0 {
+			continue
+		}
+		summaries = append(summaries, StreamSummary{
+			ID:      id,
+			Version: h.store.GetStreamVersion(id),
+			Length:  length,
+		})
+	}
+	writeJSON(w, http.StatusOK, summaries)
+}
+
+func (h *Handler) showStream(w http.ResponseWriter, id string) {
+	events, err := h.store.GetStream(id)
+	if err != nil {
+		var notFound *common.StreamNotFoundError
+		if errors.As(err, &notFound) {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, StreamDetail{
+		ID:      id,
+		Version: h.store.GetStreamVersion(id),
+		Events:  events,
+	})
+}
+
+func (h *Handler) listEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	after, err := intParam(query.Get("after"), 0)
+	if err != nil || after < 0 {
+		writeError(w, http.StatusBadRequest, errors.New("after must be a non-negative integer"))
+		return
+	}
+	limit, err := intParam(query.Get("limit"), defaultLimit)
+	if err != nil || limit <= 0 {
+		writeError(w, http.StatusBadRequest, errors.New("limit must be a positive integer"))
+		return
+	}
+	eventType := query.Get("type")
+
+	page := EventPage{Events: make([]PositionedEvent, 0), Next: after}
+	all := h.store.GetAllEvents()
+	for i := len(all) - len(common.EventsAfter(all, after)); i < len(all) && len(page.Events) < limit; i++ {
+		page.Next = common.LogPosition(all, i)
+		if eventType != "" && all[i].Type != eventType {
+			continue
+		}
+		page.Events = append(page.Events, PositionedEvent{Position: page.Next, Event: all[i]})
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (h *Handler) showCatalog(w http.ResponseWriter, r *http.Request) {
+	c := catalog.Build(h.registry)
+	if r.URL.Query().Get("format") == "markdown" {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(c.Markdown()))
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// listProjections serves the figures of the tracked projections, or none without
+// WithProjectionMetrics
+func (h *Handler) listProjections(w http.ResponseWriter) {
+	if h.projections == nil {
+		writeJSON(w, http.StatusOK, []metrics.ProjectionStats{})
+		return
+	}
+	writeJSON(w, http.StatusOK, h.projections.Snapshot())
+}
+
+// appendRequest is the body accepted by POST /admin/streams/{id}
+type appendRequest struct {
+	Type     string                 `json:"type"`
+	Data     map[string]interface{} `json:"data"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+func (h *Handler) appendEvent(w http.ResponseWriter, r *http.Request, id string) {
+	var req appendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Type == "" {
+		writeError(w, http.StatusBadRequest, errors.New("event type is required"))
+		return
+	}
+
+	event := common.NewEvent(req.Type, id, h.store.GetStreamVersion(id)+1, req.Data, req.Metadata)
+	if err := h.store.Append(event); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, event)
+}
+
+func intParam(raw string, fallback int) (int, error) {
+	if raw == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(raw)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,175 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"simple-event-modeling/common"
+	"simple-event-modeling/metrics"
+	"strings"
+	"testing"
+)
+
+func seededStore() *common.EventStore {
+	store := common.NewEventStore()
+	store.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+	store.Append(common.NewEvent("ItemAdded", "cart-1", 2, map[string]interface{}{"item": "apple"}, nil))
+	store.Append(common.NewEvent("CartCreated", "cart-2", 1, nil, nil))
+	store.Append(common.NewEvent("ItemAdded", "cart-2", 2, map[string]interface{}{"item": "pear"}, nil))
+	return store
+}
+
+func serve(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler_ListStreams(t *testing.T) {
+	h := NewHandler(seededStore())
+
+	rec := serve(h, http.MethodGet, "/admin/streams", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+
+	var streams []StreamSummary
+	if err := json.Unmarshal(rec.Body.Bytes(), &streams); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if len(streams) != 2 {
+		t.Fatalf("Expected 2 streams, got %d", len(streams))
+	}
+	if streams[0].ID != "cart-1" || streams[0].Version != 2 || streams[0].Length != 2 {
+		t.Errorf("Unexpected summary for cart-1: %+v", streams[0])
+	}
+}
+
+func TestHandler_ListStreamsByCategory(t *testing.T) {
+	store := seededStore()
+	store.Append(common.NewEvent("WishlistCreated", "wishlist-1", 1, nil, nil))
+	h := NewHandler(store)
+
+	var streams []StreamSummary
+	json.Unmarshal(serve(h, http.MethodGet, "/admin/streams?category=wishlist", "").Body.Bytes(), &streams)
+	if len(streams) != 1 || streams[0].ID != "wishlist-1" {
+		t.Errorf("Expected only the wishlist stream, got %+v", streams)
+	}
+	json.Unmarshal(serve(h, http.MethodGet, "/admin/streams?category=cart", "").Body.Bytes(), &streams)
+	if len(streams) != 2 {
+		t.Errorf("Expected the 2 cart streams, got %+v", streams)
+	}
+}
+
+func TestHandler_ShowStream(t *testing.T) {
+	h := NewHandler(seededStore())
+
+	rec := serve(h, http.MethodGet, "/admin/streams/cart-2", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+
+	var detail StreamDetail
+	if err := json.Unmarshal(rec.Body.Bytes(), &detail); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if detail.ID != "cart-2" || len(detail.Events) != 2 {
+		t.Errorf("Unexpected stream detail: %+v", detail)
+	}
+
+	rec = serve(h, http.MethodGet, "/admin/streams/missing", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for missing stream, got %d", rec.Code)
+	}
+}
+
+func TestHandler_ListEventsFiltersByTypeAndPosition(t *testing.T) {
+	h := NewHandler(seededStore())
+
+	rec := serve(h, http.MethodGet, "/admin/events?type=ItemAdded&after=2", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+
+	var page EventPage
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if len(page.Events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(page.Events))
+	}
+	if page.Events[0].Position != 4 || page.Events[0].Event.AggregateID != "cart-2" {
+		t.Errorf("Unexpected event: %+v", page.Events[0])
+	}
+	if page.Next != 4 {
+		t.Errorf("Expected next position 4, got %d", page.Next)
+	}
+
+	rec = serve(h, http.MethodGet, "/admin/events?after=abc", "")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid position, got %d", rec.Code)
+	}
+}
+
+func TestHandler_ReadOnlyByDefault(t *testing.T) {
+	store := seededStore()
+	body := `{"type":"CartCleared"}`
+
+	rec := serve(NewHandler(store), http.MethodPost, "/admin/streams/cart-1", body)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", rec.Code)
+	}
+
+	rec = serve(NewHandler(store, WithWrites()), http.MethodPost, "/admin/streams/cart-1", body)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", rec.Code)
+	}
+	if version := store.GetStreamVersion("cart-1"); version != 3 {
+		t.Errorf("Expected version 3 after append, got %d", version)
+	}
+}
+
+func TestHandler_EventCatalog(t *testing.T) {
+	registry := common.NewRegistry()
+	registry.RegisterEvent(common.EventInfo{
+		Name:      "ItemAdded",
+		Aggregate: "Cart",
+		Payload:   []common.FieldInfo{{Name: "item", Type: "string"}},
+	})
+	h := NewHandler(seededStore(), WithRegistry(registry))
+
+	rec := serve(h, http.MethodGet, "/admin/event-catalog", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `"name":"ItemAdded"`) {
+		t.Errorf("Expected ItemAdded in catalog, got %s", rec.Body.String())
+	}
+
+	rec = serve(h, http.MethodGet, "/admin/event-catalog?format=markdown", "")
+	if !strings.Contains(rec.Body.String(), "## ItemAdded") {
+		t.Errorf("Expected Markdown catalog, got %s", rec.Body.String())
+	}
+}
+
+func TestHandler_Projections(t *testing.T) {
+	store := seededStore()
+	projections := metrics.NewProjections(store)
+	projections.Track("cart-items")(common.Checkpoint{Projection: "cart-items", Position: 3}, 3, nil)
+	h := NewHandler(store, WithProjectionMetrics(projections))
+
+	rec := serve(h, http.MethodGet, "/admin/projections", "")
+	var stats []metrics.ProjectionStats
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if rec.Code != http.StatusOK || len(stats) != 1 || stats[0].Name != "cart-items" || stats[0].Lag != 1 {
+		t.Errorf("Expected cart-items one event behind, got %d %+v", rec.Code, stats)
+	}
+
+	rec = serve(NewHandler(store), http.MethodGet, "/admin/projections", "")
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("Expected no projections without metrics, got %d %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,392 @@
+// Package analytics exports the event log as flat tables for offline analysis. Export
+// replays the store, upcasts each event of the selected types to its current schema,
+// flattens it into a record, and writes one CSV or Parquet file per event type, so
+// cart behavior can be explored in a notebook or a query engine without standing up
+// a warehouse pipeline.
+package analytics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"simple-event-modeling/common"
+)
+
+// ColumnType is the type of the values in a column
+type ColumnType int
+
+// Column types, inferred from the values of each column
+const (
+	String ColumnType = iota
+	Int64
+	Double
+	Boolean
+	Timestamp
+)
+
+func (t ColumnType) String() string {
+	switch t {
+	case Int64:
+		return "int64"
+	case Double:
+		return "double"
+	case Boolean:
+		return "boolean"
+	case Timestamp:
+		return "timestamp"
+	default:
+		return "string"
+	}
+}
+
+// Column is a named, typed column of a table
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// Table holds the flattened events of one type. Rows hold one value per column: a
+// string, int64, float64, bool, or time.Time matching the column type, or nil when
+// the event has no value for the column.
+type Table struct {
+	EventType string
+	Columns   []Column
+	Rows      [][]interface{}
+}
+
+// Envelope columns leading every table
+const (
+	ColumnEventID     = "event_id"
+	ColumnStreamID    = "stream_id"
+	ColumnVersion     = "event_version"
+	ColumnCreatedAt   = "created_at"
+	ColumnEffectiveAt = "effective_at"
+)
+
+// Format is the file format of an export
+type Format string
+
+// Supported export formats
+const (
+	FormatCSV     Format = "csv"
+	FormatParquet Format = "parquet"
+)
+
+// Options selects what Export writes
+type Options struct {
+	// EventTypes limits the export to these event types; nil exports every type
+	EventTypes []string
+	// MetadataKeys are metadata entries to export as metadata_<key> columns
+	MetadataKeys []string
+	// Registry upcasts events before they are flattened; nil uses common.DefaultRegistry
+	Registry *common.Registry
+}
+
+// Tables flattens events into one table per event type, sorted by event type. Nested
+// payload objects become columns joined with "_" (shipping_city); arrays are kept as
+// JSON text. Payload fields named like an envelope column get a "data_" prefix.
+func Tables(events []*common.Event, opts Options) ([]*Table, error) {
+	return tablesOf(func(yield func(*common.Event, error) bool) {
+		for _, event := range events {
+			if !yield(event, nil) {
+				return
+			}
+		}
+	}, opts)
+}
+
+// tablesOf flattens the events of a scan like Tables
+func tablesOf(events iter.Seq2[*common.Event, error], opts Options) ([]*Table, error) {
+	registry := opts.Registry
+	if registry == nil {
+		registry = common.DefaultRegistry
+	}
+	selected := make(map[string]bool, len(opts.EventTypes))
+	for _, eventType := range opts.EventTypes {
+		selected[eventType] = true
+	}
+
+	builders := make(map[string]*tableBuilder)
+	for event, err := range events {
+		if err != nil {
+			return nil, err
+		}
+		if len(selected) > 0 && !selected[event.Type] {
+			continue
+		}
+		upcast, err := registry.Upcast(event)
+		if err != nil {
+			return nil, err
+		}
+		record, err := flatten(upcast, opts.MetadataKeys)
+		if err != nil {
+			return nil, fmt.Errorf("flattening %s %s: %w", event.Type, event.ID, err)
+		}
+
+		builder, exists := builders[event.Type]
+		if !exists {
+			builder = newTableBuilder(event.Type)
+			builders[event.Type] = builder
+		}
+		builder.add(record)
+	}
+
+	tables := make([]*Table, 0, len(builders))
+	for _, builder := range builders {
+		tables = append(tables, builder.build())
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].EventType < tables[j].EventType })
+	return tables, nil
+}
+
+// Export replays every event in store into flat tables and writes each to
+// dir/<event type>.<format>, returning the paths of the files written. The store is
+// scanned with common.QueryEvents, so only the selected event types are read into
+// memory when it scans natively.
+func Export(store common.Store, dir string, format Format, opts Options) ([]string, error) {
+	var write func(*os.File, *Table) error
+	switch format {
+	case FormatCSV:
+		write = func(f *os.File, t *Table) error { return WriteCSV(f, t) }
+	case FormatParquet:
+		write = func(f *os.File, t *Table) error { return WriteParquet(f, t) }
+	default:
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+
+	tables, err := tablesOf(common.QueryEvents(store, common.Filter{Types: opts.EventTypes}), opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(tables))
+	for _, table := range tables {
+		path := filepath.Join(dir, table.EventType+"."+string(format))
+		f, err := os.Create(path)
+		if err != nil {
+			return paths, err
+		}
+		err = write(f, table)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return paths, fmt.Errorf("writing %s: %w", path, err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// field is a flattened value of a record, before its column type is known
+type field struct {
+	name  string
+	value interface{}
+}
+
+// flatten turns an event into its envelope fields followed by its payload and the
+// selected metadata. Payload values are normalized through JSON so events read
+// from any store flatten alike.
+func flatten(event *common.Event, metadataKeys []string) ([]field, error) {
+	record := []field{
+		{ColumnEventID, event.ID},
+		{ColumnStreamID, event.AggregateID},
+		{ColumnVersion, int64(event.Version)},
+		{ColumnCreatedAt, event.CreatedAt},
+		{ColumnEffectiveAt, common.EffectiveTime(event)},
+	}
+
+	data, err := normalize(event.Data)
+	if err != nil {
+		return nil, err
+	}
+	var payload []field
+	flattenObject(data, "", &payload)
+	for _, f := range payload {
+		if isEnvelopeColumn(f.name) {
+			f.name = "data_" + f.name
+		}
+		record = append(record, f)
+	}
+
+	if len(metadataKeys) > 0 {
+		metadata, err := normalize(event.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range metadataKeys {
+			if value, exists := metadata[key]; exists {
+				flattenValue(value, "metadata_"+key, &record)
+			}
+		}
+	}
+	return record, nil
+}
+
+func flattenObject(object map[string]interface{}, prefix string, record *[]field) {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		flattenValue(object[key], prefix+key, record)
+	}
+}
+
+func flattenValue(value interface{}, name string, record *[]field) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		flattenObject(v, name+"_", record)
+	case []interface{}:
+		encoded, _ := json.Marshal(v)
+		*record = append(*record, field{name, string(encoded)})
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			*record = append(*record, field{name, n})
+		} else {
+			f, _ := v.Float64()
+			*record = append(*record, field{name, f})
+		}
+	default:
+		// string, bool, or nil
+		*record = append(*record, field{name, v})
+	}
+}
+
+// normalize round-trips a map through JSON, keeping numbers as json.Number
+func normalize(m map[string]interface{}) (map[string]interface{}, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var normalized map[string]interface{}
+	if err := decoder.Decode(&normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+func isEnvelopeColumn(name string) bool {
+	switch name {
+	case ColumnEventID, ColumnStreamID, ColumnVersion, ColumnCreatedAt, ColumnEffectiveAt:
+		return true
+	}
+	return strings.HasPrefix(name, "metadata_")
+}
+
+// tableBuilder collects the records of one event type, adding columns as new payload
+// fields appear and widening column types as values disagree
+type tableBuilder struct {
+	eventType string
+	columns   []Column
+	// typed records whether a column has seen a non-nil value yet
+	typed   []bool
+	index   map[string]int
+	records []map[int]interface{}
+}
+
+func newTableBuilder(eventType string) *tableBuilder {
+	return &tableBuilder{eventType: eventType, index: make(map[string]int)}
+}
+
+func (b *tableBuilder) add(record []field) {
+	row := make(map[int]interface{}, len(record))
+	for _, f := range record {
+		i, exists := b.index[f.name]
+		if !exists {
+			i = len(b.columns)
+			b.index[f.name] = i
+			b.columns = append(b.columns, Column{Name: f.name})
+			b.typed = append(b.typed, false)
+		}
+		if f.value == nil {
+			continue
+		}
+		row[i] = f.value
+
+		valueType := typeOf(f.value)
+		if !b.typed[i] {
+			b.columns[i].Type = valueType
+			b.typed[i] = true
+		} else {
+			b.columns[i].Type = widen(b.columns[i].Type, valueType)
+		}
+	}
+	b.records = append(b.records, row)
+}
+
+func (b *tableBuilder) build() *Table {
+	table := &Table{EventType: b.eventType, Columns: b.columns, Rows: make([][]interface{}, len(b.records))}
+	for r, record := range b.records {
+		row := make([]interface{}, len(b.columns))
+		for i, value := range record {
+			row[i] = convert(value, b.columns[i].Type)
+		}
+		table.Rows[r] = row
+	}
+	return table
+}
+
+func typeOf(value interface{}) ColumnType {
+	switch value.(type) {
+	case int64:
+		return Int64
+	case float64:
+		return Double
+	case bool:
+		return Boolean
+	case time.Time:
+		return Timestamp
+	default:
+		return String
+	}
+}
+
+// widen returns a column type holding values of both types
+func widen(a, b ColumnType) ColumnType {
+	switch {
+	case a == b:
+		return a
+	case (a == Int64 && b == Double) || (a == Double && b == Int64):
+		return Double
+	default:
+		return String
+	}
+}
+
+// convert returns value as the Go type of a column of type t
+func convert(value interface{}, t ColumnType) interface{} {
+	switch t {
+	case Double:
+		if n, ok := value.(int64); ok {
+			return float64(n)
+		}
+	case String:
+		switch v := value.(type) {
+		case string:
+			return v
+		case time.Time:
+			return v.Format(time.RFC3339Nano)
+		default:
+			encoded, _ := json.Marshal(v)
+			return string(encoded)
+		}
+	}
+	return value
+}
@@ -0,0 +1,281 @@
+package analytics
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"simple-event-modeling/common"
+)
+
+func analyticsEvents() []*common.Event {
+	return []*common.Event{
+		common.NewEvent("ItemAdded", "cart-1", 1, map[string]interface{}{"item": "apple", "quantity": 2}, map[string]interface{}{common.CustomerIDKey: "c-1"}),
+		common.NewEvent("ItemAdded", "cart-1", 2, map[string]interface{}{"item": "pear", "quantity": 1.5, "gift": true}, nil),
+		common.NewEvent("ItemAdded", "cart-2", 1, map[string]interface{}{
+			"item":     "plum",
+			"version":  "v2",
+			"shipping": map[string]interface{}{"city": "Lyon"},
+			"tags":     []interface{}{"fresh"},
+		}, nil),
+		common.NewEvent("CartCleared", "cart-1", 3, nil, nil),
+	}
+}
+
+func TestTables(t *testing.T) {
+	events := analyticsEvents()
+	tables, err := Tables(events, Options{MetadataKeys: []string{common.CustomerIDKey}})
+	if err != nil {
+		t.Fatalf("Error building tables: %v", err)
+	}
+	if len(tables) != 2 || tables[0].EventType != "CartCleared" || tables[1].EventType != "ItemAdded" {
+		t.Fatalf("Expected a table per event type, got %v", tables)
+	}
+
+	added := tables[1]
+	types := make(map[string]ColumnType)
+	for _, column := range added.Columns {
+		types[column.Name] = column.Type
+	}
+	expected := map[string]ColumnType{
+		ColumnEventID:           String,
+		ColumnStreamID:          String,
+		ColumnVersion:           Int64,
+		ColumnCreatedAt:         Timestamp,
+		ColumnEffectiveAt:       Timestamp,
+		"item":                  String,
+		"quantity":              Double,
+		"gift":                  Boolean,
+		"metadata_customer_id":  String,
+		"data_" + ColumnVersion: String,
+		"shipping_city":         String,
+		"tags":                  String,
+	}
+	for name, columnType := range expected {
+		if types[name] != columnType {
+			t.Errorf("Expected column %s of type %s, got %s", name, columnType, types[name])
+		}
+	}
+	if len(added.Rows) != 3 {
+		t.Fatalf("Expected 3 rows, got %d", len(added.Rows))
+	}
+	row := func(r int, name string) interface{} {
+		for i, column := range added.Columns {
+			if column.Name == name {
+				return added.Rows[r][i]
+			}
+		}
+		t.Fatalf("No column %s", name)
+		return nil
+	}
+	if row(0, "quantity") != 2.0 || row(0, "gift") != nil || row(2, "tags") != `["fresh"]` || row(0, ColumnVersion) != int64(1) {
+		t.Errorf("Unexpected rows: %v", added.Rows)
+	}
+
+	filtered, err := Tables(events, Options{EventTypes: []string{"CartCleared"}})
+	if err != nil || len(filtered) != 1 || len(filtered[0].Rows) != 1 {
+		t.Errorf("Expected only CartCleared, got %v (%v)", filtered, err)
+	}
+}
+
+func TestExport_CSV(t *testing.T) {
+	store := common.NewEventStore()
+	for _, event := range analyticsEvents() {
+		if err := store.Append(event); err != nil {
+			t.Fatalf("Error appending: %v", err)
+		}
+	}
+
+	dir := t.TempDir()
+	paths, err := Export(store, dir, FormatCSV, Options{EventTypes: []string{"ItemAdded"}})
+	if err != nil {
+		t.Fatalf("Error exporting: %v", err)
+	}
+	if len(paths) != 1 || paths[0] != filepath.Join(dir, "ItemAdded.csv") {
+		t.Fatalf("Unexpected files: %v", paths)
+	}
+
+	f, err := os.Open(paths[0])
+	if err != nil {
+		t.Fatalf("Error opening export: %v", err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(bufio.NewReader(f)).ReadAll()
+	if err != nil {
+		t.Fatalf("Error reading CSV: %v", err)
+	}
+	if len(records) != 4 || records[0][0] != ColumnEventID {
+		t.Fatalf("Expected a header and 3 rows, got %v", records)
+	}
+	if records[1][2] != "1" || records[2][2] != "2" {
+		t.Errorf("Unexpected versions: %v", records)
+	}
+
+	if _, err := Export(store, dir, "xlsx", Options{}); err == nil {
+		t.Error("Expected an unknown format to fail")
+	}
+}
+
+func TestWriteParquet(t *testing.T) {
+	tables, err := Tables(analyticsEvents(), Options{})
+	if err != nil {
+		t.Fatalf("Error building tables: %v", err)
+	}
+	table := tables[1]
+
+	var buf bytes.Buffer
+	if err := WriteParquet(&buf, table); err != nil {
+		t.Fatalf("Error writing Parquet: %v", err)
+	}
+	file := buf.Bytes()
+	if string(file[:4]) != "PAR1" || string(file[len(file)-4:]) != "PAR1" {
+		t.Fatal("Expected the Parquet magic at both ends")
+	}
+
+	length := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	footer := readStruct(t, bytes.NewReader(file[len(file)-8-length:len(file)-8]))
+	if footer[3] != int64(len(table.Rows)) {
+		t.Errorf("Expected %d rows, got %v", len(table.Rows), footer[3])
+	}
+	schema := footer[2].([]interface{})
+	if len(schema) != len(table.Columns)+1 {
+		t.Fatalf("Expected %d schema elements, got %d", len(table.Columns)+1, len(schema))
+	}
+
+	chunks := footer[4].([]interface{})[0].(map[int16]interface{})[1].([]interface{})
+	for i, column := range table.Columns {
+		element := schema[i+1].(map[int16]interface{})
+		if element[4] != column.Name {
+			t.Errorf("Expected column %s, got %v", column.Name, element[4])
+		}
+
+		meta := chunks[i].(map[int16]interface{})[3].(map[int16]interface{})
+		page := bytes.NewReader(file[meta[9].(int64):])
+		header := readStruct(t, page)
+		data := make([]byte, header[2].(int64))
+		io.ReadFull(page, data)
+
+		values := readColumn(t, column.Type, data, len(table.Rows))
+		for r, row := range table.Rows {
+			want := row[i]
+			if ts, ok := want.(time.Time); ok {
+				want = ts.UnixMicro()
+			}
+			if !reflect.DeepEqual(values[r], want) {
+				t.Errorf("Column %s row %d: expected %v, got %v", column.Name, r, want, values[r])
+			}
+		}
+	}
+}
+
+// readColumn decodes a data page written by WriteParquet
+func readColumn(t *testing.T, columnType ColumnType, data []byte, rows int) []interface{} {
+	t.Helper()
+	r := bytes.NewReader(data)
+	var levelsLength uint32
+	binary.Read(r, binary.LittleEndian, &levelsLength)
+	levels := make([]byte, levelsLength)
+	io.ReadFull(r, levels)
+	header, n := binary.Uvarint(levels)
+	if header&1 != 1 {
+		t.Fatal("Expected bit-packed definition levels")
+	}
+	packed := levels[n:]
+
+	var present []int
+	for i := 0; i < rows; i++ {
+		if packed[i/8]&(1<<(i%8)) != 0 {
+			present = append(present, i)
+		}
+	}
+
+	values := make([]interface{}, rows)
+	var bits []byte
+	if columnType == Boolean {
+		bits, _ = io.ReadAll(r)
+	}
+	for n, i := range present {
+		switch columnType {
+		case String:
+			var size uint32
+			binary.Read(r, binary.LittleEndian, &size)
+			s := make([]byte, size)
+			io.ReadFull(r, s)
+			values[i] = string(s)
+		case Int64, Timestamp:
+			var v int64
+			binary.Read(r, binary.LittleEndian, &v)
+			values[i] = v
+		case Double:
+			var v uint64
+			binary.Read(r, binary.LittleEndian, &v)
+			values[i] = math.Float64frombits(v)
+		case Boolean:
+			values[i] = bits[n/8]&(1<<(n%8)) != 0
+		}
+	}
+	return values
+}
+
+// readStruct decodes a Thrift compact protocol struct into its fields by ID
+func readStruct(t *testing.T, r *bytes.Reader) map[int16]interface{} {
+	t.Helper()
+	fields := make(map[int16]interface{})
+	var last int16
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			t.Fatalf("Error reading struct: %v", err)
+		}
+		if b == 0 {
+			return fields
+		}
+		id := last + int16(b>>4)
+		if b>>4 == 0 {
+			id = int16(readZigzag(r))
+		}
+		fields[id] = readValue(t, r, b&0x0f)
+		last = id
+	}
+}
+
+func readValue(t *testing.T, r *bytes.Reader, valueType byte) interface{} {
+	switch valueType {
+	case ctI32, ctI64:
+		return readZigzag(r)
+	case ctBinary:
+		size, _ := binary.ReadUvarint(r)
+		s := make([]byte, size)
+		io.ReadFull(r, s)
+		return string(s)
+	case ctStruct:
+		return readStruct(t, r)
+	case ctList:
+		header, _ := r.ReadByte()
+		size := int(header >> 4)
+		if size == 15 {
+			n, _ := binary.ReadUvarint(r)
+			size = int(n)
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = readValue(t, r, header&0x0f)
+		}
+		return list
+	}
+	t.Fatalf("Unexpected Thrift type %d", valueType)
+	return nil
+}
+
+func readZigzag(r *bytes.Reader) int64 {
+	v, _ := binary.ReadUvarint(r)
+	return int64(v>>1) ^ -int64(v&1)
+}
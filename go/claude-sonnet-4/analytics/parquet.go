@@ -0,0 +1,301 @@
+package analytics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+)
+
+// WriteParquet writes a table as a Parquet file with a single row group. Every column
+// is an optional leaf holding one uncompressed, PLAIN encoded data page, which any
+// Parquet reader can load; strings are UTF8 byte arrays and timestamps are
+// microseconds since the Unix epoch.
+func WriteParquet(w io.Writer, t *Table) error {
+	out := &countingWriter{w: w}
+	out.Write(parquetMagic)
+
+	chunks := make([]columnChunk, len(t.Columns))
+	for i, column := range t.Columns {
+		page := encodePage(column.Type, t.Rows, i)
+
+		header := &compactWriter{}
+		header.beginStruct()
+		header.i32(1, pageTypeData)
+		header.i32(2, int32(len(page)))
+		header.i32(3, int32(len(page)))
+		header.structField(5)
+		header.i32(1, int32(len(t.Rows)))
+		header.i32(2, encodingPlain)
+		header.i32(3, encodingRLE)
+		header.i32(4, encodingRLE)
+		header.endStruct()
+		header.endStruct()
+
+		chunks[i] = columnChunk{offset: out.n, size: int64(header.buf.Len() + len(page))}
+		out.Write(header.buf.Bytes())
+		out.Write(page)
+	}
+
+	footer := fileMetaData(t, chunks)
+	out.Write(footer)
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(footer)))
+	out.Write(length[:])
+	out.Write(parquetMagic)
+	return out.err
+}
+
+var parquetMagic = []byte("PAR1")
+
+// Parquet format enum values
+const (
+	pageTypeData = 0
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	repetitionOptional = 1
+
+	physicalBoolean   = 0
+	physicalInt64     = 2
+	physicalDouble    = 5
+	physicalByteArray = 6
+
+	convertedUTF8            = 0
+	convertedTimestampMicros = 10
+)
+
+// columnChunk locates a column's data page in the file
+type columnChunk struct {
+	offset int64
+	size   int64
+}
+
+// physicalType returns the Parquet physical type of a column type and its converted
+// type, or -1 when it has none
+func physicalType(t ColumnType) (physical, converted int32) {
+	switch t {
+	case Int64:
+		return physicalInt64, -1
+	case Double:
+		return physicalDouble, -1
+	case Boolean:
+		return physicalBoolean, -1
+	case Timestamp:
+		return physicalInt64, convertedTimestampMicros
+	default:
+		return physicalByteArray, convertedUTF8
+	}
+}
+
+// encodePage encodes column i of rows as a data page: the definition levels, which
+// mark missing values, followed by the PLAIN encoded values that are present
+func encodePage(t ColumnType, rows [][]interface{}, i int) []byte {
+	var page bytes.Buffer
+
+	// Definition levels use the RLE/bit-packed hybrid encoding with a bit width of 1,
+	// written as bit-packed groups of 8 and prefixed with their length
+	groups := (len(rows) + 7) / 8
+	levels := binary.AppendUvarint(nil, uint64(groups)<<1|1)
+	packed := make([]byte, groups)
+	for r, row := range rows {
+		if row[i] != nil {
+			packed[r/8] |= 1 << (r % 8)
+		}
+	}
+	levels = append(levels, packed...)
+	binary.Write(&page, binary.LittleEndian, uint32(len(levels)))
+	page.Write(levels)
+
+	var bits []byte
+	present := 0
+	for _, row := range rows {
+		switch v := row[i].(type) {
+		case nil:
+			continue
+		case string:
+			binary.Write(&page, binary.LittleEndian, uint32(len(v)))
+			page.WriteString(v)
+		case int64:
+			binary.Write(&page, binary.LittleEndian, v)
+		case float64:
+			binary.Write(&page, binary.LittleEndian, math.Float64bits(v))
+		case time.Time:
+			binary.Write(&page, binary.LittleEndian, v.UnixMicro())
+		case bool:
+			if present%8 == 0 {
+				bits = append(bits, 0)
+			}
+			if v {
+				bits[len(bits)-1] |= 1 << (present % 8)
+			}
+		}
+		present++
+	}
+	if t == Boolean {
+		page.Write(bits)
+	}
+	return page.Bytes()
+}
+
+// fileMetaData encodes the file footer describing the schema and the column chunks
+func fileMetaData(t *Table, chunks []columnChunk) []byte {
+	meta := &compactWriter{}
+	meta.beginStruct()
+	meta.i32(1, 1)
+
+	meta.list(2, ctStruct, len(t.Columns)+1)
+	meta.beginStruct()
+	meta.str(4, "schema")
+	meta.i32(5, int32(len(t.Columns)))
+	meta.endStruct()
+	for _, column := range t.Columns {
+		physical, converted := physicalType(column.Type)
+		meta.beginStruct()
+		meta.i32(1, physical)
+		meta.i32(3, repetitionOptional)
+		meta.str(4, column.Name)
+		if converted >= 0 {
+			meta.i32(6, converted)
+		}
+		meta.endStruct()
+	}
+
+	meta.i64(3, int64(len(t.Rows)))
+
+	var total int64
+	for _, chunk := range chunks {
+		total += chunk.size
+	}
+	meta.list(4, ctStruct, 1)
+	meta.beginStruct()
+	meta.list(1, ctStruct, len(chunks))
+	for i, chunk := range chunks {
+		physical, _ := physicalType(t.Columns[i].Type)
+		meta.beginStruct()
+		meta.i64(2, chunk.offset)
+		meta.structField(3)
+		meta.i32(1, physical)
+		meta.list(2, ctI32, 2)
+		meta.elemI32(encodingPlain)
+		meta.elemI32(encodingRLE)
+		meta.list(3, ctBinary, 1)
+		meta.elemString(t.Columns[i].Name)
+		meta.i32(4, 0) // uncompressed
+		meta.i64(5, int64(len(t.Rows)))
+		meta.i64(6, chunk.size)
+		meta.i64(7, chunk.size)
+		meta.i64(9, chunk.offset)
+		meta.endStruct()
+		meta.endStruct()
+	}
+	meta.i64(2, total)
+	meta.i64(3, int64(len(t.Rows)))
+	meta.endStruct()
+
+	meta.str(6, "simple-event-modeling analytics")
+	meta.endStruct()
+	return meta.buf.Bytes()
+}
+
+// Thrift compact protocol field types
+const (
+	ctI32    = 5
+	ctI64    = 6
+	ctBinary = 8
+	ctList   = 9
+	ctStruct = 12
+)
+
+// compactWriter encodes Thrift structs with the compact protocol Parquet uses for
+// page headers and the file footer
+type compactWriter struct {
+	buf bytes.Buffer
+	// last holds the last field ID written in each open struct
+	last []int16
+}
+
+func (w *compactWriter) beginStruct() {
+	w.last = append(w.last, 0)
+}
+
+func (w *compactWriter) endStruct() {
+	w.buf.WriteByte(0)
+	w.last = w.last[:len(w.last)-1]
+}
+
+func (w *compactWriter) field(id int16, fieldType byte) {
+	last := &w.last[len(w.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | fieldType)
+	} else {
+		w.buf.WriteByte(fieldType)
+		w.varint(int64(id))
+	}
+	*last = id
+}
+
+func (w *compactWriter) structField(id int16) {
+	w.field(id, ctStruct)
+	w.beginStruct()
+}
+
+func (w *compactWriter) i32(id int16, v int32) {
+	w.field(id, ctI32)
+	w.varint(int64(v))
+}
+
+func (w *compactWriter) i64(id int16, v int64) {
+	w.field(id, ctI64)
+	w.varint(v)
+}
+
+func (w *compactWriter) str(id int16, s string) {
+	w.field(id, ctBinary)
+	w.elemString(s)
+}
+
+// list starts a list field; its elements are written with the elem methods or, for
+// structs, beginStruct and endStruct
+func (w *compactWriter) list(id int16, elemType byte, size int) {
+	w.field(id, ctList)
+	if size < 15 {
+		w.buf.WriteByte(byte(size)<<4 | elemType)
+		return
+	}
+	w.buf.WriteByte(0xf0 | elemType)
+	w.buf.Write(binary.AppendUvarint(nil, uint64(size)))
+}
+
+func (w *compactWriter) elemI32(v int32) {
+	w.varint(int64(v))
+}
+
+func (w *compactWriter) elemString(s string) {
+	w.buf.Write(binary.AppendUvarint(nil, uint64(len(s))))
+	w.buf.WriteString(s)
+}
+
+// varint writes a zigzag encoded integer
+func (w *compactWriter) varint(v int64) {
+	w.buf.Write(binary.AppendUvarint(nil, uint64(v<<1^v>>63)))
+}
+
+// countingWriter tracks the file offset and keeps the first write error
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
@@ -0,0 +1,453 @@
+// Package archive provides an archival tier that moves old events out of a hot store
+// into object storage in compressed chunks. The archive Store wraps the hot store and
+// reads through to the archived chunks, so full replays, audits, and aggregate
+// hydration still see every event.
+//
+// Chunks are gzip-compressed JSON lines, one object per run of consecutive versions
+// of a stream, under the key <prefix>/<stream>/<first>-<last>.jsonl.gz. They are
+// written before the events are truncated from the hot store, so a failed run is
+// safely retried: events present in both places are read once. Every line keeps
+// the event's position in the hot store's global log (see common.Event.Position),
+// so archived events merge back into the log where they were, and checkpoints of
+// subscribers stay valid across archival.
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"simple-event-modeling/common"
+)
+
+// DefaultPrefix is the key prefix used when New is given an empty one
+const DefaultPrefix = "archive"
+
+// DefaultChunkSize is the number of events written per object when Policy.ChunkSize is unset
+const DefaultChunkSize = 1000
+
+// HotStore is a Store that can drop the oldest events of a stream once they are
+// archived, such as *common.EventStore
+type HotStore interface {
+	common.Store
+	// TruncateStream drops the events of a stream below beforeVersion, always
+	// keeping the latest one
+	TruncateStream(aggregateID string, beforeVersion int) error
+}
+
+// Policy selects the events to archive. An event is archived when it is older than
+// OlderThan and below the stream's snapshot version; the latest event of a stream
+// always stays hot so the stream keeps its version.
+type Policy struct {
+	// OlderThan is the minimum age of an archived event
+	OlderThan time.Duration
+	// SnapshotVersion returns the version of the latest snapshot of a stream; events
+	// at or above it are kept hot. Nil applies only the age threshold.
+	SnapshotVersion func(aggregateID string) int
+	// ChunkSize caps the number of events per object, defaulting to DefaultChunkSize
+	ChunkSize int
+	// Now returns the current time, defaulting to time.Now
+	Now func() time.Time
+}
+
+// Result summarizes an archival run
+type Result struct {
+	Streams int
+	Events  int
+	Chunks  int
+}
+
+// Store is a Store over a hot store and its archived chunks. Appends go to the hot
+// store; reads of a stream or of the whole log include the archived events.
+type Store struct {
+	hot     HotStore
+	objects ObjectStore
+	prefix  string
+
+	mu     sync.Mutex
+	chunks map[string][]*common.Event
+
+	// indexMu guards the index of archived events in global order, built from
+	// object storage on first read and kept up to date by writeChunk
+	indexMu     sync.Mutex
+	indexed     bool
+	index       []*common.Event
+	indexedKeys map[string]bool
+	indexedIDs  map[string]bool
+}
+
+var _ common.Store = (*Store)(nil)
+
+// New wraps hot with an archive kept in objects under prefix
+func New(hot HotStore, objects ObjectStore, prefix string) *Store {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	return &Store{
+		hot:     hot,
+		objects: objects,
+		prefix:  prefix,
+		chunks:  make(map[string][]*common.Event),
+	}
+}
+
+// Archive moves the events selected by policy from the hot store to object storage
+func (s *Store) Archive(policy Policy) (Result, error) {
+	now := time.Now
+	if policy.Now != nil {
+		now = policy.Now
+	}
+	chunkSize := policy.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	cutoff := now().Add(-policy.OlderThan)
+
+	var result Result
+	for _, id := range s.hot.StreamIDs() {
+		stream, err := s.hot.GetStream(id)
+		if err != nil {
+			return result, err
+		}
+		limit := len(stream) - 1
+		if policy.SnapshotVersion != nil {
+			snapshot := policy.SnapshotVersion(id)
+			for limit > 0 && stream[limit-1].Version >= snapshot {
+				limit--
+			}
+		}
+
+		n := 0
+		for n < limit && stream[n].CreatedAt.Before(cutoff) {
+			n++
+		}
+		if n == 0 {
+			continue
+		}
+
+		chunks, err := s.archive(id, stream[:n], chunkSize)
+		result.Chunks += chunks
+		if err != nil {
+			return result, err
+		}
+		if err := s.hot.TruncateStream(id, stream[n].Version); err != nil {
+			return result, err
+		}
+		result.Streams++
+		result.Events += n
+	}
+	return result, nil
+}
+
+// ArchiveStream moves the events of a stream below beforeVersion from the hot store
+// to object storage, in chunks of DefaultChunkSize, regardless of their age. The
+// stream's latest event stays hot. It returns the number of events moved.
+func (s *Store) ArchiveStream(aggregateID string, beforeVersion int) (int, error) {
+	stream, err := s.hot.GetStream(aggregateID)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for n < len(stream)-1 && stream[n].Version < beforeVersion {
+		n++
+	}
+	if n == 0 {
+		return 0, nil
+	}
+
+	if _, err := s.archive(aggregateID, stream[:n], DefaultChunkSize); err != nil {
+		return 0, err
+	}
+	if err := s.hot.TruncateStream(aggregateID, stream[n].Version); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// archive writes events of a stream in chunks of chunkSize, returning how many
+// chunks were written
+func (s *Store) archive(aggregateID string, events []*common.Event, chunkSize int) (int, error) {
+	chunks := 0
+	for start := 0; start < len(events); start += chunkSize {
+		end := min(start+chunkSize, len(events))
+		if err := s.writeChunk(aggregateID, events[start:end]); err != nil {
+			return chunks, err
+		}
+		chunks++
+	}
+	return chunks, nil
+}
+
+// Append adds an event to the hot store
+func (s *Store) Append(event *common.Event) error {
+	return s.hot.Append(event)
+}
+
+// GetStream returns the archived events of a stream followed by its hot events
+func (s *Store) GetStream(aggregateID string) ([]*common.Event, error) {
+	archived, err := s.archivedStream(aggregateID)
+	if err != nil {
+		return nil, err
+	}
+	hot, err := s.hot.GetStream(aggregateID)
+	var notFound *common.StreamNotFoundError
+	if err != nil && !(errors.As(err, &notFound) && len(archived) > 0) {
+		return nil, err
+	}
+
+	stream := archived
+	for _, event := range hot {
+		if len(stream) == 0 || event.Version > stream[len(stream)-1].Version {
+			stream = append(stream, event)
+		}
+	}
+	return stream, nil
+}
+
+// GetStreamVersion returns the version of a stream in the hot store
+func (s *Store) GetStreamVersion(aggregateID string) int {
+	return s.hot.GetStreamVersion(aggregateID)
+}
+
+// StreamLength returns the number of archived and hot events of a stream
+func (s *Store) StreamLength(aggregateID string) int {
+	stream, err := s.GetStream(aggregateID)
+	if err != nil {
+		return 0
+	}
+	return len(stream)
+}
+
+// HeadEvent returns the last event of a stream from the hot store, which always
+// keeps the latest event of an archived stream
+func (s *Store) HeadEvent(aggregateID string) (*common.Event, error) {
+	return s.hot.HeadEvent(aggregateID)
+}
+
+// GetAllEvents returns every archived and hot event in global order (see ReadAll).
+// When the archive cannot be read it returns no events rather than a log missing
+// the archived ones, so subscribers wait instead of checkpointing past them; call
+// ReadAll for the error.
+func (s *Store) GetAllEvents() []*common.Event {
+	all, err := s.ReadAll()
+	if err != nil {
+		return nil
+	}
+	return all
+}
+
+// ReadAll returns every archived and hot event, merged by position in the hot
+// store's global log. Events archived without a position are merged by creation
+// time and ID instead (see common.EventBefore). It fails if any archived chunk
+// cannot be listed or read.
+func (s *Store) ReadAll() ([]*common.Event, error) {
+	archived, archivedIDs, err := s.archivedLog()
+	if err != nil {
+		return nil, err
+	}
+
+	hot := s.hot.GetAllEvents()
+	all := make([]*common.Event, 0, len(archived)+len(hot))
+	for _, event := range hot {
+		if archivedIDs[event.ID] {
+			continue
+		}
+		for len(archived) > 0 && logBefore(archived[0], event) {
+			all = append(all, archived[0])
+			archived = archived[1:]
+		}
+		all = append(all, event)
+	}
+	return append(all, archived...), nil
+}
+
+// StreamIDs returns the identifiers of all streams in the hot store, sorted. Every
+// archived stream keeps its latest event hot, so none are missing.
+func (s *Store) StreamIDs() []string {
+	return s.hot.StreamIDs()
+}
+
+// chunkLine is a line of a chunk: the event's JSON with its global position, which
+// the event itself leaves out. Chunks written before positions were kept have none.
+type chunkLine struct {
+	*common.Event
+	Position int `json:"position,omitempty"`
+}
+
+func (s *Store) writeChunk(aggregateID string, events []*common.Event) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	encoder := json.NewEncoder(gz)
+	for _, event := range events {
+		if err := encoder.Encode(chunkLine{Event: event, Position: event.Position}); err != nil {
+			return err
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s%010d-%010d.jsonl.gz", s.streamPrefix(aggregateID), events[0].Version, events[len(events)-1].Version)
+	if err := s.objects.Put(key, buf.Bytes()); err != nil {
+		return fmt.Errorf("archiving %s: %w", key, err)
+	}
+
+	s.mu.Lock()
+	s.chunks[key] = events
+	s.mu.Unlock()
+
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+	if s.indexed {
+		s.indexChunk(key, events)
+	}
+	return nil
+}
+
+// archivedLog returns the archived events in global order and their IDs. The first
+// call reads every chunk into the index; a failure leaves the index unbuilt, so the
+// next call tries again.
+func (s *Store) archivedLog() ([]*common.Event, map[string]bool, error) {
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+	if s.indexed {
+		return s.index, s.indexedIDs, nil
+	}
+
+	keys, err := s.objects.List(s.prefix + "/")
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing archived chunks: %w", err)
+	}
+	chunks := make([][]*common.Event, len(keys))
+	for i, key := range keys {
+		if chunks[i], err = s.readChunk(key); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	index := make([]*common.Event, 0)
+	s.indexedKeys = make(map[string]bool, len(keys))
+	s.indexedIDs = make(map[string]bool)
+	for i, key := range keys {
+		s.indexedKeys[key] = true
+		index = appendNew(index, s.indexedIDs, chunks[i])
+	}
+	sortLog(index)
+	s.index, s.indexed = index, true
+	return s.index, s.indexedIDs, nil
+}
+
+// indexChunk adds the events of a chunk to the index, skipping events of chunks
+// from a retried run that are already in it. The index and ID set are replaced,
+// not modified, so callers still holding the old ones are unaffected. Callers must
+// hold s.indexMu.
+func (s *Store) indexChunk(key string, events []*common.Event) {
+	if s.indexedKeys[key] {
+		return
+	}
+	s.indexedKeys[key] = true
+
+	index := append(make([]*common.Event, 0, len(s.index)+len(events)), s.index...)
+	ids := make(map[string]bool, len(s.indexedIDs)+len(events))
+	for id := range s.indexedIDs {
+		ids[id] = true
+	}
+	index = appendNew(index, ids, events)
+	sortLog(index)
+	s.index, s.indexedIDs = index, ids
+}
+
+// appendNew appends the events whose IDs are not in ids, adding them to it
+func appendNew(index []*common.Event, ids map[string]bool, events []*common.Event) []*common.Event {
+	for _, event := range events {
+		if !ids[event.ID] {
+			ids[event.ID] = true
+			index = append(index, event)
+		}
+	}
+	return index
+}
+
+// sortLog orders events as in the global log (see logBefore)
+func sortLog(events []*common.Event) {
+	sort.SliceStable(events, func(i, j int) bool { return logBefore(events[i], events[j]) })
+}
+
+// logBefore reports whether a comes before b in the global log: by position when
+// both have one, otherwise by creation time and ID
+func logBefore(a, b *common.Event) bool {
+	if a.Position > 0 && b.Position > 0 {
+		return a.Position < b.Position
+	}
+	return common.EventBefore(a, b)
+}
+
+// archivedStream reads the archived events of a stream in version order
+func (s *Store) archivedStream(aggregateID string) ([]*common.Event, error) {
+	keys, err := s.objects.List(s.streamPrefix(aggregateID))
+	if err != nil {
+		return nil, err
+	}
+
+	stream := make([]*common.Event, 0)
+	for _, key := range keys {
+		events, err := s.readChunk(key)
+		if err != nil {
+			return nil, err
+		}
+		// Chunks from a retried run may overlap earlier ones
+		for _, event := range events {
+			if len(stream) == 0 || event.Version > stream[len(stream)-1].Version {
+				stream = append(stream, event)
+			}
+		}
+	}
+	return stream, nil
+}
+
+// readChunk decodes a chunk, caching it since archived chunks never change
+func (s *Store) readChunk(key string) ([]*common.Event, error) {
+	s.mu.Lock()
+	events, cached := s.chunks[key]
+	s.mu.Unlock()
+	if cached {
+		return events, nil
+	}
+
+	data, err := s.objects.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", key, err)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", key, err)
+	}
+	decoder := json.NewDecoder(gz)
+	for decoder.More() {
+		line := chunkLine{Event: &common.Event{}}
+		if err := decoder.Decode(&line); err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", key, err)
+		}
+		line.Event.Position = line.Position
+		events = append(events, line.Event)
+	}
+
+	s.mu.Lock()
+	s.chunks[key] = events
+	s.mu.Unlock()
+	return events, nil
+}
+
+// streamPrefix returns the key prefix of a stream's chunks. Stream IDs are escaped
+// so they form a single path segment.
+func (s *Store) streamPrefix(aggregateID string) string {
+	return s.prefix + "/" + url.PathEscape(aggregateID) + "/"
+}
@@ -0,0 +1,198 @@
+package archive
+
+import (
+	"errors"
+	"simple-event-modeling/common"
+	"testing"
+	"time"
+)
+
+// seed appends versions 1..n of a stream, created a day apart ending at now
+func seed(store *common.EventStore, id string, n int, now time.Time) {
+	for version := 1; version <= n; version++ {
+		event := common.NewEvent("Changed", id, version, map[string]interface{}{"n": version}, nil)
+		event.CreatedAt = now.Add(time.Duration(version-n) * 24 * time.Hour)
+		store.Append(event)
+	}
+}
+
+func TestArchive_MovesOldEventsAndReadsThrough(t *testing.T) {
+	now := time.Now()
+	hot := common.NewEventStore()
+	seed(hot, "cart-1", 10, now)
+	seed(hot, "cart-2", 2, now)
+	store := New(hot, Dir(t.TempDir()), "")
+
+	result, err := store.Archive(Policy{
+		OlderThan:       3 * 24 * time.Hour,
+		SnapshotVersion: func(id string) int { return 6 },
+		ChunkSize:       2,
+		Now:             func() time.Time { return now },
+	})
+	if err != nil {
+		t.Fatalf("Error archiving: %v", err)
+	}
+	// cart-1 versions 1-5 are below the snapshot and older than 3 days; cart-2 is too recent
+	if result.Streams != 1 || result.Events != 5 || result.Chunks != 3 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if hotStream, _ := hot.GetStream("cart-1"); len(hotStream) != 5 || hotStream[0].Version != 6 {
+		t.Errorf("Expected versions 6-10 to stay hot, got %v", hotStream)
+	}
+
+	stream, err := store.GetStream("cart-1")
+	if err != nil {
+		t.Fatalf("Error reading stream: %v", err)
+	}
+	for i, event := range stream {
+		if event.Version != i+1 {
+			t.Fatalf("Expected versions 1-10 in order, got %d at %d", event.Version, i)
+		}
+	}
+	if len(stream) != 10 {
+		t.Errorf("Expected 10 events, got %d", len(stream))
+	}
+
+	all := store.GetAllEvents()
+	if len(all) != 12 || all[0].AggregateID != "cart-1" || all[0].Version != 1 {
+		t.Errorf("Expected all 12 events starting with the oldest archived one, got %d", len(all))
+	}
+	if store.GetStreamVersion("cart-1") != 10 {
+		t.Errorf("Expected version 10, got %d", store.GetStreamVersion("cart-1"))
+	}
+}
+
+func TestArchive_ReadsFromAFreshStore(t *testing.T) {
+	now := time.Now()
+	objects := Dir(t.TempDir())
+	hot := common.NewEventStore()
+	seed(hot, "cart/1", 4, now)
+	New(hot, objects, "events").Archive(Policy{Now: func() time.Time { return now }})
+
+	stream, err := New(hot, objects, "events").GetStream("cart/1")
+	if err != nil {
+		t.Fatalf("Error reading stream: %v", err)
+	}
+	if len(stream) != 4 || stream[0].Data["n"] != float64(1) {
+		t.Errorf("Expected 4 events read back from object storage, got %v", stream)
+	}
+}
+
+func TestArchive_KeepsLatestEventHot(t *testing.T) {
+	hot := common.NewEventStore()
+	seed(hot, "cart-1", 3, time.Now().Add(-time.Hour))
+	store := New(hot, Dir(t.TempDir()), "")
+
+	store.Archive(Policy{})
+	if err := store.Append(common.NewEvent("Changed", "cart-1", 4, nil, nil)); err != nil {
+		t.Errorf("Expected appends to follow the archived stream, got %v", err)
+	}
+	var conflict *common.ConcurrencyError
+	if err := store.Append(common.NewEvent("Changed", "cart-1", 2, nil, nil)); !errors.As(err, &conflict) {
+		t.Errorf("Expected ConcurrencyError, got %v", err)
+	}
+	if _, err := store.GetStream("missing"); err == nil {
+		t.Error("Expected error for missing stream")
+	}
+}
+
+func TestArchive_ArchiveStream(t *testing.T) {
+	hot := common.NewEventStore()
+	seed(hot, "cart-1", 5, time.Now())
+	store := New(hot, Dir(t.TempDir()), "")
+
+	moved, err := store.ArchiveStream("cart-1", 4)
+	if err != nil || moved != 3 {
+		t.Fatalf("Expected 3 events archived regardless of age, got %d, %v", moved, err)
+	}
+	if hotStream, _ := hot.GetStream("cart-1"); len(hotStream) != 2 || hotStream[0].Version != 4 {
+		t.Errorf("Expected versions 4-5 to stay hot, got %v", hotStream)
+	}
+	if stream, _ := store.GetStream("cart-1"); len(stream) != 5 {
+		t.Errorf("Expected all 5 events read through, got %d", len(stream))
+	}
+
+	if moved, err := store.ArchiveStream("cart-1", 100); err != nil || moved != 1 {
+		t.Errorf("Expected only the latest event to stay hot, got %d, %v", moved, err)
+	}
+}
+
+// countingObjects counts the reads of an ObjectStore
+type countingObjects struct {
+	ObjectStore
+	reads int
+}
+
+func (o *countingObjects) Get(key string) ([]byte, error) {
+	o.reads++
+	return o.ObjectStore.Get(key)
+}
+
+func (o *countingObjects) List(prefix string) ([]string, error) {
+	o.reads++
+	return o.ObjectStore.List(prefix)
+}
+
+func TestArchive_KeepsGlobalPositions(t *testing.T) {
+	now := time.Now()
+	hot := common.NewEventStore()
+	seed(hot, "cart-1", 3, now)
+	// Created earlier than cart-1, but appended after it
+	seed(hot, "cart-2", 3, now.Add(-time.Hour))
+	objects := Dir(t.TempDir())
+	store := New(hot, objects, "")
+
+	follower := common.NewFollower("counter", store)
+	noop := func([]*common.Event, []int) error { return nil }
+	follower.Process(0, noop)
+	if _, err := store.Archive(Policy{Now: func() time.Time { return now }}); err != nil {
+		t.Fatalf("Error archiving: %v", err)
+	}
+	hot.Append(common.NewEvent("Changed", "cart-1", 4, nil, nil))
+
+	var positions []int
+	follower.Process(0, func(_ []*common.Event, batch []int) error {
+		positions = append(positions, batch...)
+		return nil
+	})
+	if len(positions) != 1 || positions[0] != 7 {
+		t.Errorf("Expected only the event appended after archiving, got %v", positions)
+	}
+
+	// A fresh store reads the positions back from object storage
+	counted := &countingObjects{ObjectStore: objects}
+	fresh := New(hot, counted, "")
+	all, err := fresh.ReadAll()
+	if err != nil || len(all) != 7 {
+		t.Fatalf("Expected all 7 events, got %d (%v)", len(all), err)
+	}
+	for i, event := range all {
+		if event.Position != i+1 {
+			t.Errorf("Expected position %d, got %s v%d at %d", i+1, event.AggregateID, event.Version, event.Position)
+		}
+	}
+	reads := counted.reads
+	fresh.GetAllEvents()
+	if counted.reads != reads {
+		t.Errorf("Expected the archived chunks indexed once, got %d more reads", counted.reads-reads)
+	}
+}
+
+func TestArchive_RefusesPartialLog(t *testing.T) {
+	now := time.Now()
+	hot := common.NewEventStore()
+	seed(hot, "cart-1", 3, now)
+	seed(hot, "cart-2", 3, now)
+	objects := Dir(t.TempDir())
+	New(hot, objects, "").Archive(Policy{Now: func() time.Time { return now }})
+
+	keys, _ := objects.List("archive/cart-2/")
+	objects.Put(keys[0], []byte("not gzip"))
+	store := New(hot, objects, "")
+	if _, err := store.ReadAll(); err == nil {
+		t.Error("Expected an error reading a corrupt chunk")
+	}
+	if all := store.GetAllEvents(); len(all) != 0 {
+		t.Errorf("Expected no events rather than a partial log, got %d", len(all))
+	}
+}
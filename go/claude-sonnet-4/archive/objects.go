@@ -0,0 +1,81 @@
+package archive
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrObjectNotFound is returned by ObjectStore.Get for a key that was never written
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectStore is the subset of an object storage API the archive needs. An S3 (or
+// GCS, MinIO, Azure Blob) client adapts to it with PutObject, GetObject, and
+// ListObjectsV2 on a fixed bucket.
+type ObjectStore interface {
+	// Put writes data under key, replacing any existing object
+	Put(key string, data []byte) error
+	// Get reads the object at key, or returns ErrObjectNotFound
+	Get(key string) ([]byte, error)
+	// List returns the keys starting with prefix, sorted
+	List(prefix string) ([]string, error)
+}
+
+// Dir is an ObjectStore keeping objects as files under a local directory, for
+// development, tests, and mounted network storage
+type Dir string
+
+var _ ObjectStore = Dir("")
+
+// Put writes data to the file for key, creating parent directories as needed
+func (d Dir) Put(key string, data []byte) error {
+	path := d.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// Write to a temporary file first so readers never see a partial object
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Get reads the file for key
+func (d Dir) Get(key string) ([]byte, error) {
+	data, err := os.ReadFile(d.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrObjectNotFound
+	}
+	return data, err
+}
+
+// List walks the directory for keys starting with prefix
+func (d Dir) List(prefix string) ([]string, error) {
+	keys := make([]string, 0)
+	err := filepath.WalkDir(string(d), func(path string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && path == string(d) {
+			return filepath.SkipDir
+		}
+		if err != nil || entry.IsDir() || strings.HasSuffix(path, ".tmp") {
+			return err
+		}
+		rel, err := filepath.Rel(string(d), path)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	sort.Strings(keys)
+	return keys, err
+}
+
+func (d Dir) path(key string) string {
+	return filepath.Join(string(d), filepath.FromSlash(key))
+}
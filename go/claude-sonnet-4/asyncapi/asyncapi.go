@@ -0,0 +1,194 @@
+// Package asyncapi generates an AsyncAPI 2.6 document describing the events published
+// by the registered domains. Each aggregate's events are published on one channel, and
+// every event type becomes a message whose payload schema is the event envelope with
+// the registered Data fields.
+package asyncapi
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"simple-event-modeling/common"
+)
+
+// Version is the AsyncAPI specification version of generated documents
+const Version = "2.6.0"
+
+// Config describes how events are published to the broker
+type Config struct {
+	Title       string
+	Version     string
+	Description string
+	// ServerURL and Protocol describe the broker; the servers section is omitted when ServerURL is empty
+	ServerURL string
+	Protocol  string
+	// ChannelFor maps an aggregate type to the channel its events are published on.
+	// DefaultChannel is used when nil.
+	ChannelFor func(aggregate string) string
+}
+
+// DefaultChannel publishes each aggregate's events on "<aggregate>.events"
+func DefaultChannel(aggregate string) string {
+	return strings.ToLower(aggregate) + ".events"
+}
+
+// Document is an AsyncAPI document
+type Document struct {
+	AsyncAPI   string             `json:"asyncapi"`
+	Info       Info               `json:"info"`
+	Servers    map[string]Server  `json:"servers,omitempty"`
+	Channels   map[string]Channel `json:"channels"`
+	Components Components         `json:"components"`
+}
+
+// Info holds the document metadata
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Server describes a broker
+type Server struct {
+	URL      string `json:"url"`
+	Protocol string `json:"protocol"`
+}
+
+// Channel describes the messages available on a channel
+type Channel struct {
+	Description string    `json:"description,omitempty"`
+	Subscribe   Operation `json:"subscribe"`
+}
+
+// Operation describes what consumers of a channel receive
+type Operation struct {
+	OperationID string           `json:"operationId"`
+	Message     map[string][]Ref `json:"message"`
+}
+
+// Ref is a JSON reference to a component
+type Ref struct {
+	Ref string `json:"$ref"`
+}
+
+// Components holds the reusable messages and schemas
+type Components struct {
+	Messages map[string]Message `json:"messages"`
+	Schemas  map[string]Schema  `json:"schemas"`
+}
+
+// Message describes a single event type
+type Message struct {
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	ContentType string `json:"contentType"`
+	Payload     Ref    `json:"payload"`
+}
+
+// Schema is the subset of JSON Schema used to describe payloads
+type Schema struct {
+	Type        string            `json:"type"`
+	Format      string            `json:"format,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Const       string            `json:"const,omitempty"`
+	Properties  map[string]Schema `json:"properties,omitempty"`
+	Required    []string          `json:"required,omitempty"`
+}
+
+// Generate builds the AsyncAPI document for the events in the registry
+func Generate(registry *common.Registry, config Config) Document {
+	channelFor := config.ChannelFor
+	if channelFor == nil {
+		channelFor = DefaultChannel
+	}
+
+	doc := Document{
+		AsyncAPI: Version,
+		Info: Info{
+			Title:       config.Title,
+			Version:     config.Version,
+			Description: config.Description,
+		},
+		Channels: make(map[string]Channel),
+		Components: Components{
+			Messages: make(map[string]Message),
+			Schemas:  make(map[string]Schema),
+		},
+	}
+	if config.ServerURL != "" {
+		doc.Servers = map[string]Server{"broker": {URL: config.ServerURL, Protocol: config.Protocol}}
+	}
+
+	byChannel := make(map[string][]common.EventInfo)
+	for _, event := range registry.Events() {
+		channel := channelFor(event.Aggregate)
+		byChannel[channel] = append(byChannel[channel], event)
+
+		doc.Components.Schemas[event.Name] = envelopeSchema(event)
+		doc.Components.Messages[event.Name] = Message{
+			Name:        event.Name,
+			Title:       event.Name + " event",
+			ContentType: "application/json",
+			Payload:     Ref{Ref: "#/components/schemas/" + event.Name},
+		}
+	}
+
+	channels := make([]string, 0, len(byChannel))
+	for channel := range byChannel {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	for _, channel := range channels {
+		events := byChannel[channel]
+		refs := make([]Ref, len(events))
+		for i, event := range events {
+			refs[i] = Ref{Ref: "#/components/messages/" + event.Name}
+		}
+		doc.Channels[channel] = Channel{
+			Description: "Events published by the " + events[0].Aggregate + " aggregate",
+			Subscribe: Operation{
+				OperationID: "receive" + events[0].Aggregate + "Events",
+				Message:     map[string][]Ref{"oneOf": refs},
+			},
+		}
+	}
+	return doc
+}
+
+// JSON renders the document as indented JSON
+func (d Document) JSON() ([]byte, error) {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// envelopeSchema describes the common.Event envelope carrying the event's payload fields
+func envelopeSchema(event common.EventInfo) Schema {
+	data := Schema{Type: "object", Properties: make(map[string]Schema)}
+	for _, field := range event.Payload {
+		data.Properties[field.Name] = Schema{Type: field.Type, Description: field.Description}
+		if !field.Optional {
+			data.Required = append(data.Required, field.Name)
+		}
+	}
+
+	return Schema{
+		Type: "object",
+		Properties: map[string]Schema{
+			"id":           {Type: "string", Format: "uuid"},
+			"type":         {Type: "string", Const: event.Name},
+			"created_at":   {Type: "string", Format: "date-time"},
+			"effective_at": {Type: "string", Format: "date-time"},
+			"aggregate_id": {Type: "string"},
+			"version":      {Type: "integer"},
+			"data":         data,
+			"metadata":     {Type: "object"},
+			"payload":      {Type: "string", Format: "byte"},
+			"content_type": {Type: "string"},
+		},
+		Required: []string{"id", "type", "created_at", "aggregate_id", "version", "data"},
+	}
+}
@@ -0,0 +1,75 @@
+package asyncapi
+
+import (
+	"os"
+	"simple-event-modeling/common"
+	"testing"
+
+	// Register the cart domain with common.DefaultRegistry
+	_ "simple-event-modeling/cart"
+)
+
+// cartConfig is the configuration docs/asyncapi.json is generated with
+var cartConfig = Config{Title: "Cart Events", Version: "1.0.0"}
+
+func TestGenerate_CartEvents(t *testing.T) {
+	doc := Generate(common.DefaultRegistry, cartConfig)
+
+	if doc.AsyncAPI != Version {
+		t.Errorf("Expected AsyncAPI version %s, got %s", Version, doc.AsyncAPI)
+	}
+	channel, ok := doc.Channels["cart.events"]
+	if !ok {
+		t.Fatalf("Expected cart.events channel, got %v", doc.Channels)
+	}
+	if len(channel.Subscribe.Message["oneOf"]) != 15 {
+		t.Errorf("Expected 15 cart messages, got %v", channel.Subscribe.Message["oneOf"])
+	}
+
+	schema, ok := doc.Components.Schemas["ItemAdded"]
+	if !ok {
+		t.Fatal("Expected ItemAdded schema")
+	}
+	data := schema.Properties["data"]
+	if data.Properties["item"].Type != "string" || len(data.Required) != 1 {
+		t.Errorf("Unexpected ItemAdded data schema: %+v", data)
+	}
+	if schema.Properties["type"].Const != "ItemAdded" {
+		t.Errorf("Expected type const ItemAdded, got %q", schema.Properties["type"].Const)
+	}
+}
+
+func TestGenerate_CustomChannelAndServer(t *testing.T) {
+	registry := common.NewRegistry()
+	registry.RegisterEvent(common.EventInfo{Name: "OrderPlaced", Aggregate: "Order"})
+
+	doc := Generate(registry, Config{
+		Title:      "Orders",
+		Version:    "2.0.0",
+		ServerURL:  "nats://localhost:4222",
+		Protocol:   "nats",
+		ChannelFor: func(aggregate string) string { return "shop." + aggregate },
+	})
+	if _, ok := doc.Channels["shop.Order"]; !ok {
+		t.Errorf("Expected custom channel name, got %v", doc.Channels)
+	}
+	if doc.Servers["broker"].Protocol != "nats" {
+		t.Errorf("Expected nats server, got %v", doc.Servers)
+	}
+}
+
+// TestAsyncAPIDocIsCurrent keeps docs/asyncapi.json in sync with the registered domains.
+// Regenerate it with: go run ./cmd/sem asyncapi > docs/asyncapi.json
+func TestAsyncAPIDocIsCurrent(t *testing.T) {
+	doc, err := os.ReadFile("../docs/asyncapi.json")
+	if err != nil {
+		t.Fatalf("Error reading AsyncAPI doc: %v", err)
+	}
+	expected, err := Generate(common.DefaultRegistry, cartConfig).JSON()
+	if err != nil {
+		t.Fatalf("Error encoding document: %v", err)
+	}
+	if string(doc) != string(expected) {
+		t.Error("docs/asyncapi.json is out of date; regenerate it with `go run ./cmd/sem asyncapi > docs/asyncapi.json`")
+	}
+}
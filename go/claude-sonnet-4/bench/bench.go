@@ -0,0 +1,329 @@
+// Package bench holds the performance suite: append throughput per store backend,
+// JSON against MessagePack records in the file and bbolt backends, hydration of
+// long streams, projection rebuilds, sequential and partitioned across workers,
+// subscription fan-out, and cart command handling through each port (the canonical
+// cart and the gpt41 and gpt5 compatibility adapters). Run prints results in the format of `go test -bench`, so
+// two runs can be compared with benchstat or with Compare to catch regressions.
+package bench
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"simple-event-modeling/boltstore"
+	"simple-event-modeling/cart"
+	"simple-event-modeling/common"
+	"simple-event-modeling/conformance"
+	"simple-event-modeling/filestore"
+	"simple-event-modeling/serialization"
+)
+
+// Benchmark is a named benchmark of the suite. Names follow the sub-benchmark
+// convention of `go test`, e.g. "Append/store=memory".
+type Benchmark struct {
+	Name string
+	F    func(b *testing.B)
+}
+
+// Suite returns every benchmark of the suite
+func Suite() []Benchmark {
+	var suite []Benchmark
+	for _, backend := range []string{"memory", "file", "bolt"} {
+		suite = append(suite, Benchmark{"Append/store=" + backend, benchmarkAppend(backend, nil)})
+	}
+	for _, format := range formats {
+		suite = append(suite, Benchmark{"Serialize/format=" + format.name, benchmarkSerialize(format.serializer)})
+		for _, backend := range []string{"file", "bolt"} {
+			if format.name != "json" {
+				// The JSON appends are the Append/store= benchmarks
+				suite = append(suite, Benchmark{"Append/store=" + backend + "/format=" + format.name, benchmarkAppend(backend, format.serializer)})
+			}
+			suite = append(suite, Benchmark{"Load/store=" + backend + "/format=" + format.name + "/events=10000", benchmarkLoad(backend, format.serializer, 10000)})
+		}
+	}
+	for _, events := range []int{100, 1000, 10000} {
+		suite = append(suite, Benchmark{fmt.Sprintf("Hydrate/events=%d", events), benchmarkHydrate(events)})
+	}
+	suite = append(suite, Benchmark{"ProjectionRebuild/events=10000", benchmarkProjectionRebuild(10000)})
+	for _, workers := range []int{1, 4} {
+		suite = append(suite, Benchmark{fmt.Sprintf("ProjectionRebuild/projection=related-items/workers=%d/events=10000", workers), benchmarkParallelRebuild(10000, workers)})
+	}
+	for _, subscribers := range []int{1, 10, 100} {
+		suite = append(suite, Benchmark{fmt.Sprintf("SubscriptionFanout/subscribers=%d", subscribers), benchmarkFanout(subscribers)})
+	}
+	for _, port := range ports {
+		suite = append(suite, Benchmark{"CartCommands/port=" + port.name, benchmarkCartCommands(port.newDriver)})
+	}
+	return suite
+}
+
+// ports are the cart implementations compared by the CartCommands benchmarks
+var ports = []struct {
+	name      string
+	newDriver func() conformance.CartDriver
+}{
+	{"canonical", func() conformance.CartDriver { return conformance.NewCanonicalCart() }},
+	{"gpt41", func() conformance.CartDriver { return conformance.NewGPT41Cart() }},
+	{"gpt5", func() conformance.CartDriver { return conformance.NewGPT5Cart() }},
+}
+
+// formats are the record formats compared by the Serialize, Append and Load
+// benchmarks
+var formats = []struct {
+	name       string
+	serializer serialization.Serializer
+}{
+	{"json", serialization.JSON{}},
+	{"msgpack", serialization.MessagePack{}},
+}
+
+// openBackend opens an empty store of the named backend in a temporary directory,
+// writing records with serializer, or JSON when nil
+func openBackend(b *testing.B, backend string, serializer serialization.Serializer) common.Store {
+	b.Helper()
+	switch backend {
+	case "file":
+		store, err := filestore.Open(filepath.Join(b.TempDir(), "events.jsonl"), filestore.WithSerializer(serializer))
+		if err != nil {
+			b.Fatalf("Error opening file store: %v", err)
+		}
+		return store
+	case "bolt":
+		store, err := boltstore.Open(filepath.Join(b.TempDir(), "events.db"), boltstore.WithSerializer(serializer))
+		if err != nil {
+			b.Fatalf("Error opening bolt store: %v", err)
+		}
+		b.Cleanup(func() { store.Close() })
+		return store
+	default:
+		return common.NewEventStore()
+	}
+}
+
+// benchmarkAppend appends one event per operation, spread over 100 streams
+func benchmarkAppend(backend string, serializer serialization.Serializer) func(b *testing.B) {
+	return func(b *testing.B) {
+		store := openBackend(b, backend, serializer)
+		const streams = 100
+		versions := make([]int, streams)
+		data := map[string]interface{}{"item": "apple"}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			stream := i % streams
+			versions[stream]++
+			event := common.NewEvent(cart.EventTypeItemAdded, fmt.Sprintf("cart-%d", stream), versions[stream], data, nil)
+			if err := store.Append(event); err != nil {
+				b.Fatalf("Error appending: %v", err)
+			}
+		}
+	}
+}
+
+// benchmarkSerialize encodes and decodes a typical cart event per operation and
+// reports the size of its record
+func benchmarkSerialize(serializer serialization.Serializer) func(b *testing.B) {
+	return func(b *testing.B) {
+		event := common.NewEvent(cart.EventTypeItemAdded, "cart-1", 2, map[string]interface{}{
+			"item":    "apple",
+			"options": map[string]interface{}{"size": "M", "color": "red"},
+		}, map[string]interface{}{common.ActorIDKey: "alice"})
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		var size int
+		for i := 0; i < b.N; i++ {
+			record, err := serializer.Marshal(event)
+			if err != nil {
+				b.Fatalf("Error marshaling: %v", err)
+			}
+			if _, err := serializer.Unmarshal(record); err != nil {
+				b.Fatalf("Error unmarshaling: %v", err)
+			}
+			size = len(record)
+		}
+		b.ReportMetric(float64(size), "B/record")
+	}
+}
+
+// benchmarkLoad reopens a store of the given length per operation and reads every
+// event, measuring how fast records decode from disk
+func benchmarkLoad(backend string, serializer serialization.Serializer, events int) func(b *testing.B) {
+	return func(b *testing.B) {
+		dir := b.TempDir()
+		var open func() (common.Store, func(), error)
+		switch backend {
+		case "file":
+			path := filepath.Join(dir, "events.jsonl")
+			open = func() (common.Store, func(), error) {
+				store, err := filestore.Open(path, filestore.WithSerializer(serializer))
+				return store, func() {}, err
+			}
+		default:
+			path := filepath.Join(dir, "events.db")
+			open = func() (common.Store, func(), error) {
+				store, err := boltstore.Open(path, boltstore.WithSerializer(serializer))
+				if err != nil {
+					return nil, nil, err
+				}
+				return store, func() { store.Close() }, nil
+			}
+		}
+
+		store, closeStore, err := open()
+		if err != nil {
+			b.Fatalf("Error opening store: %v", err)
+		}
+		const carts = 100
+		for c := 0; c < carts; c++ {
+			seedCart(b, store, fmt.Sprintf("cart-%d", c), events/carts)
+		}
+		closeStore()
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			store, closeStore, err := open()
+			if err != nil {
+				b.Fatalf("Error opening store: %v", err)
+			}
+			if loaded := len(store.GetAllEvents()); loaded != events {
+				b.Fatalf("Expected %d events, loaded %d", events, loaded)
+			}
+			closeStore()
+		}
+	}
+}
+
+// seedCart appends a cart stream of the given length, alternating added and
+// removed items so the cart stays under its item limit
+func seedCart(b *testing.B, store common.Store, cartID string, events int) {
+	b.Helper()
+	if err := store.Append(cart.NewCartCreatedEvent(cartID)); err != nil {
+		b.Fatalf("Error seeding cart: %v", err)
+	}
+	for version := 2; version <= events; version++ {
+		event := cart.NewItemAddedEvent(cartID, version, "apple")
+		if version%2 == 1 {
+			event = cart.NewItemRemovedEvent(cartID, version, "apple")
+		}
+		if err := store.Append(event); err != nil {
+			b.Fatalf("Error seeding cart: %v", err)
+		}
+	}
+}
+
+// benchmarkHydrate rebuilds a cart aggregate from a stream of the given length
+func benchmarkHydrate(events int) func(b *testing.B) {
+	return func(b *testing.B) {
+		store := common.NewEventStore()
+		seedCart(b, store, "cart-1", events)
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := cart.NewCartAggregate(store).Hydrate("cart-1"); err != nil {
+				b.Fatalf("Error hydrating: %v", err)
+			}
+		}
+	}
+}
+
+// benchmarkProjectionRebuild replays a log of the given length spread over 100
+// carts through the cart items projection
+func benchmarkProjectionRebuild(events int) func(b *testing.B) {
+	return func(b *testing.B) {
+		store := common.NewEventStore()
+		const carts = 100
+		for c := 0; c < carts; c++ {
+			seedCart(b, store, fmt.Sprintf("cart-%d", c), events/carts)
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := common.ReplayProjection(store, cart.NewCartItemsProjection(), 0, nil); err != nil {
+				b.Fatalf("Error rebuilding: %v", err)
+			}
+		}
+	}
+}
+
+// benchmarkParallelRebuild rebuilds the related items projection from a log of the
+// given length spread over 100 carts of 50 products, partitioned across workers
+func benchmarkParallelRebuild(events, workers int) func(b *testing.B) {
+	return func(b *testing.B) {
+		store := common.NewEventStore()
+		const carts = 100
+		for c := 0; c < carts; c++ {
+			cartID := fmt.Sprintf("cart-%d", c)
+			for version := 1; version <= events/carts; version++ {
+				if err := store.Append(cart.NewItemAddedEvent(cartID, version, fmt.Sprintf("item-%d", (c+version)%50))); err != nil {
+					b.Fatalf("Error seeding cart: %v", err)
+				}
+			}
+		}
+		factory := func() common.Projection { return cart.NewRelatedItemsProjection() }
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, _, err := common.RebuildParallel(store, factory, workers); err != nil {
+				b.Fatalf("Error rebuilding: %v", err)
+			}
+		}
+	}
+}
+
+// benchmarkFanout delivers one event per operation to every subscriber of a stream
+func benchmarkFanout(subscribers int) func(b *testing.B) {
+	return func(b *testing.B) {
+		store := common.NewEventStore()
+		seedCart(b, store, "cart-1", b.N)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		var wg sync.WaitGroup
+		for s := 0; s < subscribers; s++ {
+			wg.Add(1)
+			events := common.SubscribeStream(ctx, store, "cart-1", 0, time.Millisecond)
+			go func() {
+				defer wg.Done()
+				for received := 0; received < b.N; received++ {
+					<-events
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+// benchmarkCartCommands creates a cart and adds two items per operation, keeping
+// streams short so the result reflects command handling rather than stream length
+func benchmarkCartCommands(newDriver func() conformance.CartDriver) func(b *testing.B) {
+	return func(b *testing.B) {
+		driver := newDriver()
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			id, err := driver.CreateCart()
+			if err != nil {
+				b.Fatalf("Error creating cart: %v", err)
+			}
+			if err := driver.AddItem(id, "apple"); err != nil {
+				b.Fatalf("Error adding item: %v", err)
+			}
+			if err := driver.AddItem(id, "pear"); err != nil {
+				b.Fatalf("Error adding item: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package bench
+
+import (
+	"bytes"
+	"flag"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// BenchmarkSuite runs the suite under `go test -bench`
+func BenchmarkSuite(b *testing.B) {
+	for _, benchmark := range Suite() {
+		b.Run(benchmark.Name, benchmark.F)
+	}
+}
+
+func TestSuite_Names(t *testing.T) {
+	names := make(map[string]bool)
+	for _, benchmark := range Suite() {
+		if names[benchmark.Name] {
+			t.Errorf("Duplicate benchmark %s", benchmark.Name)
+		}
+		names[benchmark.Name] = true
+	}
+	for _, want := range []string{"Append/store=bolt", "Hydrate/events=10000", "ProjectionRebuild/events=10000", "ProjectionRebuild/projection=related-items/workers=4/events=10000", "SubscriptionFanout/subscribers=100", "CartCommands/port=gpt5", "Serialize/format=msgpack", "Append/store=file/format=msgpack", "Load/store=bolt/format=msgpack/events=10000"} {
+		if !names[want] {
+			t.Errorf("Expected benchmark %s in the suite", want)
+		}
+	}
+}
+
+func TestRun_BenchstatFormat(t *testing.T) {
+	// Run sets the benchtime flag, which the benchmarks of this binary share
+	benchtime := flag.Lookup("test.benchtime").Value.String()
+	t.Cleanup(func() { flag.Set("test.benchtime", benchtime) })
+
+	var out bytes.Buffer
+	opts := Options{Filter: regexp.MustCompile(`^CartCommands/`), Count: 2, Benchtime: "5x"}
+	if err := Run(&out, Suite(), opts); err != nil {
+		t.Fatalf("Error running suite: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "goos: ") {
+		t.Errorf("Expected configuration lines first, got %q", out.String())
+	}
+
+	results, err := ParseResults(&out)
+	if err != nil {
+		t.Fatalf("Error parsing results: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected a result per port, got %v", results)
+	}
+	for name, samples := range results {
+		if !strings.HasPrefix(name, "CartCommands/port=") || len(samples) != 2 {
+			t.Errorf("Unexpected samples for %s: %v", name, samples)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	old, _ := ParseResults(strings.NewReader(`goos: linux
+BenchmarkHydrate/events=100-8   	   10000	      1000 ns/op	     512 B/op	       6 allocs/op
+BenchmarkHydrate/events=100-8   	   10000	      1200 ns/op	     512 B/op	       6 allocs/op
+BenchmarkAppend/store=memory-8  	  100000	       300 ns/op
+BenchmarkRemoved-8              	  100000	       300 ns/op
+`))
+	new, _ := ParseResults(strings.NewReader(`BenchmarkHydrate/events=100-8   	   10000	      1650 ns/op
+BenchmarkAppend/store=memory-8  	  100000	       290 ns/op
+`))
+
+	changes := Compare(old, new)
+	if len(changes) != 2 {
+		t.Fatalf("Expected changes for the shared benchmarks, got %v", changes)
+	}
+	if changes[0].Name != "Append/store=memory" || changes[0].Regressed(0.1) {
+		t.Errorf("Expected append to hold steady, got %v", changes[0])
+	}
+	if changes[1].Old != 1100 || !changes[1].Regressed(0.1) {
+		t.Errorf("Expected hydration to regress by 50%%, got %v", changes[1])
+	}
+}
@@ -0,0 +1,138 @@
+package bench
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Options selects how Run executes the suite
+type Options struct {
+	// Filter selects benchmarks by name; nil runs them all
+	Filter *regexp.Regexp
+	// Count runs each benchmark this many times, so benchstat can report variance
+	Count int
+	// Benchtime is the run time of each benchmark in `go test -benchtime` syntax,
+	// e.g. "2s" or "500x"; empty keeps the default of 1s
+	Benchtime string
+}
+
+// Run executes the selected benchmarks and writes their results to out in the
+// format of `go test -bench`, preceded by the configuration lines benchstat groups
+// results by
+func Run(out io.Writer, suite []Benchmark, opts Options) error {
+	// testing.Benchmark reads the test flags, which only `go test` registers
+	testing.Init()
+	if opts.Benchtime != "" {
+		if err := flag.Set("test.benchtime", opts.Benchtime); err != nil {
+			return fmt.Errorf("invalid benchtime %q: %w", opts.Benchtime, err)
+		}
+	}
+	count := opts.Count
+	if count < 1 {
+		count = 1
+	}
+
+	fmt.Fprintf(out, "goos: %s\ngoarch: %s\npkg: simple-event-modeling/bench\n", runtime.GOOS, runtime.GOARCH)
+	suffix := ""
+	if procs := runtime.GOMAXPROCS(0); procs > 1 {
+		suffix = fmt.Sprintf("-%d", procs)
+	}
+	for _, benchmark := range suite {
+		if opts.Filter != nil && !opts.Filter.MatchString(benchmark.Name) {
+			continue
+		}
+		for i := 0; i < count; i++ {
+			result := testing.Benchmark(benchmark.F)
+			if result.N == 0 {
+				return fmt.Errorf("benchmark %s failed", benchmark.Name)
+			}
+			fmt.Fprintf(out, "Benchmark%s%s\t%s\t%s\n", benchmark.Name, suffix, result.String(), result.MemString())
+		}
+	}
+	return nil
+}
+
+// ParseResults reads `go test -bench` output, returning the ns/op samples of each
+// benchmark by name without the GOMAXPROCS suffix
+func ParseResults(r io.Reader) (map[string][]float64, error) {
+	results := make(map[string][]float64)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		name := strings.TrimPrefix(fields[0], "Benchmark")
+		if i := strings.LastIndex(name, "-"); i > 0 {
+			if _, err := strconv.Atoi(name[i+1:]); err == nil {
+				name = name[:i]
+			}
+		}
+		for i := 2; i+1 < len(fields); i += 2 {
+			if fields[i+1] != "ns/op" {
+				continue
+			}
+			ns, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ns/op in %q: %w", scanner.Text(), err)
+			}
+			results[name] = append(results[name], ns)
+		}
+	}
+	return results, scanner.Err()
+}
+
+// Change compares the median ns/op of a benchmark across two runs
+type Change struct {
+	Name string
+	Old  float64
+	New  float64
+	// Delta is the relative change, e.g. 0.25 for 25% slower
+	Delta float64
+}
+
+// Regressed reports whether the benchmark slowed down by more than threshold
+func (c Change) Regressed(threshold float64) bool {
+	return c.Delta > threshold
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %.0f ns/op -> %.0f ns/op (%+.1f%%)", c.Name, c.Old, c.New, c.Delta*100)
+}
+
+// Compare returns the change of every benchmark present in both runs, sorted by name.
+// It is a quick CI check; benchstat remains the tool for a significance test.
+func Compare(old, new map[string][]float64) []Change {
+	var changes []Change
+	for name, oldSamples := range old {
+		newSamples, exists := new[name]
+		if !exists || len(oldSamples) == 0 || len(newSamples) == 0 {
+			continue
+		}
+		change := Change{Name: name, Old: median(oldSamples), New: median(newSamples)}
+		if change.Old > 0 {
+			change.Delta = (change.New - change.Old) / change.Old
+		}
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
+
+func median(samples []float64) float64 {
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
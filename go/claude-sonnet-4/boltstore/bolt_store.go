@@ -0,0 +1,466 @@
+// Package boltstore provides an embedded, durable Store backend on a bbolt database
+// file. It needs no server, which suits CLI tools and tests that want events to
+// survive a restart, and unlike the JSON-lines filestore it reads a stream without
+// scanning the whole log.
+//
+// Events are kept in two places: the "events" bucket maps a global sequence number
+// to the encoded event, giving GetAllEvents its append order, and each aggregate
+// stream has a bucket under "streams" mapping versions to sequence numbers. An
+// append updates both in one transaction. The sequence number is the event's
+// Position, so deleting or truncating streams leaves the positions of the events
+// left alone.
+//
+// Events are encoded as JSON unless the store is opened WithSerializer; every
+// record names its format, so records written with different serializers can be
+// read side by side.
+//
+// bbolt locks the database file, so only one process can open it at a time.
+package boltstore
+
+import (
+	"encoding/binary"
+	"fmt"
+	"iter"
+	"sync"
+	"time"
+
+	"simple-event-modeling/common"
+	"simple-event-modeling/serialization"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	eventsBucket  = []byte("events")
+	streamsBucket = []byte("streams")
+)
+
+// BoltStore is a Store backed by a bbolt database file
+type BoltStore struct {
+	db    *bolt.DB
+	codec serialization.Codec
+	// txMu serializes appends with transactions, which hold it until they commit
+	txMu sync.Mutex
+}
+
+var (
+	_ common.Store         = (*BoltStore)(nil)
+	_ common.Redactor      = (*BoltStore)(nil)
+	_ common.BatchAppender = (*BoltStore)(nil)
+	_ common.StreamDeleter = (*BoltStore)(nil)
+	_ common.EventQuerier  = (*BoltStore)(nil)
+	_ common.Transactor    = (*BoltStore)(nil)
+)
+
+// QueryBatchSize is the number of events Query reads per read transaction. Events are
+// yielded between transactions, so the caller may write to the store while scanning.
+const QueryBatchSize = 256
+
+// Option configures a BoltStore
+type Option func(*BoltStore)
+
+// WithSerializer encodes events with serializer instead of JSON. Events already in
+// the database are read whatever their format, as long as the serialization
+// registry knows it.
+func WithSerializer(serializer serialization.Serializer) Option {
+	return func(bs *BoltStore) {
+		bs.codec = serialization.NewCodec(serializer, nil)
+	}
+}
+
+// Open opens the database file at path, creating it if it doesn't exist. It waits up
+// to a second for another process to release the file lock.
+func Open(path string, opts ...Option) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(eventsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(streamsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	bs := &BoltStore{db: db}
+	for _, opt := range opts {
+		opt(bs)
+	}
+	return bs, nil
+}
+
+// Path returns the location of the database file
+func (bs *BoltStore) Path() string {
+	return bs.db.Path()
+}
+
+// Close releases the database file
+func (bs *BoltStore) Close() error {
+	return bs.db.Close()
+}
+
+// Append stores an event at the end of its stream. The event must directly follow
+// the stream's current version, otherwise a *common.ConcurrencyError is returned.
+func (bs *BoltStore) Append(event *common.Event) error {
+	data, err := bs.codec.Encode(event)
+	if err != nil {
+		return err
+	}
+
+	bs.txMu.Lock()
+	defer bs.txMu.Unlock()
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		return appendTx(tx, event, data)
+	})
+}
+
+// AppendBatch stores events in a single transaction. See common.BatchAppender.
+func (bs *BoltStore) AppendBatch(events []*common.Event) error {
+	bs.txMu.Lock()
+	defer bs.txMu.Unlock()
+	return bs.appendBatch(events)
+}
+
+// WithinTransaction buffers the appends of fn in a common.UnitOfWork and stores
+// them in a single database transaction once it returns nil. Appends from other
+// goroutines wait until the transaction ends; fn must append through tx. See
+// common.Transactor.
+func (bs *BoltStore) WithinTransaction(fn func(tx common.Store) error) error {
+	bs.txMu.Lock()
+	defer bs.txMu.Unlock()
+
+	uow := common.NewUnitOfWork(bs, nil)
+	if err := fn(uow); err != nil {
+		return err
+	}
+	return bs.appendBatch(uow.Pending())
+}
+
+func (bs *BoltStore) appendBatch(events []*common.Event) error {
+	encoded := make([][]byte, len(events))
+	for i, event := range events {
+		data, err := bs.codec.Encode(event)
+		if err != nil {
+			return err
+		}
+		encoded[i] = data
+	}
+
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		for i, event := range events {
+			if err := appendTx(tx, event, encoded[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RedactEvent rewrites the event at version in a stream. See common.Redactor.
+func (bs *BoltStore) RedactEvent(streamID string, version int, fields []string) (*common.Event, error) {
+	var audit *common.Event
+	err := bs.db.Update(func(tx *bolt.Tx) error {
+		stream := tx.Bucket(streamsBucket).Bucket([]byte(streamID))
+		if stream == nil {
+			return &common.StreamNotFoundError{StreamID: streamID}
+		}
+		seq := stream.Get(itob(uint64(version)))
+		if seq == nil {
+			return &common.InvalidCommandError{Message: fmt.Sprintf("stream %s has no version %d", streamID, version)}
+		}
+
+		events := tx.Bucket(eventsBucket)
+		original, err := bs.decode(seq, events.Get(seq))
+		if err != nil {
+			return err
+		}
+		redacted, err := common.RedactedCopy(original, fields)
+		if err != nil {
+			return err
+		}
+		data, err := bs.codec.Encode(redacted)
+		if err != nil {
+			return err
+		}
+		if err := events.Put(seq, data); err != nil {
+			return err
+		}
+
+		auditVersion := 1
+		if auditStream := tx.Bucket(streamsBucket).Bucket([]byte(common.RedactionStreamID)); auditStream != nil {
+			auditVersion = streamVersion(auditStream) + 1
+		}
+		audit = common.NewRedactionEvent(original, fields, auditVersion)
+		if data, err = bs.codec.Encode(audit); err != nil {
+			return err
+		}
+		return appendTx(tx, audit, data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return audit, nil
+}
+
+// TruncateStream drops the events of a stream below beforeVersion, once they have
+// been snapshotted or copied elsewhere (see package compaction). The stream's latest
+// event is always kept, so its version and optimistic concurrency checks are
+// unaffected. The freed pages are reused by later appends.
+func (bs *BoltStore) TruncateStream(aggregateID string, beforeVersion int) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		stream := tx.Bucket(streamsBucket).Bucket([]byte(aggregateID))
+		if stream == nil {
+			return &common.StreamNotFoundError{StreamID: aggregateID}
+		}
+		if latest := streamVersion(stream); beforeVersion > latest {
+			beforeVersion = latest
+		}
+
+		// Collect the keys first: deleting under a cursor can skip the next key
+		var versions, seqs [][]byte
+		cursor := stream.Cursor()
+		for version, seq := cursor.First(); version != nil && binary.BigEndian.Uint64(version) < uint64(beforeVersion); version, seq = cursor.Next() {
+			versions = append(versions, version)
+			seqs = append(seqs, seq)
+		}
+
+		events := tx.Bucket(eventsBucket)
+		for i := range versions {
+			if err := events.Delete(seqs[i]); err != nil {
+				return err
+			}
+			if err := stream.Delete(versions[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteStream removes every event of a stream at expectedVersion. See
+// common.StreamDeleter.
+func (bs *BoltStore) DeleteStream(streamID string, expectedVersion int) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		streams := tx.Bucket(streamsBucket)
+		stream := streams.Bucket([]byte(streamID))
+		if stream == nil {
+			return &common.StreamNotFoundError{StreamID: streamID}
+		}
+		if actual := streamVersion(stream); actual != expectedVersion {
+			return &common.ConcurrencyError{StreamID: streamID, Expected: expectedVersion, Actual: actual}
+		}
+		events := tx.Bucket(eventsBucket)
+		err := stream.ForEach(func(_, seq []byte) error {
+			return events.Delete(seq)
+		})
+		if err != nil {
+			return err
+		}
+		return streams.DeleteBucket([]byte(streamID))
+	})
+}
+
+// GetStream retrieves all events for a given aggregate ID
+func (bs *BoltStore) GetStream(aggregateID string) ([]*common.Event, error) {
+	var stream []*common.Event
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(streamsBucket).Bucket([]byte(aggregateID))
+		if bucket == nil {
+			return &common.StreamNotFoundError{StreamID: aggregateID}
+		}
+
+		events := tx.Bucket(eventsBucket)
+		return bucket.ForEach(func(_, seq []byte) error {
+			event, err := bs.decode(seq, events.Get(seq))
+			if err != nil {
+				return err
+			}
+			stream = append(stream, event)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+// GetStreamVersion returns the current version of a stream
+func (bs *BoltStore) GetStreamVersion(aggregateID string) int {
+	version := 0
+	bs.db.View(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket(streamsBucket).Bucket([]byte(aggregateID)); bucket != nil {
+			version = streamVersion(bucket)
+		}
+		return nil
+	})
+	return version
+}
+
+// StreamLength returns the number of events in a stream from the first and last keys
+// of its bucket, without decoding any: truncation only drops a stream's oldest
+// versions, so the versions left are contiguous
+func (bs *BoltStore) StreamLength(aggregateID string) int {
+	length := 0
+	bs.db.View(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket(streamsBucket).Bucket([]byte(aggregateID)); bucket != nil {
+			cursor := bucket.Cursor()
+			first, _ := cursor.First()
+			last, _ := cursor.Last()
+			if first != nil {
+				length = int(binary.BigEndian.Uint64(last)-binary.BigEndian.Uint64(first)) + 1
+			}
+		}
+		return nil
+	})
+	return length
+}
+
+// HeadEvent decodes only the latest event of a stream
+func (bs *BoltStore) HeadEvent(aggregateID string) (*common.Event, error) {
+	var head *common.Event
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(streamsBucket).Bucket([]byte(aggregateID))
+		if bucket == nil {
+			return &common.StreamNotFoundError{StreamID: aggregateID}
+		}
+		_, seq := bucket.Cursor().Last()
+		if seq == nil {
+			return &common.StreamNotFoundError{StreamID: aggregateID}
+		}
+		var err error
+		head, err = bs.decode(seq, tx.Bucket(eventsBucket).Get(seq))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return head, nil
+}
+
+// GetAllEvents returns every event in append order
+func (bs *BoltStore) GetAllEvents() []*common.Event {
+	all := make([]*common.Event, 0)
+	bs.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(eventsBucket).ForEach(func(seq, data []byte) error {
+			event, err := bs.decode(seq, data)
+			if err != nil {
+				return err
+			}
+			all = append(all, event)
+			return nil
+		})
+	})
+	return all
+}
+
+// Query scans the events bucket in batches of QueryBatchSize, decoding one batch at a
+// time. Events appended during the scan are yielded if they pass the filter. See
+// common.EventQuerier.
+func (bs *BoltStore) Query(filter common.Filter) iter.Seq2[*common.Event, error] {
+	return func(yield func(*common.Event, error) bool) {
+		var next []byte
+		for {
+			batch := make([]*common.Event, 0, QueryBatchSize)
+			read := 0
+			err := bs.db.View(func(tx *bolt.Tx) error {
+				cursor := tx.Bucket(eventsBucket).Cursor()
+				seq, data := cursor.First()
+				if next != nil {
+					seq, data = cursor.Seek(next)
+				}
+				for ; seq != nil && read < QueryBatchSize; seq, data = cursor.Next() {
+					read++
+					event, err := bs.decode(seq, data)
+					if err != nil {
+						return err
+					}
+					if filter.Matches(event) {
+						batch = append(batch, event)
+					}
+					next = itob(binary.BigEndian.Uint64(seq) + 1)
+				}
+				return nil
+			})
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			for _, event := range batch {
+				if !yield(event, nil) {
+					return
+				}
+			}
+			if read < QueryBatchSize {
+				return
+			}
+		}
+	}
+}
+
+// StreamIDs returns the identifiers of all streams in the store, sorted
+func (bs *BoltStore) StreamIDs() []string {
+	ids := make([]string, 0)
+	bs.db.View(func(tx *bolt.Tx) error {
+		// Bucket keys are kept in byte order
+		return tx.Bucket(streamsBucket).ForEach(func(id, _ []byte) error {
+			ids = append(ids, string(id))
+			return nil
+		})
+	})
+	return ids
+}
+
+// appendTx stores an encoded event at the end of its stream
+func appendTx(tx *bolt.Tx, event *common.Event, data []byte) error {
+	stream, err := tx.Bucket(streamsBucket).CreateBucketIfNotExists([]byte(event.AggregateID))
+	if err != nil {
+		return err
+	}
+	if err := common.CheckVersion(event, streamVersion(stream)); err != nil {
+		return err
+	}
+
+	events := tx.Bucket(eventsBucket)
+	seq, err := events.NextSequence()
+	if err != nil {
+		return err
+	}
+	if err := events.Put(itob(seq), data); err != nil {
+		return err
+	}
+	if err := stream.Put(itob(uint64(event.Version)), itob(seq)); err != nil {
+		return err
+	}
+	event.Position = int(seq)
+	return nil
+}
+
+// streamVersion returns the highest version in a stream bucket
+func streamVersion(stream *bolt.Bucket) int {
+	last, _ := stream.Cursor().Last()
+	if last == nil {
+		return 0
+	}
+	return int(binary.BigEndian.Uint64(last))
+}
+
+func (bs *BoltStore) decode(seq, data []byte) (*common.Event, error) {
+	event, err := bs.codec.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("decoding event %d: %w", binary.BigEndian.Uint64(seq), err)
+	}
+	event.Position = int(binary.BigEndian.Uint64(seq))
+	return event, nil
+}
+
+// itob encodes n big-endian, so keys sort numerically
+func itob(n uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, n)
+	return b
+}
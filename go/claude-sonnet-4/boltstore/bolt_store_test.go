@@ -0,0 +1,341 @@
+package boltstore
+
+import (
+	"errors"
+	"path/filepath"
+	"simple-event-modeling/common"
+	"simple-event-modeling/serialization"
+	"testing"
+)
+
+func TestBoltStore_AppendAndReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+	store.Append(common.NewEvent("CartCreated", "cart-2", 1, nil, nil))
+	store.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+	store.Append(common.NewEvent("ItemAdded", "cart-1", 2, map[string]interface{}{"item": "apple"}, nil))
+	store.Close()
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Error reopening store: %v", err)
+	}
+	defer reopened.Close()
+
+	all := reopened.GetAllEvents()
+	if len(all) != 3 || all[0].AggregateID != "cart-2" || all[2].Type != "ItemAdded" {
+		t.Errorf("Expected 3 events in append order after reopen, got %v", all)
+	}
+	if version := reopened.GetStreamVersion("cart-1"); version != 2 {
+		t.Errorf("Expected cart-1 version 2, got %d", version)
+	}
+
+	events, err := reopened.GetStream("cart-1")
+	if err != nil {
+		t.Fatalf("Error getting stream: %v", err)
+	}
+	if len(events) != 2 || events[1].Data["item"] != "apple" {
+		t.Errorf("Expected item data to round-trip, got %v", events)
+	}
+
+	ids := reopened.StreamIDs()
+	if len(ids) != 2 || ids[0] != "cart-1" || ids[1] != "cart-2" {
+		t.Errorf("Unexpected stream IDs: %v", ids)
+	}
+}
+
+func TestBoltStore_StreamNotFound(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+	defer store.Close()
+
+	var notFound *common.StreamNotFoundError
+	if _, err := store.GetStream("missing"); !errors.As(err, &notFound) {
+		t.Errorf("Expected StreamNotFoundError, got %v", err)
+	}
+	if version := store.GetStreamVersion("missing"); version != 0 {
+		t.Errorf("Expected version 0 for missing stream, got %d", version)
+	}
+	if _, err := store.HeadEvent("missing"); !errors.As(err, &notFound) {
+		t.Errorf("Expected StreamNotFoundError for the head, got %v", err)
+	}
+	if length := store.StreamLength("missing"); length != 0 {
+		t.Errorf("Expected length 0 for missing stream, got %d", length)
+	}
+}
+
+func TestBoltStore_RejectsConflictingAppend(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+	defer store.Close()
+
+	store.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+	err = store.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+
+	var conflict *common.ConcurrencyError
+	if !errors.As(err, &conflict) {
+		t.Errorf("Expected ConcurrencyError, got %v", err)
+	}
+	if len(store.GetAllEvents()) != 1 {
+		t.Errorf("Expected the rejected event not to be stored, got %d events", len(store.GetAllEvents()))
+	}
+}
+
+func TestBoltStore_AppendBatchIsAtomic(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+	defer store.Close()
+
+	err = store.AppendBatch([]*common.Event{
+		common.NewEvent("CartCreated", "cart-1", 1, nil, nil),
+		common.NewEvent("CartCreated", "cart-2", 2, nil, nil),
+	})
+	var conflict *common.ConcurrencyError
+	if !errors.As(err, &conflict) || len(store.GetAllEvents()) != 0 {
+		t.Fatalf("Expected the whole batch to be rejected, got %v with %d events", err, len(store.GetAllEvents()))
+	}
+
+	err = store.AppendBatch([]*common.Event{
+		common.NewEvent("CartCreated", "cart-1", 1, nil, nil),
+		common.NewEvent("ItemAdded", "cart-1", 2, nil, nil),
+		common.NewEvent("CartCreated", "cart-2", 1, nil, nil),
+	})
+	if err != nil || store.GetStreamVersion("cart-1") != 2 || len(store.GetAllEvents()) != 3 {
+		t.Errorf("Expected the batch to be stored, got %v", err)
+	}
+}
+
+func TestBoltStore_WithinTransaction(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+	defer store.Close()
+	store.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+
+	err = store.WithinTransaction(func(tx common.Store) error {
+		tx.Append(common.NewEvent("CartCheckedOut", "cart-1", 2, nil, nil))
+		return tx.Append(common.NewEvent("OrderPlaced", "order-1", 2, nil, nil))
+	})
+	var conflict *common.ConcurrencyError
+	if !errors.As(err, &conflict) || len(store.GetAllEvents()) != 1 {
+		t.Fatalf("Expected the whole transaction to be rejected, got %v with %d events", err, len(store.GetAllEvents()))
+	}
+
+	err = store.WithinTransaction(func(tx common.Store) error {
+		if err := tx.Append(common.NewEvent("CartCheckedOut", "cart-1", 2, nil, nil)); err != nil {
+			return err
+		}
+		return tx.Append(common.NewEvent("OrderPlaced", "order-1", 1, nil, nil))
+	})
+	if err != nil {
+		t.Fatalf("Error committing transaction: %v", err)
+	}
+	if store.GetStreamVersion("cart-1") != 2 || store.GetStreamVersion("order-1") != 1 {
+		t.Errorf("Expected both streams to be appended, got %v", store.GetAllEvents())
+	}
+}
+
+func TestBoltStore_Query(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+	defer store.Close()
+
+	// Enough events for the scan to span several read transactions
+	events := make([]*common.Event, 0, 2*QueryBatchSize+10)
+	for i := 1; i <= cap(events); i++ {
+		eventType := "ItemAdded"
+		if i%2 == 0 {
+			eventType = "ItemRemoved"
+		}
+		events = append(events, common.NewEvent(eventType, "cart-1", i, nil, nil))
+	}
+	if err := store.AppendBatch(events); err != nil {
+		t.Fatalf("Error appending: %v", err)
+	}
+
+	last := 0
+	for event, err := range store.Query(common.Filter{Types: []string{"ItemAdded"}, AggregatePrefix: "cart-1"}) {
+		if err != nil {
+			t.Fatalf("Error scanning: %v", err)
+		}
+		if event.Type != "ItemAdded" || event.Version <= last {
+			t.Fatalf("Expected ItemAdded events in append order, got %s v%d after v%d", event.Type, event.Version, last)
+		}
+		last = event.Version
+		// Writing between batches must not deadlock with the scan
+		if event.Version == QueryBatchSize-1 {
+			if err := store.Append(common.NewEvent("ItemAdded", "cart-2", 1, nil, nil)); err != nil {
+				t.Fatalf("Error appending while scanning: %v", err)
+			}
+		}
+	}
+	if last != 2*QueryBatchSize+9 {
+		t.Errorf("Expected the scan to reach the last ItemAdded, v%d, got v%d", 2*QueryBatchSize+9, last)
+	}
+
+	count := 0
+	for range common.QueryEvents(store, common.Filter{AggregatePrefix: "cart-2"}) {
+		count++
+	}
+	if count != 1 {
+		t.Errorf("Expected the event appended during the scan, got %d", count)
+	}
+}
+
+func TestBoltStore_RedactEvent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+	store.Append(common.NewEvent("CustomerRegistered", "c-1", 1, map[string]interface{}{"email": "ada@example.com", "plan": "pro"}, nil))
+
+	audit, err := store.RedactEvent("c-1", 1, []string{"email"})
+	if err != nil {
+		t.Fatalf("Error redacting event: %v", err)
+	}
+	if audit.AggregateID != common.RedactionStreamID || audit.Version != 1 {
+		t.Errorf("Unexpected audit event: %+v", audit)
+	}
+	if _, err := store.RedactEvent("c-1", 1, []string{"missing"}); err == nil {
+		t.Error("Expected error redacting a missing field")
+	}
+	store.Close()
+
+	reopened, _ := Open(path)
+	defer reopened.Close()
+	stream, _ := reopened.GetStream("c-1")
+	if stream[0].Data["email"] != common.RedactedValue || stream[0].Data["plan"] != "pro" {
+		t.Errorf("Expected the redaction to persist, got %v", stream[0].Data)
+	}
+	if all := reopened.GetAllEvents(); len(all) != 2 || all[1].Type != common.RedactionEventType {
+		t.Errorf("Expected the audit event after the redacted one, got %v", all)
+	}
+}
+
+func TestBoltStore_TruncateStream(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+	defer store.Close()
+
+	store.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+	store.Append(common.NewEvent("CartCreated", "cart-2", 1, nil, nil))
+	for version := 2; version <= 4; version++ {
+		store.Append(common.NewEvent("ItemAdded", "cart-1", version, nil, nil))
+	}
+
+	if err := store.TruncateStream("cart-1", 4); err != nil {
+		t.Fatalf("Error truncating stream: %v", err)
+	}
+	events, err := store.GetStream("cart-1")
+	if err != nil {
+		t.Fatalf("Error getting stream: %v", err)
+	}
+	if len(events) != 1 || events[0].Version != 4 {
+		t.Errorf("Expected only version 4 to remain, got %v", events)
+	}
+	if all := store.GetAllEvents(); len(all) != 2 || all[0].AggregateID != "cart-2" {
+		t.Errorf("Expected truncated events to leave the global log, got %v", all)
+	}
+
+	// The latest event stays, and appends continue from it
+	if err := store.TruncateStream("cart-1", 10); err != nil {
+		t.Fatalf("Error truncating stream: %v", err)
+	}
+	if version := store.GetStreamVersion("cart-1"); version != 4 {
+		t.Errorf("Expected version 4 to be kept, got %d", version)
+	}
+	if err := store.Append(common.NewEvent("ItemAdded", "cart-1", 5, nil, nil)); err != nil {
+		t.Errorf("Expected append after truncation to succeed, got %v", err)
+	}
+	if length := store.StreamLength("cart-1"); length != 2 {
+		t.Errorf("Expected versions 4 and 5 to remain, got length %d", length)
+	}
+	if head, err := store.HeadEvent("cart-1"); err != nil || head.Version != 5 {
+		t.Errorf("Expected head at version 5, got %v (%v)", head, err)
+	}
+
+	var notFound *common.StreamNotFoundError
+	if err := store.TruncateStream("missing", 1); !errors.As(err, &notFound) {
+		t.Errorf("Expected StreamNotFoundError, got %v", err)
+	}
+}
+
+func TestBoltStore_DeleteStream(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+	defer store.Close()
+
+	store.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+	store.Append(common.NewEvent("CartCreated", "cart-2", 1, nil, nil))
+	store.Append(common.NewEvent("ItemAdded", "cart-1", 2, nil, nil))
+
+	var conflict *common.ConcurrencyError
+	if err := store.DeleteStream("cart-1", 1); !errors.As(err, &conflict) {
+		t.Fatalf("Expected ConcurrencyError deleting a stale version, got %v", err)
+	}
+	if err := store.DeleteStream("cart-1", 2); err != nil {
+		t.Fatalf("Error deleting stream: %v", err)
+	}
+	var notFound *common.StreamNotFoundError
+	if _, err := store.GetStream("cart-1"); !errors.As(err, &notFound) {
+		t.Errorf("Expected the stream gone, got %v", err)
+	}
+	if all := store.GetAllEvents(); len(all) != 1 || all[0].AggregateID != "cart-2" || all[0].Position != 2 {
+		t.Errorf("Expected the stream's events to leave the global log, others keeping their positions, got %v", all)
+	}
+	if ids := store.StreamIDs(); len(ids) != 1 || ids[0] != "cart-2" {
+		t.Errorf("Expected only cart-2 listed, got %v", ids)
+	}
+	if err := store.DeleteStream("cart-1", 2); !errors.As(err, &notFound) {
+		t.Errorf("Expected StreamNotFoundError deleting twice, got %v", err)
+	}
+}
+
+func TestBoltStore_MixesSerializers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+
+	store, _ := Open(path)
+	store.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+	store.Close()
+
+	proto, err := Open(path, WithSerializer(serialization.Protobuf{}))
+	if err != nil {
+		t.Fatalf("Error reopening store with protobuf: %v", err)
+	}
+	defer proto.Close()
+	if err := proto.Append(common.NewEvent("ItemAdded", "cart-1", 2, map[string]interface{}{"item": "apple"}, nil)); err != nil {
+		t.Fatalf("Error appending protobuf event: %v", err)
+	}
+
+	events, err := proto.GetStream("cart-1")
+	if err != nil {
+		t.Fatalf("Error getting stream: %v", err)
+	}
+	if len(events) != 2 || events[0].Type != "CartCreated" || events[1].Data["item"] != "apple" {
+		t.Errorf("Expected the JSON and protobuf events, got %v", events)
+	}
+	if _, err := proto.RedactEvent("cart-1", 2, []string{"item"}); err != nil {
+		t.Fatalf("Error redacting protobuf event: %v", err)
+	}
+	if head, _ := proto.HeadEvent("cart-1"); head.Data["item"] == "apple" {
+		t.Errorf("Expected the item to be redacted, got %v", head.Data)
+	}
+}
@@ -0,0 +1,176 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"runtime/debug"
+	"sync"
+
+	"simple-event-modeling/common"
+)
+
+var (
+	// ErrQueueFull is returned by DispatchAsync when the target worker's queue is full
+	ErrQueueFull = errors.New("command queue is full")
+	// ErrDispatcherClosed is returned by DispatchAsync after Close
+	ErrDispatcherClosed = errors.New("async dispatcher is closed")
+)
+
+// AsyncConfig sizes an AsyncDispatcher
+type AsyncConfig struct {
+	// Workers is the number of goroutines handling commands (default 4)
+	Workers int
+	// QueueSize is the number of commands each worker can hold before
+	// DispatchAsync fails with ErrQueueFull (default 64)
+	QueueSize int
+	// Limiter, when set, refuses commands over its limits in DispatchAsync, before
+	// a hot aggregate or caller can fill a worker's queue
+	Limiter *RateLimiter
+}
+
+// PanicError is the error of an asynchronously dispatched command whose handler or
+// middleware panicked. The worker recovers and goes on with the next command.
+type PanicError struct {
+	CommandType string
+	Value       interface{}
+	// Stack is the stack trace of the panicking goroutine
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("command %s panicked: %v", e.CommandType, e.Value)
+}
+
+// Pending is the handle of a command dispatched asynchronously
+type Pending struct {
+	done  chan struct{}
+	event *common.Event
+	err   error
+}
+
+// Done is closed when the command has been handled
+func (p *Pending) Done() <-chan struct{} {
+	return p.done
+}
+
+// Wait blocks until the command has been handled or ctx is done
+func (p *Pending) Wait(ctx context.Context) (*common.Event, error) {
+	select {
+	case <-p.done:
+		return p.event, p.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type job struct {
+	ctx     context.Context
+	command common.Command
+	pending *Pending
+}
+
+// AsyncDispatcher absorbs bursts of commands with a bounded queue per worker.
+// Commands for the same aggregate always go to the same worker, so they are handled
+// one at a time and in the order they were dispatched.
+type AsyncDispatcher struct {
+	bus     *CommandBus
+	queues  []chan job
+	limiter *RateLimiter
+	wg      sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+	next   int
+}
+
+// NewAsyncDispatcher starts the workers of an async dispatcher for the bus
+func NewAsyncDispatcher(bus *CommandBus, config AsyncConfig) *AsyncDispatcher {
+	if config.Workers <= 0 {
+		config.Workers = 4
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 64
+	}
+
+	d := &AsyncDispatcher{bus: bus, queues: make([]chan job, config.Workers), limiter: config.Limiter}
+	for i := range d.queues {
+		d.queues[i] = make(chan job, config.QueueSize)
+		d.wg.Add(1)
+		go d.work(d.queues[i])
+	}
+	return d
+}
+
+// DispatchAsync queues the command and returns immediately. The command is handled
+// with the values of ctx but is not cancelled with it, since callers typically return
+// before the command runs.
+func (d *AsyncDispatcher) DispatchAsync(ctx context.Context, command common.Command) (*Pending, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return nil, ErrDispatcherClosed
+	}
+	if d.limiter != nil {
+		if err := d.limiter.Allow(ctx, command); err != nil {
+			return nil, err
+		}
+	}
+	pending := &Pending{done: make(chan struct{})}
+	select {
+	case d.queues[d.worker(command.AggregateID())] <- job{ctx: context.WithoutCancel(ctx), command: command, pending: pending}:
+		return pending, nil
+	default:
+		return nil, ErrQueueFull
+	}
+}
+
+// Close stops accepting commands and waits for the queued ones to be handled
+func (d *AsyncDispatcher) Close() {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+	d.closed = true
+	for _, queue := range d.queues {
+		close(queue)
+	}
+	d.mu.Unlock()
+
+	d.wg.Wait()
+}
+
+// worker picks the queue for an aggregate. Commands without an aggregate ID create a
+// new aggregate and are spread round-robin. Callers hold d.mu.
+func (d *AsyncDispatcher) worker(aggregateID string) int {
+	if aggregateID == "" {
+		d.next = (d.next + 1) % len(d.queues)
+		return d.next
+	}
+	h := fnv.New32a()
+	h.Write([]byte(aggregateID))
+	return int(h.Sum32() % uint32(len(d.queues)))
+}
+
+func (d *AsyncDispatcher) work(queue <-chan job) {
+	defer d.wg.Done()
+	for j := range queue {
+		d.handle(j)
+	}
+}
+
+// handle dispatches a queued command, turning a panic into the command's error so
+// the worker survives it
+func (d *AsyncDispatcher) handle(j job) {
+	defer close(j.pending.done)
+	defer func() {
+		if value := recover(); value != nil {
+			j.pending.event = nil
+			j.pending.err = &PanicError{CommandType: j.command.CommandType(), Value: value, Stack: debug.Stack()}
+		}
+	}()
+	j.pending.event, j.pending.err = d.bus.Dispatch(j.ctx, j.command)
+}
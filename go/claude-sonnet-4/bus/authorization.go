@@ -0,0 +1,80 @@
+package bus
+
+import (
+	"context"
+
+	"simple-event-modeling/common"
+)
+
+// Principal identifies the caller issuing a command
+type Principal struct {
+	ID    string
+	Roles []string
+}
+
+// HasRole reports whether the principal has the given role
+func (p Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying the caller's identity
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFrom returns the caller's identity stored in ctx
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+// Authorizer decides whether a principal may issue a command. The command's
+// AggregateID identifies the target aggregate. Returning an error rejects the command;
+// a *common.UnauthorizedError is reported to HTTP callers as 403 Forbidden.
+type Authorizer interface {
+	Authorize(ctx context.Context, principal Principal, command common.Command) error
+}
+
+// AuthorizerFunc adapts a function to the Authorizer interface
+type AuthorizerFunc func(ctx context.Context, principal Principal, command common.Command) error
+
+// Authorize calls f
+func (f AuthorizerFunc) Authorize(ctx context.Context, principal Principal, command common.Command) error {
+	return f(ctx, principal, command)
+}
+
+// Authorization consults the authorizer before commands reach their handler.
+// Commands dispatched without a principal in the context are authorized as the zero
+// Principal, so authorizers decide how anonymous callers are treated.
+func Authorization(authorizer Authorizer) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, command common.Command) (*common.Event, error) {
+			principal, _ := PrincipalFrom(ctx)
+			if err := authorizer.Authorize(ctx, principal, command); err != nil {
+				return nil, err
+			}
+			return next(ctx, command)
+		}
+	}
+}
+
+// ActorMetadata records the ID of the principal in the context as the
+// common.ActorIDKey metadata of the events commands produce, for handlers that
+// append through common.MetadataStore. Commands without a principal are unchanged.
+func ActorMetadata() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, command common.Command) (*common.Event, error) {
+			if principal, ok := PrincipalFrom(ctx); ok && principal.ID != "" {
+				ctx = common.WithEventMetadata(ctx, common.ActorIDKey, principal.ID)
+			}
+			return next(ctx, command)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Package bus routes commands to their handlers through a chain of middleware, and
+// queries to the read models that answer them.
+// Cross-cutting concerns such as validation run as middleware, so every command
+// is checked the same way before it reaches an aggregate.
+package bus
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"simple-event-modeling/common"
+)
+
+// Handler handles a command and returns the resulting event
+type Handler func(ctx context.Context, command common.Command) (*common.Event, error)
+
+// Middleware wraps a handler with additional behavior
+type Middleware func(next Handler) Handler
+
+// CommandBus dispatches commands to the handler registered for their command type
+type CommandBus struct {
+	mu         sync.RWMutex
+	handlers   map[string]Handler
+	middleware []Middleware
+}
+
+// NewCommandBus creates a bus with the given middleware. Middleware runs in the order
+// given, the first one outermost.
+func NewCommandBus(middleware ...Middleware) *CommandBus {
+	return &CommandBus{
+		handlers:   make(map[string]Handler),
+		middleware: middleware,
+	}
+}
+
+// Use appends middleware to the chain
+func (b *CommandBus) Use(middleware ...Middleware) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.middleware = append(b.middleware, middleware...)
+}
+
+// Register sets the handler for a command type
+func (b *CommandBus) Register(commandType string, handler Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, exists := b.handlers[commandType]; exists {
+		panic(fmt.Sprintf("command handler %q is already registered", commandType))
+	}
+	b.handlers[commandType] = handler
+}
+
+// CommandTypes returns the registered command types, sorted
+func (b *CommandBus) CommandTypes() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	types := make([]string, 0, len(b.handlers))
+	for commandType := range b.handlers {
+		types = append(types, commandType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Dispatch runs the command through the middleware chain and its handler.
+// Commands without a handler fail with *common.UnknownCommandError.
+func (b *CommandBus) Dispatch(ctx context.Context, command common.Command) (*common.Event, error) {
+	b.mu.RLock()
+	handler, exists := b.handlers[command.CommandType()]
+	middleware := b.middleware
+	b.mu.RUnlock()
+
+	if !exists {
+		return nil, &common.UnknownCommandError{CommandType: command.CommandType(), Registered: b.CommandTypes()}
+	}
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler(ctx, command)
+}
@@ -0,0 +1,481 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"simple-event-modeling/common"
+	"sync"
+	"testing"
+	"time"
+)
+
+type renameCommand struct {
+	ID   string `json:"aggregate_id" validate:"required"`
+	Name string `json:"name" validate:"required"`
+}
+
+func (c *renameCommand) AggregateID() string { return c.ID }
+func (c *renameCommand) CommandType() string { return "Rename" }
+
+type archiveCommand struct{}
+
+func (archiveCommand) AggregateID() string { return "" }
+func (archiveCommand) CommandType() string { return "Archive" }
+
+func renamed(_ context.Context, command common.Command) (*common.Event, error) {
+	return common.NewEvent("Renamed", command.AggregateID(), 1, nil, nil), nil
+}
+
+func TestCommandBus_Dispatch(t *testing.T) {
+	b := NewCommandBus()
+	b.Register("Rename", renamed)
+
+	event, err := b.Dispatch(context.Background(), &renameCommand{ID: "a-1", Name: "Groceries"})
+	if err != nil {
+		t.Fatalf("Error dispatching: %v", err)
+	}
+	if event.Type != "Renamed" || event.AggregateID != "a-1" {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+
+	_, err = b.Dispatch(context.Background(), archiveCommand{})
+	var unknown *common.UnknownCommandError
+	if !errors.As(err, &unknown) {
+		t.Fatalf("Expected UnknownCommandError, got %v", err)
+	}
+	if len(unknown.Registered) != 1 || unknown.Registered[0] != "Rename" {
+		t.Errorf("Expected registered types to be listed, got %v", unknown.Registered)
+	}
+}
+
+func TestCommandBus_MiddlewareOrder(t *testing.T) {
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, command common.Command) (*common.Event, error) {
+				calls = append(calls, name)
+				return next(ctx, command)
+			}
+		}
+	}
+
+	b := NewCommandBus(trace("first"))
+	b.Use(trace("second"))
+	b.Register("Rename", renamed)
+	b.Dispatch(context.Background(), &renameCommand{ID: "a-1", Name: "Groceries"})
+
+	if len(calls) != 2 || calls[0] != "first" || calls[1] != "second" {
+		t.Errorf("Expected middleware to run in registration order, got %v", calls)
+	}
+}
+
+func TestValidation(t *testing.T) {
+	handled := false
+	b := NewCommandBus(Validation())
+	b.Register("Rename", func(ctx context.Context, command common.Command) (*common.Event, error) {
+		handled = true
+		return renamed(ctx, command)
+	})
+
+	_, err := b.Dispatch(context.Background(), &renameCommand{ID: "a-1"})
+	var validationErr *common.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected ValidationError, got %v", err)
+	}
+	if len(validationErr.Fields) != 1 || validationErr.Fields[0].Field != "name" {
+		t.Errorf("Unexpected fields: %v", validationErr.Fields)
+	}
+	if handled {
+		t.Error("Expected the handler not to run for an invalid command")
+	}
+}
+
+func TestAuthorization(t *testing.T) {
+	authorizer := AuthorizerFunc(func(_ context.Context, principal Principal, command common.Command) error {
+		if principal.HasRole("admin") {
+			return nil
+		}
+		return &common.UnauthorizedError{Principal: principal.ID, CommandType: command.CommandType(), AggregateID: command.AggregateID()}
+	})
+	b := NewCommandBus(Authorization(authorizer))
+	b.Register("Rename", renamed)
+
+	admin := WithPrincipal(context.Background(), Principal{ID: "alice", Roles: []string{"admin"}})
+	if _, err := b.Dispatch(admin, &renameCommand{ID: "a-1", Name: "Groceries"}); err != nil {
+		t.Errorf("Expected admin to be authorized, got %v", err)
+	}
+
+	guest := WithPrincipal(context.Background(), Principal{ID: "bob"})
+	_, err := b.Dispatch(guest, &renameCommand{ID: "a-1", Name: "Groceries"})
+	var unauthorized *common.UnauthorizedError
+	if !errors.As(err, &unauthorized) || unauthorized.Principal != "bob" {
+		t.Errorf("Expected UnauthorizedError for bob, got %v", err)
+	}
+
+	if _, err := b.Dispatch(context.Background(), &renameCommand{ID: "a-1", Name: "Groceries"}); err == nil {
+		t.Error("Expected anonymous callers to be rejected")
+	}
+}
+
+func TestActorMetadata(t *testing.T) {
+	var metadata map[string]interface{}
+	b := NewCommandBus(ActorMetadata())
+	b.Register("Rename", func(ctx context.Context, command common.Command) (*common.Event, error) {
+		metadata = common.EventMetadataFrom(ctx)
+		return renamed(ctx, command)
+	})
+
+	b.Dispatch(WithPrincipal(context.Background(), Principal{ID: "alice"}), &renameCommand{ID: "a-1", Name: "Groceries"})
+	if metadata[common.ActorIDKey] != "alice" {
+		t.Errorf("Expected alice as the actor, got %v", metadata)
+	}
+	b.Dispatch(context.Background(), &renameCommand{ID: "a-1", Name: "Groceries"})
+	if metadata != nil {
+		t.Errorf("Expected no metadata for anonymous commands, got %v", metadata)
+	}
+}
+
+func TestEnvelopeMetadata(t *testing.T) {
+	var metadata map[string]interface{}
+	var handled common.Command
+	b := NewCommandBus(EnvelopeMetadata(), Validation())
+	b.Register("Rename", func(ctx context.Context, command common.Command) (*common.Event, error) {
+		metadata = common.EventMetadataFrom(ctx)
+		handled = command
+		event, err := renamed(ctx, command)
+		event.Metadata = metadata
+		return event, err
+	})
+
+	command := &renameCommand{ID: "a-1", Name: "Groceries"}
+	issuedAt := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	envelope := &CommandEnvelope{Command: command, Origin: OriginMobile, ClientRequestID: "req-42", IssuedAt: issuedAt}
+	event, err := b.Dispatch(WithPrincipal(context.Background(), Principal{ID: "alice"}), envelope)
+	if err != nil {
+		t.Fatalf("Error dispatching envelope: %v", err)
+	}
+	if handled != command {
+		t.Errorf("Expected the handler to get the wrapped command, got %T", handled)
+	}
+	provenance := ProvenanceOf(event)
+	if provenance.ActorID != "alice" || provenance.Origin != OriginMobile || provenance.ClientRequestID != "req-42" || !provenance.IssuedAt.Equal(issuedAt) {
+		t.Errorf("Unexpected provenance: %+v from %v", provenance, metadata)
+	}
+
+	if _, err := b.Dispatch(context.Background(), NewCommandEnvelope(&renameCommand{ID: "a-1"}, "bob", OriginWeb, "")); !errors.Is(err, common.ErrValidation) {
+		t.Errorf("Expected the wrapped command validated, got %v", err)
+	}
+	b.Dispatch(context.Background(), NewCommandEnvelope(command, "bob", OriginWeb, ""))
+	if metadata[common.ActorIDKey] != "bob" || metadata[common.OriginKey] != "web" || metadata[common.ClientRequestIDKey] != nil {
+		t.Errorf("Expected bob on the web without a request ID, got %v", metadata)
+	}
+}
+
+func TestCommandLog(t *testing.T) {
+	store := common.NewEventStore()
+	b := NewCommandBus(CommandLog(store, ""), Validation())
+	b.Register("Rename", renamed)
+
+	ctx := WithPrincipal(context.Background(), Principal{ID: "alice"})
+	b.Dispatch(ctx, &renameCommand{ID: "a-1", Name: "Groceries"})
+	b.Dispatch(ctx, &renameCommand{ID: "a-1"})
+
+	records, err := ReadCommandLog(store, "")
+	if err != nil {
+		t.Fatalf("Error reading command log: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+	if records[0].Outcome != OutcomeAccepted || records[0].EventID == "" || records[0].Principal != "alice" {
+		t.Errorf("Unexpected accepted record: %+v", records[0])
+	}
+	if records[1].Outcome != OutcomeRejected || records[1].Error == "" {
+		t.Errorf("Unexpected rejected record: %+v", records[1])
+	}
+
+	// Reproduce the recorded traffic against a fresh bus
+	var replayed []string
+	replay := NewCommandBus(Validation())
+	replay.Register("Rename", func(ctx context.Context, command common.Command) (*common.Event, error) {
+		replayed = append(replayed, command.(*renameCommand).Name)
+		return renamed(ctx, command)
+	})
+	for _, record := range records {
+		var command renameCommand
+		if err := record.Decode(&command); err != nil {
+			t.Fatalf("Error decoding record: %v", err)
+		}
+		replay.Dispatch(context.Background(), &command)
+	}
+	if len(replayed) != 1 || replayed[0] != "Groceries" {
+		t.Errorf("Expected only the accepted command to be handled again, got %v", replayed)
+	}
+}
+
+func TestRecordRejections(t *testing.T) {
+	store := common.NewEventStore()
+	b := NewCommandBus(RecordRejections(store, ""), Validation())
+	b.Register("Rename", renamed)
+	b.Register("Archive", func(context.Context, common.Command) (*common.Event, error) {
+		return nil, &common.ConcurrencyError{StreamID: "a-1", Expected: 2, Actual: 3}
+	})
+
+	ctx := WithPrincipal(context.Background(), Principal{ID: "alice"})
+	b.Dispatch(ctx, &renameCommand{ID: "a-1", Name: "Groceries"})
+	b.Dispatch(ctx, &renameCommand{ID: "a-1"})
+	b.Dispatch(ctx, archiveCommand{})
+
+	events, err := store.GetStream(DefaultRejectionStream)
+	if err != nil {
+		t.Fatalf("Error reading rejection stream: %v", err)
+	}
+	if len(events) != 1 || events[0].Type != "RenameRejected" {
+		t.Fatalf("Expected only the invalid command to be recorded, got %v", events)
+	}
+
+	rejections, err := ReadRejections(store, "")
+	if err != nil {
+		t.Fatalf("Error reading rejections: %v", err)
+	}
+	rejection := rejections[0]
+	if rejection.AggregateID != "a-1" || rejection.Principal != "alice" || rejection.Code != common.CodeValidation || rejection.Reason == "" {
+		t.Errorf("Unexpected rejection: %+v", rejection)
+	}
+}
+
+func TestAsyncDispatcher_SerializesPerAggregate(t *testing.T) {
+	var mu sync.Mutex
+	versions := make(map[string]int)
+	order := make(map[string][]string)
+	active := make(map[string]bool)
+
+	b := NewCommandBus()
+	b.Register("Rename", func(_ context.Context, command common.Command) (*common.Event, error) {
+		id := command.AggregateID()
+		mu.Lock()
+		if active[id] {
+			mu.Unlock()
+			return nil, errors.New("concurrent commands for " + id)
+		}
+		active[id] = true
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		active[id] = false
+		versions[id]++
+		order[id] = append(order[id], command.(*renameCommand).Name)
+		return common.NewEvent("Renamed", id, versions[id], nil, nil), nil
+	})
+
+	d := NewAsyncDispatcher(b, AsyncConfig{Workers: 3, QueueSize: 20})
+	var handles []*Pending
+	for i := 0; i < 10; i++ {
+		for _, id := range []string{"a-1", "a-2"} {
+			pending, err := d.DispatchAsync(context.Background(), &renameCommand{ID: id, Name: fmt.Sprint(i)})
+			if err != nil {
+				t.Fatalf("Error dispatching: %v", err)
+			}
+			handles = append(handles, pending)
+		}
+	}
+
+	for _, pending := range handles {
+		if _, err := pending.Wait(context.Background()); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+	d.Close()
+
+	for _, id := range []string{"a-1", "a-2"} {
+		if fmt.Sprint(order[id]) != "[0 1 2 3 4 5 6 7 8 9]" {
+			t.Errorf("Expected %s commands in dispatch order, got %v", id, order[id])
+		}
+	}
+	if _, err := d.DispatchAsync(context.Background(), &renameCommand{ID: "a-1"}); !errors.Is(err, ErrDispatcherClosed) {
+		t.Errorf("Expected ErrDispatcherClosed, got %v", err)
+	}
+}
+
+func TestAsyncDispatcher_QueueFull(t *testing.T) {
+	release := make(chan struct{})
+	b := NewCommandBus()
+	b.Register("Rename", func(ctx context.Context, command common.Command) (*common.Event, error) {
+		<-release
+		return renamed(ctx, command)
+	})
+
+	d := NewAsyncDispatcher(b, AsyncConfig{Workers: 1, QueueSize: 1})
+	defer d.Close()
+	defer close(release)
+
+	var err error
+	for i := 0; i < 3 && err == nil; i++ {
+		_, err = d.DispatchAsync(context.Background(), &renameCommand{ID: "a-1"})
+	}
+	if !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected ErrQueueFull, got %v", err)
+	}
+}
+
+func TestAsyncDispatcher_RecoversPanics(t *testing.T) {
+	b := NewCommandBus()
+	b.Register("Rename", func(ctx context.Context, command common.Command) (*common.Event, error) {
+		if command.(*renameCommand).Name == "boom" {
+			panic("handler bug")
+		}
+		return renamed(ctx, command)
+	})
+
+	d := NewAsyncDispatcher(b, AsyncConfig{Workers: 1})
+	defer d.Close()
+	panicked, _ := d.DispatchAsync(context.Background(), &renameCommand{ID: "a-1", Name: "boom"})
+	next, _ := d.DispatchAsync(context.Background(), &renameCommand{ID: "a-1", Name: "Groceries"})
+
+	var panicErr *PanicError
+	if _, err := panicked.Wait(context.Background()); !errors.As(err, &panicErr) || panicErr.Value != "handler bug" || panicErr.CommandType != "Rename" {
+		t.Errorf("Expected the panic as the command's error, got %v", err)
+	}
+	if _, err := next.Wait(context.Background()); err != nil {
+		t.Errorf("Expected the worker to survive the panic, got %v", err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	limiter := NewRateLimiter(RateLimitConfig{
+		PerAggregate: TokenBucket{Rate: 1, Burst: 2},
+		PerPrincipal: TokenBucket{Rate: 10, Burst: 3},
+		Now:          func() time.Time { return now },
+	})
+	b := NewCommandBus(RateLimit(limiter))
+	b.Register("Rename", renamed)
+	alice := WithPrincipal(context.Background(), Principal{ID: "alice"})
+
+	for i := 0; i < 2; i++ {
+		if _, err := b.Dispatch(alice, &renameCommand{ID: "a-1", Name: "Groceries"}); err != nil {
+			t.Fatalf("Expected the burst to be accepted, got %v", err)
+		}
+	}
+	_, err := b.Dispatch(alice, &renameCommand{ID: "a-1", Name: "Groceries"})
+	var limited *common.RateLimitedError
+	if !errors.As(err, &limited) || limited.Scope != "aggregate" || limited.Key != "a-1" || limited.RetryAfter != time.Second {
+		t.Fatalf("Expected a-1 to be limited for a second, got %v", err)
+	}
+
+	// The refused command took no token from alice, who has one left
+	if _, err := b.Dispatch(alice, &renameCommand{ID: "a-2", Name: "Groceries"}); err != nil {
+		t.Errorf("Expected another aggregate to be accepted, got %v", err)
+	}
+	_, err = b.Dispatch(alice, &renameCommand{ID: "a-3", Name: "Groceries"})
+	if !errors.As(err, &limited) || limited.Scope != "principal" || limited.Key != "alice" {
+		t.Errorf("Expected alice to be limited, got %v", err)
+	}
+	if _, err := b.Dispatch(context.Background(), &renameCommand{ID: "a-3", Name: "Groceries"}); err != nil {
+		t.Errorf("Expected anonymous commands to skip the principal limit, got %v", err)
+	}
+
+	now = now.Add(time.Second)
+	if _, err := b.Dispatch(alice, &renameCommand{ID: "a-1", Name: "Groceries"}); err != nil {
+		t.Errorf("Expected the buckets to refill, got %v", err)
+	}
+}
+
+func TestAsyncDispatcher_RateLimited(t *testing.T) {
+	b := NewCommandBus()
+	b.Register("Rename", renamed)
+	limiter := NewRateLimiter(RateLimitConfig{PerAggregate: TokenBucket{Rate: 0.001}})
+	d := NewAsyncDispatcher(b, AsyncConfig{Limiter: limiter})
+	defer d.Close()
+
+	if _, err := d.DispatchAsync(context.Background(), &renameCommand{ID: "a-1"}); err != nil {
+		t.Fatalf("Error dispatching: %v", err)
+	}
+	if _, err := d.DispatchAsync(context.Background(), &renameCommand{ID: "a-1"}); !errors.Is(err, common.ErrRateLimited) {
+		t.Errorf("Expected the hot aggregate to be refused before queueing, got %v", err)
+	}
+}
+
+func TestRetryOnConflict(t *testing.T) {
+	attempts := 0
+	b := NewCommandBus(RetryOnConflict(3))
+	b.Register("Rename", func(ctx context.Context, command common.Command) (*common.Event, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, &common.ConcurrencyError{StreamID: "a-1", Expected: 1, Actual: 2}
+		}
+		return renamed(ctx, command)
+	})
+
+	if _, err := b.Dispatch(context.Background(), &renameCommand{ID: "a-1", Name: "x"}); err != nil {
+		t.Fatalf("Expected the third attempt to succeed, got %v", err)
+	}
+
+	attempts = -10
+	_, err := b.Dispatch(context.Background(), &renameCommand{ID: "a-1", Name: "x"})
+	var conflict *common.ConcurrencyError
+	if !errors.As(err, &conflict) || attempts != -7 {
+		t.Errorf("Expected the conflict after 3 attempts, got %v after %d", err, attempts+10)
+	}
+}
+
+type countQuery struct{ Tag string }
+
+func (countQuery) QueryType() string { return "Count" }
+
+func TestQueryBus_Ask(t *testing.T) {
+	b := NewQueryBus()
+	b.Register("Count", func(_ context.Context, query common.Query) (interface{}, error) {
+		return len(query.(countQuery).Tag), nil
+	})
+
+	result, err := b.Ask(context.Background(), countQuery{Tag: "home"})
+	if err != nil || result != 4 {
+		t.Errorf("Expected 4, got %v (%v)", result, err)
+	}
+
+	var unknown *common.UnknownQueryError
+	if _, err := NewQueryBus().Ask(context.Background(), countQuery{}); !errors.As(err, &unknown) {
+		t.Errorf("Expected UnknownQueryError, got %v", err)
+	}
+}
+
+func TestFeatureFlags(t *testing.T) {
+	store := common.NewEventStore()
+	flags := common.NewStaticFeatureFlags()
+	flags.Enable("fast-path", "acme")
+	flags.Enable("audit")
+	tenant := func(ctx context.Context, _ common.Command) string {
+		principal, _ := PrincipalFrom(ctx)
+		return principal.ID
+	}
+	bus := NewCommandBus(FeatureFlags(flags, tenant, "fast-path", "audit", "unknown"))
+	var seen common.ActiveFeatures
+	bus.Register("Rename", func(ctx context.Context, command common.Command) (*common.Event, error) {
+		seen = common.ActiveFeaturesFrom(ctx)
+		event := common.NewEvent("Renamed", command.AggregateID(), store.GetStreamVersion(command.AggregateID())+1, nil, nil)
+		return event, common.MetadataStore(store, common.EventMetadataFrom(ctx)).Append(event)
+	})
+
+	acme := WithPrincipal(context.Background(), Principal{ID: "acme"})
+	event, err := bus.Dispatch(acme, &renameCommand{ID: "doc-1", Name: "a"})
+	if err != nil {
+		t.Fatalf("Error dispatching: %v", err)
+	}
+	if !seen.Enabled("fast-path") || !seen.Enabled("audit") || seen.Enabled("unknown") {
+		t.Errorf("Expected fast-path and audit active for acme, got %v", seen)
+	}
+	if event.Metadata[common.FeatureFlagsKey] != "audit,fast-path" {
+		t.Errorf("Expected the active flags recorded, got %v", event.Metadata)
+	}
+
+	globex := WithPrincipal(context.Background(), Principal{ID: "globex"})
+	event, _ = bus.Dispatch(globex, &renameCommand{ID: "doc-2", Name: "b"})
+	if seen.Enabled("fast-path") || common.ActiveFeaturesOf(event)[0] != "audit" {
+		t.Errorf("Expected only audit active for globex, got %v and %v", seen, event.Metadata)
+	}
+}
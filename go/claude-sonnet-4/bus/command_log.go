@@ -0,0 +1,122 @@
+package bus
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"simple-event-modeling/common"
+)
+
+// DefaultCommandLogStream is the stream CommandLog records commands in when no stream is given
+const DefaultCommandLogStream = "$commands"
+
+// EventTypeCommandReceived is the type of the events recorded in a command log
+const EventTypeCommandReceived = "CommandReceived"
+
+// Command outcomes recorded in a command log
+const (
+	OutcomeAccepted = "accepted"
+	OutcomeRejected = "rejected"
+)
+
+// CommandRecord is a command as recorded in a command log
+type CommandRecord struct {
+	CommandType string          `json:"command_type"`
+	AggregateID string          `json:"aggregate_id,omitempty"`
+	Payload     json.RawMessage `json:"payload"`
+	Principal   string          `json:"principal,omitempty"`
+	Outcome     string          `json:"outcome"`
+	// Error is the rejection message of a rejected command
+	Error string `json:"error,omitempty"`
+	// EventID is the ID of the event produced by an accepted command
+	EventID string `json:"event_id,omitempty"`
+}
+
+// Decode unmarshals the recorded payload into command, which must be a pointer to the
+// command type named by CommandType
+func (r CommandRecord) Decode(command common.Command) error {
+	return json.Unmarshal(r.Payload, command)
+}
+
+// CommandLog records every dispatched command, accepted or rejected, as a
+// CommandReceived event in a dedicated stream of the store, so traffic can be
+// reproduced and rejected commands debugged. Place it first in the chain to record
+// commands rejected by other middleware too. Failing to record a command does not
+// fail the command.
+func CommandLog(store common.Store, streamID string) Middleware {
+	if streamID == "" {
+		streamID = DefaultCommandLogStream
+	}
+	var mu sync.Mutex
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, command common.Command) (*common.Event, error) {
+			event, err := next(ctx, command)
+
+			record := CommandRecord{
+				CommandType: command.CommandType(),
+				AggregateID: command.AggregateID(),
+				Outcome:     OutcomeAccepted,
+			}
+			if principal, ok := PrincipalFrom(ctx); ok {
+				record.Principal = principal.ID
+			}
+			if err != nil {
+				record.Outcome = OutcomeRejected
+				record.Error = err.Error()
+			} else if event != nil {
+				record.EventID = event.ID
+			}
+			if payload, marshalErr := json.Marshal(command); marshalErr == nil {
+				record.Payload = payload
+			}
+
+			if data, marshalErr := recordData(record); marshalErr == nil {
+				mu.Lock()
+				store.Append(common.NewEvent(EventTypeCommandReceived, streamID, store.GetStreamVersion(streamID)+1, data, nil))
+				mu.Unlock()
+			}
+			return event, err
+		}
+	}
+}
+
+// ReadCommandLog returns the commands recorded in a command log stream, oldest first
+func ReadCommandLog(store common.Store, streamID string) ([]CommandRecord, error) {
+	if streamID == "" {
+		streamID = DefaultCommandLogStream
+	}
+	events, err := store.GetStream(streamID)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]CommandRecord, 0, len(events))
+	for _, event := range events {
+		if event.Type != EventTypeCommandReceived {
+			continue
+		}
+		raw, err := json.Marshal(event.Data)
+		if err != nil {
+			return nil, err
+		}
+		var record CommandRecord
+		if err := json.Unmarshal(raw, &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// recordData converts a record to the map stored as event data
+func recordData(record CommandRecord) (map[string]interface{}, error) {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]interface{}
+	err = json.Unmarshal(raw, &data)
+	return data, err
+}
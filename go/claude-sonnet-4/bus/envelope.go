@@ -0,0 +1,108 @@
+package bus
+
+import (
+	"context"
+	"time"
+
+	"simple-event-modeling/common"
+)
+
+// Origin is the channel a command was issued through
+type Origin string
+
+// Origins of commands
+const (
+	OriginWeb    Origin = "web"
+	OriginMobile Origin = "mobile"
+	OriginAPI    Origin = "api"
+)
+
+// CommandEnvelope wraps a command with who issued it, from where and when. It is
+// dispatched like the command itself on a bus using EnvelopeMetadata, which records
+// the envelope in the metadata of the events the command produces and hands the
+// wrapped command on.
+type CommandEnvelope struct {
+	Command common.Command
+	// ActorID is the user issuing the command; empty uses the principal in the
+	// context (see WithPrincipal)
+	ActorID string
+	Origin  Origin
+	// ClientRequestID is the ID the client gave its request, e.g. to match it up
+	// with the client's logs
+	ClientRequestID string
+	// IssuedAt is when the client issued the command; zero when unknown
+	IssuedAt time.Time
+}
+
+// NewCommandEnvelope wraps a command issued now
+func NewCommandEnvelope(command common.Command, actorID string, origin Origin, clientRequestID string) *CommandEnvelope {
+	return &CommandEnvelope{
+		Command:         command,
+		ActorID:         actorID,
+		Origin:          origin,
+		ClientRequestID: clientRequestID,
+		IssuedAt:        time.Now(),
+	}
+}
+
+func (e *CommandEnvelope) AggregateID() string { return e.Command.AggregateID() }
+func (e *CommandEnvelope) CommandType() string { return e.Command.CommandType() }
+
+// EnvelopeMetadata unwraps a *CommandEnvelope, recording its actor, origin, client
+// request ID and issue time as the metadata of the events the command produces, for
+// handlers that append through common.MetadataStore. The rest of the chain and the
+// handler get the wrapped command, so add it first. Other commands are unchanged.
+func EnvelopeMetadata() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, command common.Command) (*common.Event, error) {
+			envelope, ok := command.(*CommandEnvelope)
+			if !ok {
+				return next(ctx, command)
+			}
+
+			actorID := envelope.ActorID
+			if principal, ok := PrincipalFrom(ctx); ok && actorID == "" {
+				actorID = principal.ID
+			}
+			if actorID != "" {
+				ctx = common.WithEventMetadata(ctx, common.ActorIDKey, actorID)
+			}
+			if envelope.Origin != "" {
+				ctx = common.WithEventMetadata(ctx, common.OriginKey, string(envelope.Origin))
+			}
+			if envelope.ClientRequestID != "" {
+				ctx = common.WithEventMetadata(ctx, common.ClientRequestIDKey, envelope.ClientRequestID)
+			}
+			if !envelope.IssuedAt.IsZero() {
+				ctx = common.WithEventMetadata(ctx, common.IssuedAtKey, envelope.IssuedAt.UTC().Format(time.RFC3339Nano))
+			}
+			return next(ctx, envelope.Command)
+		}
+	}
+}
+
+// Provenance tells who issued the command an event resulted from, from where and
+// when, as recorded by EnvelopeMetadata
+type Provenance struct {
+	ActorID         string
+	Origin          Origin
+	ClientRequestID string
+	// IssuedAt is when the client issued the command; RecordedAt, when the event
+	// was stored
+	IssuedAt   time.Time
+	RecordedAt time.Time
+}
+
+// ProvenanceOf reads the provenance of an event from its metadata. Fields the
+// metadata lacks are left empty.
+func ProvenanceOf(event *common.Event) Provenance {
+	provenance := Provenance{RecordedAt: event.CreatedAt}
+	provenance.ActorID, _ = event.Metadata[common.ActorIDKey].(string)
+	origin, _ := event.Metadata[common.OriginKey].(string)
+	provenance.Origin = Origin(origin)
+	provenance.ClientRequestID, _ = event.Metadata[common.ClientRequestIDKey].(string)
+	if issuedAt, ok := event.Metadata[common.IssuedAtKey].(string); ok {
+		provenance.IssuedAt, _ = time.Parse(time.RFC3339Nano, issuedAt)
+	}
+	return provenance
+}
@@ -0,0 +1,41 @@
+package bus
+
+import (
+	"context"
+	"strings"
+
+	"simple-event-modeling/common"
+)
+
+// TenantFunc returns the tenant a command is issued on behalf of, or ""
+type TenantFunc func(ctx context.Context, command common.Command) string
+
+// FeatureFlags resolves the given feature flags for the tenant of each command once,
+// before it reaches its handler, so every rule consulted while handling it sees the
+// same flags. The active flags are put in the context (see
+// common.ActiveFeaturesFrom) and recorded as the common.FeatureFlagsKey metadata of
+// the events the command produces, for handlers that append through
+// common.MetadataStore. A nil tenant function resolves every command for the ""
+// tenant.
+func FeatureFlags(flags common.FeatureFlags, tenant TenantFunc, known ...string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, command common.Command) (*common.Event, error) {
+			var tenantID string
+			if tenant != nil {
+				tenantID = tenant(ctx, command)
+			}
+			active := make(common.ActiveFeatures)
+			for _, flag := range known {
+				if flags.Enabled(ctx, flag, tenantID) {
+					active[flag] = true
+				}
+			}
+
+			ctx = common.WithActiveFeatures(ctx, active)
+			if names := active.Names(); len(names) > 0 {
+				ctx = common.WithEventMetadata(ctx, common.FeatureFlagsKey, strings.Join(names, ","))
+			}
+			return next(ctx, command)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package bus
+
+import (
+	"context"
+	"errors"
+
+	"simple-event-modeling/common"
+)
+
+// Commutes reports whether command has the same outcome applied after event as
+// before it, so a command based on a version older than event can be merged with it
+type Commutes func(command common.Command, event *common.Event) bool
+
+// MergeOnConflict merges commands based on a stale version instead of failing them.
+// A command dispatched with common.WithExpectedVersion that fails with a
+// *common.ConcurrencyError is run again at the stream's current version if it
+// commutes with every event appended since the expected version. Otherwise, or when
+// the re-run is rejected as an invalid command, e.g. because the merged state breaks
+// a limit, a *common.MergeConflictError is returned for the caller to resolve.
+// Commands without an expected version pass through untouched.
+func MergeOnConflict(store common.Store, commutes Commutes) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, command common.Command) (*common.Event, error) {
+			event, err := next(ctx, command)
+			expected, pinned := common.ExpectedVersionFrom(ctx)
+			var conflict *common.ConcurrencyError
+			if err == nil || !pinned || !errors.As(err, &conflict) {
+				return event, err
+			}
+
+			stream, readErr := store.GetStream(command.AggregateID())
+			if readErr != nil {
+				return nil, err
+			}
+			theirs := make([]*common.Event, 0, len(stream))
+			for _, event := range stream {
+				if event.Version > expected {
+					theirs = append(theirs, event)
+				}
+			}
+			if len(theirs) == 0 {
+				return nil, err
+			}
+			merge := &common.MergeConflictError{
+				StreamID: command.AggregateID(),
+				Expected: expected,
+				Actual:   theirs[len(theirs)-1].Version,
+				Theirs:   theirs,
+			}
+			for _, event := range theirs {
+				if !commutes(command, event) {
+					return nil, merge
+				}
+			}
+
+			event, err = next(common.WithExpectedVersion(ctx, merge.Actual), command)
+			if errors.Is(err, common.ErrInvalidCommand) {
+				merge.Rejection = err
+				return nil, merge
+			}
+			return event, err
+		}
+	}
+}
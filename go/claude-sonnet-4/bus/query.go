@@ -0,0 +1,63 @@
+package bus
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"simple-event-modeling/common"
+)
+
+// QueryHandler answers a query from a read model
+type QueryHandler func(ctx context.Context, query common.Query) (interface{}, error)
+
+// QueryBus dispatches queries to the handler registered for their query type.
+// It is the read-side counterpart of CommandBus: callers ask for a result by query
+// type without knowing which projection holds it.
+type QueryBus struct {
+	mu       sync.RWMutex
+	handlers map[string]QueryHandler
+}
+
+// NewQueryBus creates an empty query bus
+func NewQueryBus() *QueryBus {
+	return &QueryBus{handlers: make(map[string]QueryHandler)}
+}
+
+// Register sets the handler for a query type
+func (b *QueryBus) Register(queryType string, handler QueryHandler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, exists := b.handlers[queryType]; exists {
+		panic(fmt.Sprintf("query handler %q is already registered", queryType))
+	}
+	b.handlers[queryType] = handler
+}
+
+// QueryTypes returns the registered query types, sorted
+func (b *QueryBus) QueryTypes() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	types := make([]string, 0, len(b.handlers))
+	for queryType := range b.handlers {
+		types = append(types, queryType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Ask runs the query through its handler.
+// Queries without a handler fail with *common.UnknownQueryError.
+func (b *QueryBus) Ask(ctx context.Context, query common.Query) (interface{}, error) {
+	b.mu.RLock()
+	handler, exists := b.handlers[query.QueryType()]
+	b.mu.RUnlock()
+
+	if !exists {
+		return nil, &common.UnknownQueryError{QueryType: query.QueryType(), Registered: b.QueryTypes()}
+	}
+	return handler(ctx, query)
+}
@@ -0,0 +1,152 @@
+package bus
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"simple-event-modeling/common"
+)
+
+// TokenBucket is a rate limit allowing Rate commands per second on average and up
+// to Burst at once. The zero value imposes no limit.
+type TokenBucket struct {
+	Rate float64
+	// Burst defaults to Rate rounded up, and at least 1
+	Burst int
+}
+
+func (b TokenBucket) enabled() bool { return b.Rate > 0 }
+
+func (b TokenBucket) capacity() float64 {
+	if b.Burst > 0 {
+		return float64(b.Burst)
+	}
+	return math.Max(1, math.Ceil(b.Rate))
+}
+
+// RateLimitConfig sets the limits of a RateLimiter
+type RateLimitConfig struct {
+	// PerAggregate limits the commands for each aggregate ID. Commands creating an
+	// aggregate, which have no ID yet, are not limited by it.
+	PerAggregate TokenBucket
+	// PerPrincipal limits the commands of each caller (see WithPrincipal). Anonymous
+	// commands are not limited by it.
+	PerPrincipal TokenBucket
+	// Now returns the current time; nil uses time.Now
+	Now func() time.Time
+}
+
+// sweepInterval is how many new buckets a RateLimiter creates between removals of
+// the buckets that have refilled, which limit nothing
+const sweepInterval = 1024
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// RateLimiter keeps a token bucket per aggregate and per principal. A command is
+// accepted only if both of its buckets have a token, and then takes one from each.
+type RateLimiter struct {
+	config RateLimitConfig
+
+	mu         sync.Mutex
+	aggregates map[string]*bucket
+	principals map[string]*bucket
+	created    int
+}
+
+// NewRateLimiter creates a rate limiter with full buckets
+func NewRateLimiter(config RateLimitConfig) *RateLimiter {
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &RateLimiter{
+		config:     config,
+		aggregates: make(map[string]*bucket),
+		principals: make(map[string]*bucket),
+	}
+}
+
+// Allow takes a token for the command from the buckets of its aggregate and of the
+// principal in ctx, or fails with a *common.RateLimitedError without taking any
+func (l *RateLimiter) Allow(ctx context.Context, command common.Command) error {
+	principal, _ := PrincipalFrom(ctx)
+	now := l.config.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	type take struct {
+		scope  string
+		key    string
+		limit  TokenBucket
+		bucket *bucket
+	}
+	var takes []take
+	if aggregateID := command.AggregateID(); aggregateID != "" && l.config.PerAggregate.enabled() {
+		takes = append(takes, take{"aggregate", aggregateID, l.config.PerAggregate, l.bucket(l.aggregates, aggregateID, l.config.PerAggregate, now)})
+	}
+	if principal.ID != "" && l.config.PerPrincipal.enabled() {
+		takes = append(takes, take{"principal", principal.ID, l.config.PerPrincipal, l.bucket(l.principals, principal.ID, l.config.PerPrincipal, now)})
+	}
+
+	for _, t := range takes {
+		if t.bucket.tokens < 1 {
+			wait := time.Duration((1 - t.bucket.tokens) / t.limit.Rate * float64(time.Second))
+			return &common.RateLimitedError{Scope: t.scope, Key: t.key, CommandType: command.CommandType(), RetryAfter: wait}
+		}
+	}
+	for _, t := range takes {
+		t.bucket.tokens--
+	}
+	return nil
+}
+
+// bucket returns the refilled bucket of key. Callers hold l.mu.
+func (l *RateLimiter) bucket(buckets map[string]*bucket, key string, limit TokenBucket, now time.Time) *bucket {
+	b, exists := buckets[key]
+	if !exists {
+		l.created++
+		if l.created%sweepInterval == 0 {
+			l.sweep(now)
+		}
+		b = &bucket{tokens: limit.capacity(), updated: now}
+		buckets[key] = b
+		return b
+	}
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = math.Min(limit.capacity(), b.tokens+elapsed.Seconds()*limit.Rate)
+		b.updated = now
+	}
+	return b
+}
+
+// sweep removes the buckets that would be full by now. Callers hold l.mu.
+func (l *RateLimiter) sweep(now time.Time) {
+	for _, scope := range []struct {
+		buckets map[string]*bucket
+		limit   TokenBucket
+	}{{l.aggregates, l.config.PerAggregate}, {l.principals, l.config.PerPrincipal}} {
+		for key, b := range scope.buckets {
+			if b.tokens+now.Sub(b.updated).Seconds()*scope.limit.Rate >= scope.limit.capacity() {
+				delete(scope.buckets, key)
+			}
+		}
+	}
+}
+
+// RateLimit refuses commands over the limits of limiter with a
+// *common.RateLimitedError before they reach their handler
+func RateLimit(limiter *RateLimiter) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, command common.Command) (*common.Event, error) {
+			if err := limiter.Allow(ctx, command); err != nil {
+				return nil, err
+			}
+			return next(ctx, command)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package bus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"simple-event-modeling/common"
+)
+
+// DefaultRejectionStream is the stream RecordRejections records rejections in when no stream is given
+const DefaultRejectionStream = "$rejections"
+
+// RejectionEventType returns the type of the event recording a rejected command,
+// e.g. "AddItemRejected" for "AddItem"
+func RejectionEventType(commandType string) string {
+	return commandType + "Rejected"
+}
+
+// Rejection is a rejected command as recorded in a rejection stream
+type Rejection struct {
+	CommandType string          `json:"command_type"`
+	AggregateID string          `json:"aggregate_id,omitempty"`
+	Payload     json.RawMessage `json:"payload"`
+	Principal   string          `json:"principal,omitempty"`
+	// Reason is the rejection message
+	Reason string `json:"reason"`
+	// Code identifies the broken rule, e.g. "cart_item_limit_exceeded"
+	Code common.ErrorCode `json:"code"`
+}
+
+// RecordRejections records commands rejected by a business rule or validation as
+// negative events, such as AddItemRejected, in a side stream of the store. Projections
+// over that stream show where users hit friction (how often carts reach their item
+// limit) without scraping logs. Failures that say nothing about the command, such as
+// concurrency conflicts, are not recorded, and failing to record a rejection does not
+// change the command's outcome.
+func RecordRejections(store common.Store, streamID string) Middleware {
+	if streamID == "" {
+		streamID = DefaultRejectionStream
+	}
+	var mu sync.Mutex
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, command common.Command) (*common.Event, error) {
+			event, err := next(ctx, command)
+			if !isRejection(err) {
+				return event, err
+			}
+
+			rejection := Rejection{
+				CommandType: command.CommandType(),
+				AggregateID: command.AggregateID(),
+				Reason:      err.Error(),
+				Code:        common.ErrorCodeOf(err),
+			}
+			if principal, ok := PrincipalFrom(ctx); ok {
+				rejection.Principal = principal.ID
+			}
+			if payload, marshalErr := json.Marshal(command); marshalErr == nil {
+				rejection.Payload = payload
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if recorded, typedErr := common.NewTypedEvent(RejectionEventType(rejection.CommandType), streamID, store.GetStreamVersion(streamID)+1, rejection, nil); typedErr == nil {
+				store.Append(recorded)
+			}
+			return event, err
+		}
+	}
+}
+
+// ReadRejections returns the rejections recorded in a rejection stream, oldest first
+func ReadRejections(store common.Store, streamID string) ([]Rejection, error) {
+	if streamID == "" {
+		streamID = DefaultRejectionStream
+	}
+	events, err := store.GetStream(streamID)
+	if err != nil {
+		return nil, err
+	}
+
+	rejections := make([]Rejection, 0, len(events))
+	for _, event := range events {
+		typed, err := common.AsTyped[Rejection](event)
+		if err != nil {
+			return nil, err
+		}
+		if event.Type != RejectionEventType(typed.Data.CommandType) {
+			continue
+		}
+		rejections = append(rejections, typed.Data)
+	}
+	return rejections, nil
+}
+
+// isRejection reports whether err rejects the command itself rather than reporting
+// an infrastructure failure
+func isRejection(err error) bool {
+	return errors.Is(err, common.ErrInvalidCommand) ||
+		errors.Is(err, common.ErrValidation) ||
+		errors.Is(err, common.ErrUnauthorized)
+}
@@ -0,0 +1,31 @@
+package bus
+
+import (
+	"context"
+	"errors"
+
+	"simple-event-modeling/common"
+)
+
+// RetryOnConflict re-runs a command whose append lost a race with another writer
+// (*common.ConcurrencyError), up to attempts times in total. Handlers must load
+// fresh aggregate state on every call for the retry to see the winning write.
+func RetryOnConflict(attempts int) Middleware {
+	if attempts < 1 {
+		attempts = 1
+	}
+	return func(next Handler) Handler {
+		return func(ctx context.Context, command common.Command) (*common.Event, error) {
+			var conflict *common.ConcurrencyError
+			for attempt := 1; ; attempt++ {
+				event, err := next(ctx, command)
+				if err == nil || !errors.As(err, &conflict) || attempt >= attempts {
+					return event, err
+				}
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+			}
+		}
+	}
+}
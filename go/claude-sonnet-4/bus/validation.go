@@ -0,0 +1,19 @@
+package bus
+
+import (
+	"context"
+
+	"simple-event-modeling/common"
+)
+
+// Validation rejects commands that fail common.Validate before they reach their handler
+func Validation() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, command common.Command) (*common.Event, error) {
+			if err := common.Validate(command); err != nil {
+				return nil, err
+			}
+			return next(ctx, command)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Package cart provides the continuous projection detecting abandoned carts.
+package cart
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+)
+
+// AbandonedCartsProjectionName is the name the projection is registered under
+const AbandonedCartsProjectionName = "abandoned-carts"
+
+// DefaultAbandonmentThreshold is how long a cart with items can sit without activity
+// before it counts as abandoned
+const DefaultAbandonmentThreshold = 24 * time.Hour
+
+// AbandonedCart is a cart tracked by AbandonedCartsProjection
+type AbandonedCart struct {
+	CartID string `json:"cart_id"`
+	// LastActivity is when the latest event of the cart was recorded
+	LastActivity time.Time `json:"last_activity"`
+	// Version is the version of the cart's stream at its last activity
+	Version int `json:"version"`
+	Items   int `json:"items"`
+	deleted bool
+}
+
+// AbandonedCartsProjection tracks the last activity of every open cart. Carts leave
+// it when they are checked out or expired, and are hidden while soft-deleted.
+// Activity is read from the events' CreatedAt, so a rebuild finds the same carts
+// inactive as the live projection did.
+type AbandonedCartsProjection struct {
+	carts map[string]*AbandonedCart
+}
+
+// NewAbandonedCartsProjection creates an empty abandoned carts projection
+func NewAbandonedCartsProjection() *AbandonedCartsProjection {
+	return &AbandonedCartsProjection{carts: make(map[string]*AbandonedCart)}
+}
+
+// Name returns the registered name of the projection
+func (p *AbandonedCartsProjection) Name() string {
+	return AbandonedCartsProjectionName
+}
+
+// Consumes returns the event types the projection folds
+func (p *AbandonedCartsProjection) Consumes() []string {
+	return []string{
+		EventTypeCartCreated, EventTypeItemAdded, EventTypeItemRemoved, EventTypeItemAnnotated,
+		EventTypeCartRenamed, EventTypeCartAttributeSet, EventTypeShippingOptionSelected,
+		EventTypeGiftCardApplied, EventTypeGiftCardRemoved, EventTypeCartCleared, EventTypeCartCheckedOut,
+		EventTypeCartExpired, EventTypeCartDeleted, EventTypeCartRestored,
+	}
+}
+
+// On records the activity of a cart event; other events are ignored
+func (p *AbandonedCartsProjection) On(event *common.Event) error {
+	switch event.Type {
+	case EventTypeCartCheckedOut, EventTypeCartExpired:
+		delete(p.carts, event.AggregateID)
+		return nil
+	case EventTypeCartCreated, EventTypeItemAdded, EventTypeItemRemoved, EventTypeItemAnnotated,
+		EventTypeCartRenamed, EventTypeCartAttributeSet, EventTypeShippingOptionSelected,
+		EventTypeGiftCardApplied, EventTypeGiftCardRemoved, EventTypeCartCleared, EventTypeCartDeleted,
+		EventTypeCartRestored:
+	default:
+		return nil
+	}
+
+	cart, exists := p.carts[event.AggregateID]
+	if !exists {
+		cart = &AbandonedCart{CartID: event.AggregateID}
+		p.carts[event.AggregateID] = cart
+	}
+	cart.LastActivity = event.CreatedAt
+	cart.Version = event.Version
+	switch event.Type {
+	case EventTypeItemAdded:
+		cart.Items++
+	case EventTypeItemRemoved:
+		if cart.Items > 0 {
+			cart.Items--
+		}
+	case EventTypeCartCleared:
+		cart.Items = 0
+	case EventTypeCartDeleted:
+		cart.deleted = true
+	case EventTypeCartRestored:
+		cart.deleted = false
+	}
+	return nil
+}
+
+// Inactive returns the carts holding items whose last activity is more than
+// threshold before now, least recently active first
+func (p *AbandonedCartsProjection) Inactive(threshold time.Duration, now time.Time) []AbandonedCart {
+	cutoff := now.Add(-threshold)
+	inactive := make([]AbandonedCart, 0)
+	for _, cart := range p.carts {
+		if !cart.deleted && cart.Items > 0 && cart.LastActivity.Before(cutoff) {
+			inactive = append(inactive, *cart)
+		}
+	}
+	sort.Slice(inactive, func(i, j int) bool {
+		if !inactive[i].LastActivity.Equal(inactive[j].LastActivity) {
+			return inactive[i].LastActivity.Before(inactive[j].LastActivity)
+		}
+		return inactive[i].CartID < inactive[j].CartID
+	})
+	return inactive
+}
+
+// State returns the open carts that are not deleted, keyed by cart ID
+func (p *AbandonedCartsProjection) State() interface{} {
+	state := make(map[string]AbandonedCart, len(p.carts))
+	for cartID, cart := range p.carts {
+		if !cart.deleted {
+			state[cartID] = *cart
+		}
+	}
+	return state
+}
+
+// AbandonedCartsQuery asks for the carts inactive for longer than a threshold
+type AbandonedCartsQuery struct {
+	Threshold time.Duration
+	// Now is the time inactivity is measured to; zero uses time.Now
+	Now   time.Time
+	Store common.Store
+}
+
+// NewAbandonedCartsQuery creates a query for the carts inactive for longer than
+// threshold; 0 uses DefaultAbandonmentThreshold
+func NewAbandonedCartsQuery(threshold time.Duration, store common.Store) *AbandonedCartsQuery {
+	if threshold <= 0 {
+		threshold = DefaultAbandonmentThreshold
+	}
+	return &AbandonedCartsQuery{Threshold: threshold, Store: store}
+}
+
+// Execute replays the global event log into a fresh projection and returns the
+// abandoned carts. Process managers polling for abandoned carts keep an
+// AbandonedCartsProjection caught up instead, e.g. with common.NewAsyncProjection.
+func (q *AbandonedCartsQuery) Execute() ([]AbandonedCart, error) {
+	projection := NewAbandonedCartsProjection()
+	if _, err := common.ReplayProjection(q.Store, projection, 0, nil); err != nil {
+		return nil, err
+	}
+	now := q.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	return projection.Inactive(q.Threshold, now), nil
+}
+
+// ExpireAbandoned dispatches ExpireCart for every abandoned cart and returns the IDs
+// of the carts expired. The command expects the cart at the version the projection
+// saw, so a cart with activity since then fails with a concurrency conflict and is
+// skipped, as are carts rejecting the command. Run it periodically, e.g. from a
+// ticker, with the carts of AbandonedCartsProjection.Inactive.
+func ExpireAbandoned(ctx context.Context, commands *bus.CommandBus, abandoned []AbandonedCart) ([]string, error) {
+	expired := make([]string, 0, len(abandoned))
+	for _, cart := range abandoned {
+		_, err := commands.Dispatch(common.WithExpectedVersion(ctx, cart.Version), &ExpireCartCommand{CartID: cart.CartID})
+		switch {
+		case err == nil:
+			expired = append(expired, cart.CartID)
+		case errors.Is(err, common.ErrConcurrency), errors.Is(err, common.ErrInvalidCommand), errors.Is(err, common.ErrAggregateClosed):
+		default:
+			return expired, err
+		}
+	}
+	return expired, nil
+}
@@ -0,0 +1,74 @@
+package cart
+
+import (
+	"context"
+	"errors"
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+	"testing"
+	"time"
+)
+
+func TestAbandonedCartsProjection_ExpiresInactiveCarts(t *testing.T) {
+	store := common.NewEventStore()
+	commands := bus.NewCommandBus()
+	RegisterCommands(commands, store)
+	now := time.Now()
+	at := func(event *common.Event, ago time.Duration) *common.Event {
+		event.CreatedAt = now.Add(-ago)
+		return event
+	}
+
+	// stale: abandoned two days ago; empty: nothing to remind about; busy: recent
+	// activity; gone: deleted; done: checked out
+	const stale, empty, busy, gone, done = "11111111-1111-4111-8111-111111111111", "22222222-2222-4222-8222-222222222222",
+		"33333333-3333-4333-8333-333333333333", "44444444-4444-4444-8444-444444444444", "55555555-5555-4555-8555-555555555555"
+	for _, cartID := range []string{stale, empty, busy, gone, done} {
+		store.Append(at(NewCartCreatedEvent(cartID), 72*time.Hour))
+	}
+	store.Append(at(NewItemAddedEvent(stale, 2, "apple"), 48*time.Hour))
+	store.Append(at(NewItemAddedEvent(empty, 2, "apple"), 48*time.Hour))
+	store.Append(at(NewItemRemovedEvent(empty, 3, "apple"), 48*time.Hour))
+	store.Append(at(NewItemAddedEvent(busy, 2, "apple"), 48*time.Hour))
+	store.Append(at(NewItemAddedEvent(busy, 3, "pear"), time.Hour))
+	store.Append(at(NewItemAddedEvent(gone, 2, "apple"), 48*time.Hour))
+	store.Append(at(NewCartDeletedEvent(gone, 3), 47*time.Hour))
+	store.Append(at(NewItemAddedEvent(done, 2, "apple"), 48*time.Hour))
+	store.Append(at(NewCartCheckedOutEvent(done, 3), 47*time.Hour))
+
+	query := NewAbandonedCartsQuery(0, store)
+	query.Now = now
+	abandoned, err := query.Execute()
+	if err != nil {
+		t.Fatalf("Error executing query: %v", err)
+	}
+	if len(abandoned) != 1 || abandoned[0].CartID != stale || abandoned[0].Items != 1 || abandoned[0].Version != 2 {
+		t.Fatalf("Expected only the stale cart abandoned, got %+v", abandoned)
+	}
+
+	projection := NewAbandonedCartsProjection()
+	common.ReplayProjection(store, projection, 0, nil)
+	store.Append(at(NewCartRestoredEvent(gone, 4), 30*time.Hour))
+	common.ReplayProjection(store, projection, len(store.GetAllEvents())-1, nil)
+	if inactive := projection.Inactive(DefaultAbandonmentThreshold, now); len(inactive) != 2 || inactive[1].CartID != gone {
+		t.Errorf("Expected the restored cart tracked again, got %+v", inactive)
+	}
+
+	// The stale cart saw activity after the projection's snapshot of it
+	store.Append(NewItemAnnotatedEvent(stale, 3, "apple", nil, "for mum", true))
+	expired, err := ExpireAbandoned(context.Background(), commands, projection.Inactive(DefaultAbandonmentThreshold, now))
+	if err != nil {
+		t.Fatalf("Error expiring carts: %v", err)
+	}
+	if len(expired) != 1 || expired[0] != gone {
+		t.Errorf("Expected only the untouched restored cart expired, got %v", expired)
+	}
+	if _, err := commands.Dispatch(context.Background(), &AddItemCommand{CartID: gone, ItemID: "pear"}); !errors.Is(err, common.ErrAggregateClosed) {
+		t.Errorf("Expected the expired cart closed, got %v", err)
+	}
+
+	common.ReplayProjection(store, projection, len(store.GetAllEvents())-2, nil)
+	if inactive := projection.Inactive(time.Hour, now.Add(2*time.Hour)); len(inactive) != 2 || inactive[0].CartID != busy || inactive[1].CartID != stale {
+		t.Errorf("Expected the expired cart dropped and the others ordered by activity, got %+v", inactive)
+	}
+}
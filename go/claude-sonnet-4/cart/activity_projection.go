@@ -0,0 +1,50 @@
+// Package cart provides the hourly activity projection over all carts.
+package cart
+
+import (
+	"time"
+
+	"simple-event-modeling/common"
+)
+
+// ItemsAddedPerHourProjectionName is the name the projection is registered under
+const ItemsAddedPerHourProjectionName = "items-added-per-hour"
+
+// NewItemsAddedPerHourProjection creates an empty projection counting the items
+// added to any cart in each hour, by when the ItemAdded events were recorded
+func NewItemsAddedPerHourProjection() *common.WindowedProjection[int] {
+	return common.NewWindowedProjection(
+		ItemsAddedPerHourProjectionName,
+		[]string{EventTypeItemAdded},
+		common.TumblingWindows(time.Hour),
+		func(count int, _ *common.Event) int { return count + 1 },
+	)
+}
+
+// ItemsAddedPerHourQuery asks for the number of items added in each hour
+type ItemsAddedPerHourQuery struct {
+	// Since skips the hours ending at or before it; zero returns every hour
+	Since time.Time
+	Store common.Store
+}
+
+// NewItemsAddedPerHourQuery creates a query for the items added in each hour
+func NewItemsAddedPerHourQuery(store common.Store) *ItemsAddedPerHourQuery {
+	return &ItemsAddedPerHourQuery{Store: store}
+}
+
+// Execute replays the global event log into a fresh projection and returns the hours
+// in which items were added, earliest first
+func (q *ItemsAddedPerHourQuery) Execute() ([]common.WindowResult[int], error) {
+	projection := NewItemsAddedPerHourProjection()
+	if _, err := common.ReplayProjection(q.Store, projection, 0, nil); err != nil {
+		return nil, err
+	}
+	hours := make([]common.WindowResult[int], 0)
+	for _, hour := range projection.Windows() {
+		if q.Since.IsZero() || hour.End.After(q.Since) {
+			hours = append(hours, hour)
+		}
+	}
+	return hours, nil
+}
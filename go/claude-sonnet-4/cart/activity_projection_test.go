@@ -0,0 +1,38 @@
+package cart
+
+import (
+	"simple-event-modeling/common"
+	"testing"
+	"time"
+)
+
+func TestItemsAddedPerHourQuery(t *testing.T) {
+	store := common.NewEventStore()
+	base := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	at := func(event *common.Event, offset time.Duration) *common.Event {
+		event.CreatedAt = base.Add(offset)
+		return event
+	}
+
+	const first, second = "11111111-1111-4111-8111-111111111111", "22222222-2222-4222-8222-222222222222"
+	store.Append(at(NewCartCreatedEvent(first), 0))
+	store.Append(at(NewItemAddedEvent(first, 2, "apple"), 10*time.Minute))
+	store.Append(at(NewItemAddedEvent(first, 3, "pear"), 20*time.Minute))
+	store.Append(at(NewItemRemovedEvent(first, 4, "pear"), 30*time.Minute))
+	store.Append(at(NewCartCreatedEvent(second), 2*time.Hour))
+	store.Append(at(NewItemAddedEvent(second, 2, "apple"), 2*time.Hour+5*time.Minute))
+
+	hours, err := NewItemsAddedPerHourQuery(store).Execute()
+	if err != nil {
+		t.Fatalf("Error executing query: %v", err)
+	}
+	if len(hours) != 2 || hours[0].Start.Hour() != 9 || hours[0].State != 2 || hours[1].Start.Hour() != 11 || hours[1].State != 1 {
+		t.Fatalf("Expected two items at 09:00 and one at 11:00, got %+v", hours)
+	}
+
+	query := NewItemsAddedPerHourQuery(store)
+	query.Since = base.Add(time.Hour)
+	if hours, _ := query.Execute(); len(hours) != 1 || hours[0].Start.Hour() != 11 {
+		t.Errorf("Expected only the 11:00 hour since 10:00, got %+v", hours)
+	}
+}
@@ -3,10 +3,10 @@
 package cart
 
 import (
-	"errors"
+	"encoding/json"
+	"fmt"
 	"simple-event-modeling/common"
-
-	"github.com/google/uuid"
+	"sort"
 )
 
 // CartAggregate represents a shopping cart aggregate
@@ -14,133 +14,366 @@ import (
 // Aggregates hydrate by replaying the relevant event stream.
 type CartAggregate struct {
 	*common.BaseAggregate
-	items map[string]int // itemID -> quantity
+	lines      *common.Entities[*CartLine] // keyed by LineKey
+	giftCards  map[string]float64          // gift card ID -> amount applied
+	pending    map[string]bool             // gift cards awaiting their reservation
+	deleted    bool
+	shipping   ShippingRater
+	reorder    ReorderTranslator
+	autoCreate AutoCreatePolicy
+	namer      common.StreamNamer
+	features   common.ActiveFeatures
 }
 
+// AutoCreatePolicy selects what AddItem does when it names no cart
+type AutoCreatePolicy int
+
+const (
+	// AutoCreate creates a cart holding the item, committing both events together.
+	// It is the policy of new carts.
+	AutoCreate AutoCreatePolicy = iota
+	// Reject rejects the command with a CartNotCreatedError
+	Reject
+)
+
 // NewCartAggregate creates a new cart aggregate
-func NewCartAggregate(store *common.EventStore) *CartAggregate {
+func NewCartAggregate(store common.Store) *CartAggregate {
 	return &CartAggregate{
 		BaseAggregate: common.NewBaseAggregate(store),
-		items:         make(map[string]int),
+		lines:         cartLines.NewEntities(),
+		giftCards:     make(map[string]float64),
+		pending:       make(map[string]bool),
 	}
 }
 
-// Items returns a copy of the items in the cart
+// Items returns the quantity of every line in the cart, keyed by LineKey
 func (ca *CartAggregate) Items() map[string]int {
-	items := make(map[string]int)
-	for k, v := range ca.items {
-		items[k] = v
+	items := make(map[string]int, ca.lines.Len())
+	for _, line := range ca.lines.All() {
+		items[line.Key] = line.Quantity
 	}
 	return items
 }
 
-// Handle processes commands and returns resulting events
-func (ca *CartAggregate) Handle(command interface{}) (*common.Event, error) {
-	// Extract aggregate ID and determine if we need to hydrate
-	var aggregateID string
-	switch cmd := command.(type) {
-	case *CreateCartCommand:
-		aggregateID = cmd.AggregateID
-	case *AddItemCommand:
-		aggregateID = cmd.AggregateID
-	case *RemoveItemCommand:
-		aggregateID = cmd.AggregateID
-	case *ClearCartCommand:
-		aggregateID = cmd.AggregateID
-	default:
-		return nil, errors.New("unknown command type")
+// Lines returns a copy of the lines in the cart, ordered by key
+func (ca *CartAggregate) Lines() []CartLine {
+	lines := make([]CartLine, 0, ca.lines.Len())
+	for _, line := range ca.lines.All() {
+		lines = append(lines, *line)
 	}
+	return lines
+}
 
-	// Only hydrate if we have an aggregate ID and we're not creating a new cart
-	if aggregateID != "" && !ca.IsLive() {
-		if err := ca.Hydrate(aggregateID); err != nil {
-			return nil, err
+// Line returns a copy of the line holding an item with the given variant options
+func (ca *CartAggregate) Line(itemID string, options map[string]string) (CartLine, bool) {
+	line, exists := ca.lines.Get(LineKey(itemID, options))
+	if !exists {
+		return CartLine{}, false
+	}
+	return *line, true
+}
+
+// GiftCards returns a copy of the amounts paid with gift cards, keyed by card ID
+func (ca *CartAggregate) GiftCards() map[string]float64 {
+	giftCards := make(map[string]float64, len(ca.giftCards))
+	for k, v := range ca.giftCards {
+		giftCards[k] = v
+	}
+	return giftCards
+}
+
+// PendingGiftCards returns the IDs of the gift cards whose balance reservation is
+// not confirmed yet, sorted
+func (ca *CartAggregate) PendingGiftCards() []string {
+	pending := make([]string, 0, len(ca.pending))
+	for giftCardID := range ca.pending {
+		pending = append(pending, giftCardID)
+	}
+	sort.Strings(pending)
+	return pending
+}
+
+// NewSnapshottingCartAggregate creates a cart aggregate that hydrates from the
+// latest snapshot in snapshots and the events after it
+func NewSnapshottingCartAggregate(store common.Store, snapshots common.SnapshotStore) *CartAggregate {
+	ca := NewCartAggregate(store)
+	ca.SetSnapshots(snapshots)
+	return ca
+}
+
+// cartSnapshot is the state of a cart saved in a snapshot. Snapshots taken before
+// Lines was added only hold the quantities in Items.
+type cartSnapshot struct {
+	Items     map[string]int     `json:"items"`
+	Lines     []CartLine         `json:"lines,omitempty"`
+	GiftCards map[string]float64 `json:"gift_cards,omitempty"`
+	// PendingGiftCards are the gift cards awaiting their balance reservation
+	PendingGiftCards []string `json:"pending_gift_cards,omitempty"`
+	Deleted          bool     `json:"deleted,omitempty"`
+	ClosedBy         string   `json:"closed_by,omitempty"`
+}
+
+// SnapshotState returns the cart's items for a snapshot. See common.Snapshotter.
+func (ca *CartAggregate) SnapshotState() (interface{}, error) {
+	return cartSnapshot{
+		Items:            ca.Items(),
+		Lines:            ca.Lines(),
+		GiftCards:        ca.GiftCards(),
+		PendingGiftCards: ca.PendingGiftCards(),
+		Deleted:          ca.deleted,
+		ClosedBy:         ca.ClosedBy(),
+	}, nil
+}
+
+// RestoreSnapshot replaces the cart's items with a snapshot's. See common.Snapshotter.
+func (ca *CartAggregate) RestoreSnapshot(snapshot common.Snapshot) error {
+	var state cartSnapshot
+	if err := json.Unmarshal(snapshot.State, &state); err != nil {
+		return err
+	}
+	ca.lines = cartLines.NewEntities()
+	if state.Lines != nil {
+		for _, line := range state.Lines {
+			line := line
+			ca.lines.Put(line.Key, &line)
+		}
+	} else {
+		for key, quantity := range state.Items {
+			ca.lines.Put(key, &CartLine{Key: key, Item: key, Quantity: quantity})
 		}
 	}
+	ca.giftCards = make(map[string]float64, len(state.GiftCards))
+	for giftCardID, amount := range state.GiftCards {
+		ca.giftCards[giftCardID] = amount
+	}
+	ca.pending = make(map[string]bool, len(state.PendingGiftCards))
+	for _, giftCardID := range state.PendingGiftCards {
+		ca.pending[giftCardID] = true
+	}
+	ca.deleted = state.Deleted
+	if state.ClosedBy != "" {
+		ca.Close(state.ClosedBy)
+	}
+	return nil
+}
+
+// SetShippingRater sets the rater quoting shipping options; nil uses
+// StandardShippingRates
+func (ca *CartAggregate) SetShippingRater(rater ShippingRater) {
+	ca.shipping = rater
+}
+
+// SetReorderTranslator sets the translator ReorderCart fills the new cart with; nil
+// uses SameItems
+func (ca *CartAggregate) SetReorderTranslator(translator ReorderTranslator) {
+	ca.reorder = translator
+}
+
+// SetAutoCreatePolicy sets what AddItem does when it names no cart
+func (ca *CartAggregate) SetAutoCreatePolicy(policy AutoCreatePolicy) {
+	ca.autoCreate = policy
+}
+
+// SetStreamNamer sets the namer new carts' stream IDs come from, instead of
+// common.DefaultStreamNamer
+func (ca *CartAggregate) SetStreamNamer(namer common.StreamNamer) {
+	ca.namer = namer
+}
+
+// SetFeatures sets the feature flags active for the commands the aggregate handles,
+// usually common.ActiveFeaturesFrom the command's context
+func (ca *CartAggregate) SetFeatures(features common.ActiveFeatures) {
+	ca.features = features
+}
+
+// IsDeleted returns whether the cart is soft-deleted
+func (ca *CartAggregate) IsDeleted() bool {
+	return ca.deleted
+}
+
+// NewRepository creates a repository loading carts from store, e.g. to load the cart
+// a command names before handling it (see common.Repository.LoadFor)
+func NewRepository(store common.Store) *common.Repository[*CartAggregate] {
+	repository := common.NewRepository(store, NewCartAggregate)
+	repository.Category = CategoryCart
+	return repository
+}
+
+// newCartID returns the stream ID of a new cart, "cart-<uuid>" by default, so the
+// cart falls in CategoryCart
+func (ca *CartAggregate) newCartID() string {
+	if ca.namer == nil {
+		return common.DefaultStreamNamer.NewStreamID(CategoryCart)
+	}
+	return ca.namer.NewStreamID(CategoryCart)
+}
+
+// Handle processes commands and returns resulting events. A command naming a cart is
+// handled against that cart's history, hydrated first by a fresh aggregate.
+func (ca *CartAggregate) Handle(command common.Command) (*common.Event, error) {
+	if err := ca.loadFor(command); err != nil {
+		return nil, err
+	}
+
+	// A checked-out or expired cart is closed for good
+	if err := ca.CheckOpen(); err != nil {
+		return nil, err
+	}
+	// A deleted cart only accepts being restored
+	if _, restoring := command.(*RestoreCartCommand); ca.deleted && !restoring {
+		return nil, &CartDeletedError{CartID: ca.ID()}
+	}
 
 	switch cmd := command.(type) {
 	case *CreateCartCommand:
 		return ca.handleCreateCart()
 	case *AddItemCommand:
 		return ca.handleAddItem(cmd)
+	case *AddItemsCommand:
+		return ca.handleAddItems(cmd)
+	case *ReorderCartCommand:
+		return ca.handleReorderCart(cmd)
 	case *RemoveItemCommand:
 		return ca.handleRemoveItem(cmd)
 	case *ClearCartCommand:
 		return ca.handleClearCart(cmd)
+	case *AnnotateItemCommand:
+		return ca.handleAnnotateItem(cmd)
+	case *RenameCartCommand:
+		return ca.handleRenameCart(cmd)
+	case *SetCartAttributeCommand:
+		return ca.handleSetCartAttribute(cmd)
+	case *SelectShippingOptionCommand:
+		return ca.handleSelectShippingOption(cmd)
+	case *ApplyGiftCardCommand:
+		return ca.handleApplyGiftCard(cmd)
+	case *RemoveGiftCardCommand:
+		return ca.handleRemoveGiftCard(cmd)
+	case *ConfirmGiftCardCommand:
+		return ca.handleConfirmGiftCard(cmd)
+	case *CheckoutCartCommand:
+		return ca.handleCheckoutCart(cmd)
+	case *ExpireCartCommand:
+		return ca.handleExpireCart(cmd)
+	case *DeleteCartCommand:
+		return ca.handleDeleteCart(cmd)
+	case *RestoreCartCommand:
+		return ca.handleRestoreCart(cmd)
 	default:
-		return nil, errors.New("unknown command type")
+		return nil, &common.UnknownCommandError{
+			CommandType: command.CommandType(),
+			Registered:  common.DefaultRegistry.CommandTypes(AggregateTypeCart),
+		}
+	}
+}
+
+// loadFor hydrates a fresh aggregate from the stream of the cart a command names. A
+// cart without events rejects the command with a CartNotCreatedError, rather than
+// starting a stream without its CartCreated event, and an aggregate already holding
+// another cart refuses it. CreateCart always starts a new cart, whatever it names.
+func (ca *CartAggregate) loadFor(command common.Command) error {
+	id := command.AggregateID()
+	if _, creating := command.(*CreateCartCommand); creating || id == "" {
+		return nil
+	}
+	if !ca.IsLive() {
+		if err := ca.Hydrate(id); err != nil {
+			return err
+		}
+	}
+	// Hydrating a stream that doesn't exist leaves the aggregate at version 0
+	if ca.Version() == 0 {
+		return &CartNotCreatedError{CartID: id}
 	}
+	if ca.ID() != id {
+		return fmt.Errorf("cart %s cannot handle a command for cart %s", ca.ID(), id)
+	}
+	return nil
 }
 
-// On applies events to aggregate state
+// cartEventHandlers routes cart events to the On<EventType> methods of the
+// aggregate. Item events are handled by the cart's lines; shipping and labels don't
+// affect what commands the cart accepts, so they only move its version.
+var cartEventHandlers = common.MustEventHandlers[*CartAggregate](common.DefaultRegistry).
+	Ignore(cartLines.EventTypes()...).
+	Ignore(EventTypeShippingOptionSelected, EventTypeCartRenamed, EventTypeCartAttributeSet)
+
+// On applies events to aggregate state, routing item events to their line
 func (ca *CartAggregate) On(event *common.Event) error {
-	switch event.Type {
-	case EventTypeCartCreated:
-		return ca.onCartCreated(event)
-	case EventTypeItemAdded:
-		return ca.onItemAdded(event)
-	case EventTypeItemRemoved:
-		return ca.onItemRemoved(event)
-	case EventTypeCartCleared:
-		return ca.onCartCleared(event)
-	default:
-		return errors.New("unhandled event type: " + event.Type)
+	if err := ca.lines.On(event); err != nil {
+		return err
+	}
+	if err := cartEventHandlers.Dispatch(ca, event); err != nil {
+		return err
 	}
+	return ca.Advance(event)
 }
 
-// Hydrate rebuilds the aggregate state from its event stream
+// Hydrate rebuilds the aggregate state from its event stream, starting from its
+// latest snapshot when the aggregate has a snapshot store
 func (ca *CartAggregate) Hydrate(id string) error {
-	return ca.BaseAggregate.Hydrate(id, ca.On)
+	return ca.BaseAggregate.HydrateFromSnapshot(id, ca.RestoreSnapshot, ca.On)
 }
 
-// Event handlers
+// HydrateUpTo rebuilds the cart as it was at a version of its stream, as a read-only
+// view rejecting commands
+func (ca *CartAggregate) HydrateUpTo(id string, version int) error {
+	return ca.BaseAggregate.HydrateUpTo(id, version, ca.RestoreSnapshot, ca.On)
+}
 
-func (ca *CartAggregate) onCartCreated(event *common.Event) error {
-	ca.SetID(event.AggregateID)
-	ca.SetVersion(event.Version)
-	// Mark the aggregate as live after creation
-	if !ca.IsLive() {
-		ca.SetLive(true)
-	}
-	return nil
+// Event handlers, called by On
+
+// OnCartCreated makes the aggregate live
+func (ca *CartAggregate) OnCartCreated(event *common.Event) {
+	ca.Begin(event)
 }
 
-func (ca *CartAggregate) onItemAdded(event *common.Event) error {
-	if item, ok := event.Data["item"].(string); ok {
-		if ca.items[item] == 0 {
-			ca.items[item] = 1
-		} else {
-			ca.items[item]++
-		}
-	}
-	ca.SetVersion(event.Version)
-	return nil
+// OnCartCleared empties the cart
+func (ca *CartAggregate) OnCartCleared() {
+	ca.lines.Clear()
 }
 
-func (ca *CartAggregate) onItemRemoved(event *common.Event) error {
-	if item, ok := event.Data["item"].(string); ok {
-		if ca.items[item] > 0 {
-			ca.items[item]--
-			if ca.items[item] == 0 {
-				delete(ca.items, item)
-			}
-		}
-	}
-	ca.SetVersion(event.Version)
-	return nil
+// OnGiftCardApplied records the amount paid with the card, pending its reservation
+func (ca *CartAggregate) OnGiftCardApplied(data GiftCardData) {
+	ca.giftCards[data.GiftCardID] = data.Amount
+	ca.pending[data.GiftCardID] = true
 }
 
-func (ca *CartAggregate) onCartCleared(event *common.Event) error {
-	ca.items = make(map[string]int)
-	ca.SetVersion(event.Version)
-	return nil
+// OnGiftCardConfirmed records that the card's balance is reserved
+func (ca *CartAggregate) OnGiftCardConfirmed(data GiftCardData) {
+	delete(ca.pending, data.GiftCardID)
+}
+
+// OnGiftCardRemoved forgets the card
+func (ca *CartAggregate) OnGiftCardRemoved(data GiftCardData) {
+	delete(ca.giftCards, data.GiftCardID)
+	delete(ca.pending, data.GiftCardID)
+}
+
+// OnCartCheckedOut closes the cart
+func (ca *CartAggregate) OnCartCheckedOut() {
+	ca.Close(EventTypeCartCheckedOut)
+}
+
+// OnCartExpired closes the cart
+func (ca *CartAggregate) OnCartExpired() {
+	ca.Close(EventTypeCartExpired)
+}
+
+// OnCartDeleted soft-deletes the cart
+func (ca *CartAggregate) OnCartDeleted() {
+	ca.deleted = true
+}
+
+// OnCartRestored undoes a soft delete
+func (ca *CartAggregate) OnCartRestored() {
+	ca.deleted = false
 }
 
 // Command handlers
 
 func (ca *CartAggregate) handleCreateCart() (*common.Event, error) {
-	cartID := uuid.New().String()
-	event := NewCartCreatedEvent(cartID)
+	event := NewCartCreatedEvent(ca.newCartID())
 
 	if err := ca.On(event); err != nil {
 		return nil, err
@@ -153,31 +386,210 @@ func (ca *CartAggregate) handleCreateCart() (*common.Event, error) {
 	return event, nil
 }
 
+// handleAddItem adds an item to the cart, one ItemAdded event per unit. Without a
+// cart ID, the AutoCreate policy creates a cart first; its CartCreated event is
+// committed with the ItemAdded ones, so none is stored alone. The last ItemAdded
+// event is returned.
 func (ca *CartAggregate) handleAddItem(cmd *AddItemCommand) (*common.Event, error) {
-	// If cart doesn't exist (no aggregate ID), create it first
-	if cmd.AggregateID == "" || !ca.IsLive() {
-		createEvent, err := ca.handleCreateCart()
-		if err != nil {
+	uow := common.NewUnitOfWork(ca.Store(), nil)
+	if cmd.CartID == "" {
+		if ca.autoCreate == Reject {
+			return nil, &CartNotCreatedError{}
+		}
+		created := NewCartCreatedEvent(ca.newCartID())
+		if err := ca.On(created); err != nil {
+			return nil, err
+		}
+		if err := uow.Append(created); err != nil {
+			return nil, err
+		}
+	}
+
+	if !ca.IsLive() {
+		return nil, &CartNotCreatedError{CartID: ca.ID()}
+	}
+
+	// Behind FlagQuantityAwareAdd, every unit of the quantity is added at once
+	quantity := 1
+	if ca.features.Enabled(FlagQuantityAwareAdd) && cmd.Quantity > 1 {
+		quantity = cmd.Quantity
+	}
+
+	// Business rule: at most MaxItems items in a cart
+	totalItems := ca.itemCount()
+	if totalItems+quantity > MaxItems {
+		err := &CartItemLimitExceededError{CartID: ca.ID(), Limit: MaxItems, Current: totalItems}
+		if quantity > 1 {
+			err.Adding = quantity
+		}
+		return nil, err
+	}
+
+	var event *common.Event
+	for range quantity {
+		event = NewVariantAddedEvent(ca.ID(), ca.Version()+1, cmd.ItemID, cmd.Options)
+
+		if err := ca.On(event); err != nil {
 			return nil, err
 		}
-		// Update the command with the new cart ID
-		cmd.AggregateID = createEvent.AggregateID
+
+		if err := uow.Append(event); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := uow.Commit(); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// handleAddItems adds every line of the batch or none. The events are buffered in a
+// unit of work and committed together, so a failed append stores none of them; the
+// last event is returned.
+func (ca *CartAggregate) handleAddItems(cmd *AddItemsCommand) (*common.Event, error) {
+	if !ca.IsLive() {
+		return nil, &CartNotCreatedError{CartID: ca.ID()}
+	}
+	if len(cmd.Lines) == 0 {
+		return nil, &common.InvalidCommandError{Message: "no items to add"}
+	}
+
+	// Business rule: the whole batch must fit in the cart's MaxItems
+	totalItems := ca.itemCount()
+	if totalItems+len(cmd.Lines) > MaxItems {
+		return nil, &CartItemLimitExceededError{CartID: ca.ID(), Limit: MaxItems, Current: totalItems, Adding: len(cmd.Lines)}
+	}
+
+	uow := common.NewUnitOfWork(ca.Store(), nil)
+	var event *common.Event
+	for _, line := range cmd.Lines {
+		event = NewVariantAddedEvent(ca.ID(), ca.Version()+1, line.ItemID, line.Options)
+
+		if err := ca.On(event); err != nil {
+			return nil, err
+		}
+
+		if err := uow.Append(event); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := uow.Commit(); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// handleReorderCart reads the stream of the checked-out source cart, translates it
+// into lines and creates a new cart holding them. The new cart's events are
+// committed together; the last one is returned.
+func (ca *CartAggregate) handleReorderCart(cmd *ReorderCartCommand) (*common.Event, error) {
+	source := NewCartAggregate(ca.Store())
+	if err := source.Hydrate(cmd.SourceCartID); err != nil {
+		return nil, err
+	}
+	// Hydrating a stream that doesn't exist leaves the aggregate at version 0
+	if source.Version() == 0 {
+		return nil, &CartNotCreatedError{CartID: cmd.SourceCartID}
+	}
+	if source.ClosedBy() != EventTypeCartCheckedOut {
+		return nil, &CartNotCheckedOutError{CartID: cmd.SourceCartID}
+	}
+	history, err := ca.Store().GetStream(cmd.SourceCartID)
+	if err != nil {
+		return nil, err
+	}
+
+	translator := ca.reorder
+	if translator == nil {
+		translator = SameItems
+	}
+	lines, err := translator.Translate(history)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, &CartEmptyError{CartID: cmd.SourceCartID}
+	}
+	if len(lines) > MaxItems {
+		return nil, &CartItemLimitExceededError{CartID: cmd.SourceCartID, Limit: MaxItems, Adding: len(lines)}
+	}
+
+	uow := common.NewUnitOfWork(ca.Store(), nil)
+	apply := func(event *common.Event) error {
+		if err := ca.On(event); err != nil {
+			return err
+		}
+		return uow.Append(event)
+	}
+
+	event := NewCartCreatedEvent(ca.newCartID())
+	event.Metadata = map[string]interface{}{ReorderedFromKey: cmd.SourceCartID}
+	// The new cart is taxed like the one it reorders
+	if region, ok := history[0].Metadata[RegionKey]; ok {
+		event.Metadata[RegionKey] = region
+	}
+	if err := apply(event); err != nil {
+		return nil, err
+	}
+	for _, line := range lines {
+		event = NewVariantAddedEvent(ca.ID(), ca.Version()+1, line.ItemID, line.Options)
+		if err := apply(event); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := uow.Commit(); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// itemCount returns the number of items in the cart, counting every unit of a line
+func (ca *CartAggregate) itemCount() int {
+	count := 0
+	for _, line := range ca.lines.All() {
+		count += line.Quantity
 	}
+	return count
+}
 
+func (ca *CartAggregate) handleRemoveItem(cmd *RemoveItemCommand) (*common.Event, error) {
 	if !ca.IsLive() {
-		return nil, &common.InvalidCommandError{Message: "cart not initialized"}
+		return nil, &CartNotCreatedError{CartID: ca.ID()}
 	}
 
-	// Business rule: maximum 3 total items in cart
-	totalItems := 0
-	for _, quantity := range ca.items {
-		totalItems += quantity
+	if _, exists := ca.Line(cmd.ItemID, cmd.Options); !exists {
+		return nil, &ItemNotInCartError{CartID: ca.ID(), ItemID: LineKey(cmd.ItemID, cmd.Options)}
 	}
-	if totalItems >= 3 {
-		return nil, &common.InvalidCommandError{Message: "too many items in cart"}
+
+	event := NewVariantRemovedEvent(ca.ID(), ca.Version()+1, cmd.ItemID, cmd.Options)
+
+	if err := ca.On(event); err != nil {
+		return nil, err
+	}
+
+	if err := ca.Store().Append(event); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+func (ca *CartAggregate) handleAnnotateItem(cmd *AnnotateItemCommand) (*common.Event, error) {
+	if !ca.IsLive() {
+		return nil, &CartNotCreatedError{CartID: ca.ID()}
+	}
+
+	if _, exists := ca.Line(cmd.ItemID, cmd.Options); !exists {
+		return nil, &ItemNotInCartError{CartID: ca.ID(), ItemID: LineKey(cmd.ItemID, cmd.Options)}
 	}
 
-	event := NewItemAddedEvent(ca.ID(), ca.Version()+1, cmd.ItemID)
+	event := NewItemAnnotatedEvent(ca.ID(), ca.Version()+1, cmd.ItemID, cmd.Options, cmd.Note, cmd.Gift)
 
 	if err := ca.On(event); err != nil {
 		return nil, err
@@ -190,16 +602,30 @@ func (ca *CartAggregate) handleAddItem(cmd *AddItemCommand) (*common.Event, erro
 	return event, nil
 }
 
-func (ca *CartAggregate) handleRemoveItem(cmd *RemoveItemCommand) (*common.Event, error) {
+func (ca *CartAggregate) handleRenameCart(cmd *RenameCartCommand) (*common.Event, error) {
 	if !ca.IsLive() {
-		return nil, &common.InvalidCommandError{Message: "cart not initialized"}
+		return nil, &CartNotCreatedError{CartID: ca.ID()}
 	}
 
-	if ca.items[cmd.ItemID] == 0 {
-		return nil, &common.InvalidCommandError{Message: "item " + cmd.ItemID + " is not in the cart"}
+	event := NewCartRenamedEvent(ca.ID(), ca.Version()+1, cmd.Name)
+
+	if err := ca.On(event); err != nil {
+		return nil, err
 	}
 
-	event := NewItemRemovedEvent(ca.ID(), ca.Version()+1, cmd.ItemID)
+	if err := ca.Store().Append(event); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+func (ca *CartAggregate) handleSetCartAttribute(cmd *SetCartAttributeCommand) (*common.Event, error) {
+	if !ca.IsLive() {
+		return nil, &CartNotCreatedError{CartID: ca.ID()}
+	}
+
+	event := NewCartAttributeSetEvent(ca.ID(), ca.Version()+1, cmd.Key, cmd.Value)
 
 	if err := ca.On(event); err != nil {
 		return nil, err
@@ -214,7 +640,7 @@ func (ca *CartAggregate) handleRemoveItem(cmd *RemoveItemCommand) (*common.Event
 
 func (ca *CartAggregate) handleClearCart(cmd *ClearCartCommand) (*common.Event, error) {
 	if !ca.IsLive() {
-		return nil, &common.InvalidCommandError{Message: "cart not initialized"}
+		return nil, &CartNotCreatedError{CartID: ca.ID()}
 	}
 
 	event := NewCartClearedEvent(ca.ID(), ca.Version()+1)
@@ -229,3 +655,180 @@ func (ca *CartAggregate) handleClearCart(cmd *ClearCartCommand) (*common.Event,
 
 	return event, nil
 }
+
+func (ca *CartAggregate) handleSelectShippingOption(cmd *SelectShippingOptionCommand) (*common.Event, error) {
+	if !ca.IsLive() {
+		return nil, &CartNotCreatedError{CartID: ca.ID()}
+	}
+	if ca.lines.Len() == 0 {
+		return nil, &CartEmptyError{CartID: ca.ID()}
+	}
+
+	rater := ca.shipping
+	if rater == nil {
+		rater = StandardShippingRates()
+	}
+	cost, available, err := rater.Rate(cmd.Option, ca.Items())
+	if err != nil {
+		return nil, err
+	}
+	if !available {
+		return nil, &ShippingUnavailableError{CartID: ca.ID(), Option: cmd.Option}
+	}
+
+	event := NewShippingOptionSelectedEvent(ca.ID(), ca.Version()+1, cmd.Option, cost)
+
+	if err := ca.On(event); err != nil {
+		return nil, err
+	}
+
+	if err := ca.Store().Append(event); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+func (ca *CartAggregate) handleApplyGiftCard(cmd *ApplyGiftCardCommand) (*common.Event, error) {
+	if !ca.IsLive() {
+		return nil, &CartNotCreatedError{CartID: ca.ID()}
+	}
+	if _, applied := ca.giftCards[cmd.GiftCardID]; applied {
+		return nil, &GiftCardAppliedError{CartID: ca.ID(), GiftCardID: cmd.GiftCardID}
+	}
+
+	event := NewGiftCardAppliedEvent(ca.ID(), ca.Version()+1, cmd.GiftCardID, cmd.Amount)
+
+	if err := ca.On(event); err != nil {
+		return nil, err
+	}
+
+	if err := ca.Store().Append(event); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+func (ca *CartAggregate) handleRemoveGiftCard(cmd *RemoveGiftCardCommand) (*common.Event, error) {
+	if !ca.IsLive() {
+		return nil, &CartNotCreatedError{CartID: ca.ID()}
+	}
+	if _, applied := ca.giftCards[cmd.GiftCardID]; !applied {
+		return nil, &GiftCardNotAppliedError{CartID: ca.ID(), GiftCardID: cmd.GiftCardID}
+	}
+
+	event := NewGiftCardRemovedEvent(ca.ID(), ca.Version()+1, cmd.GiftCardID)
+
+	if err := ca.On(event); err != nil {
+		return nil, err
+	}
+
+	if err := ca.Store().Append(event); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+func (ca *CartAggregate) handleConfirmGiftCard(cmd *ConfirmGiftCardCommand) (*common.Event, error) {
+	if !ca.IsLive() {
+		return nil, &CartNotCreatedError{CartID: ca.ID()}
+	}
+	if _, applied := ca.giftCards[cmd.GiftCardID]; !applied {
+		return nil, &GiftCardNotAppliedError{CartID: ca.ID(), GiftCardID: cmd.GiftCardID}
+	}
+	if !ca.pending[cmd.GiftCardID] {
+		return nil, &GiftCardNotPendingError{CartID: ca.ID(), GiftCardID: cmd.GiftCardID}
+	}
+
+	event := NewGiftCardConfirmedEvent(ca.ID(), ca.Version()+1, cmd.GiftCardID)
+
+	if err := ca.On(event); err != nil {
+		return nil, err
+	}
+
+	if err := ca.Store().Append(event); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+func (ca *CartAggregate) handleCheckoutCart(cmd *CheckoutCartCommand) (*common.Event, error) {
+	if !ca.IsLive() {
+		return nil, &CartNotCreatedError{CartID: ca.ID()}
+	}
+	if pending := ca.PendingGiftCards(); len(pending) > 0 {
+		return nil, &GiftCardNotConfirmedError{CartID: ca.ID(), GiftCardID: pending[0]}
+	}
+
+	event := NewCartCheckedOutEvent(ca.ID(), ca.Version()+1)
+
+	if err := ca.On(event); err != nil {
+		return nil, err
+	}
+
+	if err := ca.Store().Append(event); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+func (ca *CartAggregate) handleExpireCart(cmd *ExpireCartCommand) (*common.Event, error) {
+	if !ca.IsLive() {
+		return nil, &CartNotCreatedError{CartID: ca.ID()}
+	}
+
+	event := NewCartExpiredEvent(ca.ID(), ca.Version()+1)
+
+	if err := ca.On(event); err != nil {
+		return nil, err
+	}
+
+	if err := ca.Store().Append(event); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+func (ca *CartAggregate) handleDeleteCart(cmd *DeleteCartCommand) (*common.Event, error) {
+	if !ca.IsLive() {
+		return nil, &CartNotCreatedError{CartID: ca.ID()}
+	}
+
+	event := NewCartDeletedEvent(ca.ID(), ca.Version()+1)
+
+	if err := ca.On(event); err != nil {
+		return nil, err
+	}
+
+	if err := ca.Store().Append(event); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+func (ca *CartAggregate) handleRestoreCart(cmd *RestoreCartCommand) (*common.Event, error) {
+	if !ca.IsLive() {
+		return nil, &CartNotCreatedError{CartID: ca.ID()}
+	}
+	if !ca.deleted {
+		return nil, &CartNotDeletedError{CartID: ca.ID()}
+	}
+
+	event := NewCartRestoredEvent(ca.ID(), ca.Version()+1)
+
+	if err := ca.On(event); err != nil {
+		return nil, err
+	}
+
+	if err := ca.Store().Append(event); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
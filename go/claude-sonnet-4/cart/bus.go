@@ -0,0 +1,100 @@
+// Package cart registers the cart command handlers with a command bus.
+package cart
+
+import (
+	"context"
+	"errors"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+)
+
+// CommandOption configures the carts handling the commands registered by
+// RegisterCommands
+type CommandOption func(*CartAggregate)
+
+// WithShippingRater quotes shipping options with rater instead of
+// StandardShippingRates
+func WithShippingRater(rater ShippingRater) CommandOption {
+	return func(ca *CartAggregate) {
+		ca.SetShippingRater(rater)
+	}
+}
+
+// WithReorderTranslator fills reordered carts with translator instead of SameItems
+func WithReorderTranslator(translator ReorderTranslator) CommandOption {
+	return func(ca *CartAggregate) {
+		ca.SetReorderTranslator(translator)
+	}
+}
+
+// WithAutoCreatePolicy sets what AddItem does when it names no cart, instead of
+// AutoCreate
+func WithAutoCreatePolicy(policy AutoCreatePolicy) CommandOption {
+	return func(ca *CartAggregate) {
+		ca.SetAutoCreatePolicy(policy)
+	}
+}
+
+// WithStreamNamer names new carts' streams with namer instead of
+// common.DefaultStreamNamer. Repositories reading carts back by category need the
+// same namer.
+func WithStreamNamer(namer common.StreamNamer) CommandOption {
+	return func(ca *CartAggregate) {
+		ca.SetStreamNamer(namer)
+	}
+}
+
+// RegisterCommands registers a handler for every cart command.
+// Each command is handled by a fresh aggregate loaded from the store before handling
+// (see common.Repository.LoadFor), and its events carry the metadata recorded in the
+// context (see bus.ActorMetadata). A command naming a cart without events is rejected
+// with a CartNotCreatedError. A version required by the context (see
+// common.WithExpectedVersion) must match the loaded cart; the append then fails if
+// another write lands in between. Business rules behind feature flags follow the
+// flags active in the context (see bus.FeatureFlags). Options configure every cart
+// the handlers load, e.g. with the shipping rater of a carrier integration.
+func RegisterCommands(commands *bus.CommandBus, store common.Store, opts ...CommandOption) {
+	handle := func(ctx context.Context, command common.Command) (*common.Event, error) {
+		store := common.MetadataStore(store, common.EventMetadataFrom(ctx))
+		aggregate := NewCartAggregate(store)
+		// CreateCart always starts a new cart, whatever it names
+		if _, creating := command.(*CreateCartCommand); !creating {
+			loaded, err := NewRepository(store).LoadFor(command)
+			if errors.Is(err, common.ErrStreamNotFound) {
+				return nil, &CartNotCreatedError{CartID: command.AggregateID()}
+			}
+			if err != nil {
+				return nil, err
+			}
+			aggregate = loaded
+		}
+		if expected, ok := common.ExpectedVersionFrom(ctx); ok && command.AggregateID() != "" {
+			if aggregate.Version() != expected {
+				return nil, &common.ConcurrencyError{StreamID: command.AggregateID(), Expected: expected, Actual: aggregate.Version()}
+			}
+		}
+		for _, opt := range opts {
+			opt(aggregate)
+		}
+		aggregate.SetFeatures(common.ActiveFeaturesFrom(ctx))
+		return aggregate.Handle(command)
+	}
+	commands.Register(CommandTypeCreateCart, handle)
+	commands.Register(CommandTypeAddItem, handle)
+	commands.Register(CommandTypeAddItems, handle)
+	commands.Register(CommandTypeReorderCart, handle)
+	commands.Register(CommandTypeRemoveItem, handle)
+	commands.Register(CommandTypeClearCart, handle)
+	commands.Register(CommandTypeAnnotateItem, handle)
+	commands.Register(CommandTypeRenameCart, handle)
+	commands.Register(CommandTypeSetCartAttribute, handle)
+	commands.Register(CommandTypeSelectShippingOption, handle)
+	commands.Register(CommandTypeApplyGiftCard, handle)
+	commands.Register(CommandTypeRemoveGiftCard, handle)
+	commands.Register(CommandTypeConfirmGiftCard, handle)
+	commands.Register(CommandTypeCheckoutCart, handle)
+	commands.Register(CommandTypeExpireCart, handle)
+	commands.Register(CommandTypeDeleteCart, handle)
+	commands.Register(CommandTypeRestoreCart, handle)
+}
@@ -0,0 +1,468 @@
+package cart
+
+import (
+	"context"
+	"errors"
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+	"simple-event-modeling/notifications"
+	"simple-event-modeling/quota"
+	"simple-event-modeling/rules"
+	"simple-event-modeling/typedstore"
+	"simple-event-modeling/unique"
+	"testing"
+)
+
+// ownerAuthorizer lets anyone create a cart and only the creator change it
+type ownerAuthorizer struct {
+	owners map[string]string
+}
+
+func (a *ownerAuthorizer) Authorize(_ context.Context, principal bus.Principal, command common.Command) error {
+	if command.AggregateID() == "" || a.owners[command.AggregateID()] == principal.ID {
+		return nil
+	}
+	return &common.UnauthorizedError{
+		Principal:   principal.ID,
+		CommandType: command.CommandType(),
+		AggregateID: command.AggregateID(),
+		Reason:      "not the cart owner",
+	}
+}
+
+func TestRegisterCommands_RejectsNonOwners(t *testing.T) {
+	store := common.NewEventStore()
+	authorizer := &ownerAuthorizer{owners: make(map[string]string)}
+	commands := bus.NewCommandBus(bus.Authorization(authorizer), bus.Validation())
+	RegisterCommands(commands, store)
+
+	alice := bus.WithPrincipal(context.Background(), bus.Principal{ID: "alice"})
+	bob := bus.WithPrincipal(context.Background(), bus.Principal{ID: "bob"})
+
+	created, err := commands.Dispatch(alice, &CreateCartCommand{})
+	if err != nil {
+		t.Fatalf("Error creating cart: %v", err)
+	}
+	authorizer.owners[created.AggregateID] = "alice"
+
+	if _, err := commands.Dispatch(alice, &AddItemCommand{CartID: created.AggregateID, ItemID: "apple"}); err != nil {
+		t.Errorf("Expected owner to add items, got %v", err)
+	}
+
+	// Bob is rejected before the aggregate checks whether pear is in the cart
+	_, err = commands.Dispatch(bob, &RemoveItemCommand{CartID: created.AggregateID, ItemID: "pear"})
+	var unauthorized *common.UnauthorizedError
+	if !errors.As(err, &unauthorized) {
+		t.Fatalf("Expected UnauthorizedError, got %v", err)
+	}
+	if version := store.GetStreamVersion(created.AggregateID); version != 2 {
+		t.Errorf("Expected version 2, got %d", version)
+	}
+}
+
+func TestRegisterCommands_RecordsActor(t *testing.T) {
+	store := common.NewEventStore()
+	commands := bus.NewCommandBus(bus.ActorMetadata())
+	RegisterCommands(commands, store)
+
+	alice := bus.WithPrincipal(context.Background(), bus.Principal{ID: "alice"})
+	if _, err := commands.Dispatch(alice, &AddItemCommand{ItemID: "apple"}); err != nil {
+		t.Fatalf("Error adding item: %v", err)
+	}
+	events := store.GetAllEvents()
+	if len(events) != 2 {
+		t.Fatalf("Expected the cart to be created with the item, got %d events", len(events))
+	}
+	for _, event := range events {
+		if event.Metadata[common.ActorIDKey] != "alice" {
+			t.Errorf("Expected %s to record alice as the actor, got %v", event.Type, event.Metadata)
+		}
+	}
+}
+
+func TestRegisterCommands_AddItemsRecordsActor(t *testing.T) {
+	store := common.NewEventStore()
+	commands := bus.NewCommandBus(bus.ActorMetadata())
+	RegisterCommands(commands, store)
+
+	alice := bus.WithPrincipal(context.Background(), bus.Principal{ID: "alice"})
+	created, _ := commands.Dispatch(alice, &CreateCartCommand{})
+	lines := []ItemLine{{ItemID: "apple"}, {ItemID: "pear"}, {ItemID: "plum"}}
+	if _, err := commands.Dispatch(alice, &AddItemsCommand{CartID: created.AggregateID, Lines: lines}); err != nil {
+		t.Fatalf("Error adding items: %v", err)
+	}
+	events, _ := store.GetStream(created.AggregateID)
+	if len(events) != 4 {
+		t.Fatalf("Expected an ItemAdded event per line, got %d events", len(events))
+	}
+	for _, event := range events[1:] {
+		if event.Type != EventTypeItemAdded || event.Metadata[common.ActorIDKey] != "alice" {
+			t.Errorf("Expected ItemAdded by alice, got %s with %v", event.Type, event.Metadata)
+		}
+	}
+}
+
+func TestRegisterCommands_RecordsEnvelope(t *testing.T) {
+	store := common.NewEventStore()
+	commands := bus.NewCommandBus(bus.EnvelopeMetadata())
+	RegisterCommands(commands, store)
+
+	ctx := context.Background()
+	created, _ := commands.Dispatch(ctx, bus.NewCommandEnvelope(&CreateCartCommand{}, "alice", bus.OriginMobile, "req-1"))
+	lines := []ItemLine{{ItemID: "apple"}, {ItemID: "pear"}}
+	commands.Dispatch(ctx, bus.NewCommandEnvelope(&AddItemsCommand{CartID: created.AggregateID, Lines: lines}, "bob", bus.OriginWeb, "req-2"))
+
+	events, _ := store.GetStream(created.AggregateID)
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(events))
+	}
+	for i, event := range events {
+		provenance := bus.ProvenanceOf(event)
+		if provenance.IssuedAt.IsZero() || provenance.IssuedAt.After(provenance.RecordedAt) {
+			t.Errorf("Expected %s issued before it was recorded, got %+v", event.Type, provenance)
+		}
+		if i == 0 && (provenance.ActorID != "alice" || provenance.Origin != bus.OriginMobile || provenance.ClientRequestID != "req-1") {
+			t.Errorf("Expected the cart created by alice on mobile, got %+v", provenance)
+		}
+		if i > 0 && (provenance.ActorID != "bob" || provenance.Origin != bus.OriginWeb || provenance.ClientRequestID != "req-2") {
+			t.Errorf("Expected %s added by bob on the web, got %+v", event.Type, provenance)
+		}
+	}
+}
+
+func TestRegisterCommands_RecordsRejections(t *testing.T) {
+	store := common.NewEventStore()
+	commands := bus.NewCommandBus(bus.RecordRejections(store, ""), bus.Validation())
+	RegisterCommands(commands, store)
+
+	created, err := commands.Dispatch(context.Background(), &CreateCartCommand{})
+	if err != nil {
+		t.Fatalf("Error creating cart: %v", err)
+	}
+	for _, item := range []string{"apple", "pear", "plum", "fig"} {
+		commands.Dispatch(context.Background(), &AddItemCommand{CartID: created.AggregateID, ItemID: item})
+	}
+
+	rejections, err := bus.ReadRejections(store, "")
+	if err != nil {
+		t.Fatalf("Error reading rejections: %v", err)
+	}
+	if len(rejections) != 1 {
+		t.Fatalf("Expected 1 rejection, got %d", len(rejections))
+	}
+	if rejections[0].CommandType != CommandTypeAddItem || rejections[0].Code != CodeCartItemLimitExceeded {
+		t.Errorf("Unexpected rejection: %+v", rejections[0])
+	}
+}
+
+func TestRegisterCommands_TypedStore(t *testing.T) {
+	store := typedstore.New(common.NewEventStore(), common.DefaultRegistry)
+	commands := bus.NewCommandBus()
+	RegisterCommands(commands, store)
+
+	ctx := context.Background()
+	created, err := commands.Dispatch(ctx, &CreateCartCommand{})
+	if err != nil {
+		t.Fatalf("Error creating cart: %v", err)
+	}
+	if _, err := commands.Dispatch(ctx, &AddItemsCommand{CartID: created.AggregateID, Lines: []ItemLine{{ItemID: "apple"}, {ItemID: "pear"}}}); err != nil {
+		t.Fatalf("Error adding items: %v", err)
+	}
+	if aggregate := store.AggregateOf(created.AggregateID); aggregate != AggregateTypeCart {
+		t.Errorf("Expected a cart stream, got %q", aggregate)
+	}
+
+	price := NewItemPriceChangedEvent(4, "apple", 2.5)
+	price.AggregateID = created.AggregateID
+	if err := store.Append(price); !errors.Is(err, common.ErrEventNotAllowed) {
+		t.Errorf("Expected a catalog event rejected in a cart stream, got %v", err)
+	}
+	if err := store.Append(NewItemPriceChangedEvent(1, "apple", 2.5)); err != nil {
+		t.Errorf("Expected a catalog event in the catalog stream, got %v", err)
+	}
+}
+
+func TestRegisterCommands_LoadsCartBeforeHandling(t *testing.T) {
+	store := common.NewEventStore()
+	commands := bus.NewCommandBus(bus.Validation())
+	RegisterCommands(commands, store)
+	ctx := context.Background()
+
+	created, err := commands.Dispatch(ctx, &CreateCartCommand{})
+	if err != nil {
+		t.Fatalf("Error creating cart: %v", err)
+	}
+	for _, item := range []string{"apple", "pear"} {
+		if _, err := commands.Dispatch(ctx, &AddItemCommand{CartID: created.AggregateID, ItemID: item}); err != nil {
+			t.Fatalf("Error adding %s: %v", item, err)
+		}
+	}
+	cart := NewCartAggregate(store)
+	cart.Hydrate(created.AggregateID)
+	if items := cart.Items(); cart.Version() != 3 || items["apple"] != 1 || items["pear"] != 1 {
+		t.Errorf("Expected both items in the one cart at version 3, got %v at version %d", items, cart.Version())
+	}
+
+	missing := "6f1c2b7e-0d7a-4c55-9e43-1a2b3c4d5e99"
+	_, err = commands.Dispatch(ctx, &AddItemCommand{CartID: missing, ItemID: "apple"})
+	var notCreated *CartNotCreatedError
+	if !errors.As(err, &notCreated) || notCreated.CartID != missing {
+		t.Errorf("Expected CartNotCreatedError for a cart without events, got %v", err)
+	}
+	if version := store.GetStreamVersion(missing); version != 0 {
+		t.Errorf("Expected no stream started for the missing cart, got version %d", version)
+	}
+}
+
+func TestRegisterCommands_Options(t *testing.T) {
+	store := common.NewEventStore()
+	commands := bus.NewCommandBus(bus.Validation())
+	RegisterCommands(commands, store, WithShippingRater(FakeShippingRater{"courier": 7.5}), WithAutoCreatePolicy(Reject))
+	ctx := context.Background()
+
+	var notCreated *CartNotCreatedError
+	if _, err := commands.Dispatch(ctx, &AddItemCommand{ItemID: "apple"}); !errors.As(err, &notCreated) {
+		t.Errorf("Expected the Reject policy to refuse an item without a cart, got %v", err)
+	}
+	created, _ := commands.Dispatch(ctx, &CreateCartCommand{})
+	commands.Dispatch(ctx, &AddItemCommand{CartID: created.AggregateID, ItemID: "apple"})
+	selected, err := commands.Dispatch(ctx, &SelectShippingOptionCommand{CartID: created.AggregateID, Option: "courier"})
+	if err != nil || selected.Data["cost"] != 7.5 {
+		t.Errorf("Expected the courier rate from the configured rater, got %v (%v)", selected, err)
+	}
+	var unavailable *ShippingUnavailableError
+	if _, err := commands.Dispatch(ctx, &SelectShippingOptionCommand{CartID: created.AggregateID, Option: "express"}); !errors.As(err, &unavailable) {
+		t.Errorf("Expected the standard rates to be replaced, got %v", err)
+	}
+}
+
+func TestRegisterCommands_CartsFallInTheirCategory(t *testing.T) {
+	store := common.NewEventStore()
+	commands := bus.NewCommandBus(bus.Validation())
+	RegisterCommands(commands, store)
+	ctx := context.Background()
+
+	created, err := commands.Dispatch(ctx, &CreateCartCommand{})
+	if err != nil {
+		t.Fatalf("Error creating cart: %v", err)
+	}
+	if _, err := commands.Dispatch(ctx, &AddItemCommand{CartID: created.AggregateID, ItemID: "apple"}); err != nil {
+		t.Fatalf("Expected a command naming the cart to pass validation, got %v", err)
+	}
+	autoCreated, err := commands.Dispatch(ctx, &AddItemCommand{ItemID: "pear"})
+	if err != nil {
+		t.Fatalf("Error adding item: %v", err)
+	}
+
+	ids := common.CategoryStreamIDs(store, common.DefaultStreamNamer, CategoryCart)
+	if len(ids) != 2 {
+		t.Fatalf("Expected both carts in the cart category, got %v", ids)
+	}
+	carts, err := NewRepository(store).LoadCategory()
+	if err != nil {
+		t.Fatalf("Error loading carts: %v", err)
+	}
+	if carts[created.AggregateID].Items()["apple"] != 1 || carts[autoCreated.AggregateID].Items()["pear"] != 1 {
+		t.Errorf("Expected both carts to load by category, got %v", carts)
+	}
+}
+
+func TestOneOpenCartPerCustomer(t *testing.T) {
+	store := common.NewEventStore()
+	index := unique.NewIndex(store, OpenCartIndex)
+	commands := bus.NewCommandBus(bus.Validation(), OneOpenCartPerCustomer(index))
+	RegisterCommands(commands, store)
+	alice := bus.WithPrincipal(context.Background(), bus.Principal{ID: "alice"})
+	bob := bus.WithPrincipal(context.Background(), bus.Principal{ID: "bob"})
+
+	// Adding an item without a cart opens one
+	opened, err := commands.Dispatch(alice, &AddItemCommand{ItemID: "apple"})
+	if err != nil {
+		t.Fatalf("Error adding item: %v", err)
+	}
+	var taken *unique.KeyTakenError
+	if _, err := commands.Dispatch(alice, &CreateCartCommand{}); !errors.As(err, &taken) || taken.Owner != opened.AggregateID {
+		t.Fatalf("Expected a second open cart to be rejected, got %v", err)
+	}
+	if _, err := commands.Dispatch(bob, &CreateCartCommand{}); err != nil {
+		t.Errorf("Expected another customer to open a cart, got %v", err)
+	}
+	if _, err := commands.Dispatch(alice, &AddItemCommand{CartID: opened.AggregateID, ItemID: "pear"}); err != nil {
+		t.Errorf("Expected the open cart to accept items, got %v", err)
+	}
+
+	if _, err := commands.Dispatch(alice, &CheckoutCartCommand{CartID: opened.AggregateID}); err != nil {
+		t.Fatalf("Error checking out: %v", err)
+	}
+	if _, err := commands.Dispatch(alice, &CreateCartCommand{}); err != nil {
+		t.Errorf("Expected a new cart once the open one is checked out, got %v", err)
+	}
+}
+
+func TestCustomerQuotaMiddleware(t *testing.T) {
+	store := common.NewEventStore()
+	usage := common.NewAsyncProjection(store, NewCustomerUsageProjection())
+	commands := bus.NewCommandBus(bus.Validation(), bus.ActorMetadata(), CustomerQuotaMiddleware(usage, CustomerQuotas{
+		MaxOpenCarts:   2,
+		MaxItemsPerDay: 3,
+		Entitlement: func(_ context.Context, customer string) (CustomerQuotas, bool) {
+			return CustomerQuotas{MaxOpenCarts: 3}, customer == "vip"
+		},
+	}))
+	RegisterCommands(commands, store)
+	alice := bus.WithPrincipal(context.Background(), bus.Principal{ID: "alice"})
+	vip := bus.WithPrincipal(context.Background(), bus.Principal{ID: "vip"})
+
+	first, err := commands.Dispatch(alice, &AddItemCommand{ItemID: "apple"})
+	if err != nil {
+		t.Fatalf("Error adding item: %v", err)
+	}
+	if _, err := commands.Dispatch(alice, &CreateCartCommand{}); err != nil {
+		t.Fatalf("Error creating cart: %v", err)
+	}
+	var exceeded *quota.QuotaExceededError
+	if _, err := commands.Dispatch(alice, &CreateCartCommand{}); !errors.As(err, &exceeded) || exceeded.Quota != QuotaOpenCarts || exceeded.Used != 2 {
+		t.Fatalf("Expected a third open cart to exceed the quota, got %v", err)
+	}
+	for range 3 {
+		if _, err := commands.Dispatch(vip, &CreateCartCommand{}); err != nil {
+			t.Fatalf("Expected the entitlement to allow 3 open carts, got %v", err)
+		}
+	}
+
+	add := &AddItemsCommand{CartID: first.AggregateID, Lines: []ItemLine{{ItemID: "pear"}, {ItemID: "plum"}, {ItemID: "fig"}}}
+	if _, err := commands.Dispatch(alice, add); !errors.As(err, &exceeded) || exceeded.Quota != QuotaItemsPerDay || exceeded.Used != 1 || exceeded.Requested != 3 {
+		t.Fatalf("Expected 3 more items to exceed the daily quota, got %v", err)
+	}
+	if _, err := commands.Dispatch(alice, &AddItemCommand{CartID: first.AggregateID, ItemID: "pear"}); err != nil {
+		t.Errorf("Expected an item within the daily quota to be added, got %v", err)
+	}
+
+	if _, err := commands.Dispatch(alice, &CheckoutCartCommand{CartID: first.AggregateID}); err != nil {
+		t.Fatalf("Error checking out: %v", err)
+	}
+	if _, err := commands.Dispatch(alice, &CreateCartCommand{}); err != nil {
+		t.Errorf("Expected a new cart once one is checked out, got %v", err)
+	}
+}
+
+func TestItemCountIs_AlmostFullRule(t *testing.T) {
+	store := common.NewEventStore()
+	commands := bus.NewCommandBus()
+	RegisterCommands(commands, store)
+	var almostFull []string
+	engine, err := rules.NewEngine("cart-automations", store, commands)
+	if err != nil {
+		t.Fatalf("Error creating engine: %v", err)
+	}
+	engine.Add("almost-full", rules.When(EventTypeItemAdded).
+		If(ItemCountIs(store, MaxItems-1)).
+		Then(func(_ context.Context, event *common.Event) ([]common.Command, error) {
+			almostFull = append(almostFull, event.AggregateID)
+			return nil, nil
+		}))
+
+	ctx := context.Background()
+	created, _ := commands.Dispatch(ctx, &CreateCartCommand{})
+	for _, item := range []string{"apple", "pear", "plum"} {
+		commands.Dispatch(ctx, &AddItemCommand{CartID: created.AggregateID, ItemID: item})
+	}
+	// Removing an item brings the count back, but the rule judges each event as of
+	// its own version
+	commands.Dispatch(ctx, &RemoveItemCommand{CartID: created.AggregateID, ItemID: "plum"})
+
+	if _, err := engine.Process(ctx); err != nil {
+		t.Fatalf("Error processing: %v", err)
+	}
+	if len(almostFull) != 1 || almostFull[0] != created.AggregateID {
+		t.Errorf("Expected the rule to fire once for the cart, got %v", almostFull)
+	}
+}
+
+func TestAddNotificationRules(t *testing.T) {
+	store := common.NewEventStore()
+	email, sms := notifications.NewFake(), notifications.NewFake()
+	commands := bus.NewCommandBus(bus.ActorMetadata())
+	RegisterCommands(commands, store)
+	notifications.RegisterCommands(commands, store, notifications.Channels{notifications.ChannelEmail: email, notifications.ChannelSMS: sms}, NotificationTemplates())
+	engine, _ := rules.NewEngine("cart-notifications", store, commands)
+	err := AddNotificationRules(engine, store, func(_ context.Context, customer string) ([]notifications.Recipient, error) {
+		switch customer {
+		case "alice":
+			return []notifications.Recipient{{Channel: notifications.ChannelEmail, To: "alice@example.com"}}, nil
+		case "bob":
+			return []notifications.Recipient{{Channel: notifications.ChannelSMS, To: "+15550100"}}, nil
+		}
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("Error adding rules: %v", err)
+	}
+
+	alice := bus.WithPrincipal(context.Background(), bus.Principal{ID: "alice"})
+	bob := bus.WithPrincipal(context.Background(), bus.Principal{ID: "bob"})
+	checkedOut, _ := commands.Dispatch(alice, &AddItemCommand{ItemID: "apple"})
+	commands.Dispatch(alice, &AddItemCommand{CartID: checkedOut.AggregateID, ItemID: "pear"})
+	commands.Dispatch(alice, &CheckoutCartCommand{CartID: checkedOut.AggregateID})
+	abandoned, _ := commands.Dispatch(bob, &AddItemCommand{ItemID: "plum"})
+	commands.Dispatch(context.Background(), &ExpireCartCommand{CartID: abandoned.AggregateID})
+	anonymous, _ := commands.Dispatch(context.Background(), &AddItemCommand{ItemID: "fig"})
+	commands.Dispatch(context.Background(), &CheckoutCartCommand{CartID: anonymous.AggregateID})
+
+	if _, err := engine.Process(context.Background()); err != nil {
+		t.Fatalf("Error processing: %v", err)
+	}
+	sent := email.Sent()
+	if len(sent) != 1 || sent[0].To != "alice@example.com" || sent[0].Subject != "Thanks for your order" ||
+		sent[0].Body != "Your cart "+checkedOut.AggregateID+" with 2 item(s) is checked out." {
+		t.Errorf("Unexpected emails: %+v", sent)
+	}
+	if texts := sms.Sent(); len(texts) != 1 || texts[0].Body != "Your cart with 1 item(s) expired before checkout." {
+		t.Errorf("Unexpected SMS: %+v", texts)
+	}
+}
+
+func TestQuantityAwareAddFlag(t *testing.T) {
+	store := common.NewEventStore()
+	flags := common.NewStaticFeatureFlags()
+	flags.Enable(FlagQuantityAwareAdd, "acme")
+	tenant := func(ctx context.Context, _ common.Command) string {
+		principal, _ := bus.PrincipalFrom(ctx)
+		return principal.ID
+	}
+	commands := bus.NewCommandBus(bus.Validation(), bus.FeatureFlags(flags, tenant, FeatureFlags...))
+	RegisterCommands(commands, store)
+	acme := bus.WithPrincipal(context.Background(), bus.Principal{ID: "acme"})
+	globex := bus.WithPrincipal(context.Background(), bus.Principal{ID: "globex"})
+
+	added, err := commands.Dispatch(acme, &AddItemCommand{ItemID: "apple", Quantity: 2})
+	if err != nil {
+		t.Fatalf("Error adding items: %v", err)
+	}
+	cart := NewCartAggregate(store)
+	cart.Hydrate(added.AggregateID)
+	if cart.Items()["apple"] != 2 || cart.Version() != 3 {
+		t.Errorf("Expected 2 apples added under the flag, got %v at version %d", cart.Items(), cart.Version())
+	}
+	if flags := common.ActiveFeaturesOf(added); len(flags) != 1 || flags[0] != FlagQuantityAwareAdd {
+		t.Errorf("Expected the flag recorded on the event, got %v", added.Metadata)
+	}
+	var limit *CartItemLimitExceededError
+	if _, err := commands.Dispatch(acme, &AddItemCommand{CartID: added.AggregateID, ItemID: "pear", Quantity: 2}); !errors.As(err, &limit) || limit.Adding != 2 {
+		t.Errorf("Expected the quantity to be checked against the limit, got %v", err)
+	}
+
+	// Without the flag the quantity is ignored
+	other, err := commands.Dispatch(globex, &AddItemCommand{ItemID: "apple", Quantity: 2})
+	if err != nil {
+		t.Fatalf("Error adding item: %v", err)
+	}
+	cart = NewCartAggregate(store)
+	cart.Hydrate(other.AggregateID)
+	if cart.Items()["apple"] != 1 || len(common.ActiveFeaturesOf(other)) != 0 {
+		t.Errorf("Expected a single apple without flags, got %v (%v)", cart.Items(), other.Metadata)
+	}
+	if _, err := commands.Dispatch(globex, &AddItemCommand{ItemID: "apple", Quantity: -1}); !errors.As(err, new(*common.ValidationError)) {
+		t.Errorf("Expected a negative quantity to be rejected, got %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package cart
 
 import (
+	"errors"
 	"simple-event-modeling/common"
 	"testing"
 )
@@ -29,13 +30,13 @@ func BenchmarkCartAggregate_AddItem(b *testing.B) {
 		}
 
 		addCmd := &AddItemCommand{
-			AggregateID: createEvent.AggregateID,
-			ItemID:      "item-1",
+			CartID: createEvent.AggregateID,
+			ItemID: "item-1",
 		}
 		_, err := cart.Handle(addCmd)
 		if err != nil {
 			// Skip if we hit the limit
-			if _, ok := err.(*common.InvalidCommandError); !ok {
+			if !errors.Is(err, common.ErrInvalidCommand) {
 				b.Fatalf("Unexpected error: %v", err)
 			}
 		}
@@ -66,8 +67,8 @@ func BenchmarkCartAggregate_EventReplay(b *testing.B) {
 		}
 
 		addCmd := &AddItemCommand{
-			AggregateID: createEvent.AggregateID,
-			ItemID:      "item-1",
+			CartID: createEvent.AggregateID,
+			ItemID: "item-1",
 		}
 		cart.Handle(addCmd)
 	}
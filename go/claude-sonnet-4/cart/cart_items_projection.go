@@ -0,0 +1,173 @@
+// Package cart provides the continuous cart items projection over the global event log.
+package cart
+
+import (
+	"sort"
+
+	"simple-event-modeling/common"
+)
+
+// CartItemsProjectionName is the name the projection is registered under
+const CartItemsProjectionName = "cart-items"
+
+// CartItemsProjection maintains a CartProjection for every cart in the store.
+// It applies the same folding rules as CartItemsQuery, but is fed from the global
+// event log so it can be rebuilt for all carts at once. Items are priced from the
+// ItemPriceChanged events relayed from the catalog. Soft-deleted carts are left out
+// until they are restored.
+type CartItemsProjection struct {
+	carts  map[string]*CartItemsQuery
+	prices map[string]float64
+	tax    TaxCalculator
+}
+
+// NewCartItemsProjection creates an empty cart items projection
+func NewCartItemsProjection() *CartItemsProjection {
+	return &CartItemsProjection{
+		carts:  make(map[string]*CartItemsQuery),
+		prices: make(map[string]float64),
+	}
+}
+
+// Name returns the registered name of the projection
+func (p *CartItemsProjection) Name() string {
+	return CartItemsProjectionName
+}
+
+// Consumes returns the event types the projection folds
+func (p *CartItemsProjection) Consumes() []string {
+	return []string{
+		EventTypeCartCreated, EventTypeItemAdded, EventTypeItemRemoved, EventTypeItemAnnotated,
+		EventTypeCartRenamed, EventTypeCartAttributeSet, EventTypeShippingOptionSelected,
+		EventTypeGiftCardApplied, EventTypeGiftCardRemoved, EventTypeCartCleared, EventTypeCartDeleted,
+		EventTypeCartRestored, EventTypeItemPriceChanged,
+	}
+}
+
+// On applies a cart event to the projection of its cart; other events are ignored
+func (p *CartItemsProjection) On(event *common.Event) error {
+	switch event.Type {
+	case EventTypeCartCreated, EventTypeItemAdded, EventTypeItemRemoved, EventTypeItemAnnotated,
+		EventTypeCartRenamed, EventTypeCartAttributeSet, EventTypeShippingOptionSelected,
+		EventTypeGiftCardApplied, EventTypeGiftCardRemoved, EventTypeCartCleared, EventTypeCartDeleted,
+		EventTypeCartRestored:
+	case EventTypeItemPriceChanged:
+		return p.onItemPriceChanged(event)
+	default:
+		return nil
+	}
+
+	query, exists := p.carts[event.AggregateID]
+	if !exists {
+		query = NewCartItemsQuery(event.AggregateID, nil)
+		query.Tax = p.tax
+		p.carts[event.AggregateID] = query
+	}
+	if err := query.On(event); err != nil {
+		return err
+	}
+	p.price(query)
+	return nil
+}
+
+// onItemPriceChanged reprices the item in every cart holding it
+func (p *CartItemsProjection) onItemPriceChanged(event *common.Event) error {
+	changed, err := common.AsTyped[PriceData](event)
+	if err != nil {
+		return err
+	}
+	p.prices[changed.Data.Item] = changed.Data.Price
+	for _, query := range p.carts {
+		for _, view := range query.Projection.Items {
+			if view.Item == changed.Data.Item || view.Options[SKUOption] == changed.Data.Item {
+				p.price(query)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// price sets the known prices on a cart's items and recomputes its totals. A
+// variant with a SKU option is priced by its SKU when the catalog has a price for
+// it, and by its product otherwise.
+func (p *CartItemsProjection) price(query *CartItemsQuery) {
+	for _, view := range query.Projection.Items {
+		if price, known := p.prices[view.Options[SKUOption]]; known && view.Options[SKUOption] != "" {
+			view.Price = price
+		} else if price, known := p.prices[view.Item]; known {
+			view.Price = price
+		}
+	}
+	query.computeTotals()
+}
+
+// SetTaxCalculator sets the calculator that taxes every cart's totals and
+// recomputes the totals of the carts already projected
+func (p *CartItemsProjection) SetTaxCalculator(tax TaxCalculator) {
+	p.tax = tax
+	for _, query := range p.carts {
+		query.Tax = tax
+		query.computeTotals()
+	}
+}
+
+// Cart returns the projection for a single cart, unless it is deleted
+func (p *CartItemsProjection) Cart(cartID string) (*CartProjection, bool) {
+	query, exists := p.carts[cartID]
+	if !exists || query.Projection.Deleted {
+		return nil, false
+	}
+	return query.Projection, true
+}
+
+// FindByAttribute returns the carts, except deleted ones, whose attribute key has
+// value, ordered by cart ID. An empty value matches every cart with the attribute.
+func (p *CartItemsProjection) FindByAttribute(key, value string) []*CartProjection {
+	found := make([]*CartProjection, 0)
+	for _, query := range p.carts {
+		cart := query.Projection
+		if actual, set := cart.Attributes[key]; set && !cart.Deleted && (value == "" || actual == value) {
+			found = append(found, cart)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].CartID < found[j].CartID
+	})
+	return found
+}
+
+// State returns the projections of all carts that are not deleted, keyed by cart ID
+func (p *CartItemsProjection) State() interface{} {
+	state := make(map[string]*CartProjection, len(p.carts))
+	for cartID, query := range p.carts {
+		if !query.Projection.Deleted {
+			state[cartID] = query.Projection
+		}
+	}
+	return state
+}
+
+// FindCartsByAttributeQuery asks for the carts labelled with an attribute
+type FindCartsByAttributeQuery struct {
+	Key string
+	// Value is the attribute value to match; empty matches any value
+	Value string
+	Store common.Store
+}
+
+// NewFindCartsByAttributeQuery creates a query for the carts whose attribute key has
+// value
+func NewFindCartsByAttributeQuery(key, value string, store common.Store) *FindCartsByAttributeQuery {
+	return &FindCartsByAttributeQuery{Key: key, Value: value, Store: store}
+}
+
+// Execute replays the global event log into a fresh cart items projection and
+// returns the matching carts
+func (q *FindCartsByAttributeQuery) Execute() ([]*CartProjection, error) {
+	projection := NewCartItemsProjection()
+	if _, err := common.ReplayProjection(q.Store, projection, 0, nil); err != nil {
+		return nil, err
+	}
+	return projection.FindByAttribute(q.Key, q.Value), nil
+}
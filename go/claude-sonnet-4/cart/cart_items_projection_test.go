@@ -0,0 +1,244 @@
+package cart
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+	"simple-event-modeling/inbound"
+	"testing"
+)
+
+func TestCartItemsProjection_Replay(t *testing.T) {
+	store := common.NewEventStore()
+	first := NewCartAggregate(store)
+	createFirst, _ := first.Handle(&CreateCartCommand{})
+	first.Handle(&AddItemCommand{CartID: createFirst.AggregateID, ItemID: "apple"})
+	first.Handle(&AddItemCommand{CartID: createFirst.AggregateID, ItemID: "apple"})
+
+	second := NewCartAggregate(store)
+	createSecond, _ := second.Handle(&CreateCartCommand{})
+	second.Handle(&AddItemCommand{CartID: createSecond.AggregateID, ItemID: "pear"})
+	second.Handle(&ClearCartCommand{CartID: createSecond.AggregateID})
+
+	projection, err := common.DefaultRegistry.NewProjection(CartItemsProjectionName)
+	if err != nil {
+		t.Fatalf("Error creating projection: %v", err)
+	}
+	checkpoint, err := common.ReplayProjection(store, projection, 0, nil)
+	if err != nil {
+		t.Fatalf("Error replaying projection: %v", err)
+	}
+	if checkpoint.Position != len(store.GetAllEvents()) {
+		t.Errorf("Expected checkpoint at %d, got %d", len(store.GetAllEvents()), checkpoint.Position)
+	}
+
+	carts := projection.(*CartItemsProjection)
+	firstCart, ok := carts.Cart(createFirst.AggregateID)
+	if !ok {
+		t.Fatal("Expected projection for first cart")
+	}
+	if firstCart.Items["apple"].Quantity != 2 || firstCart.Totals.ItemCount != 2 {
+		t.Errorf("Unexpected first cart projection: %+v", firstCart)
+	}
+
+	secondCart, ok := carts.Cart(createSecond.AggregateID)
+	if !ok {
+		t.Fatal("Expected projection for second cart")
+	}
+	if len(secondCart.Items) != 0 || secondCart.Totals.ItemCount != 0 {
+		t.Errorf("Expected second cart to be empty, got %+v", secondCart)
+	}
+}
+
+func TestCartItemsProjection_ExcludesDeletedCarts(t *testing.T) {
+	store := common.NewEventStore()
+	created, _ := NewCartAggregate(store).Handle(&CreateCartCommand{})
+	cartID := created.AggregateID
+	NewCartAggregate(store).Handle(&AddItemCommand{CartID: cartID, ItemID: "apple"})
+	NewCartAggregate(store).Handle(&DeleteCartCommand{CartID: cartID})
+
+	projection := NewCartItemsProjection()
+	if _, err := common.ReplayProjection(store, projection, 0, nil); err != nil {
+		t.Fatalf("Error replaying projection: %v", err)
+	}
+	if _, ok := projection.Cart(cartID); ok {
+		t.Error("Expected the deleted cart left out")
+	}
+	if state := projection.State().(map[string]*CartProjection); len(state) != 0 {
+		t.Errorf("Expected no carts in the summary, got %v", state)
+	}
+
+	NewCartAggregate(store).Handle(&RestoreCartCommand{CartID: cartID})
+	common.ReplayProjection(store, projection, len(store.GetAllEvents())-1, nil)
+	if cart, ok := projection.Cart(cartID); !ok || cart.Items["apple"].Quantity != 1 {
+		t.Errorf("Expected the restored cart back with its items, got %+v", cart)
+	}
+}
+
+func TestFindCartsByAttributeQuery(t *testing.T) {
+	store := common.NewEventStore()
+	commands := bus.NewCommandBus(bus.Validation())
+	RegisterCommands(commands, store)
+	ctx := context.Background()
+	create := func() string {
+		created, _ := commands.Dispatch(ctx, &CreateCartCommand{})
+		return created.AggregateID
+	}
+
+	party, work, gone := create(), create(), create()
+	commands.Dispatch(ctx, &RenameCartCommand{CartID: party, Name: "Birthday party"})
+	commands.Dispatch(ctx, &SetCartAttributeCommand{CartID: party, Key: "occasion", Value: "birthday"})
+	commands.Dispatch(ctx, &SetCartAttributeCommand{CartID: party, Key: "channel", Value: "mobile"})
+	commands.Dispatch(ctx, &SetCartAttributeCommand{CartID: work, Key: "occasion", Value: "office"})
+	commands.Dispatch(ctx, &SetCartAttributeCommand{CartID: work, Key: "channel", Value: "web"})
+	commands.Dispatch(ctx, &SetCartAttributeCommand{CartID: work, Key: "channel"})
+	commands.Dispatch(ctx, &SetCartAttributeCommand{CartID: gone, Key: "occasion", Value: "birthday"})
+	commands.Dispatch(ctx, &DeleteCartCommand{CartID: gone})
+	if _, err := commands.Dispatch(ctx, &RenameCartCommand{CartID: work}); !errors.Is(err, common.ErrValidation) {
+		t.Errorf("Expected an unnamed rename rejected, got %v", err)
+	}
+
+	found, err := NewFindCartsByAttributeQuery("occasion", "birthday", store).Execute()
+	if err != nil {
+		t.Fatalf("Error executing query: %v", err)
+	}
+	if len(found) != 1 || found[0].CartID != party || found[0].Name != "Birthday party" || found[0].Attributes["channel"] != "mobile" {
+		t.Errorf("Expected only the named party cart, got %+v", found)
+	}
+	if found, _ := NewFindCartsByAttributeQuery("occasion", "", store).Execute(); len(found) != 2 {
+		t.Errorf("Expected every open cart with an occasion, got %+v", found)
+	}
+	if found, _ := NewFindCartsByAttributeQuery("channel", "web", store).Execute(); len(found) != 0 {
+		t.Errorf("Expected the removed attribute not to match, got %+v", found)
+	}
+}
+
+func TestCartItemsProjection_PricesVariants(t *testing.T) {
+	store := common.NewEventStore()
+	cart := NewCartAggregate(store)
+	created, _ := cart.Handle(&CreateCartCommand{})
+	cartID := created.AggregateID
+	cart.Handle(&AddItemCommand{CartID: cartID, ItemID: "shirt", Options: map[string]string{"size": "M"}})
+	cart.Handle(&AddItemCommand{CartID: cartID, ItemID: "shirt", Options: map[string]string{"size": "XL", SKUOption: "shirt-xl"}})
+	store.Append(NewItemPriceChangedEvent(1, "shirt", 10))
+	store.Append(NewItemPriceChangedEvent(2, "shirt-xl", 12))
+
+	projection := NewCartItemsProjection()
+	if _, err := common.ReplayProjection(store, projection, 0, nil); err != nil {
+		t.Fatalf("Error replaying projection: %v", err)
+	}
+	view, _ := projection.Cart(cartID)
+	medium := view.Items["shirt[size=M]"]
+	xl := view.Items["shirt[size=XL,sku=shirt-xl]"]
+	if medium == nil || medium.Item != "shirt" || medium.Options["size"] != "M" || medium.Price != 10 {
+		t.Errorf("Expected the medium shirt priced by product, got %+v", medium)
+	}
+	if xl == nil || xl.Price != 12 {
+		t.Errorf("Expected the XL shirt priced by its SKU, got %+v", xl)
+	}
+	if view.Totals.ItemCount != 2 || view.Totals.TotalAmount != 22 {
+		t.Errorf("Expected totals over both lines, got %+v", view.Totals)
+	}
+}
+
+func TestCartItemsProjection_ItemAnnotations(t *testing.T) {
+	store := common.NewEventStore()
+	cart := NewCartAggregate(store)
+	created, _ := cart.Handle(&CreateCartCommand{})
+	cartID := created.AggregateID
+	large := map[string]string{"size": "L"}
+	cart.Handle(&AddItemCommand{CartID: cartID, ItemID: "shirt", Options: large})
+	cart.Handle(&AddItemCommand{CartID: cartID, ItemID: "mug"})
+
+	if _, err := cart.Handle(&AnnotateItemCommand{CartID: cartID, ItemID: "shirt", Options: large, Note: "Happy birthday!", Gift: true}); err != nil {
+		t.Fatalf("Error annotating item: %v", err)
+	}
+	_, err := cart.Handle(&AnnotateItemCommand{CartID: cartID, ItemID: "shirt", Note: "wrong line"})
+	if !errors.As(err, new(*ItemNotInCartError)) {
+		t.Errorf("Expected ItemNotInCartError for a line not in the cart, got %v", err)
+	}
+
+	projection := NewCartItemsProjection()
+	if _, err := common.ReplayProjection(store, projection, 0, nil); err != nil {
+		t.Fatalf("Error replaying projection: %v", err)
+	}
+	view, _ := projection.Cart(cartID)
+	if shirt := view.Items["shirt[size=L]"]; shirt.Note != "Happy birthday!" || !shirt.Gift {
+		t.Errorf("Expected the annotation on the shirt line, got %+v", shirt)
+	}
+	if mug := view.Items["mug"]; mug.Note != "" || mug.Gift {
+		t.Errorf("Expected the mug line unannotated, got %+v", mug)
+	}
+
+	// Removing the line's last unit drops its annotation with it
+	cart.Handle(&RemoveItemCommand{CartID: cartID, ItemID: "shirt", Options: large})
+	cart.Handle(&AddItemCommand{CartID: cartID, ItemID: "shirt", Options: large})
+	query, err := NewCartItemsQuery(cartID, store).Execute()
+	if err != nil {
+		t.Fatalf("Error executing query: %v", err)
+	}
+	if shirt := query.Items["shirt[size=L]"]; shirt.Note != "" || shirt.Gift {
+		t.Errorf("Expected a re-added line without the old annotation, got %+v", shirt)
+	}
+}
+
+func TestCartItemsProjection_Inline(t *testing.T) {
+	store := common.NewEventStore()
+	inline := common.NewInlineProjections(NewCartItemsProjection())
+
+	uow := common.NewUnitOfWork(store, inline)
+	cart := NewCartAggregate(uow)
+	created, _ := cart.Handle(&CreateCartCommand{})
+	if _, err := cart.Handle(&AddItemCommand{CartID: created.AggregateID, ItemID: "apple"}); err != nil {
+		t.Fatalf("Error adding item: %v", err)
+	}
+	if err := uow.Commit(); err != nil {
+		t.Fatalf("Error committing: %v", err)
+	}
+
+	inline.View(CartItemsProjectionName, func(p common.Projection) {
+		view, exists := p.(*CartItemsProjection).Cart(created.AggregateID)
+		if !exists || view.Items["apple"].Quantity != 1 {
+			t.Errorf("Expected the committed item in the read model, got %+v", view)
+		}
+	})
+}
+
+func TestCartItemsProjection_PricesFromCatalog(t *testing.T) {
+	store := common.NewEventStore()
+	consumer := inbound.NewConsumer(store, bus.NewCommandBus())
+	RegisterInbound(consumer)
+	priceChanged := func(id, data string) inbound.Outcome {
+		outcome, err := consumer.Handle(context.Background(), inbound.Message{
+			ID: id, Source: "catalog", Type: MessageTypeCatalogPriceChanged, Data: json.RawMessage(data),
+		})
+		if err != nil {
+			t.Fatalf("Error handling %s: %v", id, err)
+		}
+		return outcome
+	}
+
+	cartID := "8b0e7e0a-3c55-4b52-9c1e-6f3b1e2d4a10"
+	store.Append(NewCartCreatedEvent(cartID))
+	store.Append(NewItemAddedEvent(cartID, 2, "apple"))
+	priceChanged("p-1", `{"sku": "apple", "unit_price_cents": 250}`)
+	store.Append(NewItemAddedEvent(cartID, 3, "apple"))
+	priceChanged("p-2", `{"sku": "apple", "unit_price_cents": 200}`)
+	if outcome := priceChanged("p-3", `{"sku": "pear"}`); outcome != inbound.Rejected {
+		t.Errorf("Expected a price change without a price to be rejected, got %s", outcome)
+	}
+
+	projection := NewCartItemsProjection()
+	if _, err := common.ReplayProjection(store, projection, 0, nil); err != nil {
+		t.Fatalf("Error replaying projection: %v", err)
+	}
+	cart, _ := projection.Cart(cartID)
+	if apple := cart.Items["apple"]; apple.Price != 2 || apple.Total != 4 || cart.Totals.TotalAmount != 4 {
+		t.Errorf("Expected two apples at the latest price of 2.00, got %+v %+v", apple, cart.Totals)
+	}
+	if events, _ := store.GetStream(CatalogPricesStream); len(events) != 2 {
+		t.Errorf("Expected 2 price changes recorded, got %d", len(events))
+	}
+}
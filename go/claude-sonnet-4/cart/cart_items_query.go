@@ -2,7 +2,11 @@
 // Queries implement the read side of CQRS, creating projections optimized for specific read scenarios.
 package cart
 
-import "simple-event-modeling/common"
+import (
+	"math"
+
+	"simple-event-modeling/common"
+)
 
 // CartItemsQuery represents a query for projecting cart state from events.
 // This demonstrates the Query side of CQRS (Command Query Responsibility Segregation).
@@ -10,24 +14,43 @@ import "simple-event-modeling/common"
 // optimized for specific read scenarios.
 type CartItemsQuery struct {
 	AggregateID string
-	Store       *common.EventStore
+	Store       common.Store
 	Projection  *CartProjection
+	// Tax works out TaxAmount in the totals; nil leaves carts untaxed
+	Tax TaxCalculator
 }
 
 // CartProjection represents a read model projection of cart state.
 // This can differ from the aggregate's internal representation to optimize for queries.
 type CartProjection struct {
-	CartID string                   `json:"cart_id"`
-	Items  map[string]*CartItemView `json:"items"`
-	Totals *CartTotals              `json:"totals"`
+	CartID string `json:"cart_id"`
+	// Name and Attributes are set by CartRenamed and CartAttributeSet
+	Name       string            `json:"name,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// Region is the cart's tax region (see RegionKey)
+	Region string `json:"region,omitempty"`
+	// ShippingOption is set by ShippingOptionSelected
+	ShippingOption string `json:"shipping_option,omitempty"`
+	// GiftCards are the amounts paid with gift cards, keyed by card ID
+	GiftCards map[string]float64       `json:"gift_cards,omitempty"`
+	Items     map[string]*CartItemView `json:"items"`
+	Totals    *CartTotals              `json:"totals"`
+	// Deleted is set while the cart is soft-deleted
+	Deleted bool `json:"deleted,omitempty"`
 }
 
 // CartItemView represents an item in the cart projection.
 // This view model can include additional computed fields for display.
 type CartItemView struct {
-	Quantity int     `json:"quantity"`
-	Price    float64 `json:"price,omitempty"` // Could be enriched from product service
-	Total    float64 `json:"total,omitempty"` // Computed field
+	// Item is the product ID; Items is keyed by line (see LineKey)
+	Item     string            `json:"item"`
+	Options  map[string]string `json:"options,omitempty"`
+	Quantity int               `json:"quantity"`
+	Price    float64           `json:"price,omitempty"` // Could be enriched from product service
+	Total    float64           `json:"total,omitempty"` // Computed field
+	// Note and Gift are set by ItemAnnotated
+	Note string `json:"note,omitempty"`
+	Gift bool   `json:"gift,omitempty"`
 }
 
 // CartTotals represents computed totals for the cart.
@@ -35,11 +58,15 @@ type CartTotals struct {
 	ItemCount   int     `json:"item_count"`
 	TotalAmount float64 `json:"total_amount"`
 	TaxAmount   float64 `json:"tax_amount,omitempty"`
-	GrandTotal  float64 `json:"grand_total,omitempty"`
+	// ShippingAmount is the cost of the selected shipping option, which is not taxed
+	ShippingAmount float64 `json:"shipping_amount,omitempty"`
+	// GiftCardAmount is paid with gift cards and deducted from GrandTotal
+	GiftCardAmount float64 `json:"gift_card_amount,omitempty"`
+	GrandTotal     float64 `json:"grand_total,omitempty"`
 }
 
 // NewCartItemsQuery creates a new query for projecting cart state.
-func NewCartItemsQuery(aggregateID string, store *common.EventStore) *CartItemsQuery {
+func NewCartItemsQuery(aggregateID string, store common.Store) *CartItemsQuery {
 	return &CartItemsQuery{
 		AggregateID: aggregateID,
 		Store:       store,
@@ -70,6 +97,19 @@ func (q *CartItemsQuery) Execute() (*CartProjection, error) {
 	return q.Projection, nil
 }
 
+// ExecuteAtLeast runs the query, failing with a *common.StaleReadError when the
+// store has not yet got the write named by token, e.g. a replica lagging behind the
+// primary the command was handled by. Tokens of other streams are ignored: the
+// query replays this cart's stream, which no other write affects.
+func (q *CartItemsQuery) ExecuteAtLeast(token common.ConsistencyToken) (*CartProjection, error) {
+	if token.StreamID == q.AggregateID {
+		if version := q.Store.GetStreamVersion(q.AggregateID); version < token.Version {
+			return nil, &common.StaleReadError{Token: token, Version: version}
+		}
+	}
+	return q.Execute()
+}
+
 // On applies events to build the projection.
 // Note: This is similar to aggregate.On() but builds a different view of the data.
 func (q *CartItemsQuery) On(event *common.Event) error {
@@ -82,6 +122,22 @@ func (q *CartItemsQuery) On(event *common.Event) error {
 		return q.onItemRemoved(event)
 	case EventTypeCartCleared:
 		return q.onCartCleared(event)
+	case EventTypeItemAnnotated:
+		return q.onItemAnnotated(event)
+	case EventTypeCartRenamed:
+		return q.onCartRenamed(event)
+	case EventTypeCartAttributeSet:
+		return q.onCartAttributeSet(event)
+	case EventTypeShippingOptionSelected:
+		return q.onShippingOptionSelected(event)
+	case EventTypeGiftCardApplied, EventTypeGiftCardRemoved:
+		return q.onGiftCardChanged(event)
+	case EventTypeCartDeleted:
+		q.Projection.Deleted = true
+		return nil
+	case EventTypeCartRestored:
+		q.Projection.Deleted = false
+		return nil
 	default:
 		// Queries can choose to ignore unknown events
 		return nil
@@ -92,38 +148,117 @@ func (q *CartItemsQuery) On(event *common.Event) error {
 
 func (q *CartItemsQuery) onCartCreated(event *common.Event) error {
 	q.Projection.CartID = event.AggregateID
+	q.Projection.Region, _ = event.Metadata[RegionKey].(string)
 	q.Projection.Items = make(map[string]*CartItemView)
 	q.Projection.Totals = &CartTotals{}
 	return nil
 }
 
 func (q *CartItemsQuery) onItemAdded(event *common.Event) error {
-	if item, ok := event.Data["item"].(string); ok {
-		if q.Projection.Items[item] == nil {
-			q.Projection.Items[item] = &CartItemView{
+	added, err := common.AsTyped[ItemData](event)
+	if err != nil {
+		return err
+	}
+	if item := added.Data.Item; item != "" {
+		line := added.Data.Line()
+		if q.Projection.Items[line] == nil {
+			q.Projection.Items[line] = &CartItemView{
+				Item:     item,
+				Options:  added.Data.Options,
 				Quantity: 0,
 				Price:    0.0, // Could be enriched from product catalog
 			}
 		}
-		q.Projection.Items[item].Quantity++
+		q.Projection.Items[line].Quantity++
 	}
 	return nil
 }
 
 func (q *CartItemsQuery) onItemRemoved(event *common.Event) error {
-	if item, ok := event.Data["item"].(string); ok {
-		if itemView, exists := q.Projection.Items[item]; exists {
+	removed, err := common.AsTyped[ItemData](event)
+	if err != nil {
+		return err
+	}
+	if removed.Data.Item != "" {
+		line := removed.Data.Line()
+		if itemView, exists := q.Projection.Items[line]; exists {
 			itemView.Quantity--
 			if itemView.Quantity <= 0 {
-				delete(q.Projection.Items, item)
+				delete(q.Projection.Items, line)
 			}
 		}
 	}
 	return nil
 }
 
+func (q *CartItemsQuery) onItemAnnotated(event *common.Event) error {
+	annotated, err := common.AsTyped[AnnotationData](event)
+	if err != nil {
+		return err
+	}
+	if itemView, exists := q.Projection.Items[annotated.Data.Line()]; exists {
+		itemView.Note = annotated.Data.Note
+		itemView.Gift = annotated.Data.Gift
+	}
+	return nil
+}
+
+func (q *CartItemsQuery) onCartRenamed(event *common.Event) error {
+	renamed, err := common.AsTyped[NameData](event)
+	if err != nil {
+		return err
+	}
+	q.Projection.Name = renamed.Data.Name
+	return nil
+}
+
+func (q *CartItemsQuery) onCartAttributeSet(event *common.Event) error {
+	set, err := common.AsTyped[AttributeData](event)
+	if err != nil {
+		return err
+	}
+	if set.Data.Value == "" {
+		delete(q.Projection.Attributes, set.Data.Key)
+		return nil
+	}
+	if q.Projection.Attributes == nil {
+		q.Projection.Attributes = make(map[string]string)
+	}
+	q.Projection.Attributes[set.Data.Key] = set.Data.Value
+	return nil
+}
+
+func (q *CartItemsQuery) onShippingOptionSelected(event *common.Event) error {
+	selected, err := common.AsTyped[ShippingData](event)
+	if err != nil {
+		return err
+	}
+	q.Projection.ShippingOption = selected.Data.Option
+	q.Projection.Totals.ShippingAmount = selected.Data.Cost
+	return nil
+}
+
+func (q *CartItemsQuery) onGiftCardChanged(event *common.Event) error {
+	changed, err := common.AsTyped[GiftCardData](event)
+	if err != nil {
+		return err
+	}
+	if event.Type == EventTypeGiftCardRemoved {
+		delete(q.Projection.GiftCards, changed.Data.GiftCardID)
+		return nil
+	}
+	if q.Projection.GiftCards == nil {
+		q.Projection.GiftCards = make(map[string]float64)
+	}
+	q.Projection.GiftCards[changed.Data.GiftCardID] = changed.Data.Amount
+	return nil
+}
+
 func (q *CartItemsQuery) onCartCleared(event *common.Event) error {
 	q.Projection.Items = make(map[string]*CartItemView)
+	// The shipping quote was for the items cleared
+	q.Projection.ShippingOption = ""
+	q.Projection.Totals.ShippingAmount = 0
 	return nil
 }
 
@@ -141,6 +276,15 @@ func (q *CartItemsQuery) computeTotals() {
 
 	q.Projection.Totals.ItemCount = itemCount
 	q.Projection.Totals.TotalAmount = totalAmount
-	// Could add tax calculation, discounts, etc.
-	q.Projection.Totals.GrandTotal = totalAmount
+	q.Projection.Totals.TaxAmount = 0
+	if q.Tax != nil {
+		q.Projection.Totals.TaxAmount = q.Tax.Tax(q.Projection.Region, totalAmount)
+	}
+	giftCardAmount := 0.0
+	for _, amount := range q.Projection.GiftCards {
+		giftCardAmount += amount
+	}
+	q.Projection.Totals.GiftCardAmount = giftCardAmount
+	// Gift cards pay at most the whole cart
+	q.Projection.Totals.GrandTotal = math.Max(0, roundCents(totalAmount+q.Projection.Totals.TaxAmount+q.Projection.Totals.ShippingAmount-giftCardAmount))
 }
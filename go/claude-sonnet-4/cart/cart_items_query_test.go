@@ -18,19 +18,19 @@ func TestCartItemsQuery_Execute(t *testing.T) {
 	cartID := createEvent.AggregateID
 
 	// Add multiple items
-	addCmd1 := &AddItemCommand{AggregateID: cartID, ItemID: "apple"}
+	addCmd1 := &AddItemCommand{CartID: cartID, ItemID: "apple"}
 	_, err = cart.Handle(addCmd1)
 	if err != nil {
 		t.Fatalf("Error adding apple: %v", err)
 	}
 
-	addCmd2 := &AddItemCommand{AggregateID: cartID, ItemID: "banana"}
+	addCmd2 := &AddItemCommand{CartID: cartID, ItemID: "banana"}
 	_, err = cart.Handle(addCmd2)
 	if err != nil {
 		t.Fatalf("Error adding banana: %v", err)
 	}
 
-	addCmd3 := &AddItemCommand{AggregateID: cartID, ItemID: "apple"} // Add another apple
+	addCmd3 := &AddItemCommand{CartID: cartID, ItemID: "apple"} // Add another apple
 	_, err = cart.Handle(addCmd3)
 	if err != nil {
 		t.Fatalf("Error adding second apple: %v", err)
@@ -78,20 +78,20 @@ func TestCartItemsQuery_WithRemovals(t *testing.T) {
 	cartID := createEvent.AggregateID
 
 	// Add items
-	addCmd1 := &AddItemCommand{AggregateID: cartID, ItemID: "apple"}
+	addCmd1 := &AddItemCommand{CartID: cartID, ItemID: "apple"}
 	_, err = cart.Handle(addCmd1)
 	if err != nil {
 		t.Fatalf("Error adding apple: %v", err)
 	}
 
-	addCmd2 := &AddItemCommand{AggregateID: cartID, ItemID: "banana"}
+	addCmd2 := &AddItemCommand{CartID: cartID, ItemID: "banana"}
 	_, err = cart.Handle(addCmd2)
 	if err != nil {
 		t.Fatalf("Error adding banana: %v", err)
 	}
 
 	// Remove item
-	removeCmd := &RemoveItemCommand{AggregateID: cartID, ItemID: "apple"}
+	removeCmd := &RemoveItemCommand{CartID: cartID, ItemID: "apple"}
 	_, err = cart.Handle(removeCmd)
 	if err != nil {
 		t.Fatalf("Error removing apple: %v", err)
@@ -135,14 +135,14 @@ func TestCartItemsQuery_ClearCart(t *testing.T) {
 	cartID := createEvent.AggregateID
 
 	// Add items
-	addCmd := &AddItemCommand{AggregateID: cartID, ItemID: "apple"}
+	addCmd := &AddItemCommand{CartID: cartID, ItemID: "apple"}
 	_, err = cart.Handle(addCmd)
 	if err != nil {
 		t.Fatalf("Error adding apple: %v", err)
 	}
 
 	// Clear cart
-	clearCmd := &ClearCartCommand{AggregateID: cartID}
+	clearCmd := &ClearCartCommand{CartID: cartID}
 	_, err = cart.Handle(clearCmd)
 	if err != nil {
 		t.Fatalf("Error clearing cart: %v", err)
@@ -177,7 +177,7 @@ func TestCartItemsQuery_ComputedFields(t *testing.T) {
 	}
 	cartID := createEvent.AggregateID
 
-	addCmd := &AddItemCommand{AggregateID: cartID, ItemID: "apple"}
+	addCmd := &AddItemCommand{CartID: cartID, ItemID: "apple"}
 	_, err = cart.Handle(addCmd)
 	if err != nil {
 		t.Fatalf("Error adding apple: %v", err)
@@ -1,9 +1,13 @@
 package cart
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"simple-event-modeling/common"
 	"testing"
+
+	"github.com/google/uuid"
 )
 
 func TestCartAggregate_CreateCart(t *testing.T) {
@@ -43,8 +47,8 @@ func TestCartAggregate_AddItem(t *testing.T) {
 
 	// Add item
 	addCmd := &AddItemCommand{
-		AggregateID: createEvent.AggregateID,
-		ItemID:      "item-1",
+		CartID: createEvent.AggregateID,
+		ItemID: "item-1",
 	}
 	event, err := cart.Handle(addCmd)
 
@@ -71,8 +75,8 @@ func TestCartAggregate_AddItemWithoutCart(t *testing.T) {
 
 	// Add item without creating cart first (should auto-create)
 	addCmd := &AddItemCommand{
-		AggregateID: "",
-		ItemID:      "item-1",
+		CartID: "",
+		ItemID: "item-1",
 	}
 	event, err := cart.Handle(addCmd)
 
@@ -91,6 +95,134 @@ func TestCartAggregate_AddItemWithoutCart(t *testing.T) {
 	if items["item-1"] != 1 {
 		t.Errorf("Expected item-1 quantity 1, got %d", items["item-1"])
 	}
+
+	if addCmd.CartID != "" {
+		t.Errorf("Expected the command left as given, got cart ID %q", addCmd.CartID)
+	}
+	if types := eventTypes(store, event.AggregateID); fmt.Sprint(types) != fmt.Sprint([]string{EventTypeCartCreated, EventTypeItemAdded}) {
+		t.Errorf("Expected the cart created and the item added in its stream, got %v", types)
+	}
+}
+
+func TestCartAggregate_AddItemWithoutCartRejected(t *testing.T) {
+	store := common.NewEventStore()
+	cart := NewCartAggregate(store)
+	cart.SetAutoCreatePolicy(Reject)
+
+	_, err := cart.Handle(&AddItemCommand{ItemID: "item-1"})
+	var notCreated *CartNotCreatedError
+	if !errors.As(err, &notCreated) || notCreated.CartID != "" {
+		t.Errorf("Expected CartNotCreatedError without a cart ID, got %v", err)
+	}
+	if events := store.GetAllEvents(); len(events) != 0 {
+		t.Errorf("Expected nothing stored, got %d events", len(events))
+	}
+}
+
+func TestCartAggregate_AddItemToExistingCartFromFreshAggregate(t *testing.T) {
+	store := common.NewEventStore()
+	created, err := NewCartAggregate(store).Handle(&CreateCartCommand{})
+	if err != nil {
+		t.Fatalf("Error creating cart: %v", err)
+	}
+	cartID := created.AggregateID
+	if _, err := NewCartAggregate(store).Handle(&AddItemCommand{CartID: cartID, ItemID: "apple"}); err != nil {
+		t.Fatalf("Error adding item: %v", err)
+	}
+
+	event, err := NewCartAggregate(store).Handle(&AddItemCommand{CartID: cartID, ItemID: "pear"})
+	if err != nil {
+		t.Fatalf("Error adding item: %v", err)
+	}
+	if event.AggregateID != cartID || event.Version != 3 {
+		t.Errorf("Expected the item added to the existing cart at version 3, got %s v%d", event.AggregateID, event.Version)
+	}
+	want := []string{EventTypeCartCreated, EventTypeItemAdded, EventTypeItemAdded}
+	if types := eventTypes(store, cartID); fmt.Sprint(types) != fmt.Sprint(want) {
+		t.Errorf("Expected one history for the cart, got %v", types)
+	}
+	if streams := len(store.GetAllEvents()); streams != 3 {
+		t.Errorf("Expected no other cart created, got %d events in the store", streams)
+	}
+
+	carts := NewRepository(store)
+	loaded, err := carts.LoadFor(&AddItemCommand{CartID: cartID, ItemID: "fig"})
+	if err != nil {
+		t.Fatalf("Error loading cart: %v", err)
+	}
+	if items := loaded.Items(); items["apple"] != 1 || items["pear"] != 1 {
+		t.Errorf("Expected the repository to load the cart's items, got %v", items)
+	}
+	if _, err := loaded.Handle(&AddItemCommand{CartID: cartID, ItemID: "fig"}); err != nil {
+		t.Fatalf("Error adding item to the loaded cart: %v", err)
+	}
+	if _, err := loaded.Handle(&AddItemCommand{CartID: uuid.New().String(), ItemID: "fig"}); err == nil {
+		t.Errorf("Expected a cart to refuse commands for another cart")
+	}
+}
+
+func TestCartAggregate_AddItemToMissingCart(t *testing.T) {
+	store := common.NewEventStore()
+	missing := uuid.New().String()
+
+	_, err := NewCartAggregate(store).Handle(&AddItemCommand{CartID: missing, ItemID: "apple"})
+	var notCreated *CartNotCreatedError
+	if !errors.As(err, &notCreated) || notCreated.CartID != missing {
+		t.Errorf("Expected CartNotCreatedError for a cart without events, got %v", err)
+	}
+	if events := store.GetAllEvents(); len(events) != 0 {
+		t.Errorf("Expected nothing stored, got %d events", len(events))
+	}
+	if _, err := NewRepository(store).LoadFor(&AddItemCommand{CartID: missing}); !errors.Is(err, common.ErrStreamNotFound) {
+		t.Errorf("Expected ErrStreamNotFound from the repository, got %v", err)
+	}
+}
+
+func TestCartAggregate_HydrateUpTo(t *testing.T) {
+	store := common.NewEventStore()
+	cart := NewCartAggregate(store)
+	created, _ := cart.Handle(&CreateCartCommand{})
+	cartID := created.AggregateID
+	cart.Handle(&AddItemCommand{CartID: cartID, ItemID: "apple"})
+	cart.Handle(&AddItemCommand{CartID: cartID, ItemID: "pear"})
+	cart.Handle(&CheckoutCartCommand{CartID: cartID})
+
+	snapshots := common.NewStoreSnapshots(store)
+	if _, err := common.TakeSnapshot(snapshots, cart); err != nil {
+		t.Fatalf("Error taking snapshot: %v", err)
+	}
+
+	view := NewCartAggregate(store)
+	view.SetSnapshots(snapshots)
+	if err := view.HydrateUpTo(cartID, 2); err != nil {
+		t.Fatalf("Error hydrating up to version 2: %v", err)
+	}
+	if items := view.Items(); view.Version() != 2 || len(items) != 1 || items["apple"] != 1 || view.IsClosed() {
+		t.Errorf("Expected the open cart holding the apple at version 2, got %v at version %d", items, view.Version())
+	}
+	if !view.IsReadOnly() {
+		t.Errorf("Expected a view hydrated up to a version to be read-only")
+	}
+	_, err := view.Handle(&AddItemCommand{CartID: cartID, ItemID: "fig"})
+	if !errors.Is(err, common.ErrAggregateReadOnly) {
+		t.Errorf("Expected commands rejected by the read-only view, got %v", err)
+	}
+	if version := store.GetStreamVersion(cartID); version != 4 {
+		t.Errorf("Expected the stream untouched at version 4, got %d", version)
+	}
+
+	if err := NewCartAggregate(store).HydrateUpTo(cartID, 0); err == nil {
+		t.Errorf("Expected an error hydrating up to version 0")
+	}
+}
+
+func eventTypes(store common.Store, streamID string) []string {
+	events, _ := store.GetStream(streamID)
+	types := make([]string, len(events))
+	for i, event := range events {
+		types[i] = event.Type
+	}
+	return types
 }
 
 func TestCartAggregate_RemoveItem(t *testing.T) {
@@ -105,8 +237,8 @@ func TestCartAggregate_RemoveItem(t *testing.T) {
 	}
 
 	addCmd := &AddItemCommand{
-		AggregateID: createEvent.AggregateID,
-		ItemID:      "item-1",
+		CartID: createEvent.AggregateID,
+		ItemID: "item-1",
 	}
 	_, err = cart.Handle(addCmd)
 	if err != nil {
@@ -115,8 +247,8 @@ func TestCartAggregate_RemoveItem(t *testing.T) {
 
 	// Remove item
 	removeCmd := &RemoveItemCommand{
-		AggregateID: createEvent.AggregateID,
-		ItemID:      "item-1",
+		CartID: createEvent.AggregateID,
+		ItemID: "item-1",
 	}
 	event, err := cart.Handle(removeCmd)
 
@@ -150,16 +282,17 @@ func TestCartAggregate_RemoveNonexistentItem(t *testing.T) {
 
 	// Try to remove item that's not in cart
 	removeCmd := &RemoveItemCommand{
-		AggregateID: createEvent.AggregateID,
-		ItemID:      "nonexistent-item",
+		CartID: createEvent.AggregateID,
+		ItemID: "nonexistent-item",
 	}
 	_, err = cart.Handle(removeCmd)
 
 	if err == nil {
 		t.Error("Expected error when removing nonexistent item")
 	}
-	if _, ok := err.(*common.InvalidCommandError); !ok {
-		t.Errorf("Expected InvalidCommandError, got %T", err)
+	var notInCart *ItemNotInCartError
+	if !errors.As(err, &notInCart) || notInCart.ItemID != "nonexistent-item" {
+		t.Errorf("Expected ItemNotInCartError, got %T", err)
 	}
 }
 
@@ -175,8 +308,8 @@ func TestCartAggregate_ClearCart(t *testing.T) {
 	}
 
 	addCmd1 := &AddItemCommand{
-		AggregateID: createEvent.AggregateID,
-		ItemID:      "item-1",
+		CartID: createEvent.AggregateID,
+		ItemID: "item-1",
 	}
 	_, err = cart.Handle(addCmd1)
 	if err != nil {
@@ -184,8 +317,8 @@ func TestCartAggregate_ClearCart(t *testing.T) {
 	}
 
 	addCmd2 := &AddItemCommand{
-		AggregateID: createEvent.AggregateID,
-		ItemID:      "item-2",
+		CartID: createEvent.AggregateID,
+		ItemID: "item-2",
 	}
 	_, err = cart.Handle(addCmd2)
 	if err != nil {
@@ -194,7 +327,7 @@ func TestCartAggregate_ClearCart(t *testing.T) {
 
 	// Clear cart
 	clearCmd := &ClearCartCommand{
-		AggregateID: createEvent.AggregateID,
+		CartID: createEvent.AggregateID,
 	}
 	event, err := cart.Handle(clearCmd)
 
@@ -212,6 +345,171 @@ func TestCartAggregate_ClearCart(t *testing.T) {
 	}
 }
 
+func TestCartAggregate_CheckoutClosesCart(t *testing.T) {
+	store := common.NewEventStore()
+	cart := NewCartAggregate(store)
+
+	createEvent, err := cart.Handle(&CreateCartCommand{})
+	if err != nil {
+		t.Fatalf("Error creating cart: %v", err)
+	}
+	cartID := createEvent.AggregateID
+	if _, err := cart.Handle(&AddItemCommand{CartID: cartID, ItemID: "item-1"}); err != nil {
+		t.Fatalf("Error adding item: %v", err)
+	}
+
+	event, err := cart.Handle(&CheckoutCartCommand{CartID: cartID})
+	if err != nil {
+		t.Fatalf("Error checking out cart: %v", err)
+	}
+	if event.Type != EventTypeCartCheckedOut || event.Version != 3 {
+		t.Errorf("Expected CartCheckedOut at version 3, got %s at %d", event.Type, event.Version)
+	}
+
+	// A freshly hydrated cart exposes the terminal state and rejects commands
+	hydrated := NewCartAggregate(store)
+	if err := hydrated.Hydrate(cartID); err != nil {
+		t.Fatalf("Error hydrating cart: %v", err)
+	}
+	if !hydrated.IsClosed() || hydrated.ClosedBy() != EventTypeCartCheckedOut {
+		t.Errorf("Expected hydrated cart closed by %s, got %q", EventTypeCartCheckedOut, hydrated.ClosedBy())
+	}
+
+	_, err = NewCartAggregate(store).Handle(&AddItemCommand{CartID: cartID, ItemID: "item-2"})
+	var closed *common.AggregateClosedError
+	if !errors.As(err, &closed) || closed.ClosedBy != EventTypeCartCheckedOut || closed.Version != 3 {
+		t.Errorf("Expected AggregateClosedError, got %v", err)
+	}
+	if _, err := NewCartAggregate(store).Handle(&CheckoutCartCommand{CartID: cartID}); !errors.Is(err, common.ErrAggregateClosed) {
+		t.Errorf("Expected a second checkout to be rejected, got %v", err)
+	}
+	if version := store.GetStreamVersion(cartID); version != 3 {
+		t.Errorf("Expected no events after checkout, got version %d", version)
+	}
+}
+
+func TestCartAggregate_DeleteAndRestore(t *testing.T) {
+	store := common.NewEventStore()
+	created, err := NewCartAggregate(store).Handle(&CreateCartCommand{})
+	if err != nil {
+		t.Fatalf("Error creating cart: %v", err)
+	}
+	cartID := created.AggregateID
+
+	if _, err := NewCartAggregate(store).Handle(&RestoreCartCommand{CartID: cartID}); !errors.As(err, new(*CartNotDeletedError)) {
+		t.Errorf("Expected CartNotDeletedError restoring a live cart, got %v", err)
+	}
+	if _, err := NewCartAggregate(store).Handle(&DeleteCartCommand{CartID: cartID}); err != nil {
+		t.Fatalf("Error deleting cart: %v", err)
+	}
+
+	deleted := NewCartAggregate(store)
+	_, err = deleted.Handle(&AddItemCommand{CartID: cartID, ItemID: "item-1"})
+	var deletedErr *CartDeletedError
+	if !errors.As(err, &deletedErr) || !errors.Is(err, common.ErrInvalidCommand) {
+		t.Errorf("Expected CartDeletedError, got %v", err)
+	}
+	if !deleted.IsDeleted() {
+		t.Error("Expected the hydrated cart to be deleted")
+	}
+
+	event, err := NewCartAggregate(store).Handle(&RestoreCartCommand{CartID: cartID})
+	if err != nil || event.Type != EventTypeCartRestored || event.Version != 3 {
+		t.Fatalf("Expected CartRestored at version 3, got %v, %v", event, err)
+	}
+	if _, err := NewCartAggregate(store).Handle(&AddItemCommand{CartID: cartID, ItemID: "item-1"}); err != nil {
+		t.Errorf("Expected a restored cart to accept commands, got %v", err)
+	}
+}
+
+func TestCartAggregate_VariantsAreDistinctLines(t *testing.T) {
+	store := common.NewEventStore()
+	cart := NewCartAggregate(store)
+	created, _ := cart.Handle(&CreateCartCommand{})
+	cartID := created.AggregateID
+
+	medium := map[string]string{"size": "M", "color": "red"}
+	large := map[string]string{"size": "L", "color": "red"}
+	cart.Handle(&AddItemCommand{CartID: cartID, ItemID: "shirt", Options: medium})
+	cart.Handle(&AddItemCommand{CartID: cartID, ItemID: "shirt", Options: large})
+
+	items := cart.Items()
+	if len(items) != 2 || items["shirt[color=red,size=M]"] != 1 || items["shirt[color=red,size=L]"] != 1 {
+		t.Errorf("Expected one line per variant, got %v", items)
+	}
+
+	_, err := cart.Handle(&RemoveItemCommand{CartID: cartID, ItemID: "shirt"})
+	var notInCart *ItemNotInCartError
+	if !errors.As(err, &notInCart) || notInCart.ItemID != "shirt" {
+		t.Errorf("Expected ItemNotInCartError for the product without options, got %v", err)
+	}
+	if _, err := cart.Handle(&RemoveItemCommand{CartID: cartID, ItemID: "shirt", Options: medium}); err != nil {
+		t.Fatalf("Error removing variant: %v", err)
+	}
+
+	hydrated := NewCartAggregate(store)
+	hydrated.Hydrate(cartID)
+	if items := hydrated.Items(); len(items) != 1 || items["shirt[color=red,size=L]"] != 1 {
+		t.Errorf("Expected the large variant left after replay, got %v", items)
+	}
+}
+
+func TestCartAggregate_Lines(t *testing.T) {
+	store := common.NewEventStore()
+	cart := NewCartAggregate(store)
+	created, _ := cart.Handle(&CreateCartCommand{})
+	cartID := created.AggregateID
+
+	medium := map[string]string{"size": "M"}
+	cart.Handle(&AddItemCommand{CartID: cartID, ItemID: "shirt", Options: medium})
+	cart.Handle(&AddItemCommand{CartID: cartID, ItemID: "shirt", Options: medium})
+	cart.Handle(&AddItemCommand{CartID: cartID, ItemID: "apple"})
+	if _, err := cart.Handle(&AnnotateItemCommand{CartID: cartID, ItemID: "shirt", Options: medium, Note: "wrap it", Gift: true}); err != nil {
+		t.Fatalf("Error annotating: %v", err)
+	}
+	cart.Handle(&RemoveItemCommand{CartID: cartID, ItemID: "apple"})
+
+	hydrated := NewCartAggregate(store)
+	hydrated.Hydrate(cartID)
+	want := CartLine{Key: "shirt[size=M]", Item: "shirt", Options: medium, Quantity: 2, Note: "wrap it", Gift: true}
+	if lines := hydrated.Lines(); len(lines) != 1 || fmt.Sprint(lines[0]) != fmt.Sprint(want) {
+		t.Errorf("Expected the annotated shirt line and the apple line dropped, got %+v", lines)
+	}
+	if _, ok := hydrated.Line("apple", nil); ok {
+		t.Errorf("Expected no apple line once its last unit was removed")
+	}
+
+	state, err := hydrated.SnapshotState()
+	if err != nil {
+		t.Fatalf("Error taking snapshot: %v", err)
+	}
+	raw, _ := json.Marshal(state)
+	restored := NewCartAggregate(store)
+	if err := restored.RestoreSnapshot(common.Snapshot{StreamID: cartID, State: raw}); err != nil {
+		t.Fatalf("Error restoring snapshot: %v", err)
+	}
+	if line, ok := restored.Line("shirt", medium); !ok || fmt.Sprint(line) != fmt.Sprint(want) {
+		t.Errorf("Expected the line restored from the snapshot, got %+v", line)
+	}
+
+	legacy := common.Snapshot{StreamID: cartID, State: json.RawMessage(`{"items":{"pear":3}}`)}
+	if err := restored.RestoreSnapshot(legacy); err != nil {
+		t.Fatalf("Error restoring snapshot: %v", err)
+	}
+	if items := restored.Items(); len(items) != 1 || items["pear"] != 3 {
+		t.Errorf("Expected quantities restored from a snapshot without lines, got %v", items)
+	}
+}
+
+func TestLineKey(t *testing.T) {
+	if key := LineKey("apple", nil); key != "apple" {
+		t.Errorf("Expected the item ID without options, got %s", key)
+	}
+	if key := LineKey("shirt", map[string]string{"size": "M", "color": "red"}); key != "shirt[color=red,size=M]" {
+		t.Errorf("Expected options sorted by name, got %s", key)
+	}
+}
+
 func TestCartAggregate_MaxItemsLimit(t *testing.T) {
 	store := common.NewEventStore()
 	cart := NewCartAggregate(store)
@@ -226,8 +524,8 @@ func TestCartAggregate_MaxItemsLimit(t *testing.T) {
 	// Add 3 items (the limit)
 	for i := 1; i <= 3; i++ {
 		addCmd := &AddItemCommand{
-			AggregateID: createEvent.AggregateID,
-			ItemID:      fmt.Sprintf("item-%d", i),
+			CartID: createEvent.AggregateID,
+			ItemID: fmt.Sprintf("item-%d", i),
 		}
 		_, err = cart.Handle(addCmd)
 		if err != nil {
@@ -237,16 +535,98 @@ func TestCartAggregate_MaxItemsLimit(t *testing.T) {
 
 	// Try to add a 4th item (should fail)
 	addCmd := &AddItemCommand{
-		AggregateID: createEvent.AggregateID,
-		ItemID:      "item-4",
+		CartID: createEvent.AggregateID,
+		ItemID: "item-4",
 	}
 	_, err = cart.Handle(addCmd)
 
 	if err == nil {
 		t.Error("Expected error when exceeding item limit")
 	}
-	if _, ok := err.(*common.InvalidCommandError); !ok {
-		t.Errorf("Expected InvalidCommandError, got %T", err)
+	var limit *CartItemLimitExceededError
+	if !errors.As(err, &limit) || limit.Limit != MaxItems || limit.Current != 3 {
+		t.Errorf("Expected CartItemLimitExceededError, got %T", err)
+	}
+	if !errors.Is(err, common.ErrInvalidCommand) || common.ErrorCodeOf(err) != CodeCartItemLimitExceeded {
+		t.Errorf("Expected the rejection to be an invalid command coded %q, got %v", CodeCartItemLimitExceeded, common.ErrorCodeOf(err))
+	}
+}
+
+func TestCartAggregate_AddItems(t *testing.T) {
+	store := common.NewEventStore()
+	created, _ := NewCartAggregate(store).Handle(&CreateCartCommand{})
+	cartID := created.AggregateID
+
+	last, err := NewCartAggregate(store).Handle(&AddItemsCommand{CartID: cartID, Lines: []ItemLine{
+		{ItemID: "apple"},
+		{ItemID: "shirt", Options: map[string]string{"size": "M"}},
+	}})
+	if err != nil {
+		t.Fatalf("Error adding items: %v", err)
+	}
+	if last.Version != 3 || store.GetStreamVersion(cartID) != 3 {
+		t.Errorf("Expected one event per line ending at version 3, got %d", store.GetStreamVersion(cartID))
+	}
+
+	// The batch as a whole exceeds the limit, so none of it is added
+	cart := NewCartAggregate(store)
+	_, err = cart.Handle(&AddItemsCommand{CartID: cartID, Lines: []ItemLine{{ItemID: "pear"}, {ItemID: "plum"}}})
+	var limit *CartItemLimitExceededError
+	if !errors.As(err, &limit) || limit.Current != 2 || limit.Adding != 2 {
+		t.Errorf("Expected CartItemLimitExceededError, got %v", err)
+	}
+	if store.GetStreamVersion(cartID) != 3 {
+		t.Errorf("Expected nothing stored, got version %d", store.GetStreamVersion(cartID))
+	}
+
+	if _, err := NewCartAggregate(store).Handle(&AddItemsCommand{CartID: cartID}); !errors.Is(err, common.ErrInvalidCommand) {
+		t.Errorf("Expected an empty batch rejected, got %v", err)
+	}
+}
+
+func TestCartAggregate_ReorderCart(t *testing.T) {
+	store := common.NewEventStore()
+	created, _ := NewCartAggregate(store).Handle(&CreateCartCommand{})
+	sourceID := created.AggregateID
+	NewCartAggregate(store).Handle(&AddItemCommand{CartID: sourceID, ItemID: "apple"})
+	NewCartAggregate(store).Handle(&AddItemCommand{CartID: sourceID, ItemID: "shirt", Options: map[string]string{"size": "M"}})
+	NewCartAggregate(store).Handle(&AddItemCommand{CartID: sourceID, ItemID: "pear"})
+	NewCartAggregate(store).Handle(&RemoveItemCommand{CartID: sourceID, ItemID: "pear"})
+
+	_, err := NewCartAggregate(store).Handle(&ReorderCartCommand{SourceCartID: sourceID})
+	var open *CartNotCheckedOutError
+	if !errors.As(err, &open) || common.ErrorCodeOf(err) != CodeCartNotCheckedOut {
+		t.Fatalf("Expected an open cart not reorderable, got %v", err)
+	}
+	NewCartAggregate(store).Handle(&CheckoutCartCommand{CartID: sourceID})
+
+	reorder := NewCartAggregate(store)
+	last, err := reorder.Handle(&ReorderCartCommand{SourceCartID: sourceID})
+	if err != nil {
+		t.Fatalf("Error reordering cart: %v", err)
+	}
+	events, _ := store.GetStream(last.AggregateID)
+	if last.AggregateID == sourceID || len(events) != 3 || events[0].Metadata[ReorderedFromKey] != sourceID {
+		t.Fatalf("Expected a new cart reordered from the source, got %v", events)
+	}
+	items := reorder.Items()
+	if len(items) != 2 || items["apple"] != 1 || items["shirt[size=M]"] != 1 {
+		t.Errorf("Expected the checked-out items, got %v", items)
+	}
+
+	// A translator leaving out items no longer sold
+	inStock := NewCartAggregate(store)
+	inStock.SetReorderTranslator(ReorderTranslatorFunc(func(source []*common.Event) ([]ItemLine, error) {
+		lines, err := SameItems.Translate(source)
+		return lines[1:], err
+	}))
+	if last, err := inStock.Handle(&ReorderCartCommand{SourceCartID: sourceID}); err != nil || last.Version != 2 {
+		t.Errorf("Expected the translated items only, got %v, %v", last, err)
+	}
+
+	var missing *CartNotCreatedError
+	if _, err := NewCartAggregate(store).Handle(&ReorderCartCommand{SourceCartID: "99999999-9999-4999-8999-999999999999"}); !errors.As(err, &missing) {
+		t.Errorf("Expected an unknown source rejected, got %v", err)
 	}
 }
 
@@ -262,8 +642,8 @@ func TestCartAggregate_EventReplay(t *testing.T) {
 	}
 
 	addCmd1 := &AddItemCommand{
-		AggregateID: createEvent.AggregateID,
-		ItemID:      "item-1",
+		CartID: createEvent.AggregateID,
+		ItemID: "item-1",
 	}
 	_, err = cart1.Handle(addCmd1)
 	if err != nil {
@@ -271,8 +651,8 @@ func TestCartAggregate_EventReplay(t *testing.T) {
 	}
 
 	addCmd2 := &AddItemCommand{
-		AggregateID: createEvent.AggregateID,
-		ItemID:      "item-2",
+		CartID: createEvent.AggregateID,
+		ItemID: "item-2",
 	}
 	_, err = cart1.Handle(addCmd2)
 	if err != nil {
@@ -308,3 +688,128 @@ func TestCartAggregate_EventReplay(t *testing.T) {
 		t.Errorf("Expected same ID, got %s vs %s", cart1.ID(), cart2.ID())
 	}
 }
+
+// shareCartCommand is a command the cart aggregate does not handle
+type shareCartCommand struct{}
+
+func (shareCartCommand) AggregateID() string { return "" }
+func (shareCartCommand) CommandType() string { return "ShareCart" }
+
+func TestCartAggregate_UnknownCommand(t *testing.T) {
+	cart := NewCartAggregate(common.NewEventStore())
+
+	_, err := cart.Handle(shareCartCommand{})
+	var unknown *common.UnknownCommandError
+	if !errors.As(err, &unknown) {
+		t.Fatalf("Expected UnknownCommandError, got %v", err)
+	}
+	if unknown.CommandType != "ShareCart" {
+		t.Errorf("Expected command type ShareCart, got %s", unknown.CommandType)
+	}
+	if len(unknown.Registered) != 17 || unknown.Registered[0] != CommandTypeAddItem {
+		t.Errorf("Expected the registered cart commands, got %v", unknown.Registered)
+	}
+}
+
+// failingRater is a ShippingRater whose carrier is down
+type failingRater struct{}
+
+func (failingRater) Rate(option string, items map[string]int) (float64, bool, error) {
+	return 0, false, errors.New("carrier unreachable")
+}
+
+func TestCartAggregate_SelectShippingOption(t *testing.T) {
+	store := common.NewEventStore()
+	cart := NewCartAggregate(store)
+	cart.SetShippingRater(FakeShippingRater{"pickup": 0, "courier": 7.5})
+	created, _ := cart.Handle(&CreateCartCommand{})
+	cartID := created.AggregateID
+
+	_, err := cart.Handle(&SelectShippingOptionCommand{CartID: cartID, Option: "courier"})
+	if !errors.As(err, new(*CartEmptyError)) {
+		t.Errorf("Expected CartEmptyError for an empty cart, got %v", err)
+	}
+	cart.Handle(&AddItemCommand{CartID: cartID, ItemID: "apple"})
+	_, err = cart.Handle(&SelectShippingOptionCommand{CartID: cartID, Option: "express"})
+	if !errors.As(err, new(*ShippingUnavailableError)) || !errors.Is(err, common.ErrInvalidCommand) {
+		t.Errorf("Expected ShippingUnavailableError for an option the rater doesn't offer, got %v", err)
+	}
+
+	event, err := cart.Handle(&SelectShippingOptionCommand{CartID: cartID, Option: "courier"})
+	if err != nil {
+		t.Fatalf("Error selecting shipping: %v", err)
+	}
+	if event.Type != EventTypeShippingOptionSelected || event.Version != 3 || event.Data["cost"] != 7.5 {
+		t.Errorf("Expected the courier quote recorded at version 3, got %+v", event)
+	}
+
+	cart.SetShippingRater(failingRater{})
+	if _, err := cart.Handle(&SelectShippingOptionCommand{CartID: cartID, Option: "pickup"}); err == nil || err.Error() != "carrier unreachable" {
+		t.Errorf("Expected the rater's error, got %v", err)
+	}
+
+	cart.Handle(&CheckoutCartCommand{CartID: cartID})
+	view, err := NewCartItemsQuery(cartID, store).Execute()
+	if err != nil {
+		t.Fatalf("Error executing query: %v", err)
+	}
+	if view.ShippingOption != "courier" || view.Totals.ShippingAmount != 7.5 || view.Totals.GrandTotal != 7.5 {
+		t.Errorf("Expected the courier shipping in the totals, got %q %+v", view.ShippingOption, view.Totals)
+	}
+}
+
+func TestCartAggregate_GiftCards(t *testing.T) {
+	store := common.NewEventStore()
+	cart := NewCartAggregate(store)
+	created, _ := cart.Handle(&CreateCartCommand{})
+	cartID := created.AggregateID
+	cart.Handle(&AddItemCommand{CartID: cartID, ItemID: "apple"})
+	cart.Handle(&SelectShippingOptionCommand{CartID: cartID, Option: "standard"})
+
+	if _, err := cart.Handle(&ApplyGiftCardCommand{CartID: cartID, GiftCardID: "card-1", Amount: 3}); err != nil {
+		t.Fatalf("Error applying gift card: %v", err)
+	}
+	_, err := cart.Handle(&ApplyGiftCardCommand{CartID: cartID, GiftCardID: "card-1", Amount: 1})
+	if !errors.As(err, new(*GiftCardAppliedError)) {
+		t.Errorf("Expected GiftCardAppliedError applying a card twice, got %v", err)
+	}
+	cart.Handle(&ApplyGiftCardCommand{CartID: cartID, GiftCardID: "card-2", Amount: 10})
+
+	view, err := NewCartItemsQuery(cartID, store).Execute()
+	if err != nil {
+		t.Fatalf("Error executing query: %v", err)
+	}
+	if view.Totals.GiftCardAmount != 13 || view.Totals.GrandTotal != 0 {
+		t.Errorf("Expected gift cards to pay the whole 4.99 shipping, got %+v", view.Totals)
+	}
+
+	if _, err := cart.Handle(&RemoveGiftCardCommand{CartID: cartID, GiftCardID: "card-2"}); err != nil {
+		t.Fatalf("Error removing gift card: %v", err)
+	}
+	_, err = cart.Handle(&RemoveGiftCardCommand{CartID: cartID, GiftCardID: "card-2"})
+	if !errors.As(err, new(*GiftCardNotAppliedError)) {
+		t.Errorf("Expected GiftCardNotAppliedError removing a card twice, got %v", err)
+	}
+	if giftCards := cart.GiftCards(); len(giftCards) != 1 || giftCards["card-1"] != 3 {
+		t.Errorf("Expected only card-1 applied, got %v", giftCards)
+	}
+	view, _ = NewCartItemsQuery(cartID, store).Execute()
+	if _, applied := view.GiftCards["card-2"]; applied || view.Totals.GrandTotal != 1.99 {
+		t.Errorf("Expected card-1 to pay 3 of the 4.99 shipping, got %v %+v", view.GiftCards, view.Totals)
+	}
+
+	_, err = cart.Handle(&CheckoutCartCommand{CartID: cartID})
+	if unconfirmed := new(*GiftCardNotConfirmedError); !errors.As(err, unconfirmed) || (*unconfirmed).GiftCardID != "card-1" {
+		t.Errorf("Expected GiftCardNotConfirmedError checking out before the reservation, got %v", err)
+	}
+	if _, err := cart.Handle(&ConfirmGiftCardCommand{CartID: cartID, GiftCardID: "card-1"}); err != nil {
+		t.Fatalf("Error confirming gift card: %v", err)
+	}
+	_, err = cart.Handle(&ConfirmGiftCardCommand{CartID: cartID, GiftCardID: "card-1"})
+	if !errors.As(err, new(*GiftCardNotPendingError)) {
+		t.Errorf("Expected GiftCardNotPendingError confirming a card twice, got %v", err)
+	}
+	if _, err := cart.Handle(&CheckoutCartCommand{CartID: cartID}); err != nil {
+		t.Errorf("Expected checkout once every card is confirmed, got %v", err)
+	}
+}
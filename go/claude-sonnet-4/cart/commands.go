@@ -1,25 +1,185 @@
 // Package cart provides command types for the cart domain.
-// Commands are simple record structures with no behaviors.
+// Commands are simple record structures; their only methods expose the routing
+// metadata required by common.Command.
 package cart
 
 // CreateCartCommand represents a command to create a new cart
 type CreateCartCommand struct {
-	AggregateID string
+	CartID string `json:"aggregate_id,omitempty"`
 }
 
-// AddItemCommand represents a command to add an item to the cart
+// AddItemCommand represents a command to add an item to the cart. Without a CartID
+// the cart's AutoCreatePolicy decides whether a new cart is created for the item.
 type AddItemCommand struct {
-	AggregateID string
-	ItemID      string
+	CartID string `json:"aggregate_id,omitempty" validate:"omitempty,streamid"`
+	ItemID string `json:"item_id" validate:"required"`
+	// Options are the variant attributes of the item, e.g. {"size": "M"}
+	Options map[string]string `json:"options,omitempty"`
+	// Quantity is how many units to add; 0 adds one. Units beyond one are added
+	// only while FlagQuantityAwareAdd is active and ignored otherwise.
+	Quantity int `json:"quantity,omitempty" validate:"omitempty,positive"`
+}
+
+// ItemLine is an item to add with AddItemsCommand
+type ItemLine struct {
+	ItemID string `json:"item_id" validate:"required"`
+	// Options are the variant attributes of the item, e.g. {"size": "M"}
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// AddItemsCommand represents a command to add several items to the cart at once,
+// e.g. when importing a wishlist. The whole batch is checked against the cart's
+// limits and its ItemAdded events, one per line, are stored together or not at all.
+type AddItemsCommand struct {
+	CartID string     `json:"aggregate_id" validate:"required,streamid"`
+	Lines  []ItemLine `json:"lines" validate:"required"`
+}
+
+// ReorderCartCommand represents a command to create a new cart holding the items of
+// a checked-out cart, translated by the aggregate's ReorderTranslator
+type ReorderCartCommand struct {
+	SourceCartID string `json:"source_cart_id" validate:"required,streamid"`
 }
 
 // RemoveItemCommand represents a command to remove an item from the cart
 type RemoveItemCommand struct {
-	AggregateID string
-	ItemID      string
+	CartID string `json:"aggregate_id" validate:"required,streamid"`
+	ItemID string `json:"item_id" validate:"required"`
+	// Options select the variant to remove, as given when it was added
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// AnnotateItemCommand represents a command to attach a note and gift flag to a cart
+// line, replacing any earlier annotation of it
+type AnnotateItemCommand struct {
+	CartID string `json:"aggregate_id" validate:"required,streamid"`
+	ItemID string `json:"item_id" validate:"required"`
+	// Options select the variant to annotate, as given when it was added
+	Options map[string]string `json:"options,omitempty"`
+	Note    string            `json:"note,omitempty"`
+	Gift    bool              `json:"gift,omitempty"`
+}
+
+// RenameCartCommand represents a command to give the cart a user-facing name, e.g.
+// "Birthday party"
+type RenameCartCommand struct {
+	CartID string `json:"aggregate_id" validate:"required,streamid"`
+	Name   string `json:"name" validate:"required"`
+}
+
+// SetCartAttributeCommand represents a command to set an arbitrary attribute of the
+// cart, e.g. {"channel": "mobile"}; an empty value removes the attribute
+type SetCartAttributeCommand struct {
+	CartID string `json:"aggregate_id" validate:"required,streamid"`
+	Key    string `json:"key" validate:"required"`
+	Value  string `json:"value,omitempty"`
 }
 
 // ClearCartCommand represents a command to clear all items from the cart
 type ClearCartCommand struct {
-	AggregateID string
+	CartID string `json:"aggregate_id" validate:"required,streamid"`
 }
+
+// CheckoutCartCommand represents a command to check out the cart, closing it
+type CheckoutCartCommand struct {
+	CartID string `json:"aggregate_id" validate:"required,streamid"`
+}
+
+// SelectShippingOptionCommand represents a command to choose how the cart is
+// shipped, replacing any earlier choice
+type SelectShippingOptionCommand struct {
+	CartID string `json:"aggregate_id" validate:"required,streamid"`
+	Option string `json:"option" validate:"required"`
+}
+
+// ApplyGiftCardCommand represents a command to pay part of the cart with a gift
+// card. The card's balance is reserved by the giftcard process manager, which
+// removes the card again if the balance is short.
+type ApplyGiftCardCommand struct {
+	CartID     string  `json:"aggregate_id" validate:"required,streamid"`
+	GiftCardID string  `json:"gift_card_id" validate:"required,uuid"`
+	Amount     float64 `json:"amount" validate:"positive"`
+}
+
+// RemoveGiftCardCommand represents a command to stop paying with a gift card
+type RemoveGiftCardCommand struct {
+	CartID     string `json:"aggregate_id" validate:"required,streamid"`
+	GiftCardID string `json:"gift_card_id" validate:"required,uuid"`
+}
+
+// ConfirmGiftCardCommand represents a command to record that the balance of a gift
+// card paying for the cart is reserved. The giftcard process manager sends it once
+// the reservation succeeds; the cart can't be checked out before.
+type ConfirmGiftCardCommand struct {
+	CartID     string `json:"aggregate_id" validate:"required,streamid"`
+	GiftCardID string `json:"gift_card_id" validate:"required,uuid"`
+}
+
+// ExpireCartCommand represents a command to expire an abandoned cart, closing it
+// like a checkout
+type ExpireCartCommand struct {
+	CartID string `json:"aggregate_id" validate:"required,streamid"`
+}
+
+// DeleteCartCommand represents a command to soft-delete the cart; it can be
+// restored until the retention policy purges it
+type DeleteCartCommand struct {
+	CartID string `json:"aggregate_id" validate:"required,streamid"`
+}
+
+// RestoreCartCommand represents a command to restore a soft-deleted cart
+type RestoreCartCommand struct {
+	CartID string `json:"aggregate_id" validate:"required,streamid"`
+}
+
+func (c *CreateCartCommand) AggregateID() string { return c.CartID }
+func (c *CreateCartCommand) CommandType() string { return CommandTypeCreateCart }
+
+func (c *AddItemCommand) AggregateID() string { return c.CartID }
+func (c *AddItemCommand) CommandType() string { return CommandTypeAddItem }
+
+func (c *AddItemsCommand) AggregateID() string { return c.CartID }
+func (c *AddItemsCommand) CommandType() string { return CommandTypeAddItems }
+
+// AggregateID is empty: the command creates a new cart
+func (c *ReorderCartCommand) AggregateID() string { return "" }
+func (c *ReorderCartCommand) CommandType() string { return CommandTypeReorderCart }
+
+func (c *RemoveItemCommand) AggregateID() string { return c.CartID }
+func (c *RemoveItemCommand) CommandType() string { return CommandTypeRemoveItem }
+
+func (c *ClearCartCommand) AggregateID() string { return c.CartID }
+func (c *ClearCartCommand) CommandType() string { return CommandTypeClearCart }
+
+func (c *CheckoutCartCommand) AggregateID() string { return c.CartID }
+func (c *CheckoutCartCommand) CommandType() string { return CommandTypeCheckoutCart }
+
+func (c *SelectShippingOptionCommand) AggregateID() string { return c.CartID }
+func (c *SelectShippingOptionCommand) CommandType() string { return CommandTypeSelectShippingOption }
+
+func (c *ApplyGiftCardCommand) AggregateID() string { return c.CartID }
+func (c *ApplyGiftCardCommand) CommandType() string { return CommandTypeApplyGiftCard }
+
+func (c *RemoveGiftCardCommand) AggregateID() string { return c.CartID }
+func (c *RemoveGiftCardCommand) CommandType() string { return CommandTypeRemoveGiftCard }
+
+func (c *ConfirmGiftCardCommand) AggregateID() string { return c.CartID }
+func (c *ConfirmGiftCardCommand) CommandType() string { return CommandTypeConfirmGiftCard }
+
+func (c *ExpireCartCommand) AggregateID() string { return c.CartID }
+func (c *ExpireCartCommand) CommandType() string { return CommandTypeExpireCart }
+
+func (c *DeleteCartCommand) AggregateID() string { return c.CartID }
+func (c *DeleteCartCommand) CommandType() string { return CommandTypeDeleteCart }
+
+func (c *RestoreCartCommand) AggregateID() string { return c.CartID }
+func (c *RestoreCartCommand) CommandType() string { return CommandTypeRestoreCart }
+
+func (c *AnnotateItemCommand) AggregateID() string { return c.CartID }
+func (c *AnnotateItemCommand) CommandType() string { return CommandTypeAnnotateItem }
+
+func (c *RenameCartCommand) AggregateID() string { return c.CartID }
+func (c *RenameCartCommand) CommandType() string { return CommandTypeRenameCart }
+
+func (c *SetCartAttributeCommand) AggregateID() string { return c.CartID }
+func (c *SetCartAttributeCommand) CommandType() string { return CommandTypeSetCartAttribute }
@@ -0,0 +1,24 @@
+// Package cart provides the rules for merging cart commands that raced each other.
+package cart
+
+import "simple-event-modeling/common"
+
+// Commutes reports whether a cart command can be merged with an event appended
+// since the version it was based on (see bus.MergeOnConflict). Adding items
+// commutes with other changes to the cart's lines and labels, since the cart ends
+// up holding the same items in either order; the item limit is checked again when
+// the command is replayed. Every other command, and any command racing a clear,
+// checkout, expiry or deletion, conflicts and is left to the caller.
+func Commutes(command common.Command, event *common.Event) bool {
+	switch command.(type) {
+	case *AddItemCommand, *AddItemsCommand:
+	default:
+		return false
+	}
+	switch event.Type {
+	case EventTypeItemAdded, EventTypeItemRemoved, EventTypeItemAnnotated, EventTypeCartRenamed,
+		EventTypeCartAttributeSet, EventTypeGiftCardApplied, EventTypeGiftCardRemoved:
+		return true
+	}
+	return false
+}
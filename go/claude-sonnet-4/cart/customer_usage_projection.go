@@ -0,0 +1,132 @@
+// Package cart provides the projection of what each customer has done with carts,
+// backing the customer quotas.
+package cart
+
+import (
+	"sort"
+	"time"
+
+	"simple-event-modeling/common"
+)
+
+// CustomerUsageProjectionName is the name the projection is registered under
+const CustomerUsageProjectionName = "customer-usage"
+
+// CustomerUsage is what a customer has done with carts
+type CustomerUsage struct {
+	// OpenCarts lists the carts the customer has open, neither checked out, expired,
+	// nor deleted
+	OpenCarts []string `json:"open_carts"`
+	// ItemsAdded counts the items the customer added on each UTC day
+	ItemsAdded map[time.Time]int `json:"items_added"`
+}
+
+// CustomerUsageProjection tracks the open carts of each customer and the items they
+// add each day. A cart belongs to the actor recorded on its CartCreated event (see
+// bus.ActorMetadata); carts created anonymously are not tracked. Days are read from
+// the events' CreatedAt in UTC, so a rebuild counts the same days as the live
+// projection did.
+type CustomerUsageProjection struct {
+	owners     map[string]string            // cart -> customer
+	open       map[string]map[string]bool   // customer -> open carts
+	itemsAdded map[string]map[time.Time]int // customer -> day -> items
+}
+
+// NewCustomerUsageProjection creates an empty customer usage projection
+func NewCustomerUsageProjection() *CustomerUsageProjection {
+	return &CustomerUsageProjection{
+		owners:     make(map[string]string),
+		open:       make(map[string]map[string]bool),
+		itemsAdded: make(map[string]map[time.Time]int),
+	}
+}
+
+// Name returns the registered name of the projection
+func (p *CustomerUsageProjection) Name() string {
+	return CustomerUsageProjectionName
+}
+
+// Consumes returns the event types the projection folds
+func (p *CustomerUsageProjection) Consumes() []string {
+	return []string{
+		EventTypeCartCreated, EventTypeItemAdded, EventTypeCartCheckedOut, EventTypeCartExpired,
+		EventTypeCartDeleted, EventTypeCartRestored,
+	}
+}
+
+// On folds a cart event into the usage of its customer; other events are ignored
+func (p *CustomerUsageProjection) On(event *common.Event) error {
+	switch event.Type {
+	case EventTypeCartCreated:
+		customer, _ := event.Metadata[common.ActorIDKey].(string)
+		if customer == "" {
+			return nil
+		}
+		p.owners[event.AggregateID] = customer
+		p.setOpen(customer, event.AggregateID, true)
+	case EventTypeCartRestored:
+		if customer, exists := p.owners[event.AggregateID]; exists {
+			p.setOpen(customer, event.AggregateID, true)
+		}
+	case EventTypeCartCheckedOut, EventTypeCartExpired, EventTypeCartDeleted:
+		if customer, exists := p.owners[event.AggregateID]; exists {
+			p.setOpen(customer, event.AggregateID, false)
+		}
+	case EventTypeItemAdded:
+		customer, exists := p.owners[event.AggregateID]
+		if !exists {
+			return nil
+		}
+		days, exists := p.itemsAdded[customer]
+		if !exists {
+			days = make(map[time.Time]int)
+			p.itemsAdded[customer] = days
+		}
+		days[dayOf(event.CreatedAt)]++
+	}
+	return nil
+}
+
+func (p *CustomerUsageProjection) setOpen(customer, cartID string, open bool) {
+	carts, exists := p.open[customer]
+	if !exists {
+		carts = make(map[string]bool)
+		p.open[customer] = carts
+	}
+	if open {
+		carts[cartID] = true
+	} else {
+		delete(carts, cartID)
+	}
+}
+
+// OpenCarts returns how many carts customer has open
+func (p *CustomerUsageProjection) OpenCarts(customer string) int {
+	return len(p.open[customer])
+}
+
+// ItemsAddedOn returns how many items customer added on the UTC day of t
+func (p *CustomerUsageProjection) ItemsAddedOn(customer string, t time.Time) int {
+	return p.itemsAdded[customer][dayOf(t)]
+}
+
+// State returns the usage of every tracked customer
+func (p *CustomerUsageProjection) State() interface{} {
+	state := make(map[string]CustomerUsage, len(p.open))
+	for customer, carts := range p.open {
+		usage := CustomerUsage{OpenCarts: make([]string, 0, len(carts)), ItemsAdded: make(map[time.Time]int)}
+		for cartID := range carts {
+			usage.OpenCarts = append(usage.OpenCarts, cartID)
+		}
+		sort.Strings(usage.OpenCarts)
+		for day, items := range p.itemsAdded[customer] {
+			usage.ItemsAdded[day] = items
+		}
+		state[customer] = usage
+	}
+	return state
+}
+
+func dayOf(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
@@ -0,0 +1,60 @@
+package cart
+
+import (
+	"testing"
+	"time"
+
+	"simple-event-modeling/common"
+)
+
+func TestCustomerUsageProjection_TracksOpenCartsAndDailyItems(t *testing.T) {
+	projection := NewCustomerUsageProjection()
+	day := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	by := func(event *common.Event, actor string, at time.Time) *common.Event {
+		event.CreatedAt = at
+		if actor != "" {
+			event.Metadata = map[string]interface{}{common.ActorIDKey: actor}
+		}
+		return event
+	}
+
+	// first and second belong to alice, third to bob, anonymous to nobody
+	const first, second, third, anonymous = "11111111-1111-4111-8111-111111111111", "22222222-2222-4222-8222-222222222222",
+		"33333333-3333-4333-8333-333333333333", "44444444-4444-4444-8444-444444444444"
+	events := []*common.Event{
+		by(NewCartCreatedEvent(first), "alice", day),
+		by(NewItemAddedEvent(first, 2, "apple"), "alice", day),
+		by(NewItemAddedEvent(first, 3, "pear"), "alice", day.Add(time.Hour)),
+		by(NewCartCreatedEvent(second), "alice", day),
+		by(NewItemAddedEvent(second, 2, "plum"), "alice", day.Add(24*time.Hour)),
+		by(NewCartCreatedEvent(third), "bob", day),
+		by(NewCartCreatedEvent(anonymous), "", day),
+		by(NewItemAddedEvent(anonymous, 2, "apple"), "", day),
+		by(NewCartCheckedOutEvent(first, 4), "alice", day),
+		by(NewCartDeletedEvent(third, 2), "bob", day),
+		by(NewCartRestoredEvent(third, 3), "bob", day),
+	}
+	for _, event := range events {
+		if err := projection.On(event); err != nil {
+			t.Fatalf("Error applying %s: %v", event.Type, err)
+		}
+	}
+
+	if open := projection.OpenCarts("alice"); open != 1 {
+		t.Errorf("Expected alice to have 1 open cart, got %d", open)
+	}
+	if open := projection.OpenCarts("bob"); open != 1 {
+		t.Errorf("Expected bob's restored cart to be open, got %d open carts", open)
+	}
+	if items := projection.ItemsAddedOn("alice", day.Add(12*time.Hour)); items != 2 {
+		t.Errorf("Expected alice to have added 2 items on the first day, got %d", items)
+	}
+	if items := projection.ItemsAddedOn("alice", day.Add(24*time.Hour)); items != 1 {
+		t.Errorf("Expected alice to have added 1 item on the second day, got %d", items)
+	}
+
+	state := projection.State().(map[string]CustomerUsage)
+	if len(state) != 2 || len(state["alice"].OpenCarts) != 1 || state["alice"].OpenCarts[0] != second {
+		t.Errorf("Expected the usage of alice and bob, got %+v", state)
+	}
+}
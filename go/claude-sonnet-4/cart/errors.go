@@ -0,0 +1,198 @@
+// Package cart provides the structured rejections of cart commands. Each names the
+// broken rule and carries its parameters, so UIs and tests can react to the rule
+// instead of matching message text. They all match common.ErrInvalidCommand with
+// errors.Is and are reported to HTTP callers as 422 InvalidCommandError, with the
+// rule in the "code" field.
+package cart
+
+import (
+	"fmt"
+
+	"simple-event-modeling/common"
+)
+
+// MaxItems is the maximum total quantity of items in a cart
+const MaxItems = 3
+
+// Rejection codes of cart commands
+const (
+	CodeCartNotCreated        common.ErrorCode = "cart_not_created"
+	CodeCartItemLimitExceeded common.ErrorCode = "cart_item_limit_exceeded"
+	CodeItemNotInCart         common.ErrorCode = "item_not_in_cart"
+	CodeCartDeleted           common.ErrorCode = "cart_deleted"
+	CodeCartNotDeleted        common.ErrorCode = "cart_not_deleted"
+	CodeCartEmpty             common.ErrorCode = "cart_empty"
+	CodeCartNotCheckedOut     common.ErrorCode = "cart_not_checked_out"
+	CodeShippingUnavailable   common.ErrorCode = "shipping_unavailable"
+	CodeGiftCardApplied       common.ErrorCode = "gift_card_already_applied"
+	CodeGiftCardNotApplied    common.ErrorCode = "gift_card_not_applied"
+	CodeGiftCardNotPending    common.ErrorCode = "gift_card_not_pending"
+	CodeGiftCardNotConfirmed  common.ErrorCode = "gift_card_not_confirmed"
+)
+
+// CartNotCreatedError rejects a command for a cart that has not been created. An
+// AddItem naming no cart under the Reject policy has no CartID.
+type CartNotCreatedError struct {
+	CartID string
+}
+
+func (e *CartNotCreatedError) Error() string {
+	if e.CartID == "" {
+		return "no cart given and carts are not created by adding items"
+	}
+	return fmt.Sprintf("cart %s has not been created", e.CartID)
+}
+
+func (e *CartNotCreatedError) Is(target error) bool   { return target == common.ErrInvalidCommand }
+func (e *CartNotCreatedError) Code() common.ErrorCode { return CodeCartNotCreated }
+
+// CartItemLimitExceededError rejects adding an item to a cart holding MaxItems items,
+// or a batch of items that would take it past MaxItems
+type CartItemLimitExceededError struct {
+	CartID  string
+	Limit   int
+	Current int
+	// Adding is the number of items in a rejected batch; 0 for a single item
+	Adding int
+}
+
+func (e *CartItemLimitExceededError) Error() string {
+	if e.Adding > 0 {
+		return fmt.Sprintf("too many items in cart %s: it holds %d of at most %d, adding %d", e.CartID, e.Current, e.Limit, e.Adding)
+	}
+	return fmt.Sprintf("too many items in cart %s: it holds %d of at most %d", e.CartID, e.Current, e.Limit)
+}
+
+func (e *CartItemLimitExceededError) Is(target error) bool   { return target == common.ErrInvalidCommand }
+func (e *CartItemLimitExceededError) Code() common.ErrorCode { return CodeCartItemLimitExceeded }
+
+// ItemNotInCartError rejects removing an item the cart doesn't hold
+type ItemNotInCartError struct {
+	CartID string
+	// ItemID is the line key of the item, including its variant options
+	ItemID string
+}
+
+func (e *ItemNotInCartError) Error() string {
+	return fmt.Sprintf("item %s is not in the cart", e.ItemID)
+}
+
+func (e *ItemNotInCartError) Is(target error) bool   { return target == common.ErrInvalidCommand }
+func (e *ItemNotInCartError) Code() common.ErrorCode { return CodeItemNotInCart }
+
+// CartDeletedError rejects a command for a soft-deleted cart other than RestoreCart
+type CartDeletedError struct {
+	CartID string
+}
+
+func (e *CartDeletedError) Error() string {
+	return fmt.Sprintf("cart %s is deleted; restore it first", e.CartID)
+}
+
+func (e *CartDeletedError) Is(target error) bool   { return target == common.ErrInvalidCommand }
+func (e *CartDeletedError) Code() common.ErrorCode { return CodeCartDeleted }
+
+// CartNotDeletedError rejects restoring a cart that is not deleted
+type CartNotDeletedError struct {
+	CartID string
+}
+
+func (e *CartNotDeletedError) Error() string {
+	return fmt.Sprintf("cart %s is not deleted", e.CartID)
+}
+
+func (e *CartNotDeletedError) Is(target error) bool   { return target == common.ErrInvalidCommand }
+func (e *CartNotDeletedError) Code() common.ErrorCode { return CodeCartNotDeleted }
+
+// CartEmptyError rejects selecting shipping for a cart without items, or reordering
+// one
+type CartEmptyError struct {
+	CartID string
+}
+
+func (e *CartEmptyError) Error() string {
+	return fmt.Sprintf("cart %s is empty", e.CartID)
+}
+
+func (e *CartEmptyError) Is(target error) bool   { return target == common.ErrInvalidCommand }
+func (e *CartEmptyError) Code() common.ErrorCode { return CodeCartEmpty }
+
+// CartNotCheckedOutError rejects reordering a cart that has not been checked out
+type CartNotCheckedOutError struct {
+	CartID string
+}
+
+func (e *CartNotCheckedOutError) Error() string {
+	return fmt.Sprintf("cart %s has not been checked out", e.CartID)
+}
+
+func (e *CartNotCheckedOutError) Is(target error) bool   { return target == common.ErrInvalidCommand }
+func (e *CartNotCheckedOutError) Code() common.ErrorCode { return CodeCartNotCheckedOut }
+
+// ShippingUnavailableError rejects a shipping option the ShippingRater can't ship
+// the cart's items with
+type ShippingUnavailableError struct {
+	CartID string
+	Option string
+}
+
+func (e *ShippingUnavailableError) Error() string {
+	return fmt.Sprintf("shipping option %q is not available for cart %s", e.Option, e.CartID)
+}
+
+func (e *ShippingUnavailableError) Is(target error) bool   { return target == common.ErrInvalidCommand }
+func (e *ShippingUnavailableError) Code() common.ErrorCode { return CodeShippingUnavailable }
+
+// GiftCardAppliedError rejects applying a gift card the cart is already paid with
+type GiftCardAppliedError struct {
+	CartID     string
+	GiftCardID string
+}
+
+func (e *GiftCardAppliedError) Error() string {
+	return fmt.Sprintf("gift card %s is already applied to cart %s", e.GiftCardID, e.CartID)
+}
+
+func (e *GiftCardAppliedError) Is(target error) bool   { return target == common.ErrInvalidCommand }
+func (e *GiftCardAppliedError) Code() common.ErrorCode { return CodeGiftCardApplied }
+
+// GiftCardNotAppliedError rejects removing a gift card the cart isn't paid with
+type GiftCardNotAppliedError struct {
+	CartID     string
+	GiftCardID string
+}
+
+func (e *GiftCardNotAppliedError) Error() string {
+	return fmt.Sprintf("gift card %s is not applied to cart %s", e.GiftCardID, e.CartID)
+}
+
+func (e *GiftCardNotAppliedError) Is(target error) bool   { return target == common.ErrInvalidCommand }
+func (e *GiftCardNotAppliedError) Code() common.ErrorCode { return CodeGiftCardNotApplied }
+
+// GiftCardNotPendingError rejects confirming a gift card that is already confirmed
+type GiftCardNotPendingError struct {
+	CartID     string
+	GiftCardID string
+}
+
+func (e *GiftCardNotPendingError) Error() string {
+	return fmt.Sprintf("gift card %s is already confirmed for cart %s", e.GiftCardID, e.CartID)
+}
+
+func (e *GiftCardNotPendingError) Is(target error) bool   { return target == common.ErrInvalidCommand }
+func (e *GiftCardNotPendingError) Code() common.ErrorCode { return CodeGiftCardNotPending }
+
+// GiftCardNotConfirmedError rejects checking out a cart paid with a gift card whose
+// balance is not reserved yet. The card is confirmed once the reservation succeeds,
+// and removed from the cart if it is declined.
+type GiftCardNotConfirmedError struct {
+	CartID     string
+	GiftCardID string
+}
+
+func (e *GiftCardNotConfirmedError) Error() string {
+	return fmt.Sprintf("gift card %s of cart %s is awaiting its balance reservation", e.GiftCardID, e.CartID)
+}
+
+func (e *GiftCardNotConfirmedError) Is(target error) bool   { return target == common.ErrInvalidCommand }
+func (e *GiftCardNotConfirmedError) Code() common.ErrorCode { return CodeGiftCardNotConfirmed }
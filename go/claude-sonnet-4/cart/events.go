@@ -2,7 +2,12 @@
 // Events are simple record structures with no behaviors.
 package cart
 
-import "simple-event-modeling/common"
+import (
+	"sort"
+	"strings"
+
+	"simple-event-modeling/common"
+)
 
 // Event type constants
 const (
@@ -10,8 +15,115 @@ const (
 	EventTypeItemAdded   = "ItemAdded"
 	EventTypeItemRemoved = "ItemRemoved"
 	EventTypeCartCleared = "CartCleared"
+	// EventTypeItemAnnotated sets the note and gift flag of a cart line
+	EventTypeItemAnnotated = "ItemAnnotated"
+	// EventTypeCartRenamed and EventTypeCartAttributeSet label the cart for its owner
+	EventTypeCartRenamed      = "CartRenamed"
+	EventTypeCartAttributeSet = "CartAttributeSet"
+	// EventTypeShippingOptionSelected records the shipping option and its quoted cost
+	EventTypeShippingOptionSelected = "ShippingOptionSelected"
+	// EventTypeGiftCardApplied and EventTypeGiftCardRemoved record the gift cards
+	// paying for the cart
+	EventTypeGiftCardApplied = "GiftCardApplied"
+	EventTypeGiftCardRemoved = "GiftCardRemoved"
+	// EventTypeGiftCardConfirmed records that an applied gift card's balance is
+	// reserved for the cart, which may then be checked out
+	EventTypeGiftCardConfirmed = "GiftCardConfirmed"
+	// EventTypeCartCheckedOut closes the cart for good
+	EventTypeCartCheckedOut = "CartCheckedOut"
+	// EventTypeCartExpired closes an abandoned cart for good
+	EventTypeCartExpired = "CartExpired"
+	// EventTypeCartDeleted soft-deletes the cart until CartRestored
+	EventTypeCartDeleted  = "CartDeleted"
+	EventTypeCartRestored = "CartRestored"
+	// EventTypeItemPriceChanged is an integration event recording a catalog price
+	EventTypeItemPriceChanged = "ItemPriceChanged"
 )
 
+// CatalogPricesStream is the stream ItemPriceChanged events are appended to
+const CatalogPricesStream = "catalog-prices"
+
+// ItemData is the payload of ItemAdded and ItemRemoved events
+type ItemData struct {
+	Item string `json:"item"`
+	// Options are the variant attributes of the item, e.g. size and color
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// AnnotationData is the payload of ItemAnnotated events
+type AnnotationData struct {
+	Item    string            `json:"item"`
+	Options map[string]string `json:"options,omitempty"`
+	Note    string            `json:"note"`
+	Gift    bool              `json:"gift"`
+}
+
+// Line returns the key of the cart line the annotation belongs to
+func (d AnnotationData) Line() string {
+	return LineKey(d.Item, d.Options)
+}
+
+// SKUOption is the variant option naming the SKU of a variant, which catalog prices
+// may be given for instead of the product
+const SKUOption = "sku"
+
+// Line returns the key of the cart line the item belongs to
+func (d ItemData) Line() string {
+	return LineKey(d.Item, d.Options)
+}
+
+// LineKey returns the key of the cart line holding an item with the given variant
+// options, so the same product in different options is a distinct line. Without
+// options the key is the item ID; otherwise the options follow it sorted by name,
+// e.g. "shirt[color=red,size=M]".
+func LineKey(itemID string, options map[string]string) string {
+	if len(options) == 0 {
+		return itemID
+	}
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + options[name]
+	}
+	return itemID + "[" + strings.Join(pairs, ",") + "]"
+}
+
+// PriceData is the payload of ItemPriceChanged events
+type PriceData struct {
+	Item  string  `json:"item"`
+	Price float64 `json:"price"`
+}
+
+// NameData is the payload of CartRenamed events
+type NameData struct {
+	Name string `json:"name"`
+}
+
+// AttributeData is the payload of CartAttributeSet events
+type AttributeData struct {
+	Key string `json:"key"`
+	// Value is the attribute's new value; empty removes the attribute
+	Value string `json:"value,omitempty"`
+}
+
+// ShippingData is the payload of ShippingOptionSelected events
+type ShippingData struct {
+	Option string  `json:"option"`
+	Cost   float64 `json:"cost"`
+}
+
+// GiftCardData is the payload of GiftCardApplied and GiftCardRemoved events
+type GiftCardData struct {
+	GiftCardID string `json:"gift_card_id"`
+	// Amount is the amount paid with the card; it is not set on GiftCardRemoved
+	Amount float64 `json:"amount,omitempty"`
+}
+
 // NewCartCreatedEvent creates a new CartCreated event
 func NewCartCreatedEvent(aggregateID string) *common.Event {
 	return common.NewEvent(EventTypeCartCreated, aggregateID, 1, nil, nil)
@@ -19,21 +131,122 @@ func NewCartCreatedEvent(aggregateID string) *common.Event {
 
 // NewItemAddedEvent creates a new ItemAdded event
 func NewItemAddedEvent(aggregateID string, version int, itemID string) *common.Event {
-	data := map[string]interface{}{
-		"item": itemID,
-	}
-	return common.NewEvent(EventTypeItemAdded, aggregateID, version, data, nil)
+	return NewVariantAddedEvent(aggregateID, version, itemID, nil)
+}
+
+// NewVariantAddedEvent creates a new ItemAdded event for an item with variant options
+func NewVariantAddedEvent(aggregateID string, version int, itemID string, options map[string]string) *common.Event {
+	return common.NewEvent(EventTypeItemAdded, aggregateID, version, itemPayload(itemID, options), nil)
 }
 
 // NewItemRemovedEvent creates a new ItemRemoved event
 func NewItemRemovedEvent(aggregateID string, version int, itemID string) *common.Event {
+	return NewVariantRemovedEvent(aggregateID, version, itemID, nil)
+}
+
+// NewVariantRemovedEvent creates a new ItemRemoved event for an item with variant
+// options
+func NewVariantRemovedEvent(aggregateID string, version int, itemID string, options map[string]string) *common.Event {
+	return common.NewEvent(EventTypeItemRemoved, aggregateID, version, itemPayload(itemID, options), nil)
+}
+
+// itemPayload builds the data of ItemAdded and ItemRemoved events, leaving options
+// out when there are none
+func itemPayload(itemID string, options map[string]string) map[string]interface{} {
 	data := map[string]interface{}{
 		"item": itemID,
 	}
-	return common.NewEvent(EventTypeItemRemoved, aggregateID, version, data, nil)
+	if len(options) > 0 {
+		encoded := make(map[string]interface{}, len(options))
+		for name, value := range options {
+			encoded[name] = value
+		}
+		data["options"] = encoded
+	}
+	return data
+}
+
+// NewItemAnnotatedEvent creates a new ItemAnnotated event for the line of an item
+// with the given variant options
+func NewItemAnnotatedEvent(aggregateID string, version int, itemID string, options map[string]string, note string, gift bool) *common.Event {
+	data := itemPayload(itemID, options)
+	data["note"] = note
+	data["gift"] = gift
+	return common.NewEvent(EventTypeItemAnnotated, aggregateID, version, data, nil)
+}
+
+// NewCartRenamedEvent creates a new CartRenamed event
+func NewCartRenamedEvent(aggregateID string, version int, name string) *common.Event {
+	return common.NewEvent(EventTypeCartRenamed, aggregateID, version, map[string]interface{}{"name": name}, nil)
+}
+
+// NewCartAttributeSetEvent creates a new CartAttributeSet event; an empty value
+// removes the attribute
+func NewCartAttributeSetEvent(aggregateID string, version int, key, value string) *common.Event {
+	data := map[string]interface{}{"key": key}
+	if value != "" {
+		data["value"] = value
+	}
+	return common.NewEvent(EventTypeCartAttributeSet, aggregateID, version, data, nil)
 }
 
 // NewCartClearedEvent creates a new CartCleared event
 func NewCartClearedEvent(aggregateID string, version int) *common.Event {
 	return common.NewEvent(EventTypeCartCleared, aggregateID, version, nil, nil)
 }
+
+// NewShippingOptionSelectedEvent creates a new ShippingOptionSelected event
+func NewShippingOptionSelectedEvent(aggregateID string, version int, option string, cost float64) *common.Event {
+	return common.NewEvent(EventTypeShippingOptionSelected, aggregateID, version, map[string]interface{}{
+		"option": option,
+		"cost":   cost,
+	}, nil)
+}
+
+// NewGiftCardAppliedEvent creates a new GiftCardApplied event
+func NewGiftCardAppliedEvent(aggregateID string, version int, giftCardID string, amount float64) *common.Event {
+	return common.NewEvent(EventTypeGiftCardApplied, aggregateID, version, map[string]interface{}{
+		"gift_card_id": giftCardID,
+		"amount":       amount,
+	}, nil)
+}
+
+// NewGiftCardConfirmedEvent creates a new GiftCardConfirmed event
+func NewGiftCardConfirmedEvent(aggregateID string, version int, giftCardID string) *common.Event {
+	return common.NewEvent(EventTypeGiftCardConfirmed, aggregateID, version, map[string]interface{}{"gift_card_id": giftCardID}, nil)
+}
+
+// NewGiftCardRemovedEvent creates a new GiftCardRemoved event
+func NewGiftCardRemovedEvent(aggregateID string, version int, giftCardID string) *common.Event {
+	return common.NewEvent(EventTypeGiftCardRemoved, aggregateID, version, map[string]interface{}{"gift_card_id": giftCardID}, nil)
+}
+
+// NewCartCheckedOutEvent creates a new CartCheckedOut event
+func NewCartCheckedOutEvent(aggregateID string, version int) *common.Event {
+	return common.NewEvent(EventTypeCartCheckedOut, aggregateID, version, nil, nil)
+}
+
+// NewCartExpiredEvent creates a new CartExpired event
+func NewCartExpiredEvent(aggregateID string, version int) *common.Event {
+	return common.NewEvent(EventTypeCartExpired, aggregateID, version, nil, nil)
+}
+
+// NewCartDeletedEvent creates a new CartDeleted event
+func NewCartDeletedEvent(aggregateID string, version int) *common.Event {
+	return common.NewEvent(EventTypeCartDeleted, aggregateID, version, nil, nil)
+}
+
+// NewCartRestoredEvent creates a new CartRestored event
+func NewCartRestoredEvent(aggregateID string, version int) *common.Event {
+	return common.NewEvent(EventTypeCartRestored, aggregateID, version, nil, nil)
+}
+
+// NewItemPriceChangedEvent creates a new ItemPriceChanged event for the catalog
+// prices stream
+func NewItemPriceChangedEvent(version int, itemID string, price float64) *common.Event {
+	data := map[string]interface{}{
+		"item":  itemID,
+		"price": price,
+	}
+	return common.NewEvent(EventTypeItemPriceChanged, CatalogPricesStream, version, data, nil)
+}
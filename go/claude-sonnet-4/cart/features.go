@@ -0,0 +1,11 @@
+// Package cart provides the feature flags gating cart business rules.
+package cart
+
+// FlagQuantityAwareAdd enables the quantity-aware add path: AddItem adds the
+// command's Quantity units at once, checked against MaxItems together, instead of
+// a single unit
+const FlagQuantityAwareAdd = "cart.quantity-aware-add"
+
+// FeatureFlags lists the feature flags consulted by the cart, to resolve with
+// bus.FeatureFlags
+var FeatureFlags = []string{FlagQuantityAwareAdd}
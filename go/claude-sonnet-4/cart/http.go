@@ -0,0 +1,196 @@
+// Package cart exposes the cart commands and queries over the HTTP API.
+package cart
+
+import (
+	"context"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+	"simple-event-modeling/httpapi"
+)
+
+// CartItemsParams are the parameters of the cart-items HTTP query
+type CartItemsParams struct {
+	CartID string `json:"cart_id"`
+}
+
+// RelatedItemsParams are the parameters of the related-items HTTP query
+type RelatedItemsParams struct {
+	ItemID string `json:"item_id"`
+	TopN   int    `json:"top_n,omitempty"`
+}
+
+// CartsByAttributeParams are the parameters of the carts-by-attribute HTTP query
+type CartsByAttributeParams struct {
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+}
+
+// TopItemsParams are the parameters of the top-items HTTP query
+type TopItemsParams struct {
+	Limit int `json:"limit,omitempty"`
+}
+
+// SearchCartsParams are the parameters of the cart-search HTTP query
+type SearchCartsParams struct {
+	Term string `json:"term"`
+}
+
+// RegisterRoutes exposes the cart commands and the cart-items, carts-by-attribute,
+// related-items, top-items and cart-search queries on an HTTP server.
+// Commands are dispatched through the bus, which must have the cart commands
+// registered; queries read from the store. Cart-items queries honor the consistency
+// token of the request and are tagged with the version of the cart's stream.
+func RegisterRoutes(server *httpapi.Server, commands *bus.CommandBus, store common.Store) {
+	handle := httpapi.CommandHandlerFunc(commands.Dispatch)
+
+	server.RegisterCommand(httpapi.CommandRoute{
+		Name:        CommandTypeCreateCart,
+		Description: "Create a new, empty cart",
+		Payload:     CreateCartCommand{},
+		Handler:     handle,
+	})
+	server.RegisterCommand(httpapi.CommandRoute{
+		Name:        CommandTypeAddItem,
+		Description: "Add an item to a cart, creating the cart when no ID is given",
+		Payload:     AddItemCommand{},
+		Handler:     handle,
+	})
+	server.RegisterCommand(httpapi.CommandRoute{
+		Name:        CommandTypeAddItems,
+		Description: "Add several items to a cart, all or none of them",
+		Payload:     AddItemsCommand{},
+		Handler:     handle,
+	})
+	server.RegisterCommand(httpapi.CommandRoute{
+		Name:        CommandTypeReorderCart,
+		Description: "Create a new cart holding the items of a checked-out cart",
+		Payload:     ReorderCartCommand{},
+		Handler:     handle,
+	})
+	server.RegisterCommand(httpapi.CommandRoute{
+		Name:        CommandTypeRemoveItem,
+		Description: "Remove one unit of an item from a cart",
+		Payload:     RemoveItemCommand{},
+		Handler:     handle,
+	})
+	server.RegisterCommand(httpapi.CommandRoute{
+		Name:        CommandTypeClearCart,
+		Description: "Remove every item from a cart",
+		Payload:     ClearCartCommand{},
+		Handler:     handle,
+	})
+	server.RegisterCommand(httpapi.CommandRoute{
+		Name:        CommandTypeAnnotateItem,
+		Description: "Attach a note and gift flag to a cart line",
+		Payload:     AnnotateItemCommand{},
+		Handler:     handle,
+	})
+	server.RegisterCommand(httpapi.CommandRoute{
+		Name:        CommandTypeRenameCart,
+		Description: "Give a cart a user-facing name",
+		Payload:     RenameCartCommand{},
+		Handler:     handle,
+	})
+	server.RegisterCommand(httpapi.CommandRoute{
+		Name:        CommandTypeSetCartAttribute,
+		Description: "Set or, with an empty value, remove an attribute of a cart",
+		Payload:     SetCartAttributeCommand{},
+		Handler:     handle,
+	})
+	server.RegisterCommand(httpapi.CommandRoute{
+		Name:        CommandTypeSelectShippingOption,
+		Description: "Choose how a cart is shipped, quoting the option's cost",
+		Payload:     SelectShippingOptionCommand{},
+		Handler:     handle,
+	})
+	server.RegisterCommand(httpapi.CommandRoute{
+		Name:        CommandTypeApplyGiftCard,
+		Description: "Pay part of a cart with a gift card, reserving its balance",
+		Payload:     ApplyGiftCardCommand{},
+		Handler:     handle,
+	})
+	server.RegisterCommand(httpapi.CommandRoute{
+		Name:        CommandTypeRemoveGiftCard,
+		Description: "Stop paying for a cart with a gift card, releasing its balance",
+		Payload:     RemoveGiftCardCommand{},
+		Handler:     handle,
+	})
+	server.RegisterCommand(httpapi.CommandRoute{
+		Name:        CommandTypeCheckoutCart,
+		Description: "Check out a cart, after which it accepts no more commands",
+		Payload:     CheckoutCartCommand{},
+		Handler:     handle,
+	})
+	server.RegisterCommand(httpapi.CommandRoute{
+		Name:        CommandTypeExpireCart,
+		Description: "Expire an abandoned cart, after which it accepts no more commands",
+		Payload:     ExpireCartCommand{},
+		Handler:     handle,
+	})
+	server.RegisterCommand(httpapi.CommandRoute{
+		Name:        CommandTypeDeleteCart,
+		Description: "Soft-delete a cart; it can be restored until the retention policy purges it",
+		Payload:     DeleteCartCommand{},
+		Handler:     handle,
+	})
+	server.RegisterCommand(httpapi.CommandRoute{
+		Name:        CommandTypeRestoreCart,
+		Description: "Restore a soft-deleted cart",
+		Payload:     RestoreCartCommand{},
+		Handler:     handle,
+	})
+
+	server.RegisterQuery(httpapi.QueryRoute{
+		Name:        CartItemsProjectionName,
+		Description: "Project the items and totals of a cart",
+		Params:      CartItemsParams{},
+		Result:      CartProjection{},
+		Handler: func(ctx context.Context, params interface{}) (interface{}, error) {
+			token, _ := common.ConsistencyTokenFrom(ctx)
+			return NewCartItemsQuery(params.(*CartItemsParams).CartID, store).ExecuteAtLeast(token)
+		},
+		Version: func(_ context.Context, params interface{}) (common.ConsistencyToken, error) {
+			cartID := params.(*CartItemsParams).CartID
+			return common.ConsistencyToken{StreamID: cartID, Version: store.GetStreamVersion(cartID)}, nil
+		},
+	})
+	server.RegisterQuery(httpapi.QueryRoute{
+		Name:        "carts-by-attribute",
+		Description: "List the carts with an attribute, optionally of a given value",
+		Params:      CartsByAttributeParams{},
+		Result:      []CartProjection{},
+		Handler: func(_ context.Context, params interface{}) (interface{}, error) {
+			p := params.(*CartsByAttributeParams)
+			return NewFindCartsByAttributeQuery(p.Key, p.Value, store).Execute()
+		},
+	})
+	server.RegisterQuery(httpapi.QueryRoute{
+		Name:        RelatedItemsProjectionName,
+		Description: "List the items most often added to the same carts as an item",
+		Params:      RelatedItemsParams{},
+		Result:      []RelatedItem{},
+		Handler: func(_ context.Context, params interface{}) (interface{}, error) {
+			p := params.(*RelatedItemsParams)
+			return NewRelatedItemsQuery(p.ItemID, p.TopN, store).Execute()
+		},
+	})
+	server.RegisterQuery(httpapi.QueryRoute{
+		Name:        TopItemsProjectionName,
+		Description: "List the most-added items, recent additions counting most",
+		Params:      TopItemsParams{},
+		Result:      []TopItem{},
+		Handler: func(_ context.Context, params interface{}) (interface{}, error) {
+			return NewTopItemsQuery(params.(*TopItemsParams).Limit, store).Execute()
+		},
+	})
+	server.RegisterQuery(httpapi.QueryRoute{
+		Name:        SearchProjectionName,
+		Description: "List the IDs of the carts whose items, notes, name or attributes match every word of a term",
+		Params:      SearchCartsParams{},
+		Result:      []string{},
+		Handler: func(_ context.Context, params interface{}) (interface{}, error) {
+			return NewSearchCartsQuery(params.(*SearchCartsParams).Term, store).Execute()
+		},
+	})
+}
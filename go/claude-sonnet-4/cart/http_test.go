@@ -0,0 +1,148 @@
+package cart
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+	"simple-event-modeling/httpapi"
+	"strings"
+	"testing"
+)
+
+// openAPIInfo is the metadata docs/openapi.json is generated with
+var openAPIInfo = httpapi.OpenAPIInfo{Title: "Cart API", Version: "1.0.0"}
+
+func TestRegisterRoutes_CommandsAndQuery(t *testing.T) {
+	store := common.NewEventStore()
+	commands := bus.NewCommandBus(bus.Validation())
+	RegisterCommands(commands, store)
+	server := httpapi.NewServer()
+	RegisterRoutes(server, commands, store)
+
+	post := func(name, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/commands/"+name, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := post("CreateCart", `{}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created common.Event
+	json.Unmarshal(rec.Body.Bytes(), &created)
+
+	rec = post("AddItem", `{"aggregate_id":"`+created.AggregateID+`","item_id":"apple"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	token := rec.Header().Get(httpapi.ConsistencyTokenHeader)
+
+	rec = post("RemoveItem", `{"aggregate_id":"`+created.AggregateID+`","item_id":"pear"}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for removing a missing item, got %d", rec.Code)
+	}
+
+	rec = post("ClearCart", `{"aggregate_id":"not-a-uuid"}`)
+	var response httpapi.ErrorResponse
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	if rec.Code != http.StatusUnprocessableEntity || response.Type != "ValidationError" {
+		t.Errorf("Expected 422 ValidationError, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(response.Fields) != 1 || response.Fields[0].Field != "aggregate_id" {
+		t.Errorf("Expected aggregate_id to be reported, got %+v", response.Fields)
+	}
+
+	query := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/queries/cart-items?cart_id="+created.AggregateID, nil)
+		req.Header.Set(httpapi.ConsistencyTokenHeader, token)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// A token from a write the store has not seen, as from a lagging replica
+	rec = query(created.AggregateID + "@3")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 for a write the store lacks, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = query(token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var projection CartProjection
+	json.Unmarshal(rec.Body.Bytes(), &projection)
+	if projection.Items["apple"] == nil || projection.Items["apple"].Quantity != 1 {
+		t.Errorf("Unexpected projection: %s", rec.Body.String())
+	}
+}
+
+func TestRegisterRoutes_ConditionalRequests(t *testing.T) {
+	store := common.NewEventStore()
+	commands := bus.NewCommandBus(bus.Validation())
+	RegisterCommands(commands, store)
+	server := httpapi.NewServer()
+	RegisterRoutes(server, commands, store)
+	cartID := "8b0e7e0a-3c55-4b52-9c1e-6f3b1e2d4a10"
+	store.Append(NewCartCreatedEvent(cartID))
+
+	send := func(method, target, body, header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(header, value)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec
+	}
+	query := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		return send(http.MethodGet, "/queries/cart-items?cart_id="+cartID, "", "If-None-Match", ifNoneMatch)
+	}
+	addItem := func(ifMatch string) *httptest.ResponseRecorder {
+		return send(http.MethodPost, "/commands/AddItem", `{"aggregate_id":"`+cartID+`","item_id":"apple"}`, "If-Match", ifMatch)
+	}
+
+	rec := query("")
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag != `"`+cartID+`@1"` {
+		t.Fatalf("Expected 200 tagged with version 1, got %d %q", rec.Code, etag)
+	}
+	if rec = query(etag); rec.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for an unchanged cart, got %d", rec.Code)
+	}
+
+	if rec = addItem(etag); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 for a current If-Match, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec = addItem(etag); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 for a stale If-Match, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if store.GetStreamVersion(cartID) != 2 {
+		t.Errorf("Expected only the first add to be stored, got version %d", store.GetStreamVersion(cartID))
+	}
+	if rec = query(etag); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 for a changed cart, got %d", rec.Code)
+	}
+}
+
+// TestOpenAPIDocIsCurrent keeps docs/openapi.json in sync with the cart HTTP routes.
+// Regenerate it with: go run ./cmd/sem openapi > docs/openapi.json
+func TestOpenAPIDocIsCurrent(t *testing.T) {
+	server := httpapi.NewServer()
+	RegisterRoutes(server, bus.NewCommandBus(), nil)
+
+	doc, err := os.ReadFile("../docs/openapi.json")
+	if err != nil {
+		t.Fatalf("Error reading OpenAPI doc: %v", err)
+	}
+	expected, err := server.OpenAPI(openAPIInfo).JSON()
+	if err != nil {
+		t.Fatalf("Error encoding document: %v", err)
+	}
+	if string(doc) != string(expected) {
+		t.Error("docs/openapi.json is out of date; regenerate it with `go run ./cmd/sem openapi > docs/openapi.json`")
+	}
+}
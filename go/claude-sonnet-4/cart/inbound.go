@@ -0,0 +1,46 @@
+// Package cart translates the messages of external systems the cart reacts to.
+package cart
+
+import (
+	"context"
+	"encoding/json"
+
+	"simple-event-modeling/common"
+	"simple-event-modeling/inbound"
+)
+
+// MessageTypeCatalogPriceChanged is the type of the catalog's price change messages
+const MessageTypeCatalogPriceChanged = "catalog.price_changed"
+
+// catalogPriceChanged is the catalog's schema of a price change. It stays here: the
+// cart records the change in its own terms, as an ItemPriceChanged event.
+type catalogPriceChanged struct {
+	SKU            string `json:"sku"`
+	UnitPriceCents *int64 `json:"unit_price_cents"`
+}
+
+// RegisterInbound registers the mappers of the external messages the cart reacts to
+func RegisterInbound(consumer *inbound.Consumer) {
+	consumer.Map(MessageTypeCatalogPriceChanged, mapCatalogPriceChanged)
+}
+
+// mapCatalogPriceChanged records a catalog price change on the catalog prices stream
+func mapCatalogPriceChanged(_ context.Context, msg inbound.Message) (inbound.Translation, error) {
+	var changed catalogPriceChanged
+	if err := json.Unmarshal(msg.Data, &changed); err != nil {
+		return inbound.Translation{}, &common.ValidationError{Fields: []common.FieldError{{Field: "data", Message: err.Error()}}}
+	}
+	var fields []common.FieldError
+	if changed.SKU == "" {
+		fields = append(fields, common.FieldError{Field: "sku", Message: "is required"})
+	}
+	if changed.UnitPriceCents == nil || *changed.UnitPriceCents < 0 {
+		fields = append(fields, common.FieldError{Field: "unit_price_cents", Message: "must be a non-negative integer"})
+	}
+	if len(fields) > 0 {
+		return inbound.Translation{}, &common.ValidationError{Fields: fields}
+	}
+
+	price := float64(*changed.UnitPriceCents) / 100
+	return inbound.Translation{Events: []*common.Event{NewItemPriceChangedEvent(0, changed.SKU, price)}}, nil
+}
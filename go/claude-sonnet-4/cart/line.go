@@ -0,0 +1,67 @@
+// Package cart provides CartLine, the child entity holding one line of a cart.
+package cart
+
+import "simple-event-modeling/common"
+
+// CartLine is a line of a cart: one item in one combination of variant options,
+// keyed by LineKey. The cart routes the item events of a line to it.
+type CartLine struct {
+	Key      string            `json:"key"`
+	Item     string            `json:"item"`
+	Options  map[string]string `json:"options,omitempty"`
+	Quantity int               `json:"quantity"`
+	Note     string            `json:"note,omitempty"`
+	Gift     bool              `json:"gift,omitempty"`
+}
+
+// NewCartLine creates an empty line with the given key
+func NewCartLine(key string) *CartLine {
+	return &CartLine{Key: key}
+}
+
+// OnItemAdded adds a unit to the line
+func (l *CartLine) OnItemAdded(data ItemData) {
+	l.Item = data.Item
+	l.Options = data.Options
+	l.Quantity++
+}
+
+// OnItemRemoved removes a unit from the line
+func (l *CartLine) OnItemRemoved(data ItemData) {
+	if l.Quantity > 0 {
+		l.Quantity--
+	}
+}
+
+// OnItemAnnotated replaces the note and gift flag of the line
+func (l *CartLine) OnItemAnnotated(data AnnotationData) {
+	l.Note = data.Note
+	l.Gift = data.Gift
+}
+
+// Retired reports whether the line has no units left, which drops it from the cart
+func (l *CartLine) Retired() bool {
+	return l.Quantity <= 0
+}
+
+// cartLines routes item events to the line they concern
+var cartLines = common.MustEntityType(common.DefaultRegistry, NewCartLine, routeToLine)
+
+// routeToLine returns the key of the line an item event concerns
+func routeToLine(event *common.Event) (string, error) {
+	switch event.Type {
+	case EventTypeItemAdded, EventTypeItemRemoved:
+		item, err := common.AsTyped[ItemData](event)
+		if err != nil || item.Data.Item == "" {
+			return "", err
+		}
+		return item.Data.Line(), nil
+	case EventTypeItemAnnotated:
+		annotated, err := common.AsTyped[AnnotationData](event)
+		if err != nil || annotated.Data.Item == "" {
+			return "", err
+		}
+		return annotated.Data.Line(), nil
+	}
+	return "", nil
+}
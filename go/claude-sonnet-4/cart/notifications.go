@@ -0,0 +1,92 @@
+// Package cart provides the notifications telling customers about their carts.
+package cart
+
+import (
+	"context"
+	"errors"
+
+	"simple-event-modeling/common"
+	"simple-event-modeling/notifications"
+	"simple-event-modeling/rules"
+)
+
+// Templates of cart notifications
+const (
+	TemplateCartAbandoned    = "cart-abandoned"
+	TemplateCheckoutComplete = "checkout-complete"
+)
+
+// ContactsFunc returns where a customer wants to be notified
+type ContactsFunc func(ctx context.Context, customer string) ([]notifications.Recipient, error)
+
+// NotificationTemplates returns the default templates of cart notifications. They
+// are executed with "cart_id" and "items", the number of items in the cart.
+func NotificationTemplates() *notifications.Templates {
+	templates := notifications.NewTemplates()
+	for _, t := range []struct {
+		name, channel string
+		template      notifications.Template
+	}{
+		{TemplateCartAbandoned, notifications.ChannelEmail, notifications.Template{
+			Subject: "Your cart is waiting",
+			Body:    "Your cart {{.cart_id}} with {{.items}} item(s) expired before checkout. Add the items again whenever you are ready.",
+		}},
+		{TemplateCartAbandoned, notifications.ChannelSMS, notifications.Template{
+			Body: "Your cart with {{.items}} item(s) expired before checkout.",
+		}},
+		{TemplateCheckoutComplete, notifications.ChannelEmail, notifications.Template{
+			Subject: "Thanks for your order",
+			Body:    "Your cart {{.cart_id}} with {{.items}} item(s) is checked out.",
+		}},
+		{TemplateCheckoutComplete, notifications.ChannelSMS, notifications.Template{
+			Body: "Your order of {{.items}} item(s) is checked out.",
+		}},
+	} {
+		if err := templates.Add(t.name, t.channel, t.template); err != nil {
+			panic(err)
+		}
+	}
+	return templates
+}
+
+// AddNotificationRules adds the rules notifying a cart's customer, the actor who
+// created it (see bus.ActorMetadata), when the cart is checked out and when it
+// expires abandoned. Anonymous carts are not notified. The engine's command bus
+// needs notifications.RegisterCommands with templates rendering
+// NotificationTemplates' names, e.g. NotificationTemplates itself.
+func AddNotificationRules(engine *rules.Engine, store common.Store, contacts ContactsFunc) error {
+	recipients := func(ctx context.Context, event *common.Event) ([]notifications.Recipient, error) {
+		customer, err := cartCustomer(store, event.AggregateID)
+		if err != nil || customer == "" {
+			return nil, err
+		}
+		return contacts(ctx, customer)
+	}
+	data := func(_ context.Context, event *common.Event) (map[string]interface{}, error) {
+		cart := NewCartAggregate(store)
+		if err := cart.HydrateUpTo(event.AggregateID, event.Version); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"cart_id": event.AggregateID, "items": cart.itemCount()}, nil
+	}
+
+	if err := engine.Add(TemplateCheckoutComplete, rules.When(EventTypeCartCheckedOut).
+		Then(notifications.Notify(TemplateCheckoutComplete, recipients, data))); err != nil {
+		return err
+	}
+	return engine.Add(TemplateCartAbandoned, rules.When(EventTypeCartExpired).
+		Then(notifications.Notify(TemplateCartAbandoned, recipients, data)))
+}
+
+// cartCustomer returns the actor who created a cart, or "" for anonymous carts
+func cartCustomer(store common.Store, cartID string) (string, error) {
+	events, err := store.GetStream(cartID)
+	if errors.Is(err, common.ErrStreamNotFound) || len(events) == 0 {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	customer, _ := events[0].Metadata[common.ActorIDKey].(string)
+	return customer, nil
+}
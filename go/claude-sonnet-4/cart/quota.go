@@ -0,0 +1,139 @@
+// Package cart provides the quotas limiting what each customer may do with carts.
+package cart
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+	"simple-event-modeling/quota"
+)
+
+// Names of the customer quotas, as reported by *quota.QuotaExceededError
+const (
+	QuotaOpenCarts   = "open-carts"
+	QuotaItemsPerDay = "items-per-day"
+)
+
+// Default customer quotas
+const (
+	DefaultMaxOpenCarts   = 5
+	DefaultMaxItemsPerDay = 100
+)
+
+// CustomerQuotas sets the limits CustomerQuotaMiddleware enforces on each customer
+type CustomerQuotas struct {
+	// MaxOpenCarts caps the carts a customer has open; 0 uses DefaultMaxOpenCarts
+	MaxOpenCarts int
+	// MaxItemsPerDay caps the items a customer adds each UTC day; 0 uses
+	// DefaultMaxItemsPerDay
+	MaxItemsPerDay int
+	// Entitlement, when set, returns a customer's own quotas, e.g. from their plan.
+	// Its zero fields keep the limits above.
+	Entitlement func(ctx context.Context, customer string) (CustomerQuotas, bool)
+	// Now returns the current time; nil uses time.Now
+	Now func() time.Time
+}
+
+// CustomerQuotaMiddleware returns middleware enforcing quotas on each customer, the
+// principal dispatching a command (see bus.WithPrincipal). Commands opening a cart
+// (CreateCart, ReorderCart, RestoreCart, and AddItem without a cart) count against
+// the open carts quota; AddItem and AddItems count their items against the daily
+// items quota. A command over a quota is rejected with a *quota.QuotaExceededError.
+// Items copied by ReorderCart and commands without a principal are not limited.
+//
+// Usage is read from usage, which must feed a CustomerUsageProjection, e.g.
+// common.NewAsyncProjection(store, NewCustomerUsageProjection()); it is caught up
+// before every check. Carts are attributed to customers by the actor metadata of
+// their events, so the bus must also use bus.ActorMetadata.
+func CustomerQuotaMiddleware(usage *common.AsyncProjection, quotas CustomerQuotas) bus.Middleware {
+	if quotas.MaxOpenCarts == 0 {
+		quotas.MaxOpenCarts = DefaultMaxOpenCarts
+	}
+	if quotas.MaxItemsPerDay == 0 {
+		quotas.MaxItemsPerDay = DefaultMaxItemsPerDay
+	}
+	customer := func(ctx context.Context, _ common.Command) string {
+		principal, _ := bus.PrincipalFrom(ctx)
+		return principal.ID
+	}
+	entitlement := func(limit func(CustomerQuotas) int) func(context.Context, string) (int, bool) {
+		if quotas.Entitlement == nil {
+			return nil
+		}
+		return func(ctx context.Context, key string) (int, bool) {
+			entitled, ok := quotas.Entitlement(ctx, key)
+			if !ok || limit(entitled) == 0 {
+				return 0, false
+			}
+			return limit(entitled), true
+		}
+	}
+
+	return quota.Middleware(
+		quota.Limit{
+			Quota: QuotaOpenCarts,
+			Max:   quotas.MaxOpenCarts,
+			Key:   customer,
+			Cost: func(command common.Command) int {
+				switch command := command.(type) {
+				case *CreateCartCommand, *ReorderCartCommand, *RestoreCartCommand:
+					return 1
+				case *AddItemCommand:
+					if command.CartID == "" {
+						return 1
+					}
+				}
+				return 0
+			},
+			Usage: readCustomerUsage(usage, func(p *CustomerUsageProjection, customer string, _ time.Time) int {
+				return p.OpenCarts(customer)
+			}),
+			Entitlement: entitlement(func(q CustomerQuotas) int { return q.MaxOpenCarts }),
+			Now:         quotas.Now,
+		},
+		quota.Limit{
+			Quota: QuotaItemsPerDay,
+			Max:   quotas.MaxItemsPerDay,
+			Key:   customer,
+			Cost: func(command common.Command) int {
+				switch command := command.(type) {
+				case *AddItemCommand:
+					return 1
+				case *AddItemsCommand:
+					return len(command.Lines)
+				}
+				return 0
+			},
+			Usage:       readCustomerUsage(usage, (*CustomerUsageProjection).ItemsAddedOn),
+			Entitlement: entitlement(func(q CustomerQuotas) int { return q.MaxItemsPerDay }),
+			Now:         quotas.Now,
+		},
+	)
+}
+
+// readCustomerUsage returns a quota.UsageFunc catching usage up and reading it with
+// read
+func readCustomerUsage(usage *common.AsyncProjection, read func(p *CustomerUsageProjection, customer string, now time.Time) int) quota.UsageFunc {
+	return func(ctx context.Context, customer string, now time.Time) (int, error) {
+		if err := usage.CatchUp(); err != nil {
+			return 0, err
+		}
+		var used int
+		var err error
+		readErr := usage.ExecuteAtLeast(ctx, common.ConsistencyToken{}, func(projection common.Projection) {
+			p, ok := projection.(*CustomerUsageProjection)
+			if !ok {
+				err = fmt.Errorf("customer quotas need a *CustomerUsageProjection, got %T", projection)
+				return
+			}
+			used = read(p, customer, now)
+		})
+		if readErr != nil {
+			return 0, readErr
+		}
+		return used, err
+	}
+}
@@ -0,0 +1,252 @@
+// Package cart registers the cart domain with the common registry so tooling
+// can discover its commands, events, and aggregate.
+package cart
+
+import "simple-event-modeling/common"
+
+// AggregateTypeCart is the registered name of the cart aggregate
+const AggregateTypeCart = "Cart"
+
+// AggregateTypeCatalog names the external catalog whose price changes the cart
+// records as integration events
+const AggregateTypeCatalog = "Catalog"
+
+// Stream categories: streams named "cart-<id>" hold cart events and
+// CatalogPricesStream holds catalog events
+const (
+	CategoryCart    = "cart"
+	CategoryCatalog = "catalog"
+)
+
+// Command type names
+const (
+	CommandTypeCreateCart = "CreateCart"
+	CommandTypeAddItem    = "AddItem"
+	// CommandTypeAddItems adds a batch of items atomically
+	CommandTypeAddItems = "AddItems"
+	// CommandTypeReorderCart creates a cart with the items of a checked-out one
+	CommandTypeReorderCart = "ReorderCart"
+	CommandTypeRemoveItem  = "RemoveItem"
+	CommandTypeClearCart   = "ClearCart"
+	// CommandTypeAnnotateItem attaches a note and gift flag to a cart line
+	CommandTypeAnnotateItem = "AnnotateItem"
+	// CommandTypeRenameCart and CommandTypeSetCartAttribute label the cart
+	CommandTypeRenameCart       = "RenameCart"
+	CommandTypeSetCartAttribute = "SetCartAttribute"
+	// CommandTypeSelectShippingOption chooses how the cart is shipped
+	CommandTypeSelectShippingOption = "SelectShippingOption"
+	// CommandTypeApplyGiftCard and CommandTypeRemoveGiftCard pay with gift cards
+	CommandTypeApplyGiftCard  = "ApplyGiftCard"
+	CommandTypeRemoveGiftCard = "RemoveGiftCard"
+	// CommandTypeConfirmGiftCard records that a gift card's balance is reserved
+	CommandTypeConfirmGiftCard = "ConfirmGiftCard"
+	// CommandTypeCheckoutCart closes the cart; it accepts no commands afterwards
+	CommandTypeCheckoutCart = "CheckoutCart"
+	// CommandTypeExpireCart closes an abandoned cart, like CheckoutCart
+	CommandTypeExpireCart  = "ExpireCart"
+	CommandTypeDeleteCart  = "DeleteCart"
+	CommandTypeRestoreCart = "RestoreCart"
+)
+
+func init() {
+	register(common.DefaultRegistry)
+}
+
+func register(registry *common.Registry) {
+	registry.RegisterAggregate(common.AggregateInfo{Name: AggregateTypeCart})
+	registry.RegisterCategory(common.CategoryInfo{Name: CategoryCart, Aggregate: AggregateTypeCart})
+	registry.RegisterCategory(common.CategoryInfo{Name: CategoryCatalog, Aggregate: AggregateTypeCatalog})
+
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeCreateCart,
+		Aggregate: AggregateTypeCart,
+		Produces:  []string{EventTypeCartCreated},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeAddItem,
+		Aggregate: AggregateTypeCart,
+		Produces:  []string{EventTypeCartCreated, EventTypeItemAdded},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeAddItems,
+		Aggregate: AggregateTypeCart,
+		Produces:  []string{EventTypeItemAdded},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeReorderCart,
+		Aggregate: AggregateTypeCart,
+		Produces:  []string{EventTypeCartCreated, EventTypeItemAdded},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeRemoveItem,
+		Aggregate: AggregateTypeCart,
+		Produces:  []string{EventTypeItemRemoved},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeClearCart,
+		Aggregate: AggregateTypeCart,
+		Produces:  []string{EventTypeCartCleared},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeAnnotateItem,
+		Aggregate: AggregateTypeCart,
+		Produces:  []string{EventTypeItemAnnotated},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeRenameCart,
+		Aggregate: AggregateTypeCart,
+		Produces:  []string{EventTypeCartRenamed},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeSetCartAttribute,
+		Aggregate: AggregateTypeCart,
+		Produces:  []string{EventTypeCartAttributeSet},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeSelectShippingOption,
+		Aggregate: AggregateTypeCart,
+		Produces:  []string{EventTypeShippingOptionSelected},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeApplyGiftCard,
+		Aggregate: AggregateTypeCart,
+		Produces:  []string{EventTypeGiftCardApplied},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeRemoveGiftCard,
+		Aggregate: AggregateTypeCart,
+		Produces:  []string{EventTypeGiftCardRemoved},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeConfirmGiftCard,
+		Aggregate: AggregateTypeCart,
+		Produces:  []string{EventTypeGiftCardConfirmed},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeCheckoutCart,
+		Aggregate: AggregateTypeCart,
+		Produces:  []string{EventTypeCartCheckedOut},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeExpireCart,
+		Aggregate: AggregateTypeCart,
+		Produces:  []string{EventTypeCartExpired},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeDeleteCart,
+		Aggregate: AggregateTypeCart,
+		Produces:  []string{EventTypeCartDeleted},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeRestoreCart,
+		Aggregate: AggregateTypeCart,
+		Produces:  []string{EventTypeCartRestored},
+	})
+
+	itemField := common.FieldInfo{Name: "item", Type: "string", Description: "ID of the item"}
+	optionsField := common.FieldInfo{
+		Name:        "options",
+		Type:        "object",
+		Description: "Variant attributes of the item, e.g. size and color; each combination is a separate cart line",
+		Optional:    true,
+	}
+	registry.RegisterEvent(common.EventInfo{Name: EventTypeCartCreated, Aggregate: AggregateTypeCart})
+	registry.RegisterEvent(common.EventInfo{
+		Name:      EventTypeItemAdded,
+		Aggregate: AggregateTypeCart,
+		Payload:   []common.FieldInfo{itemField, optionsField},
+	})
+	registry.RegisterEvent(common.EventInfo{
+		Name:      EventTypeItemRemoved,
+		Aggregate: AggregateTypeCart,
+		Payload:   []common.FieldInfo{itemField, optionsField},
+	})
+	registry.RegisterEvent(common.EventInfo{Name: EventTypeCartCleared, Aggregate: AggregateTypeCart})
+	registry.RegisterEvent(common.EventInfo{
+		Name:      EventTypeItemAnnotated,
+		Aggregate: AggregateTypeCart,
+		Payload: []common.FieldInfo{
+			itemField,
+			optionsField,
+			{Name: "note", Type: "string", Description: "Note for the line, e.g. a gift message; empty clears it"},
+			{Name: "gift", Type: "boolean", Description: "Whether the line is a gift"},
+		},
+	})
+	registry.RegisterEvent(common.EventInfo{
+		Name:      EventTypeCartRenamed,
+		Aggregate: AggregateTypeCart,
+		Payload:   []common.FieldInfo{{Name: "name", Type: "string", Description: "User-facing name of the cart"}},
+	})
+	registry.RegisterEvent(common.EventInfo{
+		Name:      EventTypeCartAttributeSet,
+		Aggregate: AggregateTypeCart,
+		Payload: []common.FieldInfo{
+			{Name: "key", Type: "string", Description: "Name of the attribute"},
+			{Name: "value", Type: "string", Description: "Value of the attribute; absent removes it", Optional: true},
+		},
+	})
+	registry.RegisterEvent(common.EventInfo{
+		Name:      EventTypeShippingOptionSelected,
+		Aggregate: AggregateTypeCart,
+		Payload: []common.FieldInfo{
+			{Name: "option", Type: "string", Description: "Shipping option, e.g. standard or express"},
+			{Name: "cost", Type: "number", Description: "Shipping cost quoted when the option was selected"},
+		},
+	})
+	giftCardField := common.FieldInfo{Name: "gift_card_id", Type: "string", Description: "ID of the gift card"}
+	registry.RegisterEvent(common.EventInfo{
+		Name:      EventTypeGiftCardApplied,
+		Aggregate: AggregateTypeCart,
+		Payload: []common.FieldInfo{
+			giftCardField,
+			{Name: "amount", Type: "number", Description: "Amount of the cart paid with the card"},
+		},
+	})
+	registry.RegisterEvent(common.EventInfo{Name: EventTypeGiftCardRemoved, Aggregate: AggregateTypeCart, Payload: []common.FieldInfo{giftCardField}})
+	registry.RegisterEvent(common.EventInfo{Name: EventTypeGiftCardConfirmed, Aggregate: AggregateTypeCart, Payload: []common.FieldInfo{giftCardField}})
+	registry.RegisterEvent(common.EventInfo{Name: EventTypeCartCheckedOut, Aggregate: AggregateTypeCart})
+	registry.RegisterEvent(common.EventInfo{Name: EventTypeCartExpired, Aggregate: AggregateTypeCart})
+	registry.RegisterEvent(common.EventInfo{Name: EventTypeCartDeleted, Aggregate: AggregateTypeCart})
+	registry.RegisterEvent(common.EventInfo{Name: EventTypeCartRestored, Aggregate: AggregateTypeCart})
+	common.RegisterPayload[ItemData](registry, EventTypeItemAdded)
+	common.RegisterPayload[ItemData](registry, EventTypeItemRemoved)
+	common.RegisterPayload[AnnotationData](registry, EventTypeItemAnnotated)
+	common.RegisterPayload[NameData](registry, EventTypeCartRenamed)
+	common.RegisterPayload[AttributeData](registry, EventTypeCartAttributeSet)
+	common.RegisterPayload[ShippingData](registry, EventTypeShippingOptionSelected)
+	common.RegisterPayload[GiftCardData](registry, EventTypeGiftCardApplied)
+	common.RegisterPayload[GiftCardData](registry, EventTypeGiftCardRemoved)
+	common.RegisterPayload[GiftCardData](registry, EventTypeGiftCardConfirmed)
+
+	registry.RegisterEvent(common.EventInfo{
+		Name:      EventTypeItemPriceChanged,
+		Aggregate: AggregateTypeCatalog,
+		Payload: []common.FieldInfo{
+			itemField,
+			{Name: "price", Type: "number", Description: "Unit price in the catalog"},
+		},
+	})
+	common.RegisterPayload[PriceData](registry, EventTypeItemPriceChanged)
+
+	registry.RegisterProjection(CartItemsProjectionName, func() common.Projection {
+		return NewCartItemsProjection()
+	})
+	registry.RegisterProjection(RelatedItemsProjectionName, func() common.Projection {
+		return NewRelatedItemsProjection()
+	})
+	registry.RegisterProjection(AbandonedCartsProjectionName, func() common.Projection {
+		return NewAbandonedCartsProjection()
+	})
+	registry.RegisterProjection(CustomerUsageProjectionName, func() common.Projection {
+		return NewCustomerUsageProjection()
+	})
+	registry.RegisterProjection(ItemsAddedPerHourProjectionName, func() common.Projection {
+		return NewItemsAddedPerHourProjection()
+	})
+	registry.RegisterProjection(TopItemsProjectionName, func() common.Projection {
+		return NewTopItemsProjection(DefaultTopItemsHalfLife)
+	})
+	registry.RegisterProjection(SearchProjectionName, func() common.Projection {
+		return NewSearchProjection()
+	})
+}
@@ -0,0 +1,198 @@
+// Package cart provides the frequently-added-together projection over all carts.
+package cart
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"simple-event-modeling/common"
+)
+
+// RelatedItemsProjectionName is the name the projection is registered under
+const RelatedItemsProjectionName = "related-items"
+
+// RelatedItem is an item added to the same carts as another, with the number of
+// carts both were added to
+type RelatedItem struct {
+	Item  string `json:"item"`
+	Count int    `json:"count"`
+}
+
+// RelatedItemsProjection counts how often items are added to the same cart. It is an
+// analytical read model: it folds the ItemAdded events of every cart in the global
+// event log rather than one cart's stream. A pair of items counts once per cart,
+// however often either is added, and keeps counting after items are removed,
+// since they were still added together. Variants count as their product.
+type RelatedItemsProjection struct {
+	carts    map[string]map[string]bool // cart ID -> products added
+	together map[string]map[string]int  // product -> product -> carts
+}
+
+// NewRelatedItemsProjection creates an empty related items projection
+func NewRelatedItemsProjection() *RelatedItemsProjection {
+	return &RelatedItemsProjection{
+		carts:    make(map[string]map[string]bool),
+		together: make(map[string]map[string]int),
+	}
+}
+
+// Name returns the registered name of the projection
+func (p *RelatedItemsProjection) Name() string {
+	return RelatedItemsProjectionName
+}
+
+// Consumes returns the event types the projection folds
+func (p *RelatedItemsProjection) Consumes() []string {
+	return []string{EventTypeItemAdded}
+}
+
+// On counts an added item together with every product added to its cart before;
+// other events are ignored
+func (p *RelatedItemsProjection) On(event *common.Event) error {
+	if event.Type != EventTypeItemAdded {
+		return nil
+	}
+	added, err := common.AsTyped[ItemData](event)
+	if err != nil {
+		return err
+	}
+	item := added.Data.Item
+	if item == "" {
+		return nil
+	}
+
+	products, exists := p.carts[event.AggregateID]
+	if !exists {
+		products = make(map[string]bool)
+		p.carts[event.AggregateID] = products
+	}
+	if products[item] {
+		return nil
+	}
+	for other := range products {
+		p.count(item, other)
+		p.count(other, item)
+	}
+	products[item] = true
+	return nil
+}
+
+func (p *RelatedItemsProjection) count(item, other string) {
+	if p.together[item] == nil {
+		p.together[item] = make(map[string]int)
+	}
+	p.together[item][other]++
+}
+
+// Related returns the items most often added to the same carts as itemID, most
+// frequent first and then by item ID, at most topN of them; topN <= 0 returns all
+func (p *RelatedItemsProjection) Related(itemID string, topN int) []RelatedItem {
+	related := make([]RelatedItem, 0, len(p.together[itemID]))
+	for other, count := range p.together[itemID] {
+		related = append(related, RelatedItem{Item: other, Count: count})
+	}
+	sort.Slice(related, func(i, j int) bool {
+		if related[i].Count != related[j].Count {
+			return related[i].Count > related[j].Count
+		}
+		return related[i].Item < related[j].Item
+	})
+	if topN > 0 && len(related) > topN {
+		related = related[:topN]
+	}
+	return related
+}
+
+// State returns the co-occurrence counts, keyed by item and then related item
+func (p *RelatedItemsProjection) State() interface{} {
+	return p.together
+}
+
+// Merge adds the counts of a related items projection built from other carts. See
+// common.PartitionSafe.
+func (p *RelatedItemsProjection) Merge(other common.Projection) error {
+	o, ok := other.(*RelatedItemsProjection)
+	if !ok {
+		return fmt.Errorf("cannot merge %T into a related items projection", other)
+	}
+	for cartID, products := range o.carts {
+		p.carts[cartID] = products
+	}
+	for item, related := range o.together {
+		for other, count := range related {
+			if p.together[item] == nil {
+				p.together[item] = make(map[string]int)
+			}
+			p.together[item][other] += count
+		}
+	}
+	return nil
+}
+
+// relatedItemsSnapshot is the state of a RelatedItemsProjection saved in a snapshot
+type relatedItemsSnapshot struct {
+	Carts    map[string][]string       `json:"carts"`
+	Together map[string]map[string]int `json:"together"`
+}
+
+// SnapshotState returns the products added to every cart and the co-occurrence
+// counts. See common.ProjectionSnapshotter.
+func (p *RelatedItemsProjection) SnapshotState() (interface{}, error) {
+	carts := make(map[string][]string, len(p.carts))
+	for cartID, products := range p.carts {
+		items := make([]string, 0, len(products))
+		for item := range products {
+			items = append(items, item)
+		}
+		sort.Strings(items)
+		carts[cartID] = items
+	}
+	return relatedItemsSnapshot{Carts: carts, Together: p.together}, nil
+}
+
+// RestoreSnapshot replaces the counts with a snapshot's. See
+// common.ProjectionSnapshotter.
+func (p *RelatedItemsProjection) RestoreSnapshot(snapshot common.Snapshot) error {
+	var state relatedItemsSnapshot
+	if err := json.Unmarshal(snapshot.State, &state); err != nil {
+		return err
+	}
+	p.carts = make(map[string]map[string]bool, len(state.Carts))
+	for cartID, items := range state.Carts {
+		products := make(map[string]bool, len(items))
+		for _, item := range items {
+			products[item] = true
+		}
+		p.carts[cartID] = products
+	}
+	p.together = state.Together
+	if p.together == nil {
+		p.together = make(map[string]map[string]int)
+	}
+	return nil
+}
+
+// RelatedItemsQuery asks for the items most often added to the same carts as an item
+type RelatedItemsQuery struct {
+	ItemID string
+	TopN   int
+	Store  common.Store
+}
+
+// NewRelatedItemsQuery creates a query for the topN items most often added together
+// with itemID
+func NewRelatedItemsQuery(itemID string, topN int, store common.Store) *RelatedItemsQuery {
+	return &RelatedItemsQuery{ItemID: itemID, TopN: topN, Store: store}
+}
+
+// Execute replays the global event log into a fresh projection and returns the
+// related items. Callers answering many queries keep a RelatedItemsProjection
+// caught up instead, e.g. with common.NewAsyncProjection.
+func (q *RelatedItemsQuery) Execute() ([]RelatedItem, error) {
+	projection := NewRelatedItemsProjection()
+	if _, err := common.ReplayProjection(q.Store, projection, 0, nil); err != nil {
+		return nil, err
+	}
+	return projection.Related(q.ItemID, q.TopN), nil
+}
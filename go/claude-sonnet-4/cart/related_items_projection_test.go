@@ -0,0 +1,110 @@
+package cart
+
+import (
+	"fmt"
+	"reflect"
+	"simple-event-modeling/common"
+	"testing"
+)
+
+func TestRelatedItemsProjection_CountsItemsAddedTogether(t *testing.T) {
+	store := common.NewEventStore()
+	add := func(cartID string, items ...string) {
+		store.Append(NewCartCreatedEvent(cartID))
+		for i, item := range items {
+			store.Append(NewItemAddedEvent(cartID, i+2, item))
+		}
+	}
+	add("cart-1", "bread", "butter", "jam")
+	add("cart-2", "bread", "butter", "bread")
+	add("cart-3", "bread", "milk")
+	store.Append(NewVariantAddedEvent("cart-3", 4, "butter", map[string]string{"size": "large"}))
+	store.Append(NewItemRemovedEvent("cart-3", 5, "milk"))
+
+	projection := NewRelatedItemsProjection()
+	if _, err := common.ReplayProjection(store, projection, 0, nil); err != nil {
+		t.Fatalf("Error replaying projection: %v", err)
+	}
+	related := projection.Related("bread", 0)
+	want := []RelatedItem{{Item: "butter", Count: 3}, {Item: "jam", Count: 1}, {Item: "milk", Count: 1}}
+	if len(related) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, related)
+	}
+	for i := range want {
+		if related[i] != want[i] {
+			t.Errorf("Expected %v at %d, got %v", want[i], i, related[i])
+		}
+	}
+	if top := projection.Related("jam", 1); len(top) != 1 || top[0].Item != "bread" {
+		t.Errorf("Expected the top item ranked by ID among ties, got %v", top)
+	}
+
+	query, err := NewRelatedItemsQuery("butter", 1, store).Execute()
+	if err != nil {
+		t.Fatalf("Error executing query: %v", err)
+	}
+	if len(query) != 1 || query[0] != (RelatedItem{Item: "bread", Count: 3}) {
+		t.Errorf("Expected bread as butter's top related item, got %v", query)
+	}
+	if none := projection.Related("caviar", 5); len(none) != 0 {
+		t.Errorf("Expected no related items for an item never added, got %v", none)
+	}
+}
+
+func TestRelatedItemsProjection_ResumesFromSnapshot(t *testing.T) {
+	store := common.NewEventStore()
+	snapshots := common.NewStoreSnapshots(common.NewEventStore())
+	store.Append(NewItemAddedEvent("cart-1", 1, "bread"))
+	store.Append(NewItemAddedEvent("cart-1", 2, "butter"))
+	store.Append(NewItemAddedEvent("cart-2", 1, "bread"))
+
+	projection := NewRelatedItemsProjection()
+	checkpoint, err := common.ReplayProjection(store, projection, 0, nil)
+	if err != nil {
+		t.Fatalf("Error replaying projection: %v", err)
+	}
+	if _, err := common.SnapshotProjection(snapshots, projection, checkpoint); err != nil {
+		t.Fatalf("Error snapshotting projection: %v", err)
+	}
+	store.Append(NewItemAddedEvent("cart-2", 2, "butter"))
+	store.Append(NewItemAddedEvent("cart-1", 3, "bread"))
+
+	resumed := NewRelatedItemsProjection()
+	checkpoint, restored, err := common.RestoreProjection(snapshots, resumed)
+	if err != nil || !restored || checkpoint.Position != 3 {
+		t.Fatalf("Expected the snapshot at position 3, got %+v, %v (%v)", checkpoint, restored, err)
+	}
+	if _, err := common.ReplayProjection(store, resumed, checkpoint.Position, nil); err != nil {
+		t.Fatalf("Error replaying projection: %v", err)
+	}
+	// cart-1 already held bread, so adding it again counts nothing
+	if related := resumed.Related("bread", 0); len(related) != 1 || related[0] != (RelatedItem{Item: "butter", Count: 2}) {
+		t.Errorf("Expected butter added with bread to 2 carts, got %v", related)
+	}
+}
+
+func TestRelatedItemsProjection_RebuildsInParallel(t *testing.T) {
+	store := common.NewEventStore()
+	for c := 0; c < 30; c++ {
+		cartID := fmt.Sprintf("cart-%d", c)
+		for version := 1; version <= c%5+2; version++ {
+			store.Append(NewItemAddedEvent(cartID, version, fmt.Sprintf("item-%d", (c+version)%7)))
+		}
+	}
+	factory := func() common.Projection { return NewRelatedItemsProjection() }
+
+	sequential, _, err := common.RebuildParallel(store, factory, 1)
+	if err != nil {
+		t.Fatalf("Error rebuilding: %v", err)
+	}
+	parallel, checkpoint, err := common.RebuildParallel(store, factory, 4)
+	if err != nil {
+		t.Fatalf("Error rebuilding in parallel: %v", err)
+	}
+	if !reflect.DeepEqual(parallel.State(), sequential.State()) {
+		t.Errorf("Expected the parallel rebuild to match the sequential one, got %v and %v", parallel.State(), sequential.State())
+	}
+	if checkpoint.Position != len(store.GetAllEvents()) {
+		t.Errorf("Expected the checkpoint at the end of the log, got %+v", checkpoint)
+	}
+}
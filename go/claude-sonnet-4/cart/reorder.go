@@ -0,0 +1,69 @@
+// Package cart provides the translator ReorderCart fills a new cart with from the
+// stream of a checked-out cart.
+package cart
+
+import "simple-event-modeling/common"
+
+// ReorderedFromKey is the metadata key of a reordered cart's CartCreated event
+// naming the cart it was reordered from
+const ReorderedFromKey = "reordered_from"
+
+// ReorderTranslator translates the event stream of a checked-out cart into the
+// lines of a new cart. Translators may drop or substitute items, e.g. ones no longer
+// sold.
+type ReorderTranslator interface {
+	Translate(source []*common.Event) ([]ItemLine, error)
+}
+
+// ReorderTranslatorFunc adapts a function to a ReorderTranslator
+type ReorderTranslatorFunc func(source []*common.Event) ([]ItemLine, error)
+
+// Translate calls f
+func (f ReorderTranslatorFunc) Translate(source []*common.Event) ([]ItemLine, error) {
+	return f(source)
+}
+
+// SameItems is the default ReorderTranslator. It replays the source stream and
+// returns a line for every unit in the cart when it was checked out, in the order
+// the lines were first added. Notes, shipping and gift cards are not carried over.
+var SameItems ReorderTranslator = ReorderTranslatorFunc(sameItems)
+
+func sameItems(source []*common.Event) ([]ItemLine, error) {
+	order := make([]string, 0)
+	lines := make(map[string]ItemLine)
+	quantities := make(map[string]int)
+	for _, event := range source {
+		switch event.Type {
+		case EventTypeItemAdded, EventTypeItemRemoved:
+			changed, err := common.AsTyped[ItemData](event)
+			if err != nil {
+				return nil, err
+			}
+			if changed.Data.Item == "" {
+				continue
+			}
+			line := changed.Data.Line()
+			if event.Type == EventTypeItemRemoved {
+				if quantities[line] > 0 {
+					quantities[line]--
+				}
+				continue
+			}
+			if _, seen := lines[line]; !seen {
+				order = append(order, line)
+				lines[line] = ItemLine{ItemID: changed.Data.Item, Options: changed.Data.Options}
+			}
+			quantities[line]++
+		case EventTypeCartCleared:
+			quantities = make(map[string]int)
+		}
+	}
+
+	reordered := make([]ItemLine, 0)
+	for _, line := range order {
+		for i := 0; i < quantities[line]; i++ {
+			reordered = append(reordered, lines[line])
+		}
+	}
+	return reordered, nil
+}
@@ -0,0 +1,80 @@
+// Package cart provides the retention policy that hard-deletes soft-deleted carts.
+package cart
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"simple-event-modeling/common"
+)
+
+// DefaultDeletedRetention is how long a soft-deleted cart can be restored when
+// RetentionPolicy.After is unset
+const DefaultDeletedRetention = 30 * 24 * time.Hour
+
+// RetentionPolicy decides when soft-deleted carts are removed for good
+type RetentionPolicy struct {
+	// After is how long a cart stays restorable after DeleteCart; 0 uses
+	// DefaultDeletedRetention
+	After time.Duration
+	// Now returns the current time, defaulting to time.Now
+	Now func() time.Time
+}
+
+// PurgeDeleted hard-deletes the streams of carts whose latest event is a CartDeleted
+// older than the retention period, and returns their IDs. Purged carts can no
+// longer be restored and leave the global log. A cart restored while it runs is
+// kept: the stream is only deleted at the version that was checked. The store must
+// implement common.StreamDeleter.
+func (p RetentionPolicy) PurgeDeleted(store common.Store) ([]string, error) {
+	deleter, ok := store.(common.StreamDeleter)
+	if !ok {
+		return nil, fmt.Errorf("store %T cannot delete streams", store)
+	}
+	now := time.Now
+	if p.Now != nil {
+		now = p.Now
+	}
+	after := p.After
+	if after <= 0 {
+		after = DefaultDeletedRetention
+	}
+	cutoff := now().Add(-after)
+
+	// Only carts deleted before the cutoff can qualify
+	candidates := make([]string, 0)
+	for _, event := range store.GetAllEvents() {
+		if event.Type == EventTypeCartDeleted && event.CreatedAt.Before(cutoff) {
+			candidates = append(candidates, event.AggregateID)
+		}
+	}
+
+	purged := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, cartID := range candidates {
+		if seen[cartID] {
+			continue
+		}
+		seen[cartID] = true
+
+		latest, err := store.HeadEvent(cartID)
+		if err != nil {
+			return purged, err
+		}
+		// Restored since, or deleted again more recently
+		if latest.Type != EventTypeCartDeleted || !latest.CreatedAt.Before(cutoff) {
+			continue
+		}
+		if err := deleter.DeleteStream(cartID, latest.Version); err != nil {
+			var conflict *common.ConcurrencyError
+			if errors.As(err, &conflict) {
+				// Restored since the check
+				continue
+			}
+			return purged, err
+		}
+		purged = append(purged, cartID)
+	}
+	return purged, nil
+}
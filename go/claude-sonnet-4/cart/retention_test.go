@@ -0,0 +1,87 @@
+package cart
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"simple-event-modeling/common"
+)
+
+func TestRetentionPolicy_PurgeDeleted(t *testing.T) {
+	store := common.NewEventStore()
+	newCart := func() string {
+		created, _ := NewCartAggregate(store).Handle(&CreateCartCommand{})
+		return created.AggregateID
+	}
+	purgeable, restored, kept := newCart(), newCart(), newCart()
+	for _, cartID := range []string{purgeable, restored} {
+		if _, err := NewCartAggregate(store).Handle(&DeleteCartCommand{CartID: cartID}); err != nil {
+			t.Fatalf("Error deleting cart: %v", err)
+		}
+	}
+	NewCartAggregate(store).Handle(&RestoreCartCommand{CartID: restored})
+
+	// Nothing is old enough yet
+	policy := RetentionPolicy{After: time.Hour}
+	if purged, err := policy.PurgeDeleted(store); err != nil || len(purged) != 0 {
+		t.Errorf("Expected nothing purged within the retention period, got %v, %v", purged, err)
+	}
+
+	policy.Now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	purged, err := policy.PurgeDeleted(store)
+	if err != nil {
+		t.Fatalf("Error purging: %v", err)
+	}
+	if len(purged) != 1 || purged[0] != purgeable {
+		t.Errorf("Expected only the deleted cart purged, got %v", purged)
+	}
+	if _, err := store.GetStream(purgeable); !errors.Is(err, common.ErrStreamNotFound) {
+		t.Errorf("Expected the purged stream gone, got %v", err)
+	}
+	for _, cartID := range []string{restored, kept} {
+		if _, err := store.GetStream(cartID); err != nil {
+			t.Errorf("Expected cart %s kept, got %v", cartID, err)
+		}
+	}
+}
+
+// restoringStore restores a cart right after PurgeDeleted reads its head
+type restoringStore struct {
+	*common.EventStore
+	cartID string
+}
+
+func (s *restoringStore) HeadEvent(aggregateID string) (*common.Event, error) {
+	head, err := s.EventStore.HeadEvent(aggregateID)
+	if err == nil && aggregateID == s.cartID {
+		_, err = NewCartAggregate(s.EventStore).Handle(&RestoreCartCommand{CartID: aggregateID})
+	}
+	return head, err
+}
+
+func TestRetentionPolicy_KeepsCartRestoredWhilePurging(t *testing.T) {
+	events := common.NewEventStore()
+	created, _ := NewCartAggregate(events).Handle(&CreateCartCommand{})
+	if _, err := NewCartAggregate(events).Handle(&DeleteCartCommand{CartID: created.AggregateID}); err != nil {
+		t.Fatalf("Error deleting cart: %v", err)
+	}
+
+	store := &restoringStore{EventStore: events, cartID: created.AggregateID}
+	policy := RetentionPolicy{After: time.Hour, Now: func() time.Time { return time.Now().Add(2 * time.Hour) }}
+	purged, err := policy.PurgeDeleted(store)
+	if err != nil || len(purged) != 0 {
+		t.Errorf("Expected the restored cart skipped, got %v, %v", purged, err)
+	}
+	if latest, err := events.HeadEvent(created.AggregateID); err != nil || latest.Type != EventTypeCartRestored {
+		t.Errorf("Expected the restored cart kept, got %v (%v)", latest, err)
+	}
+}
+
+func TestRetentionPolicy_RequiresStreamDeleter(t *testing.T) {
+	// Embedding hides the in-memory store's DeleteStream
+	store := struct{ common.Store }{common.NewEventStore()}
+	if _, err := (RetentionPolicy{}).PurgeDeleted(store); err == nil {
+		t.Error("Expected an error for a store that cannot delete streams")
+	}
+}
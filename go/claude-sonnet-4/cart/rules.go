@@ -0,0 +1,23 @@
+// Package cart provides conditions for automation rules about carts.
+package cart
+
+import (
+	"context"
+
+	"simple-event-modeling/common"
+	"simple-event-modeling/rules"
+)
+
+// ItemCountIs is a rules.Condition holding for cart events after which the cart
+// holds exactly count items, e.g. to tell a customer their cart is almost full.
+// The cart is hydrated as of the event, so the condition gives the same answer
+// however late the rule is evaluated.
+func ItemCountIs(store common.Store, count int) rules.Condition {
+	return func(_ context.Context, event *common.Event) (bool, error) {
+		cart := NewCartAggregate(store)
+		if err := cart.HydrateUpTo(event.AggregateID, event.Version); err != nil {
+			return false, err
+		}
+		return cart.itemCount() == count, nil
+	}
+}
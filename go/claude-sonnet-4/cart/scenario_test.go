@@ -0,0 +1,23 @@
+package cart
+
+import (
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+	"simple-event-modeling/scenario"
+	"testing"
+)
+
+// TestScenarios runs the fixtures transcribed from the Ruby event model slice specs
+func TestScenarios(t *testing.T) {
+	scenario.RunDir(t, "testdata/scenarios", scenario.Domain{
+		Commands: map[string]func() common.Command{
+			CommandTypeCreateCart:           func() common.Command { return &CreateCartCommand{} },
+			CommandTypeAddItem:              func() common.Command { return &AddItemCommand{} },
+			CommandTypeRemoveItem:           func() common.Command { return &RemoveItemCommand{} },
+			CommandTypeClearCart:            func() common.Command { return &ClearCartCommand{} },
+			CommandTypeSelectShippingOption: func() common.Command { return &SelectShippingOptionCommand{} },
+			CommandTypeCheckoutCart:         func() common.Command { return &CheckoutCartCommand{} },
+		},
+		Register: func(commands *bus.CommandBus, store common.Store) { RegisterCommands(commands, store) },
+	})
+}
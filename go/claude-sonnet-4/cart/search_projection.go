@@ -0,0 +1,280 @@
+// Package cart provides the full-text search projection over all carts.
+package cart
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"simple-event-modeling/common"
+)
+
+// SearchProjectionName is the name the projection is registered under
+const SearchProjectionName = "cart-search"
+
+// searchDocument is the searchable text of one cart
+type searchDocument struct {
+	lines      map[string]int    // line key -> quantity
+	items      map[string]string // line key -> item ID
+	options    map[string]map[string]string
+	notes      map[string]string // line key -> note
+	name       string
+	attributes map[string]string
+	deleted    bool
+	terms      map[string]bool // terms the cart is indexed under
+}
+
+func newSearchDocument() *searchDocument {
+	return &searchDocument{
+		lines:      make(map[string]int),
+		items:      make(map[string]string),
+		options:    make(map[string]map[string]string),
+		notes:      make(map[string]string),
+		attributes: make(map[string]string),
+		terms:      make(map[string]bool),
+	}
+}
+
+// SearchProjection builds an in-memory inverted index of carts, a search read model
+// folded straight from the global event log. A cart is indexed under the item IDs,
+// variant option values and notes of the lines it holds, its name and its attribute
+// values; text is split into lowercase words, and item IDs are indexed whole as
+// well. Removing the last unit of a line or clearing the cart drops its terms, and
+// deleted carts are left out of results until they are restored.
+type SearchProjection struct {
+	carts map[string]*searchDocument
+	index map[string]map[string]bool // term -> cart IDs
+}
+
+// NewSearchProjection creates an empty search projection
+func NewSearchProjection() *SearchProjection {
+	return &SearchProjection{
+		carts: make(map[string]*searchDocument),
+		index: make(map[string]map[string]bool),
+	}
+}
+
+// Name returns the registered name of the projection
+func (p *SearchProjection) Name() string {
+	return SearchProjectionName
+}
+
+// Consumes returns the event types the projection folds
+func (p *SearchProjection) Consumes() []string {
+	return []string{
+		EventTypeItemAdded, EventTypeItemRemoved, EventTypeCartCleared, EventTypeItemAnnotated,
+		EventTypeCartRenamed, EventTypeCartAttributeSet, EventTypeCartDeleted, EventTypeCartRestored,
+	}
+}
+
+// On updates the searchable text of the event's cart and reindexes it; other events
+// are ignored
+func (p *SearchProjection) On(event *common.Event) error {
+	doc := p.carts[event.AggregateID]
+	if doc == nil {
+		doc = newSearchDocument()
+	}
+
+	switch event.Type {
+	case EventTypeItemAdded:
+		added, err := common.AsTyped[ItemData](event)
+		if err != nil {
+			return err
+		}
+		line := added.Data.Line()
+		doc.lines[line]++
+		doc.items[line] = added.Data.Item
+		doc.options[line] = added.Data.Options
+	case EventTypeItemRemoved:
+		removed, err := common.AsTyped[ItemData](event)
+		if err != nil {
+			return err
+		}
+		line := removed.Data.Line()
+		if doc.lines[line]--; doc.lines[line] <= 0 {
+			doc.dropLine(line)
+		}
+	case EventTypeCartCleared:
+		for line := range doc.lines {
+			doc.dropLine(line)
+		}
+	case EventTypeItemAnnotated:
+		annotated, err := common.AsTyped[AnnotationData](event)
+		if err != nil {
+			return err
+		}
+		doc.notes[annotated.Data.Line()] = annotated.Data.Note
+	case EventTypeCartRenamed:
+		renamed, err := common.AsTyped[NameData](event)
+		if err != nil {
+			return err
+		}
+		doc.name = renamed.Data.Name
+	case EventTypeCartAttributeSet:
+		attribute, err := common.AsTyped[AttributeData](event)
+		if err != nil {
+			return err
+		}
+		if attribute.Data.Value == "" {
+			delete(doc.attributes, attribute.Data.Key)
+		} else {
+			doc.attributes[attribute.Data.Key] = attribute.Data.Value
+		}
+	case EventTypeCartDeleted:
+		doc.deleted = true
+	case EventTypeCartRestored:
+		doc.deleted = false
+	default:
+		return nil
+	}
+
+	p.carts[event.AggregateID] = doc
+	p.reindex(event.AggregateID, doc)
+	return nil
+}
+
+func (d *searchDocument) dropLine(line string) {
+	delete(d.lines, line)
+	delete(d.items, line)
+	delete(d.options, line)
+	delete(d.notes, line)
+}
+
+// text returns the terms the cart is indexed under
+func (d *searchDocument) text() map[string]bool {
+	terms := make(map[string]bool)
+	add := func(text string) {
+		for _, term := range tokenize(text) {
+			terms[term] = true
+		}
+	}
+	for line := range d.lines {
+		terms[strings.ToLower(d.items[line])] = true
+		add(d.items[line])
+		for _, value := range d.options[line] {
+			add(value)
+		}
+		if note, annotated := d.notes[line]; annotated {
+			add(note)
+		}
+	}
+	add(d.name)
+	for _, value := range d.attributes {
+		add(value)
+	}
+	delete(terms, "")
+	return terms
+}
+
+// reindex moves the cart from the terms it no longer holds to the ones it gained
+func (p *SearchProjection) reindex(cartID string, doc *searchDocument) {
+	terms := doc.text()
+	for term := range doc.terms {
+		if terms[term] {
+			continue
+		}
+		delete(p.index[term], cartID)
+		if len(p.index[term]) == 0 {
+			delete(p.index, term)
+		}
+	}
+	for term := range terms {
+		if doc.terms[term] {
+			continue
+		}
+		if p.index[term] == nil {
+			p.index[term] = make(map[string]bool)
+		}
+		p.index[term][cartID] = true
+	}
+	doc.terms = terms
+}
+
+// SearchCarts returns the IDs of the carts matching every word of term, sorted;
+// a term without words matches no carts
+func (p *SearchProjection) SearchCarts(term string) []string {
+	words := tokenize(term)
+	if whole := strings.ToLower(strings.TrimSpace(term)); whole != "" && p.index[whole] != nil {
+		// An item ID matches whole even when it splits into other words
+		words = []string{whole}
+	}
+	if len(words) == 0 {
+		return []string{}
+	}
+
+	carts := []string{}
+	for cartID := range p.index[words[0]] {
+		if p.carts[cartID].deleted {
+			continue
+		}
+		matches := true
+		for _, word := range words[1:] {
+			if !p.index[word][cartID] {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			carts = append(carts, cartID)
+		}
+	}
+	sort.Strings(carts)
+	return carts
+}
+
+// Merge adds the carts of a search projection built from other carts to the index.
+// See common.PartitionSafe.
+func (p *SearchProjection) Merge(other common.Projection) error {
+	o, ok := other.(*SearchProjection)
+	if !ok {
+		return fmt.Errorf("cannot merge %T into a search projection", other)
+	}
+	for cartID, doc := range o.carts {
+		p.carts[cartID] = doc
+	}
+	for term, carts := range o.index {
+		if p.index[term] == nil {
+			p.index[term] = make(map[string]bool, len(carts))
+		}
+		for cartID := range carts {
+			p.index[term][cartID] = true
+		}
+	}
+	return nil
+}
+
+// State returns the inverted index, keyed by term and then cart ID
+func (p *SearchProjection) State() interface{} {
+	return p.index
+}
+
+// tokenize splits text into the lowercase words the search projection indexes,
+// breaking on anything but letters and digits
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// SearchCartsQuery asks for the carts matching a search term
+type SearchCartsQuery struct {
+	Term  string
+	Store common.Store
+}
+
+// NewSearchCartsQuery creates a query for the carts matching term
+func NewSearchCartsQuery(term string, store common.Store) *SearchCartsQuery {
+	return &SearchCartsQuery{Term: term, Store: store}
+}
+
+// Execute replays the global event log into a fresh projection and returns the
+// matching cart IDs. Callers answering many queries keep a SearchProjection caught
+// up instead, e.g. with common.NewAsyncProjection.
+func (q *SearchCartsQuery) Execute() ([]string, error) {
+	projection := NewSearchProjection()
+	if _, err := common.ReplayProjection(q.Store, projection, 0, nil); err != nil {
+		return nil, err
+	}
+	return projection.SearchCarts(q.Term), nil
+}
@@ -0,0 +1,99 @@
+package cart
+
+import (
+	"fmt"
+	"reflect"
+	"simple-event-modeling/common"
+	"testing"
+)
+
+func TestSearchProjection_IndexesCarts(t *testing.T) {
+	store := common.NewEventStore()
+	store.Append(NewCartCreatedEvent("cart-1"))
+	store.Append(NewItemAddedEvent("cart-1", 2, "sku-42"))
+	store.Append(NewVariantAddedEvent("cart-1", 3, "shirt", map[string]string{"color": "Red"}))
+	store.Append(NewItemAnnotatedEvent("cart-1", 4, "shirt", map[string]string{"color": "Red"}, "Birthday present", true))
+	store.Append(NewCartCreatedEvent("cart-2"))
+	store.Append(NewItemAddedEvent("cart-2", 2, "shirt"))
+	store.Append(NewCartRenamedEvent("cart-2", 3, "Weekend groceries"))
+	store.Append(NewCartAttributeSetEvent("cart-2", 4, "channel", "mobile-app"))
+	store.Append(NewCartCreatedEvent("cart-3"))
+	store.Append(NewItemAddedEvent("cart-3", 2, "sku-42"))
+	store.Append(NewItemAddedEvent("cart-3", 3, "sku-42"))
+	store.Append(NewItemRemovedEvent("cart-3", 4, "sku-42"))
+
+	projection := NewSearchProjection()
+	if _, err := common.ReplayProjection(store, projection, 0, nil); err != nil {
+		t.Fatalf("Error replaying projection: %v", err)
+	}
+	cases := map[string][]string{
+		"shirt":          {"cart-1", "cart-2"},
+		"SKU-42":         {"cart-1", "cart-3"},
+		"red shirt":      {"cart-1"},
+		"birthday":       {"cart-1"},
+		"groceries":      {"cart-2"},
+		"mobile":         {"cart-2"},
+		"shirt weekend":  {"cart-2"},
+		"caviar":         {},
+		"  ":             {},
+		"present caviar": {},
+	}
+	for term, want := range cases {
+		if got := projection.SearchCarts(term); !reflect.DeepEqual(got, want) {
+			t.Errorf("SearchCarts(%q): expected %v, got %v", term, want, got)
+		}
+	}
+
+	// Removing the last unit, clearing and deleting drop carts from the results
+	projection.On(NewItemRemovedEvent("cart-3", 5, "sku-42"))
+	projection.On(NewCartClearedEvent("cart-1", 5))
+	if got := projection.SearchCarts("sku-42"); len(got) != 0 {
+		t.Errorf("Expected no carts holding sku-42, got %v", got)
+	}
+	projection.On(NewCartDeletedEvent("cart-2", 5))
+	if got := projection.SearchCarts("shirt"); len(got) != 0 {
+		t.Errorf("Expected the deleted cart to be left out, got %v", got)
+	}
+	projection.On(NewCartRestoredEvent("cart-2", 6))
+	projection.On(NewCartAttributeSetEvent("cart-2", 7, "channel", ""))
+	if got := projection.SearchCarts("shirt"); !reflect.DeepEqual(got, []string{"cart-2"}) {
+		t.Errorf("Expected the restored cart to be found again, got %v", got)
+	}
+	if got := projection.SearchCarts("mobile"); len(got) != 0 {
+		t.Errorf("Expected the removed attribute to be unindexed, got %v", got)
+	}
+
+	query, err := NewSearchCartsQuery("birthday present", store).Execute()
+	if err != nil {
+		t.Fatalf("Error executing query: %v", err)
+	}
+	if !reflect.DeepEqual(query, []string{"cart-1"}) {
+		t.Errorf("Expected cart-1 from the query, got %v", query)
+	}
+}
+
+func TestSearchProjection_RebuildsInParallel(t *testing.T) {
+	store := common.NewEventStore()
+	for c := 0; c < 20; c++ {
+		cartID := fmt.Sprintf("cart-%d", c)
+		store.Append(NewCartCreatedEvent(cartID))
+		store.Append(NewItemAddedEvent(cartID, 2, fmt.Sprintf("sku-%d", c%4)))
+		store.Append(NewCartRenamedEvent(cartID, 3, fmt.Sprintf("list %d", c%3)))
+		if c%5 == 0 {
+			store.Append(NewCartDeletedEvent(cartID, 4))
+		}
+	}
+	factory := func() common.Projection { return NewSearchProjection() }
+
+	sequential, _, _ := common.RebuildParallel(store, factory, 1)
+	parallel, _, err := common.RebuildParallel(store, factory, 3)
+	if err != nil {
+		t.Fatalf("Error rebuilding in parallel: %v", err)
+	}
+	for _, term := range []string{"sku-1", "list 2", "sku-0 list"} {
+		want := sequential.(*SearchProjection).SearchCarts(term)
+		if got := parallel.(*SearchProjection).SearchCarts(term); !reflect.DeepEqual(got, want) || len(want) == 0 {
+			t.Errorf("SearchCarts(%q): expected %v, got %v", term, want, got)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+// Package cart provides the shipping rater port carts price shipping options with.
+package cart
+
+// ShippingRater quotes the cost of shipping a cart's items with a shipping option.
+// It reports false for options that can't ship the items. items maps line keys
+// (see LineKey) to quantities.
+type ShippingRater interface {
+	Rate(option string, items map[string]int) (cost float64, available bool, err error)
+}
+
+// FakeShippingRater is a ShippingRater for demos and tests charging a fixed cost
+// per option, whatever the items
+type FakeShippingRater map[string]float64
+
+// Rate returns the fixed cost of option, if the rater offers it
+func (r FakeShippingRater) Rate(option string, items map[string]int) (float64, bool, error) {
+	cost, available := r[option]
+	return cost, available, nil
+}
+
+// StandardShippingRates returns the rater of carts without a rater of their own
+// (see CartAggregate.SetShippingRater and WithShippingRater), charging fixed
+// standard and express rates
+func StandardShippingRates() ShippingRater {
+	return FakeShippingRater{"standard": 4.99, "express": 14.99}
+}
@@ -0,0 +1,44 @@
+// Package cart provides the tax calculators the cart read models total with.
+package cart
+
+import "math"
+
+// RegionKey is the event metadata key naming the tax region of a cart. It is read
+// from the cart's CartCreated event, e.g. when created through a bus whose context
+// carries common.WithEventMetadata(ctx, RegionKey, "EU").
+const RegionKey = "region"
+
+// TaxCalculator works out the tax due on a cart's total amount
+type TaxCalculator interface {
+	Tax(region string, amount float64) float64
+}
+
+// FlatRate taxes every region at the same rate, e.g. 0.2 for 20%
+type FlatRate float64
+
+// Tax returns amount taxed at the flat rate, rounded to cents
+func (r FlatRate) Tax(region string, amount float64) float64 {
+	return roundCents(amount * float64(r))
+}
+
+// RegionRates taxes each region at its own rate
+type RegionRates struct {
+	// Rates are the tax rates keyed by region
+	Rates map[string]float64
+	// Default is the rate of regions missing from Rates, including carts without
+	// a region
+	Default float64
+}
+
+// Tax returns amount taxed at the rate of region, rounded to cents
+func (r RegionRates) Tax(region string, amount float64) float64 {
+	rate, known := r.Rates[region]
+	if !known {
+		rate = r.Default
+	}
+	return roundCents(amount * rate)
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,83 @@
+package cart
+
+import (
+	"simple-event-modeling/common"
+	"testing"
+)
+
+func TestTaxCalculators(t *testing.T) {
+	if tax := FlatRate(0.2).Tax("EU", 9.99); tax != 2 {
+		t.Errorf("Expected a flat 20%% of 9.99 rounded to 2.00, got %v", tax)
+	}
+	rates := RegionRates{Rates: map[string]float64{"EU": 0.2, "US-OR": 0}, Default: 0.1}
+	for region, want := range map[string]float64{"EU": 2, "US-OR": 0, "": 1, "CA": 1} {
+		if tax := rates.Tax(region, 10); tax != want {
+			t.Errorf("Expected tax %v for region %q, got %v", want, region, tax)
+		}
+	}
+}
+
+func TestCartItemsProjection_TaxByRegion(t *testing.T) {
+	store := common.NewEventStore()
+	eu := NewCartCreatedEvent("cart-eu")
+	eu.Metadata = map[string]interface{}{RegionKey: "EU"}
+	store.Append(eu)
+	store.Append(NewItemAddedEvent("cart-eu", 2, "apple"))
+	store.Append(NewCartCreatedEvent("cart-none"))
+	store.Append(NewItemAddedEvent("cart-none", 2, "apple"))
+	store.Append(NewItemPriceChangedEvent(1, "apple", 10))
+
+	projection := NewCartItemsProjection()
+	if _, err := common.ReplayProjection(store, projection, 0, nil); err != nil {
+		t.Fatalf("Error replaying projection: %v", err)
+	}
+	if cart, _ := projection.Cart("cart-eu"); cart.Totals.TaxAmount != 0 || cart.Totals.GrandTotal != 10 {
+		t.Errorf("Expected an untaxed cart without a calculator, got %+v", cart.Totals)
+	}
+
+	projection.SetTaxCalculator(RegionRates{Rates: map[string]float64{"EU": 0.2}, Default: 0.05})
+	cart, _ := projection.Cart("cart-eu")
+	if cart.Region != "EU" || cart.Totals.TaxAmount != 2 || cart.Totals.GrandTotal != 12 {
+		t.Errorf("Expected EU tax of 2.00, got region %q and %+v", cart.Region, cart.Totals)
+	}
+	if cart, _ := projection.Cart("cart-none"); cart.Totals.TaxAmount != 0.5 || cart.Totals.GrandTotal != 10.5 {
+		t.Errorf("Expected the default rate for a cart without a region, got %+v", cart.Totals)
+	}
+
+	query := NewCartItemsQuery("cart-eu", store)
+	query.Tax = FlatRate(0.1)
+	view, err := query.Execute()
+	if err != nil {
+		t.Fatalf("Error executing query: %v", err)
+	}
+	// The query doesn't see catalog prices, so there is nothing to tax
+	if view.Region != "EU" || view.Totals.TaxAmount != 0 {
+		t.Errorf("Expected an unpriced EU cart, got region %q and %+v", view.Region, view.Totals)
+	}
+}
+
+func TestCartItemsProjection_ShippingIsNotTaxed(t *testing.T) {
+	store := common.NewEventStore()
+	store.Append(NewCartCreatedEvent("cart-1"))
+	store.Append(NewItemAddedEvent("cart-1", 2, "apple"))
+	store.Append(NewShippingOptionSelectedEvent("cart-1", 3, "standard", 4.99))
+	store.Append(NewItemPriceChangedEvent(1, "apple", 10))
+
+	projection := NewCartItemsProjection()
+	projection.SetTaxCalculator(FlatRate(0.2))
+	if _, err := common.ReplayProjection(store, projection, 0, nil); err != nil {
+		t.Fatalf("Error replaying projection: %v", err)
+	}
+	cart, _ := projection.Cart("cart-1")
+	if totals := cart.Totals; totals.TaxAmount != 2 || totals.ShippingAmount != 4.99 || totals.GrandTotal != 16.99 {
+		t.Errorf("Expected 10 + 2 tax + 4.99 shipping, got %+v", totals)
+	}
+
+	store.Append(NewCartClearedEvent("cart-1", 4))
+	if _, err := common.ReplayProjection(store, projection, 4, nil); err != nil {
+		t.Fatalf("Error replaying projection: %v", err)
+	}
+	if cart, _ := projection.Cart("cart-1"); cart.ShippingOption != "" || cart.Totals.GrandTotal != 0 {
+		t.Errorf("Expected clearing the cart to drop its shipping, got %q %+v", cart.ShippingOption, cart.Totals)
+	}
+}
@@ -0,0 +1,242 @@
+// Package cart provides the most-added items leaderboard with time decay.
+package cart
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"simple-event-modeling/common"
+)
+
+// TopItemsProjectionName is the name the projection is registered under
+const TopItemsProjectionName = "top-items"
+
+// DefaultTopItemsHalfLife is how long it takes an addition to count for half as much
+// in the leaderboard
+const DefaultTopItemsHalfLife = 24 * time.Hour
+
+// MinTopItemScore is the score below which Recompute drops an item from the
+// leaderboard, about seven half-lives after its only addition
+const MinTopItemScore = 0.01
+
+// maxTopItemWeight bounds the weight of a new addition relative to the reference
+// time before the scores are rebased, keeping them far from overflowing
+const maxTopItemWeight = 1 << 32
+
+// TopItem is an item on the leaderboard with its decayed score: every addition
+// counts 1 when it happens and halves every half-life after
+type TopItem struct {
+	Item  string  `json:"item"`
+	Score float64 `json:"score"`
+}
+
+// TopItemsProjection ranks the items added to any cart by how often, and how
+// recently, they were added. Scores decay exponentially with the age of each
+// addition, measured from the events' CreatedAt, so additions are folded in O(1):
+// each adds 2^((t - reference) / half-life) to the item's score as of a reference
+// time.
+//
+// Decay needs the passage of time as well as events, so the leaderboard is only
+// re-ranked by Recompute, which also rebases the scores to now and drops items that
+// decayed below MinTopItemScore. Top returns the leaderboard as of the last
+// Recompute; AsyncProjection recomputes after every catch-up. Since decay scales every
+// score alike, ranks don't depend on when Recompute runs, and rebuilds rank the same
+// items as the live projection, apart from items dropped when nearly zero.
+type TopItemsProjection struct {
+	halfLife    time.Duration
+	reference   time.Time
+	scores      map[string]float64 // item -> score as of reference
+	leaderboard []TopItem
+	asOf        time.Time
+}
+
+// NewTopItemsProjection creates an empty leaderboard whose scores halve every
+// halfLife; 0 uses DefaultTopItemsHalfLife
+func NewTopItemsProjection(halfLife time.Duration) *TopItemsProjection {
+	if halfLife <= 0 {
+		halfLife = DefaultTopItemsHalfLife
+	}
+	return &TopItemsProjection{
+		halfLife:    halfLife,
+		scores:      make(map[string]float64),
+		leaderboard: make([]TopItem, 0),
+	}
+}
+
+// Name returns the registered name of the projection
+func (p *TopItemsProjection) Name() string {
+	return TopItemsProjectionName
+}
+
+// Consumes returns the event types the projection folds
+func (p *TopItemsProjection) Consumes() []string {
+	return []string{EventTypeItemAdded}
+}
+
+// On adds the weight of an added item to its score; other events are ignored.
+// Variants count as their product.
+func (p *TopItemsProjection) On(event *common.Event) error {
+	if event.Type != EventTypeItemAdded {
+		return nil
+	}
+	added, err := common.AsTyped[ItemData](event)
+	if err != nil {
+		return err
+	}
+	item := added.Data.Item
+	if item == "" {
+		return nil
+	}
+
+	if p.reference.IsZero() {
+		p.reference = event.CreatedAt
+	}
+	weight := p.weight(event.CreatedAt)
+	if weight > maxTopItemWeight {
+		p.rebase(event.CreatedAt)
+		weight = 1
+	}
+	p.scores[item] += weight
+	return nil
+}
+
+// Recompute rebases the scores to now, drops the items that decayed below
+// MinTopItemScore and re-ranks the leaderboard
+func (p *TopItemsProjection) Recompute(now time.Time) error {
+	if !p.reference.IsZero() {
+		p.rebase(now)
+	}
+	leaderboard := make([]TopItem, 0, len(p.scores))
+	for item, score := range p.scores {
+		if score < MinTopItemScore {
+			delete(p.scores, item)
+			continue
+		}
+		leaderboard = append(leaderboard, TopItem{Item: item, Score: score})
+	}
+	sort.Slice(leaderboard, func(i, j int) bool {
+		if leaderboard[i].Score != leaderboard[j].Score {
+			return leaderboard[i].Score > leaderboard[j].Score
+		}
+		return leaderboard[i].Item < leaderboard[j].Item
+	})
+	p.leaderboard = leaderboard
+	p.asOf = now
+	return nil
+}
+
+// Top returns up to limit items of the leaderboard, highest score first; a limit of
+// 0 or less returns every item
+func (p *TopItemsProjection) Top(limit int) []TopItem {
+	if limit <= 0 || limit > len(p.leaderboard) {
+		limit = len(p.leaderboard)
+	}
+	top := make([]TopItem, limit)
+	copy(top, p.leaderboard)
+	return top
+}
+
+// AsOf returns the time of the last Recompute, which the scores of Top are as of
+func (p *TopItemsProjection) AsOf() time.Time {
+	return p.asOf
+}
+
+// State returns the leaderboard as of the last Recompute
+func (p *TopItemsProjection) State() interface{} {
+	return p.Top(0)
+}
+
+// topItemsSnapshot is the state of a TopItemsProjection saved in a snapshot
+type topItemsSnapshot struct {
+	HalfLife    time.Duration      `json:"half_life"`
+	Reference   time.Time          `json:"reference"`
+	Scores      map[string]float64 `json:"scores"`
+	Leaderboard []TopItem          `json:"leaderboard"`
+	AsOf        time.Time          `json:"as_of"`
+}
+
+// SnapshotState returns the scores with their reference time and the leaderboard of
+// the last Recompute. See common.ProjectionSnapshotter.
+func (p *TopItemsProjection) SnapshotState() (interface{}, error) {
+	return topItemsSnapshot{
+		HalfLife:    p.halfLife,
+		Reference:   p.reference,
+		Scores:      p.scores,
+		Leaderboard: p.leaderboard,
+		AsOf:        p.asOf,
+	}, nil
+}
+
+// RestoreSnapshot replaces the scores and leaderboard with a snapshot's. Snapshots
+// of a leaderboard decaying with another half-life are rejected, since its scores
+// don't carry over. See common.ProjectionSnapshotter.
+func (p *TopItemsProjection) RestoreSnapshot(snapshot common.Snapshot) error {
+	var state topItemsSnapshot
+	if err := json.Unmarshal(snapshot.State, &state); err != nil {
+		return err
+	}
+	if state.HalfLife != p.halfLife {
+		return fmt.Errorf("snapshot decays with a half-life of %s, not %s", state.HalfLife, p.halfLife)
+	}
+	p.reference = state.Reference
+	p.scores = state.Scores
+	if p.scores == nil {
+		p.scores = make(map[string]float64)
+	}
+	p.leaderboard = state.Leaderboard
+	if p.leaderboard == nil {
+		p.leaderboard = make([]TopItem, 0)
+	}
+	p.asOf = state.AsOf
+	return nil
+}
+
+// weight returns the weight of an addition at t relative to the reference time
+func (p *TopItemsProjection) weight(t time.Time) float64 {
+	return math.Exp2(float64(t.Sub(p.reference)) / float64(p.halfLife))
+}
+
+// rebase rescales the scores to be as of t
+func (p *TopItemsProjection) rebase(t time.Time) {
+	decay := 1 / p.weight(t)
+	for item := range p.scores {
+		p.scores[item] *= decay
+	}
+	p.reference = t
+}
+
+// TopItemsQuery asks for the most-added items, with decay
+type TopItemsQuery struct {
+	// Limit is the number of items returned; 0 or less returns every item
+	Limit int
+	// HalfLife is how fast additions decay; 0 uses DefaultTopItemsHalfLife
+	HalfLife time.Duration
+	// Now is the time scores are decayed to; zero uses time.Now
+	Now   time.Time
+	Store common.Store
+}
+
+// NewTopItemsQuery creates a query for the limit most-added items
+func NewTopItemsQuery(limit int, store common.Store) *TopItemsQuery {
+	return &TopItemsQuery{Limit: limit, Store: store}
+}
+
+// Execute replays the global event log into a fresh projection, recomputes it as of
+// Now and returns the leaderboard
+func (q *TopItemsQuery) Execute() ([]TopItem, error) {
+	projection := NewTopItemsProjection(q.HalfLife)
+	if _, err := common.ReplayProjection(q.Store, projection, 0, nil); err != nil {
+		return nil, err
+	}
+	now := q.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if err := projection.Recompute(now); err != nil {
+		return nil, err
+	}
+	return projection.Top(q.Limit), nil
+}
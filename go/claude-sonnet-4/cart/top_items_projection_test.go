@@ -0,0 +1,144 @@
+package cart
+
+import (
+	"math"
+	"simple-event-modeling/common"
+	"testing"
+	"time"
+)
+
+func TestTopItemsQuery_DecaysOlderAdditions(t *testing.T) {
+	store := common.NewEventStore()
+	base := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	at := func(event *common.Event, offset time.Duration) *common.Event {
+		event.CreatedAt = base.Add(offset)
+		return event
+	}
+
+	const first, second, third = "11111111-1111-4111-8111-111111111111", "22222222-2222-4222-8222-222222222222",
+		"33333333-3333-4333-8333-333333333333"
+	store.Append(at(NewItemAddedEvent(first, 1, "banana"), -10*time.Hour))
+	store.Append(at(NewItemAddedEvent(first, 2, "apple"), 0))
+	store.Append(at(NewItemAddedEvent(second, 1, "pear"), 2*time.Hour))
+	store.Append(at(NewVariantAddedEvent(third, 1, "pear", map[string]string{"size": "large"}), 2*time.Hour))
+	store.Append(at(NewItemAddedEvent(third, 2, "apple"), 3*time.Hour))
+	store.Append(at(NewItemRemovedEvent(third, 3, "apple"), 3*time.Hour))
+
+	query := NewTopItemsQuery(0, store)
+	query.HalfLife = time.Hour
+	query.Now = base.Add(3 * time.Hour)
+	top, err := query.Execute()
+	if err != nil {
+		t.Fatalf("Error executing query: %v", err)
+	}
+	// apple: 1/8 + 1, pear: 1/2 + 1/2, banana decayed below MinTopItemScore
+	if len(top) != 2 || top[0].Item != "apple" || math.Abs(top[0].Score-1.125) > 1e-9 || top[1].Item != "pear" || math.Abs(top[1].Score-1) > 1e-9 {
+		t.Fatalf("Expected apple then pear, got %+v", top)
+	}
+
+	query.Limit = 1
+	query.Now = base.Add(5 * time.Hour)
+	if top, _ := query.Execute(); len(top) != 1 || top[0].Item != "apple" || math.Abs(top[0].Score-1.125/4) > 1e-9 {
+		t.Errorf("Expected only apple, decayed two more half-lives, got %+v", top)
+	}
+}
+
+func TestTopItemsProjection_RecomputedWhileLive(t *testing.T) {
+	store := common.NewEventStore()
+	base := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	now := base
+	live := NewTopItemsProjection(time.Hour)
+	async := common.NewAsyncProjection(store, live)
+	async.Now = func() time.Time { return now }
+	add := func(cartID, item string, offset time.Duration) {
+		event := NewItemAddedEvent(cartID, store.GetStreamVersion(cartID)+1, item)
+		event.CreatedAt = base.Add(offset)
+		store.Append(event)
+	}
+
+	const cartID = "11111111-1111-4111-8111-111111111111"
+	add(cartID, "apple", 0)
+	add(cartID, "apple", 0)
+	async.CatchUp()
+	if top := live.Top(0); len(top) != 0 {
+		t.Errorf("Expected no leaderboard before a recompute, got %+v", top)
+	}
+	async.Recompute()
+	if top := live.Top(0); len(top) != 1 || top[0].Score != 2 || !live.AsOf().Equal(now) {
+		t.Errorf("Expected apple at 2, got %+v as of %v", top, live.AsOf())
+	}
+
+	// Far enough ahead that the scores are rebased while folding
+	now = base.Add(40 * time.Hour)
+	add(cartID, "pear", 40*time.Hour)
+	add(cartID, "pear", 40*time.Hour)
+	add(cartID, "apple", 40*time.Hour)
+	async.CatchUp()
+	async.Recompute()
+
+	query := NewTopItemsQuery(0, store)
+	query.HalfLife = time.Hour
+	query.Now = now
+	rebuilt, err := query.Execute()
+	if err != nil {
+		t.Fatalf("Error executing query: %v", err)
+	}
+	top := live.Top(0)
+	if len(top) != 2 || top[0].Item != "pear" || top[0].Score != 2 || top[1].Item != "apple" || math.Abs(top[1].Score-1) > 1e-9 {
+		t.Fatalf("Expected pear then apple, got %+v", top)
+	}
+	if len(rebuilt) != len(top) || rebuilt[0].Item != top[0].Item || rebuilt[1].Item != top[1].Item || math.Abs(rebuilt[1].Score-top[1].Score) > 1e-9 {
+		t.Errorf("Expected the rebuild to rank like the live projection, got %+v and %+v", rebuilt, top)
+	}
+}
+
+func TestTopItemsProjection_ResumesFromSnapshot(t *testing.T) {
+	store := common.NewEventStore()
+	snapshots := common.NewStoreSnapshots(common.NewEventStore())
+	base := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	const cartID = "11111111-1111-4111-8111-111111111111"
+	add := func(item string, offset time.Duration) {
+		event := NewItemAddedEvent(cartID, store.GetStreamVersion(cartID)+1, item)
+		event.CreatedAt = base.Add(offset)
+		store.Append(event)
+	}
+	add("apple", 0)
+	add("pear", time.Hour)
+
+	live := common.NewAsyncProjection(store, NewTopItemsProjection(time.Hour))
+	live.Snapshots = snapshots
+	live.Now = func() time.Time { return base.Add(time.Hour) }
+	if err := live.CatchUp(); err != nil {
+		t.Fatalf("Error catching up: %v", err)
+	}
+	if err := live.Recompute(); err != nil {
+		t.Fatalf("Error recomputing: %v", err)
+	}
+	if err := live.Snapshot(); err != nil {
+		t.Fatalf("Error snapshotting: %v", err)
+	}
+	add("apple", 2*time.Hour)
+
+	projection := NewTopItemsProjection(time.Hour)
+	resumed := common.NewAsyncProjection(store, projection)
+	resumed.Snapshots = snapshots
+	resumed.Now = func() time.Time { return base.Add(2 * time.Hour) }
+	if restored, err := resumed.Restore(); err != nil || !restored {
+		t.Fatalf("Expected the snapshot to be restored, got %v (%v)", restored, err)
+	}
+	if top := projection.Top(0); len(top) != 2 || top[0].Item != "pear" || top[0].Score != 1 {
+		t.Errorf("Expected the snapshot's leaderboard, got %+v", top)
+	}
+	resumed.CatchUp()
+	resumed.Recompute()
+	// apple: 1/4 + 1, pear: 1/2
+	if top := projection.Top(0); len(top) != 2 || top[0].Item != "apple" || math.Abs(top[0].Score-1.25) > 1e-9 || math.Abs(top[1].Score-0.5) > 1e-9 {
+		t.Errorf("Expected apple then pear, got %+v", top)
+	}
+
+	other := common.NewAsyncProjection(store, NewTopItemsProjection(24*time.Hour))
+	other.Snapshots = snapshots
+	if _, err := other.Restore(); err == nil {
+		t.Error("Expected a snapshot of another half-life to be rejected")
+	}
+}
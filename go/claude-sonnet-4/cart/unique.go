@@ -0,0 +1,37 @@
+// Package cart provides the rule allowing a customer one open cart at a time.
+package cart
+
+import (
+	"context"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+	"simple-event-modeling/unique"
+)
+
+// OpenCartIndex is the name of the index of open carts by customer
+const OpenCartIndex = "open-cart-per-customer"
+
+// OneOpenCartPerCustomer returns middleware allowing each customer, the principal
+// dispatching a command (see bus.WithPrincipal), a single open cart. Commands opening
+// a cart claim the customer's key in index: CreateCart, ReorderCart, RestoreCart, and
+// AddItem without a cart. A second open cart is rejected with a
+// *unique.KeyTakenError. Checking out, expiring, or deleting a cart releases the key.
+// Commands without a principal are not constrained.
+func OneOpenCartPerCustomer(index *unique.Index) bus.Middleware {
+	customer := func(ctx context.Context, _ common.Command) string {
+		principal, _ := bus.PrincipalFrom(ctx)
+		return principal.ID
+	}
+	return index.Middleware(map[string]unique.KeyFunc{
+		CommandTypeCreateCart:  customer,
+		CommandTypeReorderCart: customer,
+		CommandTypeRestoreCart: customer,
+		CommandTypeAddItem: func(ctx context.Context, command common.Command) string {
+			if add, ok := command.(*AddItemCommand); ok && add.CartID == "" {
+				return customer(ctx, command)
+			}
+			return ""
+		},
+	}, CommandTypeCheckoutCart, CommandTypeExpireCart, CommandTypeDeleteCart)
+}
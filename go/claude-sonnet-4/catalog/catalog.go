@@ -0,0 +1,110 @@
+// Package catalog builds a catalog of the event types registered in a common.Registry,
+// including their payload schemas, the aggregates and commands that produce them, and
+// the projections that consume them. The catalog renders as JSON for tooling and as
+// Markdown for humans.
+package catalog
+
+import (
+	"fmt"
+	"strings"
+
+	"simple-event-modeling/common"
+)
+
+// Catalog lists every registered event type
+type Catalog struct {
+	Events []Entry `json:"events"`
+}
+
+// Entry documents a single event type
+type Entry struct {
+	Name       string             `json:"name"`
+	Aggregate  string             `json:"aggregate"`
+	Payload    []common.FieldInfo `json:"payload"`
+	ProducedBy []string           `json:"produced_by"`
+	ConsumedBy []string           `json:"consumed_by"`
+}
+
+// Build collects the catalog from the registry
+func Build(registry *common.Registry) Catalog {
+	producers := make(map[string][]string)
+	for _, cmd := range registry.Commands() {
+		for _, event := range cmd.Produces {
+			producers[event] = append(producers[event], cmd.Name)
+		}
+	}
+
+	consumers := make(map[string][]string)
+	for _, name := range registry.ProjectionNames() {
+		projection, err := registry.NewProjection(name)
+		if err != nil {
+			continue
+		}
+		if consumer, ok := projection.(common.EventConsumer); ok {
+			for _, event := range consumer.Consumes() {
+				consumers[event] = append(consumers[event], name)
+			}
+		}
+	}
+
+	catalog := Catalog{Events: make([]Entry, 0)}
+	for _, event := range registry.Events() {
+		entry := Entry{
+			Name:       event.Name,
+			Aggregate:  event.Aggregate,
+			Payload:    event.Payload,
+			ProducedBy: producers[event.Name],
+			ConsumedBy: consumers[event.Name],
+		}
+		if entry.Payload == nil {
+			entry.Payload = []common.FieldInfo{}
+		}
+		if entry.ProducedBy == nil {
+			entry.ProducedBy = []string{}
+		}
+		if entry.ConsumedBy == nil {
+			entry.ConsumedBy = []string{}
+		}
+		catalog.Events = append(catalog.Events, entry)
+	}
+	return catalog
+}
+
+// Markdown renders the catalog as a Markdown document
+func (c Catalog) Markdown() string {
+	var b strings.Builder
+	b.WriteString("# Event Catalog\n\n")
+	b.WriteString("<!-- Generated by `sem catalog -format markdown`. Do not edit by hand. -->\n")
+
+	for _, entry := range c.Events {
+		fmt.Fprintf(&b, "\n## %s\n\n", entry.Name)
+		fmt.Fprintf(&b, "- **Aggregate:** %s\n", entry.Aggregate)
+		fmt.Fprintf(&b, "- **Produced by:** %s\n", listOrNone(entry.ProducedBy))
+		fmt.Fprintf(&b, "- **Consumed by:** %s\n", listOrNone(entry.ConsumedBy))
+
+		if len(entry.Payload) == 0 {
+			b.WriteString("\nNo payload.\n")
+			continue
+		}
+		b.WriteString("\n| Field | Type | Description |\n")
+		b.WriteString("|-------|------|-------------|\n")
+		for _, field := range entry.Payload {
+			fieldType := field.Type
+			if field.Optional {
+				fieldType += ", optional"
+			}
+			if field.Sensitive {
+				fieldType += ", sensitive"
+			}
+			fmt.Fprintf(&b, "| `%s` | %s | %s |\n", field.Name, fieldType, field.Description)
+		}
+	}
+	return b.String()
+}
+
+func listOrNone(items []string) string {
+	if len(items) == 0 {
+		return "none"
+	}
+	return strings.Join(items, ", ")
+}
@@ -0,0 +1,73 @@
+package catalog
+
+import (
+	"encoding/json"
+	"os"
+	"simple-event-modeling/common"
+	"strings"
+	"testing"
+
+	// Register the cart domain with common.DefaultRegistry
+	_ "simple-event-modeling/cart"
+)
+
+func TestBuild_CartEvents(t *testing.T) {
+	catalog := Build(common.DefaultRegistry)
+
+	var itemAdded *Entry
+	for i := range catalog.Events {
+		if catalog.Events[i].Name == "ItemAdded" {
+			itemAdded = &catalog.Events[i]
+		}
+	}
+	if itemAdded == nil {
+		t.Fatal("Expected ItemAdded in catalog")
+	}
+	if itemAdded.Aggregate != "Cart" {
+		t.Errorf("Expected aggregate Cart, got %s", itemAdded.Aggregate)
+	}
+	if len(itemAdded.Payload) != 2 || itemAdded.Payload[0].Name != "item" || !itemAdded.Payload[1].Optional {
+		t.Errorf("Unexpected payload: %+v", itemAdded.Payload)
+	}
+	if len(itemAdded.ProducedBy) != 3 || itemAdded.ProducedBy[0] != "AddItem" || itemAdded.ProducedBy[2] != "ReorderCart" {
+		t.Errorf("Unexpected producers: %v", itemAdded.ProducedBy)
+	}
+	if len(itemAdded.ConsumedBy) != 7 || itemAdded.ConsumedBy[0] != "abandoned-carts" || itemAdded.ConsumedBy[5] != "related-items" {
+		t.Errorf("Unexpected consumers: %v", itemAdded.ConsumedBy)
+	}
+}
+
+func TestCatalog_JSONUsesEmptyLists(t *testing.T) {
+	registry := common.NewRegistry()
+	registry.RegisterEvent(common.EventInfo{Name: "Orphaned", Aggregate: "Nothing"})
+
+	data, err := json.Marshal(Build(registry))
+	if err != nil {
+		t.Fatalf("Error encoding catalog: %v", err)
+	}
+	if !strings.Contains(string(data), `"produced_by":[]`) {
+		t.Errorf("Expected empty lists rather than null, got %s", data)
+	}
+}
+
+func TestCatalog_Markdown(t *testing.T) {
+	md := Build(common.DefaultRegistry).Markdown()
+
+	for _, want := range []string{"## ItemAdded", "- **Produced by:** AddItem", "| `item` | string |"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Expected Markdown to contain %q, got:\n%s", want, md)
+		}
+	}
+}
+
+// TestEventCatalogDocIsCurrent keeps docs/event_catalog.md in sync with the registered domains.
+// Regenerate it with: go run ./cmd/sem catalog -format markdown > docs/event_catalog.md
+func TestEventCatalogDocIsCurrent(t *testing.T) {
+	doc, err := os.ReadFile("../docs/event_catalog.md")
+	if err != nil {
+		t.Fatalf("Error reading event catalog doc: %v", err)
+	}
+	if string(doc) != Build(common.DefaultRegistry).Markdown() {
+		t.Error("docs/event_catalog.md is out of date; regenerate it with `go run ./cmd/sem catalog -format markdown > docs/event_catalog.md`")
+	}
+}
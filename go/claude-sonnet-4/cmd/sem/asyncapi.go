@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"io"
+
+	"simple-event-modeling/asyncapi"
+	"simple-event-modeling/common"
+)
+
+// printAsyncAPI writes the AsyncAPI document for the registered events
+func printAsyncAPI(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("asyncapi", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	title := flags.String("title", "Cart Events", "document title")
+	version := flags.String("version", "1.0.0", "API version")
+	server := flags.String("server", "", "broker URL")
+	protocol := flags.String("protocol", "kafka", "broker protocol")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	doc, err := asyncapi.Generate(common.DefaultRegistry, asyncapi.Config{
+		Title:     *title,
+		Version:   *version,
+		ServerURL: *server,
+		Protocol:  *protocol,
+	}).JSON()
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(doc)
+	return err
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"simple-event-modeling/catalog"
+	"simple-event-modeling/common"
+)
+
+// printCatalog writes the event catalog of the registered domains
+func printCatalog(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("catalog", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	format := flags.String("format", "json", "json or markdown")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	c := catalog.Build(common.DefaultRegistry)
+	switch *format {
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(c)
+	case "markdown":
+		_, err := fmt.Fprint(out, c.Markdown())
+		return err
+	default:
+		return fmt.Errorf("unknown catalog format %q", *format)
+	}
+}
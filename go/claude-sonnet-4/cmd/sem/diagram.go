@@ -0,0 +1,27 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"simple-event-modeling/common"
+	"simple-event-modeling/diagram"
+)
+
+// printDiagram writes an event model diagram of the registered domains
+func printDiagram(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("diagram", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	format := flags.String("format", string(diagram.FormatMermaid), "mermaid, plantuml, or markdown")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	doc, err := diagram.Generate(common.DefaultRegistry, diagram.Format(*format))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprint(out, doc)
+	return err
+}
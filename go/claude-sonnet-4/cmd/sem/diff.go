@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+
+	"simple-event-modeling/common"
+	"simple-event-modeling/semdiff"
+)
+
+// diffStream prints the events of a stream between two versions and, when a
+// projection is named, the changes they make to its state
+func diffStream(store common.Store, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	name := flags.String("projection", "", "name of the registered projection to diff the state of")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 3 {
+		return errUsage
+	}
+	id := flags.Arg(0)
+	from, fromErr := strconv.Atoi(flags.Arg(1))
+	to, toErr := strconv.Atoi(flags.Arg(2))
+	if fromErr != nil || toErr != nil || from < 0 || from > to {
+		return errUsage
+	}
+
+	events, err := semdiff.EventsBetween(store, id, from, to)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "events v%d..v%d of %s:\n", from, to, id)
+	for _, event := range events {
+		if err := printEvent(out, event); err != nil {
+			return err
+		}
+	}
+
+	if *name == "" {
+		return nil
+	}
+	if _, err := common.DefaultRegistry.NewProjection(*name); err != nil {
+		return err
+	}
+	changes, err := semdiff.StreamDiff(store, id, from, to, semdiff.ProjectionState(func() common.Projection {
+		projection, _ := common.DefaultRegistry.NewProjection(*name)
+		return projection
+	}))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "\n%s changes:\n%s\n", *name, semdiff.Format(changes))
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"simple-event-modeling/analytics"
+	"simple-event-modeling/common"
+)
+
+// exportEvents writes the event log as one flat CSV or Parquet file per event type
+func exportEvents(store common.Store, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	format := flags.String("format", string(analytics.FormatCSV), "file format: csv or parquet")
+	dir := flags.String("dir", "export", "directory to write the files to")
+	types := flags.String("types", "", "comma-separated event types to export (default: all)")
+	metadata := flags.String("metadata", "", "comma-separated metadata keys to export as columns")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return errUsage
+	}
+
+	var opts analytics.Options
+	if *types != "" {
+		opts.EventTypes = strings.Split(*types, ",")
+	}
+	if *metadata != "" {
+		opts.MetadataKeys = strings.Split(*metadata, ",")
+	}
+
+	paths, err := analytics.Export(store, *dir, analytics.Format(*format), opts)
+	for _, path := range paths {
+		fmt.Fprintln(out, path)
+	}
+	return err
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"flag"
+	"io"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/cart"
+	"simple-event-modeling/graphql"
+	"simple-event-modeling/httpapi"
+)
+
+// printGraphQL writes the GraphQL schema generated from the HTTP API's query routes
+func printGraphQL(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("graphql", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return errUsage
+	}
+
+	server := httpapi.NewServer()
+	cart.RegisterRoutes(server, bus.NewCommandBus(), nil)
+
+	_, err := io.WriteString(out, graphql.NewSchema(server.QueryRoutes()).SDL())
+	return err
+}
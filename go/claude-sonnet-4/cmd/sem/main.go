@@ -0,0 +1,147 @@
+// Command sem is the operational CLI for SimpleEventModeling stores.
+//
+// Usage:
+//
+//	sem [-store path] streams list
+//	sem [-store path] stream show <id>
+//	sem [-store path] tail [-from version] [-interval duration] <id>
+//	sem [-store path] diff [-projection name] <id> <from> <to>
+//	sem [-store path] redact <id> <version> <field>...
+//	sem [-store path] subject export [-metadata keys] [-fields fields] <subject-id>
+//	sem [-store path] export [-format csv|parquet] [-dir path] [-types types] [-metadata keys]
+//	sem [-store path] project list
+//	sem [-store path] project rebuild -projection name [-from position] [-workers n] [-checkpoint file] [-out file]
+//	sem [-store path] upcast dry-run [-format text|json] [-v]
+//	sem diagram [-format mermaid|plantuml|markdown]
+//	sem catalog [-format json|markdown]
+//	sem asyncapi [-title t] [-version v] [-server url] [-protocol p]
+//	sem openapi [-title t] [-version v]
+//	sem graphql
+//	sem new domain <name> -commands Create,AddLine -events OrderCreated,LineAdded [-dir path]
+//
+// The store defaults to the value of $SEM_STORE, falling back to ./events.jsonl.
+// A redis://host:port URL opens a Redis Streams store instead of an event file, and
+// bolt://path opens a bbolt database.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	// Domain packages register their projections with common.DefaultRegistry
+	_ "simple-event-modeling/cart"
+)
+
+const usage = `usage: sem [-store path] <command> [arguments]
+
+commands:
+  streams list             list streams with their versions
+  stream show <id>         print every event in a stream
+  tail [flags] <id>        follow a stream, printing events as they are appended
+  diff [flags] <id> <from> <to>
+                           print the events between two versions and the state changes
+  redact <id> <version> <field>...
+                           replace payload fields of a stored event, recording a $redaction audit event
+  subject export [flags] <subject-id>
+                           export every event concerning a data subject as JSON
+  export [flags]           write one flat CSV or Parquet file per event type for analytics
+  project list             list registered projections
+  project rebuild [flags]  replay the event log through a registered projection
+  upcast dry-run [flags]   report which stored events the upcasters would change or fail on
+  diagram [-format f]      print the event model diagram of the registered domains
+  catalog [-format f]      print the catalog of registered event types
+  asyncapi [flags]         print the AsyncAPI document for published events
+  openapi [flags]          print the OpenAPI document for the command/query HTTP API
+  graphql                  print the GraphQL schema of the HTTP API's queries
+  new domain <name> [flags]
+                           generate a domain package skeleton
+`
+
+// errUsage signals that the arguments didn't match any command
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := run(ctx, os.Args[1:], os.Stdout)
+	if errors.Is(err, errUsage) {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "sem:", err)
+		os.Exit(1)
+	}
+}
+
+// run parses global flags and dispatches to the requested command
+func run(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("sem", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	storePath := flags.String("store", defaultStorePath(), "path of the event store file, or a redis:// or bolt:// URL")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	args = flags.Args()
+	if len(args) < 1 {
+		return errUsage
+	}
+
+	// Commands that only inspect registered code don't need a store
+	switch args[0] {
+	case "diagram":
+		return printDiagram(args[1:], out)
+	case "catalog":
+		return printCatalog(args[1:], out)
+	case "asyncapi":
+		return printAsyncAPI(args[1:], out)
+	case "openapi":
+		return printOpenAPI(args[1:], out)
+	case "graphql":
+		return printGraphQL(args[1:], out)
+	case "new":
+		return newDomain(args[1:], out)
+	}
+
+	store, err := openStore(*storePath)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(args) == 2 && args[0] == "streams" && args[1] == "list":
+		return listStreams(store, out)
+	case len(args) == 3 && args[0] == "stream" && args[1] == "show":
+		return showStream(store, args[2], out)
+	case args[0] == "tail":
+		return tailStream(ctx, store, args[1:], out)
+	case args[0] == "diff":
+		return diffStream(store, args[1:], out)
+	case args[0] == "redact":
+		return redactEvent(store, args[1:], out)
+	case args[0] == "subject":
+		return runSubject(store, args[1:], out)
+	case args[0] == "export":
+		return exportEvents(store, args[1:], out)
+	case args[0] == "project":
+		return runProject(store, args[1:], out)
+	case args[0] == "upcast":
+		return runUpcast(store, args[1:], out)
+	default:
+		return errUsage
+	}
+}
+
+func defaultStorePath() string {
+	if path := os.Getenv("SEM_STORE"); path != "" {
+		return path
+	}
+	return "events.jsonl"
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"simple-event-modeling/boltstore"
+	"simple-event-modeling/common"
+	"simple-event-modeling/filestore"
+	"strings"
+	"testing"
+	"time"
+)
+
+func seededStorePath(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	store, err := filestore.Open(path)
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+	store.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+	store.Append(common.NewEvent("ItemAdded", "cart-1", 2, map[string]interface{}{"item": "apple"}, nil))
+	store.Append(common.NewEvent("CartCreated", "cart-2", 1, nil, nil))
+	return path
+}
+
+func TestRun_StreamsList(t *testing.T) {
+	path := seededStorePath(t)
+	var out bytes.Buffer
+
+	if err := run(context.Background(), []string{"-store", path, "streams", "list"}, &out); err != nil {
+		t.Fatalf("Error running command: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected header and 2 streams, got %q", out.String())
+	}
+	if !strings.HasPrefix(lines[1], "cart-1") || !strings.Contains(lines[1], "2") {
+		t.Errorf("Unexpected line for cart-1: %q", lines[1])
+	}
+}
+
+func TestRun_StreamShow(t *testing.T) {
+	path := seededStorePath(t)
+	var out bytes.Buffer
+
+	if err := run(context.Background(), []string{"-store", path, "stream", "show", "cart-1"}, &out); err != nil {
+		t.Fatalf("Error running command: %v", err)
+	}
+	if !strings.Contains(out.String(), "ItemAdded") || !strings.Contains(out.String(), `"item":"apple"`) {
+		t.Errorf("Expected ItemAdded event in output, got %q", out.String())
+	}
+
+	err := run(context.Background(), []string{"-store", path, "stream", "show", "missing"}, &out)
+	var notFound *common.StreamNotFoundError
+	if !errors.As(err, &notFound) {
+		t.Errorf("Expected StreamNotFoundError, got %v", err)
+	}
+}
+
+func TestRun_TailFollowsNewEvents(t *testing.T) {
+	path := seededStorePath(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		store, _ := filestore.Open(path)
+		store.Append(common.NewEvent("ItemAdded", "cart-1", 3, map[string]interface{}{"item": "pear"}, nil))
+	}()
+
+	var out bytes.Buffer
+	if err := run(ctx, []string{"-store", path, "tail", "-from", "2", "-interval", "5ms", "cart-1"}, &out); err != nil {
+		t.Fatalf("Error running command: %v", err)
+	}
+	if strings.Contains(out.String(), "apple") {
+		t.Errorf("Expected events up to -from to be skipped, got %q", out.String())
+	}
+	if !strings.Contains(out.String(), "pear") {
+		t.Errorf("Expected appended event in output, got %q", out.String())
+	}
+}
+
+func TestRun_InvalidUsage(t *testing.T) {
+	var out bytes.Buffer
+	err := run(context.Background(), []string{"-store", seededStorePath(t), "bogus"}, &out)
+	if !errors.Is(err, errUsage) {
+		t.Errorf("Expected usage error, got %v", err)
+	}
+}
+
+func TestRun_ProjectRebuild(t *testing.T) {
+	path := seededStorePath(t)
+	dir := t.TempDir()
+	checkpointPath := filepath.Join(dir, "checkpoint.json")
+	statePath := filepath.Join(dir, "state.json")
+	var out bytes.Buffer
+
+	args := []string{"-store", path, "project", "rebuild", "--projection", "cart-items", "--from", "0",
+		"-every", "1", "-checkpoint", checkpointPath, "-out", statePath}
+	if err := run(context.Background(), args, &out); err != nil {
+		t.Fatalf("Error running command: %v", err)
+	}
+	if !strings.Contains(out.String(), "3/3 events") || !strings.Contains(out.String(), "checkpoint: cart-items@3") {
+		t.Errorf("Expected progress and checkpoint output, got %q", out.String())
+	}
+
+	checkpoint, err := os.ReadFile(checkpointPath)
+	if err != nil {
+		t.Fatalf("Error reading checkpoint: %v", err)
+	}
+	if !strings.Contains(string(checkpoint), `"position": 3`) {
+		t.Errorf("Unexpected checkpoint file: %s", checkpoint)
+	}
+
+	state, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatalf("Error reading state: %v", err)
+	}
+	if !strings.Contains(string(state), `"apple"`) {
+		t.Errorf("Expected rebuilt read model to contain apple, got %s", state)
+	}
+
+	err = run(context.Background(), []string{"-store", path, "project", "rebuild", "-projection", "missing"}, &out)
+	if err == nil {
+		t.Error("Expected error for unregistered projection")
+	}
+
+	out.Reset()
+	args = []string{"-store", path, "project", "rebuild", "-projection", "cart-search", "-workers", "4", "-out", statePath}
+	if err := run(context.Background(), args, &out); err != nil {
+		t.Fatalf("Error running parallel rebuild: %v", err)
+	}
+	if !strings.Contains(out.String(), "with 4 workers") || !strings.Contains(out.String(), "checkpoint: cart-search@3") {
+		t.Errorf("Expected a parallel rebuild, got %q", out.String())
+	}
+	out.Reset()
+	args = []string{"-store", path, "project", "rebuild", "-projection", "cart-items", "-workers", "4"}
+	if err := run(context.Background(), args, &out); err != nil || !strings.Contains(out.String(), "not partition-safe") {
+		t.Errorf("Expected cart-items to be rebuilt sequentially, got %q (%v)", out.String(), err)
+	}
+	args = []string{"-store", path, "project", "rebuild", "-projection", "cart-search", "-workers", "4", "-from", "1"}
+	if err := run(context.Background(), args, &out); err == nil {
+		t.Error("Expected a parallel rebuild from a position to be rejected")
+	}
+}
+
+func TestRun_Diff(t *testing.T) {
+	path := seededStorePath(t)
+	var out bytes.Buffer
+
+	args := []string{"-store", path, "diff", "-projection", "cart-items", "cart-1", "1", "2"}
+	if err := run(context.Background(), args, &out); err != nil {
+		t.Fatalf("Error running command: %v", err)
+	}
+	if !strings.Contains(out.String(), "ItemAdded") || !strings.Contains(out.String(), `+ cart-1.items.apple: {"item":"apple","quantity":1}`) {
+		t.Errorf("Expected the added event and item in output, got %q", out.String())
+	}
+
+	if err := run(context.Background(), []string{"-store", path, "diff", "cart-1", "2", "1"}, &out); !errors.Is(err, errUsage) {
+		t.Errorf("Expected a reversed range to be a usage error, got %v", err)
+	}
+}
+
+func TestRun_UpcastDryRun(t *testing.T) {
+	path := seededStorePath(t)
+	var out bytes.Buffer
+
+	if err := run(context.Background(), []string{"-store", path, "upcast", "dry-run"}, &out); err != nil {
+		t.Fatalf("Error running command: %v", err)
+	}
+	if !strings.Contains(out.String(), "3 events scanned, 0 would change: ok") {
+		t.Errorf("Expected a clean report, got %q", out.String())
+	}
+}
+
+func TestRun_Redact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	store, err := boltstore.Open(path)
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+	store.Append(common.NewEvent("ItemAdded", "cart-1", 1, map[string]interface{}{"item": "apple"}, nil))
+	store.Close()
+
+	var out bytes.Buffer
+	if err := run(context.Background(), []string{"-store", "bolt://" + path, "redact", "cart-1", "1", "item"}, &out); err != nil {
+		t.Fatalf("Error running command: %v", err)
+	}
+	if !strings.Contains(out.String(), "$redactions v1") {
+		t.Errorf("Expected the audit event to be reported, got %q", out.String())
+	}
+
+	if err := run(context.Background(), []string{"-store", seededStorePath(t), "redact", "cart-1", "2", "item"}, &out); err == nil {
+		t.Error("Expected an error for a store without redaction support")
+	}
+}
+
+func TestRun_SubjectExport(t *testing.T) {
+	path := seededStorePath(t)
+	var out bytes.Buffer
+
+	if err := run(context.Background(), []string{"-store", path, "subject", "export", "cart-2"}, &out); err != nil {
+		t.Fatalf("Error running command: %v", err)
+	}
+	if !strings.Contains(out.String(), `"subject": "cart-2"`) || !strings.Contains(out.String(), `"CartCreated"`) {
+		t.Errorf("Expected the cart-2 events in the report, got %q", out.String())
+	}
+}
+
+func TestRun_Export(t *testing.T) {
+	path := seededStorePath(t)
+	dir := t.TempDir()
+	var out bytes.Buffer
+
+	args := []string{"-store", path, "export", "-format", "parquet", "-dir", dir, "-types", "ItemAdded"}
+	if err := run(context.Background(), args, &out); err != nil {
+		t.Fatalf("Error running command: %v", err)
+	}
+	exported := filepath.Join(dir, "ItemAdded.parquet")
+	if strings.TrimSpace(out.String()) != exported {
+		t.Errorf("Expected the written file to be listed, got %q", out.String())
+	}
+	if _, err := os.Stat(exported); err != nil {
+		t.Errorf("Expected %s to exist: %v", exported, err)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"simple-event-modeling/scaffold"
+)
+
+// newDomain generates a domain package skeleton:
+// sem new domain <name> -commands Create,AddLine -events OrderCreated,LineAdded [-dir path]
+func newDomain(args []string, out io.Writer) error {
+	if len(args) < 2 || args[0] != "domain" {
+		return errUsage
+	}
+	name := args[1]
+
+	flags := flag.NewFlagSet("new domain", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	commands := flags.String("commands", "", "comma-separated command names")
+	events := flags.String("events", "", "comma-separated event type names")
+	dir := flags.String("dir", ".", "directory to create the package in")
+	module := flags.String("module", "", "module path (detected from go.mod by default)")
+	if err := flags.Parse(args[2:]); err != nil {
+		return errUsage
+	}
+
+	created, err := scaffold.Generate(scaffold.Spec{
+		Name:     name,
+		Module:   *module,
+		Commands: splitList(*commands),
+		Events:   splitList(*events),
+	}, *dir)
+	for _, path := range created {
+		fmt.Fprintln(out, "created", path)
+	}
+	return err
+}
+
+func splitList(raw string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
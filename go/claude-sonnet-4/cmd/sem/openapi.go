@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"io"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/cart"
+	"simple-event-modeling/httpapi"
+)
+
+// printOpenAPI writes the OpenAPI document for the command/query HTTP API
+func printOpenAPI(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("openapi", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	title := flags.String("title", "Cart API", "document title")
+	version := flags.String("version", "1.0.0", "API version")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	server := httpapi.NewServer()
+	cart.RegisterRoutes(server, bus.NewCommandBus(), nil)
+
+	doc, err := server.OpenAPI(httpapi.OpenAPIInfo{Title: *title, Version: *version}).JSON()
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(doc)
+	return err
+}
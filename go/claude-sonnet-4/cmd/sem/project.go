@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"simple-event-modeling/common"
+)
+
+// runProject dispatches the "project" subcommands
+func runProject(store common.Store, args []string, out io.Writer) error {
+	if len(args) < 1 {
+		return errUsage
+	}
+	switch args[0] {
+	case "list":
+		for _, name := range common.DefaultRegistry.ProjectionNames() {
+			fmt.Fprintln(out, name)
+		}
+		return nil
+	case "rebuild":
+		return rebuildProjection(store, args[1:], out)
+	default:
+		return errUsage
+	}
+}
+
+// rebuildProjection replays the global log through a registered projection,
+// reporting progress and the final checkpoint. With more than one worker a
+// partition-safe projection is rebuilt from the beginning of the log in parallel.
+func rebuildProjection(store common.Store, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("project rebuild", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	name := flags.String("projection", "", "name of the registered projection")
+	from := flags.Int("from", 0, "global position to start after")
+	every := flags.Int("every", 1000, "report progress every N events")
+	checkpointPath := flags.String("checkpoint", "", "file to write the final checkpoint to")
+	statePath := flags.String("out", "", "file to write the rebuilt read model to")
+	workers := flags.Int("workers", 1, "rebuild a partition-safe projection with N parallel workers")
+	if err := flags.Parse(args); err != nil || *name == "" || *from < 0 || *every <= 0 || *workers < 1 {
+		return errUsage
+	}
+	if *workers > 1 && *from > 0 {
+		return fmt.Errorf("parallel rebuilds start from the beginning of the log: %w", errUsage)
+	}
+
+	projection, err := common.DefaultRegistry.NewProjection(*name)
+	if err != nil {
+		return err
+	}
+
+	var checkpoint common.Checkpoint
+	if _, partitionSafe := projection.(common.PartitionSafe); partitionSafe && *workers > 1 {
+		fmt.Fprintf(out, "rebuilding %s with %d workers\n", *name, *workers)
+		projection, checkpoint, err = common.RebuildParallel(store, func() common.Projection {
+			projection, _ := common.DefaultRegistry.NewProjection(*name)
+			return projection
+		}, *workers)
+		if err != nil {
+			return fmt.Errorf("rebuild failed: %w", err)
+		}
+	} else {
+		if *workers > 1 {
+			fmt.Fprintf(out, "%s is not partition-safe, rebuilding sequentially\n", *name)
+		}
+		fmt.Fprintf(out, "rebuilding %s from position %d\n", *name, *from)
+		checkpoint, err = common.ReplayProjection(store, projection, *from, func(checkpoint common.Checkpoint, total int) {
+			if checkpoint.Position%*every == 0 {
+				fmt.Fprintf(out, "  %d/%d events\n", checkpoint.Position, total)
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("rebuild stopped at position %d: %w", checkpoint.Position, err)
+		}
+	}
+	fmt.Fprintf(out, "checkpoint: %s@%d\n", checkpoint.Projection, checkpoint.Position)
+
+	if *checkpointPath != "" {
+		if err := writeJSONFile(*checkpointPath, checkpoint); err != nil {
+			return err
+		}
+	}
+	if *statePath != "" {
+		if err := writeJSONFile(*statePath, projection.State()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeJSONFile(path string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"simple-event-modeling/boltstore"
+	"simple-event-modeling/common"
+	"simple-event-modeling/filestore"
+	"simple-event-modeling/redisstore"
+)
+
+// openStore opens the store backend configured for the CLI: a Redis server for
+// redis://[:password@]host:port[/db][?prefix=p] URLs, a bbolt database for
+// bolt://path, otherwise an event file
+func openStore(path string) (common.Store, error) {
+	if strings.HasPrefix(path, "redis://") {
+		return openRedisStore(path)
+	}
+	if file, ok := strings.CutPrefix(path, "bolt://"); ok {
+		return boltstore.Open(file)
+	}
+	return filestore.Open(path)
+}
+
+func openRedisStore(rawURL string) (common.Store, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []redisstore.Option
+	if password, ok := u.User.Password(); ok {
+		opts = append(opts, redisstore.WithPassword(password))
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		n, err := strconv.Atoi(db)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, redisstore.WithDB(n))
+	}
+	if prefix := u.Query().Get("prefix"); prefix != "" {
+		opts = append(opts, redisstore.WithPrefix(prefix))
+	}
+	return redisstore.Open(u.Host, opts...)
+}
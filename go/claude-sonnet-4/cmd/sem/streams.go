@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"simple-event-modeling/common"
+)
+
+// listStreams prints one line per stream with its version and event count
+func listStreams(store common.Store, out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "STREAM\tVERSION\tEVENTS")
+	for _, id := range store.StreamIDs() {
+		fmt.Fprintf(w, "%s\t%d\t%d\n", id, store.GetStreamVersion(id), store.StreamLength(id))
+	}
+	return w.Flush()
+}
+
+// showStream prints every event in a stream
+func showStream(store common.Store, id string, out io.Writer) error {
+	events, err := store.GetStream(id)
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		if err := printEvent(out, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tailStream follows a stream until ctx is cancelled
+func tailStream(ctx context.Context, store common.Store, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("tail", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	from := flags.Int("from", 0, "only print events after this version")
+	interval := flags.Duration("interval", common.DefaultPollInterval, "how often to poll the store")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return errUsage
+	}
+
+	for event := range common.SubscribeStream(ctx, store, flags.Arg(0), *from, *interval) {
+		if err := printEvent(out, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printEvent(out io.Writer, event *common.Event) error {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "v%-4d %s  %-14s %s\n",
+		event.Version, event.CreatedAt.Format(time.RFC3339), event.Type, data)
+	return err
+}
+
+// redactEvent replaces payload fields of a stored event, for stores that support it
+func redactEvent(store common.Store, args []string, out io.Writer) error {
+	if len(args) < 3 {
+		return errUsage
+	}
+	version, err := strconv.Atoi(args[1])
+	if err != nil {
+		return errUsage
+	}
+	redactor, ok := store.(common.Redactor)
+	if !ok {
+		return fmt.Errorf("%T does not support redaction", store)
+	}
+
+	audit, err := redactor.RedactEvent(args[0], version, args[2:])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "redacted %s from %s v%d (audit event %s v%d)\n",
+		strings.Join(args[2:], ", "), args[0], version, common.RedactionStreamID, audit.Version)
+	return nil
+}
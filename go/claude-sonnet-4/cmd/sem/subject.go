@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"strings"
+
+	"simple-event-modeling/common"
+	"simple-event-modeling/gdpr"
+)
+
+// runSubject dispatches the "subject" subcommands
+func runSubject(store common.Store, args []string, out io.Writer) error {
+	if len(args) < 1 || args[0] != "export" {
+		return errUsage
+	}
+
+	flags := flag.NewFlagSet("subject export", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	metadata := flags.String("metadata", "", "comma-separated metadata keys holding subject IDs (default customer_id,actor_id)")
+	fields := flags.String("fields", "", "comma-separated payload fields to search (default: the whole payload)")
+	if err := flags.Parse(args[1:]); err != nil || flags.NArg() != 1 {
+		return errUsage
+	}
+
+	var opts gdpr.Options
+	if *metadata != "" {
+		opts.MetadataKeys = strings.Split(*metadata, ",")
+	}
+	if *fields != "" {
+		opts.DataFields = strings.Split(*fields, ",")
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(gdpr.Export(store, flags.Arg(0), opts))
+}
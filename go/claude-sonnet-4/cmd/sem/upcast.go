@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"simple-event-modeling/common"
+	"simple-event-modeling/upcast"
+)
+
+// runUpcast dispatches the "upcast" subcommands
+func runUpcast(store common.Store, args []string, out io.Writer) error {
+	if len(args) < 1 || args[0] != "dry-run" {
+		return errUsage
+	}
+
+	flags := flag.NewFlagSet("upcast dry-run", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	format := flags.String("format", "text", "text or json")
+	verbose := flags.Bool("v", false, "list the field changes of every event that would change")
+	if err := flags.Parse(args[1:]); err != nil {
+		return errUsage
+	}
+
+	report := upcast.DryRun(store, common.DefaultRegistry)
+	switch *format {
+	case "text":
+		if err := report.WriteText(out, *verbose); err != nil {
+			return err
+		}
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown report format %q", *format)
+	}
+
+	if !report.OK() {
+		return fmt.Errorf("upcasting would fail for some events")
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+// Command sembench runs the performance suite of package bench and prints the results
+// in benchstat format. Given a baseline from an earlier run it also reports the
+// benchmarks that slowed down by more than a threshold and exits with status 1.
+//
+// Usage:
+//
+//	sembench [-run regexp] [-count 1] [-benchtime 1s] [-o file] [-baseline file] [-threshold 0.1]
+//
+// Typical use records a baseline on the main branch and checks a change against it:
+//
+//	sembench -count 5 -o base.txt
+//	sembench -count 5 -o new.txt -baseline base.txt
+//	benchstat base.txt new.txt
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	"simple-event-modeling/bench"
+)
+
+// errUsage signals invalid command line arguments
+var errUsage = errors.New("invalid usage")
+
+// errRegressed signals that a benchmark regressed against the baseline
+var errRegressed = errors.New("performance regressed")
+
+func main() {
+	err := run(os.Args[1:], os.Stdout)
+	switch {
+	case errors.Is(err, errUsage):
+		fmt.Fprintln(os.Stderr, "usage: sembench [-run regexp] [-count n] [-benchtime d] [-o file] [-baseline file] [-threshold f]")
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "sembench:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("sembench", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	filter := flags.String("run", "", "run only benchmarks whose name matches this regular expression")
+	count := flags.Int("count", 1, "run each benchmark this many times")
+	benchtime := flags.String("benchtime", "", "run time of each benchmark, e.g. 2s or 1000x")
+	output := flags.String("o", "", "also write the results to this file")
+	baseline := flags.String("baseline", "", "results of an earlier run to compare against")
+	threshold := flags.Float64("threshold", 0.1, "relative slowdown reported as a regression")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 || *count < 1 || *threshold < 0 {
+		return errUsage
+	}
+
+	opts := bench.Options{Count: *count, Benchtime: *benchtime}
+	if *filter != "" {
+		re, err := regexp.Compile(*filter)
+		if err != nil {
+			return fmt.Errorf("invalid -run: %w", err)
+		}
+		opts.Filter = re
+	}
+
+	var results bytes.Buffer
+	if err := bench.Run(io.MultiWriter(out, &results), bench.Suite(), opts); err != nil {
+		return err
+	}
+	if *output != "" {
+		if err := os.WriteFile(*output, results.Bytes(), 0o644); err != nil {
+			return err
+		}
+	}
+	if *baseline == "" {
+		return nil
+	}
+	return compare(*baseline, results.Bytes(), *threshold, out)
+}
+
+// compare prints the changes against the baseline, failing when any regressed
+func compare(baselinePath string, results []byte, threshold float64, out io.Writer) error {
+	f, err := os.Open(baselinePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	old, err := bench.ParseResults(f)
+	if err != nil {
+		return err
+	}
+	new, err := bench.ParseResults(bytes.NewReader(results))
+	if err != nil {
+		return err
+	}
+
+	regressions := 0
+	fmt.Fprintf(out, "\ncompared with %s:\n", baselinePath)
+	for _, change := range bench.Compare(old, new) {
+		marker := " "
+		if change.Regressed(threshold) {
+			marker = "!"
+			regressions++
+		}
+		fmt.Fprintf(out, "%s %s\n", marker, change)
+	}
+	if regressions > 0 {
+		return fmt.Errorf("%w: %d benchmarks slowed down by more than %.0f%%", errRegressed, regressions, threshold*100)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun_ReportsRegressions(t *testing.T) {
+	dir := t.TempDir()
+	baseline := filepath.Join(dir, "base.txt")
+	// A baseline no real run can match
+	os.WriteFile(baseline, []byte("BenchmarkCartCommands/port=canonical-8 \t 1000000 \t 1 ns/op\n"), 0o644)
+
+	var out bytes.Buffer
+	args := []string{"-run", "^CartCommands/port=canonical$", "-benchtime", "5x", "-o", filepath.Join(dir, "new.txt"), "-baseline", baseline}
+	err := run(args, &out)
+	if !errors.Is(err, errRegressed) {
+		t.Fatalf("Expected a regression, got %v", err)
+	}
+	if !strings.Contains(out.String(), "! CartCommands/port=canonical") {
+		t.Errorf("Expected the regression to be marked, got %q", out.String())
+	}
+
+	written, err := os.ReadFile(filepath.Join(dir, "new.txt"))
+	if err != nil || !strings.Contains(string(written), "BenchmarkCartCommands/port=canonical") {
+		t.Errorf("Expected the results to be written, got %q (%v)", written, err)
+	}
+}
+
+func TestRun_InvalidUsage(t *testing.T) {
+	if err := run([]string{"-count", "0"}, &bytes.Buffer{}); !errors.Is(err, errUsage) {
+		t.Errorf("Expected errUsage, got %v", err)
+	}
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/cart"
+	"simple-event-modeling/common"
+)
+
+// Config describes a simulation run
+type Config struct {
+	Shoppers int
+	// Commands stops each shopper after this many commands; 0 runs until the context ends
+	Commands int
+	// Mix holds the relative weight of each cart command type
+	Mix map[string]int
+	// HotCarts are created up front and shared by every shopper
+	HotCarts int
+	// SharedRate is the probability that a command targets a hot cart
+	SharedRate float64
+	// Think pauses each shopper between commands
+	Think time.Duration
+	// RetryAttempts re-runs commands that hit a concurrency conflict
+	RetryAttempts int
+	Seed          int64
+}
+
+// Command outcomes
+const (
+	outcomeAccepted = "accepted"
+	outcomeRejected = "rejected"
+	outcomeConflict = "conflict"
+	outcomeFailed   = "failed"
+)
+
+// mixAliases maps the short names accepted by -mix to command types
+var mixAliases = map[string]string{
+	"create": cart.CommandTypeCreateCart,
+	"add":    cart.CommandTypeAddItem,
+	"remove": cart.CommandTypeRemoveItem,
+	"clear":  cart.CommandTypeClearCart,
+}
+
+// items is the catalog shoppers pick from
+var items = []string{"apple", "pear", "plum", "fig", "kiwi", "lime", "melon", "grape"}
+
+// parseMix parses weights such as "add=6,remove=2"
+func parseMix(mix string) (map[string]int, error) {
+	weights := make(map[string]int)
+	total := 0
+	for _, entry := range strings.Split(mix, ",") {
+		name, weight, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		commandType, known := mixAliases[name]
+		if !ok || !known {
+			return nil, fmt.Errorf("invalid mix entry %q: want create|add|remove|clear=weight", entry)
+		}
+		n, err := strconv.Atoi(weight)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid weight in mix entry %q", entry)
+		}
+		weights[commandType] = n
+		total += n
+	}
+	if total == 0 {
+		return nil, errors.New("the command mix has no weight")
+	}
+	return weights, nil
+}
+
+// Report summarizes a simulation run
+type Report struct {
+	Store    string        `json:"store,omitempty"`
+	Shoppers int           `json:"shoppers"`
+	Elapsed  time.Duration `json:"elapsed_ns"`
+	// Throughput is the number of commands handled per second
+	Throughput float64        `json:"throughput"`
+	Total      CommandStats   `json:"total"`
+	ByCommand  []CommandStats `json:"by_command"`
+}
+
+// CommandStats counts the outcomes of one command type, or all of them
+type CommandStats struct {
+	CommandType string `json:"command_type,omitempty"`
+	Commands    int    `json:"commands"`
+	Accepted    int    `json:"accepted"`
+	// Rejected commands broke a business rule or failed validation
+	Rejected int `json:"rejected"`
+	// Conflicts lost an optimistic concurrency race, after any retries
+	Conflicts int `json:"conflicts"`
+	// Failed commands hit any other error
+	Failed int `json:"failed"`
+	// ConflictRate is Conflicts as a fraction of Commands
+	ConflictRate float64       `json:"conflict_rate"`
+	P50          time.Duration `json:"p50_ns"`
+	P99          time.Duration `json:"p99_ns"`
+	Max          time.Duration `json:"max_ns"`
+}
+
+// sample is the result of one dispatched command
+type sample struct {
+	commandType string
+	outcome     string
+	latency     time.Duration
+}
+
+// Simulate runs the shoppers against store until each has issued config.Commands
+// commands or ctx ends, and reports what happened
+func Simulate(ctx context.Context, store common.Store, config Config) (*Report, error) {
+	commands := bus.NewCommandBus(bus.RetryOnConflict(config.RetryAttempts), bus.Validation())
+	cart.RegisterCommands(commands, store)
+
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	hot := make([]string, 0, config.HotCarts)
+	for i := 0; i < config.HotCarts; i++ {
+		created, err := commands.Dispatch(ctx, &cart.CreateCartCommand{})
+		if err != nil {
+			return nil, fmt.Errorf("creating shared cart: %w", err)
+		}
+		hot = append(hot, created.AggregateID)
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		samples []sample
+	)
+	start := time.Now()
+	for i := 0; i < config.Shoppers; i++ {
+		wg.Add(1)
+		go func(s *shopper) {
+			defer wg.Done()
+			results := s.shop(ctx)
+			mu.Lock()
+			samples = append(samples, results...)
+			mu.Unlock()
+		}(&shopper{
+			config:   config,
+			commands: commands,
+			hot:      hot,
+			rand:     rand.New(rand.NewSource(seed + int64(i))),
+		})
+	}
+	wg.Wait()
+
+	return newReport(config.Shoppers, time.Since(start), samples), nil
+}
+
+// shopper issues commands against its own cart and the shared ones
+type shopper struct {
+	config   Config
+	commands *bus.CommandBus
+	hot      []string
+	rand     *rand.Rand
+	cartID   string
+}
+
+func (s *shopper) shop(ctx context.Context) []sample {
+	var samples []sample
+	for n := 0; s.config.Commands == 0 || n < s.config.Commands; n++ {
+		if ctx.Err() != nil {
+			break
+		}
+
+		command := s.next()
+		started := time.Now()
+		event, err := s.commands.Dispatch(ctx, command)
+		latency := time.Since(started)
+		if ctx.Err() != nil && err != nil {
+			// Interrupted by the end of the run, not by the store
+			break
+		}
+		if err == nil && command.CommandType() == cart.CommandTypeCreateCart {
+			s.cartID = event.AggregateID
+		}
+		samples = append(samples, sample{commandType: command.CommandType(), outcome: outcomeOf(err), latency: latency})
+
+		if s.config.Think > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(s.config.Think):
+			}
+		}
+	}
+	return samples
+}
+
+// next picks the shopper's next command from the mix. A shopper without a cart
+// starts by creating one.
+func (s *shopper) next() common.Command {
+	if s.cartID == "" {
+		return &cart.CreateCartCommand{}
+	}
+
+	cartID := s.cartID
+	if len(s.hot) > 0 && s.rand.Float64() < s.config.SharedRate {
+		cartID = s.hot[s.rand.Intn(len(s.hot))]
+	}
+	item := items[s.rand.Intn(len(items))]
+
+	switch s.pick() {
+	case cart.CommandTypeCreateCart:
+		return &cart.CreateCartCommand{}
+	case cart.CommandTypeRemoveItem:
+		return &cart.RemoveItemCommand{CartID: cartID, ItemID: item}
+	case cart.CommandTypeClearCart:
+		return &cart.ClearCartCommand{CartID: cartID}
+	default:
+		return &cart.AddItemCommand{CartID: cartID, ItemID: item}
+	}
+}
+
+// pick draws a command type according to the mix weights
+func (s *shopper) pick() string {
+	types := make([]string, 0, len(s.config.Mix))
+	total := 0
+	for commandType, weight := range s.config.Mix {
+		types = append(types, commandType)
+		total += weight
+	}
+	// Iterate in a fixed order so a seed reproduces the run
+	sort.Strings(types)
+
+	n := s.rand.Intn(total)
+	for _, commandType := range types {
+		if n < s.config.Mix[commandType] {
+			return commandType
+		}
+		n -= s.config.Mix[commandType]
+	}
+	return cart.CommandTypeAddItem
+}
+
+func outcomeOf(err error) string {
+	switch {
+	case err == nil:
+		return outcomeAccepted
+	case errors.Is(err, common.ErrConcurrency):
+		return outcomeConflict
+	case errors.Is(err, common.ErrInvalidCommand), errors.Is(err, common.ErrValidation):
+		return outcomeRejected
+	default:
+		return outcomeFailed
+	}
+}
+
+func newReport(shoppers int, elapsed time.Duration, samples []sample) *Report {
+	report := &Report{Shoppers: shoppers, Elapsed: elapsed, Total: stats("", samples)}
+	if elapsed > 0 {
+		report.Throughput = float64(len(samples)) / elapsed.Seconds()
+	}
+
+	byType := make(map[string][]sample)
+	for _, s := range samples {
+		byType[s.commandType] = append(byType[s.commandType], s)
+	}
+	for commandType, typed := range byType {
+		report.ByCommand = append(report.ByCommand, stats(commandType, typed))
+	}
+	sort.Slice(report.ByCommand, func(i, j int) bool {
+		return report.ByCommand[i].CommandType < report.ByCommand[j].CommandType
+	})
+	return report
+}
+
+func stats(commandType string, samples []sample) CommandStats {
+	stats := CommandStats{CommandType: commandType, Commands: len(samples)}
+	if len(samples) == 0 {
+		return stats
+	}
+
+	latencies := make([]time.Duration, len(samples))
+	for i, s := range samples {
+		latencies[i] = s.latency
+		switch s.outcome {
+		case outcomeAccepted:
+			stats.Accepted++
+		case outcomeRejected:
+			stats.Rejected++
+		case outcomeConflict:
+			stats.Conflicts++
+		default:
+			stats.Failed++
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	stats.ConflictRate = float64(stats.Conflicts) / float64(len(samples))
+	stats.P50 = percentile(latencies, 0.50)
+	stats.P99 = percentile(latencies, 0.99)
+	stats.Max = latencies[len(latencies)-1]
+	return stats
+}
+
+// percentile returns the nearest-rank percentile of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(p*float64(len(sorted))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+// WriteText prints the report as a table
+func (r *Report) WriteText(out io.Writer) {
+	if r.Store != "" {
+		fmt.Fprintf(out, "store: %s\n", r.Store)
+	}
+	fmt.Fprintf(out, "shoppers: %d  elapsed: %s  throughput: %.1f commands/s\n\n", r.Shoppers, r.Elapsed.Round(time.Millisecond), r.Throughput)
+	fmt.Fprintf(out, "%-12s %9s %9s %9s %9s %7s %9s %10s %10s %10s\n",
+		"command", "total", "accepted", "rejected", "conflicts", "failed", "conflict%", "p50", "p99", "max")
+	row := func(name string, s CommandStats) {
+		fmt.Fprintf(out, "%-12s %9d %9d %9d %9d %7d %8.2f%% %10s %10s %10s\n",
+			name, s.Commands, s.Accepted, s.Rejected, s.Conflicts, s.Failed, s.ConflictRate*100,
+			s.P50.Round(time.Microsecond), s.P99.Round(time.Microsecond), s.Max.Round(time.Microsecond))
+	}
+	for _, s := range r.ByCommand {
+		row(s.CommandType, s)
+	}
+	row("total", r.Total)
+}
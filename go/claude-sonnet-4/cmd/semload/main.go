@@ -0,0 +1,107 @@
+// Command semload drives a store backend with concurrent simulated shoppers issuing
+// a realistic mix of cart commands, then reports throughput, latency percentiles,
+// and how often commands were rejected or lost an optimistic concurrency race.
+//
+// Usage:
+//
+//	semload [-store memory] [-shoppers 8] [-duration 10s] [-commands n] [-mix create=1,add=6,remove=2,clear=1]
+//	        [-hot 4] [-shared 0.2] [-think 0] [-latency 0] [-retry 1] [-seed n] [-format text|json]
+//
+// The store is "memory" (the default), an event file path, a bolt://path database,
+// or a redis://host:port server. Shoppers work on a cart of their own and, with
+// probability -shared, on one of -hot carts shared by every shopper, which is where
+// conflicts come from. -latency slows every store call down to widen those races.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// errUsage signals invalid command line arguments
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := run(ctx, os.Args[1:], os.Stdout)
+	if errors.Is(err, errUsage) {
+		fmt.Fprintln(os.Stderr, "usage: semload [flags]; see semload -h")
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "semload:", err)
+		os.Exit(1)
+	}
+}
+
+// run parses the flags, runs the simulation, and prints the report
+func run(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("semload", flag.ContinueOnError)
+	flags.SetOutput(out)
+	storeURL := flags.String("store", "memory", "store backend: memory, an event file path, bolt://path, or redis://host:port")
+	shoppers := flags.Int("shoppers", 8, "number of concurrent simulated shoppers")
+	duration := flags.Duration("duration", 10*time.Second, "how long to run")
+	commands := flags.Int("commands", 0, "stop each shopper after this many commands (0: run for -duration)")
+	mix := flags.String("mix", "create=1,add=6,remove=2,clear=1", "relative weights of the cart commands")
+	hot := flags.Int("hot", 4, "number of carts shared by all shoppers")
+	shared := flags.Float64("shared", 0.2, "probability, from 0 to 1, that a command targets a shared cart")
+	think := flags.Duration("think", 0, "pause between a shopper's commands")
+	latency := flags.Duration("latency", 0, "latency injected into every store call")
+	retry := flags.Int("retry", 1, "attempts per command on a concurrency conflict (1: no retry)")
+	seed := flags.Int64("seed", 0, "random seed making a run reproducible (0: seed from the clock)")
+	format := flags.String("format", "text", "report format: text or json")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if flags.NArg() != 0 || *shoppers < 1 || *hot < 0 || *shared < 0 || *shared > 1 || (*format != "text" && *format != "json") {
+		return errUsage
+	}
+	weights, err := parseMix(*mix)
+	if err != nil {
+		return err
+	}
+
+	store, closeStore, err := openStore(*storeURL, *latency, *seed)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	if *commands == 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	report, err := Simulate(ctx, store, Config{
+		Shoppers:      *shoppers,
+		Commands:      *commands,
+		Mix:           weights,
+		HotCarts:      *hot,
+		SharedRate:    *shared,
+		Think:         *think,
+		RetryAttempts: *retry,
+		Seed:          *seed,
+	})
+	if err != nil {
+		return err
+	}
+	report.Store = *storeURL
+
+	if *format == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	report.WriteText(out)
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"simple-event-modeling/cart"
+)
+
+func TestRun_ReportsEveryCommand(t *testing.T) {
+	var out bytes.Buffer
+	args := []string{"-shoppers", "4", "-commands", "25", "-hot", "2", "-shared", "0.5", "-seed", "7", "-format", "json"}
+	if err := run(context.Background(), args, &out); err != nil {
+		t.Fatalf("Error running simulation: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("Error decoding report %q: %v", out.String(), err)
+	}
+	total := report.Total
+	if total.Commands != 100 {
+		t.Errorf("Expected 100 commands, got %d", total.Commands)
+	}
+	if total.Accepted+total.Rejected+total.Conflicts+total.Failed != total.Commands {
+		t.Errorf("Expected every command to have one outcome, got %+v", total)
+	}
+	if total.Failed != 0 || total.Accepted == 0 {
+		t.Errorf("Unexpected outcomes: %+v", total)
+	}
+	if total.P99 < total.P50 || total.Max < total.P99 {
+		t.Errorf("Expected ordered percentiles, got %+v", total)
+	}
+}
+
+func TestRun_TextReport(t *testing.T) {
+	var out bytes.Buffer
+	if err := run(context.Background(), []string{"-shoppers", "2", "-duration", "20ms"}, &out); err != nil {
+		t.Fatalf("Error running simulation: %v", err)
+	}
+	for _, want := range []string{"throughput:", cart.CommandTypeAddItem, "total"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in the report, got %q", want, out.String())
+		}
+	}
+}
+
+func TestRun_InvalidMix(t *testing.T) {
+	var out bytes.Buffer
+	if err := run(context.Background(), []string{"-mix", "checkout=1"}, &out); err == nil {
+		t.Error("Expected an unknown command in the mix to fail")
+	}
+	if err := run(context.Background(), []string{"-shoppers", "0"}, &out); !errors.Is(err, errUsage) {
+		t.Errorf("Expected errUsage, got %v", err)
+	}
+}
+
+func TestSimulate_CountsConflicts(t *testing.T) {
+	// Slow appends widen the window between loading a shared cart and appending to it
+	store, closeStore, err := openStore("memory", time.Millisecond, 1)
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+	defer closeStore()
+
+	report, err := Simulate(context.Background(), store, Config{
+		Shoppers:   8,
+		Commands:   10,
+		Mix:        map[string]int{cart.CommandTypeAddItem: 1, cart.CommandTypeRemoveItem: 1},
+		HotCarts:   1,
+		SharedRate: 1,
+		Seed:       1,
+	})
+	if err != nil {
+		t.Fatalf("Error simulating: %v", err)
+	}
+	if report.Total.Conflicts == 0 {
+		t.Errorf("Expected shoppers sharing one cart to conflict, got %+v", report.Total)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"time"
+
+	"simple-event-modeling/boltstore"
+	"simple-event-modeling/common"
+	"simple-event-modeling/faultstore"
+	"simple-event-modeling/filestore"
+	"simple-event-modeling/redisstore"
+)
+
+// openStore opens the backend under test, wrapped in a faultstore when latency is
+// injected, and returns a function releasing it
+func openStore(url string, latency time.Duration, seed int64) (common.Store, func(), error) {
+	var store common.Store
+	switch {
+	case url == "memory":
+		store = common.NewEventStore()
+	case strings.HasPrefix(url, "redis://"):
+		redis, err := redisstore.Open(strings.TrimPrefix(url, "redis://"))
+		if err != nil {
+			return nil, nil, err
+		}
+		store = redis
+	case strings.HasPrefix(url, "bolt://"):
+		bolt, err := boltstore.Open(strings.TrimPrefix(url, "bolt://"))
+		if err != nil {
+			return nil, nil, err
+		}
+		store = bolt
+	default:
+		file, err := filestore.Open(url)
+		if err != nil {
+			return nil, nil, err
+		}
+		store = file
+	}
+
+	closeStore := func() {
+		if closer, ok := store.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+	if latency > 0 {
+		store = faultstore.New(store, faultstore.Config{Latency: latency, Seed: seed})
+	}
+	return store, closeStore, nil
+}
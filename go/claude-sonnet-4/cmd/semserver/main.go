@@ -0,0 +1,144 @@
+// Command semserver serves the cart command/query HTTP API together with the admin
+// endpoints, the generated OpenAPI document, a GraphQL endpoint for the queries, and
+// a webhook accepting catalog price changes as CloudEvents.
+//
+// Usage:
+//
+//	semserver [-addr :8080] [-store events.jsonl] [-log-commands] [-api-keys keys.json] [-jwt-secret-env VAR] [-require-auth]
+//
+// Without -store the server keeps events in memory. With -log-commands every received
+// command is recorded in the "$commands" stream, including rejected ones.
+//
+// Callers authenticate with an API key listed in the -api-keys file, a JSON object
+// mapping each key to its principal ({"key": {"id": "alice", "roles": ["admin"]}}),
+// or with an HS256 bearer JWT signed with the secret in the named environment
+// variable, which must hold at least 32 bytes. The caller's ID is recorded as the
+// actor_id metadata of the events their commands produce. Anonymous requests are
+// accepted unless -require-auth is given.
+//
+// Probes reach /healthz and /readyz without credentials; readiness fails while the
+// store cannot be read.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"simple-event-modeling/admin"
+	"simple-event-modeling/bus"
+	"simple-event-modeling/cart"
+	"simple-event-modeling/common"
+	"simple-event-modeling/filestore"
+	"simple-event-modeling/graphql"
+	"simple-event-modeling/health"
+	"simple-event-modeling/httpapi"
+	"simple-event-modeling/inbound"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	storePath := flag.String("store", "", "path of the event store file (in-memory when empty)")
+	logCommands := flag.Bool("log-commands", false, "record every received command in the $commands stream")
+	apiKeysPath := flag.String("api-keys", "", "JSON file mapping API keys to principals")
+	jwtSecretEnv := flag.String("jwt-secret-env", "", "environment variable holding the HS256 secret of bearer JWTs")
+	requireAuth := flag.Bool("require-auth", false, "reject requests without credentials")
+	flag.Parse()
+
+	authenticator, err := newAuthenticator(*apiKeysPath, *jwtSecretEnv)
+	if err != nil {
+		log.Fatal("Error configuring authentication:", err)
+	}
+
+	var store common.Store = common.NewEventStore()
+	if *storePath != "" {
+		fileStore, err := filestore.Open(*storePath)
+		if err != nil {
+			log.Fatal("Error opening store:", err)
+		}
+		store = fileStore
+	}
+
+	checker := health.New()
+	checker.AddReadiness("store", health.StoreCheck(store))
+
+	root := http.NewServeMux()
+	root.Handle("/healthz", checker.LivenessHandler())
+	root.Handle("/readyz", checker.ReadinessHandler())
+	root.Handle("/", httpapi.Authenticate(authenticator, *requireAuth, newMux(store, *logCommands)))
+
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, root))
+}
+
+// newAuthenticator accepts the configured API keys and bearer JWTs
+func newAuthenticator(apiKeysPath, jwtSecretEnv string) (httpapi.Authenticator, error) {
+	var authenticators httpapi.Authenticators
+	if apiKeysPath != "" {
+		data, err := os.ReadFile(apiKeysPath)
+		if err != nil {
+			return nil, err
+		}
+		var keys map[string]struct {
+			ID    string   `json:"id"`
+			Roles []string `json:"roles"`
+		}
+		if err := json.Unmarshal(data, &keys); err != nil {
+			return nil, fmt.Errorf("invalid API keys file: %w", err)
+		}
+		apiKeys := make(httpapi.APIKeys, len(keys))
+		for key, principal := range keys {
+			apiKeys[key] = bus.Principal{ID: principal.ID, Roles: principal.Roles}
+		}
+		authenticators = append(authenticators, apiKeys)
+	}
+	if jwtSecretEnv != "" {
+		secret := os.Getenv(jwtSecretEnv)
+		if secret == "" {
+			return nil, fmt.Errorf("$%s is empty", jwtSecretEnv)
+		}
+		jwt, err := httpapi.NewJWT([]byte(secret))
+		if err != nil {
+			return nil, fmt.Errorf("$%s: %w", jwtSecretEnv, err)
+		}
+		authenticators = append(authenticators, jwt)
+	}
+	return authenticators, nil
+}
+
+// newMux mounts the API, the admin endpoints, the OpenAPI document, GraphQL, and the
+// inbound webhook
+func newMux(store common.Store, logCommands bool) *http.ServeMux {
+	commands := bus.NewCommandBus(bus.ActorMetadata())
+	if logCommands {
+		commands.Use(bus.CommandLog(store, bus.DefaultCommandLogStream))
+	}
+	commands.Use(bus.Validation())
+	cart.RegisterCommands(commands, store)
+
+	api := httpapi.NewServer()
+	cart.RegisterRoutes(api, commands, store)
+
+	mux := http.NewServeMux()
+	mux.Handle("/commands/", api)
+	mux.Handle("/queries/", api)
+	mux.Handle("/admin/", admin.NewHandler(store))
+	mux.Handle("/graphql", graphql.NewHandler(api, store))
+
+	consumer := inbound.NewConsumer(store, commands)
+	cart.RegisterInbound(consumer)
+	mux.Handle("/webhooks", consumer.WebhookHandler())
+	mux.HandleFunc("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		doc, err := api.OpenAPI(httpapi.OpenAPIInfo{Title: "Cart API", Version: "1.0.0"}).JSON()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(doc)
+	})
+	return mux
+}
@@ -2,7 +2,10 @@
 // Aggregates handle command validation and event persistence in event-sourced systems.
 package common
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 // Aggregate defines the interface for event-sourced aggregates
 type Aggregate interface {
@@ -15,7 +18,7 @@ type Aggregate interface {
 	// On applies an event to the aggregate state
 	On(event *Event) error
 	// Handle processes a command and returns any resulting events
-	Handle(command interface{}) (*Event, error)
+	Handle(command Command) (*Event, error)
 	// Hydrate rebuilds the aggregate state from its event stream
 	Hydrate(id string) error
 }
@@ -25,11 +28,24 @@ type BaseAggregate struct {
 	id      string
 	version int
 	live    bool
-	store   *EventStore
+	store   Store
+	// snapshots, when set, lets HydrateFromSnapshot skip the events a snapshot covers
+	snapshots SnapshotStore
+	// closedBy is the type of the event that moved the aggregate to a terminal state
+	closedBy string
+	// readOnly is set by HydrateUpTo: the aggregate shows its stream as of a version
+	readOnly bool
+}
+
+// Closable is implemented by aggregates with terminal states, such as those built on
+// BaseAggregate, so callers holding a hydrated aggregate can tell it is closed
+type Closable interface {
+	IsClosed() bool
+	ClosedBy() string
 }
 
 // NewBaseAggregate creates a new base aggregate
-func NewBaseAggregate(store *EventStore) *BaseAggregate {
+func NewBaseAggregate(store Store) *BaseAggregate {
 	return &BaseAggregate{
 		store: store,
 		live:  false,
@@ -53,6 +69,36 @@ func (ba *BaseAggregate) IsLive() bool {
 
 // Hydrate rebuilds the aggregate state from its event stream
 func (ba *BaseAggregate) Hydrate(id string, onEvent func(*Event) error) error {
+	return ba.HydrateFromSnapshot(id, nil, onEvent)
+}
+
+// HydrateFromSnapshot rebuilds the aggregate state from the latest snapshot of its
+// stream, passed to restore, and the events after it. Without a snapshot store, a
+// restore function, or a snapshot, it replays the whole stream like Hydrate. A
+// stream whose earlier events were compacted away (see package compaction) cannot be
+// hydrated without its snapshot.
+func (ba *BaseAggregate) HydrateFromSnapshot(id string, restore func(Snapshot) error, onEvent func(*Event) error) error {
+	return ba.hydrate(id, 0, restore, onEvent)
+}
+
+// HydrateUpTo rebuilds the aggregate state as it was at a version of its stream,
+// replaying the events up to it, e.g. to show a cart as it was before checkout. The
+// aggregate is a read-only view: CheckOpen rejects commands with an
+// *AggregateReadOnlyError.
+func (ba *BaseAggregate) HydrateUpTo(id string, version int, restore func(Snapshot) error, onEvent func(*Event) error) error {
+	if version < 1 {
+		return fmt.Errorf("cannot hydrate %s up to version %d: versions start at 1", id, version)
+	}
+	if err := ba.hydrate(id, version, restore, onEvent); err != nil {
+		return err
+	}
+	ba.readOnly = true
+	return nil
+}
+
+// hydrate replays the events of a stream up to version upTo, or all of them when
+// upTo is 0, starting from the latest snapshot it covers
+func (ba *BaseAggregate) hydrate(id string, upTo int, restore func(Snapshot) error, onEvent func(*Event) error) error {
 	if ba.live {
 		return errors.New("aggregate is already live")
 	}
@@ -60,37 +106,126 @@ func (ba *BaseAggregate) Hydrate(id string, onEvent func(*Event) error) error {
 	events, err := ba.store.GetStream(id)
 	if err != nil {
 		// If stream doesn't exist, that's okay - we'll start fresh
-		if _, ok := err.(*StreamNotFoundError); !ok {
+		if !errors.Is(err, ErrStreamNotFound) {
 			return err
 		}
 	}
 
+	if upTo > 0 {
+		for i, event := range events {
+			if event.Version > upTo {
+				events = events[:i]
+				break
+			}
+		}
+	}
+
+	restored := 0
+	if ba.snapshots != nil && restore != nil && len(events) > 0 {
+		snapshot, found, err := ba.snapshots.LoadSnapshot(id)
+		if err != nil {
+			return err
+		}
+		// A snapshot ahead of the stream belongs to a history the store no longer has
+		if found && snapshot.Version <= events[len(events)-1].Version {
+			if err := restore(snapshot); err != nil {
+				return fmt.Errorf("restoring snapshot of %s at version %d: %w", id, snapshot.Version, err)
+			}
+			ba.id = snapshot.StreamID
+			ba.version = snapshot.Version
+			restored = snapshot.Version
+		}
+	}
+	if len(events) > 0 && events[0].Version > restored+1 {
+		return fmt.Errorf("stream %s starts at version %d: events up to it were compacted and need a snapshot to hydrate", id, events[0].Version)
+	}
+
 	for _, event := range events {
+		if event.Version <= restored {
+			continue
+		}
 		if err := onEvent(event); err != nil {
 			return err
 		}
 	}
+	// Events this aggregate emits next must be stamped after the stream's latest
+	// event, even if that was written by a process whose clock runs ahead
+	if len(events) > 0 {
+		DefaultClock.Observe(events[len(events)-1].CreatedAt)
+	}
 
 	ba.live = true
 	return nil
 }
 
+// Close moves the aggregate to a terminal state. Event handlers call it when they
+// apply a terminal event, such as CartCheckedOut, with that event's type.
+func (ba *BaseAggregate) Close(eventType string) {
+	ba.closedBy = eventType
+}
+
+// IsClosed returns whether the aggregate has reached a terminal state
+func (ba *BaseAggregate) IsClosed() bool {
+	return ba.closedBy != ""
+}
+
+// ClosedBy returns the type of the event that closed the aggregate, or "" while it
+// is open
+func (ba *BaseAggregate) ClosedBy() string {
+	return ba.closedBy
+}
+
+// IsReadOnly returns whether the aggregate is a view of its stream as of a version
+// (see HydrateUpTo)
+func (ba *BaseAggregate) IsReadOnly() bool {
+	return ba.readOnly
+}
+
+// CheckOpen fails with an *AggregateClosedError once the aggregate is closed, and
+// with an *AggregateReadOnlyError for a view hydrated up to a version. Command
+// handlers call it before deciding on a command.
+func (ba *BaseAggregate) CheckOpen() error {
+	if ba.readOnly {
+		return &AggregateReadOnlyError{StreamID: ba.id, Version: ba.version}
+	}
+	if ba.closedBy != "" {
+		return &AggregateClosedError{StreamID: ba.id, ClosedBy: ba.closedBy, Version: ba.version}
+	}
+	return nil
+}
+
 // SetID sets the aggregate's identifier
 func (ba *BaseAggregate) SetID(id string) {
 	ba.id = id
 }
 
-// SetVersion sets the aggregate's version
-func (ba *BaseAggregate) SetVersion(version int) {
-	ba.version = version
+// Begin starts the aggregate from the event creating it: the aggregate takes the
+// event's stream ID and becomes live. On handlers call it for creation events.
+func (ba *BaseAggregate) Begin(event *Event) {
+	ba.id = event.AggregateID
+	ba.live = true
+}
+
+// Advance moves the aggregate to the version of an event its On handler applied. It
+// rejects an event of another stream, or one that is not after the aggregate's
+// version, so the version only moves with the stream's history.
+func (ba *BaseAggregate) Advance(event *Event) error {
+	if ba.id != "" && event.AggregateID != ba.id {
+		return fmt.Errorf("event %s of %s applied to %s", event.Type, event.AggregateID, ba.id)
+	}
+	if event.Version <= ba.version {
+		return fmt.Errorf("event %s of %s at version %d applied to version %d", event.Type, event.AggregateID, event.Version, ba.version)
+	}
+	ba.version = event.Version
+	return nil
 }
 
-// SetLive sets the aggregate's live status
-func (ba *BaseAggregate) SetLive(live bool) {
-	ba.live = live
+// SetSnapshots sets the snapshot store HydrateFromSnapshot restores from
+func (ba *BaseAggregate) SetSnapshots(snapshots SnapshotStore) {
+	ba.snapshots = snapshots
 }
 
 // Store returns the event store
-func (ba *BaseAggregate) Store() *EventStore {
+func (ba *BaseAggregate) Store() Store {
 	return ba.store
 }
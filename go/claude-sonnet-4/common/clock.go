@@ -0,0 +1,52 @@
+// Package common provides the hybrid logical clock that timestamps events. Wall
+// clocks can jump backwards and return the same reading for rapid calls, so events
+// are stamped from a clock that never repeats or goes back, and that moves past the
+// timestamps of events written by other processes once it has seen them.
+package common
+
+import (
+	"sync"
+	"time"
+)
+
+// HLC is a hybrid logical clock. It follows the physical clock while that moves
+// forward, and otherwise advances a logical counter kept in the nanoseconds of the
+// returned time, so every reading is strictly later than the previous one and than
+// every observed timestamp. It is safe for concurrent use.
+type HLC struct {
+	mu   sync.Mutex
+	now  func() time.Time
+	last int64
+}
+
+// NewHLC creates a clock following the physical clock now, e.g. time.Now
+func NewHLC(now func() time.Time) *HLC {
+	return &HLC{now: now}
+}
+
+// DefaultClock stamps the CreatedAt of events created by NewEvent
+var DefaultClock = NewHLC(time.Now)
+
+// Now returns a time strictly later than every time previously returned or observed
+func (c *HLC) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if physical := c.now().UnixNano(); physical > c.last {
+		c.last = physical
+	} else {
+		c.last++
+	}
+	return time.Unix(0, c.last)
+}
+
+// Observe advances the clock past a timestamp received from elsewhere, such as the
+// latest event of a stream written by another process with a clock running ahead
+func (c *HLC) Observe(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if ts := t.UnixNano(); ts > c.last {
+		c.last = ts
+	}
+}
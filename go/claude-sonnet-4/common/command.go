@@ -0,0 +1,13 @@
+// Package common provides the Command contract handled by aggregates.
+package common
+
+// Command is a request to change the state of a single aggregate. Commands carry
+// the routing metadata needed to hydrate the target aggregate and dispatch to its
+// handler, so callers never pass untyped values to an aggregate.
+type Command interface {
+	// AggregateID returns the ID of the target aggregate, or "" when the command
+	// creates a new aggregate
+	AggregateID() string
+	// CommandType returns the registered name of the command, e.g. "AddItem"
+	CommandType() string
+}
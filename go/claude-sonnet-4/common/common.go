@@ -5,6 +5,22 @@
 // The package is organized into separate files for each major concept:
 // - errors.go: Error types and constants
 // - event.go: Event type and creation functions
+// - clock.go: Hybrid logical clock stamping event creation times
+// - ulid.go: Event ID generators (UUID, time-sortable ULID) and log ordering
+// - temporal.go: Valid time versus record time and as-of queries
+// - store.go: Store interface implemented by event store backends
 // - event_store.go: EventStore implementation for persistence
+// - subscription.go: Polling subscriptions for following streams
+// - command.go: Command interface carrying routing metadata
+// - query.go: Query interface routed to read models
+// - validation.go: Declarative command validation
+// - redaction.go: Rewriting events with redacted fields for legal takedowns
+// - upcast.go: Upcaster pipeline migrating stored events to the current schema
+// - typed.go: TypedEvent[T] payload decoding layered over the registry
 // - aggregate.go: Aggregate interface and BaseAggregate implementation
+// - snapshot.go: Aggregate snapshots and hydrating from them
+// - unit_of_work.go: UnitOfWork buffering appends until Commit, inline projections
+// - repository.go: Repository loading aggregates singly or in parallel batches, skipping replay of unchanged streams
+// - consistency.go: Read-your-writes consistency tokens, async projections that wait for them
+// - metadata.go: Event metadata carried in a command's context and stamped on appended events
 package common
@@ -1,7 +1,17 @@
 package common
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 func TestNewEvent(t *testing.T) {
@@ -111,9 +121,11 @@ func TestBaseAggregate(t *testing.T) {
 		t.Errorf("Expected version 0 initially, got %d", aggregate.Version())
 	}
 
-	// Test setting properties
+	// Test moving with applied events
 	aggregate.SetID("test-123")
-	aggregate.SetVersion(5)
+	if err := aggregate.Advance(NewEvent("Event5", "test-123", 5, nil, nil)); err != nil {
+		t.Fatalf("Error advancing: %v", err)
+	}
 
 	if aggregate.ID() != "test-123" {
 		t.Errorf("Expected ID 'test-123', got %s", aggregate.ID())
@@ -121,6 +133,12 @@ func TestBaseAggregate(t *testing.T) {
 	if aggregate.Version() != 5 {
 		t.Errorf("Expected version 5, got %d", aggregate.Version())
 	}
+	if err := aggregate.Advance(NewEvent("Event4", "test-123", 4, nil, nil)); err == nil || aggregate.Version() != 5 {
+		t.Errorf("Expected an event before the aggregate's version rejected, got %v at version %d", err, aggregate.Version())
+	}
+	if err := aggregate.Advance(NewEvent("Event6", "other-stream", 6, nil, nil)); err == nil || aggregate.Version() != 5 {
+		t.Errorf("Expected an event of another stream rejected, got %v at version %d", err, aggregate.Version())
+	}
 
 	// Test hydration with no events
 	eventHandler := func(event *Event) error {
@@ -178,3 +196,1471 @@ func TestBaseAggregateHydrationWithEvents(t *testing.T) {
 		t.Error("Expected aggregate to be live after hydration")
 	}
 }
+
+// countingProjection counts the events it has been fed
+type countingProjection struct {
+	count int
+}
+
+func (p *countingProjection) Name() string       { return "counting" }
+func (p *countingProjection) On(*Event) error    { p.count++; return nil }
+func (p *countingProjection) State() interface{} { return p.count }
+
+func TestReplayProjectionFromPosition(t *testing.T) {
+	store := NewEventStore()
+	store.Append(NewEvent("Event1", "stream-1", 1, nil, nil))
+	store.Append(NewEvent("Event2", "stream-1", 2, nil, nil))
+	store.Append(NewEvent("Event1", "stream-2", 1, nil, nil))
+
+	projection := &countingProjection{}
+	progressCalls := 0
+	checkpoint, err := ReplayProjection(store, projection, 1, func(Checkpoint, int) { progressCalls++ })
+	if err != nil {
+		t.Fatalf("Error replaying projection: %v", err)
+	}
+	if projection.count != 2 {
+		t.Errorf("Expected 2 events applied after position 1, got %d", projection.count)
+	}
+	if progressCalls != 2 {
+		t.Errorf("Expected 2 progress callbacks, got %d", progressCalls)
+	}
+	if checkpoint.Projection != "counting" || checkpoint.Position != 3 {
+		t.Errorf("Unexpected checkpoint: %+v", checkpoint)
+	}
+}
+
+func TestRegistryProjections(t *testing.T) {
+	registry := NewRegistry()
+	registry.RegisterProjection("counting", func() Projection { return &countingProjection{} })
+
+	if names := registry.ProjectionNames(); len(names) != 1 || names[0] != "counting" {
+		t.Errorf("Unexpected projection names: %v", names)
+	}
+	if _, err := registry.NewProjection("missing"); err == nil {
+		t.Error("Expected error for unregistered projection")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic when registering a duplicate projection")
+		}
+	}()
+	registry.RegisterProjection("counting", func() Projection { return &countingProjection{} })
+}
+
+func TestRegistryDomainComponents(t *testing.T) {
+	registry := NewRegistry()
+	registry.RegisterAggregate(AggregateInfo{Name: "Cart"})
+	registry.RegisterCommand(CommandInfo{Name: "AddItem", Aggregate: "Cart", Produces: []string{"ItemAdded"}})
+	registry.RegisterEvent(EventInfo{Name: "ItemAdded", Aggregate: "Cart"})
+	registry.RegisterEvent(EventInfo{Name: "CartCreated", Aggregate: "Cart"})
+	registry.RegisterEvent(EventInfo{Name: "CustomerAssigned", Aggregate: "Cart", Payload: []FieldInfo{
+		{Name: "customer_id", Type: "string"},
+		{Name: "email", Type: "string", Sensitive: true},
+	}})
+
+	if aggregates := registry.Aggregates(); len(aggregates) != 1 || aggregates[0].Name != "Cart" {
+		t.Errorf("Unexpected aggregates: %v", aggregates)
+	}
+	if commands := registry.Commands(); len(commands) != 1 || commands[0].Produces[0] != "ItemAdded" {
+		t.Errorf("Unexpected commands: %v", commands)
+	}
+	events := registry.Events()
+	if len(events) != 3 || events[0].Name != "CartCreated" {
+		t.Errorf("Expected events sorted by name, got %v", events)
+	}
+	if fields := registry.SensitiveFields("CustomerAssigned"); len(fields) != 1 || fields[0] != "email" {
+		t.Errorf("Expected email to be the only sensitive field, got %v", fields)
+	}
+	if fields := registry.SensitiveFields("ItemAdded"); len(fields) != 0 {
+		t.Errorf("Expected no sensitive fields for ItemAdded, got %v", fields)
+	}
+	if _, ok := registry.Event("ItemAdded"); !ok {
+		t.Error("Expected ItemAdded to be registered")
+	}
+	if types := registry.CommandTypes("Cart"); len(types) != 1 || types[0] != "AddItem" {
+		t.Errorf("Unexpected command types: %v", types)
+	}
+	if types := registry.CommandTypes("Order"); len(types) != 0 {
+		t.Errorf("Expected no command types for Order, got %v", types)
+	}
+}
+
+type orderLine struct {
+	SKU      string `json:"sku" validate:"required"`
+	Quantity int    `json:"quantity" validate:"positive"`
+}
+
+type placeOrder struct {
+	OrderID  string      `json:"order_id" validate:"required,uuid"`
+	Coupon   string      `json:"coupon,omitempty" validate:"omitempty,uuid"`
+	Customer string      `json:"customer_id,omitempty" validate:"omitempty,streamid"`
+	Lines    []orderLine `json:"lines"`
+	rejected bool
+}
+
+func (p *placeOrder) Validate() error {
+	if p.rejected {
+		return &InvalidCommandError{Message: "rejected"}
+	}
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	valid := &placeOrder{
+		OrderID:  "5f1c9f2e-3b7a-4a7e-9d43-1c2b3a4d5e6f",
+		Customer: DefaultStreamNamer.NewStreamID("customer"),
+		Lines:    []orderLine{{SKU: "apple", Quantity: 2}},
+	}
+	if err := Validate(valid); err != nil {
+		t.Errorf("Expected valid command, got %v", err)
+	}
+	valid.Customer = "5f1c9f2e-3b7a-4a7e-9d43-1c2b3a4d5e6f"
+	if err := Validate(valid); err != nil {
+		t.Errorf("Expected a bare UUID to be a valid stream ID, got %v", err)
+	}
+	valid.Customer = DefaultStreamNamer.NewStreamID("gift-card")
+	if err := Validate(valid); err != nil {
+		t.Errorf("Expected a category with a dash to be valid, got %v", err)
+	}
+	valid.Customer = "-5f1c9f2e-3b7a-4a7e-9d43-1c2b3a4d5e6f"
+	if err := Validate(valid); err == nil {
+		t.Error("Expected a stream ID without a category to be invalid")
+	}
+	valid.Customer = "5f1c9f2e-3b7a-4a7e-9d43-1c2b3a4d5e6f"
+
+	invalid := &placeOrder{
+		OrderID:  "order-1",
+		Customer: "customer-1",
+		Lines:    []orderLine{{SKU: "apple", Quantity: 1}, {Quantity: 0}},
+	}
+	err := Validate(invalid)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected ValidationError, got %v", err)
+	}
+	expected := []FieldError{
+		{Field: "order_id", Message: "must be a UUID"},
+		{Field: "customer_id", Message: "must be a UUID or a stream ID of the form <category>-<uuid>"},
+		{Field: "lines[1].sku", Message: "is required"},
+		{Field: "lines[1].quantity", Message: "must be positive"},
+	}
+	if !reflect.DeepEqual(validationErr.Fields, expected) {
+		t.Errorf("Expected %v, got %v", expected, validationErr.Fields)
+	}
+
+	valid.rejected = true
+	var invalidErr *InvalidCommandError
+	if err := Validate(valid); !errors.As(err, &invalidErr) {
+		t.Errorf("Expected the Validate method's error, got %v", err)
+	}
+}
+
+type mistaggedLine struct {
+	SKU string `json:"sku" validate:"required,sku"`
+}
+
+type mistaggedOrder struct {
+	OrderID string          `json:"order_id" validate:"required"`
+	Lines   []mistaggedLine `json:"lines"`
+}
+
+func TestValidateRejectsUnknownRules(t *testing.T) {
+	if err := CheckTags(placeOrder{}); err != nil {
+		t.Errorf("Expected known rules to pass, got %v", err)
+	}
+	if err := CheckTags(mistaggedOrder{}); err == nil || !strings.Contains(err.Error(), `"sku"`) {
+		t.Errorf("Expected the nested unknown rule to be reported, got %v", err)
+	}
+	var validationErr *ValidationError
+	if err := Validate(&mistaggedOrder{OrderID: "o-1"}); err == nil || errors.As(err, &validationErr) {
+		t.Errorf("Expected an error rather than a panic or a validation failure, got %v", err)
+	}
+}
+
+func TestEventStoreRejectsStaleVersions(t *testing.T) {
+	store := NewEventStore()
+	if err := store.Append(NewEvent("Created", "s-1", 1, nil, nil)); err != nil {
+		t.Fatalf("Error appending: %v", err)
+	}
+
+	err := store.Append(NewEvent("Renamed", "s-1", 1, nil, nil))
+	var conflict *ConcurrencyError
+	if !errors.As(err, &conflict) || conflict.Expected != 0 || conflict.Actual != 1 {
+		t.Errorf("Expected ConcurrencyError for a stale version, got %v", err)
+	}
+	if err := store.Append(NewEvent("Renamed", "s-1", 3, nil, nil)); err == nil {
+		t.Error("Expected a gap in versions to be rejected")
+	}
+	if version := store.GetStreamVersion("s-1"); version != 1 {
+		t.Errorf("Expected rejected events not to be stored, got version %d", version)
+	}
+}
+
+func TestEventStoreFork(t *testing.T) {
+	store := NewEventStore()
+	store.Append(NewEvent("Created", "s-1", 1, nil, nil))
+
+	fork := store.Fork()
+	if err := fork.Append(NewEvent("Renamed", "s-1", 2, nil, nil)); err != nil {
+		t.Fatalf("Error appending to fork: %v", err)
+	}
+	fork.Append(NewEvent("Created", "s-2", 1, nil, nil))
+	if err := store.Append(NewEvent("Archived", "s-1", 2, nil, nil)); err != nil {
+		t.Fatalf("Expected the original to be unaffected by the fork, got %v", err)
+	}
+
+	if len(store.GetAllEvents()) != 2 || len(store.StreamIDs()) != 1 {
+		t.Errorf("Expected the original to hold 2 events in 1 stream, got %d in %v", len(store.GetAllEvents()), store.StreamIDs())
+	}
+	forked, _ := fork.GetStream("s-1")
+	if len(fork.GetAllEvents()) != 3 || forked[1].Type != "Renamed" {
+		t.Errorf("Expected the fork to keep its own history, got %v", fork.GetAllEvents())
+	}
+}
+
+func TestRegistryUpcast(t *testing.T) {
+	registry := NewRegistry()
+	registry.RegisterEvent(EventInfo{Name: "ItemAdded", SchemaVersion: 3})
+	registry.RegisterUpcaster(Upcaster{EventType: "ItemAdded", FromVersion: 1, Upcast: func(event *Event) (*Event, error) {
+		event.Data["item_id"] = event.Data["item"]
+		delete(event.Data, "item")
+		return event, nil
+	}})
+	registry.RegisterUpcaster(Upcaster{EventType: "ItemAdded", FromVersion: 2, Upcast: func(event *Event) (*Event, error) {
+		event.Data["quantity"] = 1
+		return event, nil
+	}})
+
+	stored := NewEvent("ItemAdded", "cart-1", 2, map[string]interface{}{"item": "apple"}, nil)
+	upcast, err := registry.Upcast(stored)
+	if err != nil {
+		t.Fatalf("Error upcasting: %v", err)
+	}
+	if upcast.Data["item_id"] != "apple" || upcast.Data["quantity"] != 1 || SchemaVersion(upcast) != 3 {
+		t.Errorf("Unexpected upcast event: %+v", upcast)
+	}
+	if stored.Data["item"] != "apple" || SchemaVersion(stored) != 1 {
+		t.Errorf("Expected the stored event to be left untouched, got %+v", stored)
+	}
+	if same, _ := registry.Upcast(upcast); same != upcast {
+		t.Error("Expected a current event to be returned as is")
+	}
+
+	registry.RegisterEvent(EventInfo{Name: "CartCleared", SchemaVersion: 2})
+	_, err = registry.Upcast(NewEvent("CartCleared", "cart-1", 3, nil, nil))
+	var missing *MissingUpcasterError
+	if !errors.As(err, &missing) || missing.SchemaVersion != 1 {
+		t.Errorf("Expected MissingUpcasterError, got %v", err)
+	}
+}
+
+func TestEventStoreTruncateStream(t *testing.T) {
+	store := NewEventStore()
+	for version := 1; version <= 3; version++ {
+		store.Append(NewEvent("Renamed", "s-1", version, nil, nil))
+	}
+	store.Append(NewEvent("Created", "s-2", 1, nil, nil))
+	before, _ := store.GetStream("s-1")
+
+	if err := store.TruncateStream("s-1", 3); err != nil {
+		t.Fatalf("Error truncating stream: %v", err)
+	}
+	stream, _ := store.GetStream("s-1")
+	if len(stream) != 1 || stream[0].Version != 3 || len(store.GetAllEvents()) != 2 {
+		t.Errorf("Expected only version 3 of s-1 to remain, got %v", store.GetAllEvents())
+	}
+	if len(before) != 3 {
+		t.Errorf("Expected previously returned streams to be unaffected, got %d events", len(before))
+	}
+	if length := store.StreamLength("s-1"); length != 1 {
+		t.Errorf("Expected the truncated stream to hold 1 event at version 3, got %d", length)
+	}
+	if head, err := store.HeadEvent("s-1"); err != nil || head.Version != 3 {
+		t.Errorf("Expected head at version 3, got %v (%v)", head, err)
+	}
+	var notFound *StreamNotFoundError
+	if _, err := store.HeadEvent("missing"); !errors.As(err, &notFound) || store.StreamLength("missing") != 0 {
+		t.Errorf("Expected no head and no events for a missing stream, got %v", err)
+	}
+
+	store.TruncateStream("s-1", 10)
+	if store.GetStreamVersion("s-1") != 3 {
+		t.Errorf("Expected the latest event to be kept, got version %d", store.GetStreamVersion("s-1"))
+	}
+	if err := store.Append(NewEvent("Renamed", "s-1", 4, nil, nil)); err != nil {
+		t.Errorf("Expected appends to continue after truncation, got %v", err)
+	}
+}
+
+func TestEventStoreDeleteStream(t *testing.T) {
+	store := NewEventStore()
+	store.Append(NewEvent("Created", "s-1", 1, nil, nil))
+	store.Append(NewEvent("Created", "s-2", 1, nil, nil))
+	store.Append(NewEvent("Renamed", "s-1", 2, nil, nil))
+
+	var conflict *ConcurrencyError
+	if err := store.DeleteStream("s-1", 1); !errors.As(err, &conflict) {
+		t.Fatalf("Expected ConcurrencyError deleting a stale version, got %v", err)
+	}
+	if err := store.DeleteStream("s-1", 2); err != nil {
+		t.Fatalf("Error deleting stream: %v", err)
+	}
+	if _, err := store.GetStream("s-1"); !errors.Is(err, ErrStreamNotFound) {
+		t.Errorf("Expected the stream gone, got %v", err)
+	}
+	if all := store.GetAllEvents(); len(all) != 1 || all[0].AggregateID != "s-2" || all[0].Position != 2 {
+		t.Errorf("Expected the stream's events to leave the global log, others keeping their positions, got %v", all)
+	}
+	if ids := store.StreamIDs(); len(ids) != 1 || ids[0] != "s-2" {
+		t.Errorf("Expected only s-2 listed, got %v", ids)
+	}
+	if err := store.DeleteStream("s-1", 2); !errors.Is(err, ErrStreamNotFound) {
+		t.Errorf("Expected StreamNotFoundError deleting twice, got %v", err)
+	}
+}
+
+func TestEventStoreRedactEvent(t *testing.T) {
+	store := NewEventStore()
+	store.Append(NewEvent("CustomerRegistered", "c-1", 1, map[string]interface{}{"name": "Ada", "email": "ada@example.com", "plan": "pro"}, nil))
+	store.Append(NewEvent("PlanChanged", "c-1", 2, map[string]interface{}{"plan": "free"}, nil))
+	before, _ := store.GetStream("c-1")
+
+	audit, err := store.RedactEvent("c-1", 1, []string{"name", "email"})
+	if err != nil {
+		t.Fatalf("Error redacting event: %v", err)
+	}
+	stream, _ := store.GetStream("c-1")
+	if stream[0].Data["email"] != RedactedValue || stream[0].Data["name"] != RedactedValue || stream[0].Data["plan"] != "pro" {
+		t.Errorf("Expected name and email to be redacted, got %v", stream[0].Data)
+	}
+	if all := store.GetAllEvents(); all[0].Data["email"] != RedactedValue || len(all) != 3 {
+		t.Errorf("Expected the log to hold the redacted event and the audit event, got %v", all)
+	}
+	if before[0].Data["email"] != "ada@example.com" {
+		t.Errorf("Expected previously returned events to be unaffected, got %v", before[0].Data)
+	}
+
+	if audit.Type != RedactionEventType || audit.AggregateID != RedactionStreamID || audit.Version != 1 {
+		t.Errorf("Unexpected audit event: %+v", audit)
+	}
+	if fields := audit.Data["fields"].([]string); len(fields) != 2 || fields[0] != "email" || audit.Data["event_id"] != stream[0].ID {
+		t.Errorf("Expected the audit event to name the redacted fields and event, got %v", audit.Data)
+	}
+
+	var invalid *InvalidCommandError
+	if _, err := store.RedactEvent("c-1", 2, []string{"email"}); !errors.As(err, &invalid) {
+		t.Errorf("Expected InvalidCommandError for a missing field, got %v", err)
+	}
+	if _, err := store.RedactEvent("c-1", 5, []string{"plan"}); !errors.As(err, &invalid) {
+		t.Errorf("Expected InvalidCommandError for a missing version, got %v", err)
+	}
+	if _, err := store.RedactEvent(RedactionStreamID, 1, []string{"fields"}); !errors.As(err, &invalid) {
+		t.Errorf("Expected the audit stream to be protected, got %v", err)
+	}
+}
+
+// plainStore hides the native scan of the store it wraps
+type plainStore struct{ Store }
+
+func TestQueryEvents(t *testing.T) {
+	store := NewEventStore()
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	at := func(event *Event, offset time.Duration) *Event {
+		event.CreatedAt = start.Add(offset)
+		return event
+	}
+	store.Append(at(NewEvent("CartCreated", "cart-1", 1, nil, nil), 0))
+	store.Append(at(NewEvent("ItemAdded", "cart-1", 2, nil, nil), time.Minute))
+	store.Append(at(NewEvent("ItemAdded", "wishlist-1", 1, nil, nil), 2*time.Minute))
+	store.Append(at(NewEvent("ItemAdded", "cart-2", 1, nil, nil), 3*time.Minute))
+	store.Append(at(NewEvent("ItemRemoved", "cart-2", 2, nil, nil), 4*time.Minute))
+
+	filter := Filter{Types: []string{"ItemAdded"}, AggregatePrefix: "cart-", After: start}
+	for name, scanned := range map[string]Store{"native": store, "fallback": plainStore{store}} {
+		var matched []string
+		for event, err := range QueryEvents(scanned, filter) {
+			if err != nil {
+				t.Fatalf("%s: Error scanning: %v", name, err)
+			}
+			matched = append(matched, fmt.Sprintf("%s v%d", event.AggregateID, event.Version))
+		}
+		if !reflect.DeepEqual(matched, []string{"cart-1 v2", "cart-2 v1"}) {
+			t.Errorf("%s: Expected the cart items added after the start, got %v", name, matched)
+		}
+	}
+
+	before := Filter{Before: start.Add(2 * time.Minute)}
+	count := 0
+	for range QueryEvents(store, before) {
+		count++
+		// Appending while scanning neither blocks nor shows up in the scan
+		store.Append(at(NewEvent("ItemAdded", "cart-3", count, nil, nil), 0))
+	}
+	if count != 2 {
+		t.Errorf("Expected the 2 events before the bound, got %d", count)
+	}
+	for event := range QueryEvents(store, Filter{}) {
+		if event.Type != "CartCreated" {
+			t.Errorf("Expected the scan to stop when the loop breaks, got %s", event.Type)
+		}
+		break
+	}
+}
+
+func TestEventStoreWithinTransaction(t *testing.T) {
+	store := NewEventStore()
+	store.Append(NewEvent("CartCreated", "cart-1", 1, nil, nil))
+
+	failed := errors.New("payment declined")
+	err := WithinTransaction(store, func(tx Store) error {
+		tx.Append(NewEvent("CartCheckedOut", "cart-1", 2, nil, nil))
+		return failed
+	})
+	if err != failed || store.GetStreamVersion("cart-1") != 1 {
+		t.Fatalf("Expected a failed transaction to store nothing, got %v at version %d", err, store.GetStreamVersion("cart-1"))
+	}
+
+	appended := make(chan error)
+	err = WithinTransaction(store, func(tx Store) error {
+		// A concurrent append waits for the transaction, then conflicts with it
+		go func() { appended <- store.Append(NewEvent("ItemAdded", "cart-1", 2, nil, nil)) }()
+		if err := tx.Append(NewEvent("CartCheckedOut", "cart-1", 2, nil, nil)); err != nil {
+			return err
+		}
+		if err := tx.Append(NewEvent("OrderPlaced", "order-1", 1, nil, nil)); err != nil {
+			return err
+		}
+		if tx.GetStreamVersion("order-1") != 1 || store.GetStreamVersion("order-1") != 0 {
+			t.Errorf("Expected only the transaction to see its appends before it commits")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error committing transaction: %v", err)
+	}
+	var conflict *ConcurrencyError
+	if err := <-appended; !errors.As(err, &conflict) {
+		t.Errorf("Expected the concurrent append to conflict, got %v", err)
+	}
+	if all := store.GetAllEvents(); len(all) != 3 || all[1].Type != "CartCheckedOut" || all[2].AggregateID != "order-1" {
+		t.Errorf("Expected both streams to be appended, got %v", all)
+	}
+
+	if err := WithinTransaction(plainStore{store}, func(Store) error { return nil }); err == nil {
+		t.Error("Expected an error for a store without transactions")
+	}
+}
+
+func TestEventJSON_EffectiveAt(t *testing.T) {
+	event := NewEvent("PriceSet", "p-1", 1, nil, nil)
+	data, _ := json.Marshal(event)
+	if strings.Contains(string(data), "effective_at") {
+		t.Errorf("Expected no effective_at for an event without one, got %s", data)
+	}
+
+	effectiveAt := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	event.EffectiveAt = &effectiveAt
+	data, _ = json.Marshal(event)
+	var decoded Event
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.EffectiveAt == nil || !decoded.EffectiveAt.Equal(effectiveAt) {
+		t.Errorf("Expected effective_at to round-trip, got %s (%v)", data, err)
+	}
+}
+
+func TestAsOf(t *testing.T) {
+	jan := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := jan.AddDate(0, 1, 0)
+	mar := jan.AddDate(0, 2, 0)
+
+	price := NewEvent("PriceSet", "p-1", 1, map[string]interface{}{"price": 10}, nil)
+	price.CreatedAt = jan
+	// Recorded in March, but the price was wrong since February
+	correction := NewEvent("PriceCorrected", "p-1", 2, map[string]interface{}{"price": 12}, nil)
+	correction.CreatedAt = mar
+	correction.EffectiveAt = &feb
+	events := []*Event{price, correction}
+
+	if EffectiveTime(price) != jan || EffectiveTime(correction) != feb {
+		t.Errorf("Unexpected effective times: %v, %v", EffectiveTime(price), EffectiveTime(correction))
+	}
+	if got := AsOf(events, feb, time.Time{}); len(got) != 2 {
+		t.Errorf("Expected the correction to be in effect in February as known now, got %d events", len(got))
+	}
+	if got := AsOf(events, feb, feb); len(got) != 1 || got[0] != price {
+		t.Errorf("Expected only the original price to be known in February, got %v", got)
+	}
+	if got := AsOf(events, jan.AddDate(0, 0, 15), time.Time{}); len(got) != 1 {
+		t.Errorf("Expected the correction not to be in effect in January, got %d events", len(got))
+	}
+
+	store := NewEventStore()
+	store.Append(price)
+	store.Append(correction)
+	projection := &countingProjection{}
+	if err := ReplayProjectionAsOf(store, projection, mar, feb); err != nil || projection.count != 1 {
+		t.Errorf("Expected the replay to apply only what was known in February, got %d events (%v)", projection.count, err)
+	}
+}
+
+func TestNewULID(t *testing.T) {
+	before := time.Now().Truncate(time.Millisecond)
+	ids := make([]string, 1000)
+	for i := range ids {
+		ids[i] = NewULID()
+	}
+
+	for i, id := range ids {
+		if len(id) != 26 {
+			t.Fatalf("Expected a 26 character ULID, got %q", id)
+		}
+		if i > 0 && id <= ids[i-1] {
+			t.Fatalf("Expected strictly increasing ULIDs, got %q after %q", id, ids[i-1])
+		}
+	}
+	created, ok := ULIDTime(ids[0])
+	if !ok || created.Before(before) || created.After(time.Now()) {
+		t.Errorf("Expected the ULID to encode its creation time, got %v (%v)", created, ok)
+	}
+	if _, ok := ULIDTime(NewUUID()); ok {
+		t.Error("Expected a UUID not to parse as a ULID")
+	}
+}
+
+func TestSetIDGenerator(t *testing.T) {
+	SetIDGenerator(NewULID)
+	defer SetIDGenerator(NewUUID)
+
+	first := NewEvent("Created", "s-1", 1, nil, nil)
+	second := NewEvent("Renamed", "s-1", 2, nil, nil)
+	if _, ok := ULIDTime(first.ID); !ok {
+		t.Fatalf("Expected a ULID event ID, got %q", first.ID)
+	}
+
+	// Same timestamp: the ULID breaks the tie in creation order
+	second.CreatedAt = first.CreatedAt
+	events := []*Event{second, first}
+	SortEvents(events)
+	if events[0] != first {
+		t.Errorf("Expected the ID to break the tie, got %v", events)
+	}
+}
+
+func TestHLC(t *testing.T) {
+	physical := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := NewHLC(func() time.Time { return physical })
+
+	first := clock.Now()
+	second := clock.Now()
+	if !second.After(first) {
+		t.Errorf("Expected readings in the same instant to increase, got %v then %v", first, second)
+	}
+
+	// The wall clock jumps back a minute
+	physical = physical.Add(-time.Minute)
+	if third := clock.Now(); !third.After(second) {
+		t.Errorf("Expected the clock not to go back with the wall clock, got %v after %v", third, second)
+	}
+
+	// An event from a process whose clock runs an hour ahead
+	ahead := physical.Add(time.Hour)
+	clock.Observe(ahead)
+	if next := clock.Now(); !next.After(ahead) {
+		t.Errorf("Expected the clock to move past observed timestamps, got %v", next)
+	}
+}
+
+func TestHydrateObservesStreamTimestamps(t *testing.T) {
+	defer func(clock *HLC) { DefaultClock = clock }(DefaultClock)
+	DefaultClock = NewHLC(time.Now)
+
+	store := NewEventStore()
+	ahead := NewEvent("Created", "s-1", 1, nil, nil)
+	ahead.CreatedAt = time.Now().Add(time.Hour)
+	store.Append(ahead)
+
+	aggregate := NewBaseAggregate(store)
+	if err := aggregate.Hydrate("s-1", func(*Event) error { return nil }); err != nil {
+		t.Fatalf("Error hydrating: %v", err)
+	}
+	if next := NewEvent("Renamed", "s-1", 2, nil, nil); !next.CreatedAt.After(ahead.CreatedAt) {
+		t.Errorf("Expected the next event to be stamped after the stream's latest, got %v", next.CreatedAt)
+	}
+}
+
+func TestNewBinaryEvent(t *testing.T) {
+	event := NewBinaryEvent("ItemAdded", "cart-1", 1, "application/avro", []byte{0, 1, 2, 255}, nil)
+
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("Error encoding event: %v", err)
+	}
+	var decoded Event
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Error decoding event: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.Payload, event.Payload) || decoded.ContentType != "application/avro" || len(decoded.Data) != 0 {
+		t.Errorf("Expected the payload to round-trip, got %+v", decoded)
+	}
+
+	plain, _ := json.Marshal(NewEvent("ItemAdded", "cart-1", 1, nil, nil))
+	if strings.Contains(string(plain), "payload") {
+		t.Errorf("Expected events without a payload to omit it, got %s", plain)
+	}
+}
+
+type itemAddedV2 struct {
+	ItemID   string `json:"item_id"`
+	Quantity int    `json:"quantity"`
+}
+
+func TestAsTyped(t *testing.T) {
+	registry := NewRegistry()
+	registry.RegisterEvent(EventInfo{Name: "ItemAdded", SchemaVersion: 2})
+	registry.RegisterUpcaster(Upcaster{EventType: "ItemAdded", FromVersion: 1, Upcast: func(event *Event) (*Event, error) {
+		event.Data["item_id"] = event.Data["item"]
+		event.Data["quantity"] = 1
+		delete(event.Data, "item")
+		return event, nil
+	}})
+	RegisterPayload[itemAddedV2](registry, "ItemAdded")
+
+	stored := NewEvent("ItemAdded", "cart-1", 1, map[string]interface{}{"item": "apple"}, nil)
+	added, err := AsTypedIn[itemAddedV2](registry, stored)
+	if err != nil {
+		t.Fatalf("Error decoding payload: %v", err)
+	}
+	if added.Data.ItemID != "apple" || added.Data.Quantity != 1 || added.Event.AggregateID != "cart-1" {
+		t.Errorf("Expected the upcast payload, got %+v", added)
+	}
+
+	var typeErr *PayloadTypeError
+	if _, err := AsTypedIn[struct{ Item string }](registry, stored); !errors.As(err, &typeErr) || typeErr.Registered == "" {
+		t.Errorf("Expected a PayloadTypeError for the wrong type, got %v", err)
+	}
+	bad := NewEvent("ItemAdded", "cart-1", 2, map[string]interface{}{"item_id": "pear", "quantity": "two"}, map[string]interface{}{SchemaVersionKey: 2})
+	if _, err := AsTypedIn[itemAddedV2](registry, bad); !errors.As(err, &typeErr) {
+		t.Errorf("Expected a PayloadTypeError for a mistyped field, got %v", err)
+	}
+
+	event, err := NewTypedEvent("ItemAdded", "cart-1", 3, itemAddedV2{ItemID: "fig", Quantity: 2}, nil)
+	if err != nil || event.Data["item_id"] != "fig" || event.Data["quantity"] != float64(2) {
+		t.Errorf("Expected the payload in Data, got %v (%v)", event, err)
+	}
+	binary := NewBinaryEvent("ItemAdded", "cart-1", 4, "application/protobuf", []byte{1}, map[string]interface{}{SchemaVersionKey: 2})
+	if _, err := AsTypedIn[itemAddedV2](registry, binary); !errors.As(err, &typeErr) {
+		t.Errorf("Expected a PayloadTypeError for a binary payload, got %v", err)
+	}
+}
+
+// basket declares its event handlers by convention
+type basket struct {
+	id     string
+	items  map[string]int
+	closed string
+}
+
+func (b *basket) OnBasketOpened(event *Event) { b.id = event.AggregateID }
+
+func (b *basket) OnItemAdded(data itemAddedV2) { b.items[data.ItemID] += data.Quantity }
+
+func (b *basket) OnItemRemoved(data itemAddedV2, event *Event) error {
+	if b.items[data.ItemID] < data.Quantity {
+		return fmt.Errorf("%s v%d removes more %s than the basket holds", event.AggregateID, event.Version, data.ItemID)
+	}
+	b.items[data.ItemID] -= data.Quantity
+	return nil
+}
+
+func (b *basket) OnBasketClosed() { b.closed = "closed" }
+
+// Once is not a handler: no event type follows "On"
+func (b *basket) Once() {}
+
+// badBasket has a handler returning something other than an error
+type badBasket struct{}
+
+func (b *badBasket) OnItemAdded(data itemAddedV2) int { return data.Quantity }
+
+func TestEventHandlers_DispatchByConvention(t *testing.T) {
+	registry := NewRegistry()
+	RegisterPayload[itemAddedV2](registry, "ItemAdded")
+	handlers, err := NewEventHandlers[*basket](registry)
+	if err != nil {
+		t.Fatalf("Error collecting handlers: %v", err)
+	}
+	handlers.Ignore("BasketRenamed")
+	if types := handlers.EventTypes(); !reflect.DeepEqual(types, []string{"BasketClosed", "BasketOpened", "ItemAdded", "ItemRemoved"}) {
+		t.Errorf("Unexpected handled event types: %v", types)
+	}
+
+	b := &basket{items: make(map[string]int)}
+	events := []*Event{
+		NewEvent("BasketOpened", "basket-1", 1, nil, nil),
+		NewEvent("ItemAdded", "basket-1", 2, map[string]interface{}{"item_id": "apple", "quantity": 3}, nil),
+		NewEvent("BasketRenamed", "basket-1", 3, map[string]interface{}{"name": "fruit"}, nil),
+		NewEvent("ItemRemoved", "basket-1", 4, map[string]interface{}{"item_id": "apple", "quantity": 1}, nil),
+		NewEvent("BasketClosed", "basket-1", 5, nil, nil),
+	}
+	for _, event := range events {
+		if err := handlers.Dispatch(b, event); err != nil {
+			t.Fatalf("Error dispatching %s: %v", event.Type, err)
+		}
+	}
+	if b.id != "basket-1" || b.items["apple"] != 2 || b.closed != "closed" {
+		t.Errorf("Expected the handlers to fold the events, got %+v", b)
+	}
+
+	overdrawn := NewEvent("ItemRemoved", "basket-1", 6, map[string]interface{}{"item_id": "apple", "quantity": 5}, nil)
+	if err := handlers.Dispatch(b, overdrawn); err == nil || !strings.Contains(err.Error(), "basket-1 v6") {
+		t.Errorf("Expected the handler's error, got %v", err)
+	}
+	mistyped := NewEvent("ItemAdded", "basket-1", 6, map[string]interface{}{"item_id": "pear", "quantity": "two"}, nil)
+	if err := handlers.Dispatch(b, mistyped); !errors.Is(err, ErrPayloadType) {
+		t.Errorf("Expected a PayloadTypeError for a mistyped payload, got %v", err)
+	}
+	if err := handlers.Dispatch(b, NewEvent("BasketShared", "basket-1", 6, nil, nil)); err == nil || !strings.Contains(err.Error(), "unhandled event type: BasketShared") {
+		t.Errorf("Expected an error for an unhandled event type, got %v", err)
+	}
+
+	if _, err := NewEventHandlers[*badBasket](registry); err == nil || !strings.Contains(err.Error(), "OnItemAdded") {
+		t.Errorf("Expected an error naming the unsupported handler, got %v", err)
+	}
+}
+
+// basketLine is a child entity of a basket, one per item
+type basketLine struct {
+	item     string
+	quantity int
+}
+
+func (l *basketLine) OnItemAdded(data itemAddedV2) { l.quantity += data.Quantity }
+
+func (l *basketLine) OnItemRemoved(data itemAddedV2) { l.quantity -= data.Quantity }
+
+func (l *basketLine) Retired() bool { return l.quantity <= 0 }
+
+func TestEntities_RouteEventsToEntities(t *testing.T) {
+	registry := NewRegistry()
+	RegisterPayload[itemAddedV2](registry, "ItemAdded")
+	route := func(event *Event) (string, error) {
+		item, _ := event.Data["item_id"].(string)
+		return item, nil
+	}
+	lines, err := NewEntityType(registry, func(id string) *basketLine { return &basketLine{item: id} }, route)
+	if err != nil {
+		t.Fatalf("Error creating entity type: %v", err)
+	}
+	if types := lines.EventTypes(); !reflect.DeepEqual(types, []string{"ItemAdded", "ItemRemoved"}) {
+		t.Errorf("Unexpected handled event types: %v", types)
+	}
+
+	entities := lines.NewEntities()
+	events := []*Event{
+		NewEvent("BasketOpened", "basket-1", 1, nil, nil),
+		NewEvent("ItemAdded", "basket-1", 2, map[string]interface{}{"item_id": "apple", "quantity": 3}, nil),
+		NewEvent("ItemAdded", "basket-1", 3, map[string]interface{}{"item_id": "pear", "quantity": 1}, nil),
+		NewEvent("ItemAdded", "basket-1", 4, map[string]interface{}{"quantity": 1}, nil),
+		NewEvent("ItemRemoved", "basket-1", 5, map[string]interface{}{"item_id": "apple", "quantity": 1}, nil),
+		NewEvent("ItemRemoved", "basket-1", 6, map[string]interface{}{"item_id": "pear", "quantity": 1}, nil),
+	}
+	for _, event := range events {
+		if err := entities.On(event); err != nil {
+			t.Fatalf("Error applying %s: %v", event.Type, err)
+		}
+	}
+	if ids := entities.IDs(); !reflect.DeepEqual(ids, []string{"apple"}) {
+		t.Errorf("Expected the retired pear line dropped and unrouted events ignored, got %v", ids)
+	}
+	if apple, ok := entities.Get("apple"); !ok || apple.item != "apple" || apple.quantity != 2 {
+		t.Errorf("Expected the apple line created on its first event, got %+v", apple)
+	}
+
+	mistyped := NewEvent("ItemAdded", "basket-1", 7, map[string]interface{}{"item_id": "apple", "quantity": "two"}, nil)
+	if err := entities.On(mistyped); !errors.Is(err, ErrPayloadType) {
+		t.Errorf("Expected a PayloadTypeError for a mistyped payload, got %v", err)
+	}
+	entities.Clear()
+	if entities.Len() != 0 {
+		t.Errorf("Expected no entities after Clear, got %d", entities.Len())
+	}
+}
+
+func TestErrorTaxonomy(t *testing.T) {
+	cases := []struct {
+		err      error
+		sentinel error
+		code     ErrorCode
+	}{
+		{&StreamNotFoundError{StreamID: "s-1"}, ErrStreamNotFound, CodeStreamNotFound},
+		{&ConcurrencyError{StreamID: "s-1"}, ErrConcurrency, CodeConcurrency},
+		{&InvalidCommandError{Message: "bad"}, ErrInvalidCommand, CodeInvalidCommand},
+		{&ValidationError{}, ErrValidation, CodeValidation},
+		{&UnknownCommandError{CommandType: "X"}, ErrUnknownCommand, CodeUnknownCommand},
+		{&UnknownQueryError{QueryType: "X"}, ErrUnknownQuery, CodeUnknownQuery},
+		{&UnauthorizedError{Principal: "bob"}, ErrUnauthorized, CodeUnauthorized},
+		{&StreamAccessError{Principal: "bob"}, ErrAccessDenied, CodeAccessDenied},
+		{&MissingUpcasterError{EventType: "X"}, ErrMissingUpcaster, CodeMissingUpcaster},
+		{&PayloadTypeError{EventType: "X"}, ErrPayloadType, CodePayloadType},
+		{&StaleReadError{Token: ConsistencyToken{StreamID: "s-1", Version: 2}}, ErrStaleRead, CodeStaleRead},
+		{&UnauthenticatedError{Scheme: "Bearer", Reason: "bad signature"}, ErrUnauthenticated, CodeUnauthenticated},
+		{&RateLimitedError{Scope: "aggregate", Key: "s-1"}, ErrRateLimited, CodeRateLimited},
+		{&EventNotAllowedError{StreamID: "s-1", EventType: "X"}, ErrEventNotAllowed, CodeEventNotAllowed},
+		{ErrAggregateNotLive, ErrAggregateNotLive, CodeAggregateNotLive},
+		{&AggregateClosedError{StreamID: "s-1", ClosedBy: "Closed"}, ErrAggregateClosed, CodeAggregateClosed},
+		{&AggregateReadOnlyError{StreamID: "s-1", Version: 2}, ErrAggregateReadOnly, CodeAggregateReadOnly},
+	}
+	for _, c := range cases {
+		wrapped := fmt.Errorf("handling command: %w", c.err)
+		if !errors.Is(wrapped, c.sentinel) {
+			t.Errorf("Expected %T to match %v", c.err, c.sentinel)
+		}
+		if errors.Is(wrapped, ErrInvalidCommand) != (c.sentinel == ErrInvalidCommand) {
+			t.Errorf("Expected %T to match only its own sentinel", c.err)
+		}
+		if code := ErrorCodeOf(wrapped); code != c.code {
+			t.Errorf("Expected code %q for %T, got %q", c.code, c.err, code)
+		}
+	}
+	if code := ErrorCodeOf(errors.New("boom")); code != CodeInternal {
+		t.Errorf("Expected CodeInternal for other errors, got %q", code)
+	}
+}
+
+// tallyAggregate records the stream version it was hydrated to
+type tallyAggregate struct {
+	*BaseAggregate
+}
+
+func (a *tallyAggregate) On(event *Event) error {
+	a.SetID(event.AggregateID)
+	return a.Advance(event)
+}
+
+func (a *tallyAggregate) Handle(command Command) (*Event, error) {
+	return nil, &UnknownCommandError{CommandType: command.CommandType()}
+}
+
+func (a *tallyAggregate) Hydrate(id string) error {
+	return a.BaseAggregate.Hydrate(id, a.On)
+}
+
+func TestRepository_LoadIfChanged(t *testing.T) {
+	store := NewEventStore()
+	store.Append(NewEvent("Opened", "tally-1", 1, nil, nil))
+	store.Append(NewEvent("Counted", "tally-1", 2, nil, nil))
+
+	hydrations := 0
+	repository := NewRepository(store, func(store Store) *tallyAggregate {
+		hydrations++
+		return &tallyAggregate{BaseAggregate: NewBaseAggregate(store)}
+	})
+
+	first, changed, err := repository.LoadIfChanged("tally-1", 0)
+	if err != nil {
+		t.Fatalf("Error loading: %v", err)
+	}
+	if !changed || first.Version() != 2 || hydrations != 1 {
+		t.Errorf("Expected a first load to replay, got changed=%v version=%d hydrations=%d", changed, first.Version(), hydrations)
+	}
+
+	again, changed, err := repository.LoadIfChanged("tally-1", 2)
+	if err != nil {
+		t.Fatalf("Error loading: %v", err)
+	}
+	if changed || again != first || hydrations != 1 {
+		t.Errorf("Expected the cached aggregate without replay, got changed=%v hydrations=%d", changed, hydrations)
+	}
+
+	store.Append(NewEvent("Counted", "tally-1", 3, nil, nil))
+	moved, changed, err := repository.LoadIfChanged("tally-1", 2)
+	if err != nil {
+		t.Fatalf("Error loading: %v", err)
+	}
+	if !changed || moved.Version() != 3 || hydrations != 2 {
+		t.Errorf("Expected a moved stream to replay, got changed=%v version=%d hydrations=%d", changed, moved.Version(), hydrations)
+	}
+
+	if _, _, err := repository.LoadIfChanged("missing", 0); !errors.Is(err, ErrStreamNotFound) {
+		t.Errorf("Expected ErrStreamNotFound, got %v", err)
+	}
+	if _, err := repository.Load("missing"); !errors.Is(err, ErrStreamNotFound) {
+		t.Errorf("Expected ErrStreamNotFound from Load, got %v", err)
+	}
+}
+
+// countCommand names the tally it counts
+type countCommand struct{ TallyID string }
+
+func (c *countCommand) AggregateID() string { return c.TallyID }
+func (c *countCommand) CommandType() string { return "Count" }
+
+func TestRepository_LoadFor(t *testing.T) {
+	store := NewEventStore()
+	store.Append(NewEvent("Opened", "tally-1", 1, nil, nil))
+	store.Append(NewEvent("Counted", "tally-1", 2, nil, nil))
+	repository := NewRepository(store, func(store Store) *tallyAggregate {
+		return &tallyAggregate{BaseAggregate: NewBaseAggregate(store)}
+	})
+
+	loaded, err := repository.LoadFor(&countCommand{TallyID: "tally-1"})
+	if err != nil {
+		t.Fatalf("Error loading: %v", err)
+	}
+	if !loaded.IsLive() || loaded.Version() != 2 {
+		t.Errorf("Expected the named stream hydrated, got live=%v version=%d", loaded.IsLive(), loaded.Version())
+	}
+	if again, _ := repository.LoadFor(&countCommand{TallyID: "tally-1"}); again == loaded {
+		t.Errorf("Expected a fresh aggregate for every command")
+	}
+	if cached, _, _ := repository.LoadIfChanged("tally-1", 0); cached == loaded {
+		t.Errorf("Expected aggregates loaded for commands kept out of the cache")
+	}
+
+	fresh, err := repository.LoadFor(&countCommand{})
+	if err != nil || fresh.IsLive() || fresh.Version() != 0 {
+		t.Errorf("Expected a new aggregate for a command naming no stream, got %v, %v", fresh, err)
+	}
+	if _, err := repository.LoadFor(&countCommand{TallyID: "missing"}); !errors.Is(err, ErrStreamNotFound) {
+		t.Errorf("Expected ErrStreamNotFound, got %v", err)
+	}
+}
+
+// slowStore counts the stream reads in flight
+type slowStore struct {
+	*EventStore
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+}
+
+func (s *slowStore) GetStream(id string) ([]*Event, error) {
+	s.mu.Lock()
+	s.inFlight++
+	if s.inFlight > s.peak {
+		s.peak = s.inFlight
+	}
+	s.mu.Unlock()
+
+	time.Sleep(time.Millisecond)
+
+	s.mu.Lock()
+	s.inFlight--
+	s.mu.Unlock()
+	return s.EventStore.GetStream(id)
+}
+
+func TestRepository_LoadMany(t *testing.T) {
+	store := &slowStore{EventStore: NewEventStore()}
+	ids := make([]string, 0, 21)
+	for i := 0; i < 20; i++ {
+		id := fmt.Sprintf("tally-%d", i)
+		store.Append(NewEvent("Opened", id, 1, nil, nil))
+		ids = append(ids, id)
+	}
+	ids = append(ids, "missing", "tally-0")
+
+	repository := NewRepository[*tallyAggregate](store, func(store Store) *tallyAggregate {
+		return &tallyAggregate{BaseAggregate: NewBaseAggregate(store)}
+	})
+	repository.Parallelism = 4
+
+	loaded, err := repository.LoadMany(ids)
+	if len(loaded) != 20 || loaded["tally-7"].ID() != "tally-7" {
+		t.Errorf("Expected the 20 existing aggregates, got %d", len(loaded))
+	}
+	var loadErr *LoadManyError
+	if !errors.As(err, &loadErr) || len(loadErr.Errors) != 1 || loadErr.Requested != 21 {
+		t.Fatalf("Expected a LoadManyError for the missing stream, got %v", err)
+	}
+	if !errors.Is(err, ErrStreamNotFound) || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("Expected the per-ID error to be reachable, got %v", err)
+	}
+	if store.peak > 4 {
+		t.Errorf("Expected at most 4 concurrent hydrations, got %d", store.peak)
+	}
+}
+
+func TestRepository_LoadCategory(t *testing.T) {
+	store := NewEventStore()
+	repository := NewRepository(store, func(store Store) *tallyAggregate {
+		return &tallyAggregate{BaseAggregate: NewBaseAggregate(store)}
+	})
+	repository.Category = "tally"
+
+	id := repository.NewStreamID()
+	if _, err := uuid.Parse(strings.TrimPrefix(id, "tally-")); err != nil || DefaultStreamNamer.Category(id) != "tally" {
+		t.Errorf("Expected a tally-<uuid> stream ID, got %q", id)
+	}
+	store.Append(NewEvent("Opened", id, 1, nil, nil))
+	store.Append(NewEvent("Opened", "tally-2", 1, nil, nil))
+	store.Append(NewEvent("Opened", "counter-1", 1, nil, nil))
+	store.Append(NewEvent("Counted", "tally-2", 2, nil, nil))
+
+	loaded, err := repository.LoadCategory()
+	if err != nil || len(loaded) != 2 || loaded["tally-2"].Version() != 2 {
+		t.Errorf("Expected the 2 tally aggregates, got %v, %v", loaded, err)
+	}
+
+	events, err := ReadCategory(store, DefaultStreamNamer, "tally")
+	if err != nil || len(events) != 3 || events[2].Type != "Counted" {
+		t.Errorf("Expected the 3 tally events in append order, got %v, %v", events, err)
+	}
+	if ids := CategoryStreamIDs(store, DefaultStreamNamer, "counter"); len(ids) != 1 || ids[0] != "counter-1" {
+		t.Errorf("Expected counter-1 in the counter category, got %v", ids)
+	}
+	if category := DefaultStreamNamer.Category("$commands"); category != "" {
+		t.Errorf("Expected no category without a dash, got %q", category)
+	}
+
+	sequential := CategoryStreamNamer{NewID: func() string { return "7" }}
+	if id := sequential.NewStreamID("tally"); id != "tally-7" {
+		t.Errorf("Expected tally-7 from a custom ID generator, got %q", id)
+	}
+}
+
+func TestUnitOfWork_CommitsWithInlineProjections(t *testing.T) {
+	store := NewEventStore()
+	store.Append(NewEvent("Opened", "tally-1", 1, nil, nil))
+	projection := &countingProjection{}
+	inline := NewInlineProjections(projection)
+
+	uow := NewUnitOfWork(store, inline)
+	uow.Append(NewEvent("Counted", "tally-1", 2, nil, nil))
+	uow.Append(NewEvent("Opened", "tally-2", 1, nil, nil))
+
+	var conflict *ConcurrencyError
+	if err := uow.Append(NewEvent("Counted", "tally-1", 2, nil, nil)); !errors.As(err, &conflict) {
+		t.Errorf("Expected pending events to count toward the stream version, got %v", err)
+	}
+	if stream, _ := uow.GetStream("tally-1"); len(stream) != 2 || uow.GetStreamVersion("tally-2") != 1 {
+		t.Errorf("Expected the unit of work to see its pending events, got %v", stream)
+	}
+	if ids := uow.StreamIDs(); len(ids) != 2 || ids[1] != "tally-2" {
+		t.Errorf("Expected pending streams to be listed, got %v", ids)
+	}
+	if store.GetStreamVersion("tally-1") != 1 || projection.count != 0 {
+		t.Fatal("Expected nothing to be stored or projected before Commit")
+	}
+
+	if err := uow.Commit(); err != nil {
+		t.Fatalf("Error committing: %v", err)
+	}
+	if store.GetStreamVersion("tally-1") != 2 || store.GetStreamVersion("tally-2") != 1 {
+		t.Errorf("Expected the pending events to be stored")
+	}
+	inline.View("counting", func(p Projection) {
+		if p.State() != 2 {
+			t.Errorf("Expected the inline projection to see 2 events, got %v", p.State())
+		}
+	})
+	if len(uow.Pending()) != 0 {
+		t.Errorf("Expected no pending events after Commit")
+	}
+}
+
+func TestUnitOfWork_FailedCommitStoresNothing(t *testing.T) {
+	store := NewEventStore()
+	projection := &countingProjection{}
+	uow := NewUnitOfWork(store, NewInlineProjections(projection))
+	uow.Append(NewEvent("Opened", "tally-1", 1, nil, nil))
+
+	// Another writer gets there first
+	store.Append(NewEvent("Opened", "tally-1", 1, nil, nil))
+
+	if err := uow.Commit(); !errors.Is(err, ErrConcurrency) {
+		t.Fatalf("Expected a concurrency conflict, got %v", err)
+	}
+	if len(store.GetAllEvents()) != 1 || projection.count != 0 {
+		t.Errorf("Expected neither the store nor the projection to change")
+	}
+
+	uow.Append(NewEvent("Counted", "tally-1", 2, nil, nil))
+	uow.Rollback()
+	if err := uow.Commit(); err != nil || store.GetStreamVersion("tally-1") != 1 {
+		t.Errorf("Expected a rolled back unit of work to commit nothing, got %v", err)
+	}
+}
+
+func TestConsistencyToken(t *testing.T) {
+	token := TokenFor(NewEvent("Counted", "user@example.com", 3, nil, nil))
+	if token.String() != "user@example.com@3" {
+		t.Errorf("Unexpected token %q", token)
+	}
+	parsed, err := ParseConsistencyToken(token.String())
+	if err != nil || parsed != token {
+		t.Errorf("Expected the token to round-trip, got %v, %v", parsed, err)
+	}
+	for _, invalid := range []string{"", "tally-1", "@3", "tally-1@", "tally-1@0", "tally-1@x"} {
+		if _, err := ParseConsistencyToken(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+
+	ctx := WithConsistencyToken(context.Background(), token)
+	if got, ok := ConsistencyTokenFrom(ctx); !ok || got != token {
+		t.Errorf("Expected the token back from the context, got %v", got)
+	}
+}
+
+func TestAsyncProjection_ExecuteAtLeast(t *testing.T) {
+	store := NewEventStore()
+	projection := NewAsyncProjection(store, &countingProjection{})
+	projection.MaxWait = time.Millisecond
+	ctx := context.Background()
+
+	store.Append(NewEvent("Opened", "tally-1", 1, nil, nil))
+	var count interface{}
+	if err := projection.ExecuteAtLeast(ctx, ConsistencyToken{}, func(p Projection) { count = p.State() }); err != nil || count != 0 {
+		t.Errorf("Expected a zero token to read without catching up, got %v, %v", count, err)
+	}
+
+	// Without a background catch-up the read falls back to replaying inline
+	store.Append(NewEvent("Counted", "tally-1", 2, nil, nil))
+	token := ConsistencyToken{StreamID: "tally-1", Version: 2}
+	if err := projection.ExecuteAtLeast(ctx, token, func(p Projection) { count = p.State() }); err != nil || count != 2 {
+		t.Errorf("Expected the read to observe the write, got %v, %v", count, err)
+	}
+
+	// A background catch-up wakes waiting readers before MaxWait
+	projection.MaxWait = time.Minute
+	store.Append(NewEvent("Counted", "tally-1", 3, nil, nil))
+	go projection.CatchUp()
+	token.Version = 3
+	if err := projection.ExecuteAtLeast(ctx, token, func(p Projection) { count = p.State() }); err != nil || count != 3 {
+		t.Errorf("Expected the read to observe the write, got %v, %v", count, err)
+	}
+	if checkpoint := projection.Checkpoint(); checkpoint.Position != 3 {
+		t.Errorf("Expected checkpoint at 3, got %d", checkpoint.Position)
+	}
+
+	projection.MaxWait = time.Millisecond
+	token.Version = 5
+	var stale *StaleReadError
+	err := projection.ExecuteAtLeast(ctx, token, func(Projection) { t.Error("Expected no read of a stale projection") })
+	if !errors.As(err, &stale) || stale.Version != 3 || ErrorCodeOf(err) != CodeStaleRead {
+		t.Errorf("Expected a stale read at version 3, got %v", err)
+	}
+}
+
+// snapshottingProjection is a countingProjection saved in snapshots
+type snapshottingProjection struct {
+	countingProjection
+}
+
+func (p *snapshottingProjection) SnapshotState() (interface{}, error) { return p.count, nil }
+func (p *snapshottingProjection) RestoreSnapshot(snapshot Snapshot) error {
+	return json.Unmarshal(snapshot.State, &p.count)
+}
+
+func TestAsyncProjection_ResumesFromSnapshot(t *testing.T) {
+	store := NewEventStore()
+	snapshots := NewStoreSnapshots(NewEventStore())
+	for version := 1; version <= 3; version++ {
+		store.Append(NewEvent("Counted", "tally-1", version, nil, nil))
+	}
+
+	projection := NewAsyncProjection(store, &snapshottingProjection{})
+	projection.Snapshots = snapshots
+	projection.SnapshotEvery = 2
+	if err := projection.CatchUp(); err != nil {
+		t.Fatalf("Error catching up: %v", err)
+	}
+	store.Append(NewEvent("Counted", "tally-2", 1, nil, nil))
+	if err := projection.CatchUp(); err != nil {
+		t.Fatalf("Error catching up: %v", err)
+	}
+	if snapshot, found, _ := snapshots.LoadSnapshot(ProjectionSnapshotID("counting")); !found || snapshot.Version != 3 {
+		t.Fatalf("Expected one snapshot at position 3, got %+v", snapshot)
+	}
+
+	// After a restart the projection applies only the events after the snapshot
+	restarted := &snapshottingProjection{}
+	resumed := NewAsyncProjection(store, restarted)
+	resumed.Snapshots = snapshots
+	resumed.MaxWait = time.Millisecond
+	if restored, err := resumed.Restore(); err != nil || !restored || restarted.count != 3 {
+		t.Fatalf("Expected the snapshot to be restored, got %v (%v) with count %d", restored, err, restarted.count)
+	}
+	var count interface{}
+	token := ConsistencyToken{StreamID: "tally-1", Version: 3}
+	if err := resumed.ExecuteAtLeast(context.Background(), token, func(p Projection) { count = p.State() }); err != nil || count != 3 {
+		t.Errorf("Expected writes before the snapshot to be observed, got %v, %v", count, err)
+	}
+	if err := resumed.CatchUp(); err != nil || restarted.count != 4 || resumed.Checkpoint().Position != 4 {
+		t.Errorf("Expected only the last event applied, got %d at %+v (%v)", restarted.count, resumed.Checkpoint(), err)
+	}
+	if _, err := resumed.Restore(); err == nil {
+		t.Error("Expected restoring a projection that caught up to fail")
+	}
+	if err := resumed.Snapshot(); err != nil {
+		t.Fatalf("Error snapshotting: %v", err)
+	}
+
+	// A snapshot past the end of the log is ignored
+	emptied := NewAsyncProjection(NewEventStore(), &snapshottingProjection{})
+	emptied.Snapshots = snapshots
+	if restored, err := emptied.Restore(); err != nil || restored {
+		t.Errorf("Expected the snapshot past the end of the log to be ignored, got %v (%v)", restored, err)
+	}
+	if restored, err := NewAsyncProjection(store, &countingProjection{}).Restore(); err != nil || restored {
+		t.Errorf("Expected a projection without snapshots to start over, got %v (%v)", restored, err)
+	}
+}
+
+func TestAsyncProjection_CheckpointSurvivesDeleteStream(t *testing.T) {
+	store := NewEventStore()
+	store.Append(NewEvent("Counted", "tally-1", 1, nil, nil))
+	store.Append(NewEvent("Counted", "tally-2", 1, nil, nil))
+	store.Append(NewEvent("Counted", "tally-1", 2, nil, nil))
+
+	counting := &snapshottingProjection{}
+	projection := NewAsyncProjection(store, counting)
+	projection.Snapshots = NewStoreSnapshots(NewEventStore())
+	if err := projection.CatchUp(); err != nil {
+		t.Fatalf("Error catching up: %v", err)
+	}
+	if err := projection.Snapshot(); err != nil {
+		t.Fatalf("Error snapshotting: %v", err)
+	}
+
+	// The log shrinks below the checkpoint, but later events keep their positions
+	if err := store.DeleteStream("tally-2", 1); err != nil {
+		t.Fatalf("Error deleting stream: %v", err)
+	}
+	store.Append(NewEvent("Counted", "tally-1", 3, nil, nil))
+	if err := projection.CatchUp(); err != nil || counting.count != 4 || projection.Checkpoint().Position != 4 {
+		t.Errorf("Expected the event appended after the delete applied, got %d at %+v (%v)", counting.count, projection.Checkpoint(), err)
+	}
+
+	restarted := &snapshottingProjection{}
+	resumed := NewAsyncProjection(store, restarted)
+	resumed.Snapshots = projection.Snapshots
+	if restored, err := resumed.Restore(); err != nil || !restored {
+		t.Fatalf("Expected the snapshot to be restored, got %v (%v)", restored, err)
+	}
+	if err := resumed.CatchUp(); err != nil || restarted.count != 4 {
+		t.Errorf("Expected only the event after the snapshot applied, got %d (%v)", restarted.count, err)
+	}
+}
+
+// streamCounts counts the events of every stream, which partitions don't share
+type streamCounts struct {
+	counts map[string]int
+	failOn string
+}
+
+func (p *streamCounts) Name() string       { return "stream-counts" }
+func (p *streamCounts) State() interface{} { return p.counts }
+func (p *streamCounts) On(event *Event) error {
+	if event.Type == p.failOn {
+		return errors.New("cannot count")
+	}
+	p.counts[event.AggregateID]++
+	return nil
+}
+func (p *streamCounts) Merge(other Projection) error {
+	for streamID, count := range other.(*streamCounts).counts {
+		p.counts[streamID] += count
+	}
+	return nil
+}
+
+func TestRebuildParallel(t *testing.T) {
+	store := NewEventStore()
+	for i := 0; i < 20; i++ {
+		streamID := fmt.Sprintf("stream-%d", i)
+		for version := 1; version <= i%3+1; version++ {
+			store.Append(NewEvent("Counted", streamID, version, nil, nil))
+		}
+	}
+	factory := func() Projection { return &streamCounts{counts: make(map[string]int)} }
+
+	projection, checkpoint, err := RebuildParallel(store, factory, 4)
+	if err != nil {
+		t.Fatalf("Error rebuilding: %v", err)
+	}
+	sequential, _, _ := RebuildParallel(store, factory, 1)
+	if !reflect.DeepEqual(projection.State(), sequential.State()) || len(projection.State().(map[string]int)) != 20 {
+		t.Errorf("Expected the merged partitions to match a sequential replay, got %v and %v", projection.State(), sequential.State())
+	}
+	if checkpoint != (Checkpoint{Projection: "stream-counts", Position: 39}) {
+		t.Errorf("Unexpected checkpoint: %+v", checkpoint)
+	}
+	if PartitionOf("stream-1", 4) != PartitionOf("stream-1", 4) || PartitionOf("stream-1", 1) != 0 {
+		t.Error("Expected a stable partition per aggregate")
+	}
+
+	// Projections that are not partition-safe are replayed in order
+	counting, checkpoint, err := RebuildParallel(store, func() Projection { return &countingProjection{} }, 4)
+	if err != nil || counting.State() != 39 || checkpoint.Position != 39 {
+		t.Errorf("Expected a sequential replay of 39 events, got %v at %+v (%v)", counting.State(), checkpoint, err)
+	}
+
+	store.Append(NewEvent("Failing", "stream-3", 2, nil, nil))
+	failing := func() Projection { return &streamCounts{counts: make(map[string]int), failOn: "Failing"} }
+	if _, checkpoint, err := RebuildParallel(store, failing, 4); err == nil || checkpoint.Position != 0 {
+		t.Errorf("Expected the failing partition to fail the rebuild, got %+v (%v)", checkpoint, err)
+	}
+}
+
+func TestMetadataStore(t *testing.T) {
+	store := NewEventStore()
+	ctx := WithEventMetadata(context.Background(), ActorIDKey, "alice")
+	ctx = WithEventMetadata(ctx, "tenant", "acme")
+	if MetadataStore(store, EventMetadataFrom(context.Background())) != Store(store) {
+		t.Error("Expected the store itself without metadata")
+	}
+
+	enriched := MetadataStore(store, EventMetadataFrom(ctx))
+	enriched.Append(NewEvent("Opened", "tally-1", 1, nil, map[string]interface{}{"tenant": "other"}))
+	event := store.GetAllEvents()[0]
+	if event.Metadata[ActorIDKey] != "alice" || event.Metadata["tenant"] != "other" {
+		t.Errorf("Expected the actor added and the event's tenant kept, got %v", event.Metadata)
+	}
+}
+
+func TestWindowSpec_Assign(t *testing.T) {
+	at := time.Date(2026, 3, 1, 10, 25, 0, 0, time.UTC)
+	if windows := TumblingWindows(time.Hour).Assign(at); len(windows) != 1 || !windows[0].Start.Equal(at.Truncate(time.Hour)) || !windows[0].Contains(at) {
+		t.Errorf("Expected the 10:00 hour, got %v", windows)
+	}
+
+	windows := SlidingWindows(time.Hour, 15*time.Minute).Assign(at)
+	if len(windows) != 4 || windows[0].Start.Format("15:04") != "09:30" || windows[3].Start.Format("15:04") != "10:15" {
+		t.Fatalf("Expected the four windows from 09:30 to 10:15, got %v", windows)
+	}
+	for _, window := range windows {
+		if !window.Contains(at) {
+			t.Errorf("Expected %v to contain %v", window, at)
+		}
+	}
+}
+
+func TestWindowedProjection_ReplayMatchesLive(t *testing.T) {
+	store := NewEventStore()
+	base := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	count := func(n int, _ *Event) int { return n + 1 }
+	newProjection := func() *WindowedProjection[int] {
+		projection := NewWindowedProjection("visits", []string{"Visited"}, TumblingWindows(time.Hour), count)
+		projection.SetRetention(2 * time.Hour)
+		return projection
+	}
+
+	live := newProjection()
+	for i, offset := range []time.Duration{5 * time.Minute, 50 * time.Minute, 70 * time.Minute, 4 * time.Hour, 30 * time.Minute, 3*time.Hour + 10*time.Minute} {
+		event := NewEvent("Visited", "site-1", i+1, nil, nil)
+		event.CreatedAt = base.Add(offset)
+		store.Append(event)
+		live.On(event)
+	}
+	store.Append(NewEvent("Ignored", "site-1", 7, nil, nil))
+
+	rebuilt := newProjection()
+	if _, err := ReplayProjection(store, rebuilt, 0, nil); err != nil {
+		t.Fatalf("Error replaying projection: %v", err)
+	}
+	if !reflect.DeepEqual(live.Windows(), rebuilt.Windows()) || live.Late() != rebuilt.Late() {
+		t.Errorf("Expected the rebuild to match the live projection, got %v and %v", rebuilt.Windows(), live.Windows())
+	}
+
+	// The 10:30 visit arrived after the 10:00 hour fell out of the retention
+	windows := rebuilt.Windows()
+	if len(windows) != 2 || windows[0].Start.Hour() != 13 || windows[0].State != 1 || windows[1].Start.Hour() != 14 || rebuilt.Late() != 1 {
+		t.Errorf("Expected the 13:00 and 14:00 hours and one late visit, got %v, %d late", windows, rebuilt.Late())
+	}
+	if closed := rebuilt.Closed(rebuilt.Watermark()); len(closed) != 1 || closed[0].Start.Hour() != 13 {
+		t.Errorf("Expected the 13:00 hour closed at the watermark, got %v", closed)
+	}
+	if closed := rebuilt.Closed(base.Add(5 * time.Hour)); len(closed) != 2 {
+		t.Errorf("Expected every hour closed later on, got %v", closed)
+	}
+}
+
+func TestStaticFeatureFlags(t *testing.T) {
+	flags := NewStaticFeatureFlags()
+	ctx := context.Background()
+
+	flags.Enable("beta", "acme")
+	if !flags.Enabled(ctx, "beta", "acme") || flags.Enabled(ctx, "beta", "globex") {
+		t.Error("Expected beta on for acme only")
+	}
+	flags.Enable("beta")
+	flags.Disable("beta", "globex")
+	if !flags.Enabled(ctx, "beta", "initech") || flags.Enabled(ctx, "beta", "globex") {
+		t.Error("Expected beta on for every tenant but globex")
+	}
+	flags.Disable("beta")
+	if flags.Enabled(ctx, "beta", "acme") || flags.Enabled(ctx, "beta", "") {
+		t.Error("Expected beta off for every tenant")
+	}
+
+	active := ActiveFeatures{"b": true, "a": true, "off": false}
+	if names := active.Names(); !reflect.DeepEqual(names, []string{"a", "b"}) {
+		t.Errorf("Expected the active flags in name order, got %v", names)
+	}
+	if ActiveFeaturesFrom(WithActiveFeatures(ctx, active)).Enabled("off") || !ActiveFeaturesFrom(WithActiveFeatures(ctx, active)).Enabled("a") {
+		t.Error("Expected the active flags to be carried by the context")
+	}
+	event := NewEvent("Opened", "tally-1", 1, nil, map[string]interface{}{FeatureFlagsKey: "a,b"})
+	if flags := ActiveFeaturesOf(event); !reflect.DeepEqual(flags, []string{"a", "b"}) {
+		t.Errorf("Expected the recorded flags, got %v", flags)
+	}
+	if flags := ActiveFeaturesOf(NewEvent("Opened", "tally-2", 1, nil, nil)); flags != nil {
+		t.Errorf("Expected no recorded flags, got %v", flags)
+	}
+}
+
+func TestFollower(t *testing.T) {
+	store := NewEventStore()
+	for version := 1; version <= 5; version++ {
+		store.Append(NewEvent("Counted", "tally-1", version, nil, nil))
+	}
+	follower := NewFollower("counter", store)
+	var reported []Checkpoint
+	follower.OnProgress(func(checkpoint Checkpoint, handled int, err error) {
+		reported = append(reported, checkpoint)
+	})
+
+	var positions []int
+	failAt := 5
+	handle := func(batch []*Event, batchPositions []int) error {
+		if batchPositions[len(batchPositions)-1] >= failAt {
+			return errors.New("broker down")
+		}
+		positions = append(positions, batchPositions[0])
+		return nil
+	}
+	if handled, err := follower.Process(2, handle); err == nil || handled != 4 {
+		t.Fatalf("Expected two batches before the failure, got %d (%v)", handled, err)
+	}
+	if follower.Checkpoint().Position != 4 || !reflect.DeepEqual(positions, []int{1, 3}) {
+		t.Errorf("Expected the checkpoint after the accepted batches, got %+v, %v", follower.Checkpoint(), positions)
+	}
+
+	failAt = 6
+	if handled, err := follower.Process(2, handle); err != nil || handled != 1 || follower.Checkpoint().Position != 5 {
+		t.Errorf("Expected the failed event to be handed over again, got %d (%v)", handled, err)
+	}
+	if len(reported) != 2 || reported[1].Position != 5 {
+		t.Errorf("Expected every pass to be reported, got %v", reported)
+	}
+
+	follower.SetCheckpoint(0)
+	if handled, _ := follower.Process(0, func(batch []*Event, _ []int) error { return nil }); handled != 5 {
+		t.Errorf("Expected one batch of the whole log after rewinding, got %d", handled)
+	}
+
+	// Deleting a stream before the checkpoint leaves its place in the log
+	store.Append(NewEvent("Opened", "tally-2", 1, nil, nil))
+	follower.Process(0, func(batch []*Event, _ []int) error { return nil })
+	store.Append(NewEvent("Counted", "tally-1", 6, nil, nil))
+	if err := store.DeleteStream("tally-2", 1); err != nil {
+		t.Fatalf("Error deleting stream: %v", err)
+	}
+	positions = nil
+	failAt = 100
+	if handled, err := follower.Process(0, handle); err != nil || handled != 1 || !reflect.DeepEqual(positions, []int{7}) || follower.Checkpoint().Position != 7 {
+		t.Errorf("Expected only the event appended after the checkpoint, got %d (%v), %v", handled, err, positions)
+	}
+}
@@ -0,0 +1,338 @@
+// Package common provides read-your-writes consistency tokens and asynchronous
+// projections that honor them. A command result carries the token of the event it
+// produced; a query passed that token waits until its read model has applied the
+// event, so callers always see their own writes even when read models lag.
+package common
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ConsistencyToken names a write that a later read must observe: the version the
+// written event gave its stream. Its string form is "<stream>@<version>".
+type ConsistencyToken struct {
+	StreamID string `json:"stream_id"`
+	Version  int    `json:"version"`
+}
+
+// TokenFor returns the token of an appended event
+func TokenFor(event *Event) ConsistencyToken {
+	return ConsistencyToken{StreamID: event.AggregateID, Version: event.Version}
+}
+
+// IsZero reports whether the token names no write
+func (t ConsistencyToken) IsZero() bool {
+	return t.StreamID == "" && t.Version == 0
+}
+
+func (t ConsistencyToken) String() string {
+	return t.StreamID + "@" + strconv.Itoa(t.Version)
+}
+
+// ParseConsistencyToken parses the string form of a token
+func ParseConsistencyToken(s string) (ConsistencyToken, error) {
+	// Stream IDs may contain "@"; the version follows the last one
+	i := strings.LastIndex(s, "@")
+	if i <= 0 {
+		return ConsistencyToken{}, fmt.Errorf("invalid consistency token %q: want <stream>@<version>", s)
+	}
+	version, err := strconv.Atoi(s[i+1:])
+	if err != nil || version < 1 {
+		return ConsistencyToken{}, fmt.Errorf("invalid consistency token %q: version must be a positive integer", s)
+	}
+	return ConsistencyToken{StreamID: s[:i], Version: version}, nil
+}
+
+type consistencyTokenKey struct{}
+
+// WithConsistencyToken returns a context carrying the token a query must honor
+func WithConsistencyToken(ctx context.Context, token ConsistencyToken) context.Context {
+	return context.WithValue(ctx, consistencyTokenKey{}, token)
+}
+
+// ConsistencyTokenFrom returns the token carried by ctx, if any
+func ConsistencyTokenFrom(ctx context.Context) (ConsistencyToken, bool) {
+	token, ok := ctx.Value(consistencyTokenKey{}).(ConsistencyToken)
+	return token, ok
+}
+
+type expectedVersionKey struct{}
+
+// WithExpectedVersion returns a context requiring the stream a command targets to be
+// at version, e.g. from an HTTP If-Match header. Command handlers compare it with
+// the hydrated aggregate and fail with a *ConcurrencyError when they differ.
+func WithExpectedVersion(ctx context.Context, version int) context.Context {
+	return context.WithValue(ctx, expectedVersionKey{}, version)
+}
+
+// ExpectedVersionFrom returns the version required by ctx, if any
+func ExpectedVersionFrom(ctx context.Context) (int, bool) {
+	version, ok := ctx.Value(expectedVersionKey{}).(int)
+	return version, ok
+}
+
+// StaleReadError reports a read model that has not applied the write named by a
+// consistency token, even after replaying every stored event
+type StaleReadError struct {
+	Token ConsistencyToken
+	// Version is the stream version the read model has applied
+	Version int
+}
+
+func (e *StaleReadError) Error() string {
+	return fmt.Sprintf("read model has applied %s up to version %d, not %d", e.Token.StreamID, e.Version, e.Token.Version)
+}
+
+func (e *StaleReadError) Is(target error) bool { return target == ErrStaleRead }
+func (e *StaleReadError) Code() ErrorCode      { return CodeStaleRead }
+
+// DefaultMaxWait is how long ExecuteAtLeast waits for the background catch-up of an
+// AsyncProjection before replaying inline
+const DefaultMaxWait = 100 * time.Millisecond
+
+// AsyncProjection feeds a projection from the global event log in the background
+// (see Run), so writes don't pay for read model updates. Reads that must observe a
+// particular write go through ExecuteAtLeast.
+type AsyncProjection struct {
+	// MaxWait bounds how long ExecuteAtLeast waits before replaying inline; 0 uses
+	// DefaultMaxWait
+	MaxWait time.Duration
+	// Progress, when set, is called after every catch-up, e.g. to record metrics
+	Progress ProgressFunc
+	// Now is the time given to a Recomputer projection; nil uses time.Now
+	Now func() time.Time
+	// Snapshots, when set for a ProjectionSnapshotter, receives a snapshot of the
+	// projection every SnapshotEvery applied events (DefaultSnapshotEvery when 0),
+	// which Restore resumes from after a restart
+	Snapshots     SnapshotStore
+	SnapshotEvery int
+
+	store      Store
+	projection Projection
+
+	mu       sync.RWMutex
+	position int
+	// sinceSnapshot counts the events applied since the last snapshot
+	sinceSnapshot int
+	versions      map[string]int
+	// caughtUp is closed and replaced after every catch-up to wake waiting readers
+	caughtUp chan struct{}
+}
+
+// NewAsyncProjection creates an asynchronous projection over store, starting at the
+// beginning of the log
+func NewAsyncProjection(store Store, projection Projection) *AsyncProjection {
+	return &AsyncProjection{
+		store:      store,
+		projection: projection,
+		versions:   make(map[string]int),
+		caughtUp:   make(chan struct{}),
+	}
+}
+
+// Run catches the projection up, and recomputes it if it is a Recomputer, at every
+// interval until ctx is cancelled, an event fails to apply or a snapshot fails to
+// be saved
+func (p *AsyncProjection) Run(ctx context.Context, interval time.Duration) error {
+	return Poll(ctx, interval, func() error {
+		if err := p.CatchUp(); err != nil {
+			return err
+		}
+		return p.Recompute()
+	})
+}
+
+// CatchUp applies the events appended since the last catch-up, then snapshots the
+// projection if it is due
+func (p *AsyncProjection) CatchUp() error {
+	checkpoint, applied, err := p.catchUp()
+	if err == nil {
+		err = p.snapshotIfDue()
+	}
+	if p.Progress != nil {
+		p.Progress(checkpoint, applied, err)
+	}
+	return err
+}
+
+// Restore resumes the projection from its latest snapshot in Snapshots, and must be
+// called before the first catch-up. Restoring applies no events: the global log up
+// to the snapshot is only scanned for the stream versions ExecuteAtLeast checks
+// tokens against. It reports false, leaving the projection to start from the
+// beginning of the log, without Snapshots, for projections that are not a
+// ProjectionSnapshotter, without a snapshot, or with a snapshot past the end of the
+// log, e.g. of a store that was since emptied.
+func (p *AsyncProjection) Restore() (bool, error) {
+	snapshotter, ok := p.projection.(ProjectionSnapshotter)
+	if !ok || p.Snapshots == nil {
+		return false, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.position > 0 {
+		return false, fmt.Errorf("projection %s has already caught up to %d", p.projection.Name(), p.position)
+	}
+	snapshot, found, err := p.Snapshots.LoadSnapshot(ProjectionSnapshotID(p.projection.Name()))
+	if err != nil || !found {
+		return false, err
+	}
+	events := p.store.GetAllEvents()
+	if len(events) == 0 || snapshot.Version > LogPosition(events, len(events)-1) {
+		return false, nil
+	}
+	if err := snapshotter.RestoreSnapshot(snapshot); err != nil {
+		return false, fmt.Errorf("restoring projection %s: %w", p.projection.Name(), err)
+	}
+	for _, event := range events[:len(events)-len(EventsAfter(events, snapshot.Version))] {
+		p.versions[event.AggregateID] = event.Version
+	}
+	p.position = snapshot.Version
+	p.sinceSnapshot = 0
+	return true, nil
+}
+
+// Snapshot saves the state of the projection as of its checkpoint in Snapshots,
+// e.g. before shutting down. It does nothing without Snapshots or for projections
+// that are not a ProjectionSnapshotter.
+func (p *AsyncProjection) Snapshot() error {
+	snapshotter, ok := p.projection.(ProjectionSnapshotter)
+	if !ok || p.Snapshots == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.snapshot(snapshotter)
+}
+
+// snapshotIfDue saves a snapshot once SnapshotEvery events were applied since the
+// last one
+func (p *AsyncProjection) snapshotIfDue() error {
+	snapshotter, ok := p.projection.(ProjectionSnapshotter)
+	if !ok || p.Snapshots == nil {
+		return nil
+	}
+	every := p.SnapshotEvery
+	if every <= 0 {
+		every = DefaultSnapshotEvery
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.sinceSnapshot < every {
+		return nil
+	}
+	return p.snapshot(snapshotter)
+}
+
+// snapshot saves the projection's state. Callers must hold p.mu.
+func (p *AsyncProjection) snapshot(snapshotter ProjectionSnapshotter) error {
+	checkpoint := Checkpoint{Projection: p.projection.Name(), Position: p.position}
+	if _, err := SnapshotProjection(p.Snapshots, snapshotter, checkpoint); err != nil {
+		return fmt.Errorf("snapshotting projection %s: %w", p.projection.Name(), err)
+	}
+	p.sinceSnapshot = 0
+	return nil
+}
+
+func (p *AsyncProjection) catchUp() (Checkpoint, int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	all := p.store.GetAllEvents()
+	start := len(all) - len(EventsAfter(all, p.position))
+	applied := 0
+	var err error
+	for i := start; i < len(all); i++ {
+		event := all[i]
+		if err = p.projection.On(event); err != nil {
+			break
+		}
+		p.versions[event.AggregateID] = event.Version
+		p.position = LogPosition(all, i)
+		applied++
+	}
+	if applied > 0 {
+		p.sinceSnapshot += applied
+		close(p.caughtUp)
+		p.caughtUp = make(chan struct{})
+	}
+	return Checkpoint{Projection: p.projection.Name(), Position: p.position}, applied, err
+}
+
+// Recompute brings a Recomputer projection up to Now; other projections are left
+// alone
+func (p *AsyncProjection) Recompute() error {
+	recomputer, ok := p.projection.(Recomputer)
+	if !ok {
+		return nil
+	}
+	now := time.Now
+	if p.Now != nil {
+		now = p.Now
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return recomputer.Recompute(now())
+}
+
+// Checkpoint returns the position of the last applied event
+func (p *AsyncProjection) Checkpoint() Checkpoint {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return Checkpoint{Projection: p.projection.Name(), Position: p.position}
+}
+
+// ExecuteAtLeast calls read with the projection, under its read lock, once it has
+// applied the write named by token. It waits up to MaxWait for the background
+// catch-up, then replays the outstanding events inline. A zero token reads
+// immediately. If the store itself lacks the write, a *StaleReadError is returned.
+func (p *AsyncProjection) ExecuteAtLeast(ctx context.Context, token ConsistencyToken, read func(Projection)) error {
+	maxWait := p.MaxWait
+	if maxWait <= 0 {
+		maxWait = DefaultMaxWait
+	}
+	deadline := time.NewTimer(maxWait)
+	defer deadline.Stop()
+
+	for {
+		p.mu.RLock()
+		if p.reached(token) {
+			defer p.mu.RUnlock()
+			read(p.projection)
+			return nil
+		}
+		wake := p.caughtUp
+		p.mu.RUnlock()
+
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline.C:
+			if err := p.CatchUp(); err != nil {
+				return err
+			}
+			p.mu.RLock()
+			defer p.mu.RUnlock()
+			if !p.reached(token) {
+				return &StaleReadError{Token: token, Version: p.versions[token.StreamID]}
+			}
+			read(p.projection)
+			return nil
+		}
+	}
+}
+
+// reached reports whether the write named by token has been applied. Callers must
+// hold p.mu.
+func (p *AsyncProjection) reached(token ConsistencyToken) bool {
+	return token.IsZero() || p.versions[token.StreamID] >= token.Version
+}
@@ -0,0 +1,143 @@
+// Package common provides child entities of aggregates. An aggregate whose state
+// outgrows flat maps, such as a cart whose lines carry options, notes, and prices,
+// keeps entities with their own On<EventType> handlers (see EventHandlers), and an
+// EntityType routes each event to the entity it concerns:
+//
+//	var cartLines = common.MustEntityType(common.DefaultRegistry, NewCartLine, routeToLine)
+//
+//	ca.lines = cartLines.NewEntities()
+//	...
+//	if err := ca.lines.On(event); err != nil { ... }
+package common
+
+import (
+	"fmt"
+	"sort"
+)
+
+// EntityRouter returns the ID of the entity an event concerns, or "" when it
+// concerns none
+type EntityRouter func(event *Event) (string, error)
+
+// Retirer is implemented by entities that can leave the aggregate, such as a cart
+// line whose last unit was removed. Entities retire after the event that makes
+// Retired report true.
+type Retirer interface {
+	Retired() bool
+}
+
+// EntityType describes a kind of child entity: how to create one, which events reach
+// it, and its handlers. It is shared by every aggregate instance, so its handlers
+// are collected once.
+type EntityType[E any] struct {
+	newEntity func(id string) E
+	route     EntityRouter
+	handlers  *EventHandlers[E]
+}
+
+// NewEntityType creates an entity type whose entities are created by newEntity and
+// handle the events route sends them with their On<EventType> methods. It returns an
+// error for an unsupported handler, like NewEventHandlers.
+func NewEntityType[E any](registry *Registry, newEntity func(id string) E, route EntityRouter) (*EntityType[E], error) {
+	handlers, err := NewEventHandlers[E](registry)
+	if err != nil {
+		return nil, err
+	}
+	return &EntityType[E]{newEntity: newEntity, route: route, handlers: handlers}, nil
+}
+
+// MustEntityType is like NewEntityType but panics on an unsupported handler, for
+// initializing package-level variables
+func MustEntityType[E any](registry *Registry, newEntity func(id string) E, route EntityRouter) *EntityType[E] {
+	t, err := NewEntityType(registry, newEntity, route)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// EventTypes returns the event types the entities handle, sorted
+func (t *EntityType[E]) EventTypes() []string {
+	return t.handlers.EventTypes()
+}
+
+// NewEntities creates an empty set of entities of this type, for one aggregate
+func (t *EntityType[E]) NewEntities() *Entities[E] {
+	return &Entities[E]{entityType: t, entities: make(map[string]E)}
+}
+
+// Entities holds the child entities of one aggregate, keyed by ID
+type Entities[E any] struct {
+	entityType *EntityType[E]
+	entities   map[string]E
+}
+
+// On applies an event to the entity it is routed to, creating the entity when it is
+// new and dropping it when it retires. Events the entities don't handle, or that
+// concern no entity, are ignored.
+func (s *Entities[E]) On(event *Event) error {
+	if _, handled := s.entityType.handlers.handlers[event.Type]; !handled {
+		return nil
+	}
+	id, err := s.entityType.route(event)
+	if err != nil {
+		return fmt.Errorf("routing %s: %w", event.Type, err)
+	}
+	if id == "" {
+		return nil
+	}
+
+	entity, exists := s.entities[id]
+	if !exists {
+		entity = s.entityType.newEntity(id)
+	}
+	if err := s.entityType.handlers.Dispatch(entity, event); err != nil {
+		return err
+	}
+	if retirer, ok := any(entity).(Retirer); ok && retirer.Retired() {
+		delete(s.entities, id)
+		return nil
+	}
+	s.entities[id] = entity
+	return nil
+}
+
+// Get returns the entity with the given ID
+func (s *Entities[E]) Get(id string) (E, bool) {
+	entity, exists := s.entities[id]
+	return entity, exists
+}
+
+// Put adds or replaces an entity, e.g. when restoring a snapshot
+func (s *Entities[E]) Put(id string, entity E) {
+	s.entities[id] = entity
+}
+
+// IDs returns the IDs of the entities, sorted
+func (s *Entities[E]) IDs() []string {
+	ids := make([]string, 0, len(s.entities))
+	for id := range s.entities {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// All returns the entities ordered by ID
+func (s *Entities[E]) All() []E {
+	all := make([]E, 0, len(s.entities))
+	for _, id := range s.IDs() {
+		all = append(all, s.entities[id])
+	}
+	return all
+}
+
+// Len returns the number of entities
+func (s *Entities[E]) Len() int {
+	return len(s.entities)
+}
+
+// Clear removes every entity
+func (s *Entities[E]) Clear() {
+	s.entities = make(map[string]E)
+}
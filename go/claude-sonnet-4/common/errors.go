@@ -4,15 +4,104 @@ package common
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 )
 
-// Errors for the event modeling system
+// Errors for the event modeling system. Each error type below matches one of these
+// with errors.Is, so callers can test for a kind of failure without errors.As:
+//
+//	if errors.Is(err, common.ErrConcurrency) { retry() }
 var (
-	ErrInvalidCommand   = errors.New("invalid command")
-	ErrStreamNotFound   = errors.New("stream not found")
-	ErrAggregateNotLive = errors.New("aggregate is not live")
+	ErrInvalidCommand    = errors.New("invalid command")
+	ErrStreamNotFound    = errors.New("stream not found")
+	ErrAggregateNotLive  = errors.New("aggregate is not live")
+	ErrAggregateClosed   = errors.New("aggregate is closed")
+	ErrAggregateReadOnly = errors.New("aggregate is read-only")
+	ErrConcurrency       = errors.New("concurrency conflict")
+	ErrValidation        = errors.New("validation failed")
+	ErrUnknownCommand    = errors.New("unknown command")
+	ErrUnknownQuery      = errors.New("unknown query")
+	ErrUnauthorized      = errors.New("unauthorized")
+	ErrUnauthenticated   = errors.New("unauthenticated")
+	ErrAccessDenied      = errors.New("stream access denied")
+	ErrMissingUpcaster   = errors.New("missing upcaster")
+	ErrPayloadType       = errors.New("payload type mismatch")
+	ErrStaleRead         = errors.New("read model behind consistency token")
+	ErrRateLimited       = errors.New("rate limited")
+	ErrEventNotAllowed   = errors.New("event type not allowed in stream")
 )
 
+// ErrorCode is a stable, machine-readable identifier of a kind of error, for
+// transports such as HTTP and gRPC to map to their status codes
+type ErrorCode string
+
+// Error codes of the common error types
+const (
+	CodeInternal          ErrorCode = "internal"
+	CodeInvalidCommand    ErrorCode = "invalid_command"
+	CodeStreamNotFound    ErrorCode = "stream_not_found"
+	CodeAggregateNotLive  ErrorCode = "aggregate_not_live"
+	CodeAggregateClosed   ErrorCode = "aggregate_closed"
+	CodeAggregateReadOnly ErrorCode = "aggregate_read_only"
+	CodeConcurrency       ErrorCode = "concurrency_conflict"
+	CodeMergeConflict     ErrorCode = "merge_conflict"
+	CodeValidation        ErrorCode = "validation_failed"
+	CodeUnknownCommand    ErrorCode = "unknown_command"
+	CodeUnknownQuery      ErrorCode = "unknown_query"
+	CodeUnauthorized      ErrorCode = "unauthorized"
+	CodeUnauthenticated   ErrorCode = "unauthenticated"
+	CodeAccessDenied      ErrorCode = "access_denied"
+	CodeMissingUpcaster   ErrorCode = "missing_upcaster"
+	CodePayloadType       ErrorCode = "payload_type_mismatch"
+	CodeStaleRead         ErrorCode = "stale_read"
+	CodeRateLimited       ErrorCode = "rate_limited"
+	CodeEventNotAllowed   ErrorCode = "event_not_allowed"
+)
+
+var sentinelCodes = []struct {
+	err  error
+	code ErrorCode
+}{
+	{ErrInvalidCommand, CodeInvalidCommand},
+	{ErrStreamNotFound, CodeStreamNotFound},
+	{ErrAggregateNotLive, CodeAggregateNotLive},
+	{ErrAggregateClosed, CodeAggregateClosed},
+	{ErrAggregateReadOnly, CodeAggregateReadOnly},
+	{ErrConcurrency, CodeConcurrency},
+	{ErrValidation, CodeValidation},
+	{ErrUnknownCommand, CodeUnknownCommand},
+	{ErrUnknownQuery, CodeUnknownQuery},
+	{ErrUnauthorized, CodeUnauthorized},
+	{ErrUnauthenticated, CodeUnauthenticated},
+	{ErrAccessDenied, CodeAccessDenied},
+	{ErrMissingUpcaster, CodeMissingUpcaster},
+	{ErrPayloadType, CodePayloadType},
+	{ErrStaleRead, CodeStaleRead},
+	{ErrRateLimited, CodeRateLimited},
+	{ErrEventNotAllowed, CodeEventNotAllowed},
+}
+
+// Coder is implemented by errors that carry an ErrorCode
+type Coder interface {
+	Code() ErrorCode
+}
+
+// ErrorCodeOf returns the code of the first error in err's chain that has one,
+// including the sentinels above, or CodeInternal
+func ErrorCodeOf(err error) ErrorCode {
+	var coder Coder
+	if errors.As(err, &coder) {
+		return coder.Code()
+	}
+	for _, sentinel := range sentinelCodes {
+		if errors.Is(err, sentinel.err) {
+			return sentinel.code
+		}
+	}
+	return CodeInternal
+}
+
 // StreamNotFoundError represents an error when a stream is not found
 type StreamNotFoundError struct {
 	StreamID string
@@ -22,6 +111,83 @@ func (e *StreamNotFoundError) Error() string {
 	return fmt.Sprintf("stream %s not found", e.StreamID)
 }
 
+func (e *StreamNotFoundError) Is(target error) bool { return target == ErrStreamNotFound }
+func (e *StreamNotFoundError) Code() ErrorCode      { return CodeStreamNotFound }
+
+// ConcurrencyError represents an append that lost a race: the stream moved past the
+// version the event was based on. Retrying the command against fresh state usually succeeds.
+type ConcurrencyError struct {
+	StreamID string
+	// Expected is the stream version the appended event was based on
+	Expected int
+	// Actual is the stream version at the time of the append
+	Actual int
+}
+
+func (e *ConcurrencyError) Error() string {
+	return fmt.Sprintf("stream %s is at version %d, expected %d", e.StreamID, e.Actual, e.Expected)
+}
+
+func (e *ConcurrencyError) Is(target error) bool { return target == ErrConcurrency }
+func (e *ConcurrencyError) Code() ErrorCode      { return CodeConcurrency }
+
+// MergeConflictError represents a command that lost an expected-version race and
+// could not be merged with the events written since: either they don't commute with
+// it, or the command broke a business rule once replayed on top of them (Rejection).
+// The caller resolves it, e.g. by showing Theirs to the user and asking again.
+type MergeConflictError struct {
+	StreamID string
+	// Expected is the stream version the command was based on
+	Expected int
+	// Actual is the stream version the merge was attempted at
+	Actual int
+	// Theirs are the events appended since Expected
+	Theirs []*Event
+	// Rejection is the error of the command replayed at Actual; nil when Theirs
+	// don't commute with the command
+	Rejection error
+}
+
+func (e *MergeConflictError) Error() string {
+	if e.Rejection != nil {
+		return fmt.Sprintf("stream %s is at version %d, expected %d, and merging failed: %v", e.StreamID, e.Actual, e.Expected, e.Rejection)
+	}
+	return fmt.Sprintf("stream %s is at version %d, expected %d, and the %d events since conflict with the command", e.StreamID, e.Actual, e.Expected, len(e.Theirs))
+}
+
+func (e *MergeConflictError) Is(target error) bool { return target == ErrConcurrency }
+func (e *MergeConflictError) Code() ErrorCode      { return CodeMergeConflict }
+
+// AggregateClosedError represents a command sent to an aggregate that reached a
+// terminal state, such as a checked-out cart; its stream accepts no more events
+type AggregateClosedError struct {
+	StreamID string
+	// ClosedBy is the type of the event that closed the aggregate
+	ClosedBy string
+	Version  int
+}
+
+func (e *AggregateClosedError) Error() string {
+	return fmt.Sprintf("%s was closed by %s at version %d", e.StreamID, e.ClosedBy, e.Version)
+}
+
+func (e *AggregateClosedError) Is(target error) bool { return target == ErrAggregateClosed }
+func (e *AggregateClosedError) Code() ErrorCode      { return CodeAggregateClosed }
+
+// AggregateReadOnlyError represents a command sent to a view of an aggregate as of
+// an earlier version (see BaseAggregate.HydrateUpTo)
+type AggregateReadOnlyError struct {
+	StreamID string
+	Version  int
+}
+
+func (e *AggregateReadOnlyError) Error() string {
+	return fmt.Sprintf("%s is a read-only view as of version %d", e.StreamID, e.Version)
+}
+
+func (e *AggregateReadOnlyError) Is(target error) bool { return target == ErrAggregateReadOnly }
+func (e *AggregateReadOnlyError) Code() ErrorCode      { return CodeAggregateReadOnly }
+
 // InvalidCommandError represents an error with invalid command data
 type InvalidCommandError struct {
 	Message string
@@ -30,3 +196,129 @@ type InvalidCommandError struct {
 func (e *InvalidCommandError) Error() string {
 	return e.Message
 }
+
+func (e *InvalidCommandError) Is(target error) bool { return target == ErrInvalidCommand }
+func (e *InvalidCommandError) Code() ErrorCode      { return CodeInvalidCommand }
+
+// UnknownCommandError represents a command that the receiving aggregate does not handle
+type UnknownCommandError struct {
+	CommandType string
+	// Registered lists the command types the aggregate does handle
+	Registered []string
+}
+
+func (e *UnknownCommandError) Error() string {
+	return fmt.Sprintf("unknown command type %q (registered: %s)", e.CommandType, strings.Join(e.Registered, ", "))
+}
+
+func (e *UnknownCommandError) Is(target error) bool { return target == ErrUnknownCommand }
+func (e *UnknownCommandError) Code() ErrorCode      { return CodeUnknownCommand }
+
+// UnknownQueryError represents a query that has no registered handler
+type UnknownQueryError struct {
+	QueryType string
+	// Registered lists the query types that do have handlers
+	Registered []string
+}
+
+func (e *UnknownQueryError) Error() string {
+	return fmt.Sprintf("unknown query type %q (registered: %s)", e.QueryType, strings.Join(e.Registered, ", "))
+}
+
+func (e *UnknownQueryError) Is(target error) bool { return target == ErrUnknownQuery }
+func (e *UnknownQueryError) Code() ErrorCode      { return CodeUnknownQuery }
+
+// UnauthorizedError represents a command the caller is not allowed to issue
+type UnauthorizedError struct {
+	Principal   string
+	CommandType string
+	AggregateID string
+	Reason      string
+}
+
+func (e *UnauthorizedError) Error() string {
+	msg := fmt.Sprintf("%s may not issue %s", e.Principal, e.CommandType)
+	if e.AggregateID != "" {
+		msg += " on " + e.AggregateID
+	}
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	return msg
+}
+
+func (e *UnauthorizedError) Is(target error) bool { return target == ErrUnauthorized }
+func (e *UnauthorizedError) Code() ErrorCode      { return CodeUnauthorized }
+
+// UnauthenticatedError represents a request whose credentials could not be verified
+type UnauthenticatedError struct {
+	// Scheme is the authentication scheme of the rejected credentials, e.g. "Bearer"
+	Scheme string
+	Reason string
+}
+
+func (e *UnauthenticatedError) Error() string {
+	if e.Scheme == "" {
+		return "unauthenticated: " + e.Reason
+	}
+	return fmt.Sprintf("invalid %s credentials: %s", e.Scheme, e.Reason)
+}
+
+func (e *UnauthenticatedError) Is(target error) bool { return target == ErrUnauthenticated }
+func (e *UnauthenticatedError) Code() ErrorCode      { return CodeUnauthenticated }
+
+// RateLimitedError represents a command refused because its aggregate or its caller
+// has issued too many commands recently
+type RateLimitedError struct {
+	// Scope is the limit that was hit: "aggregate" or "principal"
+	Scope       string
+	Key         string
+	CommandType string
+	// RetryAfter is how long until the command would be accepted
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("%s rate limit exceeded for %s by %s; retry after %s", e.Scope, e.Key, e.CommandType, e.RetryAfter)
+}
+
+func (e *RateLimitedError) Is(target error) bool { return target == ErrRateLimited }
+func (e *RateLimitedError) Code() ErrorCode      { return CodeRateLimited }
+
+// EventNotAllowedError represents an event appended to a stream of an aggregate it
+// doesn't belong to, such as an order event landing in a cart stream
+type EventNotAllowedError struct {
+	StreamID  string
+	EventType string
+	// Aggregate is the aggregate the stream belongs to
+	Aggregate string
+	// Allowed lists the event types registered for Aggregate
+	Allowed []string
+}
+
+func (e *EventNotAllowedError) Error() string {
+	return fmt.Sprintf("event type %q is not allowed in %s stream %s (allowed: %s)", e.EventType, e.Aggregate, e.StreamID, strings.Join(e.Allowed, ", "))
+}
+
+func (e *EventNotAllowedError) Is(target error) bool { return target == ErrEventNotAllowed }
+func (e *EventNotAllowedError) Code() ErrorCode      { return CodeEventNotAllowed }
+
+// StreamAccessError represents a stream operation the caller is not allowed to perform
+type StreamAccessError struct {
+	Principal string
+	// Access is the operation that was denied: "read", "append", or "subscribe"
+	Access   string
+	StreamID string
+	Reason   string
+}
+
+func (e *StreamAccessError) Error() string {
+	msg := fmt.Sprintf("%s may not %s stream %s", e.Principal, e.Access, e.StreamID)
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	return msg
+}
+
+func (e *StreamAccessError) Is(target error) bool { return target == ErrAccessDenied }
+func (e *StreamAccessError) Code() ErrorCode      { return CodeAccessDenied }
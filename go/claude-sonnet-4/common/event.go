@@ -4,20 +4,48 @@ package common
 
 import (
 	"time"
+)
+
+// Metadata keys identifying the people an event concerns. Handlers that know the
+// acting user or the customer set them so tooling such as subject access exports
+// can find every event about a person.
+const (
+	ActorIDKey    = "actor_id"
+	CustomerIDKey = "customer_id"
+)
 
-	"github.com/google/uuid"
+// Metadata keys recording where the command an event resulted from came from (see
+// bus.CommandEnvelope). IssuedAtKey holds an RFC 3339 timestamp.
+const (
+	OriginKey          = "origin"
+	ClientRequestIDKey = "client_request_id"
+	IssuedAtKey        = "issued_at"
 )
 
 // Event represents a domain event in the system
 // Events are simple records with no behaviors, containing state change information
 type Event struct {
-	ID          string                 `json:"id"`
-	Type        string                 `json:"type"`
-	CreatedAt   time.Time              `json:"created_at"`
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	// EffectiveAt is when the fact became true in the domain (valid time), for events
+	// recorded after the fact such as backdated corrections. It is nil when the fact
+	// took effect when it was recorded; use EffectiveTime to read it.
+	EffectiveAt *time.Time             `json:"effective_at,omitempty"`
 	AggregateID string                 `json:"aggregate_id"`
 	Version     int                    `json:"version"`
 	Data        map[string]interface{} `json:"data"`
 	Metadata    map[string]interface{} `json:"metadata"`
+	// Payload holds a serialized body such as protobuf or Avro, described by
+	// ContentType, for events whose payload doesn't fit Data without losing type
+	// fidelity. Data is empty for such events.
+	Payload     []byte `json:"payload,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	// Position is the event's 1-based sequence number in the global log of the store
+	// holding it, stamped on append by stores that can remove events, such as
+	// EventStore, so checkpoints stay valid when earlier events are deleted. It is
+	// never reused, and 0 in stores whose log only grows; see LogPosition.
+	Position int `json:"-"`
 }
 
 // NewEvent creates a new event with the given parameters
@@ -30,12 +58,21 @@ func NewEvent(eventType, aggregateID string, version int, data, metadata map[str
 	}
 
 	return &Event{
-		ID:          uuid.New().String(),
+		ID:          NewEventID(),
 		Type:        eventType,
-		CreatedAt:   time.Now(),
+		CreatedAt:   DefaultClock.Now(),
 		AggregateID: aggregateID,
 		Version:     version,
 		Data:        data,
 		Metadata:    metadata,
 	}
 }
+
+// NewBinaryEvent creates an event carrying a serialized payload of the given content
+// type, e.g. "application/protobuf", instead of a Data map
+func NewBinaryEvent(eventType, aggregateID string, version int, contentType string, payload []byte, metadata map[string]interface{}) *Event {
+	event := NewEvent(eventType, aggregateID, version, nil, metadata)
+	event.Payload = payload
+	event.ContentType = contentType
+	return event
+}
@@ -2,11 +2,23 @@
 // EventStore provides in-memory event storage for event-sourced aggregates.
 package common
 
+import (
+	"sort"
+	"sync"
+)
+
 // EventStore provides in-memory event storage for event-sourced aggregates.
 // It stores events that implement the event protocol (have AggregateID and Version).
+// Appending stamps each event with its Position in the global log, which deleting
+// or truncating streams leaves alone. It is safe for concurrent use.
 type EventStore struct {
+	// txMu serializes appends with transactions, which hold it until they commit
+	txMu    sync.Mutex
+	mu      sync.RWMutex
 	events  []*Event
 	streams map[string][]*Event
+	// position is the Position of the latest event appended
+	position int
 }
 
 // NewEventStore creates a new in-memory event store
@@ -17,20 +29,166 @@ func NewEventStore() *EventStore {
 	}
 }
 
-// Append adds an event to the store
+// Append adds an event to the store. The event's version must directly follow the
+// stream's current version, otherwise a *ConcurrencyError is returned.
 func (es *EventStore) Append(event *Event) error {
+	es.txMu.Lock()
+	defer es.txMu.Unlock()
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
 	aggregateID := event.AggregateID
+	if err := CheckVersion(event, es.streamVersion(aggregateID)); err != nil {
+		return err
+	}
 	if es.streams[aggregateID] == nil {
 		es.streams[aggregateID] = make([]*Event, 0)
 	}
 
+	es.store(event)
+	return nil
+}
+
+// store adds an event to the global log and its stream. Callers must hold es.mu.
+func (es *EventStore) store(event *Event) {
+	es.position++
+	event.Position = es.position
 	es.events = append(es.events, event)
-	es.streams[aggregateID] = append(es.streams[aggregateID], event)
+	es.streams[event.AggregateID] = append(es.streams[event.AggregateID], event)
+}
+
+// AppendBatch appends events atomically. See BatchAppender.
+func (es *EventStore) AppendBatch(events []*Event) error {
+	es.txMu.Lock()
+	defer es.txMu.Unlock()
+	return es.appendBatch(events)
+}
+
+// WithinTransaction buffers the appends of fn in a UnitOfWork and stores them
+// atomically once it returns nil. It emulates isolation with a global lock: appends
+// from other goroutines wait until the transaction ends, while reads proceed and see
+// none of its events until then. fn must append through tx, since appending to es
+// directly waits for the transaction itself. See Transactor.
+func (es *EventStore) WithinTransaction(fn func(tx Store) error) error {
+	es.txMu.Lock()
+	defer es.txMu.Unlock()
+
+	uow := NewUnitOfWork(es, nil)
+	if err := fn(uow); err != nil {
+		return err
+	}
+	return es.appendBatch(uow.Pending())
+}
+
+func (es *EventStore) appendBatch(events []*Event) error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	versions := make(map[string]int)
+	for _, event := range events {
+		current, seen := versions[event.AggregateID]
+		if !seen {
+			current = es.streamVersion(event.AggregateID)
+		}
+		if err := CheckVersion(event, current); err != nil {
+			return err
+		}
+		versions[event.AggregateID] = event.Version
+	}
+
+	for _, event := range events {
+		es.store(event)
+	}
+	return nil
+}
+
+// Fork returns an independent store holding the history of this one up to now.
+// Events appended to either store afterwards are not visible in the other, so the
+// fork can be used to simulate commands without touching the original. The forked
+// history shares the Event values, which must not be modified.
+func (es *EventStore) Fork() *EventStore {
+	es.mu.RLock()
+	defer es.mu.RUnlock()
+
+	fork := &EventStore{
+		events:   append(make([]*Event, 0, len(es.events)), es.events...),
+		streams:  make(map[string][]*Event, len(es.streams)),
+		position: es.position,
+	}
+	for id, stream := range es.streams {
+		fork.streams[id] = append(make([]*Event, 0, len(stream)), stream...)
+	}
+	return fork
+}
+
+// TruncateStream drops the events of a stream below beforeVersion, once they have
+// been copied elsewhere (see package archive). The stream's latest event is always
+// kept, so its version and optimistic concurrency checks are unaffected, and the
+// events left keep their positions.
+func (es *EventStore) TruncateStream(aggregateID string, beforeVersion int) error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	stream, exists := es.streams[aggregateID]
+	if !exists {
+		return &StreamNotFoundError{StreamID: aggregateID}
+	}
+	if latest := stream[len(stream)-1].Version; beforeVersion > latest {
+		beforeVersion = latest
+	}
+
+	dropped := func(event *Event) bool {
+		return event.AggregateID == aggregateID && event.Version < beforeVersion
+	}
+	// Build new slices so callers still holding the old ones are unaffected
+	kept := make([]*Event, 0, len(stream))
+	for _, event := range stream {
+		if !dropped(event) {
+			kept = append(kept, event)
+		}
+	}
+	es.streams[aggregateID] = kept
+
+	events := make([]*Event, 0, len(es.events))
+	for _, event := range es.events {
+		if !dropped(event) {
+			events = append(events, event)
+		}
+	}
+	es.events = events
+	return nil
+}
+
+// DeleteStream removes every event of a stream at expectedVersion. See StreamDeleter.
+func (es *EventStore) DeleteStream(streamID string, expectedVersion int) error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	stream, exists := es.streams[streamID]
+	if !exists {
+		return &StreamNotFoundError{StreamID: streamID}
+	}
+	if actual := stream[len(stream)-1].Version; actual != expectedVersion {
+		return &ConcurrencyError{StreamID: streamID, Expected: expectedVersion, Actual: actual}
+	}
+	delete(es.streams, streamID)
+
+	// Build a new slice so callers still holding the old one are unaffected
+	events := make([]*Event, 0, len(es.events))
+	for _, event := range es.events {
+		if event.AggregateID != streamID {
+			events = append(events, event)
+		}
+	}
+	es.events = events
 	return nil
 }
 
 // GetStream retrieves all events for a given aggregate ID
 func (es *EventStore) GetStream(aggregateID string) ([]*Event, error) {
+	es.mu.RLock()
+	defer es.mu.RUnlock()
+
 	stream, exists := es.streams[aggregateID]
 	if !exists {
 		return nil, &StreamNotFoundError{StreamID: aggregateID}
@@ -40,10 +198,32 @@ func (es *EventStore) GetStream(aggregateID string) ([]*Event, error) {
 
 // GetStreamVersion returns the current version of a stream
 func (es *EventStore) GetStreamVersion(aggregateID string) int {
-	stream, err := es.GetStream(aggregateID)
-	if err != nil {
-		return 0
+	es.mu.RLock()
+	defer es.mu.RUnlock()
+	return es.streamVersion(aggregateID)
+}
+
+// StreamLength returns the number of events in a stream
+func (es *EventStore) StreamLength(aggregateID string) int {
+	es.mu.RLock()
+	defer es.mu.RUnlock()
+	return len(es.streams[aggregateID])
+}
+
+// HeadEvent returns the latest event of a stream
+func (es *EventStore) HeadEvent(aggregateID string) (*Event, error) {
+	es.mu.RLock()
+	defer es.mu.RUnlock()
+
+	stream := es.streams[aggregateID]
+	if len(stream) == 0 {
+		return nil, &StreamNotFoundError{StreamID: aggregateID}
 	}
+	return stream[len(stream)-1], nil
+}
+
+func (es *EventStore) streamVersion(aggregateID string) int {
+	stream := es.streams[aggregateID]
 	if len(stream) == 0 {
 		return 0
 	}
@@ -52,5 +232,20 @@ func (es *EventStore) GetStreamVersion(aggregateID string) int {
 
 // GetAllEvents returns all events in the store
 func (es *EventStore) GetAllEvents() []*Event {
+	es.mu.RLock()
+	defer es.mu.RUnlock()
 	return es.events
 }
+
+// StreamIDs returns the identifiers of all streams in the store, sorted
+func (es *EventStore) StreamIDs() []string {
+	es.mu.RLock()
+	defer es.mu.RUnlock()
+
+	ids := make([]string, 0, len(es.streams))
+	for id := range es.streams {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
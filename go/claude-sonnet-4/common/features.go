@@ -0,0 +1,141 @@
+// Package common provides feature flags gating business rules. A FeatureFlags
+// provider decides which flags are on for a tenant; bus middleware resolves them
+// once per command into the ActiveFeatures of its context, which aggregates and
+// policies consult, and records them in the metadata of the events the command
+// produces so it is known which rules an event was decided under.
+package common
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FeatureFlagsKey is the metadata key listing the feature flags active when an
+// event was produced, comma separated in name order
+const FeatureFlagsKey = "feature_flags"
+
+// FeatureFlags decides whether a feature flag is on for a tenant. The tenant is ""
+// for commands not issued on behalf of one.
+type FeatureFlags interface {
+	Enabled(ctx context.Context, flag, tenant string) bool
+}
+
+// StaticFeatureFlags is an in-memory FeatureFlags provider. A flag is on for a
+// tenant when it is enabled for that tenant, or enabled for every tenant and not
+// disabled for that one. It is safe for concurrent use.
+type StaticFeatureFlags struct {
+	mu       sync.RWMutex
+	all      map[string]bool
+	tenants  map[string]map[string]bool // flag -> tenant -> on
+	disabled map[string]map[string]bool // flag -> tenant -> off
+}
+
+// NewStaticFeatureFlags creates a provider with every flag off
+func NewStaticFeatureFlags() *StaticFeatureFlags {
+	return &StaticFeatureFlags{
+		all:      make(map[string]bool),
+		tenants:  make(map[string]map[string]bool),
+		disabled: make(map[string]map[string]bool),
+	}
+}
+
+// Enable turns a flag on for the given tenants, or for every tenant when none are
+// given
+func (f *StaticFeatureFlags) Enable(flag string, tenants ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(tenants) == 0 {
+		f.all[flag] = true
+		delete(f.disabled, flag)
+		return
+	}
+	for _, tenant := range tenants {
+		setTenant(f.tenants, flag, tenant, true)
+		setTenant(f.disabled, flag, tenant, false)
+	}
+}
+
+// Disable turns a flag off for the given tenants, or for every tenant when none
+// are given
+func (f *StaticFeatureFlags) Disable(flag string, tenants ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(tenants) == 0 {
+		delete(f.all, flag)
+		delete(f.tenants, flag)
+		delete(f.disabled, flag)
+		return
+	}
+	for _, tenant := range tenants {
+		setTenant(f.tenants, flag, tenant, false)
+		setTenant(f.disabled, flag, tenant, true)
+	}
+}
+
+// Enabled reports whether a flag is on for a tenant
+func (f *StaticFeatureFlags) Enabled(_ context.Context, flag, tenant string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.tenants[flag][tenant] {
+		return true
+	}
+	return f.all[flag] && !f.disabled[flag][tenant]
+}
+
+func setTenant(flags map[string]map[string]bool, flag, tenant string, on bool) {
+	if !on {
+		delete(flags[flag], tenant)
+		return
+	}
+	if flags[flag] == nil {
+		flags[flag] = make(map[string]bool)
+	}
+	flags[flag][tenant] = true
+}
+
+// ActiveFeatures is the set of feature flags on while a command is handled
+type ActiveFeatures map[string]bool
+
+// Enabled reports whether a flag is active
+func (f ActiveFeatures) Enabled(flag string) bool {
+	return f[flag]
+}
+
+// Names returns the active flags in name order
+func (f ActiveFeatures) Names() []string {
+	names := make([]string, 0, len(f))
+	for flag, on := range f {
+		if on {
+			names = append(names, flag)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+type activeFeaturesKey struct{}
+
+// WithActiveFeatures returns a context carrying the feature flags active for a
+// command
+func WithActiveFeatures(ctx context.Context, features ActiveFeatures) context.Context {
+	return context.WithValue(ctx, activeFeaturesKey{}, features)
+}
+
+// ActiveFeaturesFrom returns the feature flags active in ctx; none are without
+// feature flag middleware
+func ActiveFeaturesFrom(ctx context.Context) ActiveFeatures {
+	features, _ := ctx.Value(activeFeaturesKey{}).(ActiveFeatures)
+	return features
+}
+
+// ActiveFeaturesOf returns the feature flags recorded as active when event was
+// produced, in name order
+func ActiveFeaturesOf(event *Event) []string {
+	flags, _ := event.Metadata[FeatureFlagsKey].(string)
+	if flags == "" {
+		return nil
+	}
+	return strings.Split(flags, ",")
+}
@@ -0,0 +1,94 @@
+// Package common provides filtered, lazy scans of the global event log, for
+// analytics and tooling reading stores too large to load with GetAllEvents:
+//
+//	added := common.Filter{Types: []string{"ItemAdded"}, After: since, AggregatePrefix: "cart-"}
+//	for event, err := range common.QueryEvents(store, added) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+package common
+
+import (
+	"iter"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Filter selects events of the global log. Every set field must match; the zero
+// value matches every event.
+type Filter struct {
+	// Types limits the events to these types; nil matches every type
+	Types []string
+	// AggregatePrefix limits the events to streams whose ID starts with it, e.g. "cart-"
+	AggregatePrefix string
+	// After and Before bound CreatedAt, both exclusive; the zero time leaves a side open
+	After  time.Time
+	Before time.Time
+}
+
+// Matches reports whether an event passes the filter
+func (f Filter) Matches(event *Event) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, event.Type) {
+		return false
+	}
+	if !strings.HasPrefix(event.AggregateID, f.AggregatePrefix) {
+		return false
+	}
+	if !f.After.IsZero() && !event.CreatedAt.After(f.After) {
+		return false
+	}
+	if !f.Before.IsZero() && !event.CreatedAt.Before(f.Before) {
+		return false
+	}
+	return true
+}
+
+// EventQuerier is implemented by stores that scan their log lazily, reading events
+// as the caller consumes them instead of loading the whole log
+type EventQuerier interface {
+	// Query returns the events passing filter in append order. A read error is
+	// yielded once, with a nil event, and ends the scan.
+	Query(filter Filter) iter.Seq2[*Event, error]
+}
+
+var _ EventQuerier = (*EventStore)(nil)
+
+// QueryEvents returns the events of store passing filter in append order. It uses the
+// store's native scan when it is an EventQuerier, and otherwise filters
+// GetAllEvents, which loads the whole log.
+func QueryEvents(store Store, filter Filter) iter.Seq2[*Event, error] {
+	if querier, ok := store.(EventQuerier); ok {
+		return querier.Query(filter)
+	}
+	return filterEvents(store.GetAllEvents(), filter)
+}
+
+// Query scans the log as it was when the scan started; events appended meanwhile are
+// not yielded. See EventQuerier.
+func (es *EventStore) Query(filter Filter) iter.Seq2[*Event, error] {
+	return func(yield func(*Event, error) bool) {
+		// Appends, truncations, and deletions leave the slice read here untouched
+		es.mu.RLock()
+		events := es.events
+		es.mu.RUnlock()
+		for event := range filterEvents(events, filter) {
+			if !yield(event, nil) {
+				return
+			}
+		}
+	}
+}
+
+// filterEvents yields the events passing filter
+func filterEvents(events []*Event, filter Filter) iter.Seq2[*Event, error] {
+	return func(yield func(*Event, error) bool) {
+		for _, event := range events {
+			if filter.Matches(event) && !yield(event, nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Package common provides the Follower, the checkpoint-and-poll loop shared by
+// subscribers that follow the global event log, such as process managers, rule
+// engines, outbox relays, replicators and SQL read models.
+package common
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BatchHandler handles a batch of events of the global log. positions holds the
+// position of each event of the batch (see LogPosition), for error messages and
+// checkpoints of the handler's own. The batch counts as handled only when it
+// returns nil.
+type BatchHandler func(batch []*Event, positions []int) error
+
+// Follower remembers how far a subscriber has come through the global event log and
+// hands it the events appended since. The checkpoint advances past every batch the
+// handler accepts, so an event whose batch fails is handed over again on the next
+// pass.
+//
+// The checkpoint is kept in memory; subscribers resuming across restarts persist
+// Checkpoint and restore it with SetCheckpoint. It is safe for concurrent use, and
+// one pass runs at a time.
+type Follower struct {
+	name  string
+	store Store
+
+	// passMu serializes passes, so Checkpoint can be read while one runs
+	passMu   sync.Mutex
+	mu       sync.Mutex
+	position int
+	progress ProgressFunc
+}
+
+// NewFollower creates a follower of store's global log, starting at its beginning
+func NewFollower(name string, store Store) *Follower {
+	return &Follower{name: name, store: store}
+}
+
+// Name returns the name the follower checkpoints under
+func (f *Follower) Name() string {
+	return f.name
+}
+
+// Checkpoint returns the position of the last handled event
+func (f *Follower) Checkpoint() Checkpoint {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return Checkpoint{Projection: f.name, Position: f.position}
+}
+
+// SetCheckpoint resumes following after the given position, once a running pass
+// has ended
+func (f *Follower) SetCheckpoint(position int) {
+	f.passMu.Lock()
+	defer f.passMu.Unlock()
+	f.setPosition(position)
+}
+
+func (f *Follower) setPosition(position int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.position = position
+}
+
+// OnProgress sets a function called after every Process, e.g. to record metrics
+func (f *Follower) OnProgress(progress ProgressFunc) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.progress = progress
+}
+
+// Report tells the function set by OnProgress how a pass fared, for subscribers that
+// fail before calling Process
+func (f *Follower) Report(handled int, err error) {
+	f.mu.Lock()
+	progress := f.progress
+	f.mu.Unlock()
+	if progress != nil {
+		progress(f.Checkpoint(), handled, err)
+	}
+}
+
+// Process hands the events appended since the checkpoint to handle, in batches of
+// up to batchSize events, or all at once when batchSize is 0 or less, and returns
+// how many were handled. The first error stops the pass and is returned as is.
+func (f *Follower) Process(batchSize int, handle BatchHandler) (int, error) {
+	handled, err := f.process(batchSize, handle)
+	f.Report(handled, err)
+	return handled, err
+}
+
+func (f *Follower) process(batchSize int, handle BatchHandler) (int, error) {
+	f.passMu.Lock()
+	defer f.passMu.Unlock()
+
+	all := f.store.GetAllEvents()
+	start := len(all) - len(EventsAfter(all, f.Checkpoint().Position))
+	handled := 0
+	for start < len(all) {
+		end := len(all)
+		if batchSize > 0 {
+			end = min(start+batchSize, len(all))
+		}
+		positions := make([]int, 0, end-start)
+		for i := start; i < end; i++ {
+			positions = append(positions, LogPosition(all, i))
+		}
+		if err := handle(all[start:end], positions); err != nil {
+			return handled, err
+		}
+		handled += end - start
+		start = end
+		f.setPosition(positions[len(positions)-1])
+	}
+	return handled, nil
+}
+
+// Poll calls pass at once and then every interval, DefaultPollInterval when 0 or
+// less, until ctx is cancelled, returning ctx.Err(), or pass returns an error,
+// which it returns. Subscribers retrying failed passes on the next tick return nil
+// from pass.
+func Poll(ctx context.Context, interval time.Duration, pass func() error) error {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := pass(); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Package common provides convention-based event dispatch. Instead of switching on
+// event.Type, an aggregate declares a method per event type named On<EventType>,
+// taking the event's typed payload, and routes its On method through EventHandlers:
+//
+//	var cartHandlers = common.MustEventHandlers[*CartAggregate](common.DefaultRegistry)
+//
+//	func (ca *CartAggregate) OnGiftCardApplied(data GiftCardData) { ca.giftCards[data.GiftCardID] = data.Amount }
+//
+//	func (ca *CartAggregate) On(event *common.Event) error {
+//		return cartHandlers.Dispatch(ca, event)
+//	}
+package common
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+var (
+	eventPointerType = reflect.TypeOf((*Event)(nil))
+	errorType        = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// EventHandlers routes events to the handler methods of A, methods named
+// On<EventType> with one of the signatures
+//
+//	func(data T)
+//	func(data T, event *Event)
+//	func(event *Event)
+//	func()
+//
+// optionally returning an error. T is the payload type the event's Data is decoded
+// into, as AsTyped does: upcast first, and rejected with a *PayloadTypeError when
+// the registry has another payload type for the event type.
+type EventHandlers[A any] struct {
+	registry *Registry
+	handlers map[string]eventHandler
+	ignored  map[string]bool
+}
+
+type eventHandler struct {
+	method reflect.Value
+	// payload is the type the payload is decoded into; nil when not taken
+	payload reflect.Type
+	event   bool
+}
+
+// NewEventHandlers collects the handler methods of A. It returns an error for a
+// method named like a handler whose signature is not one of the supported ones.
+func NewEventHandlers[A any](registry *Registry) (*EventHandlers[A], error) {
+	h := &EventHandlers[A]{
+		registry: registry,
+		handlers: make(map[string]eventHandler),
+		ignored:  make(map[string]bool),
+	}
+	t := reflect.TypeOf((*A)(nil)).Elem()
+	for i := 0; i < t.NumMethod(); i++ {
+		method := t.Method(i)
+		eventType, ok := handledEventType(method.Name)
+		if !ok {
+			continue
+		}
+		handler, err := newEventHandler(method)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", t, method.Name, err)
+		}
+		h.handlers[eventType] = handler
+	}
+	return h, nil
+}
+
+// MustEventHandlers is like NewEventHandlers but panics on an unsupported handler,
+// for initializing package-level variables
+func MustEventHandlers[A any](registry *Registry) *EventHandlers[A] {
+	h, err := NewEventHandlers[A](registry)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+// Ignore accepts events of the given types without calling any handler, for events
+// that don't change the state the aggregate keeps
+func (h *EventHandlers[A]) Ignore(eventTypes ...string) *EventHandlers[A] {
+	for _, eventType := range eventTypes {
+		h.ignored[eventType] = true
+	}
+	return h
+}
+
+// EventTypes returns the event types with a handler, sorted
+func (h *EventHandlers[A]) EventTypes() []string {
+	types := make([]string, 0, len(h.handlers))
+	for eventType := range h.handlers {
+		types = append(types, eventType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Dispatch calls the handler of the event's type on aggregate. Ignored event types
+// are accepted as is; any other type without a handler is an error.
+func (h *EventHandlers[A]) Dispatch(aggregate A, event *Event) error {
+	handler, ok := h.handlers[event.Type]
+	if !ok {
+		if h.ignored[event.Type] {
+			return nil
+		}
+		return fmt.Errorf("unhandled event type: %s", event.Type)
+	}
+
+	args := []reflect.Value{reflect.ValueOf(aggregate)}
+	if handler.payload != nil {
+		data := reflect.New(handler.payload)
+		if _, err := decodePayload(h.registry, event, data.Interface()); err != nil {
+			return err
+		}
+		args = append(args, data.Elem())
+	}
+	if handler.event {
+		args = append(args, reflect.ValueOf(event))
+	}
+
+	results := handler.method.Call(args)
+	if len(results) == 1 && !results[0].IsNil() {
+		return results[0].Interface().(error)
+	}
+	return nil
+}
+
+// handledEventType returns the event type handled by a method named On<EventType>
+func handledEventType(name string) (string, bool) {
+	eventType, ok := strings.CutPrefix(name, "On")
+	if !ok || eventType == "" || !unicode.IsUpper(rune(eventType[0])) {
+		return "", false
+	}
+	return eventType, true
+}
+
+func newEventHandler(method reflect.Method) (eventHandler, error) {
+	t := method.Type
+	handler := eventHandler{method: method.Func}
+
+	params := make([]reflect.Type, 0, t.NumIn()-1)
+	for i := 1; i < t.NumIn(); i++ {
+		params = append(params, t.In(i))
+	}
+	if n := len(params); n > 0 && params[n-1] == eventPointerType {
+		handler.event = true
+		params = params[:n-1]
+	}
+	switch len(params) {
+	case 0:
+	case 1:
+		handler.payload = params[0]
+	default:
+		return eventHandler{}, fmt.Errorf("handlers take a payload and the event, got %d parameters", t.NumIn()-1)
+	}
+
+	switch {
+	case t.NumOut() == 0:
+	case t.NumOut() == 1 && t.Out(0) == errorType:
+	default:
+		return eventHandler{}, fmt.Errorf("handlers return nothing or an error")
+	}
+	return handler, nil
+}
@@ -0,0 +1,75 @@
+// Package common provides event metadata enrichment. Transports and bus middleware
+// record metadata such as the acting user in the context of a command; handlers
+// append through MetadataStore so every event the command produces carries it.
+package common
+
+import "context"
+
+type eventMetadataKey struct{}
+
+// WithEventMetadata returns a context adding key to the metadata of the events
+// produced while handling a command
+func WithEventMetadata(ctx context.Context, key string, value interface{}) context.Context {
+	metadata := make(map[string]interface{})
+	for k, v := range EventMetadataFrom(ctx) {
+		metadata[k] = v
+	}
+	metadata[key] = value
+	return context.WithValue(ctx, eventMetadataKey{}, metadata)
+}
+
+// EventMetadataFrom returns the event metadata recorded in ctx, or nil
+func EventMetadataFrom(ctx context.Context) map[string]interface{} {
+	metadata, _ := ctx.Value(eventMetadataKey{}).(map[string]interface{})
+	return metadata
+}
+
+// MetadataStore returns a store adding metadata to every event appended through it.
+// Keys an event already has keep their values. Without metadata, store is returned
+// as is.
+func MetadataStore(store Store, metadata map[string]interface{}) Store {
+	if len(metadata) == 0 {
+		return store
+	}
+	return &metadataStore{Store: store, metadata: metadata}
+}
+
+type metadataStore struct {
+	Store
+	metadata map[string]interface{}
+}
+
+func (s *metadataStore) Append(event *Event) error {
+	s.stamp(event)
+	return s.Store.Append(event)
+}
+
+// AppendBatch adds the metadata to every event and appends them atomically when the
+// underlying store is a BatchAppender, one by one otherwise
+func (s *metadataStore) AppendBatch(events []*Event) error {
+	batch, ok := s.Store.(BatchAppender)
+	if !ok {
+		for _, event := range events {
+			if err := s.Append(event); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, event := range events {
+		s.stamp(event)
+	}
+	return batch.AppendBatch(events)
+}
+
+// stamp adds the metadata to an event, keeping the values of keys it already has
+func (s *metadataStore) stamp(event *Event) {
+	if event.Metadata == nil {
+		event.Metadata = make(map[string]interface{}, len(s.metadata))
+	}
+	for key, value := range s.metadata {
+		if _, exists := event.Metadata[key]; !exists {
+			event.Metadata[key] = value
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Package common provides the Projection contract and replay helpers for read models.
+// Projections fold events from the global log into read models that can be rebuilt
+// from scratch whenever their shape changes.
+package common
+
+import (
+	"sort"
+	"time"
+)
+
+// Projection builds a read model by folding events from the global event log
+type Projection interface {
+	// Name returns the registered name of the projection
+	Name() string
+	// On applies an event to the read model
+	On(event *Event) error
+	// State returns the current read model
+	State() interface{}
+}
+
+// EventConsumer is implemented by projections that declare which event types they consume.
+// Tooling uses it to document the flow from events to read models.
+type EventConsumer interface {
+	Consumes() []string
+}
+
+// Recomputer is implemented by projections whose read model changes with the passage
+// of time as well as with events, such as scores decaying with age. Per-event folds
+// only see event time, so Recompute is called periodically to bring the read model
+// up to now; AsyncProjection calls it after every catch-up. It must not change what
+// later events fold into beyond rounding, so rebuilds agree with live projections.
+type Recomputer interface {
+	Recompute(now time.Time) error
+}
+
+// ProjectionFactory creates a fresh, empty projection
+type ProjectionFactory func() Projection
+
+// Checkpoint records how far a projection has processed the global event log.
+// Position is the position of the last processed event (see LogPosition).
+type Checkpoint struct {
+	Projection string `json:"projection"`
+	Position   int    `json:"position"`
+}
+
+// ProgressFunc is told how a continuous subscription, such as an AsyncProjection,
+// fared in each catch-up: the checkpoint it reached, how many events it applied, and
+// the error that stopped it, if any
+type ProgressFunc func(checkpoint Checkpoint, applied int, err error)
+
+// LogPosition returns the position in the global log of the event at index i of
+// events, as returned by GetAllEvents: the Position its store stamped it with, or
+// i+1 in stores whose log only grows. Positions increase along the log and are
+// never reused, so a checkpoint keeps its place when events before it are deleted.
+func LogPosition(events []*Event, i int) int {
+	if position := events[i].Position; position > 0 {
+		return position
+	}
+	return i + 1
+}
+
+// EventsAfter returns the events of a global log, as returned by GetAllEvents, that
+// come after position
+func EventsAfter(events []*Event, position int) []*Event {
+	i := sort.Search(len(events), func(i int) bool { return LogPosition(events, i) > position })
+	return events[i:]
+}
+
+// ReplayProjection applies every event after the from position to the projection.
+// The optional progress callback is invoked after each event with the current checkpoint
+// and the total number of events in the log. The final checkpoint is returned; on error
+// it points at the last event that was applied successfully.
+func ReplayProjection(store Store, projection Projection, from int, progress func(checkpoint Checkpoint, total int)) (Checkpoint, error) {
+	checkpoint := Checkpoint{Projection: projection.Name(), Position: from}
+
+	all := store.GetAllEvents()
+	start := len(all) - len(EventsAfter(all, from))
+	for i := start; i < len(all); i++ {
+		if err := projection.On(all[i]); err != nil {
+			return checkpoint, err
+		}
+		checkpoint.Position = LogPosition(all, i)
+		if progress != nil {
+			progress(checkpoint, len(all))
+		}
+	}
+	return checkpoint, nil
+}
@@ -0,0 +1,69 @@
+// Package common provides projection snapshots, letting a projection resume from a
+// saved state and checkpoint instead of replaying the whole global event log.
+package common
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ProjectionSnapshotPrefix prefixes the IDs projection snapshots are saved under in
+// a SnapshotStore, keeping them apart from the snapshots of aggregates
+const ProjectionSnapshotPrefix = "$projection-"
+
+// DefaultSnapshotEvery is how many events an AsyncProjection applies between
+// snapshots when no interval is given
+const DefaultSnapshotEvery = 1000
+
+// ProjectionSnapshotter is implemented by projections whose read model can be saved
+// in a snapshot and restored from one, typically heavyweight read models, such as
+// co-occurrence counts or leaderboards, whose full replay makes restarts slow. The
+// state must cover everything later events fold into, not just what State returns.
+type ProjectionSnapshotter interface {
+	Projection
+	// SnapshotState returns the state to save, encodable as JSON
+	SnapshotState() (interface{}, error)
+	// RestoreSnapshot replaces the projection's state with a snapshot's
+	RestoreSnapshot(snapshot Snapshot) error
+}
+
+// ProjectionSnapshotID returns the ID the snapshots of a projection are saved under
+func ProjectionSnapshotID(name string) string {
+	return ProjectionSnapshotPrefix + name
+}
+
+// SnapshotProjection saves the state of a projection as of a checkpoint. The
+// snapshot's Version is the checkpoint's position in the global event log.
+func SnapshotProjection(snapshots SnapshotStore, projection ProjectionSnapshotter, checkpoint Checkpoint) (Snapshot, error) {
+	state, err := projection.SnapshotState()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	snapshot := Snapshot{
+		StreamID: ProjectionSnapshotID(projection.Name()),
+		Version:  checkpoint.Position,
+		State:    data,
+		TakenAt:  time.Now().UTC(),
+	}
+	return snapshot, snapshots.SaveSnapshot(snapshot)
+}
+
+// RestoreProjection restores the latest snapshot of a projection and returns the
+// checkpoint to resume after, reporting false and leaving the projection alone when
+// there is no snapshot
+func RestoreProjection(snapshots SnapshotStore, projection ProjectionSnapshotter) (Checkpoint, bool, error) {
+	checkpoint := Checkpoint{Projection: projection.Name()}
+	snapshot, found, err := snapshots.LoadSnapshot(ProjectionSnapshotID(projection.Name()))
+	if err != nil || !found {
+		return checkpoint, false, err
+	}
+	if err := projection.RestoreSnapshot(snapshot); err != nil {
+		return checkpoint, false, err
+	}
+	checkpoint.Position = snapshot.Version
+	return checkpoint, true, nil
+}
@@ -0,0 +1,9 @@
+// Package common provides the Query contract answered by read models.
+package common
+
+// Query is a request to read from a read model. Queries never change state; the
+// query type routes the request to the handler that owns the read model.
+type Query interface {
+	// QueryType returns the registered name of the query, e.g. "TodosByTag"
+	QueryType() string
+}
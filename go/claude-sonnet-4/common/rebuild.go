@@ -0,0 +1,100 @@
+// Package common provides partitioned parallel rebuilds of projections whose read
+// model is the union of independent per-aggregate read models.
+package common
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+// PartitionSafe is implemented by projections that can be rebuilt in partitions.
+// Every event must only affect state derived from the events of its own aggregate,
+// apart from totals that add up, so the global log can be split by aggregate ID,
+// each partition folded into a projection of its own, and the results merged. The
+// events of an aggregate keep their order within its partition, but the order of
+// events of different aggregates is lost, so projections folding events of one
+// aggregate into another's state, such as catalog prices into carts, are not
+// partition-safe.
+type PartitionSafe interface {
+	Projection
+	// Merge folds in the read model of a projection created by the same factory and
+	// built from another partition, which holds none of this one's aggregates
+	Merge(other Projection) error
+}
+
+// PartitionOf returns which of n partitions an aggregate falls in, by FNV-1a hash
+// of its ID
+func PartitionOf(aggregateID string, n int) int {
+	if n <= 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(aggregateID))
+	return int(h.Sum32() % uint32(n))
+}
+
+// RebuildParallel rebuilds a fresh projection from the whole global event log. A
+// PartitionSafe projection is rebuilt by up to workers goroutines, each folding the
+// aggregates of one partition (see PartitionOf) into a projection of its own
+// created by factory, which are then merged into the first. Other projections, and
+// rebuilds with fewer than two workers, replay the log sequentially. The checkpoint
+// is the end of the log as it was when the rebuild started.
+func RebuildParallel(store Store, factory ProjectionFactory, workers int) (Projection, Checkpoint, error) {
+	events := store.GetAllEvents()
+	projection := factory()
+	checkpoint := Checkpoint{Projection: projection.Name()}
+
+	merged, partitionSafe := projection.(PartitionSafe)
+	if !partitionSafe || workers < 2 {
+		for i, event := range events {
+			if err := projection.On(event); err != nil {
+				return projection, checkpoint, err
+			}
+			checkpoint.Position = LogPosition(events, i)
+		}
+		return projection, checkpoint, nil
+	}
+
+	partitions := make([][]*Event, workers)
+	for _, event := range events {
+		partition := PartitionOf(event.AggregateID, workers)
+		partitions[partition] = append(partitions[partition], event)
+	}
+	projections := make([]Projection, workers)
+	projections[0] = projection
+	for i := 1; i < workers; i++ {
+		projections[i] = factory()
+	}
+
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for i := range partitions {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for _, event := range partitions[i] {
+				if err := projections[i].On(event); err != nil {
+					errs[i] = fmt.Errorf("partition %d: %s event %s: %w", i, event.Type, event.ID, err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return projection, checkpoint, err
+		}
+	}
+
+	for i := 1; i < workers; i++ {
+		if err := merged.Merge(projections[i]); err != nil {
+			return projection, checkpoint, fmt.Errorf("merging partition %d: %w", i, err)
+		}
+	}
+	if len(events) > 0 {
+		checkpoint.Position = LogPosition(events, len(events)-1)
+	}
+	return projection, checkpoint, nil
+}
@@ -0,0 +1,112 @@
+// Package common provides event redaction for legal takedowns. Redaction rewrites a
+// stored event in place, replacing payload fields with a marker, and records who
+// redacted what in an audit stream. It is a last resort for data that cannot be
+// handled by crypto-shredding; the rest of the store stays append-only.
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// RedactedValue replaces the value of every redacted payload field
+	RedactedValue = "[redacted]"
+	// RedactionEventType is the type of the audit events recorded by RedactEvent
+	RedactionEventType = "$redaction"
+	// RedactionStreamID is the stream holding the redaction audit events
+	RedactionStreamID = "$redactions"
+)
+
+// Redactor is implemented by stores that can rewrite stored events
+type Redactor interface {
+	// RedactEvent replaces the given payload fields of the event at version in a
+	// stream with RedactedValue and appends a RedactionEventType audit event to
+	// RedactionStreamID, which it returns. The audit event records the event and the
+	// field names, never their values.
+	RedactEvent(streamID string, version int, fields []string) (*Event, error)
+}
+
+// RedactedCopy returns a copy of event with the given payload fields replaced by
+// RedactedValue. Every field must be present in the payload. Backends call it from
+// RedactEvent.
+func RedactedCopy(event *Event, fields []string) (*Event, error) {
+	if event.AggregateID == RedactionStreamID {
+		return nil, &InvalidCommandError{Message: "the redaction audit stream cannot be redacted"}
+	}
+	if len(fields) == 0 {
+		return nil, &InvalidCommandError{Message: "no fields to redact"}
+	}
+
+	var missing []string
+	for _, field := range fields {
+		if _, ok := event.Data[field]; !ok {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, &InvalidCommandError{Message: fmt.Sprintf("event %s v%d has no fields %s", event.AggregateID, event.Version, strings.Join(missing, ", "))}
+	}
+
+	redacted := *event
+	redacted.Data = make(map[string]interface{}, len(event.Data))
+	for key, value := range event.Data {
+		redacted.Data[key] = value
+	}
+	for _, field := range fields {
+		redacted.Data[field] = RedactedValue
+	}
+	return &redacted, nil
+}
+
+// NewRedactionEvent creates the audit event recording the redaction of fields from
+// event, at the given version of RedactionStreamID
+func NewRedactionEvent(event *Event, fields []string, version int) *Event {
+	sorted := append([]string(nil), fields...)
+	sort.Strings(sorted)
+	return NewEvent(RedactionEventType, RedactionStreamID, version, map[string]interface{}{
+		"stream_id":  event.AggregateID,
+		"version":    event.Version,
+		"event_id":   event.ID,
+		"event_type": event.Type,
+		"fields":     sorted,
+	}, nil)
+}
+
+// RedactEvent rewrites the event at version in a stream. See Redactor.
+func (es *EventStore) RedactEvent(streamID string, version int, fields []string) (*Event, error) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	stream, exists := es.streams[streamID]
+	if !exists {
+		return nil, &StreamNotFoundError{StreamID: streamID}
+	}
+	index := sort.Search(len(stream), func(i int) bool { return stream[i].Version >= version })
+	if index == len(stream) || stream[index].Version != version {
+		return nil, &InvalidCommandError{Message: fmt.Sprintf("stream %s has no version %d", streamID, version)}
+	}
+
+	original := stream[index]
+	redacted, err := RedactedCopy(original, fields)
+	if err != nil {
+		return nil, err
+	}
+	audit := NewRedactionEvent(original, fields, es.streamVersion(RedactionStreamID)+1)
+
+	// Replace the event in new slices so callers still holding the old ones are
+	// unaffected, like Fork and TruncateStream
+	es.streams[streamID] = append(append(append(make([]*Event, 0, len(stream)), stream[:index]...), redacted), stream[index+1:]...)
+	events := append(make([]*Event, 0, len(es.events)+1), es.events...)
+	for i, event := range events {
+		if event == original {
+			events[i] = redacted
+		}
+	}
+	es.events = events
+	es.store(audit)
+	return audit, nil
+}
+
+var _ Redactor = (*EventStore)(nil)
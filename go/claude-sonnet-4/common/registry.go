@@ -0,0 +1,273 @@
+// Package common provides the Registry used to look up domain components by name.
+package common
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Registry holds the named components contributed by domain packages so that
+// tooling such as the sem CLI can discover them without hard-coding each domain.
+type Registry struct {
+	mu           sync.RWMutex
+	aggregates   map[string]AggregateInfo
+	categories   map[string]CategoryInfo
+	commands     map[string]CommandInfo
+	events       map[string]EventInfo
+	projections  map[string]ProjectionFactory
+	upcasters    map[upcasterKey]Upcaster
+	payloadTypes map[string]reflect.Type
+}
+
+// AggregateInfo describes a registered aggregate type
+type AggregateInfo struct {
+	Name string `json:"name"`
+}
+
+// CategoryInfo describes a registered stream category: streams named
+// "<Name>-<id>" belong to Aggregate, and only its events may be appended to them
+type CategoryInfo struct {
+	Name      string `json:"name"`
+	Aggregate string `json:"aggregate"`
+}
+
+// CommandInfo describes a registered command and the events it can produce
+type CommandInfo struct {
+	Name      string   `json:"name"`
+	Aggregate string   `json:"aggregate"`
+	Produces  []string `json:"produces"`
+}
+
+// EventInfo describes a registered event type and the aggregate that emits it
+type EventInfo struct {
+	Name      string `json:"name"`
+	Aggregate string `json:"aggregate"`
+	// Payload documents the keys the event carries in its Data map
+	Payload []FieldInfo `json:"payload,omitempty"`
+	// SchemaVersion is the current schema version of the payload; 0 means 1.
+	// Stored events with an older schema version are migrated by upcasters.
+	SchemaVersion int `json:"schema_version,omitempty"`
+}
+
+// FieldInfo describes a single payload field of an event
+type FieldInfo struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	// Optional fields may be left out of the payload
+	Optional bool `json:"optional,omitempty"`
+	// Sensitive fields hold personal or secret data, such as a customer's email
+	// address; cryptostore encrypts them at rest, leaving the other fields readable
+	Sensitive bool `json:"sensitive,omitempty"`
+}
+
+// DefaultRegistry is the registry domain packages register themselves with
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		aggregates:   make(map[string]AggregateInfo),
+		categories:   make(map[string]CategoryInfo),
+		commands:     make(map[string]CommandInfo),
+		events:       make(map[string]EventInfo),
+		projections:  make(map[string]ProjectionFactory),
+		upcasters:    make(map[upcasterKey]Upcaster),
+		payloadTypes: make(map[string]reflect.Type),
+	}
+}
+
+// RegisterAggregate records an aggregate type
+func (r *Registry) RegisterAggregate(info AggregateInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.aggregates[info.Name]; exists {
+		panic(fmt.Sprintf("aggregate %q is already registered", info.Name))
+	}
+	r.aggregates[info.Name] = info
+}
+
+// RegisterCategory records a stream category
+func (r *Registry) RegisterCategory(info CategoryInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.categories[info.Name]; exists {
+		panic(fmt.Sprintf("category %q is already registered", info.Name))
+	}
+	r.categories[info.Name] = info
+}
+
+// Categories returns all registered stream categories, sorted by name
+func (r *Registry) Categories() []CategoryInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	infos := make([]CategoryInfo, 0, len(r.categories))
+	for _, info := range r.categories {
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// CategoryOf returns the registered category a stream is named after. When several
+// match, as "gift" and "gift-card" both match "gift-card-1", the longest wins.
+func (r *Registry) CategoryOf(streamID string) (CategoryInfo, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var found CategoryInfo
+	for name, info := range r.categories {
+		if strings.HasPrefix(streamID, name+"-") && len(name) > len(found.Name) {
+			found = info
+		}
+	}
+	return found, found.Name != ""
+}
+
+// RegisterCommand records a command type
+func (r *Registry) RegisterCommand(info CommandInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.commands[info.Name]; exists {
+		panic(fmt.Sprintf("command %q is already registered", info.Name))
+	}
+	r.commands[info.Name] = info
+}
+
+// RegisterEvent records an event type
+func (r *Registry) RegisterEvent(info EventInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.events[info.Name]; exists {
+		panic(fmt.Sprintf("event %q is already registered", info.Name))
+	}
+	r.events[info.Name] = info
+}
+
+// Aggregates returns all registered aggregates, sorted by name
+func (r *Registry) Aggregates() []AggregateInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	infos := make([]AggregateInfo, 0, len(r.aggregates))
+	for _, info := range r.aggregates {
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// Commands returns all registered commands, sorted by name
+func (r *Registry) Commands() []CommandInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	infos := make([]CommandInfo, 0, len(r.commands))
+	for _, info := range r.commands {
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// CommandTypes returns the names of the commands registered for an aggregate, sorted
+func (r *Registry) CommandTypes(aggregate string) []string {
+	names := make([]string, 0)
+	for _, info := range r.Commands() {
+		if info.Aggregate == aggregate {
+			names = append(names, info.Name)
+		}
+	}
+	return names
+}
+
+// Events returns all registered events, sorted by name
+func (r *Registry) Events() []EventInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	infos := make([]EventInfo, 0, len(r.events))
+	for _, info := range r.events {
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// EventTypes returns the names of the events registered for an aggregate, sorted
+func (r *Registry) EventTypes(aggregate string) []string {
+	names := make([]string, 0)
+	for _, info := range r.Events() {
+		if info.Aggregate == aggregate {
+			names = append(names, info.Name)
+		}
+	}
+	return names
+}
+
+// Event looks up a registered event type by name
+func (r *Registry) Event(name string) (EventInfo, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	info, exists := r.events[name]
+	return info, exists
+}
+
+// SensitiveFields returns the names of the payload fields of an event type marked
+// sensitive, in registration order
+func (r *Registry) SensitiveFields(eventType string) []string {
+	info, _ := r.Event(eventType)
+	var fields []string
+	for _, field := range info.Payload {
+		if field.Sensitive {
+			fields = append(fields, field.Name)
+		}
+	}
+	return fields
+}
+
+// RegisterProjection makes a projection available under name.
+// It panics if the name is already taken, as that indicates a programming error.
+func (r *Registry) RegisterProjection(name string, factory ProjectionFactory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.projections[name]; exists {
+		panic(fmt.Sprintf("projection %q is already registered", name))
+	}
+	r.projections[name] = factory
+}
+
+// NewProjection creates a fresh instance of the named projection
+func (r *Registry) NewProjection(name string) (Projection, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	factory, exists := r.projections[name]
+	if !exists {
+		return nil, fmt.Errorf("projection %q is not registered", name)
+	}
+	return factory(), nil
+}
+
+// ProjectionNames returns the names of all registered projections, sorted
+func (r *Registry) ProjectionNames() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.projections))
+	for name := range r.projections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,213 @@
+// Package common provides the Repository loading aggregates of one type from a store.
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultLoadParallelism bounds the hydrations LoadMany runs at once when the
+// repository sets no Parallelism
+const DefaultLoadParallelism = 8
+
+// Repository loads aggregates of one type from a store. It keeps the last aggregate
+// loaded for every stream, so read-mostly callers, such as a page rendering the same
+// cart over and over, can skip replay while the stream hasn't moved (LoadIfChanged).
+// Cached aggregates are shared between callers and must be treated as read-only;
+// commands should be handled by a freshly loaded aggregate.
+type Repository[A Aggregate] struct {
+	// Parallelism bounds the hydrations LoadMany runs at once; 0 uses DefaultLoadParallelism
+	Parallelism int
+	// Category is the stream category of the aggregate type, e.g. "cart"; Namer
+	// names its streams, DefaultStreamNamer when nil
+	Category string
+	Namer    StreamNamer
+
+	store        Store
+	newAggregate func(Store) A
+
+	mu    sync.Mutex
+	cache map[string]A
+}
+
+// NewRepository creates a repository building aggregates with newAggregate, e.g.
+// NewRepository(store, cart.NewCartAggregate)
+func NewRepository[A Aggregate](store Store, newAggregate func(Store) A) *Repository[A] {
+	return &Repository[A]{
+		store:        store,
+		newAggregate: newAggregate,
+		cache:        make(map[string]A),
+	}
+}
+
+// Load hydrates a fresh aggregate from its stream, replacing the cached one. It
+// returns a *StreamNotFoundError if the stream has no events.
+func (r *Repository[A]) Load(id string) (A, error) {
+	aggregate := r.newAggregate(r.store)
+	if err := aggregate.Hydrate(id); err != nil {
+		var zero A
+		return zero, err
+	}
+	if aggregate.Version() == 0 {
+		var zero A
+		return zero, &StreamNotFoundError{StreamID: id}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cached, exists := r.cache[id]; !exists || cached.Version() < aggregate.Version() {
+		r.cache[id] = aggregate
+	}
+	return aggregate, nil
+}
+
+// LoadFor returns the aggregate to handle a command with: a new one when the command
+// names no stream, such as one creating an aggregate, otherwise one freshly hydrated
+// from the stream it names. It returns a *StreamNotFoundError if that stream has no
+// events. The aggregate is not cached, since handling the command changes it.
+func (r *Repository[A]) LoadFor(command Command) (A, error) {
+	aggregate := r.newAggregate(r.store)
+	id := command.AggregateID()
+	if id == "" {
+		return aggregate, nil
+	}
+	if err := aggregate.Hydrate(id); err != nil {
+		var zero A
+		return zero, err
+	}
+	if aggregate.Version() == 0 {
+		var zero A
+		return zero, &StreamNotFoundError{StreamID: id}
+	}
+	return aggregate, nil
+}
+
+// LoadIfChanged returns the aggregate of a stream and whether its version differs
+// from knownVersion, the version the caller last saw. The stream version is read
+// first, and a cached aggregate at that version is returned without replaying the
+// stream; only a stream that moved since it was cached is hydrated again.
+func (r *Repository[A]) LoadIfChanged(id string, knownVersion int) (A, bool, error) {
+	version := r.store.GetStreamVersion(id)
+	if version == 0 {
+		var zero A
+		return zero, false, &StreamNotFoundError{StreamID: id}
+	}
+
+	r.mu.Lock()
+	cached, exists := r.cache[id]
+	r.mu.Unlock()
+	if exists && cached.Version() == version {
+		return cached, version != knownVersion, nil
+	}
+
+	aggregate, err := r.Load(id)
+	if err != nil {
+		return aggregate, false, err
+	}
+	return aggregate, aggregate.Version() != knownVersion, nil
+}
+
+// LoadMany loads the aggregates of many streams concurrently, hydrating at most
+// Parallelism of them at once. It returns the aggregates that loaded, keyed by ID,
+// and a *LoadManyError with the per-ID errors of the rest, so batch operations such
+// as expiring stale carts can proceed with what loaded.
+func (r *Repository[A]) LoadMany(ids []string) (map[string]A, error) {
+	parallelism := r.Parallelism
+	if parallelism < 1 {
+		parallelism = DefaultLoadParallelism
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		loaded    = make(map[string]A, len(ids))
+		failed    = make(map[string]error)
+		seen      = make(map[string]bool, len(ids))
+		semaphore = make(chan struct{}, parallelism)
+	)
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(id string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			aggregate, err := r.Load(id)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed[id] = err
+				return
+			}
+			loaded[id] = aggregate
+		}(id)
+	}
+	wg.Wait()
+
+	if len(failed) > 0 {
+		return loaded, &LoadManyError{Errors: failed, Requested: len(seen)}
+	}
+	return loaded, nil
+}
+
+// NewStreamID returns the ID of a new stream of the repository's category
+func (r *Repository[A]) NewStreamID() string {
+	return r.namer().NewStreamID(r.Category)
+}
+
+// LoadCategory loads the aggregates of every stream in the repository's category,
+// like LoadMany
+func (r *Repository[A]) LoadCategory() (map[string]A, error) {
+	return r.LoadMany(CategoryStreamIDs(r.store, r.namer(), r.Category))
+}
+
+func (r *Repository[A]) namer() StreamNamer {
+	if r.Namer == nil {
+		return DefaultStreamNamer
+	}
+	return r.Namer
+}
+
+// LoadManyError reports the streams LoadMany could not load
+type LoadManyError struct {
+	// Errors holds the error of every stream that failed, by ID
+	Errors map[string]error
+	// Requested is the number of distinct streams requested
+	Requested int
+}
+
+func (e *LoadManyError) Error() string {
+	ids := e.ids()
+	details := make([]string, 0, len(ids))
+	for _, id := range ids {
+		details = append(details, fmt.Sprintf("%s: %v", id, e.Errors[id]))
+	}
+	return fmt.Sprintf("loading %d of %d aggregates failed: %s", len(ids), e.Requested, strings.Join(details, "; "))
+}
+
+// Unwrap returns the per-ID errors ordered by ID, so errors.Is and errors.As match
+// any of them
+func (e *LoadManyError) Unwrap() []error {
+	ids := e.ids()
+	errs := make([]error, len(ids))
+	for i, id := range ids {
+		errs[i] = e.Errors[id]
+	}
+	return errs
+}
+
+func (e *LoadManyError) ids() []string {
+	ids := make([]string, 0, len(e.Errors))
+	for id := range e.Errors {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,118 @@
+// Package common provides aggregate snapshots, letting hydration start from a saved
+// state instead of the beginning of a stream.
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SnapshotStreamPrefix prefixes the streams StoreSnapshots records snapshots in
+const SnapshotStreamPrefix = "$snapshots-"
+
+// EventTypeSnapshotTaken is the type of the events StoreSnapshots records
+const EventTypeSnapshotTaken = "SnapshotTaken"
+
+// Snapshot is the state of an aggregate as of a version of its stream
+type Snapshot struct {
+	StreamID string          `json:"stream_id"`
+	Version  int             `json:"version"`
+	State    json.RawMessage `json:"state"`
+	TakenAt  time.Time       `json:"taken_at"`
+}
+
+// SnapshotStore keeps the latest snapshot of every stream
+type SnapshotStore interface {
+	SaveSnapshot(snapshot Snapshot) error
+	// LoadSnapshot returns the latest snapshot of a stream, reporting false when
+	// there is none
+	LoadSnapshot(streamID string) (Snapshot, bool, error)
+}
+
+// Snapshotter is implemented by aggregates whose state can be saved in a snapshot
+// and restored from one
+type Snapshotter interface {
+	Aggregate
+	// SnapshotState returns the state to save, encodable as JSON
+	SnapshotState() (interface{}, error)
+	// RestoreSnapshot replaces the aggregate's state with a snapshot's
+	RestoreSnapshot(snapshot Snapshot) error
+}
+
+// TakeSnapshot saves the state of a hydrated aggregate at its current version
+func TakeSnapshot(snapshots SnapshotStore, aggregate Snapshotter) (Snapshot, error) {
+	if !aggregate.IsLive() || aggregate.Version() == 0 {
+		return Snapshot{}, fmt.Errorf("aggregate %q has no state to snapshot", aggregate.ID())
+	}
+	state, err := aggregate.SnapshotState()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	snapshot := Snapshot{StreamID: aggregate.ID(), Version: aggregate.Version(), State: data, TakenAt: time.Now().UTC()}
+	return snapshot, snapshots.SaveSnapshot(snapshot)
+}
+
+// StoreSnapshots is a SnapshotStore recording each snapshot as a SnapshotTaken event
+// in the "$snapshots-<stream>" stream of a Store, so snapshots are as durable as the
+// backend. Give it a store of its own to keep snapshots out of the global event log
+// that projections read.
+type StoreSnapshots struct {
+	store Store
+}
+
+var _ SnapshotStore = (*StoreSnapshots)(nil)
+
+// NewStoreSnapshots creates a snapshot store recording snapshots in store
+func NewStoreSnapshots(store Store) *StoreSnapshots {
+	return &StoreSnapshots{store: store}
+}
+
+// SaveSnapshot appends a snapshot to its stream's snapshot stream
+func (s *StoreSnapshots) SaveSnapshot(snapshot Snapshot) error {
+	var state interface{}
+	if err := json.Unmarshal(snapshot.State, &state); err != nil {
+		return fmt.Errorf("snapshot of %s: %w", snapshot.StreamID, err)
+	}
+	streamID := SnapshotStreamPrefix + snapshot.StreamID
+	data := map[string]interface{}{
+		"stream_id": snapshot.StreamID,
+		"version":   snapshot.Version,
+		"state":     state,
+		"taken_at":  snapshot.TakenAt.Format(time.RFC3339Nano),
+	}
+	return s.store.Append(NewEvent(EventTypeSnapshotTaken, streamID, s.store.GetStreamVersion(streamID)+1, data, nil))
+}
+
+// LoadSnapshot returns the last snapshot appended for a stream
+func (s *StoreSnapshots) LoadSnapshot(streamID string) (Snapshot, bool, error) {
+	events, err := s.store.GetStream(SnapshotStreamPrefix + streamID)
+	if errors.Is(err, ErrStreamNotFound) {
+		return Snapshot{}, false, nil
+	}
+	if err != nil {
+		return Snapshot{}, false, err
+	}
+
+	latest := events[len(events)-1]
+	encoded, err := json.Marshal(latest.Data)
+	if err != nil {
+		return Snapshot{}, false, err
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(encoded, &snapshot); err != nil {
+		return Snapshot{}, false, fmt.Errorf("snapshot of %s: %w", streamID, err)
+	}
+	return snapshot, true, nil
+}
+
+// IsSnapshotStream reports whether a stream holds snapshots rather than events
+func IsSnapshotStream(streamID string) bool {
+	return strings.HasPrefix(streamID, SnapshotStreamPrefix)
+}
@@ -0,0 +1,83 @@
+// Package common provides the Store interface implemented by event store backends.
+package common
+
+import "fmt"
+
+// Store is the contract shared by event store backends. The in-memory EventStore
+// implements it, as do the persistent backends, so aggregates, queries, and tooling
+// can work against any of them.
+type Store interface {
+	// Append adds an event to the end of its aggregate stream. Backends reject events
+	// whose version does not directly follow the stream's version with a
+	// *ConcurrencyError, which gives aggregates optimistic concurrency control.
+	Append(event *Event) error
+	// GetStream retrieves all events for a given aggregate ID
+	GetStream(aggregateID string) ([]*Event, error)
+	// GetStreamVersion returns the current version of a stream, or 0 if it doesn't exist
+	GetStreamVersion(aggregateID string) int
+	// StreamLength returns the number of events a stream holds, or 0 if it doesn't
+	// exist. It differs from the version once a stream is truncated.
+	StreamLength(aggregateID string) int
+	// HeadEvent returns the latest event of a stream without reading the rest, or a
+	// *StreamNotFoundError if it has none
+	HeadEvent(aggregateID string) (*Event, error)
+	// GetAllEvents returns every event in append order
+	GetAllEvents() []*Event
+	// StreamIDs returns the identifiers of all streams in the store, sorted
+	StreamIDs() []string
+}
+
+// BatchAppender is implemented by backends that can append several events
+// atomically: either every event is stored or, on error, none is. Events may span
+// streams; events of the same stream must be in version order.
+type BatchAppender interface {
+	AppendBatch(events []*Event) error
+}
+
+// StreamDeleter is implemented by backends that can remove a stream for good, for
+// retention policies that must erase data rather than archive it. A deleted stream
+// leaves the global log too, so projections rebuilt afterwards never see it; the
+// events left keep their positions, so checkpoints stay valid.
+type StreamDeleter interface {
+	// DeleteStream removes every event of a stream if it is still at
+	// expectedVersion, so a stream changed since the caller decided to delete it
+	// is kept. It returns a *StreamNotFoundError if the stream has no events and a
+	// *ConcurrencyError if its version differs.
+	DeleteStream(streamID string, expectedVersion int) error
+}
+
+// Transactor is implemented by backends that can run a function as one transaction,
+// for the rare flows that must append to several streams atomically, such as
+// checking out a cart and creating its order in the same process.
+type Transactor interface {
+	// WithinTransaction calls fn with a Store whose appends are stored together when
+	// fn returns nil and discarded when it returns an error, which is returned.
+	// Reads through tx see its own appends; other writers wait until it ends.
+	WithinTransaction(fn func(tx Store) error) error
+}
+
+var (
+	_ Store         = (*EventStore)(nil)
+	_ BatchAppender = (*EventStore)(nil)
+	_ StreamDeleter = (*EventStore)(nil)
+	_ Transactor    = (*EventStore)(nil)
+)
+
+// WithinTransaction runs fn in a transaction of store. The store must implement
+// Transactor.
+func WithinTransaction(store Store, fn func(tx Store) error) error {
+	transactor, ok := store.(Transactor)
+	if !ok {
+		return fmt.Errorf("store %T does not support transactions", store)
+	}
+	return transactor.WithinTransaction(fn)
+}
+
+// CheckVersion returns a *ConcurrencyError unless event directly follows a stream at
+// currentVersion. Backends call it from Append while holding their write lock.
+func CheckVersion(event *Event, currentVersion int) error {
+	if event.Version != currentVersion+1 {
+		return &ConcurrencyError{StreamID: event.AggregateID, Expected: event.Version - 1, Actual: currentVersion}
+	}
+	return nil
+}
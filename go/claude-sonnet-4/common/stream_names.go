@@ -0,0 +1,77 @@
+// Package common provides the stream naming contract. Streams of an aggregate type
+// share a category, and the category is part of the stream ID, so stores with native
+// category support (EventStoreDB's $ce- streams) and stores scanning IDs by prefix
+// agree on which streams a category holds.
+package common
+
+import "strings"
+
+// StreamNamer names the streams of aggregates and tells the category of a stream
+type StreamNamer interface {
+	// NewStreamID returns the ID of a new stream in category
+	NewStreamID(category string) string
+	// Category returns the category of a stream, or "" when its ID has none
+	Category(streamID string) string
+}
+
+// CategoryStreamNamer names streams "<category>-<id>", the convention of
+// EventStoreDB's category projection: the category is everything before the first
+// dash. IDs come from NewID, a random UUID when nil.
+type CategoryStreamNamer struct {
+	NewID IDGenerator
+}
+
+// DefaultStreamNamer names streams "<category>-<uuid>"
+var DefaultStreamNamer StreamNamer = CategoryStreamNamer{}
+
+// NewStreamID returns "<category>-<id>"
+func (n CategoryStreamNamer) NewStreamID(category string) string {
+	newID := n.NewID
+	if newID == nil {
+		newID = NewUUID
+	}
+	return category + "-" + newID()
+}
+
+// Category returns the part of streamID before the first dash
+func (n CategoryStreamNamer) Category(streamID string) string {
+	category, _, found := strings.Cut(streamID, "-")
+	if !found {
+		return ""
+	}
+	return category
+}
+
+// CategoryReader is implemented by stores that read the events of a category
+// natively instead of scanning the global log
+type CategoryReader interface {
+	// ReadCategory returns every event of the streams in category, in append order
+	ReadCategory(category string) ([]*Event, error)
+}
+
+// ReadCategory returns every event of the streams in category, in append order. It
+// uses the store's native support when it is a CategoryReader, and otherwise scans
+// the global log for the streams namer puts in category.
+func ReadCategory(store Store, namer StreamNamer, category string) ([]*Event, error) {
+	if reader, ok := store.(CategoryReader); ok {
+		return reader.ReadCategory(category)
+	}
+	events := make([]*Event, 0)
+	for _, event := range store.GetAllEvents() {
+		if namer.Category(event.AggregateID) == category {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// CategoryStreamIDs returns the IDs of the streams namer puts in category, sorted
+func CategoryStreamIDs(store Store, namer StreamNamer, category string) []string {
+	ids := make([]string, 0)
+	for _, id := range store.StreamIDs() {
+		if namer.Category(id) == category {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
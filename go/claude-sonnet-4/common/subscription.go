@@ -0,0 +1,55 @@
+// Package common provides polling subscriptions for following event streams.
+package common
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultPollInterval is used by SubscribeStream when no interval is given
+const DefaultPollInterval = 500 * time.Millisecond
+
+// SubscribeStream follows a stream, delivering every event with a version greater than
+// fromVersion on the returned channel. The store is polled at the given interval so the
+// subscription works against any Store, including persistent backends written by other
+// processes. The channel is closed when ctx is cancelled.
+func SubscribeStream(ctx context.Context, store Store, streamID string, fromVersion int, interval time.Duration) <-chan *Event {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	out := make(chan *Event)
+	go func() {
+		defer close(out)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		last := fromVersion
+		for {
+			if store.GetStreamVersion(streamID) > last {
+				events, err := store.GetStream(streamID)
+				if err == nil {
+					for _, event := range events {
+						if event.Version <= last {
+							continue
+						}
+						select {
+						case out <- event:
+							last = event.Version
+						case <-ctx.Done():
+							return
+						}
+					}
+				}
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
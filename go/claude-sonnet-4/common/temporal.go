@@ -0,0 +1,55 @@
+// Package common provides bi-temporal queries over events. Every event has a record
+// time, CreatedAt, when the store learned of it, and a valid time, EffectiveTime,
+// when it took effect in the domain. Late-arriving facts such as backdated price
+// corrections have a valid time before their record time.
+package common
+
+import (
+	"sort"
+	"time"
+)
+
+// EffectiveTime returns when an event took effect: EffectiveAt when set, otherwise
+// CreatedAt
+func EffectiveTime(event *Event) time.Time {
+	if event.EffectiveAt == nil {
+		return event.CreatedAt
+	}
+	return *event.EffectiveAt
+}
+
+// AsOf returns the events that were in effect at validAt according to what had been
+// recorded by knownAt, ordered by effective time (ties keep their order in events).
+// A zero knownAt includes everything recorded so far, and a zero validAt everything
+// in effect so far.
+//
+// AsOf(events, t, time.Time{}) answers "what was true at t, as we know now", while
+// AsOf(events, t, t) answers "what did we believe at t".
+func AsOf(events []*Event, validAt, knownAt time.Time) []*Event {
+	selected := make([]*Event, 0, len(events))
+	for _, event := range events {
+		if !knownAt.IsZero() && event.CreatedAt.After(knownAt) {
+			continue
+		}
+		if !validAt.IsZero() && EffectiveTime(event).After(validAt) {
+			continue
+		}
+		selected = append(selected, event)
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		return EffectiveTime(selected[i]).Before(EffectiveTime(selected[j]))
+	})
+	return selected
+}
+
+// ReplayProjectionAsOf builds a projection from the events AsOf selects from the
+// global log, applying them in effective time order. The projection should be fresh;
+// temporal replays have no checkpoint to resume from.
+func ReplayProjectionAsOf(store Store, projection Projection, validAt, knownAt time.Time) error {
+	for _, event := range AsOf(store.GetAllEvents(), validAt, knownAt) {
+		if err := projection.On(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// Package common provides typed payload helpers layered over the registry. Stores
+// keep the generic Event envelope with its Data map, while aggregates and
+// projections decode the payload into a struct once instead of asserting the type of
+// every Data key:
+//
+//	added, err := common.AsTyped[ItemAddedData](event)
+//	quantities[added.Data.Item]++
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// TypedEvent is an event together with its payload decoded into T
+type TypedEvent[T any] struct {
+	Event *Event
+	Data  T
+}
+
+// PayloadTypeError reports an event payload that cannot be read as the requested type
+type PayloadTypeError struct {
+	EventType string
+	// Requested is the type the payload was decoded into
+	Requested string
+	// Registered is the payload type registered for the event type, if it differs
+	Registered string
+	Err        error
+}
+
+func (e *PayloadTypeError) Error() string {
+	if e.Registered != "" {
+		return fmt.Sprintf("%s payload is registered as %s, not %s", e.EventType, e.Registered, e.Requested)
+	}
+	return fmt.Sprintf("decoding %s payload as %s: %v", e.EventType, e.Requested, e.Err)
+}
+
+func (e *PayloadTypeError) Is(target error) bool { return target == ErrPayloadType }
+func (e *PayloadTypeError) Code() ErrorCode      { return CodePayloadType }
+
+func (e *PayloadTypeError) Unwrap() error {
+	return e.Err
+}
+
+// RegisterPayload records T as the payload type of an event type, so AsTyped can
+// reject decoding it as anything else. It panics if the event type already has one.
+func RegisterPayload[T any](r *Registry, eventType string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.payloadTypes[eventType]; exists {
+		panic(fmt.Sprintf("payload of event %q is already registered", eventType))
+	}
+	r.payloadTypes[eventType] = reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// PayloadType returns the payload type registered for an event type
+func (r *Registry) PayloadType(eventType string) (reflect.Type, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, exists := r.payloadTypes[eventType]
+	return t, exists
+}
+
+// AsTyped decodes an event's payload into T using DefaultRegistry. See AsTypedIn.
+func AsTyped[T any](event *Event) (*TypedEvent[T], error) {
+	return AsTypedIn[T](DefaultRegistry, event)
+}
+
+// AsTypedIn upcasts an event to the current schema of its type, then decodes its
+// payload into T through its JSON form, so numbers and nested values get T's field
+// types. A JSON Payload is decoded instead of Data when present. It returns a
+// *PayloadTypeError if a different payload type is registered for the event type,
+// or the payload does not decode into T.
+func AsTypedIn[T any](r *Registry, event *Event) (*TypedEvent[T], error) {
+	typed := &TypedEvent[T]{}
+	upcast, err := decodePayload(r, event, &typed.Data)
+	if err != nil {
+		return nil, err
+	}
+	typed.Event = upcast
+	return typed, nil
+}
+
+// decodePayload upcasts event and decodes its payload into target, a pointer, as
+// AsTypedIn does. It returns the upcast event.
+func decodePayload(r *Registry, event *Event, target interface{}) (*Event, error) {
+	requested := reflect.TypeOf(target).Elem()
+	if registered, exists := r.PayloadType(event.Type); exists && registered != requested {
+		return nil, &PayloadTypeError{EventType: event.Type, Requested: requested.String(), Registered: registered.String()}
+	}
+
+	upcast, err := r.Upcast(event)
+	if err != nil {
+		return nil, err
+	}
+
+	data := upcast.Payload
+	switch {
+	case data != nil && upcast.ContentType != "application/json":
+		return nil, &PayloadTypeError{EventType: event.Type, Requested: requested.String(), Err: fmt.Errorf("payload has content type %q", upcast.ContentType)}
+	case data == nil:
+		if data, err = json.Marshal(upcast.Data); err != nil {
+			return nil, &PayloadTypeError{EventType: event.Type, Requested: requested.String(), Err: err}
+		}
+	}
+
+	if err := json.Unmarshal(data, target); err != nil {
+		return nil, &PayloadTypeError{EventType: event.Type, Requested: requested.String(), Err: err}
+	}
+	return upcast, nil
+}
+
+// NewTypedEvent creates an event whose Data holds the JSON fields of data
+func NewTypedEvent[T any](eventType, aggregateID string, version int, data T, metadata map[string]interface{}) (*Event, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, fmt.Errorf("%s payload must encode as a JSON object: %w", eventType, err)
+	}
+	return NewEvent(eventType, aggregateID, version, fields, metadata), nil
+}
@@ -0,0 +1,132 @@
+// Package common provides event ID generation. Event IDs default to random UUIDs;
+// ULIDs can be selected instead so IDs sort lexicographically by creation time,
+// which orders events in backends without a native global sequence.
+package common
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IDGenerator creates unique event IDs
+type IDGenerator func() string
+
+var idGenerator atomic.Value
+
+func init() {
+	idGenerator.Store(IDGenerator(NewUUID))
+}
+
+// SetIDGenerator selects the generator NewEvent uses for event IDs, e.g. NewULID.
+// It is meant to be called once at startup.
+func SetIDGenerator(generator IDGenerator) {
+	idGenerator.Store(generator)
+}
+
+// NewEventID returns an ID from the selected generator
+func NewEventID() string {
+	return idGenerator.Load().(IDGenerator)()
+}
+
+// NewUUID returns a random UUID, the default event ID
+func NewUUID() string {
+	return uuid.New().String()
+}
+
+// crockford is the ULID alphabet, Crockford's base32 without I, L, O, and U
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var ulids struct {
+	sync.Mutex
+	ms      uint64
+	entropy [10]byte
+}
+
+// NewULID returns a ULID: a 26 character string holding a millisecond timestamp
+// followed by 80 random bits. ULIDs generated in the same millisecond increment the
+// random part, so IDs from one process are strictly increasing.
+func NewULID() string {
+	ulids.Lock()
+	defer ulids.Unlock()
+
+	ms := uint64(time.Now().UnixMilli())
+	if ms > ulids.ms {
+		ulids.ms = ms
+		if _, err := rand.Read(ulids.entropy[:]); err != nil {
+			panic(err)
+		}
+	} else if !increment(ulids.entropy[:]) {
+		// The random part overflowed; borrow the next millisecond
+		ulids.ms++
+	}
+
+	var raw [16]byte
+	binary.BigEndian.PutUint64(raw[:8], ulids.ms<<16)
+	copy(raw[6:], ulids.entropy[:])
+	return encodeULID(raw)
+}
+
+// ULIDTime returns the creation time encoded in a ULID, or false if id is not a ULID
+func ULIDTime(id string) (time.Time, bool) {
+	if len(id) != 26 || id[0] > '7' {
+		return time.Time{}, false
+	}
+	var ms uint64
+	for i, c := range id {
+		digit := strings.IndexRune(crockford, c)
+		if digit < 0 {
+			return time.Time{}, false
+		}
+		if i < 10 {
+			ms = ms<<5 | uint64(digit)
+		}
+	}
+	return time.UnixMilli(int64(ms)), true
+}
+
+// SortEvents orders events by creation time, breaking ties by ID. Backends without a
+// native global sequence use it to merge streams into one log; with ULID event IDs
+// the order of events created in the same instant follows their creation too.
+func SortEvents(events []*Event) {
+	sort.SliceStable(events, func(i, j int) bool { return EventBefore(events[i], events[j]) })
+}
+
+// EventBefore reports whether a sorts before b in the order used by SortEvents
+func EventBefore(a, b *Event) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID < b.ID
+}
+
+// increment adds one to a big-endian number, reporting false on overflow
+func increment(b []byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeULID encodes 128 bits as 26 base32 characters, the first carrying 3 bits
+func encodeULID(raw [16]byte) string {
+	hi := binary.BigEndian.Uint64(raw[:8])
+	lo := binary.BigEndian.Uint64(raw[8:])
+
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
@@ -0,0 +1,209 @@
+// Package common provides the UnitOfWork grouping the appends of one logical
+// operation, and inline projections updated synchronously when it commits.
+package common
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// UnitOfWork is a Store that buffers appends until Commit. Aggregates built on a
+// unit of work see their own pending events, while the underlying store and every
+// reader of it see none of them until Commit stores them together. When the
+// underlying store is a BatchAppender the commit is atomic; otherwise the events are
+// appended one by one and a failure may leave the first ones stored.
+//
+// A unit of work belongs to a single operation and is not safe for concurrent use.
+type UnitOfWork struct {
+	store   Store
+	inline  *InlineProjections
+	pending []*Event
+}
+
+var _ Store = (*UnitOfWork)(nil)
+
+// NewUnitOfWork starts a unit of work over store. Committed events are applied to
+// the inline projections, which may be nil.
+func NewUnitOfWork(store Store, inline *InlineProjections) *UnitOfWork {
+	return &UnitOfWork{store: store, inline: inline}
+}
+
+// Append buffers an event. Its version must directly follow the stream's version,
+// including events already pending, otherwise a *ConcurrencyError is returned.
+func (u *UnitOfWork) Append(event *Event) error {
+	if err := CheckVersion(event, u.GetStreamVersion(event.AggregateID)); err != nil {
+		return err
+	}
+	u.pending = append(u.pending, event)
+	return nil
+}
+
+// Pending returns the events that Commit will store
+func (u *UnitOfWork) Pending() []*Event {
+	return u.pending
+}
+
+// Commit stores the pending events and then applies them to the inline projections
+// while readers of the projections wait, so a read that starts after Commit returns
+// observes the events. If a projection fails the events stay stored and the error
+// is returned; rebuild the projection to recover.
+func (u *UnitOfWork) Commit() error {
+	if len(u.pending) == 0 {
+		return nil
+	}
+	events := u.pending
+
+	apply := func() error {
+		if batch, ok := u.store.(BatchAppender); ok {
+			return batch.AppendBatch(events)
+		}
+		for _, event := range events {
+			if err := u.store.Append(event); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var err error
+	if u.inline != nil {
+		err = u.inline.commit(apply, events)
+	} else {
+		err = apply()
+	}
+	u.pending = nil
+	return err
+}
+
+// Rollback discards the pending events
+func (u *UnitOfWork) Rollback() {
+	u.pending = nil
+}
+
+// GetStream returns the stored events of a stream followed by its pending ones
+func (u *UnitOfWork) GetStream(aggregateID string) ([]*Event, error) {
+	stream, err := u.store.GetStream(aggregateID)
+	pending := u.pendingIn(aggregateID)
+	if err != nil && len(pending) == 0 {
+		return nil, err
+	}
+	return append(append(make([]*Event, 0, len(stream)+len(pending)), stream...), pending...), nil
+}
+
+// GetStreamVersion returns the version of a stream including pending events
+func (u *UnitOfWork) GetStreamVersion(aggregateID string) int {
+	if pending := u.pendingIn(aggregateID); len(pending) > 0 {
+		return pending[len(pending)-1].Version
+	}
+	return u.store.GetStreamVersion(aggregateID)
+}
+
+// StreamLength returns the number of stored and pending events of a stream
+func (u *UnitOfWork) StreamLength(aggregateID string) int {
+	return u.store.StreamLength(aggregateID) + len(u.pendingIn(aggregateID))
+}
+
+// HeadEvent returns the latest pending event of a stream, or its latest stored one
+func (u *UnitOfWork) HeadEvent(aggregateID string) (*Event, error) {
+	if pending := u.pendingIn(aggregateID); len(pending) > 0 {
+		return pending[len(pending)-1], nil
+	}
+	return u.store.HeadEvent(aggregateID)
+}
+
+// GetAllEvents returns the stored events followed by the pending ones
+func (u *UnitOfWork) GetAllEvents() []*Event {
+	stored := u.store.GetAllEvents()
+	return append(append(make([]*Event, 0, len(stored)+len(u.pending)), stored...), u.pending...)
+}
+
+// StreamIDs returns the stored and pending streams, sorted
+func (u *UnitOfWork) StreamIDs() []string {
+	ids := u.store.StreamIDs()
+	for _, event := range u.pending {
+		if !slices.Contains(ids, event.AggregateID) {
+			ids = append(ids, event.AggregateID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (u *UnitOfWork) pendingIn(aggregateID string) []*Event {
+	var events []*Event
+	for _, event := range u.pending {
+		if event.AggregateID == aggregateID {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// InlineProjections are read models kept strongly consistent with the write model:
+// units of work apply their events to them as part of Commit instead of leaving them
+// to a subscription that catches up later. Reads go through View, which waits for
+// a commit in progress.
+type InlineProjections struct {
+	mu          sync.RWMutex
+	projections map[string]Projection
+}
+
+// NewInlineProjections creates a set of inline projections
+func NewInlineProjections(projections ...Projection) *InlineProjections {
+	p := &InlineProjections{projections: make(map[string]Projection)}
+	for _, projection := range projections {
+		p.Register(projection)
+	}
+	return p
+}
+
+// Register adds a projection. It panics if one is already registered under its name.
+func (p *InlineProjections) Register(projection Projection) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, exists := p.projections[projection.Name()]; exists {
+		panic(fmt.Sprintf("inline projection %q is already registered", projection.Name()))
+	}
+	p.projections[projection.Name()] = projection
+}
+
+// View calls read with the named projection while no commit is in progress. It
+// reports false if no projection is registered under the name.
+func (p *InlineProjections) View(name string, read func(Projection)) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	projection, exists := p.projections[name]
+	if !exists {
+		return false
+	}
+	read(projection)
+	return true
+}
+
+// commit runs store and, if it succeeds, applies events to every projection,
+// holding off readers throughout
+func (p *InlineProjections) commit(store func() error, events []*Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := store(); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(p.projections))
+	for name := range p.projections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, event := range events {
+			if err := p.projections[name].On(event); err != nil {
+				return fmt.Errorf("updating inline projection %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
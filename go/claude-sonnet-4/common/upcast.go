@@ -0,0 +1,144 @@
+// Package common provides the upcaster pipeline that migrates stored events to the
+// current schema of their event type when they are read.
+package common
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SchemaVersionKey is the metadata key holding the schema version of an event's
+// payload. Events without it are at schema version 1.
+const SchemaVersionKey = "schema_version"
+
+// Upcaster migrates events of one type from schema version FromVersion to
+// FromVersion+1. Upcast receives a copy of the event it may modify and return;
+// the pipeline records the new schema version in its metadata.
+type Upcaster struct {
+	EventType   string
+	FromVersion int
+	Upcast      func(event *Event) (*Event, error)
+}
+
+type upcasterKey struct {
+	eventType   string
+	fromVersion int
+}
+
+// MissingUpcasterError reports an event whose schema is older than the current
+// schema of its type with no upcaster registered for the next step
+type MissingUpcasterError struct {
+	EventType     string
+	SchemaVersion int
+	Current       int
+}
+
+func (e *MissingUpcasterError) Error() string {
+	return fmt.Sprintf("no upcaster for %s from schema version %d (current %d)", e.EventType, e.SchemaVersion, e.Current)
+}
+
+func (e *MissingUpcasterError) Is(target error) bool { return target == ErrMissingUpcaster }
+func (e *MissingUpcasterError) Code() ErrorCode      { return CodeMissingUpcaster }
+
+// SchemaVersion returns the schema version recorded in an event's metadata, or 1
+func SchemaVersion(event *Event) int {
+	switch v := event.Metadata[SchemaVersionKey].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	}
+	return 1
+}
+
+// RegisterUpcaster adds an upcaster to the pipeline.
+// It panics if the step is already covered, as that indicates a programming error.
+func (r *Registry) RegisterUpcaster(upcaster Upcaster) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := upcasterKey{upcaster.EventType, upcaster.FromVersion}
+	if _, exists := r.upcasters[key]; exists {
+		panic(fmt.Sprintf("upcaster for %s from schema version %d is already registered", upcaster.EventType, upcaster.FromVersion))
+	}
+	r.upcasters[key] = upcaster
+}
+
+// Upcasters returns the registered upcasters, sorted by event type and schema version
+func (r *Registry) Upcasters() []Upcaster {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	upcasters := make([]Upcaster, 0, len(r.upcasters))
+	for _, upcaster := range r.upcasters {
+		upcasters = append(upcasters, upcaster)
+	}
+	sort.Slice(upcasters, func(i, j int) bool {
+		if upcasters[i].EventType != upcasters[j].EventType {
+			return upcasters[i].EventType < upcasters[j].EventType
+		}
+		return upcasters[i].FromVersion < upcasters[j].FromVersion
+	})
+	return upcasters
+}
+
+// CurrentSchemaVersion returns the schema version events of a type are upcast to:
+// the registered EventInfo.SchemaVersion, or the version the registered upcasters
+// reach when the event type does not declare one
+func (r *Registry) CurrentSchemaVersion(eventType string) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	current := 1
+	if info, exists := r.events[eventType]; exists && info.SchemaVersion > 0 {
+		return info.SchemaVersion
+	}
+	for key := range r.upcasters {
+		if key.eventType == eventType && key.fromVersion+1 > current {
+			current = key.fromVersion + 1
+		}
+	}
+	return current
+}
+
+// Upcast runs an event through the upcasters for its type until it reaches the
+// current schema version. The event is returned as is when it is already current;
+// otherwise a migrated copy is returned and the stored event is left untouched.
+// A gap in the pipeline fails with *MissingUpcasterError.
+func (r *Registry) Upcast(event *Event) (*Event, error) {
+	current := r.CurrentSchemaVersion(event.Type)
+	for version := SchemaVersion(event); version < current; version++ {
+		r.mu.RLock()
+		upcaster, exists := r.upcasters[upcasterKey{event.Type, version}]
+		r.mu.RUnlock()
+		if !exists {
+			return nil, &MissingUpcasterError{EventType: event.Type, SchemaVersion: version, Current: current}
+		}
+
+		upcast, err := upcaster.Upcast(copyEvent(event))
+		if err != nil {
+			return nil, fmt.Errorf("upcasting %s %s from schema version %d: %w", event.Type, event.ID, version, err)
+		}
+		if upcast.Metadata == nil {
+			upcast.Metadata = make(map[string]interface{})
+		}
+		upcast.Metadata[SchemaVersionKey] = version + 1
+		event = upcast
+	}
+	return event, nil
+}
+
+// copyEvent returns a copy of event whose top-level data and metadata maps can be
+// modified without affecting the original
+func copyEvent(event *Event) *Event {
+	copied := *event
+	copied.Data = make(map[string]interface{}, len(event.Data))
+	for key, value := range event.Data {
+		copied.Data[key] = value
+	}
+	copied.Metadata = make(map[string]interface{}, len(event.Metadata))
+	for key, value := range event.Metadata {
+		copied.Metadata[key] = value
+	}
+	return &copied
+}
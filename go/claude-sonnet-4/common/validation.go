@@ -0,0 +1,226 @@
+// Package common provides declarative validation for commands.
+//
+// Fields are validated from a `validate` struct tag holding comma-separated rules:
+//   - required: the field must not be the zero value
+//   - omitempty: skip the remaining rules when the field is the zero value
+//   - uuid: the string must be a UUID
+//   - streamid: the string must be a UUID, bare or as named by CategoryStreamNamer
+//     ("<category>-<uuid>")
+//   - positive: the number must be greater than zero
+//
+// Nested structs and slices are validated recursively. Types may also implement
+// Validator for rules that a tag cannot express. Tags naming other rules are
+// reported by CheckTags, which command registration calls so typos surface at
+// startup rather than when a command is dispatched.
+package common
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Validator is implemented by values with validation rules beyond struct tags
+type Validator interface {
+	Validate() error
+}
+
+// FieldError describes a single invalid field
+type FieldError struct {
+	// Field is the JSON path of the field, e.g. "lines[0].quantity"
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every invalid field of a value
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Field + ": " + field.Message
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool { return target == ErrValidation }
+func (e *ValidationError) Code() ErrorCode      { return CodeValidation }
+
+// knownRules are the rules a validate tag may hold
+var knownRules = map[string]bool{"required": true, "omitempty": true, "uuid": true, "streamid": true, "positive": true}
+
+// checkedTypes caches the result of CheckTags by type
+var checkedTypes sync.Map // reflect.Type -> error
+
+// CheckTags reports the first validate tag of v's type, or of the structs it nests,
+// that names an unknown rule
+func CheckTags(v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	t := reflect.TypeOf(v)
+	if err, checked := checkedTypes.Load(t); checked {
+		err, _ := err.(error)
+		return err
+	}
+	err := checkTypeTags(t, make(map[reflect.Type]bool))
+	checkedTypes.Store(t, err)
+	return err
+}
+
+func checkTypeTags(t reflect.Type, seen map[reflect.Type]bool) error {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return nil
+	}
+	seen[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		if tag := field.Tag.Get("validate"); tag != "" {
+			for _, rule := range strings.Split(tag, ",") {
+				if !knownRules[strings.TrimSpace(rule)] {
+					return fmt.Errorf("%s.%s: unknown validation rule %q", t.Name(), field.Name, rule)
+				}
+			}
+		}
+		if err := checkTypeTags(field.Type, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate checks the `validate` tags of v, then calls v.Validate when v is a Validator.
+// It returns a *ValidationError listing every failing field, or the error returned by
+// Validate, or nil when v is valid. A tag naming an unknown rule fails with the error
+// of CheckTags.
+func Validate(v interface{}) error {
+	if err := CheckTags(v); err != nil {
+		return err
+	}
+	var fields []FieldError
+	validateValue(reflect.ValueOf(v), "", &fields)
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	if validator, ok := v.(Validator); ok {
+		return validator.Validate()
+	}
+	return nil
+}
+
+func validateValue(value reflect.Value, path string, fields *[]FieldError) {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		t := value.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			fieldPath := joinPath(path, fieldName(field))
+			if msg := checkRules(value.Field(i), field.Tag.Get("validate")); msg != "" {
+				*fields = append(*fields, FieldError{Field: fieldPath, Message: msg})
+				continue
+			}
+			validateValue(value.Field(i), fieldPath, fields)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			validateValue(value.Index(i), fmt.Sprintf("%s[%d]", path, i), fields)
+		}
+	}
+}
+
+// checkRules applies the rules of a validate tag and returns the first failure message
+func checkRules(value reflect.Value, tag string) string {
+	if tag == "" {
+		return ""
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		switch strings.TrimSpace(rule) {
+		case "required":
+			if value.IsZero() {
+				return "is required"
+			}
+		case "omitempty":
+			if value.IsZero() {
+				return ""
+			}
+		case "uuid":
+			if value.Kind() == reflect.String {
+				if _, err := uuid.Parse(value.String()); err != nil {
+					return "must be a UUID"
+				}
+			}
+		case "streamid":
+			if value.Kind() == reflect.String && !isStreamID(value.String()) {
+				return "must be a UUID or a stream ID of the form <category>-<uuid>"
+			}
+		case "positive":
+			if !isPositive(value) {
+				return "must be positive"
+			}
+		}
+	}
+	return ""
+}
+
+// isStreamID reports whether id is a UUID, or a category and a UUID joined by a dash.
+// The UUID is taken from the end, as categories such as gift-card may contain dashes.
+func isStreamID(id string) bool {
+	if _, err := uuid.Parse(id); err == nil {
+		return true
+	}
+	const uuidLen = 36
+	if len(id) < uuidLen+2 || id[len(id)-uuidLen-1] != '-' {
+		return false
+	}
+	_, err := uuid.Parse(id[len(id)-uuidLen:])
+	return err == nil
+}
+
+func isPositive(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int() > 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return value.Uint() > 0
+	case reflect.Float32, reflect.Float64:
+		return value.Float() > 0
+	}
+	return false
+}
+
+// fieldName returns the JSON name of a struct field
+func fieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,238 @@
+// Package common provides windowed projections, which fold events into tumbling or
+// sliding windows of event time. Windows are assigned from each event's CreatedAt,
+// never from the time the projection happens to process it, so replaying the global
+// log into a fresh projection rebuilds exactly the windows a live projection built.
+package common
+
+import (
+	"sort"
+	"time"
+)
+
+// Window is the half-open interval of event time [Start, End)
+type Window struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Contains reports whether t falls in the window
+func (w Window) Contains(t time.Time) bool {
+	return !t.Before(w.Start) && t.Before(w.End)
+}
+
+// WindowSpec describes how event time is cut into windows. Windows are Size long and
+// a new one starts every Slide, aligned to the Unix epoch so every projection using
+// the same spec agrees on the boundaries. Slide equal to Size gives tumbling windows,
+// where every event falls in exactly one window; a shorter Slide gives overlapping
+// sliding windows.
+type WindowSpec struct {
+	Size  time.Duration
+	Slide time.Duration
+}
+
+// TumblingWindows returns the spec of consecutive, non-overlapping windows of size
+func TumblingWindows(size time.Duration) WindowSpec {
+	return WindowSpec{Size: size, Slide: size}
+}
+
+// SlidingWindows returns the spec of windows of size starting every slide
+func SlidingWindows(size, slide time.Duration) WindowSpec {
+	return WindowSpec{Size: size, Slide: slide}
+}
+
+// Assign returns the windows containing t, earliest first
+func (s WindowSpec) Assign(t time.Time) []Window {
+	if s.Size <= 0 {
+		return nil
+	}
+	slide := s.Slide
+	if slide <= 0 || slide > s.Size {
+		slide = s.Size
+	}
+	ns := t.UnixNano()
+	last := ns - ns%int64(slide)
+	if ns < 0 && ns%int64(slide) != 0 {
+		last -= int64(slide)
+	}
+
+	windows := make([]Window, 0, int(s.Size/slide))
+	for start := last; start+int64(s.Size) > ns; start -= int64(slide) {
+		begin := time.Unix(0, start).UTC()
+		windows = append(windows, Window{Start: begin, End: begin.Add(s.Size)})
+	}
+	for i, j := 0, len(windows)-1; i < j; i, j = i+1, j-1 {
+		windows[i], windows[j] = windows[j], windows[i]
+	}
+	return windows
+}
+
+// WindowFold folds an event into the state of one window; state starts as the zero
+// value of S
+type WindowFold[S any] func(state S, event *Event) S
+
+// WindowResult is the state a windowed projection folded for one window
+type WindowResult[S any] struct {
+	Window
+	State S `json:"state"`
+	// Events is the number of events folded into the window
+	Events int `json:"events"`
+}
+
+// WindowedProjection folds the events it consumes into the windows of a WindowSpec.
+//
+// Everything it keeps is derived from the events alone, so a rebuild and a live
+// projection agree:
+//   - events are assigned to windows by CreatedAt, never by the wall clock;
+//   - the watermark is the latest CreatedAt seen, not the current time;
+//   - with a retention set, windows ending more than the retention before the
+//     watermark are dropped, and events arriving for them are counted as Late
+//     instead of reopening them. Given the global log's order, the same events are
+//     late in a replay as were late live.
+//
+// Whether a window is complete depends on the time it is asked about, so Closed
+// takes that time as an argument instead of reading the clock: a live caller passes
+// time.Now, a report over history passes the watermark.
+type WindowedProjection[S any] struct {
+	name      string
+	consumes  []string
+	spec      WindowSpec
+	fold      WindowFold[S]
+	retention time.Duration
+	windows   map[int64]*WindowResult[S] // window start in Unix nanoseconds -> result
+	watermark time.Time
+	late      int
+}
+
+// NewWindowedProjection creates an empty windowed projection folding the consumed
+// event types into the windows of spec
+func NewWindowedProjection[S any](name string, consumes []string, spec WindowSpec, fold WindowFold[S]) *WindowedProjection[S] {
+	return &WindowedProjection[S]{
+		name:     name,
+		consumes: consumes,
+		spec:     spec,
+		fold:     fold,
+		windows:  make(map[int64]*WindowResult[S]),
+	}
+}
+
+// SetRetention bounds how long windows are kept behind the watermark; 0, the
+// default, keeps every window. Set it before the first event is applied.
+func (p *WindowedProjection[S]) SetRetention(retention time.Duration) {
+	p.retention = retention
+}
+
+// Name returns the registered name of the projection
+func (p *WindowedProjection[S]) Name() string {
+	return p.name
+}
+
+// Consumes returns the event types the projection folds
+func (p *WindowedProjection[S]) Consumes() []string {
+	return p.consumes
+}
+
+// Spec returns the windows the projection folds into
+func (p *WindowedProjection[S]) Spec() WindowSpec {
+	return p.spec
+}
+
+// On folds a consumed event into every window containing its CreatedAt; other
+// events are ignored
+func (p *WindowedProjection[S]) On(event *Event) error {
+	if !p.consumed(event.Type) {
+		return nil
+	}
+	if event.CreatedAt.After(p.watermark) {
+		p.watermark = event.CreatedAt
+	}
+
+	horizon := p.horizon()
+	folded := false
+	for _, window := range p.spec.Assign(event.CreatedAt) {
+		if !horizon.IsZero() && !window.End.After(horizon) {
+			continue
+		}
+		key := window.Start.UnixNano()
+		result, exists := p.windows[key]
+		if !exists {
+			result = &WindowResult[S]{Window: window}
+			p.windows[key] = result
+		}
+		result.State = p.fold(result.State, event)
+		result.Events++
+		folded = true
+	}
+	if !folded {
+		p.late++
+	}
+	p.prune(horizon)
+	return nil
+}
+
+// Watermark returns the latest CreatedAt of the events folded so far
+func (p *WindowedProjection[S]) Watermark() time.Time {
+	return p.watermark
+}
+
+// Late returns how many events arrived after every window they belong to had been
+// dropped by the retention
+func (p *WindowedProjection[S]) Late() int {
+	return p.late
+}
+
+// Windows returns every window holding events, earliest first
+func (p *WindowedProjection[S]) Windows() []WindowResult[S] {
+	results := make([]WindowResult[S], 0, len(p.windows))
+	for _, result := range p.windows {
+		results = append(results, *result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Start.Before(results[j].Start)
+	})
+	return results
+}
+
+// Closed returns the windows that ended at or before now, earliest first
+func (p *WindowedProjection[S]) Closed(now time.Time) []WindowResult[S] {
+	closed := make([]WindowResult[S], 0, len(p.windows))
+	for _, result := range p.Windows() {
+		if !result.End.After(now) {
+			closed = append(closed, result)
+		}
+	}
+	return closed
+}
+
+// State returns every window holding events, earliest first
+func (p *WindowedProjection[S]) State() interface{} {
+	return p.Windows()
+}
+
+func (p *WindowedProjection[S]) consumed(eventType string) bool {
+	for _, consumed := range p.consumes {
+		if consumed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// horizon returns the end time at or before which windows are dropped, or zero when
+// windows are kept forever
+func (p *WindowedProjection[S]) horizon() time.Time {
+	if p.retention <= 0 || p.watermark.IsZero() {
+		return time.Time{}
+	}
+	return p.watermark.Add(-p.retention)
+}
+
+func (p *WindowedProjection[S]) prune(horizon time.Time) {
+	if horizon.IsZero() {
+		return
+	}
+	for key, result := range p.windows {
+		if !result.End.After(horizon) {
+			delete(p.windows, key)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Package compaction keeps the stores of long-lived aggregates bounded. Once a
+// stream has a durable snapshot at version N, its events up to N are no longer
+// needed to hydrate the aggregate (see common.BaseAggregate.HydrateFromSnapshot),
+// so Compact removes them from the store, or moves them to an archive first when
+// the policy has one.
+//
+// Compaction rewrites history as the store sees it: projections rebuilt from the
+// global log, and aggregates hydrated without their snapshot, no longer see the
+// removed events. Archive them when either still matters; archive.Store reads
+// through to archived events.
+package compaction
+
+import (
+	"errors"
+
+	"simple-event-modeling/archive"
+	"simple-event-modeling/common"
+)
+
+// Archiver moves the events of a stream below beforeVersion out of the store it
+// wraps, always keeping the latest one, such as *archive.Store
+type Archiver interface {
+	ArchiveStream(aggregateID string, beforeVersion int) (int, error)
+}
+
+var _ Archiver = (*archive.Store)(nil)
+
+// Policy controls what Compact removes
+type Policy struct {
+	// Retain keeps this many events at and below the snapshot version, e.g. to
+	// hydrate from an older snapshot if the latest turns out to be bad
+	Retain int
+	// Archive receives the events before they leave the store; nil deletes them
+	Archive Archiver
+	// Streams selects the streams to compact; nil compacts every snapshotted stream
+	Streams func(aggregateID string) bool
+}
+
+// Result summarizes a compaction run
+type Result struct {
+	Streams int
+	Events  int
+}
+
+// Compact removes the events of every stream in store that are covered by its
+// latest snapshot in snapshots, keeping Policy.Retain of them. The latest event of a
+// stream always stays, so the stream keeps its version. With Policy.Archive set,
+// store must be the hot store the archiver wraps.
+func Compact(store archive.HotStore, snapshots common.SnapshotStore, policy Policy) (Result, error) {
+	var result Result
+	for _, id := range store.StreamIDs() {
+		if common.IsSnapshotStream(id) || (policy.Streams != nil && !policy.Streams(id)) {
+			continue
+		}
+		snapshot, found, err := snapshots.LoadSnapshot(id)
+		if err != nil {
+			return result, err
+		}
+		// A snapshot ahead of the stream belongs to a history the store no longer has
+		if !found || snapshot.Version > store.GetStreamVersion(id) {
+			continue
+		}
+		before := snapshot.Version + 1 - max(policy.Retain, 0)
+
+		removed, err := compactStream(store, id, before, policy.Archive)
+		if err != nil {
+			return result, err
+		}
+		if removed > 0 {
+			result.Streams++
+			result.Events += removed
+		}
+	}
+	return result, nil
+}
+
+// compactStream removes the events of a stream below beforeVersion
+func compactStream(store archive.HotStore, id string, beforeVersion int, archiver Archiver) (int, error) {
+	if archiver != nil {
+		return archiver.ArchiveStream(id, beforeVersion)
+	}
+
+	stream, err := store.GetStream(id)
+	if errors.Is(err, common.ErrStreamNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for n < len(stream)-1 && stream[n].Version < beforeVersion {
+		n++
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	return n, store.TruncateStream(id, beforeVersion)
+}
@@ -0,0 +1,138 @@
+package compaction
+
+import (
+	"path/filepath"
+	"testing"
+
+	"simple-event-modeling/archive"
+	"simple-event-modeling/boltstore"
+	"simple-event-modeling/cart"
+	"simple-event-modeling/common"
+)
+
+// seedCart appends a cart with the given items and snapshots it
+func seedCart(t *testing.T, store common.Store, snapshots common.SnapshotStore, id string, items ...string) {
+	t.Helper()
+	store.Append(cart.NewCartCreatedEvent(id))
+	for i, item := range items {
+		store.Append(cart.NewItemAddedEvent(id, i+2, item))
+	}
+	aggregate := cart.NewCartAggregate(store)
+	if err := aggregate.Hydrate(id); err != nil {
+		t.Fatalf("Error hydrating cart: %v", err)
+	}
+	if _, err := common.TakeSnapshot(snapshots, aggregate); err != nil {
+		t.Fatalf("Error taking snapshot: %v", err)
+	}
+}
+
+func TestCompact_HydratesFromSnapshotAfterwards(t *testing.T) {
+	store := common.NewEventStore()
+	snapshots := common.NewStoreSnapshots(common.NewEventStore())
+	seedCart(t, store, snapshots, "cart-1", "apple", "pear")
+	store.Append(cart.NewItemAddedEvent("cart-1", 4, "plum"))
+	store.Append(cart.NewCartCreatedEvent("cart-2"))
+
+	result, err := Compact(store, snapshots, Policy{})
+	if err != nil {
+		t.Fatalf("Error compacting: %v", err)
+	}
+	if result.Streams != 1 || result.Events != 3 {
+		t.Errorf("Expected 3 events of 1 stream compacted, got %+v", result)
+	}
+	if stream, _ := store.GetStream("cart-1"); len(stream) != 1 || stream[0].Version != 4 {
+		t.Errorf("Expected only the event after the snapshot to remain, got %v", stream)
+	}
+
+	aggregate := cart.NewSnapshottingCartAggregate(store, snapshots)
+	if err := aggregate.Hydrate("cart-1"); err != nil {
+		t.Fatalf("Error hydrating from snapshot: %v", err)
+	}
+	items := aggregate.Items()
+	if aggregate.Version() != 4 || items["apple"] != 1 || items["pear"] != 1 || items["plum"] != 1 {
+		t.Errorf("Expected snapshot plus later events, got version %d, items %v", aggregate.Version(), items)
+	}
+	if _, err := aggregate.Handle(&cart.RemoveItemCommand{CartID: "cart-1", ItemID: "apple"}); err != nil {
+		t.Errorf("Expected commands to work on a compacted cart, got %v", err)
+	}
+
+	if err := cart.NewCartAggregate(store).Hydrate("cart-1"); err == nil {
+		t.Error("Expected hydration without the snapshot to fail on a compacted stream")
+	}
+}
+
+func TestCompact_RetainsAndSelects(t *testing.T) {
+	store := common.NewEventStore()
+	snapshots := common.NewStoreSnapshots(common.NewEventStore())
+	seedCart(t, store, snapshots, "cart-1", "a", "b", "c", "d")
+	seedCart(t, store, snapshots, "cart-2", "a", "b")
+
+	result, err := Compact(store, snapshots, Policy{
+		Retain:  2,
+		Streams: func(id string) bool { return id == "cart-1" },
+	})
+	if err != nil {
+		t.Fatalf("Error compacting: %v", err)
+	}
+	if result.Events != 3 {
+		t.Errorf("Expected versions 1-3 compacted, got %+v", result)
+	}
+	if stream, _ := store.GetStream("cart-1"); len(stream) != 2 || stream[0].Version != 4 {
+		t.Errorf("Expected 2 events retained, got %v", stream)
+	}
+	if stream, _ := store.GetStream("cart-2"); len(stream) != 3 {
+		t.Errorf("Expected unselected stream untouched, got %v", stream)
+	}
+}
+
+func TestCompact_ArchivesInsteadOfDeleting(t *testing.T) {
+	hot := common.NewEventStore()
+	snapshots := common.NewStoreSnapshots(common.NewEventStore())
+	seedCart(t, hot, snapshots, "cart-1", "apple", "pear")
+	archived := archive.New(hot, archive.Dir(t.TempDir()), "")
+
+	result, err := Compact(hot, snapshots, Policy{Archive: archived})
+	if err != nil || result.Events != 2 {
+		t.Fatalf("Expected 2 events archived, got %+v, %v", result, err)
+	}
+	if stream, _ := hot.GetStream("cart-1"); len(stream) != 1 {
+		t.Errorf("Expected 1 event hot, got %v", stream)
+	}
+	if stream, _ := archived.GetStream("cart-1"); len(stream) != 3 {
+		t.Errorf("Expected the archive to read through to all 3 events, got %v", stream)
+	}
+}
+
+func TestCompact_BoltStore(t *testing.T) {
+	store, err := boltstore.Open(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+	defer store.Close()
+	snapshots := common.NewStoreSnapshots(store)
+	seedCart(t, store, snapshots, "cart-1", "apple", "pear")
+
+	result, err := Compact(store, snapshots, Policy{})
+	if err != nil || result.Streams != 1 {
+		t.Fatalf("Expected the cart compacted and its snapshot stream skipped, got %+v, %v", result, err)
+	}
+
+	aggregate := cart.NewSnapshottingCartAggregate(store, snapshots)
+	if err := aggregate.Hydrate("cart-1"); err != nil {
+		t.Fatalf("Error hydrating from snapshot: %v", err)
+	}
+	if items := aggregate.Items(); aggregate.Version() != 3 || items["apple"] != 1 || items["pear"] != 1 {
+		t.Errorf("Expected the snapshot restored, got version %d, items %v", aggregate.Version(), items)
+	}
+}
+
+func TestCompact_SkipsStreamsWithoutSnapshot(t *testing.T) {
+	store := common.NewEventStore()
+	store.Append(cart.NewCartCreatedEvent("cart-1"))
+	store.Append(cart.NewItemAddedEvent("cart-1", 2, "apple"))
+
+	result, err := Compact(store, common.NewStoreSnapshots(common.NewEventStore()), Policy{})
+	if err != nil || result.Events != 0 {
+		t.Errorf("Expected nothing compacted, got %+v, %v", result, err)
+	}
+}
@@ -0,0 +1,132 @@
+// Package gpt41 adapts the canonical cart to the API of the deprecated gpt41 port
+// (module simpleeventmodeling). It collects the eventstore, command and query
+// packages of that port into one package; commands other than CreateCart are routed
+// to the canonical CartAggregate so business rules match the canonical cart.
+//
+// DeleteStream is not offered: the canonical store is append-only.
+package gpt41
+
+import (
+	"errors"
+
+	canonical "simple-event-modeling/cart"
+	"simple-event-modeling/common"
+)
+
+// EventStore exposes the gpt41 store API over a canonical store
+type EventStore struct {
+	store *common.EventStore
+}
+
+// NewEventStore creates a new EventStore
+func NewEventStore() *EventStore {
+	return &EventStore{store: common.NewEventStore()}
+}
+
+// Canonical returns the underlying canonical store
+func (es *EventStore) Canonical() *common.EventStore {
+	return es.store
+}
+
+// AppendEvent adds an event to a stream, assigning the next version
+func (es *EventStore) AppendEvent(streamID string, eventType string, data map[string]interface{}) *common.Event {
+	event := common.NewEvent(eventType, streamID, es.store.GetStreamVersion(streamID)+1, data, nil)
+	es.store.Append(event)
+	return event
+}
+
+// GetEvents returns all events for a stream
+func (es *EventStore) GetEvents(streamID string) ([]*common.Event, error) {
+	return es.store.GetStream(streamID)
+}
+
+// StreamExists checks if a stream exists
+func (es *EventStore) StreamExists(streamID string) bool {
+	_, err := es.store.HeadEvent(streamID)
+	return err == nil
+}
+
+// GetStreamVersion returns the current version of a stream
+func (es *EventStore) GetStreamVersion(streamID string) (int, error) {
+	if !es.StreamExists(streamID) {
+		return 0, &common.StreamNotFoundError{StreamID: streamID}
+	}
+	return es.store.GetStreamVersion(streamID), nil
+}
+
+// Command is a gpt41 cart command
+type Command interface {
+	Execute(es *EventStore) error
+}
+
+type CreateCart struct {
+	CartID string
+}
+
+// Execute creates the cart under the caller-chosen ID
+func (c *CreateCart) Execute(es *EventStore) error {
+	if es.StreamExists(c.CartID) {
+		return errors.New("cart already exists")
+	}
+	return es.store.Append(canonical.NewCartCreatedEvent(c.CartID))
+}
+
+type AddItem struct {
+	CartID string
+	Item   string
+}
+
+func (c *AddItem) Execute(es *EventStore) error {
+	return handle(es, c.CartID, &canonical.AddItemCommand{CartID: c.CartID, ItemID: c.Item})
+}
+
+type RemoveItem struct {
+	CartID string
+	Item   string
+}
+
+func (c *RemoveItem) Execute(es *EventStore) error {
+	return handle(es, c.CartID, &canonical.RemoveItemCommand{CartID: c.CartID, ItemID: c.Item})
+}
+
+type ClearCart struct {
+	CartID string
+}
+
+func (c *ClearCart) Execute(es *EventStore) error {
+	return handle(es, c.CartID, &canonical.ClearCartCommand{CartID: c.CartID})
+}
+
+func handle(es *EventStore, cartID string, command common.Command) error {
+	if !es.StreamExists(cartID) {
+		return errors.New("cart not found")
+	}
+	_, err := canonical.NewCartAggregate(es.store).Handle(command)
+	return err
+}
+
+// GetCart returns the items in the cart in the order they were added
+func GetCart(es *EventStore, cartID string) ([]string, error) {
+	events, err := es.GetEvents(cartID)
+	if err != nil {
+		return nil, err
+	}
+	items := []string{}
+	for _, event := range events {
+		item, _ := event.Data["item"].(string)
+		switch event.Type {
+		case "ItemAdded":
+			items = append(items, item)
+		case "ItemRemoved":
+			for i, v := range items {
+				if v == item {
+					items = append(items[:i], items[i+1:]...)
+					break
+				}
+			}
+		case "CartCleared":
+			items = []string{}
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,48 @@
+package gpt41_test
+
+import (
+	"reflect"
+	"testing"
+
+	"simple-event-modeling/compat/gpt41"
+	"simple-event-modeling/conformance"
+)
+
+func TestConformance(t *testing.T) {
+	conformance.RunCartSuite(t, func() conformance.CartDriver {
+		return conformance.NewGPT41Cart()
+	})
+}
+
+func TestCartCommandsAndQueries(t *testing.T) {
+	es := gpt41.NewEventStore()
+	cartID := "cart-100"
+
+	if err := (&gpt41.CreateCart{CartID: cartID}).Execute(es); err != nil {
+		t.Fatalf("CreateCart failed: %v", err)
+	}
+	if err := (&gpt41.CreateCart{CartID: cartID}).Execute(es); err == nil {
+		t.Error("Expected creating an existing cart to fail")
+	}
+	if err := (&gpt41.AddItem{CartID: "missing", Item: "apple"}).Execute(es); err == nil {
+		t.Error("Expected adding to a missing cart to fail")
+	}
+
+	_ = (&gpt41.AddItem{CartID: cartID, Item: "pear"}).Execute(es)
+	_ = (&gpt41.AddItem{CartID: cartID, Item: "grape"}).Execute(es)
+	items, err := gpt41.GetCart(es, cartID)
+	if err != nil {
+		t.Fatalf("GetCart failed: %v", err)
+	}
+	if !reflect.DeepEqual(items, []string{"pear", "grape"}) {
+		t.Errorf("Unexpected cart items: %v", items)
+	}
+
+	version, err := es.GetStreamVersion(cartID)
+	if err != nil || version != 3 {
+		t.Errorf("Expected version 3, got %d (%v)", version, err)
+	}
+	if _, err := es.GetStreamVersion("missing"); err == nil {
+		t.Error("Expected error for missing stream")
+	}
+}
@@ -0,0 +1,101 @@
+// Package cart adapts the canonical cart package to the API of the deprecated gpt5
+// port. Callers migrate by replacing the "gpt5/cart" import with
+// "simple-event-modeling/compat/gpt5/cart". Commands are routed to the canonical
+// CartAggregate, so business rules match the canonical cart exactly.
+package cart
+
+import (
+	canonical "simple-event-modeling/cart"
+	canonicalcommon "simple-event-modeling/common"
+	"simple-event-modeling/compat/gpt5/common"
+)
+
+// Commands are simple records with no behavior.
+
+type CreateCart struct{ AggregateID string }
+
+type AddItem struct {
+	AggregateID string
+	ItemID      string
+}
+
+type RemoveItem struct {
+	AggregateID string
+	ItemID      string
+}
+
+type ClearCart struct{ AggregateID string }
+
+// Domain events for the cart. Data-only.
+
+type CartCreated struct{ common.Event }
+
+type ItemAdded struct{ common.Event }
+
+type ItemRemoved struct{ common.Event }
+
+type CartCleared struct{ common.Event }
+
+// Factories mirror the Ruby constructors.
+func NewCartCreated(aggregateID string) CartCreated {
+	return CartCreated{Event: *canonical.NewCartCreatedEvent(aggregateID)}
+}
+
+func NewItemAdded(aggregateID string, version int, itemID string) ItemAdded {
+	return ItemAdded{Event: *canonical.NewItemAddedEvent(aggregateID, version, itemID)}
+}
+
+func NewItemRemoved(aggregateID string, version int, itemID string) ItemRemoved {
+	return ItemRemoved{Event: *canonical.NewItemRemovedEvent(aggregateID, version, itemID)}
+}
+
+func NewCartCleared(aggregateID string, version int) CartCleared {
+	return CartCleared{Event: *canonical.NewCartClearedEvent(aggregateID, version)}
+}
+
+// Aggregate exposes the gpt5 aggregate API over the canonical CartAggregate
+type Aggregate struct {
+	*canonical.CartAggregate
+}
+
+// NewAggregate creates an aggregate backed by the store
+func NewAggregate(store *common.EventStore) *Aggregate {
+	return &Aggregate{CartAggregate: canonical.NewCartAggregate(store.Canonical())}
+}
+
+// Handle translates gpt5 commands to canonical ones and returns the event by value
+func (a *Aggregate) Handle(cmd any) (common.Event, error) {
+	var translated canonicalcommon.Command
+	switch c := cmd.(type) {
+	case CreateCart:
+		translated = &canonical.CreateCartCommand{CartID: c.AggregateID}
+	case AddItem:
+		if c.ItemID == "" {
+			return common.Event{}, &common.InvalidCommandError{Message: "item id required"}
+		}
+		translated = &canonical.AddItemCommand{CartID: c.AggregateID, ItemID: c.ItemID}
+	case RemoveItem:
+		if c.AggregateID == "" {
+			return common.Event{}, &common.InvalidCommandError{Message: "aggregate id required"}
+		}
+		translated = &canonical.RemoveItemCommand{CartID: c.AggregateID, ItemID: c.ItemID}
+	case ClearCart:
+		if c.AggregateID == "" {
+			return common.Event{}, &common.InvalidCommandError{Message: "aggregate id required"}
+		}
+		translated = &canonical.ClearCartCommand{CartID: c.AggregateID}
+	default:
+		return common.Event{}, &common.InvalidCommandError{Message: "unknown command"}
+	}
+
+	event, err := a.CartAggregate.Handle(translated)
+	if err != nil {
+		return common.Event{}, err
+	}
+	return *event, nil
+}
+
+// On applies an event to mutate state
+func (a *Aggregate) On(e common.Event) error {
+	return a.CartAggregate.On(&e)
+}
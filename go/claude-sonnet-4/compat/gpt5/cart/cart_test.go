@@ -0,0 +1,33 @@
+package cart_test
+
+import (
+	"errors"
+	"testing"
+
+	"simple-event-modeling/compat/gpt5/cart"
+	"simple-event-modeling/compat/gpt5/common"
+	"simple-event-modeling/conformance"
+)
+
+func TestConformance(t *testing.T) {
+	conformance.RunCartSuite(t, func() conformance.CartDriver {
+		return conformance.NewGPT5Cart()
+	})
+}
+
+func TestHandleReturnsEventValues(t *testing.T) {
+	store := common.NewEventStore()
+	created, err := cart.NewAggregate(store).Handle(cart.CreateCart{})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if created.Type != "CartCreated" || created.Version != 1 {
+		t.Errorf("Unexpected event: %+v", created)
+	}
+
+	_, err = cart.NewAggregate(store).Handle(cart.AddItem{AggregateID: created.AggregateID})
+	var invalid *common.InvalidCommandError
+	if err == nil || !errors.As(err, &invalid) {
+		t.Errorf("Expected InvalidCommandError for missing item id, got %v", err)
+	}
+}
@@ -0,0 +1,38 @@
+// Package queries adapts the canonical cart query to the API of the deprecated gpt5
+// port. The projection is computed by the canonical CartItemsQuery and reshaped into
+// the map-based result the gpt5 port returned.
+package queries
+
+import (
+	canonical "simple-event-modeling/cart"
+	"simple-event-modeling/compat/gpt5/common"
+)
+
+// CartItemsRead projects the current state of a cart from its event stream.
+// Result shape mirrors the Ruby version: { cart: { cart_id, items, totals } }
+// items is map[itemID] -> { quantity: int }
+type CartItemsRead struct {
+	aggregateID string
+	store       *common.EventStore
+}
+
+func NewCartItemsRead(aggregateID string, store *common.EventStore) *CartItemsRead {
+	return &CartItemsRead{aggregateID: aggregateID, store: store}
+}
+
+func (q *CartItemsRead) Execute() (map[string]any, error) {
+	projection, err := canonical.NewCartItemsQuery(q.aggregateID, q.store.Canonical()).Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	items := map[string]map[string]int{}
+	for itemID, view := range projection.Items {
+		items[itemID] = map[string]int{"quantity": view.Quantity}
+	}
+	return map[string]any{"cart": map[string]any{
+		"cart_id": projection.CartID,
+		"items":   items,
+		"totals":  map[string]float64{"total": 0.0},
+	}}, nil
+}
@@ -0,0 +1,69 @@
+// Package common adapts the canonical common package to the API of the deprecated
+// gpt5 port. Callers migrate by replacing the "gpt5/common" import with
+// "simple-event-modeling/compat/gpt5/common", then move to the canonical package
+// at their own pace.
+package common
+
+import canonical "simple-event-modeling/common"
+
+// Event is the canonical event; gpt5 passed it by value
+type Event = canonical.Event
+
+// InvalidCommandError is the canonical invalid command error
+type InvalidCommandError = canonical.InvalidCommandError
+
+// StreamNotFoundError is the canonical missing stream error
+type StreamNotFoundError = canonical.StreamNotFoundError
+
+// NewEvent constructs an Event value
+func NewEvent(eventType, aggregateID string, version int, data, metadata map[string]interface{}) Event {
+	return *canonical.NewEvent(eventType, aggregateID, version, data, metadata)
+}
+
+// EventStore exposes the gpt5 value-based store API over a canonical store
+type EventStore struct {
+	store *canonical.EventStore
+}
+
+// NewEventStore creates a new in-memory event store
+func NewEventStore() *EventStore {
+	return &EventStore{store: canonical.NewEventStore()}
+}
+
+// Canonical returns the underlying canonical store
+func (es *EventStore) Canonical() *canonical.EventStore {
+	return es.store
+}
+
+// Append adds an event to the store and returns it
+func (es *EventStore) Append(event Event) Event {
+	es.store.Append(&event)
+	return event
+}
+
+// GetStream returns the events for a given aggregate or an error
+func (es *EventStore) GetStream(aggregateID string) ([]Event, error) {
+	stream, err := es.store.GetStream(aggregateID)
+	if err != nil {
+		return nil, err
+	}
+	return values(stream), nil
+}
+
+// GetStreamVersion returns the latest version in the stream or 0 if empty/missing
+func (es *EventStore) GetStreamVersion(aggregateID string) int {
+	return es.store.GetStreamVersion(aggregateID)
+}
+
+// All returns all events ever appended
+func (es *EventStore) All() []Event {
+	return values(es.store.GetAllEvents())
+}
+
+func values(events []*Event) []Event {
+	out := make([]Event, len(events))
+	for i, event := range events {
+		out[i] = *event
+	}
+	return out
+}
@@ -0,0 +1,52 @@
+package conformance
+
+import (
+	"simple-event-modeling/cart"
+	"simple-event-modeling/common"
+)
+
+// CanonicalCart drives the canonical cart package
+type CanonicalCart struct {
+	Store *common.EventStore
+}
+
+// NewCanonicalCart creates a driver backed by a fresh in-memory store
+func NewCanonicalCart() *CanonicalCart {
+	return &CanonicalCart{Store: common.NewEventStore()}
+}
+
+// CreateCart creates a new cart and returns its ID
+func (c *CanonicalCart) CreateCart() (string, error) {
+	event, err := cart.NewCartAggregate(c.Store).Handle(&cart.CreateCartCommand{})
+	if err != nil {
+		return "", err
+	}
+	return event.AggregateID, nil
+}
+
+// AddItem adds an item to the cart
+func (c *CanonicalCart) AddItem(cartID, itemID string) error {
+	_, err := cart.NewCartAggregate(c.Store).Handle(&cart.AddItemCommand{CartID: cartID, ItemID: itemID})
+	return err
+}
+
+// RemoveItem removes an item from the cart
+func (c *CanonicalCart) RemoveItem(cartID, itemID string) error {
+	_, err := cart.NewCartAggregate(c.Store).Handle(&cart.RemoveItemCommand{CartID: cartID, ItemID: itemID})
+	return err
+}
+
+// ClearCart removes every item from the cart
+func (c *CanonicalCart) ClearCart(cartID string) error {
+	_, err := cart.NewCartAggregate(c.Store).Handle(&cart.ClearCartCommand{CartID: cartID})
+	return err
+}
+
+// Items returns the quantity of every item in the cart
+func (c *CanonicalCart) Items(cartID string) (map[string]int, error) {
+	aggregate := cart.NewCartAggregate(c.Store)
+	if err := aggregate.Hydrate(cartID); err != nil {
+		return nil, err
+	}
+	return aggregate.Items(), nil
+}
@@ -0,0 +1,114 @@
+// Package conformance defines the cart behavior every implementation must share.
+// The canonical cart and the compatibility adapters for the older ports all run the
+// same suite, so callers migrating between them can rely on identical semantics.
+package conformance
+
+import (
+	"testing"
+)
+
+// CartDriver drives a cart implementation through its public API
+type CartDriver interface {
+	// CreateCart creates a new cart and returns its ID
+	CreateCart() (string, error)
+	AddItem(cartID, itemID string) error
+	RemoveItem(cartID, itemID string) error
+	ClearCart(cartID string) error
+	// Items returns the quantity of every item in the cart
+	Items(cartID string) (map[string]int, error)
+}
+
+// MaxItems is the cart item limit every implementation enforces
+const MaxItems = 3
+
+// RunCartSuite runs the conformance suite, creating a fresh driver for every case
+func RunCartSuite(t *testing.T, newDriver func() CartDriver) {
+	t.Run("AddItems", func(t *testing.T) {
+		d := newDriver()
+		id := mustCreate(t, d)
+		mustDo(t, d.AddItem(id, "apple"))
+		mustDo(t, d.AddItem(id, "apple"))
+		mustDo(t, d.AddItem(id, "banana"))
+		expectItems(t, d, id, map[string]int{"apple": 2, "banana": 1})
+	})
+
+	t.Run("RemoveItem", func(t *testing.T) {
+		d := newDriver()
+		id := mustCreate(t, d)
+		mustDo(t, d.AddItem(id, "apple"))
+		mustDo(t, d.AddItem(id, "apple"))
+		mustDo(t, d.RemoveItem(id, "apple"))
+		expectItems(t, d, id, map[string]int{"apple": 1})
+	})
+
+	t.Run("RemoveMissingItemIsRejected", func(t *testing.T) {
+		d := newDriver()
+		id := mustCreate(t, d)
+		if err := d.RemoveItem(id, "apple"); err == nil {
+			t.Error("Expected removing a missing item to fail")
+		}
+	})
+
+	t.Run("ClearCart", func(t *testing.T) {
+		d := newDriver()
+		id := mustCreate(t, d)
+		mustDo(t, d.AddItem(id, "apple"))
+		mustDo(t, d.ClearCart(id))
+		expectItems(t, d, id, map[string]int{})
+	})
+
+	t.Run("ItemLimitIsEnforced", func(t *testing.T) {
+		d := newDriver()
+		id := mustCreate(t, d)
+		for i := 0; i < MaxItems; i++ {
+			mustDo(t, d.AddItem(id, "apple"))
+		}
+		if err := d.AddItem(id, "banana"); err == nil {
+			t.Errorf("Expected item %d to be rejected", MaxItems+1)
+		}
+		expectItems(t, d, id, map[string]int{"apple": MaxItems})
+	})
+
+	t.Run("CartsAreIsolated", func(t *testing.T) {
+		d := newDriver()
+		first := mustCreate(t, d)
+		second := mustCreate(t, d)
+		if first == second {
+			t.Fatalf("Expected distinct cart IDs, got %s twice", first)
+		}
+		mustDo(t, d.AddItem(first, "apple"))
+		expectItems(t, d, second, map[string]int{})
+	})
+}
+
+func mustCreate(t *testing.T, d CartDriver) string {
+	t.Helper()
+	id, err := d.CreateCart()
+	if err != nil {
+		t.Fatalf("Error creating cart: %v", err)
+	}
+	return id
+}
+
+func mustDo(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func expectItems(t *testing.T, d CartDriver, cartID string, expected map[string]int) {
+	t.Helper()
+	items, err := d.Items(cartID)
+	if err != nil {
+		t.Fatalf("Error reading items: %v", err)
+	}
+	if len(items) != len(expected) {
+		t.Fatalf("Expected items %v, got %v", expected, items)
+	}
+	for item, quantity := range expected {
+		if items[item] != quantity {
+			t.Errorf("Expected %s quantity %d, got %d", item, quantity, items[item])
+		}
+	}
+}
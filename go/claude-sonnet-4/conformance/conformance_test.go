@@ -0,0 +1,7 @@
+package conformance
+
+import "testing"
+
+func TestCanonicalCartConforms(t *testing.T) {
+	RunCartSuite(t, func() CartDriver { return NewCanonicalCart() })
+}
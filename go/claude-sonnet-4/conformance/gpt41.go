@@ -0,0 +1,51 @@
+package conformance
+
+import (
+	"simple-event-modeling/compat/gpt41"
+
+	"github.com/google/uuid"
+)
+
+// GPT41Cart drives the cart through the gpt41 compatibility API
+type GPT41Cart struct {
+	Store *gpt41.EventStore
+}
+
+// NewGPT41Cart creates a driver backed by a fresh gpt41 store
+func NewGPT41Cart() *GPT41Cart {
+	return &GPT41Cart{Store: gpt41.NewEventStore()}
+}
+
+// CreateCart creates a new cart and returns its ID; gpt41 callers chose the ID
+func (c *GPT41Cart) CreateCart() (string, error) {
+	id := uuid.New().String()
+	return id, (&gpt41.CreateCart{CartID: id}).Execute(c.Store)
+}
+
+// AddItem adds an item to the cart
+func (c *GPT41Cart) AddItem(cartID, itemID string) error {
+	return (&gpt41.AddItem{CartID: cartID, Item: itemID}).Execute(c.Store)
+}
+
+// RemoveItem removes an item from the cart
+func (c *GPT41Cart) RemoveItem(cartID, itemID string) error {
+	return (&gpt41.RemoveItem{CartID: cartID, Item: itemID}).Execute(c.Store)
+}
+
+// ClearCart removes every item from the cart
+func (c *GPT41Cart) ClearCart(cartID string) error {
+	return (&gpt41.ClearCart{CartID: cartID}).Execute(c.Store)
+}
+
+// Items returns the quantity of every item in the cart
+func (c *GPT41Cart) Items(cartID string) (map[string]int, error) {
+	list, err := gpt41.GetCart(c.Store, cartID)
+	if err != nil {
+		return nil, err
+	}
+	items := map[string]int{}
+	for _, item := range list {
+		items[item]++
+	}
+	return items, nil
+}
@@ -0,0 +1,54 @@
+package conformance
+
+import (
+	"simple-event-modeling/compat/gpt5/cart"
+	"simple-event-modeling/compat/gpt5/cart/queries"
+	"simple-event-modeling/compat/gpt5/common"
+)
+
+// GPT5Cart drives the cart through the gpt5 compatibility API
+type GPT5Cart struct {
+	Store *common.EventStore
+}
+
+// NewGPT5Cart creates a driver backed by a fresh gpt5 store
+func NewGPT5Cart() *GPT5Cart {
+	return &GPT5Cart{Store: common.NewEventStore()}
+}
+
+// CreateCart creates a new cart and returns its ID
+func (c *GPT5Cart) CreateCart() (string, error) {
+	event, err := cart.NewAggregate(c.Store).Handle(cart.CreateCart{})
+	return event.AggregateID, err
+}
+
+// AddItem adds an item to the cart
+func (c *GPT5Cart) AddItem(cartID, itemID string) error {
+	_, err := cart.NewAggregate(c.Store).Handle(cart.AddItem{AggregateID: cartID, ItemID: itemID})
+	return err
+}
+
+// RemoveItem removes an item from the cart
+func (c *GPT5Cart) RemoveItem(cartID, itemID string) error {
+	_, err := cart.NewAggregate(c.Store).Handle(cart.RemoveItem{AggregateID: cartID, ItemID: itemID})
+	return err
+}
+
+// ClearCart removes every item from the cart
+func (c *GPT5Cart) ClearCart(cartID string) error {
+	_, err := cart.NewAggregate(c.Store).Handle(cart.ClearCart{AggregateID: cartID})
+	return err
+}
+
+// Items returns the quantity of every item in the cart
+func (c *GPT5Cart) Items(cartID string) (map[string]int, error) {
+	result, err := queries.NewCartItemsRead(cartID, c.Store).Execute()
+	if err != nil {
+		return nil, err
+	}
+	items := map[string]int{}
+	for id, item := range result["cart"].(map[string]any)["items"].(map[string]map[string]int) {
+		items[id] = item["quantity"]
+	}
+	return items, nil
+}
@@ -0,0 +1,362 @@
+// Package cryptostore provides a Store decorator encrypting the payload fields
+// registered as sensitive (common.FieldInfo.Sensitive) before they are stored, and
+// decrypting them when events are read back through it:
+//
+//	store := cryptostore.New(common.NewEventStore(), common.DefaultRegistry, keyring)
+//	aggregate := cart.NewCartAggregate(store)
+//
+// Only the sensitive fields are encrypted, so projections reading the underlying
+// store directly still see every other field, and only the sensitive ones as
+// ciphertext. A sensitive field is stored as an envelope object,
+//
+//	{"$encrypted": "v1", "key": "<key ID>", "ciphertext": "<base64 nonce and ciphertext>"}
+//
+// which no plaintext value is mistaken for: every sensitive field is encrypted
+// whatever it holds, and only the sensitive fields are ever decrypted.
+//
+// Fields are encrypted with AES-256-GCM under a key named by WithKeyFunc, by
+// default one key for the whole store. Keying by data subject, e.g. by the
+// customer an event concerns, enables crypto-shredding: once the subject's key is
+// shredded from the keyring, their fields read as common.RedactedValue while the
+// events themselves stay in the append-only log.
+package cryptostore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"simple-event-modeling/common"
+)
+
+// DefaultKeyID is the key every field is encrypted with unless WithKeyFunc says
+// otherwise
+const DefaultKeyID = "default"
+
+// Keys of the envelope an encrypted field is stored as
+const (
+	envelopeVersionKey    = "$encrypted"
+	envelopeKeyIDKey      = "key"
+	envelopeCiphertextKey = "ciphertext"
+	envelopeVersion       = "v1"
+)
+
+// ErrKeyNotFound is returned by a Keyring for a key it doesn't hold, e.g. because
+// it was shredded
+var ErrKeyNotFound = errors.New("encryption key not found")
+
+// Keyring holds the 32-byte keys fields are encrypted with
+type Keyring interface {
+	// EncryptionKey returns the key with an ID, creating it if there is none
+	EncryptionKey(keyID string) ([]byte, error)
+	// DecryptionKey returns the key with an ID, or an error wrapping
+	// ErrKeyNotFound
+	DecryptionKey(keyID string) ([]byte, error)
+}
+
+// MemoryKeyring is an in-memory Keyring generating random keys, for tests and
+// demos. It is safe for concurrent use.
+type MemoryKeyring struct {
+	mu   sync.Mutex
+	keys map[string][]byte
+}
+
+var _ Keyring = (*MemoryKeyring)(nil)
+
+// NewMemoryKeyring creates an empty keyring
+func NewMemoryKeyring() *MemoryKeyring {
+	return &MemoryKeyring{keys: make(map[string][]byte)}
+}
+
+// EncryptionKey returns the key with an ID, generating it on first use
+func (k *MemoryKeyring) EncryptionKey(keyID string) ([]byte, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if key, ok := k.keys[keyID]; ok {
+		return key, nil
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	k.keys[keyID] = key
+	return key, nil
+}
+
+// DecryptionKey returns the key with an ID
+func (k *MemoryKeyring) DecryptionKey(keyID string) ([]byte, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	key, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, keyID)
+	}
+	return key, nil
+}
+
+// Shred deletes a key, making the fields encrypted with it unreadable for good
+func (k *MemoryKeyring) Shred(keyID string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.keys, keyID)
+}
+
+// Store is a Store encrypting sensitive payload fields
+type Store struct {
+	common.Store
+	registry *common.Registry
+	keyring  Keyring
+	keyID    func(event *common.Event) string
+}
+
+var (
+	_ common.Store         = (*Store)(nil)
+	_ common.BatchAppender = (*Store)(nil)
+)
+
+// Option configures a Store
+type Option func(*Store)
+
+// WithKeyFunc encrypts the fields of each event with the key keyID names, e.g. a
+// key per customer for crypto-shredding
+func WithKeyFunc(keyID func(event *common.Event) string) Option {
+	return func(s *Store) {
+		s.keyID = keyID
+	}
+}
+
+// New wraps store, encrypting the fields registry marks as sensitive with keys from
+// keyring
+func New(store common.Store, registry *common.Registry, keyring Keyring, opts ...Option) *Store {
+	s := &Store{
+		Store:    store,
+		registry: registry,
+		keyring:  keyring,
+		keyID:    func(*common.Event) string { return DefaultKeyID },
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Append stores the event with its sensitive fields encrypted. The caller's event
+// is left as it was.
+func (s *Store) Append(event *common.Event) error {
+	encrypted, err := s.Encrypt(event)
+	if err != nil {
+		return err
+	}
+	return s.Store.Append(encrypted)
+}
+
+// AppendBatch encrypts every event before appending any, then appends them
+// atomically when the underlying store is a BatchAppender, one by one otherwise
+func (s *Store) AppendBatch(events []*common.Event) error {
+	encrypted := make([]*common.Event, len(events))
+	for i, event := range events {
+		var err error
+		if encrypted[i], err = s.Encrypt(event); err != nil {
+			return err
+		}
+	}
+
+	batch, ok := s.Store.(common.BatchAppender)
+	if !ok {
+		for _, event := range encrypted {
+			if err := s.Store.Append(event); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return batch.AppendBatch(encrypted)
+}
+
+// GetStream returns the events of a stream with their sensitive fields decrypted
+func (s *Store) GetStream(aggregateID string) ([]*common.Event, error) {
+	events, err := s.Store.GetStream(aggregateID)
+	if err != nil {
+		return nil, err
+	}
+	return s.decryptAll(events)
+}
+
+// HeadEvent returns the latest event of a stream, decrypted
+func (s *Store) HeadEvent(aggregateID string) (*common.Event, error) {
+	head, err := s.Store.HeadEvent(aggregateID)
+	if err != nil {
+		return nil, err
+	}
+	return s.Decrypt(head)
+}
+
+// GetAllEvents returns every event, decrypted. Events that cannot be decrypted for
+// another reason than a shredded key are returned as stored.
+func (s *Store) GetAllEvents() []*common.Event {
+	events := s.Store.GetAllEvents()
+	decrypted := make([]*common.Event, len(events))
+	for i, event := range events {
+		var err error
+		if decrypted[i], err = s.Decrypt(event); err != nil {
+			decrypted[i] = event
+		}
+	}
+	return decrypted
+}
+
+// Encrypt returns a copy of event with its sensitive fields encrypted, or event
+// itself when it has none
+func (s *Store) Encrypt(event *common.Event) (*common.Event, error) {
+	fields := s.registry.SensitiveFields(event.Type)
+	var encrypted *common.Event
+	for _, field := range fields {
+		value, ok := event.Data[field]
+		if !ok {
+			continue
+		}
+		if encrypted == nil {
+			encrypted = copyEvent(event)
+		}
+		ciphertext, err := s.encryptValue(s.keyID(event), event, field, value)
+		if err != nil {
+			return nil, fmt.Errorf("encrypting %s of %s: %w", field, event.Type, err)
+		}
+		encrypted.Data[field] = ciphertext
+	}
+	if encrypted == nil {
+		return event, nil
+	}
+	return encrypted, nil
+}
+
+// Decrypt returns a copy of event with its sensitive fields decrypted, or event
+// itself when it has none. Sensitive fields stored before they were registered as
+// sensitive are left as they are, and fields whose key was shredded read as
+// common.RedactedValue.
+func (s *Store) Decrypt(event *common.Event) (*common.Event, error) {
+	var decrypted *common.Event
+	for _, field := range s.registry.SensitiveFields(event.Type) {
+		envelope, ok := event.Data[field].(map[string]interface{})
+		if !ok || envelope[envelopeVersionKey] == nil {
+			continue
+		}
+		if decrypted == nil {
+			decrypted = copyEvent(event)
+		}
+		plain, err := s.decryptValue(event, field, envelope)
+		if errors.Is(err, ErrKeyNotFound) {
+			plain = common.RedactedValue
+		} else if err != nil {
+			return nil, fmt.Errorf("decrypting %s of %s %s v%d: %w", field, event.Type, event.AggregateID, event.Version, err)
+		}
+		decrypted.Data[field] = plain
+	}
+	if decrypted == nil {
+		return event, nil
+	}
+	return decrypted, nil
+}
+
+// decryptAll decrypts events into a new slice, as backends may return their own
+func (s *Store) decryptAll(events []*common.Event) ([]*common.Event, error) {
+	decrypted := make([]*common.Event, len(events))
+	for i, event := range events {
+		var err error
+		if decrypted[i], err = s.Decrypt(event); err != nil {
+			return nil, err
+		}
+	}
+	return decrypted, nil
+}
+
+// encryptValue seals the JSON of value, bound to the event and field it belongs to,
+// in an envelope
+func (s *Store) encryptValue(keyID string, event *common.Event, field string, value interface{}) (map[string]interface{}, error) {
+	plaintext, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	key, err := s.keyring.EncryptionKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, additionalData(event, field))
+	return map[string]interface{}{
+		envelopeVersionKey:    envelopeVersion,
+		envelopeKeyIDKey:      keyID,
+		envelopeCiphertextKey: base64.StdEncoding.EncodeToString(sealed),
+	}, nil
+}
+
+func (s *Store) decryptValue(event *common.Event, field string, envelope map[string]interface{}) (interface{}, error) {
+	if version := envelope[envelopeVersionKey]; version != envelopeVersion {
+		return nil, fmt.Errorf("unsupported encryption envelope %v", version)
+	}
+	keyID, _ := envelope[envelopeKeyIDKey].(string)
+	ciphertext, _ := envelope[envelopeCiphertextKey].(string)
+	if keyID == "" || ciphertext == "" {
+		return nil, errors.New("malformed encrypted value")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	key, err := s.keyring.DecryptionKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("malformed encrypted value")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additionalData(event, field))
+	if err != nil {
+		return nil, err
+	}
+	var plain interface{}
+	if err := json.Unmarshal(plaintext, &plain); err != nil {
+		return nil, err
+	}
+	return plain, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// additionalData binds a ciphertext to its event and field, so it cannot be copied
+// into another one
+func additionalData(event *common.Event, field string) []byte {
+	return []byte(event.ID + "/" + field)
+}
+
+// copyEvent copies event with a data map of its own
+func copyEvent(event *common.Event) *common.Event {
+	copied := *event
+	copied.Data = make(map[string]interface{}, len(event.Data))
+	for key, value := range event.Data {
+		copied.Data[key] = value
+	}
+	return &copied
+}
@@ -0,0 +1,120 @@
+package cryptostore
+
+import (
+	"simple-event-modeling/common"
+	"testing"
+)
+
+func customerRegistry() *common.Registry {
+	registry := common.NewRegistry()
+	registry.RegisterEvent(common.EventInfo{Name: "CustomerAssigned", Payload: []common.FieldInfo{
+		{Name: "customer_id", Type: "string"},
+		{Name: "email", Type: "string", Sensitive: true},
+		{Name: "phone", Type: "string", Optional: true, Sensitive: true},
+	}})
+	return registry
+}
+
+func TestStore_EncryptsSensitiveFieldsOnly(t *testing.T) {
+	inner := common.NewEventStore()
+	store := New(inner, customerRegistry(), NewMemoryKeyring())
+
+	event := common.NewEvent("CustomerAssigned", "cart-1", 1, map[string]interface{}{
+		"customer_id": "c-1",
+		"email":       "ada@example.com",
+	}, nil)
+	if err := store.Append(event); err != nil {
+		t.Fatalf("Error appending: %v", err)
+	}
+	if event.Data["email"] != "ada@example.com" {
+		t.Errorf("Expected the caller's event to be left alone, got %v", event.Data)
+	}
+
+	stored := inner.GetAllEvents()[0]
+	if envelope, _ := stored.Data["email"].(map[string]interface{}); envelope["$encrypted"] != "v1" || envelope["key"] != DefaultKeyID {
+		t.Errorf("Expected the email to be stored encrypted, got %v", stored.Data["email"])
+	}
+	if stored.Data["customer_id"] != "c-1" {
+		t.Errorf("Expected other fields to stay readable, got %v", stored.Data)
+	}
+
+	events, err := store.GetStream("cart-1")
+	if err != nil {
+		t.Fatalf("Error reading stream: %v", err)
+	}
+	if events[0].Data["email"] != "ada@example.com" {
+		t.Errorf("Expected the email to be decrypted, got %v", events[0].Data)
+	}
+	if all := store.GetAllEvents(); all[0].Data["email"] != "ada@example.com" || inner.GetAllEvents()[0].Data["email"] == "ada@example.com" {
+		t.Error("Expected GetAllEvents to decrypt copies of the stored events")
+	}
+
+	// A ciphertext copied into another event does not decrypt
+	forged := common.NewEvent("CustomerAssigned", "cart-2", 1, map[string]interface{}{"email": stored.Data["email"]}, nil)
+	inner.Append(forged)
+	if _, err := store.HeadEvent("cart-2"); err == nil {
+		t.Error("Expected a ciphertext bound to another event to be rejected")
+	}
+}
+
+func TestStore_CryptoShredding(t *testing.T) {
+	inner := common.NewEventStore()
+	keyring := NewMemoryKeyring()
+	store := New(inner, customerRegistry(), keyring, WithKeyFunc(func(event *common.Event) string {
+		return "customer-" + event.Data["customer_id"].(string)
+	}))
+
+	err := store.AppendBatch([]*common.Event{
+		common.NewEvent("CustomerAssigned", "cart-1", 1, map[string]interface{}{"customer_id": "c-1", "email": "ada@example.com"}, nil),
+		common.NewEvent("CustomerAssigned", "cart-2", 1, map[string]interface{}{"customer_id": "c-2", "email": "bob@example.com", "phone": "555-0100"}, nil),
+	})
+	if err != nil {
+		t.Fatalf("Error appending: %v", err)
+	}
+
+	keyring.Shred("customer-c-1")
+	shredded, err := store.HeadEvent("cart-1")
+	if err != nil {
+		t.Fatalf("Error reading shredded event: %v", err)
+	}
+	if shredded.Data["email"] != common.RedactedValue || shredded.Data["customer_id"] != "c-1" {
+		t.Errorf("Expected only the shredded email to be redacted, got %v", shredded.Data)
+	}
+	kept, _ := store.HeadEvent("cart-2")
+	if kept.Data["email"] != "bob@example.com" || kept.Data["phone"] != "555-0100" {
+		t.Errorf("Expected the other customer's fields to decrypt, got %v", kept.Data)
+	}
+}
+
+func TestStore_PlaintextLookingLikeCiphertext(t *testing.T) {
+	inner := common.NewEventStore()
+	store := New(inner, customerRegistry(), NewMemoryKeyring())
+
+	event := common.NewEvent("CustomerAssigned", "cart-1", 1, map[string]interface{}{
+		"customer_id": "enc:v1:x",
+		"email":       "enc:v1:default:AAAA",
+		"phone":       map[string]interface{}{"$encrypted": "v1"},
+	}, nil)
+	if err := store.Append(event); err != nil {
+		t.Fatalf("Error appending: %v", err)
+	}
+
+	stored := inner.GetAllEvents()[0]
+	if stored.Data["email"] == "enc:v1:default:AAAA" {
+		t.Error("Expected a sensitive value to be encrypted whatever it holds")
+	}
+	if stored.Data["customer_id"] != "enc:v1:x" {
+		t.Errorf("Expected other fields to be stored as they are, got %v", stored.Data)
+	}
+
+	head, err := store.HeadEvent("cart-1")
+	if err != nil {
+		t.Fatalf("Error reading event: %v", err)
+	}
+	if head.Data["customer_id"] != "enc:v1:x" || head.Data["email"] != "enc:v1:default:AAAA" {
+		t.Errorf("Expected the values to read back as appended, got %v", head.Data)
+	}
+	if phone, _ := head.Data["phone"].(map[string]interface{}); phone["$encrypted"] != "v1" || len(phone) != 1 {
+		t.Errorf("Expected the phone to read back as appended, got %v", head.Data["phone"])
+	}
+}
@@ -0,0 +1,185 @@
+// Package diagram generates event modeling diagrams from the components registered
+// in a common.Registry. Diagrams are laid out as event modeling swimlanes:
+// commands flow into the events they produce, and events flow into the read models
+// that consume them.
+package diagram
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"simple-event-modeling/common"
+)
+
+// Format identifies a diagram output format
+type Format string
+
+// Supported diagram formats
+const (
+	FormatMermaid  Format = "mermaid"
+	FormatPlantUML Format = "plantuml"
+	// FormatMarkdown wraps the Mermaid diagram in a Markdown document
+	FormatMarkdown Format = "markdown"
+)
+
+// model is the registry content flattened into swimlanes
+type model struct {
+	commands    []common.CommandInfo
+	lanes       []lane
+	projections []projectionInfo
+}
+
+// lane groups the events emitted by one aggregate
+type lane struct {
+	aggregate string
+	events    []string
+}
+
+type projectionInfo struct {
+	name     string
+	consumes []string
+}
+
+// Generate renders the registry in the requested format
+func Generate(registry *common.Registry, format Format) (string, error) {
+	switch format {
+	case FormatMermaid:
+		return Mermaid(registry), nil
+	case FormatPlantUML:
+		return PlantUML(registry), nil
+	case FormatMarkdown:
+		return Markdown(registry), nil
+	default:
+		return "", fmt.Errorf("unknown diagram format %q", format)
+	}
+}
+
+// Mermaid renders the registry as a Mermaid flowchart
+func Mermaid(registry *common.Registry) string {
+	m := buildModel(registry)
+	var b strings.Builder
+
+	b.WriteString("flowchart LR\n")
+	b.WriteString("  subgraph commands [Commands]\n")
+	for _, cmd := range m.commands {
+		fmt.Fprintf(&b, "    %s[%s]\n", commandNode(cmd.Name), cmd.Name)
+	}
+	b.WriteString("  end\n")
+	for _, l := range m.lanes {
+		fmt.Fprintf(&b, "  subgraph %s [%s Events]\n", nodeID("lane", l.aggregate), l.aggregate)
+		for _, event := range l.events {
+			fmt.Fprintf(&b, "    %s([%s])\n", eventNode(event), event)
+		}
+		b.WriteString("  end\n")
+	}
+	b.WriteString("  subgraph readmodels [Read Models]\n")
+	for _, p := range m.projections {
+		fmt.Fprintf(&b, "    %s[(%s)]\n", projectionNode(p.name), p.name)
+	}
+	b.WriteString("  end\n")
+
+	for _, cmd := range m.commands {
+		for _, event := range cmd.Produces {
+			fmt.Fprintf(&b, "  %s --> %s\n", commandNode(cmd.Name), eventNode(event))
+		}
+	}
+	for _, p := range m.projections {
+		for _, event := range p.consumes {
+			fmt.Fprintf(&b, "  %s --> %s\n", eventNode(event), projectionNode(p.name))
+		}
+	}
+	return b.String()
+}
+
+// PlantUML renders the registry as a PlantUML diagram
+func PlantUML(registry *common.Registry) string {
+	m := buildModel(registry)
+	var b strings.Builder
+
+	b.WriteString("@startuml\n")
+	b.WriteString("left to right direction\n")
+	b.WriteString("rectangle \"Commands\" {\n")
+	for _, cmd := range m.commands {
+		fmt.Fprintf(&b, "  rectangle \"%s\" as %s #LightBlue\n", cmd.Name, commandNode(cmd.Name))
+	}
+	b.WriteString("}\n")
+	for _, l := range m.lanes {
+		fmt.Fprintf(&b, "rectangle \"%s Events\" {\n", l.aggregate)
+		for _, event := range l.events {
+			fmt.Fprintf(&b, "  rectangle \"%s\" as %s #Orange\n", event, eventNode(event))
+		}
+		b.WriteString("}\n")
+	}
+	b.WriteString("rectangle \"Read Models\" {\n")
+	for _, p := range m.projections {
+		fmt.Fprintf(&b, "  rectangle \"%s\" as %s #LightGreen\n", p.name, projectionNode(p.name))
+	}
+	b.WriteString("}\n")
+
+	for _, cmd := range m.commands {
+		for _, event := range cmd.Produces {
+			fmt.Fprintf(&b, "%s --> %s\n", commandNode(cmd.Name), eventNode(event))
+		}
+	}
+	for _, p := range m.projections {
+		for _, event := range p.consumes {
+			fmt.Fprintf(&b, "%s --> %s\n", eventNode(event), projectionNode(p.name))
+		}
+	}
+	b.WriteString("@enduml\n")
+	return b.String()
+}
+
+// Markdown renders the Mermaid diagram inside a Markdown document
+func Markdown(registry *common.Registry) string {
+	var b strings.Builder
+	b.WriteString("# Event Model\n\n")
+	b.WriteString("<!-- Generated by `sem diagram -format markdown`. Do not edit by hand. -->\n\n")
+	b.WriteString("```mermaid\n")
+	b.WriteString(Mermaid(registry))
+	b.WriteString("```\n")
+	return b.String()
+}
+
+func buildModel(registry *common.Registry) model {
+	m := model{commands: registry.Commands()}
+
+	byAggregate := make(map[string][]string)
+	for _, event := range registry.Events() {
+		byAggregate[event.Aggregate] = append(byAggregate[event.Aggregate], event.Name)
+	}
+	aggregates := make([]string, 0, len(byAggregate))
+	for aggregate := range byAggregate {
+		aggregates = append(aggregates, aggregate)
+	}
+	sort.Strings(aggregates)
+	for _, aggregate := range aggregates {
+		m.lanes = append(m.lanes, lane{aggregate: aggregate, events: byAggregate[aggregate]})
+	}
+
+	for _, name := range registry.ProjectionNames() {
+		info := projectionInfo{name: name}
+		if projection, err := registry.NewProjection(name); err == nil {
+			if consumer, ok := projection.(common.EventConsumer); ok {
+				info.consumes = consumer.Consumes()
+			}
+		}
+		m.projections = append(m.projections, info)
+	}
+	return m
+}
+
+func commandNode(name string) string    { return nodeID("cmd", name) }
+func eventNode(name string) string      { return nodeID("evt", name) }
+func projectionNode(name string) string { return nodeID("rm", name) }
+
+// nodeID builds an identifier that is valid in both Mermaid and PlantUML
+func nodeID(prefix, name string) string {
+	return prefix + "_" + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)
+}
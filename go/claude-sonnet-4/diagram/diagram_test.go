@@ -0,0 +1,72 @@
+package diagram
+
+import (
+	"os"
+	"simple-event-modeling/common"
+	"strings"
+	"testing"
+
+	// Register the cart domain with common.DefaultRegistry
+	_ "simple-event-modeling/cart"
+)
+
+func testRegistry() *common.Registry {
+	registry := common.NewRegistry()
+	registry.RegisterAggregate(common.AggregateInfo{Name: "Order"})
+	registry.RegisterCommand(common.CommandInfo{Name: "PlaceOrder", Aggregate: "Order", Produces: []string{"OrderPlaced"}})
+	registry.RegisterEvent(common.EventInfo{Name: "OrderPlaced", Aggregate: "Order"})
+	return registry
+}
+
+func TestMermaid(t *testing.T) {
+	out := Mermaid(testRegistry())
+
+	for _, want := range []string{
+		"flowchart LR",
+		"cmd_PlaceOrder[PlaceOrder]",
+		"subgraph lane_Order [Order Events]",
+		"evt_OrderPlaced([OrderPlaced])",
+		"cmd_PlaceOrder --> evt_OrderPlaced",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected Mermaid output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestPlantUML(t *testing.T) {
+	out := PlantUML(testRegistry())
+
+	if !strings.HasPrefix(out, "@startuml\n") || !strings.HasSuffix(out, "@enduml\n") {
+		t.Errorf("Expected a complete PlantUML document, got:\n%s", out)
+	}
+	if !strings.Contains(out, "cmd_PlaceOrder --> evt_OrderPlaced") {
+		t.Errorf("Expected command to event edge, got:\n%s", out)
+	}
+}
+
+func TestGenerate_UnknownFormat(t *testing.T) {
+	if _, err := Generate(testRegistry(), Format("svg")); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
+
+func TestCartDiagramIncludesReadModels(t *testing.T) {
+	out := Mermaid(common.DefaultRegistry)
+
+	if !strings.Contains(out, "evt_ItemAdded --> rm_cart_items") {
+		t.Errorf("Expected ItemAdded to feed the cart-items read model, got:\n%s", out)
+	}
+}
+
+// TestEventModelDocIsCurrent keeps docs/event_model.md in sync with the registered domains.
+// Regenerate it with: go run ./cmd/sem diagram -format markdown > docs/event_model.md
+func TestEventModelDocIsCurrent(t *testing.T) {
+	doc, err := os.ReadFile("../docs/event_model.md")
+	if err != nil {
+		t.Fatalf("Error reading event model doc: %v", err)
+	}
+	if string(doc) != Markdown(common.DefaultRegistry) {
+		t.Error("docs/event_model.md is out of date; regenerate it with `go run ./cmd/sem diagram -format markdown > docs/event_model.md`")
+	}
+}
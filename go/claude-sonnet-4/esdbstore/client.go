@@ -0,0 +1,83 @@
+package esdbstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Client is the subset of the EventStoreDB/Kurrent gRPC client the store uses.
+// Its methods mirror AppendToStream, ReadStream (forwards, and backwards for
+// ReadLastEvent), ReadAll, and SubscribeToStream of
+// the official client, with the client's option and result types flattened to the
+// plain values below so this module does not depend on the client library.
+type Client interface {
+	// AppendToStream appends events to a stream if it is at the expected revision.
+	// A stream at another revision fails with *WrongExpectedVersionError.
+	AppendToStream(ctx context.Context, streamID string, expected ExpectedRevision, events ...EventData) error
+	// ReadStream reads a stream forwards from revision 0, resolving link events such
+	// as those of the $ce- category streams to the events they point to. A stream
+	// that does not exist fails with ErrStreamNotFound.
+	ReadStream(ctx context.Context, streamID string) ([]RecordedEvent, error)
+	// ReadLastEvent reads a stream backwards from its end with a count of 1. A stream
+	// that does not exist fails with ErrStreamNotFound.
+	ReadLastEvent(ctx context.Context, streamID string) (RecordedEvent, error)
+	// ReadAll reads the $all stream forwards, skipping system events
+	ReadAll(ctx context.Context) ([]RecordedEvent, error)
+	// SubscribeToStream delivers the events of a stream after revision from (or from
+	// the start when from is nil) until ctx is done. The channel is then closed.
+	SubscribeToStream(ctx context.Context, streamID string, from *uint64) (<-chan RecordedEvent, error)
+}
+
+// ExpectedRevision is the revision a stream must be at for an append to succeed
+type ExpectedRevision struct {
+	// NoStream requires the stream not to exist yet
+	NoStream bool
+	// Revision is the 0-based revision of the stream's last event when NoStream is false
+	Revision uint64
+}
+
+// EventData is an event to append
+type EventData struct {
+	EventID   string
+	EventType string
+	// ContentType describes Data: JSONContentType, or the content type of a binary payload
+	ContentType string
+	// Data is the payload; Metadata is a JSON document
+	Data     []byte
+	Metadata []byte
+}
+
+// JSONContentType marks events whose Data is a JSON document
+const JSONContentType = "application/json"
+
+// RecordedEvent is an event read from the database
+type RecordedEvent struct {
+	StreamID    string
+	Revision    uint64
+	EventID     string
+	EventType   string
+	ContentType string
+	Data        []byte
+	Metadata    []byte
+	Created     time.Time
+}
+
+// ErrStreamNotFound is returned by Client.ReadStream and Client.ReadLastEvent for a stream that does not exist
+var ErrStreamNotFound = errors.New("stream not found")
+
+// WrongExpectedVersionError is returned by Client.AppendToStream when the stream is
+// not at the expected revision
+type WrongExpectedVersionError struct {
+	StreamID string
+	// Actual is the stream's current revision, or nil when the stream does not exist
+	Actual *uint64
+}
+
+func (e *WrongExpectedVersionError) Error() string {
+	if e.Actual == nil {
+		return fmt.Sprintf("wrong expected version for %s: stream does not exist", e.StreamID)
+	}
+	return fmt.Sprintf("wrong expected version for %s: stream is at revision %d", e.StreamID, *e.Actual)
+}
@@ -0,0 +1,273 @@
+// Package esdbstore adapts an EventStoreDB (Kurrent) database to the Store interface,
+// so applications can move from the in-memory store to a production-grade log
+// without changing aggregate code.
+//
+// Each aggregate stream maps to a database stream of the same name. Versions are
+// 1-based and database revisions 0-based, so version n is stored at revision n-1 and
+// every append carries the expected revision of the previous event; a concurrent
+// writer makes the database reject the append, which surfaces as a
+// *common.ConcurrencyError. Note that stream names starting with "$" are reserved
+// for system streams by the database, so internal streams such as "$commands" need
+// a different name when this backend is used.
+//
+// The store talks to the database through the Client interface, a flattened subset
+// of the official gRPC client. This module does not depend on that client; a small
+// wrapper around esdb.Client implementing Client connects the two.
+package esdbstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"simple-event-modeling/common"
+)
+
+// ESDBStore is a Store backed by EventStoreDB
+type ESDBStore struct {
+	client  Client
+	timeout time.Duration
+}
+
+var (
+	_ common.Store          = (*ESDBStore)(nil)
+	_ common.CategoryReader = (*ESDBStore)(nil)
+)
+
+// CategoryStreamPrefix starts the names of the streams the database's $by_category
+// system projection links the events of each category into
+const CategoryStreamPrefix = "$ce-"
+
+// DefaultTimeout bounds each call to the database
+const DefaultTimeout = 10 * time.Second
+
+// effectiveAtKey carries Event.EffectiveAt in the event metadata, which is the only
+// place EventStoreDB keeps caller-defined fields besides the payload
+const effectiveAtKey = "$effective_at"
+
+// New creates a store on top of a database client
+func New(client Client) *ESDBStore {
+	return &ESDBStore{client: client, timeout: DefaultTimeout}
+}
+
+// Append writes an event at the revision following the stream's current version.
+// If the stream has moved on, a *common.ConcurrencyError is returned.
+func (s *ESDBStore) Append(event *common.Event) error {
+	contentType, data := JSONContentType, event.Payload
+	if event.Payload != nil {
+		contentType = event.ContentType
+	} else {
+		var err error
+		if data, err = json.Marshal(event.Data); err != nil {
+			return err
+		}
+	}
+	fields := event.Metadata
+	if event.EffectiveAt != nil {
+		fields = make(map[string]interface{}, len(event.Metadata)+1)
+		for key, value := range event.Metadata {
+			fields[key] = value
+		}
+		fields[effectiveAtKey] = event.EffectiveAt.Format(time.RFC3339Nano)
+	}
+	metadata, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+
+	expected := ExpectedRevision{NoStream: event.Version <= 1}
+	if event.Version > 1 {
+		expected.Revision = uint64(event.Version - 2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	err = s.client.AppendToStream(ctx, event.AggregateID, expected, EventData{
+		EventID:     event.ID,
+		EventType:   event.Type,
+		ContentType: contentType,
+		Data:        data,
+		Metadata:    metadata,
+	})
+
+	var wrongVersion *WrongExpectedVersionError
+	if errors.As(err, &wrongVersion) {
+		actual := 0
+		if wrongVersion.Actual != nil {
+			actual = int(*wrongVersion.Actual) + 1
+		}
+		return &common.ConcurrencyError{StreamID: event.AggregateID, Expected: event.Version - 1, Actual: actual}
+	}
+	return err
+}
+
+// GetStream retrieves all events for a given aggregate ID
+func (s *ESDBStore) GetStream(aggregateID string) ([]*common.Event, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	recorded, err := s.client.ReadStream(ctx, aggregateID)
+	if errors.Is(err, ErrStreamNotFound) || (err == nil && len(recorded) == 0) {
+		return nil, &common.StreamNotFoundError{StreamID: aggregateID}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toEvents(recorded)
+}
+
+// GetStreamVersion returns the current version of a stream
+func (s *ESDBStore) GetStreamVersion(aggregateID string) int {
+	head, err := s.HeadEvent(aggregateID)
+	if err != nil {
+		return 0
+	}
+	return head.Version
+}
+
+// StreamLength returns the number of events in a stream, which the store never
+// truncates, so it equals the stream's version
+func (s *ESDBStore) StreamLength(aggregateID string) int {
+	return s.GetStreamVersion(aggregateID)
+}
+
+// HeadEvent returns the last event of a stream, reading it backwards with a count of 1
+func (s *ESDBStore) HeadEvent(aggregateID string) (*common.Event, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	recorded, err := s.client.ReadLastEvent(ctx, aggregateID)
+	if errors.Is(err, ErrStreamNotFound) {
+		return nil, &common.StreamNotFoundError{StreamID: aggregateID}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toEvent(recorded)
+}
+
+// GetAllEvents returns every event in the order the database committed them
+func (s *ESDBStore) GetAllEvents() []*common.Event {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	recorded, err := s.client.ReadAll(ctx)
+	if err != nil {
+		return nil
+	}
+	events, _ := toEvents(recorded)
+	return events
+}
+
+// ReadCategory reads the $ce-<category> stream, which holds the events of every
+// "<category>-<id>" stream once the $by_category projection is enabled. A category
+// without events yields none.
+func (s *ESDBStore) ReadCategory(category string) ([]*common.Event, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	recorded, err := s.client.ReadStream(ctx, CategoryStreamPrefix+category)
+	if errors.Is(err, ErrStreamNotFound) {
+		return []*common.Event{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toEvents(recorded)
+}
+
+// StreamIDs returns the identifiers of all streams in the store, sorted
+func (s *ESDBStore) StreamIDs() []string {
+	seen := make(map[string]bool)
+	for _, event := range s.GetAllEvents() {
+		seen[event.AggregateID] = true
+	}
+	ids := make([]string, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// SubscribeStream follows a stream with a catch-up subscription, delivering every
+// event with a version greater than fromVersion. Unlike common.SubscribeStream it is
+// pushed by the database rather than polled. The channel is closed when ctx is done
+// or the subscription drops.
+func (s *ESDBStore) SubscribeStream(ctx context.Context, streamID string, fromVersion int) (<-chan *common.Event, error) {
+	var from *uint64
+	if fromVersion > 0 {
+		revision := uint64(fromVersion - 1)
+		from = &revision
+	}
+	recorded, err := s.client.SubscribeToStream(ctx, streamID, from)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan *common.Event)
+	go func() {
+		defer close(out)
+		for r := range recorded {
+			event, err := toEvent(r)
+			if err != nil {
+				return
+			}
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func toEvents(recorded []RecordedEvent) ([]*common.Event, error) {
+	events := make([]*common.Event, 0, len(recorded))
+	for _, r := range recorded {
+		event, err := toEvent(r)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func toEvent(r RecordedEvent) (*common.Event, error) {
+	event := &common.Event{
+		ID:          r.EventID,
+		Type:        r.EventType,
+		CreatedAt:   r.Created,
+		AggregateID: r.StreamID,
+		Version:     int(r.Revision) + 1,
+		Data:        make(map[string]interface{}),
+		Metadata:    make(map[string]interface{}),
+	}
+	switch {
+	case r.ContentType != "" && r.ContentType != JSONContentType:
+		event.Payload = r.Data
+		event.ContentType = r.ContentType
+	case len(r.Data) > 0:
+		if err := json.Unmarshal(r.Data, &event.Data); err != nil {
+			return nil, err
+		}
+	}
+	if len(r.Metadata) > 0 {
+		if err := json.Unmarshal(r.Metadata, &event.Metadata); err != nil {
+			return nil, err
+		}
+	}
+	if effectiveAt, ok := event.Metadata[effectiveAtKey].(string); ok {
+		t, err := time.Parse(time.RFC3339Nano, effectiveAt)
+		if err != nil {
+			return nil, err
+		}
+		event.EffectiveAt = &t
+		delete(event.Metadata, effectiveAtKey)
+	}
+	return event, nil
+}
@@ -0,0 +1,198 @@
+package esdbstore
+
+import (
+	"context"
+	"errors"
+	"simple-event-modeling/common"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClient keeps streams in memory with the database's revision semantics, linking
+// events into $ce- category streams as the $by_category projection does
+type fakeClient struct {
+	mu      sync.Mutex
+	streams map[string][]RecordedEvent
+	all     []RecordedEvent
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{streams: make(map[string][]RecordedEvent)}
+}
+
+func (c *fakeClient) AppendToStream(_ context.Context, streamID string, expected ExpectedRevision, events ...EventData) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stream := c.streams[streamID]
+	if expected.NoStream != (len(stream) == 0) || (!expected.NoStream && expected.Revision != uint64(len(stream)-1)) {
+		err := &WrongExpectedVersionError{StreamID: streamID}
+		if len(stream) > 0 {
+			actual := uint64(len(stream) - 1)
+			err.Actual = &actual
+		}
+		return err
+	}
+	for _, event := range events {
+		recorded := RecordedEvent{
+			StreamID:    streamID,
+			Revision:    uint64(len(c.streams[streamID])),
+			EventID:     event.EventID,
+			EventType:   event.EventType,
+			ContentType: event.ContentType,
+			Data:        event.Data,
+			Metadata:    event.Metadata,
+			Created:     time.Now(),
+		}
+		c.streams[streamID] = append(c.streams[streamID], recorded)
+		c.all = append(c.all, recorded)
+		if category, _, found := strings.Cut(streamID, "-"); found {
+			c.streams[CategoryStreamPrefix+category] = append(c.streams[CategoryStreamPrefix+category], recorded)
+		}
+	}
+	return nil
+}
+
+func (c *fakeClient) ReadStream(_ context.Context, streamID string) ([]RecordedEvent, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stream, exists := c.streams[streamID]
+	if !exists {
+		return nil, ErrStreamNotFound
+	}
+	return append([]RecordedEvent(nil), stream...), nil
+}
+
+func (c *fakeClient) ReadLastEvent(_ context.Context, streamID string) (RecordedEvent, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stream := c.streams[streamID]
+	if len(stream) == 0 {
+		return RecordedEvent{}, ErrStreamNotFound
+	}
+	return stream[len(stream)-1], nil
+}
+
+func (c *fakeClient) ReadAll(context.Context) ([]RecordedEvent, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]RecordedEvent(nil), c.all...), nil
+}
+
+func (c *fakeClient) SubscribeToStream(ctx context.Context, streamID string, from *uint64) (<-chan RecordedEvent, error) {
+	stream, _ := c.ReadStream(ctx, streamID)
+	out := make(chan RecordedEvent, len(stream))
+	for _, event := range stream {
+		if from == nil || event.Revision > *from {
+			out <- event
+		}
+	}
+	close(out)
+	return out, nil
+}
+
+func TestESDBStore_AppendAndRead(t *testing.T) {
+	store := New(newFakeClient())
+	created := common.NewEvent("CartCreated", "cart-1", 1, nil, nil)
+	store.Append(created)
+	store.Append(common.NewEvent("ItemAdded", "cart-1", 2, map[string]interface{}{"item": "apple"}, map[string]interface{}{"user": "u-1"}))
+	backdated := common.NewEvent("CartCreated", "cart-2", 1, nil, nil)
+	effectiveAt := backdated.CreatedAt.Add(-time.Hour)
+	backdated.EffectiveAt = &effectiveAt
+	store.Append(backdated)
+
+	events, err := store.GetStream("cart-1")
+	if err != nil {
+		t.Fatalf("Error getting stream: %v", err)
+	}
+	if len(events) != 2 || events[0].ID != created.ID || events[1].Version != 2 || events[1].Data["item"] != "apple" || events[1].Metadata["user"] != "u-1" {
+		t.Errorf("Expected events to round-trip, got %+v", events)
+	}
+	if events[0].EffectiveAt != nil {
+		t.Errorf("Expected no effective time, got %v", events[0].EffectiveAt)
+	}
+	events, _ = store.GetStream("cart-2")
+	if events[0].EffectiveAt == nil || !events[0].EffectiveAt.Equal(effectiveAt) || len(events[0].Metadata) != 0 {
+		t.Errorf("Expected the effective time to round-trip outside the metadata, got %v %v", events[0].EffectiveAt, events[0].Metadata)
+	}
+	if version := store.GetStreamVersion("cart-1"); version != 2 {
+		t.Errorf("Expected cart-1 version 2, got %d", version)
+	}
+	if ids := store.StreamIDs(); len(ids) != 2 || ids[1] != "cart-2" {
+		t.Errorf("Unexpected stream IDs: %v", ids)
+	}
+
+	var notFound *common.StreamNotFoundError
+	if _, err := store.GetStream("missing"); !errors.As(err, &notFound) {
+		t.Errorf("Expected StreamNotFoundError, got %v", err)
+	}
+}
+
+func TestESDBStore_MapsWrongExpectedVersion(t *testing.T) {
+	store := New(newFakeClient())
+	store.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+
+	var conflict *common.ConcurrencyError
+	err := store.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+	if !errors.As(err, &conflict) || conflict.Expected != 0 || conflict.Actual != 1 {
+		t.Errorf("Expected ConcurrencyError at version 1, got %v", err)
+	}
+	err = store.Append(common.NewEvent("ItemAdded", "cart-2", 2, nil, nil))
+	if !errors.As(err, &conflict) || conflict.Actual != 0 {
+		t.Errorf("Expected ConcurrencyError for a missing stream, got %v", err)
+	}
+}
+
+func TestESDBStore_SubscribeStream(t *testing.T) {
+	store := New(newFakeClient())
+	for version := 1; version <= 3; version++ {
+		store.Append(common.NewEvent("Ticked", "clock", version, nil, nil))
+	}
+
+	events, err := store.SubscribeStream(context.Background(), "clock", 1)
+	if err != nil {
+		t.Fatalf("Error subscribing: %v", err)
+	}
+	var versions []int
+	for event := range events {
+		versions = append(versions, event.Version)
+	}
+	if len(versions) != 2 || versions[0] != 2 || versions[1] != 3 {
+		t.Errorf("Expected versions 2 and 3, got %v", versions)
+	}
+}
+
+func TestESDBStore_BinaryPayload(t *testing.T) {
+	client := newFakeClient()
+	store := New(client)
+	store.Append(common.NewBinaryEvent("ItemAdded", "cart-1", 1, "application/protobuf", []byte{0x0a, 0x05}, nil))
+
+	if recorded, _ := client.ReadStream(context.Background(), "cart-1"); recorded[0].ContentType != "application/protobuf" {
+		t.Errorf("Expected the payload to be written as binary, got %q", recorded[0].ContentType)
+	}
+	events, _ := store.GetStream("cart-1")
+	if string(events[0].Payload) != "\x0a\x05" || events[0].ContentType != "application/protobuf" || len(events[0].Data) != 0 {
+		t.Errorf("Expected the binary payload to round-trip, got %+v", events[0])
+	}
+}
+
+func TestESDBStore_ReadCategory(t *testing.T) {
+	store := New(newFakeClient())
+	store.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+	store.Append(common.NewEvent("WishlistCreated", "wishlist-1", 1, nil, nil))
+	store.Append(common.NewEvent("CartCreated", "cart-2", 1, nil, nil))
+	store.Append(common.NewEvent("ItemAdded", "cart-1", 2, nil, nil))
+
+	events, err := common.ReadCategory(store, common.DefaultStreamNamer, "cart")
+	if err != nil {
+		t.Fatalf("Error reading category: %v", err)
+	}
+	if len(events) != 3 || events[1].AggregateID != "cart-2" || events[2].Version != 2 {
+		t.Errorf("Expected the cart events in append order with their own versions, got %+v", events)
+	}
+	if events, err := store.ReadCategory("order"); err != nil || len(events) != 0 {
+		t.Errorf("Expected no events for an empty category, got %v, %v", events, err)
+	}
+}
@@ -29,8 +29,8 @@ func demonstrateEventReplay() {
 		// Add items to each cart
 		for j := 1; j <= 2; j++ {
 			addCmd := &cart.AddItemCommand{
-				AggregateID: event.AggregateID,
-				ItemID:      fmt.Sprintf("product-%d-%d", i, j),
+				CartID: event.AggregateID,
+				ItemID: fmt.Sprintf("product-%d-%d", i, j),
 			}
 			cartAggregate.Handle(addCmd)
 		}
@@ -75,8 +75,8 @@ func demonstrateBusinessRules() {
 	// Add items up to the limit
 	for i := 1; i <= 3; i++ {
 		addCmd := &cart.AddItemCommand{
-			AggregateID: cartID,
-			ItemID:      fmt.Sprintf("item-%d", i),
+			CartID: cartID,
+			ItemID: fmt.Sprintf("item-%d", i),
 		}
 		_, err := cartAggregate.Handle(addCmd)
 		if err != nil {
@@ -88,8 +88,8 @@ func demonstrateBusinessRules() {
 
 	// Try to add one more (should fail)
 	addCmd := &cart.AddItemCommand{
-		AggregateID: cartID,
-		ItemID:      "item-4",
+		CartID: cartID,
+		ItemID: "item-4",
 	}
 	_, err = cartAggregate.Handle(addCmd)
 	if err != nil {
@@ -100,8 +100,8 @@ func demonstrateBusinessRules() {
 
 	// Test removing non-existent item
 	removeCmd := &cart.RemoveItemCommand{
-		AggregateID: cartID,
-		ItemID:      "non-existent-item",
+		CartID: cartID,
+		ItemID: "non-existent-item",
 	}
 	_, err = cartAggregate.Handle(removeCmd)
 	if err != nil {
@@ -129,13 +129,13 @@ func demonstrateEventSourcing() {
 	// Series of operations
 	operations := []struct {
 		description string
-		command     interface{}
+		command     common.Command
 	}{
-		{"Add Apple", &cart.AddItemCommand{AggregateID: cartID, ItemID: "apple"}},
-		{"Add Banana", &cart.AddItemCommand{AggregateID: cartID, ItemID: "banana"}},
-		{"Add Orange", &cart.AddItemCommand{AggregateID: cartID, ItemID: "orange"}},
-		{"Remove Banana", &cart.RemoveItemCommand{AggregateID: cartID, ItemID: "banana"}},
-		{"Add Grape", &cart.AddItemCommand{AggregateID: cartID, ItemID: "grape"}},
+		{"Add Apple", &cart.AddItemCommand{CartID: cartID, ItemID: "apple"}},
+		{"Add Banana", &cart.AddItemCommand{CartID: cartID, ItemID: "banana"}},
+		{"Add Orange", &cart.AddItemCommand{CartID: cartID, ItemID: "orange"}},
+		{"Remove Banana", &cart.RemoveItemCommand{CartID: cartID, ItemID: "banana"}},
+		{"Add Grape", &cart.AddItemCommand{CartID: cartID, ItemID: "grape"}},
 	}
 
 	for i, op := range operations {
@@ -171,15 +171,11 @@ func demonstrateEventSourcing() {
 	// Demonstrate point-in-time reconstruction
 	fmt.Printf("\nDemonstrating point-in-time reconstruction:\n")
 
-	// Replay only first 3 events
+	// Replay only the first 3 events, as a read-only view of the cart
 	partialCart := cart.NewCartAggregate(store)
-	partialEvents := events[:3] // First 3 events
-
-	// We need to manually replay since we can't easily limit Hydrate
-	for _, event := range partialEvents {
-		partialCart.On(event)
+	if err := partialCart.HydrateUpTo(cartID, 3); err != nil {
+		log.Fatal("Error hydrating cart:", err)
 	}
-	partialCart.SetLive(true)
 
 	fmt.Printf("State after first 3 events:\n")
 	partialItems := partialCart.Items()
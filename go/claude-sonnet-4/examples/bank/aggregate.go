@@ -0,0 +1,194 @@
+// Package bank provides the AccountAggregate implementation for the bank domain.
+package bank
+
+import (
+	"errors"
+	"fmt"
+
+	"simple-event-modeling/common"
+
+	"github.com/google/uuid"
+)
+
+// OverdraftFee is charged by every withdrawal that leaves the balance below zero
+const OverdraftFee int64 = 500
+
+// AccountAggregate handles command validation and appends events to the store if
+// commands are valid. A single command may produce several events, e.g. a withdrawal
+// into overdraft also charges a fee; they are applied and appended in order.
+type AccountAggregate struct {
+	*common.BaseAggregate
+	owner          string
+	balance        int64
+	overdraftLimit int64
+	closed         bool
+}
+
+// NewAccountAggregate creates a new account aggregate
+func NewAccountAggregate(store common.Store) *AccountAggregate {
+	return &AccountAggregate{BaseAggregate: common.NewBaseAggregate(store)}
+}
+
+// Owner returns the account owner
+func (a *AccountAggregate) Owner() string {
+	return a.owner
+}
+
+// Balance returns the balance in cents
+func (a *AccountAggregate) Balance() int64 {
+	return a.balance
+}
+
+// IsClosed reports whether the account has been closed
+func (a *AccountAggregate) IsClosed() bool {
+	return a.closed
+}
+
+// Handle processes a command and returns the last resulting event
+func (a *AccountAggregate) Handle(command common.Command) (*common.Event, error) {
+	events, err := a.HandleAll(command)
+	if err != nil {
+		return nil, err
+	}
+	return events[len(events)-1], nil
+}
+
+// HandleAll processes a command and returns every resulting event. The events are
+// committed together through a unit of work, so with a store that appends batches
+// atomically a failure stores none of them, e.g. no overdraft fee without its
+// withdrawal.
+func (a *AccountAggregate) HandleAll(command common.Command) ([]*common.Event, error) {
+	if aggregateID := command.AggregateID(); aggregateID != "" && !a.IsLive() {
+		if err := a.Hydrate(aggregateID); err != nil {
+			return nil, err
+		}
+	}
+
+	var events []*common.Event
+	var err error
+	switch cmd := command.(type) {
+	case *OpenAccountCommand:
+		events, err = a.handleOpenAccount(cmd)
+	case *DepositCommand:
+		events, err = a.handleDeposit(cmd)
+	case *WithdrawCommand:
+		events, err = a.handleWithdraw(cmd)
+	case *CloseAccountCommand:
+		events, err = a.handleCloseAccount()
+	default:
+		return nil, &common.UnknownCommandError{
+			CommandType: command.CommandType(),
+			Registered:  common.DefaultRegistry.CommandTypes(AggregateTypeAccount),
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	uow := common.NewUnitOfWork(a.Store(), nil)
+	for _, event := range events {
+		if err := a.On(event); err != nil {
+			return nil, err
+		}
+		if err := uow.Append(event); err != nil {
+			return nil, err
+		}
+	}
+	if err := uow.Commit(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// On applies events to aggregate state
+func (a *AccountAggregate) On(event *common.Event) error {
+	switch event.Type {
+	case EventTypeAccountOpened:
+		a.Begin(event)
+		a.owner, _ = event.Data["owner"].(string)
+		a.overdraftLimit = amountOf(event.Data, "overdraft_limit")
+	case EventTypeMoneyDeposited:
+		a.balance += amountOf(event.Data, "amount")
+	case EventTypeMoneyWithdrawn, EventTypeOverdraftFeeCharged:
+		a.balance -= amountOf(event.Data, "amount")
+	case EventTypeAccountClosed:
+		a.closed = true
+	default:
+		return errors.New("unhandled event type: " + event.Type)
+	}
+	return a.Advance(event)
+}
+
+// Hydrate rebuilds the aggregate state from its event stream
+func (a *AccountAggregate) Hydrate(id string) error {
+	return a.BaseAggregate.Hydrate(id, a.On)
+}
+
+// Command handlers decide which events a command produces without applying them
+
+func (a *AccountAggregate) handleOpenAccount(cmd *OpenAccountCommand) ([]*common.Event, error) {
+	if cmd.OverdraftLimit < 0 {
+		return nil, &common.InvalidCommandError{Message: "overdraft limit cannot be negative"}
+	}
+	return []*common.Event{NewAccountOpenedEvent(uuid.New().String(), cmd.Owner, cmd.OverdraftLimit)}, nil
+}
+
+func (a *AccountAggregate) handleDeposit(cmd *DepositCommand) ([]*common.Event, error) {
+	if err := a.checkOpen(); err != nil {
+		return nil, err
+	}
+	return []*common.Event{NewMoneyDepositedEvent(a.ID(), a.Version()+1, cmd.Amount)}, nil
+}
+
+func (a *AccountAggregate) handleWithdraw(cmd *WithdrawCommand) ([]*common.Event, error) {
+	if err := a.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	// Business rule: the balance, including any overdraft fee, may not drop below
+	// the overdraft limit
+	remaining := a.balance - cmd.Amount
+	fee := int64(0)
+	if remaining < 0 {
+		fee = OverdraftFee
+	}
+	if remaining-fee < -a.overdraftLimit {
+		return nil, &common.InvalidCommandError{
+			Message: fmt.Sprintf("withdrawal of %d exceeds the available balance of %d", cmd.Amount, a.balance+a.overdraftLimit),
+		}
+	}
+
+	events := []*common.Event{NewMoneyWithdrawnEvent(a.ID(), a.Version()+1, cmd.Amount)}
+	if fee > 0 {
+		events = append(events, NewOverdraftFeeChargedEvent(a.ID(), a.Version()+2, fee))
+	}
+	return events, nil
+}
+
+func (a *AccountAggregate) handleCloseAccount() ([]*common.Event, error) {
+	if err := a.checkOpen(); err != nil {
+		return nil, err
+	}
+	if a.balance < 0 {
+		return nil, &common.InvalidCommandError{Message: "cannot close an overdrawn account"}
+	}
+
+	// Pay out the remaining balance before closing
+	var events []*common.Event
+	version := a.Version()
+	if a.balance > 0 {
+		version++
+		events = append(events, NewMoneyWithdrawnEvent(a.ID(), version, a.balance))
+	}
+	return append(events, NewAccountClosedEvent(a.ID(), version+1)), nil
+}
+
+func (a *AccountAggregate) checkOpen() error {
+	if !a.IsLive() || a.ID() == "" {
+		return &common.InvalidCommandError{Message: "account not opened"}
+	}
+	if a.closed {
+		return &common.InvalidCommandError{Message: "account is closed"}
+	}
+	return nil
+}
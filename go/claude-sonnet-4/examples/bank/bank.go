@@ -0,0 +1,13 @@
+// Package bank provides a bank account domain built on the common framework.
+// It is a second example domain beside the cart, exercising commands that produce
+// several events and invariants over monetary amounts.
+//
+// Amounts are int64 minor units (cents) so balances never suffer rounding errors.
+//
+// The package is organized into separate files for each major concept:
+// - commands.go: Command types (OpenAccount, Deposit, Withdraw, CloseAccount)
+// - events.go: Event types and creation functions (AccountOpened, MoneyDeposited, etc.)
+// - aggregate.go: AccountAggregate implementation with business rules
+// - registry.go: Registration with the common registry
+// - bus.go: Registration of command handlers with a command bus
+package bank
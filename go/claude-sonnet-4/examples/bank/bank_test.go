@@ -0,0 +1,162 @@
+package bank
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+	"simple-event-modeling/faultstore"
+	"simple-event-modeling/filestore"
+	"testing"
+)
+
+func openAccount(t *testing.T, store common.Store, overdraftLimit int64) string {
+	t.Helper()
+	event, err := NewAccountAggregate(store).Handle(&OpenAccountCommand{Owner: "alice", OverdraftLimit: overdraftLimit})
+	if err != nil {
+		t.Fatalf("Error opening account: %v", err)
+	}
+	return event.AggregateID
+}
+
+func TestAccountAggregate_DepositAndWithdraw(t *testing.T) {
+	store := common.NewEventStore()
+	id := openAccount(t, store, 0)
+
+	NewAccountAggregate(store).Handle(&DepositCommand{AccountID: id, Amount: 10000})
+	if _, err := NewAccountAggregate(store).Handle(&WithdrawCommand{AccountID: id, Amount: 2500}); err != nil {
+		t.Fatalf("Error withdrawing: %v", err)
+	}
+
+	_, err := NewAccountAggregate(store).Handle(&WithdrawCommand{AccountID: id, Amount: 7501})
+	var invalid *common.InvalidCommandError
+	if !errors.As(err, &invalid) {
+		t.Errorf("Expected withdrawal beyond the balance to be rejected, got %v", err)
+	}
+
+	account := NewAccountAggregate(store)
+	account.Hydrate(id)
+	if account.Balance() != 7500 || account.Version() != 3 {
+		t.Errorf("Expected balance 7500 at version 3, got %d at %d", account.Balance(), account.Version())
+	}
+}
+
+func TestAccountAggregate_OverdraftChargesFee(t *testing.T) {
+	store := common.NewEventStore()
+	id := openAccount(t, store, 5000)
+	NewAccountAggregate(store).Handle(&DepositCommand{AccountID: id, Amount: 1000})
+
+	events, err := NewAccountAggregate(store).HandleAll(&WithdrawCommand{AccountID: id, Amount: 3000})
+	if err != nil {
+		t.Fatalf("Error withdrawing into overdraft: %v", err)
+	}
+	if len(events) != 2 || events[0].Type != EventTypeMoneyWithdrawn || events[1].Type != EventTypeOverdraftFeeCharged {
+		t.Fatalf("Expected MoneyWithdrawn and OverdraftFeeCharged, got %v", events)
+	}
+	if events[1].Version != events[0].Version+1 {
+		t.Errorf("Expected consecutive versions, got %d and %d", events[0].Version, events[1].Version)
+	}
+
+	// -2500 balance; a 2001 withdrawal plus the fee would pass the -5000 limit
+	if _, err := NewAccountAggregate(store).Handle(&WithdrawCommand{AccountID: id, Amount: 2001}); err == nil {
+		t.Error("Expected withdrawal past the overdraft limit to be rejected")
+	}
+
+	account := NewAccountAggregate(store)
+	account.Hydrate(id)
+	if account.Balance() != -2500 {
+		t.Errorf("Expected balance -2500, got %d", account.Balance())
+	}
+	if _, err := account.Handle(&CloseAccountCommand{AccountID: id}); err == nil {
+		t.Error("Expected closing an overdrawn account to be rejected")
+	}
+}
+
+func TestAccountAggregate_OverdraftCommitsAtomically(t *testing.T) {
+	store := faultstore.New(common.NewEventStore(), faultstore.Config{})
+	id := openAccount(t, store, 5000)
+	NewAccountAggregate(store).Handle(&DepositCommand{AccountID: id, Amount: 1000})
+
+	// The withdrawal would be stored and its overdraft fee fail
+	store.FailAppendAfter(1)
+	if _, err := NewAccountAggregate(store).HandleAll(&WithdrawCommand{AccountID: id, Amount: 3000}); !errors.Is(err, faultstore.ErrTransient) {
+		t.Fatalf("Expected the injected failure, got %v", err)
+	}
+	account := NewAccountAggregate(store)
+	account.Hydrate(id)
+	if account.Balance() != 1000 || account.Version() != 2 {
+		t.Errorf("Expected neither the withdrawal nor the fee stored, got balance %d at version %d", account.Balance(), account.Version())
+	}
+
+	if _, err := NewAccountAggregate(store).HandleAll(&WithdrawCommand{AccountID: id, Amount: 3000}); err != nil {
+		t.Fatalf("Expected the retry to succeed, got %v", err)
+	}
+	account = NewAccountAggregate(store)
+	account.Hydrate(id)
+	if account.Balance() != -2500 || account.Version() != 4 {
+		t.Errorf("Expected the withdrawal and fee stored together, got balance %d at version %d", account.Balance(), account.Version())
+	}
+}
+
+func TestAccountAggregate_ClosePaysOutBalance(t *testing.T) {
+	store := common.NewEventStore()
+	id := openAccount(t, store, 0)
+	NewAccountAggregate(store).Handle(&DepositCommand{AccountID: id, Amount: 4200})
+
+	events, err := NewAccountAggregate(store).HandleAll(&CloseAccountCommand{AccountID: id})
+	if err != nil {
+		t.Fatalf("Error closing account: %v", err)
+	}
+	if len(events) != 2 || events[0].Type != EventTypeMoneyWithdrawn || events[1].Type != EventTypeAccountClosed {
+		t.Fatalf("Expected payout then AccountClosed, got %v", events)
+	}
+
+	account := NewAccountAggregate(store)
+	account.Hydrate(id)
+	if !account.IsClosed() || account.Balance() != 0 {
+		t.Errorf("Expected closed account with zero balance, got closed=%v balance=%d", account.IsClosed(), account.Balance())
+	}
+	if _, err := account.Handle(&DepositCommand{AccountID: id, Amount: 100}); err == nil {
+		t.Error("Expected deposits to a closed account to be rejected")
+	}
+}
+
+func TestAccountAggregate_HydratesFromDurableStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	store, err := filestore.Open(path)
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+	id := openAccount(t, store, 1000)
+	NewAccountAggregate(store).Handle(&DepositCommand{AccountID: id, Amount: 250})
+
+	reopened, err := filestore.Open(path)
+	if err != nil {
+		t.Fatalf("Error reopening store: %v", err)
+	}
+	account := NewAccountAggregate(reopened)
+	account.Hydrate(id)
+	if account.Balance() != 250 || account.Owner() != "alice" {
+		t.Errorf("Expected alice's balance of 250 after reload, got %s %d", account.Owner(), account.Balance())
+	}
+	if _, err := account.Handle(&WithdrawCommand{AccountID: id, Amount: 1251}); err == nil {
+		t.Error("Expected the reloaded overdraft limit to be enforced")
+	}
+}
+
+func TestRegisterCommands_ValidatesAmounts(t *testing.T) {
+	store := common.NewEventStore()
+	commands := bus.NewCommandBus(bus.Validation())
+	RegisterCommands(commands, store)
+
+	opened, err := commands.Dispatch(context.Background(), &OpenAccountCommand{Owner: "alice"})
+	if err != nil {
+		t.Fatalf("Error opening account: %v", err)
+	}
+	_, err = commands.Dispatch(context.Background(), &DepositCommand{AccountID: opened.AggregateID, Amount: -100})
+	var validationErr *common.ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Fields[0].Field != "amount" {
+		t.Errorf("Expected amount to fail validation, got %v", err)
+	}
+}
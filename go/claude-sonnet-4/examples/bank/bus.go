@@ -0,0 +1,21 @@
+// Package bank registers the account command handlers with a command bus.
+package bank
+
+import (
+	"context"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+)
+
+// RegisterCommands registers a handler for every account command.
+// Each command is handled by a fresh aggregate hydrated from the store.
+func RegisterCommands(commands *bus.CommandBus, store common.Store) {
+	handle := func(_ context.Context, command common.Command) (*common.Event, error) {
+		return NewAccountAggregate(store).Handle(command)
+	}
+	commands.Register(CommandTypeOpenAccount, handle)
+	commands.Register(CommandTypeDeposit, handle)
+	commands.Register(CommandTypeWithdraw, handle)
+	commands.Register(CommandTypeCloseAccount, handle)
+}
@@ -0,0 +1,40 @@
+// Package bank provides command types for the bank domain.
+// Commands are simple record structures; their only methods expose the routing
+// metadata required by common.Command.
+package bank
+
+// OpenAccountCommand opens a new account. The account ID is generated.
+type OpenAccountCommand struct {
+	Owner string `json:"owner" validate:"required"`
+	// OverdraftLimit is how far below zero the balance may go, in cents
+	OverdraftLimit int64 `json:"overdraft_limit"`
+}
+
+// DepositCommand adds money to an account
+type DepositCommand struct {
+	AccountID string `json:"aggregate_id" validate:"required,uuid"`
+	Amount    int64  `json:"amount" validate:"positive"`
+}
+
+// WithdrawCommand takes money from an account
+type WithdrawCommand struct {
+	AccountID string `json:"aggregate_id" validate:"required,uuid"`
+	Amount    int64  `json:"amount" validate:"positive"`
+}
+
+// CloseAccountCommand closes an account, paying out any remaining balance
+type CloseAccountCommand struct {
+	AccountID string `json:"aggregate_id" validate:"required,uuid"`
+}
+
+func (c *OpenAccountCommand) AggregateID() string { return "" }
+func (c *OpenAccountCommand) CommandType() string { return CommandTypeOpenAccount }
+
+func (c *DepositCommand) AggregateID() string { return c.AccountID }
+func (c *DepositCommand) CommandType() string { return CommandTypeDeposit }
+
+func (c *WithdrawCommand) AggregateID() string { return c.AccountID }
+func (c *WithdrawCommand) CommandType() string { return CommandTypeWithdraw }
+
+func (c *CloseAccountCommand) AggregateID() string { return c.AccountID }
+func (c *CloseAccountCommand) CommandType() string { return CommandTypeCloseAccount }
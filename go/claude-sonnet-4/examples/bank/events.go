@@ -0,0 +1,64 @@
+// Package bank provides event types and creation functions for the bank domain.
+// Events are simple record structures with no behaviors.
+package bank
+
+import (
+	"encoding/json"
+
+	"simple-event-modeling/common"
+)
+
+// Event type constants
+const (
+	EventTypeAccountOpened       = "AccountOpened"
+	EventTypeMoneyDeposited      = "MoneyDeposited"
+	EventTypeMoneyWithdrawn      = "MoneyWithdrawn"
+	EventTypeOverdraftFeeCharged = "OverdraftFeeCharged"
+	EventTypeAccountClosed       = "AccountClosed"
+)
+
+// NewAccountOpenedEvent creates a new AccountOpened event
+func NewAccountOpenedEvent(accountID, owner string, overdraftLimit int64) *common.Event {
+	data := map[string]interface{}{
+		"owner":           owner,
+		"overdraft_limit": overdraftLimit,
+	}
+	return common.NewEvent(EventTypeAccountOpened, accountID, 1, data, nil)
+}
+
+// NewMoneyDepositedEvent creates a new MoneyDeposited event
+func NewMoneyDepositedEvent(accountID string, version int, amount int64) *common.Event {
+	return common.NewEvent(EventTypeMoneyDeposited, accountID, version, map[string]interface{}{"amount": amount}, nil)
+}
+
+// NewMoneyWithdrawnEvent creates a new MoneyWithdrawn event
+func NewMoneyWithdrawnEvent(accountID string, version int, amount int64) *common.Event {
+	return common.NewEvent(EventTypeMoneyWithdrawn, accountID, version, map[string]interface{}{"amount": amount}, nil)
+}
+
+// NewOverdraftFeeChargedEvent creates a new OverdraftFeeCharged event
+func NewOverdraftFeeChargedEvent(accountID string, version int, amount int64) *common.Event {
+	return common.NewEvent(EventTypeOverdraftFeeCharged, accountID, version, map[string]interface{}{"amount": amount}, nil)
+}
+
+// NewAccountClosedEvent creates a new AccountClosed event
+func NewAccountClosedEvent(accountID string, version int) *common.Event {
+	return common.NewEvent(EventTypeAccountClosed, accountID, version, nil, nil)
+}
+
+// amountOf reads an amount from event data. Amounts are int64 when the event was
+// created in process and float64 or json.Number after a round trip through JSON.
+func amountOf(data map[string]interface{}, key string) int64 {
+	switch v := data[key].(type) {
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	case float64:
+		return int64(v)
+	case json.Number:
+		n, _ := v.Int64()
+		return n
+	}
+	return 0
+}
@@ -0,0 +1,59 @@
+// Package bank registers the bank domain with the common registry so tooling
+// can discover its commands, events, and aggregate.
+package bank
+
+import "simple-event-modeling/common"
+
+// AggregateTypeAccount is the registered name of the account aggregate
+const AggregateTypeAccount = "Account"
+
+// Command type names
+const (
+	CommandTypeOpenAccount  = "OpenAccount"
+	CommandTypeDeposit      = "Deposit"
+	CommandTypeWithdraw     = "Withdraw"
+	CommandTypeCloseAccount = "CloseAccount"
+)
+
+func init() {
+	register(common.DefaultRegistry)
+}
+
+func register(registry *common.Registry) {
+	registry.RegisterAggregate(common.AggregateInfo{Name: AggregateTypeAccount})
+
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeOpenAccount,
+		Aggregate: AggregateTypeAccount,
+		Produces:  []string{EventTypeAccountOpened},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeDeposit,
+		Aggregate: AggregateTypeAccount,
+		Produces:  []string{EventTypeMoneyDeposited},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeWithdraw,
+		Aggregate: AggregateTypeAccount,
+		Produces:  []string{EventTypeMoneyWithdrawn, EventTypeOverdraftFeeCharged},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeCloseAccount,
+		Aggregate: AggregateTypeAccount,
+		Produces:  []string{EventTypeMoneyWithdrawn, EventTypeAccountClosed},
+	})
+
+	amountField := common.FieldInfo{Name: "amount", Type: "integer", Description: "Amount in cents"}
+	registry.RegisterEvent(common.EventInfo{
+		Name:      EventTypeAccountOpened,
+		Aggregate: AggregateTypeAccount,
+		Payload: []common.FieldInfo{
+			{Name: "owner", Type: "string", Description: "Account owner"},
+			{Name: "overdraft_limit", Type: "integer", Description: "Overdraft limit in cents"},
+		},
+	})
+	registry.RegisterEvent(common.EventInfo{Name: EventTypeMoneyDeposited, Aggregate: AggregateTypeAccount, Payload: []common.FieldInfo{amountField}})
+	registry.RegisterEvent(common.EventInfo{Name: EventTypeMoneyWithdrawn, Aggregate: AggregateTypeAccount, Payload: []common.FieldInfo{amountField}})
+	registry.RegisterEvent(common.EventInfo{Name: EventTypeOverdraftFeeCharged, Aggregate: AggregateTypeAccount, Payload: []common.FieldInfo{amountField}})
+	registry.RegisterEvent(common.EventInfo{Name: EventTypeAccountClosed, Aggregate: AggregateTypeAccount})
+}
@@ -0,0 +1,140 @@
+// Package inventory provides the OrderAggregate implementation for the inventory domain.
+package inventory
+
+import (
+	"errors"
+
+	"simple-event-modeling/common"
+
+	"github.com/google/uuid"
+)
+
+// Order statuses
+const (
+	StatusPlaced    = "placed"
+	StatusPaid      = "paid"
+	StatusCancelled = "cancelled"
+)
+
+// OrderAggregate tracks an order from placement to payment or cancellation. It
+// knows nothing of stock; the stock manager reserves its lines.
+type OrderAggregate struct {
+	*common.BaseAggregate
+	status string
+	lines  []Line
+	reason string
+}
+
+// NewOrderAggregate creates a new order aggregate
+func NewOrderAggregate(store common.Store) *OrderAggregate {
+	return &OrderAggregate{BaseAggregate: common.NewBaseAggregate(store)}
+}
+
+// Status returns the status of the order, or "" before it is placed
+func (a *OrderAggregate) Status() string {
+	return a.status
+}
+
+// Lines returns the products and quantities ordered
+func (a *OrderAggregate) Lines() []Line {
+	return a.lines
+}
+
+// CancelReason returns why the order was cancelled
+func (a *OrderAggregate) CancelReason() string {
+	return a.reason
+}
+
+// Handle processes commands and returns resulting events
+func (a *OrderAggregate) Handle(command common.Command) (*common.Event, error) {
+	if aggregateID := command.AggregateID(); aggregateID != "" && !a.IsLive() {
+		if err := a.Hydrate(aggregateID); err != nil {
+			return nil, err
+		}
+	}
+
+	var event *common.Event
+	var err error
+	switch cmd := command.(type) {
+	case *PlaceOrderCommand:
+		event = NewOrderPlacedEvent(uuid.New().String(), cmd.Customer, cmd.Lines)
+	case *PayOrderCommand:
+		event, err = a.handlePayOrder()
+	case *CancelOrderCommand:
+		event, err = a.handleCancelOrder(cmd)
+	default:
+		return nil, &common.UnknownCommandError{
+			CommandType: command.CommandType(),
+			Registered:  common.DefaultRegistry.CommandTypes(AggregateTypeOrder),
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.On(event); err != nil {
+		return nil, err
+	}
+	if err := a.Store().Append(event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// On applies events to aggregate state
+func (a *OrderAggregate) On(event *common.Event) error {
+	typed, err := common.AsTyped[OrderData](event)
+	if err != nil {
+		return err
+	}
+	switch event.Type {
+	case EventTypeOrderPlaced:
+		a.Begin(event)
+		a.status = StatusPlaced
+		a.lines = typed.Data.Lines
+	case EventTypeOrderPaid:
+		a.status = StatusPaid
+	case EventTypeOrderCancelled:
+		a.status = StatusCancelled
+		a.reason = typed.Data.Reason
+		a.Close(event.Type)
+	default:
+		return errors.New("unhandled event type: " + event.Type)
+	}
+	return a.Advance(event)
+}
+
+// Hydrate rebuilds the aggregate state from its event stream
+func (a *OrderAggregate) Hydrate(id string) error {
+	return a.BaseAggregate.Hydrate(id, a.On)
+}
+
+// Command handlers
+
+func (a *OrderAggregate) handlePayOrder() (*common.Event, error) {
+	if err := a.checkPlaced(); err != nil {
+		return nil, err
+	}
+	return NewOrderPaidEvent(a.ID(), a.Version()+1), nil
+}
+
+func (a *OrderAggregate) handleCancelOrder(cmd *CancelOrderCommand) (*common.Event, error) {
+	if err := a.checkPlaced(); err != nil {
+		return nil, err
+	}
+	return NewOrderCancelledEvent(a.ID(), a.Version()+1, cmd.Reason), nil
+}
+
+// checkPlaced fails unless the order is placed and neither paid nor cancelled
+func (a *OrderAggregate) checkPlaced() error {
+	if err := a.CheckOpen(); err != nil {
+		return err
+	}
+	switch a.status {
+	case "":
+		return &common.InvalidCommandError{Message: "order not placed"}
+	case StatusPaid:
+		return &common.InvalidCommandError{Message: "order already paid"}
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+// Package inventory registers the order command handlers with a command bus.
+package inventory
+
+import (
+	"context"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+)
+
+// RegisterCommands registers a handler for every order command.
+// Each command is handled by a fresh aggregate hydrated from the store.
+func RegisterCommands(commands *bus.CommandBus, store common.Store) {
+	handle := func(_ context.Context, command common.Command) (*common.Event, error) {
+		return NewOrderAggregate(store).Handle(command)
+	}
+	commands.Register(CommandTypePlaceOrder, handle)
+	commands.Register(CommandTypePayOrder, handle)
+	commands.Register(CommandTypeCancelOrder, handle)
+}
@@ -0,0 +1,36 @@
+// Package inventory provides command types for the inventory domain.
+// Commands are simple record structures; their only methods expose the routing
+// metadata required by common.Command.
+package inventory
+
+// Line is a product and quantity of an order
+type Line struct {
+	SKU      string `json:"sku" validate:"required"`
+	Quantity int    `json:"quantity" validate:"positive"`
+}
+
+// PlaceOrderCommand places an order for products. The order ID is generated.
+type PlaceOrderCommand struct {
+	Customer string `json:"customer" validate:"required"`
+	Lines    []Line `json:"lines" validate:"required"`
+}
+
+// PayOrderCommand records the payment of a placed order
+type PayOrderCommand struct {
+	OrderID string `json:"aggregate_id" validate:"required,uuid"`
+}
+
+// CancelOrderCommand cancels an order that is not paid yet
+type CancelOrderCommand struct {
+	OrderID string `json:"aggregate_id" validate:"required,uuid"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+func (c *PlaceOrderCommand) AggregateID() string { return "" }
+func (c *PlaceOrderCommand) CommandType() string { return CommandTypePlaceOrder }
+
+func (c *PayOrderCommand) AggregateID() string { return c.OrderID }
+func (c *PayOrderCommand) CommandType() string { return CommandTypePayOrder }
+
+func (c *CancelOrderCommand) AggregateID() string { return c.OrderID }
+func (c *CancelOrderCommand) CommandType() string { return CommandTypeCancelOrder }
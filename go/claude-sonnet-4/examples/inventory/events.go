@@ -0,0 +1,44 @@
+// Package inventory provides event types and creation functions for the inventory domain.
+// Events are simple record structures with no behaviors.
+package inventory
+
+import "simple-event-modeling/common"
+
+// Event type constants
+const (
+	EventTypeOrderPlaced    = "OrderPlaced"
+	EventTypeOrderPaid      = "OrderPaid"
+	EventTypeOrderCancelled = "OrderCancelled"
+)
+
+// OrderData is the payload of order events
+type OrderData struct {
+	// Customer and Lines are set on OrderPlaced events
+	Customer string `json:"customer,omitempty"`
+	Lines    []Line `json:"lines,omitempty"`
+	// Reason is why an order was cancelled, e.g. out of stock
+	Reason string `json:"reason,omitempty"`
+}
+
+// NewOrderPlacedEvent creates a new OrderPlaced event
+func NewOrderPlacedEvent(orderID, customer string, lines []Line) *common.Event {
+	encoded := make([]interface{}, len(lines))
+	for i, line := range lines {
+		encoded[i] = map[string]interface{}{"sku": line.SKU, "quantity": line.Quantity}
+	}
+	data := map[string]interface{}{
+		"customer": customer,
+		"lines":    encoded,
+	}
+	return common.NewEvent(EventTypeOrderPlaced, orderID, 1, data, nil)
+}
+
+// NewOrderPaidEvent creates a new OrderPaid event
+func NewOrderPaidEvent(orderID string, version int) *common.Event {
+	return common.NewEvent(EventTypeOrderPaid, orderID, version, nil, nil)
+}
+
+// NewOrderCancelledEvent creates a new OrderCancelled event
+func NewOrderCancelledEvent(orderID string, version int, reason string) *common.Event {
+	return common.NewEvent(EventTypeOrderCancelled, orderID, version, map[string]interface{}{"reason": reason}, nil)
+}
@@ -0,0 +1,17 @@
+// Package inventory provides an order domain built on the common framework that
+// never oversells stock. Orders are separate aggregates, so no order can see how
+// much stock the others took; instead each line reserves units of its product
+// through the reservations package, whose limit is the stock on hand:
+//   - OrderPlaced reserves every line and cancels the order when a product is out
+//     of stock
+//   - OrderPaid confirms the reservations, and OrderCancelled cancels them
+//   - a reservation expiring because the order was not paid in time cancels the order
+//
+// The package is organized into separate files for each major concept:
+// - commands.go: Command types (PlaceOrder, PayOrder, CancelOrder)
+// - events.go: Event types and creation functions (OrderPlaced, OrderPaid, OrderCancelled)
+// - aggregate.go: OrderAggregate implementation with the order rules
+// - stock.go: Process manager reserving stock for orders
+// - registry.go: Registration with the common registry
+// - bus.go: Registration of command handlers with a command bus
+package inventory
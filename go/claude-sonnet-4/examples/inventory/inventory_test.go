@@ -0,0 +1,103 @@
+package inventory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+	"simple-event-modeling/process"
+	"simple-event-modeling/reservations"
+	"simple-event-modeling/scheduler"
+)
+
+func TestStockManager_NeverOversells(t *testing.T) {
+	store := common.NewEventStore()
+	commands := bus.NewCommandBus(bus.RetryOnConflict(20), bus.Validation())
+	RegisterCommands(commands, store)
+	reservations.RegisterCommands(commands, store)
+
+	now := time.Now()
+	timers, err := scheduler.New(store, scheduler.WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatalf("Error creating scheduler: %v", err)
+	}
+	onHand := map[string]int{"apple": 2, "pear": 1}
+	managers := []*process.Manager{
+		NewStockManager(store, commands, func(sku string) int { return onHand[sku] }, 0),
+		reservations.NewExpiryManager(store, commands, timers),
+	}
+	ctx := context.Background()
+	settle := func() {
+		t.Helper()
+		for _, manager := range managers {
+			if _, err := manager.Process(ctx); err != nil {
+				t.Fatalf("Error processing %s: %v", manager.Name(), err)
+			}
+		}
+	}
+	place := func(lines ...Line) string {
+		t.Helper()
+		event, err := commands.Dispatch(ctx, &PlaceOrderCommand{Customer: "alice", Lines: lines})
+		if err != nil {
+			t.Fatalf("Error placing order: %v", err)
+		}
+		settle()
+		return event.AggregateID
+	}
+	order := func(id string) *OrderAggregate {
+		t.Helper()
+		order := NewOrderAggregate(store)
+		if err := order.Hydrate(id); err != nil {
+			t.Fatalf("Error hydrating order: %v", err)
+		}
+		return order
+	}
+	ledger := func(sku string) *reservations.Ledger {
+		t.Helper()
+		ledger := reservations.NewLedger(store)
+		if err := ledger.Hydrate(reservations.StreamID(sku)); err != nil {
+			t.Fatalf("Error hydrating ledger: %v", err)
+		}
+		return ledger
+	}
+
+	paid := place(Line{SKU: "apple", Quantity: 2})
+	// The second order takes a pear, then finds the apples gone
+	oversold := place(Line{SKU: "pear", Quantity: 1}, Line{SKU: "apple", Quantity: 1})
+	settle()
+	if o := order(oversold); o.Status() != StatusCancelled || o.CancelReason() != "out of stock: apple" {
+		t.Errorf("Expected the order to be cancelled for lack of apples, got %s (%s)", o.Status(), o.CancelReason())
+	}
+	if reserved := ledger("pear").Reserved(); reserved != 0 {
+		t.Errorf("Expected the cancelled order's pear to be released, got %d reserved", reserved)
+	}
+
+	if _, err := commands.Dispatch(ctx, &PayOrderCommand{OrderID: paid}); err != nil {
+		t.Fatalf("Error paying order: %v", err)
+	}
+	settle()
+	if held := ledger("apple").OwnedBy(paid); len(held) != 1 || !held[0].Confirmed {
+		t.Errorf("Expected the paid order's apples to be confirmed, got %+v", held)
+	}
+
+	unpaid := place(Line{SKU: "pear", Quantity: 1})
+	now = now.Add(DefaultPaymentWindow + time.Second)
+	if _, err := timers.FireDue(); err != nil {
+		t.Fatalf("Error firing timers: %v", err)
+	}
+	settle()
+	settle()
+	if o := order(unpaid); o.Status() != StatusCancelled || o.CancelReason() != "payment window elapsed" {
+		t.Errorf("Expected the unpaid order to be cancelled, got %s (%s)", o.Status(), o.CancelReason())
+	}
+	if o := order(paid); o.Status() != StatusPaid {
+		t.Errorf("Expected the paid order to keep its stock, got %s", o.Status())
+	}
+
+	next := place(Line{SKU: "pear", Quantity: 1})
+	if o := order(next); o.Status() != StatusPlaced || ledger("pear").Reserved() != 1 {
+		t.Errorf("Expected the expired pear to be available again, got %s with %d reserved", o.Status(), ledger("pear").Reserved())
+	}
+}
@@ -0,0 +1,56 @@
+// Package inventory registers the inventory domain with the common registry so
+// tooling can discover its commands, events, and aggregate.
+package inventory
+
+import "simple-event-modeling/common"
+
+// AggregateTypeOrder is the registered name of the order aggregate
+const AggregateTypeOrder = "Order"
+
+// Command type names
+const (
+	CommandTypePlaceOrder  = "PlaceOrder"
+	CommandTypePayOrder    = "PayOrder"
+	CommandTypeCancelOrder = "CancelOrder"
+)
+
+func init() {
+	register(common.DefaultRegistry)
+}
+
+func register(registry *common.Registry) {
+	registry.RegisterAggregate(common.AggregateInfo{Name: AggregateTypeOrder})
+
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypePlaceOrder,
+		Aggregate: AggregateTypeOrder,
+		Produces:  []string{EventTypeOrderPlaced},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypePayOrder,
+		Aggregate: AggregateTypeOrder,
+		Produces:  []string{EventTypeOrderPaid},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeCancelOrder,
+		Aggregate: AggregateTypeOrder,
+		Produces:  []string{EventTypeOrderCancelled},
+	})
+
+	registry.RegisterEvent(common.EventInfo{
+		Name:      EventTypeOrderPlaced,
+		Aggregate: AggregateTypeOrder,
+		Payload: []common.FieldInfo{
+			{Name: "customer", Type: "string", Description: "Customer placing the order"},
+			{Name: "lines", Type: "array", Description: "Products and quantities ordered"},
+		},
+	})
+	registry.RegisterEvent(common.EventInfo{Name: EventTypeOrderPaid, Aggregate: AggregateTypeOrder})
+	registry.RegisterEvent(common.EventInfo{
+		Name:      EventTypeOrderCancelled,
+		Aggregate: AggregateTypeOrder,
+		Payload: []common.FieldInfo{
+			{Name: "reason", Type: "string", Description: "Why the order was cancelled, e.g. out of stock"},
+		},
+	})
+}
@@ -0,0 +1,117 @@
+// Package inventory provides the process manager reserving stock for orders.
+package inventory
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+	"simple-event-modeling/process"
+	"simple-event-modeling/reservations"
+)
+
+// DefaultPaymentWindow is how long an order holds its stock unpaid when
+// NewStockManager is given no window
+const DefaultPaymentWindow = 15 * time.Minute
+
+// Stock returns the units on hand of a product, which limits its reservations
+type Stock func(sku string) int
+
+// NewStockManager creates a process manager reserving the stock of orders, each
+// product being a reservations resource owned by the orders holding its units. The
+// bus must have both the order and the reservations commands registered, and a
+// reservations.NewExpiryManager must run for unpaid orders to release their stock.
+//   - OrderPlaced reserves every line for paymentWindow, and cancels the order when
+//     a product is out of stock
+//   - OrderPaid confirms the order's reservations, and OrderCancelled cancels them
+//   - ReservationExpired cancels the order whose payment window elapsed
+func NewStockManager(store common.Store, commands *bus.CommandBus, stock Stock, paymentWindow time.Duration) *process.Manager {
+	if paymentWindow <= 0 {
+		paymentWindow = DefaultPaymentWindow
+	}
+	m := process.NewManager("inventory-stock", store, commands)
+
+	m.On(EventTypeOrderPlaced, func(ctx context.Context, event *common.Event) ([]common.Command, error) {
+		placed, err := common.AsTyped[OrderData](event)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range placed.Data.Lines {
+			// Lines reserved before a failed run are not reserved twice
+			held, err := reservationsOf(store, line.SKU, event.AggregateID)
+			if err != nil {
+				return nil, err
+			}
+			if len(held) > 0 {
+				continue
+			}
+			_, err = commands.Dispatch(ctx, &reservations.ReserveCommand{
+				Resource: line.SKU,
+				Owner:    event.AggregateID,
+				Quantity: line.Quantity,
+				Limit:    stock(line.SKU),
+				HoldFor:  paymentWindow,
+			})
+			var exceeded *reservations.CapacityExceededError
+			if errors.As(err, &exceeded) {
+				return []common.Command{&CancelOrderCommand{OrderID: event.AggregateID, Reason: "out of stock: " + line.SKU}}, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
+
+	m.On(EventTypeOrderPaid, func(_ context.Context, event *common.Event) ([]common.Command, error) {
+		return forReservations(store, event.AggregateID, func(sku, reservationID string) common.Command {
+			return &reservations.ConfirmReservationCommand{Resource: sku, ReservationID: reservationID}
+		})
+	})
+
+	m.On(EventTypeOrderCancelled, func(_ context.Context, event *common.Event) ([]common.Command, error) {
+		return forReservations(store, event.AggregateID, func(sku, reservationID string) common.Command {
+			return &reservations.CancelReservationCommand{Resource: sku, ReservationID: reservationID, Reason: "order cancelled"}
+		})
+	})
+
+	m.On(reservations.EventTypeReservationExpired, func(_ context.Context, event *common.Event) ([]common.Command, error) {
+		expired, err := common.AsTyped[reservations.ReservationData](event)
+		if err != nil {
+			return nil, err
+		}
+		return []common.Command{&CancelOrderCommand{OrderID: expired.Data.Owner, Reason: "payment window elapsed"}}, nil
+	})
+
+	return m
+}
+
+// forReservations returns a command for every reservation an order holds
+func forReservations(store common.Store, orderID string, command func(sku, reservationID string) common.Command) ([]common.Command, error) {
+	order := NewOrderAggregate(store)
+	if err := order.Hydrate(orderID); err != nil {
+		return nil, err
+	}
+	commands := make([]common.Command, 0)
+	for _, line := range order.Lines() {
+		held, err := reservationsOf(store, line.SKU, orderID)
+		if err != nil {
+			return nil, err
+		}
+		for _, reservation := range held {
+			commands = append(commands, command(line.SKU, reservation.ID))
+		}
+	}
+	return commands, nil
+}
+
+// reservationsOf returns the active reservations of a product held by an order
+func reservationsOf(store common.Store, sku, orderID string) ([]reservations.Reservation, error) {
+	ledger := reservations.NewLedger(store)
+	if err := ledger.Hydrate(reservations.StreamID(sku)); err != nil {
+		return nil, err
+	}
+	return ledger.OwnedBy(orderID), nil
+}
@@ -0,0 +1,189 @@
+// Package reservation provides the ShowAggregate implementation for the reservation domain.
+package reservation
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"simple-event-modeling/common"
+
+	"github.com/google/uuid"
+)
+
+// DefaultHoldDuration is how long seats stay held before the hold expires
+const DefaultHoldDuration = 10 * time.Minute
+
+// Seat states
+const (
+	SeatAvailable = "available"
+	SeatHeld      = "held"
+	SeatReserved  = "reserved"
+)
+
+// ShowAggregate guards the seats of a show. All holds for a show go through one
+// stream, so two customers racing for a seat conflict on append and the loser is
+// re-run against the winner's hold.
+type ShowAggregate struct {
+	*common.BaseAggregate
+	holdDuration time.Duration
+	now          func() time.Time
+	seats        map[string]string   // seat -> state
+	holds        map[string][]string // active hold ID -> seats
+}
+
+// NewShowAggregate creates a new show aggregate
+func NewShowAggregate(store common.Store) *ShowAggregate {
+	return &ShowAggregate{
+		BaseAggregate: common.NewBaseAggregate(store),
+		holdDuration:  DefaultHoldDuration,
+		now:           time.Now,
+		seats:         make(map[string]string),
+		holds:         make(map[string][]string),
+	}
+}
+
+// SeatState returns the state of a seat, or "" if the show has no such seat
+func (a *ShowAggregate) SeatState(seat string) string {
+	return a.seats[seat]
+}
+
+// ActiveHolds returns the IDs of the holds that are neither confirmed nor released, sorted
+func (a *ShowAggregate) ActiveHolds() []string {
+	ids := make([]string, 0, len(a.holds))
+	for id := range a.holds {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Handle processes commands and returns resulting events
+func (a *ShowAggregate) Handle(command common.Command) (*common.Event, error) {
+	if aggregateID := command.AggregateID(); aggregateID != "" && !a.IsLive() {
+		if err := a.Hydrate(aggregateID); err != nil {
+			return nil, err
+		}
+	}
+
+	var event *common.Event
+	var err error
+	switch cmd := command.(type) {
+	case *CreateShowCommand:
+		event = NewShowCreatedEvent(uuid.New().String(), cmd.Seats)
+	case *HoldSeatsCommand:
+		event, err = a.handleHoldSeats(cmd)
+	case *ConfirmHoldCommand:
+		event, err = a.handleConfirmHold(cmd)
+	case *ReleaseHoldCommand:
+		event, err = a.handleReleaseHold(cmd)
+	default:
+		return nil, &common.UnknownCommandError{
+			CommandType: command.CommandType(),
+			Registered:  common.DefaultRegistry.CommandTypes(AggregateTypeShow),
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.On(event); err != nil {
+		return nil, err
+	}
+	if err := a.Store().Append(event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// On applies events to aggregate state
+func (a *ShowAggregate) On(event *common.Event) error {
+	switch event.Type {
+	case EventTypeShowCreated:
+		a.Begin(event)
+		for _, seat := range stringsOf(event.Data, "seats") {
+			a.seats[seat] = SeatAvailable
+		}
+	case EventTypeSeatsHeld:
+		holdID, _ := event.Data["hold_id"].(string)
+		seats := stringsOf(event.Data, "seats")
+		a.holds[holdID] = seats
+		a.setSeats(seats, SeatHeld)
+	case EventTypeHoldConfirmed:
+		holdID, _ := event.Data["hold_id"].(string)
+		a.setSeats(a.holds[holdID], SeatReserved)
+		delete(a.holds, holdID)
+	case EventTypeHoldReleased:
+		holdID, _ := event.Data["hold_id"].(string)
+		a.setSeats(a.holds[holdID], SeatAvailable)
+		delete(a.holds, holdID)
+	default:
+		return errors.New("unhandled event type: " + event.Type)
+	}
+	return a.Advance(event)
+}
+
+// Hydrate rebuilds the aggregate state from its event stream
+func (a *ShowAggregate) Hydrate(id string) error {
+	return a.BaseAggregate.Hydrate(id, a.On)
+}
+
+func (a *ShowAggregate) setSeats(seats []string, state string) {
+	for _, seat := range seats {
+		a.seats[seat] = state
+	}
+}
+
+// Command handlers
+
+func (a *ShowAggregate) handleHoldSeats(cmd *HoldSeatsCommand) (*common.Event, error) {
+	if err := a.checkCreated(); err != nil {
+		return nil, err
+	}
+
+	// Business rule: every seat must exist and be available
+	var unavailable []string
+	for _, seat := range cmd.Seats {
+		if a.seats[seat] != SeatAvailable {
+			unavailable = append(unavailable, seat)
+		}
+	}
+	if len(unavailable) > 0 {
+		return nil, &common.InvalidCommandError{Message: "seats not available: " + strings.Join(unavailable, ", ")}
+	}
+
+	expiresAt := a.now().Add(a.holdDuration)
+	return NewSeatsHeldEvent(a.ID(), a.Version()+1, uuid.New().String(), cmd.Customer, cmd.Seats, expiresAt), nil
+}
+
+func (a *ShowAggregate) handleConfirmHold(cmd *ConfirmHoldCommand) (*common.Event, error) {
+	if err := a.checkActiveHold(cmd.HoldID); err != nil {
+		return nil, err
+	}
+	return NewHoldConfirmedEvent(a.ID(), a.Version()+1, cmd.HoldID), nil
+}
+
+func (a *ShowAggregate) handleReleaseHold(cmd *ReleaseHoldCommand) (*common.Event, error) {
+	if err := a.checkActiveHold(cmd.HoldID); err != nil {
+		return nil, err
+	}
+	return NewHoldReleasedEvent(a.ID(), a.Version()+1, cmd.HoldID, cmd.Reason), nil
+}
+
+func (a *ShowAggregate) checkCreated() error {
+	if !a.IsLive() || a.ID() == "" {
+		return &common.InvalidCommandError{Message: "show not created"}
+	}
+	return nil
+}
+
+func (a *ShowAggregate) checkActiveHold(holdID string) error {
+	if err := a.checkCreated(); err != nil {
+		return err
+	}
+	if _, ok := a.holds[holdID]; !ok {
+		return &common.InvalidCommandError{Message: "hold " + holdID + " is not active"}
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+// Package reservation registers the show command handlers with a command bus.
+package reservation
+
+import (
+	"context"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+)
+
+// RegisterCommands registers a handler for every show command.
+// Each command is handled by a fresh aggregate hydrated from the store, so a bus
+// using bus.RetryOnConflict re-runs a losing hold against the winner's state.
+func RegisterCommands(commands *bus.CommandBus, store common.Store) {
+	handle := func(_ context.Context, command common.Command) (*common.Event, error) {
+		return NewShowAggregate(store).Handle(command)
+	}
+	commands.Register(CommandTypeCreateShow, handle)
+	commands.Register(CommandTypeHoldSeats, handle)
+	commands.Register(CommandTypeConfirmHold, handle)
+	commands.Register(CommandTypeReleaseHold, handle)
+}
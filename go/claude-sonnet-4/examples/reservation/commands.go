@@ -0,0 +1,41 @@
+// Package reservation provides command types for the reservation domain.
+// Commands are simple record structures; their only methods expose the routing
+// metadata required by common.Command.
+package reservation
+
+// CreateShowCommand creates a show with the given seats. The show ID is generated.
+type CreateShowCommand struct {
+	Seats []string `json:"seats" validate:"required"`
+}
+
+// HoldSeatsCommand holds seats for a customer until the hold expires
+type HoldSeatsCommand struct {
+	ShowID   string   `json:"aggregate_id" validate:"required,uuid"`
+	Customer string   `json:"customer" validate:"required"`
+	Seats    []string `json:"seats" validate:"required"`
+}
+
+// ConfirmHoldCommand turns a hold into a confirmed reservation
+type ConfirmHoldCommand struct {
+	ShowID string `json:"aggregate_id" validate:"required,uuid"`
+	HoldID string `json:"hold_id" validate:"required"`
+}
+
+// ReleaseHoldCommand frees the seats of a hold
+type ReleaseHoldCommand struct {
+	ShowID string `json:"aggregate_id" validate:"required,uuid"`
+	HoldID string `json:"hold_id" validate:"required"`
+	Reason string `json:"reason,omitempty"`
+}
+
+func (c *CreateShowCommand) AggregateID() string { return "" }
+func (c *CreateShowCommand) CommandType() string { return CommandTypeCreateShow }
+
+func (c *HoldSeatsCommand) AggregateID() string { return c.ShowID }
+func (c *HoldSeatsCommand) CommandType() string { return CommandTypeHoldSeats }
+
+func (c *ConfirmHoldCommand) AggregateID() string { return c.ShowID }
+func (c *ConfirmHoldCommand) CommandType() string { return CommandTypeConfirmHold }
+
+func (c *ReleaseHoldCommand) AggregateID() string { return c.ShowID }
+func (c *ReleaseHoldCommand) CommandType() string { return CommandTypeReleaseHold }
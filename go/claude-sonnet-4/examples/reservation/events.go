@@ -0,0 +1,65 @@
+// Package reservation provides event types and creation functions for the reservation domain.
+// Events are simple record structures with no behaviors.
+package reservation
+
+import (
+	"time"
+
+	"simple-event-modeling/common"
+)
+
+// Event type constants
+const (
+	EventTypeShowCreated   = "ShowCreated"
+	EventTypeSeatsHeld     = "SeatsHeld"
+	EventTypeHoldConfirmed = "HoldConfirmed"
+	EventTypeHoldReleased  = "HoldReleased"
+)
+
+// NewShowCreatedEvent creates a new ShowCreated event
+func NewShowCreatedEvent(showID string, seats []string) *common.Event {
+	return common.NewEvent(EventTypeShowCreated, showID, 1, map[string]interface{}{"seats": seats}, nil)
+}
+
+// NewSeatsHeldEvent creates a new SeatsHeld event
+func NewSeatsHeldEvent(showID string, version int, holdID, customer string, seats []string, expiresAt time.Time) *common.Event {
+	data := map[string]interface{}{
+		"hold_id":    holdID,
+		"customer":   customer,
+		"seats":      seats,
+		"expires_at": expiresAt.UTC().Format(time.RFC3339Nano),
+	}
+	return common.NewEvent(EventTypeSeatsHeld, showID, version, data, nil)
+}
+
+// NewHoldConfirmedEvent creates a new HoldConfirmed event
+func NewHoldConfirmedEvent(showID string, version int, holdID string) *common.Event {
+	return common.NewEvent(EventTypeHoldConfirmed, showID, version, map[string]interface{}{"hold_id": holdID}, nil)
+}
+
+// NewHoldReleasedEvent creates a new HoldReleased event
+func NewHoldReleasedEvent(showID string, version int, holdID, reason string) *common.Event {
+	data := map[string]interface{}{
+		"hold_id": holdID,
+		"reason":  reason,
+	}
+	return common.NewEvent(EventTypeHoldReleased, showID, version, data, nil)
+}
+
+// stringsOf reads a list of strings from event data. Lists are []string when the
+// event was created in process and []interface{} after a round trip through JSON.
+func stringsOf(data map[string]interface{}, key string) []string {
+	switch v := data[key].(type) {
+	case []string:
+		return v
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+// Package reservation provides the process manager that expires unconfirmed holds.
+package reservation
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+	"simple-event-modeling/process"
+	"simple-event-modeling/scheduler"
+)
+
+// TimeoutStream is the stream hold expiry timers fire into
+const TimeoutStream = "reservation-timeouts"
+
+// HoldExpiredTimer is the name of the timer scheduled for every hold
+const HoldExpiredTimer = "HoldExpired"
+
+// NewExpiryManager creates a process manager that schedules a timer for every new
+// hold and releases the hold when the timer elapses. Timers of holds that were
+// confirmed in the meantime still fire; their release is rejected by the aggregate
+// and skipped by the process manager.
+func NewExpiryManager(store common.Store, commands *bus.CommandBus, timers *scheduler.Scheduler) *process.Manager {
+	m := process.NewManager("reservation-expiry", store, commands)
+
+	m.On(EventTypeSeatsHeld, func(_ context.Context, event *common.Event) ([]common.Command, error) {
+		raw, _ := event.Data["expires_at"].(string)
+		expiresAt, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expires_at %q", raw)
+		}
+		_, err = timers.Schedule(TimeoutStream, HoldExpiredTimer, expiresAt, map[string]interface{}{
+			"show_id": event.AggregateID,
+			"hold_id": event.Data["hold_id"],
+		})
+		return nil, err
+	})
+
+	m.On(scheduler.EventTypeTimeoutElapsed, func(_ context.Context, event *common.Event) ([]common.Command, error) {
+		if event.AggregateID != TimeoutStream || event.Data["name"] != HoldExpiredTimer {
+			return nil, nil
+		}
+		showID, _ := event.Data["show_id"].(string)
+		holdID, _ := event.Data["hold_id"].(string)
+		return []common.Command{&ReleaseHoldCommand{ShowID: showID, HoldID: holdID, Reason: "expired"}}, nil
+	})
+
+	return m
+}
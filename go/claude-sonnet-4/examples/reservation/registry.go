@@ -0,0 +1,68 @@
+// Package reservation registers the reservation domain with the common registry so
+// tooling can discover its commands, events, and aggregate.
+package reservation
+
+import "simple-event-modeling/common"
+
+// AggregateTypeShow is the registered name of the show aggregate
+const AggregateTypeShow = "Show"
+
+// Command type names
+const (
+	CommandTypeCreateShow  = "CreateShow"
+	CommandTypeHoldSeats   = "HoldSeats"
+	CommandTypeConfirmHold = "ConfirmHold"
+	CommandTypeReleaseHold = "ReleaseHold"
+)
+
+func init() {
+	register(common.DefaultRegistry)
+}
+
+func register(registry *common.Registry) {
+	registry.RegisterAggregate(common.AggregateInfo{Name: AggregateTypeShow})
+
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeCreateShow,
+		Aggregate: AggregateTypeShow,
+		Produces:  []string{EventTypeShowCreated},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeHoldSeats,
+		Aggregate: AggregateTypeShow,
+		Produces:  []string{EventTypeSeatsHeld},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeConfirmHold,
+		Aggregate: AggregateTypeShow,
+		Produces:  []string{EventTypeHoldConfirmed},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeReleaseHold,
+		Aggregate: AggregateTypeShow,
+		Produces:  []string{EventTypeHoldReleased},
+	})
+
+	seatsField := common.FieldInfo{Name: "seats", Type: "array", Description: "Seat labels"}
+	holdField := common.FieldInfo{Name: "hold_id", Type: "string", Description: "ID of the hold"}
+	registry.RegisterEvent(common.EventInfo{Name: EventTypeShowCreated, Aggregate: AggregateTypeShow, Payload: []common.FieldInfo{seatsField}})
+	registry.RegisterEvent(common.EventInfo{
+		Name:      EventTypeSeatsHeld,
+		Aggregate: AggregateTypeShow,
+		Payload: []common.FieldInfo{
+			holdField,
+			{Name: "customer", Type: "string", Description: "Customer holding the seats"},
+			seatsField,
+			{Name: "expires_at", Type: "string", Description: "RFC 3339 time the hold expires"},
+		},
+	})
+	registry.RegisterEvent(common.EventInfo{Name: EventTypeHoldConfirmed, Aggregate: AggregateTypeShow, Payload: []common.FieldInfo{holdField}})
+	registry.RegisterEvent(common.EventInfo{
+		Name:      EventTypeHoldReleased,
+		Aggregate: AggregateTypeShow,
+		Payload: []common.FieldInfo{
+			holdField,
+			{Name: "reason", Type: "string", Description: "Why the hold was released, e.g. expired"},
+		},
+	})
+}
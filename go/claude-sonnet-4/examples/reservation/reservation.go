@@ -0,0 +1,16 @@
+// Package reservation provides a ticket reservation domain built on the common
+// framework. Customers hold seats for a show, then confirm the hold or let it expire.
+// It showcases how the framework's pieces interact under contention:
+//   - optimistic concurrency: two customers holding the same seat race to append to
+//     the show stream; the loser is retried against fresh state and rejected
+//   - the scheduler: every hold schedules a timer for its expiry
+//   - a process manager: releases holds whose timer elapsed before confirmation
+//
+// The package is organized into separate files for each major concept:
+// - commands.go: Command types (CreateShow, HoldSeats, ConfirmHold, ReleaseHold)
+// - events.go: Event types and creation functions (ShowCreated, SeatsHeld, etc.)
+// - aggregate.go: ShowAggregate implementation with the seat rules
+// - expiry.go: Process manager expiring unconfirmed holds
+// - registry.go: Registration with the common registry
+// - bus.go: Registration of command handlers with a command bus
+package reservation
@@ -0,0 +1,113 @@
+package reservation
+
+import (
+	"context"
+	"errors"
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+	"simple-event-modeling/scheduler"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newBus(store common.Store) *bus.CommandBus {
+	commands := bus.NewCommandBus(bus.RetryOnConflict(20), bus.Validation())
+	RegisterCommands(commands, store)
+	return commands
+}
+
+func createShow(t *testing.T, commands *bus.CommandBus, seats ...string) string {
+	t.Helper()
+	event, err := commands.Dispatch(context.Background(), &CreateShowCommand{Seats: seats})
+	if err != nil {
+		t.Fatalf("Error creating show: %v", err)
+	}
+	return event.AggregateID
+}
+
+func TestShowAggregate_ContentionForTheSameSeat(t *testing.T) {
+	store := common.NewEventStore()
+	commands := newBus(store)
+	showID := createShow(t, commands, "A1", "A2")
+
+	const customers = 10
+	var wg sync.WaitGroup
+	errs := make([]error, customers)
+	for i := 0; i < customers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = commands.Dispatch(context.Background(), &HoldSeatsCommand{ShowID: showID, Customer: "c", Seats: []string{"A1"}})
+		}(i)
+	}
+	wg.Wait()
+
+	held, rejected := 0, 0
+	for _, err := range errs {
+		var invalid *common.InvalidCommandError
+		switch {
+		case err == nil:
+			held++
+		case errors.As(err, &invalid):
+			rejected++
+		default:
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+	if held != 1 || rejected != customers-1 {
+		t.Errorf("Expected exactly one hold, got %d held and %d rejected", held, rejected)
+	}
+
+	show := NewShowAggregate(store)
+	show.Hydrate(showID)
+	if show.SeatState("A1") != SeatHeld || show.SeatState("A2") != SeatAvailable {
+		t.Errorf("Unexpected seat states: A1=%s A2=%s", show.SeatState("A1"), show.SeatState("A2"))
+	}
+}
+
+func TestExpiryManager_ReleasesUnconfirmedHolds(t *testing.T) {
+	store := common.NewEventStore()
+	commands := newBus(store)
+	now := time.Now()
+	timers, err := scheduler.New(store, scheduler.WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatalf("Error creating scheduler: %v", err)
+	}
+	expiry := NewExpiryManager(store, commands, timers)
+	ctx := context.Background()
+
+	showID := createShow(t, commands, "A1", "A2", "A3")
+	confirmed, _ := commands.Dispatch(ctx, &HoldSeatsCommand{ShowID: showID, Customer: "alice", Seats: []string{"A1"}})
+	commands.Dispatch(ctx, &HoldSeatsCommand{ShowID: showID, Customer: "bob", Seats: []string{"A2", "A3"}})
+	if _, err := commands.Dispatch(ctx, &ConfirmHoldCommand{ShowID: showID, HoldID: confirmed.Data["hold_id"].(string)}); err != nil {
+		t.Fatalf("Error confirming hold: %v", err)
+	}
+
+	if _, err := expiry.Process(ctx); err != nil {
+		t.Fatalf("Error processing: %v", err)
+	}
+	if pending := timers.Pending(); len(pending) != 2 {
+		t.Fatalf("Expected a timer per hold, got %d", len(pending))
+	}
+
+	now = now.Add(DefaultHoldDuration + time.Second)
+	if fired, _ := timers.FireDue(); fired != 2 {
+		t.Fatalf("Expected both timers to fire, got %d", fired)
+	}
+	if _, err := expiry.Process(ctx); err != nil {
+		t.Fatalf("Error processing timeouts: %v", err)
+	}
+
+	show := NewShowAggregate(store)
+	show.Hydrate(showID)
+	if show.SeatState("A1") != SeatReserved {
+		t.Errorf("Expected confirmed seat A1 to stay reserved, got %s", show.SeatState("A1"))
+	}
+	if show.SeatState("A2") != SeatAvailable || show.SeatState("A3") != SeatAvailable {
+		t.Errorf("Expected bob's expired seats to be released, got A2=%s A3=%s", show.SeatState("A2"), show.SeatState("A3"))
+	}
+	if holds := show.ActiveHolds(); len(holds) != 0 {
+		t.Errorf("Expected no active holds, got %v", holds)
+	}
+}
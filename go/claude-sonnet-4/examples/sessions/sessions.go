@@ -0,0 +1,73 @@
+// Package sessions shows two sessions editing one cart at the same time, e.g. a
+// shopper on their phone and laptop. Each session remembers the cart version it
+// last saw and sends its commands expecting that version, so a write based on a
+// stale view of the cart is caught instead of silently overwriting the other
+// session's:
+//   - conflicts are surfaced to callers as errors matching common.ErrConcurrency
+//   - adds commute with other changes to the cart's lines, so bus.MergeOnConflict
+//     merges them automatically using cart.Commutes
+//   - a merge that would break the cart's item limit, or a command that doesn't
+//     commute, fails with a *common.MergeConflictError the user resolves by hand
+//     after the session refreshes its view
+package sessions
+
+import (
+	"context"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/cart"
+	"simple-event-modeling/common"
+)
+
+// NewCommandBus creates a command bus handling the cart commands, merging adds that
+// raced other writes to the cart
+func NewCommandBus(store common.Store) *bus.CommandBus {
+	commands := bus.NewCommandBus(bus.MergeOnConflict(store, cart.Commutes))
+	cart.RegisterCommands(commands, store)
+	return commands
+}
+
+// Session is one client's view of a cart
+type Session struct {
+	Name   string
+	CartID string
+	// Version is the version of the cart the session last saw
+	Version int
+
+	commands *bus.CommandBus
+	store    common.Store
+}
+
+// Open starts a session on a cart, seeing its current version
+func Open(name, cartID string, commands *bus.CommandBus, store common.Store) *Session {
+	session := &Session{Name: name, CartID: cartID, commands: commands, store: store}
+	session.Refresh()
+	return session
+}
+
+// Refresh brings the session's view up to the cart's current version, as a client
+// does after reloading the cart to resolve a conflict
+func (s *Session) Refresh() {
+	s.Version = s.store.GetStreamVersion(s.CartID)
+}
+
+// Do dispatches a command expecting the version the session last saw. On success,
+// including after a merge, the session sees the cart at the version of the
+// command's last event; on error its view is left alone.
+func (s *Session) Do(ctx context.Context, command common.Command) (*common.Event, error) {
+	event, err := s.commands.Dispatch(common.WithExpectedVersion(ctx, s.Version), command)
+	if err != nil {
+		return nil, err
+	}
+	s.Version = event.Version
+	return event, nil
+}
+
+// Items returns the items in the cart now, keyed by line
+func (s *Session) Items() (map[string]int, error) {
+	current := cart.NewCartAggregate(s.store)
+	if err := current.Hydrate(s.CartID); err != nil {
+		return nil, err
+	}
+	return current.Items(), nil
+}
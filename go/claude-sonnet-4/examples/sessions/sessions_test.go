@@ -0,0 +1,66 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"simple-event-modeling/cart"
+	"simple-event-modeling/common"
+	"testing"
+)
+
+func TestSessions_MergeAddsAndSurfaceConflicts(t *testing.T) {
+	store := common.NewEventStore()
+	commands := NewCommandBus(store)
+	ctx := context.Background()
+
+	created, err := commands.Dispatch(ctx, &cart.AddItemCommand{ItemID: "apple"})
+	if err != nil {
+		t.Fatalf("Error creating cart: %v", err)
+	}
+	cartID := created.AggregateID
+	phone := Open("phone", cartID, commands, store)
+	laptop := Open("laptop", cartID, commands, store)
+
+	if _, err := phone.Do(ctx, &cart.AddItemCommand{CartID: cartID, ItemID: "pear"}); err != nil {
+		t.Fatalf("Error adding from the phone: %v", err)
+	}
+
+	// The laptop still sees version 2, but its add commutes with the phone's
+	if _, err := laptop.Do(ctx, &cart.AddItemCommand{CartID: cartID, ItemID: "plum"}); err != nil {
+		t.Fatalf("Expected the laptop's add merged, got %v", err)
+	}
+	if laptop.Version != 4 {
+		t.Errorf("Expected the laptop to see version 4, got %d", laptop.Version)
+	}
+
+	// Merging the phone's next add would exceed the item limit: resolved by hand
+	_, err = phone.Do(ctx, &cart.AddItemCommand{CartID: cartID, ItemID: "kiwi"})
+	var merge *common.MergeConflictError
+	if !errors.As(err, &merge) || !errors.Is(err, common.ErrConcurrency) || merge.Expected != 3 || merge.Actual != 4 || len(merge.Theirs) != 1 {
+		t.Fatalf("Expected a merge conflict with the laptop's add, got %v", err)
+	}
+	var limit *cart.CartItemLimitExceededError
+	if !errors.As(merge.Rejection, &limit) {
+		t.Errorf("Expected the item limit to reject the merge, got %v", merge.Rejection)
+	}
+	if items, _ := phone.Items(); len(items) != 3 {
+		t.Errorf("Expected the cart unchanged, got %v", items)
+	}
+	// The shopper swaps the apple for the kiwi
+	phone.Refresh()
+	if _, err := phone.Do(ctx, &cart.RemoveItemCommand{CartID: cartID, ItemID: "apple"}); err != nil {
+		t.Fatalf("Error removing from the phone: %v", err)
+	}
+	if _, err := phone.Do(ctx, &cart.AddItemCommand{CartID: cartID, ItemID: "kiwi"}); err != nil {
+		t.Fatalf("Error adding from the phone: %v", err)
+	}
+
+	// Clearing doesn't commute with the phone's changes the laptop hasn't seen
+	_, err = laptop.Do(ctx, &cart.ClearCartCommand{CartID: cartID})
+	if !errors.As(err, &merge) || merge.Rejection != nil || len(merge.Theirs) != 2 || common.ErrorCodeOf(err) != common.CodeMergeConflict {
+		t.Errorf("Expected the clear to conflict, got %v", err)
+	}
+	if items, _ := laptop.Items(); len(items) != 3 || items["kiwi"] != 1 || items["apple"] != 0 {
+		t.Errorf("Expected the phone's swap kept, got %v", items)
+	}
+}
@@ -0,0 +1,111 @@
+// Package todo provides the TodoAggregate implementation for the todo domain.
+package todo
+
+import (
+	"errors"
+
+	"simple-event-modeling/common"
+
+	"github.com/google/uuid"
+)
+
+// Todo statuses
+const (
+	StatusOpen = "open"
+	StatusDone = "done"
+)
+
+// TodoAggregate handles command validation and appends events to the store if
+// commands are valid. It only tracks the status; titles, tags, and due dates are
+// of interest to the read side alone.
+type TodoAggregate struct {
+	*common.BaseAggregate
+	status string
+}
+
+// NewTodoAggregate creates a new todo aggregate
+func NewTodoAggregate(store common.Store) *TodoAggregate {
+	return &TodoAggregate{BaseAggregate: common.NewBaseAggregate(store)}
+}
+
+// Status returns the status of the todo, or "" before it was added
+func (a *TodoAggregate) Status() string {
+	return a.status
+}
+
+// Handle processes commands and returns resulting events
+func (a *TodoAggregate) Handle(command common.Command) (*common.Event, error) {
+	if aggregateID := command.AggregateID(); aggregateID != "" && !a.IsLive() {
+		if err := a.Hydrate(aggregateID); err != nil {
+			return nil, err
+		}
+	}
+
+	var event *common.Event
+	var err error
+	switch cmd := command.(type) {
+	case *AddTodoCommand:
+		event = NewTodoAddedEvent(uuid.New().String(), cmd.Title, cmd.Tags, cmd.DueAt)
+	case *CompleteTodoCommand:
+		if err = a.checkStatus(StatusOpen, "only open todos can be completed"); err == nil {
+			event = NewTodoCompletedEvent(a.ID(), a.Version()+1)
+		}
+	case *ReopenTodoCommand:
+		if err = a.checkStatus(StatusDone, "only done todos can be reopened"); err == nil {
+			event = NewTodoReopenedEvent(a.ID(), a.Version()+1)
+		}
+	case *RescheduleTodoCommand:
+		if err = a.checkStatus(StatusOpen, "only open todos can be rescheduled"); err == nil {
+			event = NewTodoRescheduledEvent(a.ID(), a.Version()+1, cmd.DueAt)
+		}
+	default:
+		return nil, &common.UnknownCommandError{
+			CommandType: command.CommandType(),
+			Registered:  common.DefaultRegistry.CommandTypes(AggregateTypeTodo),
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.On(event); err != nil {
+		return nil, err
+	}
+	if err := a.Store().Append(event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// On applies events to aggregate state
+func (a *TodoAggregate) On(event *common.Event) error {
+	switch event.Type {
+	case EventTypeTodoAdded:
+		a.Begin(event)
+		a.status = StatusOpen
+	case EventTypeTodoCompleted:
+		a.status = StatusDone
+	case EventTypeTodoReopened:
+		a.status = StatusOpen
+	case EventTypeTodoRescheduled:
+	default:
+		return errors.New("unhandled event type: " + event.Type)
+	}
+	return a.Advance(event)
+}
+
+// Hydrate rebuilds the aggregate state from its event stream
+func (a *TodoAggregate) Hydrate(id string) error {
+	return a.BaseAggregate.Hydrate(id, a.On)
+}
+
+// checkStatus rejects the command unless the todo exists and has the given status
+func (a *TodoAggregate) checkStatus(status, message string) error {
+	if !a.IsLive() || a.ID() == "" {
+		return &common.InvalidCommandError{Message: "todo not added"}
+	}
+	if a.status != status {
+		return &common.InvalidCommandError{Message: message}
+	}
+	return nil
+}
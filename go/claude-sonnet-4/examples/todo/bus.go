@@ -0,0 +1,21 @@
+// Package todo registers the todo command handlers with a command bus.
+package todo
+
+import (
+	"context"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+)
+
+// RegisterCommands registers a handler for every todo command.
+// Each command is handled by a fresh aggregate hydrated from the store.
+func RegisterCommands(commands *bus.CommandBus, store common.Store) {
+	handle := func(_ context.Context, command common.Command) (*common.Event, error) {
+		return NewTodoAggregate(store).Handle(command)
+	}
+	commands.Register(CommandTypeAddTodo, handle)
+	commands.Register(CommandTypeCompleteTodo, handle)
+	commands.Register(CommandTypeReopenTodo, handle)
+	commands.Register(CommandTypeRescheduleTodo, handle)
+}
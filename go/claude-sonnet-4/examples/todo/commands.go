@@ -0,0 +1,41 @@
+// Package todo provides command types for the todo domain.
+// Commands are simple record structures; their only methods expose the routing
+// metadata required by common.Command.
+package todo
+
+import "time"
+
+// AddTodoCommand adds a todo. The todo ID is generated; DueAt is optional.
+type AddTodoCommand struct {
+	Title string    `json:"title" validate:"required"`
+	Tags  []string  `json:"tags,omitempty"`
+	DueAt time.Time `json:"due_at,omitempty"`
+}
+
+// CompleteTodoCommand marks an open todo as done
+type CompleteTodoCommand struct {
+	TodoID string `json:"aggregate_id" validate:"required,uuid"`
+}
+
+// ReopenTodoCommand marks a done todo as open again
+type ReopenTodoCommand struct {
+	TodoID string `json:"aggregate_id" validate:"required,uuid"`
+}
+
+// RescheduleTodoCommand moves the due date of an open todo
+type RescheduleTodoCommand struct {
+	TodoID string    `json:"aggregate_id" validate:"required,uuid"`
+	DueAt  time.Time `json:"due_at" validate:"required"`
+}
+
+func (c *AddTodoCommand) AggregateID() string { return "" }
+func (c *AddTodoCommand) CommandType() string { return CommandTypeAddTodo }
+
+func (c *CompleteTodoCommand) AggregateID() string { return c.TodoID }
+func (c *CompleteTodoCommand) CommandType() string { return CommandTypeCompleteTodo }
+
+func (c *ReopenTodoCommand) AggregateID() string { return c.TodoID }
+func (c *ReopenTodoCommand) CommandType() string { return CommandTypeReopenTodo }
+
+func (c *RescheduleTodoCommand) AggregateID() string { return c.TodoID }
+func (c *RescheduleTodoCommand) CommandType() string { return CommandTypeRescheduleTodo }
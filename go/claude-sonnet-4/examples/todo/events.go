@@ -0,0 +1,70 @@
+// Package todo provides event types and creation functions for the todo domain.
+// Events are simple record structures with no behaviors.
+package todo
+
+import (
+	"time"
+
+	"simple-event-modeling/common"
+)
+
+// Event type constants
+const (
+	EventTypeTodoAdded       = "TodoAdded"
+	EventTypeTodoCompleted   = "TodoCompleted"
+	EventTypeTodoReopened    = "TodoReopened"
+	EventTypeTodoRescheduled = "TodoRescheduled"
+)
+
+// NewTodoAddedEvent creates a new TodoAdded event. A zero dueAt is left out of the data.
+func NewTodoAddedEvent(todoID, title string, tags []string, dueAt time.Time) *common.Event {
+	data := map[string]interface{}{
+		"title": title,
+		"tags":  tags,
+	}
+	if !dueAt.IsZero() {
+		data["due_at"] = dueAt.UTC().Format(time.RFC3339Nano)
+	}
+	return common.NewEvent(EventTypeTodoAdded, todoID, 1, data, nil)
+}
+
+// NewTodoCompletedEvent creates a new TodoCompleted event
+func NewTodoCompletedEvent(todoID string, version int) *common.Event {
+	return common.NewEvent(EventTypeTodoCompleted, todoID, version, nil, nil)
+}
+
+// NewTodoReopenedEvent creates a new TodoReopened event
+func NewTodoReopenedEvent(todoID string, version int) *common.Event {
+	return common.NewEvent(EventTypeTodoReopened, todoID, version, nil, nil)
+}
+
+// NewTodoRescheduledEvent creates a new TodoRescheduled event
+func NewTodoRescheduledEvent(todoID string, version int, dueAt time.Time) *common.Event {
+	data := map[string]interface{}{"due_at": dueAt.UTC().Format(time.RFC3339Nano)}
+	return common.NewEvent(EventTypeTodoRescheduled, todoID, version, data, nil)
+}
+
+// dueAtOf reads the due date of an event, returning the zero time when it has none
+func dueAtOf(data map[string]interface{}) time.Time {
+	s, _ := data["due_at"].(string)
+	dueAt, _ := time.Parse(time.RFC3339Nano, s)
+	return dueAt
+}
+
+// stringsOf reads a list of strings from event data. Lists are []string when the
+// event was created in process and []interface{} after a round trip through JSON.
+func stringsOf(data map[string]interface{}, key string) []string {
+	switch v := data[key].(type) {
+	case []string:
+		return v
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
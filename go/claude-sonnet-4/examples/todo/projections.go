@@ -0,0 +1,262 @@
+// Package todo provides the read-side projections of the todo domain.
+// Each projection folds the same todo events from the global event log into a read
+// model shaped for one kind of question, and can be rebuilt with `sem project`.
+package todo
+
+import (
+	"sort"
+	"time"
+
+	"simple-event-modeling/common"
+)
+
+// Projection names
+const (
+	ByStatusProjectionName = "todos-by-status"
+	ByTagProjectionName    = "todos-by-tag"
+	OverdueProjectionName  = "todos-overdue"
+)
+
+// TodoView is the read model of a single todo
+type TodoView struct {
+	ID     string     `json:"id"`
+	Title  string     `json:"title"`
+	Tags   []string   `json:"tags,omitempty"`
+	Status string     `json:"status"`
+	DueAt  *time.Time `json:"due_at,omitempty"`
+}
+
+var todoEventTypes = []string{EventTypeTodoAdded, EventTypeTodoCompleted, EventTypeTodoReopened, EventTypeTodoRescheduled}
+
+// todoViews folds todo events into a view per todo. The projections embed it and
+// index the views in their own way.
+type todoViews map[string]*TodoView
+
+// apply updates the view of the event's todo and returns it, or returns nil for
+// events that are not todo events
+func (v todoViews) apply(event *common.Event) *TodoView {
+	if event.Type == EventTypeTodoAdded {
+		view := &TodoView{
+			ID:     event.AggregateID,
+			Title:  stringOf(event.Data, "title"),
+			Tags:   stringsOf(event.Data, "tags"),
+			Status: StatusOpen,
+		}
+		if dueAt := dueAtOf(event.Data); !dueAt.IsZero() {
+			view.DueAt = &dueAt
+		}
+		v[event.AggregateID] = view
+		return view
+	}
+
+	view, exists := v[event.AggregateID]
+	if !exists {
+		return nil
+	}
+	switch event.Type {
+	case EventTypeTodoCompleted:
+		view.Status = StatusDone
+	case EventTypeTodoReopened:
+		view.Status = StatusOpen
+	case EventTypeTodoRescheduled:
+		dueAt := dueAtOf(event.Data)
+		view.DueAt = &dueAt
+	default:
+		return nil
+	}
+	return view
+}
+
+// ByStatusProjection groups todos by status
+type ByStatusProjection struct {
+	views    todoViews
+	byStatus map[string]map[string]*TodoView
+}
+
+// NewByStatusProjection creates an empty by-status projection
+func NewByStatusProjection() *ByStatusProjection {
+	return &ByStatusProjection{
+		views:    make(todoViews),
+		byStatus: make(map[string]map[string]*TodoView),
+	}
+}
+
+// Name returns the registered name of the projection
+func (p *ByStatusProjection) Name() string {
+	return ByStatusProjectionName
+}
+
+// Consumes returns the event types the projection folds
+func (p *ByStatusProjection) Consumes() []string {
+	return todoEventTypes
+}
+
+// On moves the event's todo into the group of its current status
+func (p *ByStatusProjection) On(event *common.Event) error {
+	view := p.views.apply(event)
+	if view == nil {
+		return nil
+	}
+	for _, group := range p.byStatus {
+		delete(group, view.ID)
+	}
+	if p.byStatus[view.Status] == nil {
+		p.byStatus[view.Status] = make(map[string]*TodoView)
+	}
+	p.byStatus[view.Status][view.ID] = view
+	return nil
+}
+
+// Todos returns the todos with the given status, sorted by title
+func (p *ByStatusProjection) Todos(status string) []TodoView {
+	return sortedViews(p.byStatus[status])
+}
+
+// State returns the todos keyed by status
+func (p *ByStatusProjection) State() interface{} {
+	state := make(map[string][]TodoView, len(p.byStatus))
+	for status, group := range p.byStatus {
+		if len(group) > 0 {
+			state[status] = sortedViews(group)
+		}
+	}
+	return state
+}
+
+// ByTagProjection indexes todos by each of their tags. Tags are fixed when a todo
+// is added, so only the views change afterwards.
+type ByTagProjection struct {
+	views todoViews
+	byTag map[string]map[string]*TodoView
+}
+
+// NewByTagProjection creates an empty by-tag projection
+func NewByTagProjection() *ByTagProjection {
+	return &ByTagProjection{
+		views: make(todoViews),
+		byTag: make(map[string]map[string]*TodoView),
+	}
+}
+
+// Name returns the registered name of the projection
+func (p *ByTagProjection) Name() string {
+	return ByTagProjectionName
+}
+
+// Consumes returns the event types the projection folds
+func (p *ByTagProjection) Consumes() []string {
+	return todoEventTypes
+}
+
+// On indexes newly added todos under their tags and keeps existing views current
+func (p *ByTagProjection) On(event *common.Event) error {
+	view := p.views.apply(event)
+	if view == nil || event.Type != EventTypeTodoAdded {
+		return nil
+	}
+	for _, tag := range view.Tags {
+		if p.byTag[tag] == nil {
+			p.byTag[tag] = make(map[string]*TodoView)
+		}
+		p.byTag[tag][view.ID] = view
+	}
+	return nil
+}
+
+// Todos returns the todos carrying the given tag, sorted by title
+func (p *ByTagProjection) Todos(tag string) []TodoView {
+	return sortedViews(p.byTag[tag])
+}
+
+// Tags returns every tag in use, sorted
+func (p *ByTagProjection) Tags() []string {
+	tags := make([]string, 0, len(p.byTag))
+	for tag := range p.byTag {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// State returns the todos keyed by tag
+func (p *ByTagProjection) State() interface{} {
+	state := make(map[string][]TodoView, len(p.byTag))
+	for tag, group := range p.byTag {
+		state[tag] = sortedViews(group)
+	}
+	return state
+}
+
+// OverdueProjection tracks the open todos that have a due date. Whether a todo is
+// overdue depends on when the question is asked, so the projection keeps every
+// candidate and filters at read time.
+type OverdueProjection struct {
+	views todoViews
+	now   func() time.Time
+}
+
+// NewOverdueProjection creates an empty overdue projection whose State is as of now
+func NewOverdueProjection() *OverdueProjection {
+	return &OverdueProjection{
+		views: make(todoViews),
+		now:   time.Now,
+	}
+}
+
+// Name returns the registered name of the projection
+func (p *OverdueProjection) Name() string {
+	return OverdueProjectionName
+}
+
+// Consumes returns the event types the projection folds
+func (p *OverdueProjection) Consumes() []string {
+	return todoEventTypes
+}
+
+// On applies a todo event to the view of its todo
+func (p *OverdueProjection) On(event *common.Event) error {
+	p.views.apply(event)
+	return nil
+}
+
+// Overdue returns the open todos due before asOf, most overdue first
+func (p *OverdueProjection) Overdue(asOf time.Time) []TodoView {
+	overdue := make([]TodoView, 0)
+	for _, view := range p.views {
+		if view.Status == StatusOpen && view.DueAt != nil && view.DueAt.Before(asOf) {
+			overdue = append(overdue, *view)
+		}
+	}
+	sort.Slice(overdue, func(i, j int) bool {
+		if !overdue[i].DueAt.Equal(*overdue[j].DueAt) {
+			return overdue[i].DueAt.Before(*overdue[j].DueAt)
+		}
+		return overdue[i].ID < overdue[j].ID
+	})
+	return overdue
+}
+
+// State returns the todos that are overdue now
+func (p *OverdueProjection) State() interface{} {
+	return p.Overdue(p.now())
+}
+
+// sortedViews copies a group of views sorted by title, then ID
+func sortedViews(group map[string]*TodoView) []TodoView {
+	views := make([]TodoView, 0, len(group))
+	for _, view := range group {
+		views = append(views, *view)
+	}
+	sort.Slice(views, func(i, j int) bool {
+		if views[i].Title != views[j].Title {
+			return views[i].Title < views[j].Title
+		}
+		return views[i].ID < views[j].ID
+	})
+	return views
+}
+
+func stringOf(data map[string]interface{}, key string) string {
+	s, _ := data[key].(string)
+	return s
+}
@@ -0,0 +1,129 @@
+// Package todo provides the queries of the todo domain and the read models that
+// answer them.
+package todo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+)
+
+// Query type names
+const (
+	QueryTypeTodosByStatus = "TodosByStatus"
+	QueryTypeTodosByTag    = "TodosByTag"
+	QueryTypeOverdueTodos  = "OverdueTodos"
+)
+
+// TodosByStatusQuery asks for the todos with a status
+type TodosByStatusQuery struct {
+	Status string `json:"status"`
+}
+
+// TodosByTagQuery asks for the todos carrying a tag
+type TodosByTagQuery struct {
+	Tag string `json:"tag"`
+}
+
+// OverdueTodosQuery asks for the open todos due before AsOf, or before now when
+// AsOf is zero
+type OverdueTodosQuery struct {
+	AsOf time.Time `json:"as_of,omitempty"`
+}
+
+func (q *TodosByStatusQuery) QueryType() string { return QueryTypeTodosByStatus }
+func (q *TodosByTagQuery) QueryType() string    { return QueryTypeTodosByTag }
+func (q *OverdueTodosQuery) QueryType() string  { return QueryTypeOverdueTodos }
+
+// ReadModels holds the todo projections and keeps each at its own checkpoint in the
+// global event log. Queries catch the projections up before reading, so a query
+// always sees every event appended before it was asked.
+type ReadModels struct {
+	mu          sync.Mutex
+	store       common.Store
+	checkpoints map[string]int
+
+	ByStatus *ByStatusProjection
+	ByTag    *ByTagProjection
+	Overdue  *OverdueProjection
+}
+
+// NewReadModels creates empty read models over the store
+func NewReadModels(store common.Store) *ReadModels {
+	return &ReadModels{
+		store:       store,
+		checkpoints: make(map[string]int),
+		ByStatus:    NewByStatusProjection(),
+		ByTag:       NewByTagProjection(),
+		Overdue:     NewOverdueProjection(),
+	}
+}
+
+// CatchUp applies the events appended since the last catch-up to every projection
+func (m *ReadModels) CatchUp() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.catchUp()
+}
+
+// Checkpoints returns the checkpoint of every projection, sorted by projection name
+func (m *ReadModels) Checkpoints() []common.Checkpoint {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	checkpoints := make([]common.Checkpoint, 0, 3)
+	for _, projection := range m.projections() {
+		checkpoints = append(checkpoints, common.Checkpoint{Projection: projection.Name(), Position: m.checkpoints[projection.Name()]})
+	}
+	return checkpoints
+}
+
+// read catches the projections up and calls fn while holding the lock
+func (m *ReadModels) read(fn func() interface{}) (interface{}, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.catchUp(); err != nil {
+		return nil, err
+	}
+	return fn(), nil
+}
+
+// catchUp replays the log into every projection. Callers must hold m.mu.
+func (m *ReadModels) catchUp() error {
+	for _, projection := range m.projections() {
+		checkpoint, err := common.ReplayProjection(m.store, projection, m.checkpoints[projection.Name()], nil)
+		m.checkpoints[projection.Name()] = checkpoint.Position
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// projections returns the projections sorted by name
+func (m *ReadModels) projections() []common.Projection {
+	return []common.Projection{m.ByStatus, m.ByTag, m.Overdue}
+}
+
+// RegisterQueries registers a handler for every todo query. Results are []TodoView.
+func RegisterQueries(queries *bus.QueryBus, models *ReadModels) {
+	queries.Register(QueryTypeTodosByStatus, func(_ context.Context, query common.Query) (interface{}, error) {
+		status := query.(*TodosByStatusQuery).Status
+		return models.read(func() interface{} { return models.ByStatus.Todos(status) })
+	})
+	queries.Register(QueryTypeTodosByTag, func(_ context.Context, query common.Query) (interface{}, error) {
+		tag := query.(*TodosByTagQuery).Tag
+		return models.read(func() interface{} { return models.ByTag.Todos(tag) })
+	})
+	queries.Register(QueryTypeOverdueTodos, func(_ context.Context, query common.Query) (interface{}, error) {
+		asOf := query.(*OverdueTodosQuery).AsOf
+		if asOf.IsZero() {
+			asOf = time.Now()
+		}
+		return models.read(func() interface{} { return models.Overdue.Overdue(asOf) })
+	})
+}
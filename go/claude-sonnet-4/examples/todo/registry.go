@@ -0,0 +1,69 @@
+// Package todo registers the todo domain with the common registry so tooling can
+// discover its commands, events, aggregate, and projections.
+package todo
+
+import "simple-event-modeling/common"
+
+// AggregateTypeTodo is the registered name of the todo aggregate
+const AggregateTypeTodo = "Todo"
+
+// Command type names
+const (
+	CommandTypeAddTodo        = "AddTodo"
+	CommandTypeCompleteTodo   = "CompleteTodo"
+	CommandTypeReopenTodo     = "ReopenTodo"
+	CommandTypeRescheduleTodo = "RescheduleTodo"
+)
+
+func init() {
+	register(common.DefaultRegistry)
+}
+
+func register(registry *common.Registry) {
+	registry.RegisterAggregate(common.AggregateInfo{Name: AggregateTypeTodo})
+
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeAddTodo,
+		Aggregate: AggregateTypeTodo,
+		Produces:  []string{EventTypeTodoAdded},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeCompleteTodo,
+		Aggregate: AggregateTypeTodo,
+		Produces:  []string{EventTypeTodoCompleted},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeReopenTodo,
+		Aggregate: AggregateTypeTodo,
+		Produces:  []string{EventTypeTodoReopened},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeRescheduleTodo,
+		Aggregate: AggregateTypeTodo,
+		Produces:  []string{EventTypeTodoRescheduled},
+	})
+
+	dueAtField := common.FieldInfo{Name: "due_at", Type: "string", Description: "RFC 3339 due date"}
+	registry.RegisterEvent(common.EventInfo{
+		Name:      EventTypeTodoAdded,
+		Aggregate: AggregateTypeTodo,
+		Payload: []common.FieldInfo{
+			{Name: "title", Type: "string", Description: "What needs doing"},
+			{Name: "tags", Type: "array", Description: "Labels for grouping todos"},
+			dueAtField,
+		},
+	})
+	registry.RegisterEvent(common.EventInfo{Name: EventTypeTodoCompleted, Aggregate: AggregateTypeTodo})
+	registry.RegisterEvent(common.EventInfo{Name: EventTypeTodoReopened, Aggregate: AggregateTypeTodo})
+	registry.RegisterEvent(common.EventInfo{Name: EventTypeTodoRescheduled, Aggregate: AggregateTypeTodo, Payload: []common.FieldInfo{dueAtField}})
+
+	registry.RegisterProjection(ByStatusProjectionName, func() common.Projection {
+		return NewByStatusProjection()
+	})
+	registry.RegisterProjection(ByTagProjectionName, func() common.Projection {
+		return NewByTagProjection()
+	})
+	registry.RegisterProjection(OverdueProjectionName, func() common.Projection {
+		return NewOverdueProjection()
+	})
+}
@@ -0,0 +1,14 @@
+// Package todo provides a todo-list domain built on the common framework.
+// The write side is deliberately small so the package can focus on the read side:
+// several projections fold the same events into differently shaped read models,
+// and a query bus routes reads to the read model that answers them.
+//
+// The package is organized into separate files for each major concept:
+// - commands.go: Command types (AddTodo, CompleteTodo, ReopenTodo, RescheduleTodo)
+// - events.go: Event types and creation functions (TodoAdded, TodoCompleted, etc.)
+// - aggregate.go: TodoAggregate implementation with business rules
+// - projections.go: By-status, by-tag, and overdue projections
+// - queries.go: Query types, the read models answering them, and query handlers
+// - registry.go: Registration with the common registry
+// - bus.go: Registration of command handlers with a command bus
+package todo
@@ -0,0 +1,141 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+	"testing"
+	"time"
+)
+
+func addTodo(t *testing.T, commands *bus.CommandBus, title string, dueAt time.Time, tags ...string) string {
+	t.Helper()
+	event, err := commands.Dispatch(context.Background(), &AddTodoCommand{Title: title, Tags: tags, DueAt: dueAt})
+	if err != nil {
+		t.Fatalf("Error adding todo: %v", err)
+	}
+	return event.AggregateID
+}
+
+func titles(result interface{}) []string {
+	views := result.([]TodoView)
+	out := make([]string, len(views))
+	for i, view := range views {
+		out[i] = view.Title
+	}
+	return out
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestTodoAggregate_StatusRules(t *testing.T) {
+	store := common.NewEventStore()
+	added, _ := NewTodoAggregate(store).Handle(&AddTodoCommand{Title: "Buy milk"})
+	todoID := added.AggregateID
+
+	var invalid *common.InvalidCommandError
+	if _, err := NewTodoAggregate(store).Handle(&ReopenTodoCommand{TodoID: todoID}); !errors.As(err, &invalid) {
+		t.Errorf("Expected reopening an open todo to be rejected, got %v", err)
+	}
+	if _, err := NewTodoAggregate(store).Handle(&CompleteTodoCommand{TodoID: todoID}); err != nil {
+		t.Fatalf("Error completing todo: %v", err)
+	}
+	if _, err := NewTodoAggregate(store).Handle(&RescheduleTodoCommand{TodoID: todoID, DueAt: time.Now()}); !errors.As(err, &invalid) {
+		t.Errorf("Expected rescheduling a done todo to be rejected, got %v", err)
+	}
+
+	todo := NewTodoAggregate(store)
+	todo.Hydrate(todoID)
+	if todo.Status() != StatusDone || todo.Version() != 2 {
+		t.Errorf("Expected done at version 2, got %s at %d", todo.Status(), todo.Version())
+	}
+}
+
+func TestReadModels_AnswerQueriesFromTheSameEvents(t *testing.T) {
+	store := common.NewEventStore()
+	commands := bus.NewCommandBus(bus.Validation())
+	RegisterCommands(commands, store)
+	queries := bus.NewQueryBus()
+	models := NewReadModels(store)
+	RegisterQueries(queries, models)
+	ctx := context.Background()
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	milk := addTodo(t, commands, "Buy milk", now.Add(-2*time.Hour), "home", "errands")
+	taxes := addTodo(t, commands, "File taxes", now.Add(-48*time.Hour), "home")
+	addTodo(t, commands, "Book flights", now.Add(24*time.Hour), "travel")
+	addTodo(t, commands, "Water plants", time.Time{}, "home")
+	commands.Dispatch(ctx, &CompleteTodoCommand{TodoID: milk})
+
+	result, err := queries.Ask(ctx, &TodosByStatusQuery{Status: StatusOpen})
+	if err != nil {
+		t.Fatalf("Error asking: %v", err)
+	}
+	if got := titles(result); !equal(got, []string{"Book flights", "File taxes", "Water plants"}) {
+		t.Errorf("Unexpected open todos: %v", got)
+	}
+	result, _ = queries.Ask(ctx, &TodosByStatusQuery{Status: StatusDone})
+	if got := titles(result); !equal(got, []string{"Buy milk"}) {
+		t.Errorf("Unexpected done todos: %v", got)
+	}
+
+	result, _ = queries.Ask(ctx, &TodosByTagQuery{Tag: "home"})
+	views := result.([]TodoView)
+	if got := titles(result); !equal(got, []string{"Buy milk", "File taxes", "Water plants"}) || views[0].Status != StatusDone {
+		t.Errorf("Unexpected home todos: %+v", views)
+	}
+
+	result, _ = queries.Ask(ctx, &OverdueTodosQuery{AsOf: now})
+	if got := titles(result); !equal(got, []string{"File taxes"}) {
+		t.Errorf("Expected only the open past-due todo to be overdue, got %v", got)
+	}
+
+	// Queries catch up with events appended after the previous query
+	commands.Dispatch(ctx, &RescheduleTodoCommand{TodoID: taxes, DueAt: now.Add(time.Hour)})
+	result, _ = queries.Ask(ctx, &OverdueTodosQuery{AsOf: now})
+	if got := titles(result); len(got) != 0 {
+		t.Errorf("Expected no overdue todos after rescheduling, got %v", got)
+	}
+	for _, checkpoint := range models.Checkpoints() {
+		if checkpoint.Position != len(store.GetAllEvents()) {
+			t.Errorf("Expected %s to be caught up, got %+v", checkpoint.Projection, checkpoint)
+		}
+	}
+
+	var unknown *common.UnknownQueryError
+	if _, err := queries.Ask(ctx, unknownQuery{}); !errors.As(err, &unknown) || len(unknown.Registered) != 3 {
+		t.Errorf("Expected UnknownQueryError listing the todo queries, got %v", err)
+	}
+}
+
+func TestProjections_RebuildFromRegistry(t *testing.T) {
+	store := common.NewEventStore()
+	commands := bus.NewCommandBus()
+	RegisterCommands(commands, store)
+	addTodo(t, commands, "Buy milk", time.Time{}, "home")
+
+	for _, name := range []string{ByStatusProjectionName, ByTagProjectionName, OverdueProjectionName} {
+		projection, err := common.DefaultRegistry.NewProjection(name)
+		if err != nil {
+			t.Fatalf("Expected %s to be registered: %v", name, err)
+		}
+		if _, err := common.ReplayProjection(store, projection, 0, nil); err != nil {
+			t.Errorf("Error replaying %s: %v", name, err)
+		}
+	}
+}
+
+type unknownQuery struct{}
+
+func (unknownQuery) QueryType() string { return "Unknown" }
@@ -0,0 +1,249 @@
+// Package faultstore provides a Store decorator that injects the failures real event
+// stores exhibit: slow operations, transient append failures, and subscriptions that
+// deliver events out of order. Wrapping a store with it hardens aggregates, retry
+// middleware, and projections against those failures in tests.
+package faultstore
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"simple-event-modeling/common"
+)
+
+// ErrTransient is returned by Append when an injected failure occurs. The event is
+// not stored, so the append can safely be retried.
+var ErrTransient = errors.New("transient append failure")
+
+// Config selects the faults to inject. The zero value injects none.
+type Config struct {
+	// Latency is added to every store operation
+	Latency time.Duration
+	// Jitter adds up to this much random latency on top of Latency
+	Jitter time.Duration
+	// AppendFailureRate is the probability, from 0 to 1, that an Append fails with ErrTransient
+	AppendFailureRate float64
+	// ReorderWindow shuffles subscription delivery within batches of up to this many
+	// events; values below 2 keep delivery in order
+	ReorderWindow int
+	// Seed makes the injected faults reproducible; 0 seeds from the clock
+	Seed int64
+}
+
+// FaultStore is a Store that injects faults into the calls it passes to an inner store
+type FaultStore struct {
+	store  common.Store
+	config Config
+
+	mu        sync.Mutex
+	rand      *rand.Rand
+	failNext  int
+	failAfter int // appends left until the one that fails, plus one; 0 for none
+	failures  int
+}
+
+var (
+	_ common.Store         = (*FaultStore)(nil)
+	_ common.BatchAppender = (*FaultStore)(nil)
+)
+
+// New wraps store with the faults selected by config
+func New(store common.Store, config Config) *FaultStore {
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &FaultStore{
+		store:  store,
+		config: config,
+		rand:   rand.New(rand.NewSource(seed)),
+	}
+}
+
+// FailNextAppends makes the next n appends fail with ErrTransient regardless of
+// AppendFailureRate, for tests that need a deterministic failure
+func (fs *FaultStore) FailNextAppends(n int) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.failNext = n
+}
+
+// FailAppendAfter lets the next n events be appended and makes the one after fail
+// with ErrTransient, for tests that need an operation to fail midway through its
+// appends
+func (fs *FaultStore) FailAppendAfter(n int) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.failAfter = n + 1
+}
+
+// Failures returns the number of appends that failed with ErrTransient
+func (fs *FaultStore) Failures() int {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.failures
+}
+
+// Append passes the event to the inner store unless an injected failure occurs
+func (fs *FaultStore) Append(event *common.Event) error {
+	fs.delay()
+	if fs.failAppend() {
+		return ErrTransient
+	}
+	return fs.store.Append(event)
+}
+
+// AppendBatch appends the events together unless an injected failure hits any of
+// them, in which case none is stored. Inner stores that can't append a batch
+// atomically get the events one by one.
+func (fs *FaultStore) AppendBatch(events []*common.Event) error {
+	fs.delay()
+	for range events {
+		if fs.failAppend() {
+			return ErrTransient
+		}
+	}
+	if batch, ok := fs.store.(common.BatchAppender); ok {
+		return batch.AppendBatch(events)
+	}
+	for _, event := range events {
+		if err := fs.store.Append(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetStream retrieves all events for a given aggregate ID
+func (fs *FaultStore) GetStream(aggregateID string) ([]*common.Event, error) {
+	fs.delay()
+	return fs.store.GetStream(aggregateID)
+}
+
+// GetStreamVersion returns the current version of a stream
+func (fs *FaultStore) GetStreamVersion(aggregateID string) int {
+	fs.delay()
+	return fs.store.GetStreamVersion(aggregateID)
+}
+
+// StreamLength returns the number of events in a stream
+func (fs *FaultStore) StreamLength(aggregateID string) int {
+	fs.delay()
+	return fs.store.StreamLength(aggregateID)
+}
+
+// HeadEvent returns the latest event of a stream
+func (fs *FaultStore) HeadEvent(aggregateID string) (*common.Event, error) {
+	fs.delay()
+	return fs.store.HeadEvent(aggregateID)
+}
+
+// GetAllEvents returns every event in append order
+func (fs *FaultStore) GetAllEvents() []*common.Event {
+	fs.delay()
+	return fs.store.GetAllEvents()
+}
+
+// StreamIDs returns the identifiers of all streams in the store, sorted
+func (fs *FaultStore) StreamIDs() []string {
+	fs.delay()
+	return fs.store.StreamIDs()
+}
+
+// SubscribeStream follows a stream like common.SubscribeStream, but delivers the
+// events of each batch in random order when ReorderWindow is set. A batch is sent
+// once it holds ReorderWindow events, and a partial batch at every interval, so
+// consumers see every event exactly once, just not necessarily in version order.
+func (fs *FaultStore) SubscribeStream(ctx context.Context, streamID string, fromVersion int, interval time.Duration) <-chan *common.Event {
+	in := common.SubscribeStream(ctx, fs, streamID, fromVersion, interval)
+	if fs.config.ReorderWindow < 2 {
+		return in
+	}
+	if interval <= 0 {
+		interval = common.DefaultPollInterval
+	}
+
+	out := make(chan *common.Event)
+	go func() {
+		defer close(out)
+
+		var batch []*common.Event
+		flush := func() bool {
+			fs.shuffle(batch)
+			for _, event := range batch {
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return false
+				}
+			}
+			batch = batch[:0]
+			return true
+		}
+
+		timer := time.NewTimer(interval)
+		defer timer.Stop()
+		for {
+			select {
+			case event, ok := <-in:
+				if !ok {
+					return
+				}
+				batch = append(batch, event)
+				if len(batch) >= fs.config.ReorderWindow && !flush() {
+					return
+				}
+			case <-timer.C:
+				if !flush() {
+					return
+				}
+				timer.Reset(interval)
+			}
+		}
+	}()
+	return out
+}
+
+// delay sleeps for the configured latency plus jitter
+func (fs *FaultStore) delay() {
+	latency := fs.config.Latency
+	if fs.config.Jitter > 0 {
+		fs.mu.Lock()
+		latency += time.Duration(fs.rand.Int63n(int64(fs.config.Jitter)))
+		fs.mu.Unlock()
+	}
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+}
+
+// failAppend decides whether the current append fails and counts the failure
+func (fs *FaultStore) failAppend() bool {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fail := false
+	if fs.failAfter > 0 {
+		fs.failAfter--
+		fail = fs.failAfter == 0
+	}
+	if !fail && fs.failNext > 0 {
+		fs.failNext--
+		fail = true
+	} else if !fail && fs.config.AppendFailureRate > 0 {
+		fail = fs.rand.Float64() < fs.config.AppendFailureRate
+	}
+	if fail {
+		fs.failures++
+	}
+	return fail
+}
+
+func (fs *FaultStore) shuffle(events []*common.Event) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.rand.Shuffle(len(events), func(i, j int) { events[i], events[j] = events[j], events[i] })
+}
@@ -0,0 +1,103 @@
+package faultstore
+
+import (
+	"context"
+	"errors"
+	"simple-event-modeling/common"
+	"testing"
+	"time"
+)
+
+func TestFaultStore_TransientAppendFailures(t *testing.T) {
+	store := New(common.NewEventStore(), Config{})
+	store.FailNextAppends(2)
+
+	event := common.NewEvent("Created", "s-1", 1, nil, nil)
+	for i := 0; i < 2; i++ {
+		if err := store.Append(event); !errors.Is(err, ErrTransient) {
+			t.Fatalf("Expected ErrTransient on attempt %d, got %v", i+1, err)
+		}
+	}
+	if store.GetStreamVersion("s-1") != 0 {
+		t.Error("Expected failed appends not to be stored")
+	}
+	if err := store.Append(event); err != nil {
+		t.Fatalf("Expected the retried append to succeed, got %v", err)
+	}
+	if store.Failures() != 2 {
+		t.Errorf("Expected 2 failures, got %d", store.Failures())
+	}
+}
+
+func TestFaultStore_FailedBatchStoresNothing(t *testing.T) {
+	store := New(common.NewEventStore(), Config{})
+	store.FailAppendAfter(1)
+
+	batch := []*common.Event{common.NewEvent("Created", "s-1", 1, nil, nil), common.NewEvent("Renamed", "s-1", 2, nil, nil)}
+	if err := store.AppendBatch(batch); !errors.Is(err, ErrTransient) {
+		t.Fatalf("Expected the second event to fail the batch, got %v", err)
+	}
+	if store.GetStreamVersion("s-1") != 0 {
+		t.Error("Expected no event of the failed batch to be stored")
+	}
+	if err := store.AppendBatch(batch); err != nil || store.GetStreamVersion("s-1") != 2 {
+		t.Errorf("Expected the retried batch to be stored, got version %d (%v)", store.GetStreamVersion("s-1"), err)
+	}
+}
+
+func TestFaultStore_AppendFailureRateIsReproducible(t *testing.T) {
+	run := func() int {
+		store := New(common.NewEventStore(), Config{AppendFailureRate: 0.5, Seed: 42})
+		for version := 1; version <= 20; {
+			if store.Append(common.NewEvent("Ticked", "s-1", version, nil, nil)) == nil {
+				version++
+			}
+		}
+		return store.Failures()
+	}
+
+	failures := run()
+	if failures == 0 || failures != run() {
+		t.Errorf("Expected the same nonzero number of failures for the same seed, got %d", failures)
+	}
+}
+
+func TestFaultStore_Latency(t *testing.T) {
+	store := New(common.NewEventStore(), Config{Latency: 5 * time.Millisecond, Jitter: 5 * time.Millisecond})
+
+	start := time.Now()
+	store.GetStreamVersion("s-1")
+	if elapsed := time.Since(start); elapsed < 5*time.Millisecond {
+		t.Errorf("Expected at least 5ms of latency, got %v", elapsed)
+	}
+}
+
+func TestFaultStore_SubscribeStreamReordersDelivery(t *testing.T) {
+	inner := common.NewEventStore()
+	for version := 1; version <= 20; version++ {
+		inner.Append(common.NewEvent("Ticked", "s-1", version, nil, nil))
+	}
+	store := New(inner, Config{ReorderWindow: 5, Seed: 7})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := store.SubscribeStream(ctx, "s-1", 0, 10*time.Millisecond)
+
+	seen := make(map[int]bool)
+	inOrder := true
+	for i := 0; i < 20; i++ {
+		select {
+		case event := <-events:
+			if seen[event.Version] {
+				t.Fatalf("Version %d delivered twice", event.Version)
+			}
+			seen[event.Version] = true
+			inOrder = inOrder && event.Version == i+1
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out after %d events", i)
+		}
+	}
+	if inOrder {
+		t.Error("Expected delivery to be reordered")
+	}
+}
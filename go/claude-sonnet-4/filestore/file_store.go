@@ -0,0 +1,295 @@
+// Package filestore provides a durable Store backend that keeps events in a JSON-lines file.
+// It is intended for CLI tools, demos, and tests that need events to survive a restart
+// without running a database server.
+//
+// Events are written as one JSON object per line by default. A store opened
+// WithSerializer writes events of other formats as their content type and the
+// base64 of the serialized event, separated by a space, so lines of several formats
+// can share a file. Blank lines are skipped.
+//
+// Several processes may share a file on Unix: an append holds an exclusive advisory
+// lock (flock) on it from reading the latest events through writing its own line, so
+// concurrent appends to a stream are checked against each other's versions. Other
+// platforms have no lock, and only one process may append to a file there.
+package filestore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"simple-event-modeling/common"
+	"simple-event-modeling/serialization"
+)
+
+// FileStore is a Store backed by an append-only JSON-lines file.
+// Events appended by other processes are picked up on the next read. A line that
+// cannot be decoded fails every read from then on rather than yielding a log that
+// stops short of it.
+type FileStore struct {
+	mu      sync.Mutex
+	path    string
+	codec   serialization.Codec
+	offset  int64
+	events  []*common.Event
+	streams map[string][]*common.Event
+}
+
+var _ common.Store = (*FileStore)(nil)
+
+// Option configures a FileStore
+type Option func(*FileStore)
+
+// WithSerializer writes events with serializer instead of JSON. Lines already in the
+// file are read whatever their format, as long as the serialization registry knows
+// it.
+func WithSerializer(serializer serialization.Serializer) Option {
+	return func(fs *FileStore) {
+		fs.codec = serialization.NewCodec(serializer, nil)
+	}
+}
+
+// Open opens the event file at path, creating it if it doesn't exist
+func Open(path string, opts ...Option) (*FileStore, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+
+	fs := &FileStore{
+		path:    path,
+		events:  make([]*common.Event, 0),
+		streams: make(map[string][]*common.Event),
+	}
+	for _, opt := range opts {
+		opt(fs)
+	}
+	if err := fs.load(); err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+// Path returns the location of the event file
+func (fs *FileStore) Path() string {
+	return fs.path
+}
+
+// Append writes an event to the end of the file. The event must directly follow the
+// stream's current version, otherwise a *common.ConcurrencyError is returned.
+func (fs *FileStore) Append(event *common.Event) error {
+	line, err := fs.encode(event)
+	if err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	f, err := os.OpenFile(fs.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// Keep other processes out until the line is written, so none appends the same
+	// version in between
+	if err := lockFile(f); err != nil {
+		return fmt.Errorf("locking %s: %w", fs.path, err)
+	}
+
+	if err := fs.load(); err != nil {
+		return err
+	}
+	if err := common.CheckVersion(event, fs.streamVersion(event.AggregateID)); err != nil {
+		return err
+	}
+
+	n, err := f.Write(append(line, '\n'))
+	if err != nil {
+		return err
+	}
+	fs.offset += int64(n)
+	fs.add(event)
+	return nil
+}
+
+// GetStream retrieves all events for a given aggregate ID
+func (fs *FileStore) GetStream(aggregateID string) ([]*common.Event, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err := fs.load(); err != nil {
+		return nil, err
+	}
+	stream, exists := fs.streams[aggregateID]
+	if !exists {
+		return nil, &common.StreamNotFoundError{StreamID: aggregateID}
+	}
+	return append([]*common.Event(nil), stream...), nil
+}
+
+// GetStreamVersion returns the current version of a stream
+func (fs *FileStore) GetStreamVersion(aggregateID string) int {
+	head, err := fs.HeadEvent(aggregateID)
+	if err != nil {
+		return 0
+	}
+	return head.Version
+}
+
+// StreamLength returns the number of events in a stream, without copying them, or
+// 0 if the file cannot be read
+func (fs *FileStore) StreamLength(aggregateID string) int {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.load() != nil {
+		return 0
+	}
+	return len(fs.streams[aggregateID])
+}
+
+// HeadEvent returns the latest event of a stream, without copying the stream
+func (fs *FileStore) HeadEvent(aggregateID string) (*common.Event, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err := fs.load(); err != nil {
+		return nil, err
+	}
+	stream := fs.streams[aggregateID]
+	if len(stream) == 0 {
+		return nil, &common.StreamNotFoundError{StreamID: aggregateID}
+	}
+	return stream[len(stream)-1], nil
+}
+
+// GetAllEvents returns all events in the file in append order. When the file cannot
+// be read it returns no events rather than the ones before the failure; call
+// ReadAll for the error.
+func (fs *FileStore) GetAllEvents() []*common.Event {
+	events, err := fs.ReadAll()
+	if err != nil {
+		return nil
+	}
+	return events
+}
+
+// ReadAll returns all events in the file in append order, or the error reading or
+// decoding it
+func (fs *FileStore) ReadAll() ([]*common.Event, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err := fs.load(); err != nil {
+		return nil, err
+	}
+	return append([]*common.Event(nil), fs.events...), nil
+}
+
+// StreamIDs returns the identifiers of all streams in the file, sorted, or none if
+// the file cannot be read
+func (fs *FileStore) StreamIDs() []string {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.load() != nil {
+		return []string{}
+	}
+	ids := make([]string, 0, len(fs.streams))
+	for id := range fs.streams {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// load reads any events written to the file since the last load.
+// Callers must hold fs.mu.
+func (fs *FileStore) load() error {
+	f, err := os.Open(fs.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(fs.offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// A partial trailing line is still being written; pick it up next time
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(bytes.TrimSpace(line)) == 0 {
+			fs.offset += int64(len(line))
+			continue
+		}
+		event, err := fs.decode(line)
+		if err != nil {
+			return fmt.Errorf("decoding event at offset %d: %w", fs.offset, err)
+		}
+		fs.offset += int64(len(line))
+		fs.add(event)
+	}
+}
+
+// encode renders event as a line, without the trailing newline
+func (fs *FileStore) encode(event *common.Event) ([]byte, error) {
+	record, err := fs.codec.Encode(event)
+	if err != nil {
+		return nil, err
+	}
+	contentType, body, err := serialization.Split(record)
+	if err != nil || contentType == serialization.ContentTypeJSON {
+		return record, err
+	}
+	line := make([]byte, 0, len(contentType)+1+base64.StdEncoding.EncodedLen(len(body)))
+	line = append(line, contentType...)
+	line = append(line, ' ')
+	return base64.StdEncoding.AppendEncode(line, body), nil
+}
+
+// decode parses a line written by encode
+func (fs *FileStore) decode(line []byte) (*common.Event, error) {
+	line = bytes.TrimSpace(line)
+	if line[0] == '{' {
+		return fs.codec.Decode(line)
+	}
+	contentType, encoded, found := bytes.Cut(line, []byte{' '})
+	if !found {
+		return nil, fmt.Errorf("malformed line: no content type")
+	}
+	body, err := base64.StdEncoding.AppendDecode(nil, encoded)
+	if err != nil {
+		return nil, err
+	}
+	return fs.codec.Decode(serialization.Frame(string(contentType), body))
+}
+
+func (fs *FileStore) add(event *common.Event) {
+	fs.events = append(fs.events, event)
+	fs.streams[event.AggregateID] = append(fs.streams[event.AggregateID], event)
+}
+
+// streamVersion returns the version of a loaded stream. Callers hold fs.mu.
+func (fs *FileStore) streamVersion(aggregateID string) int {
+	stream := fs.streams[aggregateID]
+	if len(stream) == 0 {
+		return 0
+	}
+	return stream[len(stream)-1].Version
+}
@@ -0,0 +1,153 @@
+package filestore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"simple-event-modeling/common"
+	"simple-event-modeling/serialization"
+	"testing"
+)
+
+func TestFileStore_AppendAndReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+	store.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+	store.Append(common.NewEvent("ItemAdded", "cart-1", 2, map[string]interface{}{"item": "apple"}, nil))
+	store.Append(common.NewEvent("CartCreated", "cart-2", 1, nil, nil))
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Error reopening store: %v", err)
+	}
+	if len(reopened.GetAllEvents()) != 3 {
+		t.Errorf("Expected 3 events after reopen, got %d", len(reopened.GetAllEvents()))
+	}
+	if version := reopened.GetStreamVersion("cart-1"); version != 2 {
+		t.Errorf("Expected cart-1 version 2, got %d", version)
+	}
+	if length := reopened.StreamLength("cart-1"); length != 2 {
+		t.Errorf("Expected cart-1 to hold 2 events, got %d", length)
+	}
+	if head, err := reopened.HeadEvent("cart-1"); err != nil || head.Data["item"] != "apple" {
+		t.Errorf("Expected the ItemAdded event at the head, got %v (%v)", head, err)
+	}
+
+	events, err := reopened.GetStream("cart-1")
+	if err != nil {
+		t.Fatalf("Error getting stream: %v", err)
+	}
+	if events[1].Data["item"] != "apple" {
+		t.Errorf("Expected item data to round-trip, got %v", events[1].Data)
+	}
+
+	ids := reopened.StreamIDs()
+	if len(ids) != 2 || ids[0] != "cart-1" || ids[1] != "cart-2" {
+		t.Errorf("Unexpected stream IDs: %v", ids)
+	}
+}
+
+func TestFileStore_SeesAppendsFromOtherInstances(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	reader, err := Open(path)
+	if err != nil {
+		t.Fatalf("Error opening reader: %v", err)
+	}
+	writer, err := Open(path)
+	if err != nil {
+		t.Fatalf("Error opening writer: %v", err)
+	}
+
+	writer.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+
+	if version := reader.GetStreamVersion("cart-1"); version != 1 {
+		t.Errorf("Expected reader to see version 1, got %d", version)
+	}
+}
+
+func TestFileStore_StreamNotFound(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "events.jsonl"))
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+	if _, err := store.GetStream("missing"); err == nil {
+		t.Error("Expected error for missing stream")
+	}
+}
+
+func TestFileStore_RejectsConflictingAppendFromOtherInstance(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	first, _ := Open(path)
+	second, _ := Open(path)
+
+	first.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+	err := second.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+
+	var conflict *common.ConcurrencyError
+	if !errors.As(err, &conflict) {
+		t.Errorf("Expected ConcurrencyError, got %v", err)
+	}
+}
+
+func TestFileStore_SkipsBlankLinesAndFailsOnCorruptOnes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	store, _ := Open(path)
+	store.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	f.WriteString("\n  \n")
+	f.Close()
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Expected blank lines skipped, got %v", err)
+	}
+	if err := reopened.Append(common.NewEvent("ItemAdded", "cart-1", 2, nil, nil)); err != nil {
+		t.Fatalf("Error appending after blank lines: %v", err)
+	}
+	if all := store.GetAllEvents(); len(all) != 2 {
+		t.Errorf("Expected both events read past the blank lines, got %d", len(all))
+	}
+
+	f, _ = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	f.WriteString("{not json\n")
+	f.Close()
+	if _, err := store.ReadAll(); err == nil {
+		t.Error("Expected an error reading a corrupt line")
+	}
+	if all := store.GetAllEvents(); all != nil {
+		t.Errorf("Expected no events rather than a truncated log, got %d", len(all))
+	}
+	if ids := store.StreamIDs(); len(ids) != 0 || store.StreamLength("cart-1") != 0 {
+		t.Errorf("Expected no streams listed from an unreadable file, got %v", ids)
+	}
+}
+
+func TestFileStore_MixesSerializers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	store, _ := Open(path)
+	store.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+
+	proto, err := Open(path, WithSerializer(serialization.Protobuf{}))
+	if err != nil {
+		t.Fatalf("Error reopening store with protobuf: %v", err)
+	}
+	if err := proto.Append(common.NewEvent("ItemAdded", "cart-1", 2, map[string]interface{}{"item": "apple"}, nil)); err != nil {
+		t.Fatalf("Error appending protobuf event: %v", err)
+	}
+	store.Append(common.NewEvent("ItemRemoved", "cart-1", 3, map[string]interface{}{"item": "apple"}, nil))
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Error reopening store: %v", err)
+	}
+	events, _ := reopened.GetStream("cart-1")
+	if len(events) != 3 || events[1].Data["item"] != "apple" || events[2].Type != "ItemRemoved" {
+		t.Errorf("Expected all three events whatever their format, got %v", events)
+	}
+}
@@ -0,0 +1,46 @@
+//go:build unix
+
+package filestore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"simple-event-modeling/common"
+	"testing"
+	"time"
+)
+
+func TestFileStore_AppendWaitsForOtherProcesses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	store, _ := Open(path)
+
+	// Another process holds the lock while it appends version 1
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("Error opening file: %v", err)
+	}
+	if err := lockFile(f); err != nil {
+		t.Fatalf("Error locking file: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- store.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("Expected the append to wait for the lock, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	line, _ := store.encode(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+	f.Write(append(line, '\n'))
+	f.Close()
+
+	var conflict *common.ConcurrencyError
+	if err := <-done; !errors.As(err, &conflict) {
+		t.Errorf("Expected ConcurrencyError once the lock is released, got %v", err)
+	}
+	if all := store.GetAllEvents(); len(all) != 1 {
+		t.Errorf("Expected version 1 stored once, got %d events", len(all))
+	}
+}
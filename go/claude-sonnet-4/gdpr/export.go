@@ -0,0 +1,119 @@
+// Package gdpr collects the events concerning a data subject for subject access
+// requests. Export scans every stream for events that reference the subject through
+// their metadata (common.CustomerIDKey, common.ActorIDKey), their payload, or their
+// stream ID, and returns them in a portable JSON report.
+package gdpr
+
+import (
+	"sort"
+	"time"
+
+	"simple-event-modeling/common"
+)
+
+// Options selects where Export looks for the subject
+type Options struct {
+	// MetadataKeys are the metadata keys holding subject IDs; nil uses
+	// common.CustomerIDKey and common.ActorIDKey
+	MetadataKeys []string
+	// DataFields limits payload matching to these top-level fields; nil searches the
+	// whole payload, including nested values
+	DataFields []string
+}
+
+// Match is an event concerning the subject
+type Match struct {
+	Event *common.Event `json:"event"`
+	// MatchedOn lists where the subject was found, e.g. "metadata.customer_id",
+	// "data.owner", or "stream"
+	MatchedOn []string `json:"matched_on"`
+}
+
+// Report is the result of a subject access export
+type Report struct {
+	Subject     string    `json:"subject"`
+	GeneratedAt time.Time `json:"generated_at"`
+	// Streams lists the streams holding at least one matching event, sorted
+	Streams []string `json:"streams"`
+	// Events are the matching events in append order
+	Events []Match `json:"events"`
+}
+
+// Export collects every event in store concerning subject
+func Export(store common.Store, subject string, opts Options) *Report {
+	keys := opts.MetadataKeys
+	if keys == nil {
+		keys = []string{common.CustomerIDKey, common.ActorIDKey}
+	}
+
+	report := &Report{Subject: subject, GeneratedAt: time.Now(), Streams: make([]string, 0), Events: make([]Match, 0)}
+	streams := make(map[string]bool)
+	for _, event := range store.GetAllEvents() {
+		var matchedOn []string
+		if event.AggregateID == subject {
+			matchedOn = append(matchedOn, "stream")
+		}
+		for _, key := range keys {
+			if event.Metadata[key] == subject {
+				matchedOn = append(matchedOn, "metadata."+key)
+			}
+		}
+		matchedOn = append(matchedOn, matchData(event.Data, opts.DataFields, subject)...)
+		if len(matchedOn) == 0 {
+			continue
+		}
+
+		report.Events = append(report.Events, Match{Event: event, MatchedOn: matchedOn})
+		if !streams[event.AggregateID] {
+			streams[event.AggregateID] = true
+			report.Streams = append(report.Streams, event.AggregateID)
+		}
+	}
+	sort.Strings(report.Streams)
+	return report
+}
+
+// matchData returns the paths of payload values equal to subject
+func matchData(data map[string]interface{}, fields []string, subject string) []string {
+	var paths []string
+	if fields != nil {
+		for _, field := range fields {
+			if data[field] == subject {
+				paths = append(paths, "data."+field)
+			}
+		}
+		return paths
+	}
+
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		paths = append(paths, matchValue(data[key], "data."+key, subject)...)
+	}
+	return paths
+}
+
+func matchValue(value interface{}, path, subject string) []string {
+	switch v := value.(type) {
+	case string:
+		if v == subject {
+			return []string{path}
+		}
+	case map[string]interface{}:
+		var paths []string
+		for _, nested := range matchData(v, nil, subject) {
+			paths = append(paths, path+nested[len("data"):])
+		}
+		return paths
+	case []interface{}:
+		var paths []string
+		for _, item := range v {
+			paths = append(paths, matchValue(item, path+"[]", subject)...)
+		}
+		return paths
+	}
+	return nil
+}
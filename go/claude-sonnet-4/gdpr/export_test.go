@@ -0,0 +1,56 @@
+package gdpr
+
+import (
+	"encoding/json"
+	"simple-event-modeling/common"
+	"strings"
+	"testing"
+)
+
+func seededStore() *common.EventStore {
+	store := common.NewEventStore()
+	store.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, map[string]interface{}{common.CustomerIDKey: "cust-42"}))
+	store.Append(common.NewEvent("ItemAdded", "cart-1", 2, map[string]interface{}{"item": "apple"}, map[string]interface{}{common.ActorIDKey: "cust-7"}))
+	store.Append(common.NewEvent("CustomerRegistered", "cust-42", 1, map[string]interface{}{"email": "ada@example.com"}, nil))
+	store.Append(common.NewEvent("GiftSent", "gift-1", 1, map[string]interface{}{
+		"recipient": map[string]interface{}{"customer": "cust-42"},
+		"cc":        []interface{}{"cust-9", "cust-42"},
+	}, nil))
+	return store
+}
+
+func TestExport(t *testing.T) {
+	report := Export(seededStore(), "cust-42", Options{})
+
+	if len(report.Events) != 3 {
+		t.Fatalf("Expected 3 matching events, got %+v", report.Events)
+	}
+	if on := report.Events[0].MatchedOn; len(on) != 1 || on[0] != "metadata.customer_id" {
+		t.Errorf("Expected a metadata match, got %v", on)
+	}
+	if on := report.Events[1].MatchedOn; len(on) != 1 || on[0] != "stream" {
+		t.Errorf("Expected a stream match, got %v", on)
+	}
+	if on := report.Events[2].MatchedOn; len(on) != 2 || on[0] != "data.cc[]" || on[1] != "data.recipient.customer" {
+		t.Errorf("Expected nested payload matches, got %v", on)
+	}
+	if len(report.Streams) != 3 || report.Streams[0] != "cart-1" {
+		t.Errorf("Unexpected streams: %v", report.Streams)
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil || !strings.Contains(string(data), `"subject":"cust-42"`) {
+		t.Errorf("Expected a portable JSON report, got %s (%v)", data, err)
+	}
+}
+
+func TestExport_Options(t *testing.T) {
+	report := Export(seededStore(), "cust-42", Options{MetadataKeys: []string{common.ActorIDKey}, DataFields: []string{"cc"}})
+	if len(report.Events) != 1 || report.Events[0].Event.AggregateID != "cust-42" {
+		t.Errorf("Expected only the stream match, got %+v", report.Events)
+	}
+
+	if report := Export(seededStore(), "nobody", Options{}); len(report.Events) != 0 || report.Streams == nil {
+		t.Errorf("Expected an empty report, got %+v", report)
+	}
+}
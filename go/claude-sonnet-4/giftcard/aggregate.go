@@ -0,0 +1,178 @@
+// Package giftcard provides the GiftCardAggregate implementation for the gift card domain.
+package giftcard
+
+import (
+	"errors"
+	"fmt"
+
+	"simple-event-modeling/common"
+
+	"github.com/google/uuid"
+)
+
+// GiftCardAggregate guards the balance of a gift card. Reservations are held per
+// cart, so a cart reserves at most once and only the cart's own reservation can be
+// released or redeemed.
+type GiftCardAggregate struct {
+	*common.BaseAggregate
+	balance      float64
+	reservations map[string]float64 // cart ID -> amount reserved
+}
+
+// NewGiftCardAggregate creates a new gift card aggregate
+func NewGiftCardAggregate(store common.Store) *GiftCardAggregate {
+	return &GiftCardAggregate{
+		BaseAggregate: common.NewBaseAggregate(store),
+		reservations:  make(map[string]float64),
+	}
+}
+
+// Balance returns the balance not yet redeemed, including reserved amounts
+func (a *GiftCardAggregate) Balance() float64 {
+	return a.balance
+}
+
+// Available returns the balance that is neither redeemed nor reserved
+func (a *GiftCardAggregate) Available() float64 {
+	available := a.balance
+	for _, amount := range a.reservations {
+		available -= amount
+	}
+	return available
+}
+
+// Reserved returns the amount reserved for a cart, and whether it has a reservation
+func (a *GiftCardAggregate) Reserved(cartID string) (float64, bool) {
+	amount, ok := a.reservations[cartID]
+	return amount, ok
+}
+
+// Handle processes commands and returns resulting events
+func (a *GiftCardAggregate) Handle(command common.Command) (*common.Event, error) {
+	if aggregateID := command.AggregateID(); aggregateID != "" && !a.IsLive() {
+		if err := a.Hydrate(aggregateID); err != nil {
+			return nil, err
+		}
+	}
+
+	var event *common.Event
+	var err error
+	switch cmd := command.(type) {
+	case *IssueGiftCardCommand:
+		event = NewGiftCardIssuedEvent(uuid.New().String(), cmd.Balance)
+	case *ReserveBalanceCommand:
+		event, err = a.handleReserveBalance(cmd)
+	case *ReleaseBalanceCommand:
+		event, err = a.handleReleaseBalance(cmd)
+	case *RedeemBalanceCommand:
+		event, err = a.handleRedeemBalance(cmd)
+	default:
+		return nil, &common.UnknownCommandError{
+			CommandType: command.CommandType(),
+			Registered:  common.DefaultRegistry.CommandTypes(AggregateTypeGiftCard),
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.On(event); err != nil {
+		return nil, err
+	}
+	if err := a.Store().Append(event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// On applies events to aggregate state
+func (a *GiftCardAggregate) On(event *common.Event) error {
+	switch event.Type {
+	case EventTypeGiftCardIssued:
+		issued, err := common.AsTyped[IssuedData](event)
+		if err != nil {
+			return err
+		}
+		a.Begin(event)
+		a.balance = issued.Data.Balance
+	case EventTypeBalanceReserved:
+		reserved, err := common.AsTyped[ReservationData](event)
+		if err != nil {
+			return err
+		}
+		a.reservations[reserved.Data.CartID] = reserved.Data.Amount
+	case EventTypeBalanceReservationDeclined:
+	case EventTypeBalanceReleased:
+		released, err := common.AsTyped[ReservationData](event)
+		if err != nil {
+			return err
+		}
+		delete(a.reservations, released.Data.CartID)
+	case EventTypeBalanceRedeemed:
+		redeemed, err := common.AsTyped[ReservationData](event)
+		if err != nil {
+			return err
+		}
+		a.balance -= redeemed.Data.Amount
+		delete(a.reservations, redeemed.Data.CartID)
+	default:
+		return errors.New("unhandled event type: " + event.Type)
+	}
+	return a.Advance(event)
+}
+
+// Hydrate rebuilds the aggregate state from its event stream
+func (a *GiftCardAggregate) Hydrate(id string) error {
+	return a.BaseAggregate.Hydrate(id, a.On)
+}
+
+// Command handlers
+
+func (a *GiftCardAggregate) handleReserveBalance(cmd *ReserveBalanceCommand) (*common.Event, error) {
+	if err := a.checkIssued(); err != nil {
+		return nil, err
+	}
+	if _, ok := a.reservations[cmd.CartID]; ok {
+		return nil, &common.InvalidCommandError{Message: "balance already reserved for cart " + cmd.CartID}
+	}
+
+	// A short balance is a fact the cart has to react to, not a rejection
+	if available := a.Available(); cmd.Amount > available {
+		return NewBalanceReservationDeclinedEvent(a.ID(), a.Version()+1, cmd.CartID, cmd.Amount, available), nil
+	}
+	return NewBalanceReservedEvent(a.ID(), a.Version()+1, cmd.CartID, cmd.Amount), nil
+}
+
+func (a *GiftCardAggregate) handleReleaseBalance(cmd *ReleaseBalanceCommand) (*common.Event, error) {
+	amount, err := a.checkReservation(cmd.CartID)
+	if err != nil {
+		return nil, err
+	}
+	return NewBalanceReleasedEvent(a.ID(), a.Version()+1, cmd.CartID, amount, cmd.Reason), nil
+}
+
+func (a *GiftCardAggregate) handleRedeemBalance(cmd *RedeemBalanceCommand) (*common.Event, error) {
+	amount, err := a.checkReservation(cmd.CartID)
+	if err != nil {
+		return nil, err
+	}
+	return NewBalanceRedeemedEvent(a.ID(), a.Version()+1, cmd.CartID, amount), nil
+}
+
+func (a *GiftCardAggregate) checkIssued() error {
+	if !a.IsLive() || a.ID() == "" {
+		return &common.InvalidCommandError{Message: "gift card not issued"}
+	}
+	return nil
+}
+
+func (a *GiftCardAggregate) checkReservation(cartID string) (float64, error) {
+	if err := a.checkIssued(); err != nil {
+		return 0, err
+	}
+	amount, ok := a.reservations[cartID]
+	if !ok {
+		return 0, &common.InvalidCommandError{Message: fmt.Sprintf("no balance reserved for cart %s", cartID)}
+	}
+	return amount, nil
+}
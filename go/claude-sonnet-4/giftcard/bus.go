@@ -0,0 +1,22 @@
+// Package giftcard registers the gift card command handlers with a command bus.
+package giftcard
+
+import (
+	"context"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+)
+
+// RegisterCommands registers a handler for every gift card command.
+// Each command is handled by a fresh aggregate hydrated from the store, so a bus
+// using bus.RetryOnConflict re-runs a reservation racing another against fresh state.
+func RegisterCommands(commands *bus.CommandBus, store common.Store) {
+	handle := func(_ context.Context, command common.Command) (*common.Event, error) {
+		return NewGiftCardAggregate(store).Handle(command)
+	}
+	commands.Register(CommandTypeIssueGiftCard, handle)
+	commands.Register(CommandTypeReserveBalance, handle)
+	commands.Register(CommandTypeReleaseBalance, handle)
+	commands.Register(CommandTypeRedeemBalance, handle)
+}
@@ -0,0 +1,123 @@
+// Package giftcard provides the process manager coordinating gift cards with carts.
+package giftcard
+
+import (
+	"context"
+	"time"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/cart"
+	"simple-event-modeling/common"
+	"simple-event-modeling/process"
+	"simple-event-modeling/scheduler"
+)
+
+// DefaultReservationTTL is how long a cart can hold a gift card's balance before
+// the reservation expires, when NewCartManager is given no TTL
+const DefaultReservationTTL = 30 * time.Minute
+
+// TimeoutStream is the stream reservation expiry timers fire into
+const TimeoutStream = "giftcard-timeouts"
+
+// ReservationExpiredTimer is the name of the timer scheduled for every reservation
+const ReservationExpiredTimer = "ReservationExpired"
+
+// NewCartManager creates a process manager that keeps gift card balances consistent
+// with the carts paid with them. The bus must have both the cart and the gift card
+// commands registered.
+//   - GiftCardApplied reserves the amount on the card and schedules the reservation
+//     to expire after ttl
+//   - BalanceReserved confirms the card on the cart, which can't be checked out
+//     while a card is unconfirmed, so checkout only redeems reserved balances
+//   - BalanceReservationDeclined removes the card from the cart again
+//   - GiftCardRemoved releases the reservation
+//   - CartCheckedOut redeems the reservations of every card paying for the cart,
+//     and CartExpired releases them
+//   - an expired reservation is released and the card removed from the cart
+//
+// Reactions to stale facts, such as the timer of a reservation that was redeemed
+// at checkout, are rejected by the aggregates and skipped by the process manager.
+func NewCartManager(store common.Store, commands *bus.CommandBus, timers *scheduler.Scheduler, ttl time.Duration) *process.Manager {
+	if ttl <= 0 {
+		ttl = DefaultReservationTTL
+	}
+	m := process.NewManager("giftcard-carts", store, commands)
+
+	m.On(cart.EventTypeGiftCardApplied, func(_ context.Context, event *common.Event) ([]common.Command, error) {
+		applied, err := common.AsTyped[cart.GiftCardData](event)
+		if err != nil {
+			return nil, err
+		}
+		_, err = timers.Schedule(TimeoutStream, ReservationExpiredTimer, event.CreatedAt.Add(ttl), map[string]interface{}{
+			"cart_id":      event.AggregateID,
+			"gift_card_id": applied.Data.GiftCardID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return []common.Command{&ReserveBalanceCommand{GiftCardID: applied.Data.GiftCardID, CartID: event.AggregateID, Amount: applied.Data.Amount}}, nil
+	})
+
+	m.On(EventTypeBalanceReserved, func(_ context.Context, event *common.Event) ([]common.Command, error) {
+		reserved, err := common.AsTyped[ReservationData](event)
+		if err != nil {
+			return nil, err
+		}
+		return []common.Command{&cart.ConfirmGiftCardCommand{CartID: reserved.Data.CartID, GiftCardID: event.AggregateID}}, nil
+	})
+
+	m.On(EventTypeBalanceReservationDeclined, func(_ context.Context, event *common.Event) ([]common.Command, error) {
+		declined, err := common.AsTyped[ReservationData](event)
+		if err != nil {
+			return nil, err
+		}
+		return []common.Command{&cart.RemoveGiftCardCommand{CartID: declined.Data.CartID, GiftCardID: event.AggregateID}}, nil
+	})
+
+	m.On(cart.EventTypeGiftCardRemoved, func(_ context.Context, event *common.Event) ([]common.Command, error) {
+		removed, err := common.AsTyped[cart.GiftCardData](event)
+		if err != nil {
+			return nil, err
+		}
+		return []common.Command{&ReleaseBalanceCommand{GiftCardID: removed.Data.GiftCardID, CartID: event.AggregateID, Reason: "removed"}}, nil
+	})
+
+	m.On(cart.EventTypeCartCheckedOut, func(_ context.Context, event *common.Event) ([]common.Command, error) {
+		return forGiftCards(store, event.AggregateID, func(giftCardID string) common.Command {
+			return &RedeemBalanceCommand{GiftCardID: giftCardID, CartID: event.AggregateID}
+		})
+	})
+
+	m.On(cart.EventTypeCartExpired, func(_ context.Context, event *common.Event) ([]common.Command, error) {
+		return forGiftCards(store, event.AggregateID, func(giftCardID string) common.Command {
+			return &ReleaseBalanceCommand{GiftCardID: giftCardID, CartID: event.AggregateID, Reason: "cart expired"}
+		})
+	})
+
+	m.On(scheduler.EventTypeTimeoutElapsed, func(_ context.Context, event *common.Event) ([]common.Command, error) {
+		if event.AggregateID != TimeoutStream || event.Data["name"] != ReservationExpiredTimer {
+			return nil, nil
+		}
+		cartID, _ := event.Data["cart_id"].(string)
+		giftCardID, _ := event.Data["gift_card_id"].(string)
+		return []common.Command{
+			&ReleaseBalanceCommand{GiftCardID: giftCardID, CartID: cartID, Reason: "expired"},
+			&cart.RemoveGiftCardCommand{CartID: cartID, GiftCardID: giftCardID},
+		}, nil
+	})
+
+	return m
+}
+
+// forGiftCards returns a command for every gift card paying for a cart
+func forGiftCards(store common.Store, cartID string, command func(giftCardID string) common.Command) ([]common.Command, error) {
+	closed := cart.NewCartAggregate(store)
+	if err := closed.Hydrate(cartID); err != nil {
+		return nil, err
+	}
+	commands := make([]common.Command, 0)
+	for giftCardID := range closed.GiftCards() {
+		commands = append(commands, command(giftCardID))
+	}
+	return commands, nil
+}
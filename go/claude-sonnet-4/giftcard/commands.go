@@ -0,0 +1,42 @@
+// Package giftcard provides command types for the gift card domain.
+// Commands are simple record structures; their only methods expose the routing
+// metadata required by common.Command.
+package giftcard
+
+// IssueGiftCardCommand issues a gift card with an initial balance. The card ID is
+// generated.
+type IssueGiftCardCommand struct {
+	Balance float64 `json:"balance" validate:"positive"`
+}
+
+// ReserveBalanceCommand reserves part of the balance for a cart paid with the card
+type ReserveBalanceCommand struct {
+	GiftCardID string  `json:"aggregate_id" validate:"required,uuid"`
+	CartID     string  `json:"cart_id" validate:"required,streamid"`
+	Amount     float64 `json:"amount" validate:"positive"`
+}
+
+// ReleaseBalanceCommand returns a cart's reservation to the available balance
+type ReleaseBalanceCommand struct {
+	GiftCardID string `json:"aggregate_id" validate:"required,uuid"`
+	CartID     string `json:"cart_id" validate:"required,streamid"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// RedeemBalanceCommand spends a cart's reservation when the cart is checked out
+type RedeemBalanceCommand struct {
+	GiftCardID string `json:"aggregate_id" validate:"required,uuid"`
+	CartID     string `json:"cart_id" validate:"required,streamid"`
+}
+
+func (c *IssueGiftCardCommand) AggregateID() string { return "" }
+func (c *IssueGiftCardCommand) CommandType() string { return CommandTypeIssueGiftCard }
+
+func (c *ReserveBalanceCommand) AggregateID() string { return c.GiftCardID }
+func (c *ReserveBalanceCommand) CommandType() string { return CommandTypeReserveBalance }
+
+func (c *ReleaseBalanceCommand) AggregateID() string { return c.GiftCardID }
+func (c *ReleaseBalanceCommand) CommandType() string { return CommandTypeReleaseBalance }
+
+func (c *RedeemBalanceCommand) AggregateID() string { return c.GiftCardID }
+func (c *RedeemBalanceCommand) CommandType() string { return CommandTypeRedeemBalance }
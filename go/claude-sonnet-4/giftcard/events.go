@@ -0,0 +1,74 @@
+// Package giftcard provides event types and creation functions for the gift card domain.
+// Events are simple record structures with no behaviors.
+package giftcard
+
+import "simple-event-modeling/common"
+
+// Event type constants
+const (
+	EventTypeGiftCardIssued  = "GiftCardIssued"
+	EventTypeBalanceReserved = "BalanceReserved"
+	// EventTypeBalanceReservationDeclined records a reservation the available
+	// balance could not cover, so the process manager can remove the card from the cart
+	EventTypeBalanceReservationDeclined = "BalanceReservationDeclined"
+	EventTypeBalanceReleased            = "BalanceReleased"
+	EventTypeBalanceRedeemed            = "BalanceRedeemed"
+)
+
+// IssuedData is the payload of GiftCardIssued events
+type IssuedData struct {
+	Balance float64 `json:"balance"`
+}
+
+// ReservationData is the payload of the events about a cart's reservation
+type ReservationData struct {
+	CartID string  `json:"cart_id"`
+	Amount float64 `json:"amount"`
+	// Available is the balance that was available when a reservation was declined
+	Available float64 `json:"available,omitempty"`
+	// Reason is why a reservation was released, e.g. expired
+	Reason string `json:"reason,omitempty"`
+}
+
+// NewGiftCardIssuedEvent creates a new GiftCardIssued event
+func NewGiftCardIssuedEvent(giftCardID string, balance float64) *common.Event {
+	return common.NewEvent(EventTypeGiftCardIssued, giftCardID, 1, map[string]interface{}{"balance": balance}, nil)
+}
+
+// NewBalanceReservedEvent creates a new BalanceReserved event
+func NewBalanceReservedEvent(giftCardID string, version int, cartID string, amount float64) *common.Event {
+	data := map[string]interface{}{
+		"cart_id": cartID,
+		"amount":  amount,
+	}
+	return common.NewEvent(EventTypeBalanceReserved, giftCardID, version, data, nil)
+}
+
+// NewBalanceReservationDeclinedEvent creates a new BalanceReservationDeclined event
+func NewBalanceReservationDeclinedEvent(giftCardID string, version int, cartID string, amount, available float64) *common.Event {
+	data := map[string]interface{}{
+		"cart_id":   cartID,
+		"amount":    amount,
+		"available": available,
+	}
+	return common.NewEvent(EventTypeBalanceReservationDeclined, giftCardID, version, data, nil)
+}
+
+// NewBalanceReleasedEvent creates a new BalanceReleased event
+func NewBalanceReleasedEvent(giftCardID string, version int, cartID string, amount float64, reason string) *common.Event {
+	data := map[string]interface{}{
+		"cart_id": cartID,
+		"amount":  amount,
+		"reason":  reason,
+	}
+	return common.NewEvent(EventTypeBalanceReleased, giftCardID, version, data, nil)
+}
+
+// NewBalanceRedeemedEvent creates a new BalanceRedeemed event
+func NewBalanceRedeemedEvent(giftCardID string, version int, cartID string, amount float64) *common.Event {
+	data := map[string]interface{}{
+		"cart_id": cartID,
+		"amount":  amount,
+	}
+	return common.NewEvent(EventTypeBalanceRedeemed, giftCardID, version, data, nil)
+}
@@ -0,0 +1,17 @@
+// Package giftcard provides a gift card domain built on the common framework. A
+// gift card holds a balance that carts reserve when paid with the card, redeem on
+// checkout, and release when the card is removed or the reservation expires.
+// It showcases consistency across aggregates without a transaction spanning them:
+//   - the cart records that it is paid with a card (GiftCardApplied) on its own stream
+//   - a process manager reserves the balance on the card's stream, and removes the
+//     card from the cart again if the balance is short
+//   - the scheduler expires reservations of carts that are not checked out in time
+//
+// The package is organized into separate files for each major concept:
+// - commands.go: Command types (IssueGiftCard, ReserveBalance, ReleaseBalance, RedeemBalance)
+// - events.go: Event types and creation functions (GiftCardIssued, BalanceReserved, etc.)
+// - aggregate.go: GiftCardAggregate implementation with the balance rules
+// - carts.go: Process manager coordinating gift cards with carts
+// - registry.go: Registration with the common registry
+// - bus.go: Registration of command handlers with a command bus
+package giftcard
@@ -0,0 +1,169 @@
+package giftcard
+
+import (
+	"context"
+	"errors"
+	"simple-event-modeling/bus"
+	"simple-event-modeling/cart"
+	"simple-event-modeling/common"
+	"simple-event-modeling/process"
+	"simple-event-modeling/scheduler"
+	"testing"
+	"time"
+)
+
+func newBus(store common.Store) *bus.CommandBus {
+	commands := bus.NewCommandBus(bus.RetryOnConflict(20), bus.Validation())
+	RegisterCommands(commands, store)
+	cart.RegisterCommands(commands, store)
+	return commands
+}
+
+func dispatch(t *testing.T, commands *bus.CommandBus, command common.Command) *common.Event {
+	t.Helper()
+	event, err := commands.Dispatch(context.Background(), command)
+	if err != nil {
+		t.Fatalf("Error handling %s: %v", command.CommandType(), err)
+	}
+	return event
+}
+
+func newCart(t *testing.T, commands *bus.CommandBus) string {
+	t.Helper()
+	cartID := dispatch(t, commands, &cart.CreateCartCommand{}).AggregateID
+	dispatch(t, commands, &cart.AddItemCommand{CartID: cartID, ItemID: "apple"})
+	return cartID
+}
+
+// settle processes until the manager has reacted to the events its own commands
+// appended
+func settle(t *testing.T, manager *process.Manager) {
+	t.Helper()
+	for {
+		processed, err := manager.Process(context.Background())
+		if err != nil {
+			t.Fatalf("Error processing: %v", err)
+		}
+		if processed == 0 {
+			return
+		}
+	}
+}
+
+func TestGiftCardAggregate_Reservations(t *testing.T) {
+	store := common.NewEventStore()
+	commands := newBus(store)
+	cardID := dispatch(t, commands, &IssueGiftCardCommand{Balance: 50}).AggregateID
+	first, second := newCart(t, commands), newCart(t, commands)
+
+	dispatch(t, commands, &ReserveBalanceCommand{GiftCardID: cardID, CartID: first, Amount: 30})
+	if _, err := commands.Dispatch(context.Background(), &ReserveBalanceCommand{GiftCardID: cardID, CartID: first, Amount: 5}); !errors.Is(err, common.ErrInvalidCommand) {
+		t.Errorf("Expected a second reservation for the same cart to be rejected, got %v", err)
+	}
+	if event := dispatch(t, commands, &ReserveBalanceCommand{GiftCardID: cardID, CartID: second, Amount: 30}); event.Type != EventTypeBalanceReservationDeclined {
+		t.Errorf("Expected a reservation beyond the available balance to be declined, got %s", event.Type)
+	}
+
+	dispatch(t, commands, &RedeemBalanceCommand{GiftCardID: cardID, CartID: first})
+	if _, err := commands.Dispatch(context.Background(), &ReleaseBalanceCommand{GiftCardID: cardID, CartID: first}); !errors.Is(err, common.ErrInvalidCommand) {
+		t.Errorf("Expected releasing a redeemed reservation to be rejected, got %v", err)
+	}
+
+	card := NewGiftCardAggregate(store)
+	if err := card.Hydrate(cardID); err != nil {
+		t.Fatalf("Error hydrating gift card: %v", err)
+	}
+	if card.Balance() != 20 || card.Available() != 20 {
+		t.Errorf("Expected 20 left after redeeming 30, got balance %v available %v", card.Balance(), card.Available())
+	}
+}
+
+func TestCartManager_KeepsCardsConsistentWithCarts(t *testing.T) {
+	store := common.NewEventStore()
+	commands := newBus(store)
+	now := time.Now()
+	timers, err := scheduler.New(store, scheduler.WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatalf("Error creating scheduler: %v", err)
+	}
+	manager := NewCartManager(store, commands, timers, time.Hour)
+
+	cardID := dispatch(t, commands, &IssueGiftCardCommand{Balance: 40}).AggregateID
+	paid, abandoned, short := newCart(t, commands), newCart(t, commands), newCart(t, commands)
+	dispatch(t, commands, &cart.ApplyGiftCardCommand{CartID: paid, GiftCardID: cardID, Amount: 15})
+	dispatch(t, commands, &cart.ApplyGiftCardCommand{CartID: abandoned, GiftCardID: cardID, Amount: 20})
+	dispatch(t, commands, &cart.ApplyGiftCardCommand{CartID: short, GiftCardID: cardID, Amount: 10})
+	var unconfirmed *cart.GiftCardNotConfirmedError
+	if _, err := commands.Dispatch(context.Background(), &cart.CheckoutCartCommand{CartID: paid}); !errors.As(err, &unconfirmed) {
+		t.Errorf("Expected checkout to wait for the reservation, got %v", err)
+	}
+	settle(t, manager)
+	if _, err := commands.Dispatch(context.Background(), &cart.CheckoutCartCommand{CartID: short}); err != nil {
+		t.Errorf("Expected the cart whose card was declined and removed to check out, got %v", err)
+	}
+
+	giftCards := func(cartID string) map[string]float64 {
+		aggregate := cart.NewCartAggregate(store)
+		if err := aggregate.Hydrate(cartID); err != nil {
+			t.Fatalf("Error hydrating cart: %v", err)
+		}
+		return aggregate.GiftCards()
+	}
+	card := func() *GiftCardAggregate {
+		aggregate := NewGiftCardAggregate(store)
+		if err := aggregate.Hydrate(cardID); err != nil {
+			t.Fatalf("Error hydrating gift card: %v", err)
+		}
+		return aggregate
+	}
+	if len(giftCards(short)) != 0 {
+		t.Error("Expected the card removed from the cart its balance couldn't cover")
+	}
+	if card().Available() != 5 {
+		t.Errorf("Expected 35 of 40 reserved, got %v available", card().Available())
+	}
+
+	dispatch(t, commands, &cart.CheckoutCartCommand{CartID: paid})
+	settle(t, manager)
+
+	now = now.Add(time.Hour + time.Second)
+	if fired, _ := timers.FireDue(); fired != 3 {
+		t.Fatalf("Expected a timer per applied card to fire, got %d", fired)
+	}
+	settle(t, manager)
+
+	if balance, available := card().Balance(), card().Available(); balance != 25 || available != 25 {
+		t.Errorf("Expected 15 redeemed and the abandoned cart's 20 released, got balance %v available %v", balance, available)
+	}
+	if len(giftCards(abandoned)) != 0 {
+		t.Error("Expected the card removed from the abandoned cart on expiry")
+	}
+	if giftCards(paid)[cardID] != 15 {
+		t.Error("Expected the checked-out cart to keep its card")
+	}
+}
+
+func TestCartManager_ReleasesExpiredCarts(t *testing.T) {
+	store := common.NewEventStore()
+	commands := newBus(store)
+	timers, err := scheduler.New(store)
+	if err != nil {
+		t.Fatalf("Error creating scheduler: %v", err)
+	}
+	manager := NewCartManager(store, commands, timers, 0)
+
+	cardID := dispatch(t, commands, &IssueGiftCardCommand{Balance: 40}).AggregateID
+	cartID := newCart(t, commands)
+	dispatch(t, commands, &cart.ApplyGiftCardCommand{CartID: cartID, GiftCardID: cardID, Amount: 25})
+	settle(t, manager)
+	dispatch(t, commands, &cart.ExpireCartCommand{CartID: cartID})
+	settle(t, manager)
+
+	card := NewGiftCardAggregate(store)
+	if err := card.Hydrate(cardID); err != nil {
+		t.Fatalf("Error hydrating gift card: %v", err)
+	}
+	if card.Available() != 40 {
+		t.Errorf("Expected the expired cart's reservation released, got %v available", card.Available())
+	}
+}
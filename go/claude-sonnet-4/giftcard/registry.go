@@ -0,0 +1,78 @@
+// Package giftcard registers the gift card domain with the common registry so
+// tooling can discover its commands, events, and aggregate.
+package giftcard
+
+import "simple-event-modeling/common"
+
+// AggregateTypeGiftCard is the registered name of the gift card aggregate
+const AggregateTypeGiftCard = "GiftCard"
+
+// Command type names
+const (
+	CommandTypeIssueGiftCard  = "IssueGiftCard"
+	CommandTypeReserveBalance = "ReserveBalance"
+	CommandTypeReleaseBalance = "ReleaseBalance"
+	CommandTypeRedeemBalance  = "RedeemBalance"
+)
+
+func init() {
+	register(common.DefaultRegistry)
+}
+
+func register(registry *common.Registry) {
+	registry.RegisterAggregate(common.AggregateInfo{Name: AggregateTypeGiftCard})
+
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeIssueGiftCard,
+		Aggregate: AggregateTypeGiftCard,
+		Produces:  []string{EventTypeGiftCardIssued},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeReserveBalance,
+		Aggregate: AggregateTypeGiftCard,
+		Produces:  []string{EventTypeBalanceReserved, EventTypeBalanceReservationDeclined},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeReleaseBalance,
+		Aggregate: AggregateTypeGiftCard,
+		Produces:  []string{EventTypeBalanceReleased},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeRedeemBalance,
+		Aggregate: AggregateTypeGiftCard,
+		Produces:  []string{EventTypeBalanceRedeemed},
+	})
+
+	cartField := common.FieldInfo{Name: "cart_id", Type: "string", Description: "Cart paid with the card"}
+	amountField := common.FieldInfo{Name: "amount", Type: "number", Description: "Amount of the reservation"}
+	registry.RegisterEvent(common.EventInfo{
+		Name:      EventTypeGiftCardIssued,
+		Aggregate: AggregateTypeGiftCard,
+		Payload:   []common.FieldInfo{{Name: "balance", Type: "number", Description: "Initial balance"}},
+	})
+	registry.RegisterEvent(common.EventInfo{Name: EventTypeBalanceReserved, Aggregate: AggregateTypeGiftCard, Payload: []common.FieldInfo{cartField, amountField}})
+	registry.RegisterEvent(common.EventInfo{
+		Name:      EventTypeBalanceReservationDeclined,
+		Aggregate: AggregateTypeGiftCard,
+		Payload: []common.FieldInfo{
+			cartField,
+			amountField,
+			{Name: "available", Type: "number", Description: "Balance available when the reservation was declined"},
+		},
+	})
+	registry.RegisterEvent(common.EventInfo{
+		Name:      EventTypeBalanceReleased,
+		Aggregate: AggregateTypeGiftCard,
+		Payload: []common.FieldInfo{
+			cartField,
+			amountField,
+			{Name: "reason", Type: "string", Description: "Why the reservation was released, e.g. expired"},
+		},
+	})
+	registry.RegisterEvent(common.EventInfo{Name: EventTypeBalanceRedeemed, Aggregate: AggregateTypeGiftCard, Payload: []common.FieldInfo{cartField, amountField}})
+	common.RegisterPayload[IssuedData](registry, EventTypeGiftCardIssued)
+	common.RegisterPayload[ReservationData](registry, EventTypeBalanceReserved)
+	common.RegisterPayload[ReservationData](registry, EventTypeBalanceReservationDeclined)
+	common.RegisterPayload[ReservationData](registry, EventTypeBalanceReleased)
+	common.RegisterPayload[ReservationData](registry, EventTypeBalanceRedeemed)
+}
@@ -0,0 +1,404 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"simple-event-modeling/common"
+)
+
+// Error is a GraphQL error as reported in the "errors" list of a response
+type Error struct {
+	Message   string     `json:"message"`
+	Locations []Location `json:"locations,omitempty"`
+	// Path names the response field the error occurred in, e.g. ["cartItems"]
+	Path []interface{} `json:"path,omitempty"`
+	// Extensions carry the common.ErrorCode of resolver errors under "code"
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Request is a GraphQL request
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Response is the result of a request. Data is absent when the request could not be
+// executed at all, e.g. because it failed validation.
+type Response struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []*Error    `json:"errors,omitempty"`
+}
+
+// resultMap is a JSON object keeping the order of the selections it answers
+type resultMap []resultField
+
+type resultField struct {
+	key   string
+	value interface{}
+}
+
+func (m resultMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range m {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(f.key)
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (m resultMap) has(key string) bool {
+	for _, f := range m {
+		if f.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// prepared is a validated operation with its variables coerced
+type prepared struct {
+	op        *operation
+	root      *objectType
+	variables map[string]interface{}
+}
+
+// prepare parses and validates the operation a request selects
+func (s *Schema) prepare(req Request) (*prepared, []*Error) {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return nil, []*Error{err.(*Error)}
+	}
+
+	var op *operation
+	for _, candidate := range doc.operations {
+		if candidate.name == req.OperationName || (req.OperationName == "" && len(doc.operations) == 1) {
+			op = candidate
+			break
+		}
+	}
+	switch {
+	case op == nil && req.OperationName == "":
+		return nil, []*Error{{Message: "Must provide operationName if the document contains multiple operations."}}
+	case op == nil:
+		return nil, []*Error{{Message: fmt.Sprintf("Unknown operation named %q.", req.OperationName)}}
+	}
+
+	p := &prepared{op: op, variables: make(map[string]interface{})}
+	switch op.kind {
+	case "query":
+		p.root = s.query
+	case "subscription":
+		p.root = s.subscription
+		if len(op.selections) != 1 || op.selections[0].name == "__typename" {
+			return nil, []*Error{{Message: "A subscription must select exactly one field.", Locations: []Location{op.loc}}}
+		}
+	default:
+		return nil, []*Error{{Message: "Mutations are not supported; send commands to the HTTP API.", Locations: []Location{op.loc}}}
+	}
+
+	var errs []*Error
+	defined := make(map[string]bool)
+	for _, definition := range op.variables {
+		defined[definition.name] = true
+		value, provided := req.Variables[definition.name]
+		if !provided {
+			value = definition.defaultValue
+		}
+		if value == nil && definition.typ.nonNull {
+			errs = append(errs, &Error{
+				Message:   fmt.Sprintf("Variable \"$%s\" of required type %q was not provided.", definition.name, definition.typ),
+				Locations: []Location{definition.loc},
+			})
+			continue
+		}
+		if provided || definition.defaultValue != nil {
+			p.variables[definition.name] = value
+		}
+	}
+	errs = append(errs, s.validate(p.root, op.selections, defined)...)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return p, nil
+}
+
+// validate checks selections against the fields of an object type
+func (s *Schema) validate(object *objectType, selections []*selection, defined map[string]bool) []*Error {
+	var errs []*Error
+	fail := func(sel *selection, format string, args ...interface{}) {
+		errs = append(errs, &Error{Message: fmt.Sprintf(format, args...), Locations: []Location{sel.loc}})
+	}
+
+	for _, sel := range selections {
+		if sel.name == "__typename" {
+			if sel.args != nil || sel.selections != nil {
+				fail(sel, "Field \"__typename\" takes no arguments or subfields.")
+			}
+			continue
+		}
+		f := object.field(sel.name)
+		if f == nil {
+			fail(sel, "Cannot query field %q on type %q.", sel.name, object.name)
+			continue
+		}
+
+		for _, arg := range sel.args {
+			if f.argument(arg.name) == nil {
+				fail(sel, "Unknown argument %q on field \"%s.%s\".", arg.name, object.name, f.name)
+			}
+			for _, name := range variablesIn(arg.value) {
+				if !defined[name] {
+					fail(sel, "Variable \"$%s\" is not defined.", name)
+				}
+			}
+		}
+		for _, arg := range f.args {
+			if arg.typ.nonNull && !hasArgument(sel, arg.name) {
+				fail(sel, "Field %q argument %q of type %q is required, but it was not provided.", f.name, arg.name, arg.typ)
+			}
+		}
+
+		child, isObject := s.types[namedType(f.typ)]
+		switch {
+		case isObject && sel.selections == nil:
+			fail(sel, "Field %q of type %q must have a selection of subfields.", f.name, f.typ)
+		case !isObject && sel.selections != nil:
+			fail(sel, "Field %q must not have a selection since type %q has no subfields.", f.name, f.typ)
+		case isObject:
+			errs = append(errs, s.validate(child, sel.selections, defined)...)
+		}
+	}
+	return errs
+}
+
+// namedType returns the name of the named type at the core of a list type
+func namedType(t *typeRef) string {
+	for t.elem != nil {
+		t = t.elem
+	}
+	return t.name
+}
+
+func hasArgument(sel *selection, name string) bool {
+	for _, arg := range sel.args {
+		if arg.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// variablesIn lists the variables referenced by a parsed value
+func variablesIn(value interface{}) []string {
+	switch v := value.(type) {
+	case variable:
+		return []string{string(v)}
+	case []interface{}:
+		var names []string
+		for _, item := range v {
+			names = append(names, variablesIn(item)...)
+		}
+		return names
+	case map[string]interface{}:
+		var names []string
+		for _, item := range v {
+			names = append(names, variablesIn(item)...)
+		}
+		return names
+	}
+	return nil
+}
+
+// executor runs a prepared operation, collecting field errors
+type executor struct {
+	schema    *Schema
+	variables map[string]interface{}
+	errors    []*Error
+}
+
+// execute runs every root field of a prepared operation
+func (s *Schema) execute(ctx context.Context, p *prepared) *Response {
+	e := &executor{schema: s, variables: p.variables}
+	data := make(resultMap, 0, len(p.op.selections))
+	for _, sel := range p.op.selections {
+		if data.has(sel.key()) {
+			continue
+		}
+		if sel.name == "__typename" {
+			data = append(data, resultField{sel.key(), p.root.name})
+			continue
+		}
+		f := p.root.field(sel.name)
+		result, err := e.resolve(ctx, f, sel)
+		if err != nil {
+			e.errors = append(e.errors, &Error{
+				Message:    err.Error(),
+				Locations:  []Location{sel.loc},
+				Path:       []interface{}{sel.key()},
+				Extensions: map[string]interface{}{"code": common.ErrorCodeOf(err)},
+			})
+			data = append(data, resultField{sel.key(), nil})
+			continue
+		}
+		data = append(data, resultField{sel.key(), e.complete(f.typ, sel.selections, result, []interface{}{sel.key()})})
+	}
+	return &Response{Data: data, Errors: e.errors}
+}
+
+// params decodes the arguments of a root field selection into its route's params
+func (e *executor) params(f *field, sel *selection) (interface{}, error) {
+	args := make(map[string]interface{}, len(sel.args))
+	for _, arg := range sel.args {
+		if name, isVariable := arg.value.(variable); isVariable {
+			if value, exists := e.variables[string(name)]; exists {
+				args[arg.name] = value
+			}
+			continue
+		}
+		args[arg.name] = e.resolveValue(arg.value)
+	}
+
+	params := reflect.New(reflect.TypeOf(f.route.Params))
+	data, err := json.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, params.Interface()); err != nil {
+		return nil, fmt.Errorf("invalid arguments of field %q: %w", f.name, err)
+	}
+	return params.Interface(), nil
+}
+
+// resolveValue replaces the variables of a parsed value with their values
+func (e *executor) resolveValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case variable:
+		return e.variables[string(v)]
+	case enumValue:
+		return string(v)
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = e.resolveValue(item)
+		}
+		return list
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			object[key] = e.resolveValue(item)
+		}
+		return object
+	}
+	return value
+}
+
+// resolve runs the query route of a root field, returning its result as decoded JSON
+func (e *executor) resolve(ctx context.Context, f *field, sel *selection) (interface{}, error) {
+	params, err := e.params(f, sel)
+	if err != nil {
+		return nil, err
+	}
+	result, err := f.route.Handler(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// Keep integers exact rather than rounding them through float64
+	decoder.UseNumber()
+	var value interface{}
+	err = decoder.Decode(&value)
+	return value, err
+}
+
+// complete shapes a decoded JSON value into the selected fields of type t
+func (e *executor) complete(t *typeRef, selections []*selection, value interface{}, path []interface{}) interface{} {
+	if value == nil {
+		if t.nonNull {
+			e.fail(path, "Cannot return null for non-nullable field.")
+		}
+		return nil
+	}
+
+	if t.elem != nil {
+		items, isList := value.([]interface{})
+		if object, isObject := value.(map[string]interface{}); isObject {
+			items, isList = entries(object), true
+		}
+		if !isList {
+			e.fail(path, "Expected a list, got %T.", value)
+			return nil
+		}
+		list := make([]interface{}, len(items))
+		for i, item := range items {
+			list[i] = e.complete(t.elem, selections, item, append(path[:len(path):len(path)], i))
+		}
+		return list
+	}
+
+	object, isObject := e.schema.types[t.name]
+	if !isObject {
+		return value
+	}
+	source, ok := value.(map[string]interface{})
+	if !ok {
+		e.fail(path, "Expected an object, got %T.", value)
+		return nil
+	}
+	result := make(resultMap, 0, len(selections))
+	for _, sel := range selections {
+		if result.has(sel.key()) {
+			continue
+		}
+		if sel.name == "__typename" {
+			result = append(result, resultField{sel.key(), object.name})
+			continue
+		}
+		f := object.field(sel.name)
+		fieldPath := append(path[:len(path):len(path)], sel.key())
+		result = append(result, resultField{sel.key(), e.complete(f.typ, sel.selections, source[f.name], fieldPath)})
+	}
+	return result
+}
+
+func (e *executor) fail(path []interface{}, format string, args ...interface{}) {
+	e.errors = append(e.errors, &Error{Message: fmt.Sprintf(format, args...), Path: path})
+}
+
+// entries converts a JSON object into the entries of a map entry type, sorted by key
+func entries(object map[string]interface{}) []interface{} {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	list := make([]interface{}, len(keys))
+	for i, key := range keys {
+		list[i] = map[string]interface{}{"key": key, "value": object[key]}
+	}
+	return list
+}
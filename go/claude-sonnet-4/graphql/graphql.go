@@ -0,0 +1,195 @@
+// Package graphql exposes the read models of an HTTP API over GraphQL, for clients
+// that prefer it to REST.
+//
+// The schema is generated from the query routes registered with an httpapi.Server:
+// every route becomes a field of the Query type, taking the route's params as
+// arguments and returning its result type. Routes with a Version, which names the
+// stream their result is read from, also become fields of the Subscription type;
+// subscribing pushes a new result whenever the stream changes:
+//
+//	subscription { cartItems(cart_id: "...") { totals { item_count } } }
+//
+// Go maps, which GraphQL has no type for, are exposed as lists of key/value entries.
+// The executor supports fields, aliases, arguments, and variables; fragments,
+// directives, and introspection beyond __typename are not supported, so clients
+// should use the SDL from Schema.SDL (see `sem graphql`) instead. Commands stay on
+// the HTTP API, so there are no mutations.
+//
+// Over HTTP, queries are sent as GET or POST requests and answered with JSON.
+// Subscriptions answer with a stream of server-sent events, a "next" event per
+// result followed by "complete", as in the distinct connections mode of the
+// GraphQL over SSE protocol.
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"simple-event-modeling/common"
+	"simple-event-modeling/httpapi"
+)
+
+// Handler executes GraphQL requests against the query routes of an HTTP API
+type Handler struct {
+	// PollInterval is how often subscriptions poll their stream; 0 uses
+	// common.DefaultPollInterval
+	PollInterval time.Duration
+
+	schema *Schema
+	store  common.Store
+}
+
+// NewHandler creates a handler for the query routes registered with api so far.
+// Subscriptions follow streams in store.
+func NewHandler(api *httpapi.Server, store common.Store) *Handler {
+	return &Handler{schema: NewSchema(api.QueryRoutes()), store: store}
+}
+
+// Schema returns the schema the handler executes requests against
+func (h *Handler) Schema() *Schema {
+	return h.schema
+}
+
+// Execute runs a query operation
+func (h *Handler) Execute(ctx context.Context, req Request) *Response {
+	p, errs := h.schema.prepare(req)
+	if errs != nil {
+		return &Response{Errors: errs}
+	}
+	if p.op.kind == "subscription" {
+		return &Response{Errors: []*Error{{Message: "Subscriptions must be run with Subscribe.", Locations: []Location{p.op.loc}}}}
+	}
+	return h.schema.execute(ctx, p)
+}
+
+// Subscribe runs a subscription operation, delivering the current result of its
+// field and another result after every event appended to the stream the field reads,
+// until ctx is cancelled. A request that cannot be run gets a single response with
+// the errors. The channel is closed when the subscription ends.
+func (h *Handler) Subscribe(ctx context.Context, req Request) <-chan *Response {
+	p, errs := h.schema.prepare(req)
+	if errs == nil && p.op.kind != "subscription" {
+		errs = []*Error{{Message: "Subscribe runs subscriptions; use Execute.", Locations: []Location{p.op.loc}}}
+	}
+	if errs != nil {
+		out := make(chan *Response, 1)
+		out <- &Response{Errors: errs}
+		close(out)
+		return out
+	}
+	return h.subscribe(ctx, p)
+}
+
+func (h *Handler) subscribe(ctx context.Context, p *prepared) <-chan *Response {
+	out := make(chan *Response)
+	go func() {
+		defer close(out)
+		send := func(response *Response) bool {
+			select {
+			case out <- response:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		sel := p.op.selections[0]
+		f := p.root.field(sel.name)
+		e := &executor{schema: h.schema, variables: p.variables}
+		params, err := e.params(f, sel)
+		var token common.ConsistencyToken
+		if err == nil {
+			token, err = f.route.Version(ctx, params)
+		}
+		if err != nil {
+			send(&Response{Errors: []*Error{{
+				Message:    err.Error(),
+				Locations:  []Location{sel.loc},
+				Path:       []interface{}{sel.key()},
+				Extensions: map[string]interface{}{"code": common.ErrorCodeOf(err)},
+			}}})
+			return
+		}
+
+		if !send(h.schema.execute(ctx, p)) {
+			return
+		}
+		for event := range common.SubscribeStream(ctx, h.store, token.StreamID, token.Version, h.PollInterval) {
+			// The result must reflect at least the event that triggered it
+			if !send(h.schema.execute(common.WithConsistencyToken(ctx, common.TokenFor(event)), p)) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// ServeHTTP answers GET and POST requests. Queries are answered with a JSON
+// response; subscriptions with a stream of server-sent events.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		req.Query = query.Get("query")
+		req.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				writeJSON(w, http.StatusBadRequest, &Response{Errors: []*Error{{Message: "invalid variables: " + err.Error()}}})
+				return
+			}
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, &Response{Errors: []*Error{{Message: "invalid request body: " + err.Error()}}})
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeJSON(w, http.StatusMethodNotAllowed, &Response{Errors: []*Error{{Message: "method not allowed"}}})
+		return
+	}
+
+	p, errs := h.schema.prepare(req)
+	switch {
+	case errs != nil:
+		writeJSON(w, http.StatusOK, &Response{Errors: errs})
+	case p.op.kind == "subscription":
+		h.serveEvents(w, r, p)
+	default:
+		writeJSON(w, http.StatusOK, h.schema.execute(r.Context(), p))
+	}
+}
+
+// serveEvents streams the results of a subscription as server-sent events
+func (h *Handler) serveEvents(w http.ResponseWriter, r *http.Request, p *prepared) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, &Response{Errors: []*Error{{Message: "streaming is not supported"}}})
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for response := range h.subscribe(r.Context(), p) {
+		data, err := json.Marshal(response)
+		if err != nil {
+			data, _ = json.Marshal(&Response{Errors: []*Error{{Message: err.Error()}}})
+		}
+		fmt.Fprintf(w, "event: next\ndata: %s\n\n", data)
+		flusher.Flush()
+	}
+	fmt.Fprint(w, "event: complete\ndata:\n\n")
+	flusher.Flush()
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,225 @@
+package graphql
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/cart"
+	"simple-event-modeling/common"
+	"simple-event-modeling/httpapi"
+)
+
+const cartID = "8b0e7e0a-3c55-4b52-9c1e-6f3b1e2d4a10"
+
+// cartHandler serves the cart queries over a store holding one cart with two apples
+// and a pear
+func cartHandler(t *testing.T) (*Handler, common.Store) {
+	t.Helper()
+	store := common.NewEventStore()
+	for _, event := range []*common.Event{
+		cart.NewCartCreatedEvent(cartID),
+		cart.NewItemAddedEvent(cartID, 2, "apple"),
+		cart.NewItemAddedEvent(cartID, 3, "pear"),
+		cart.NewItemAddedEvent(cartID, 4, "apple"),
+	} {
+		if err := store.Append(event); err != nil {
+			t.Fatalf("Error seeding cart: %v", err)
+		}
+	}
+	api := httpapi.NewServer()
+	cart.RegisterRoutes(api, bus.NewCommandBus(), store)
+	handler := NewHandler(api, store)
+	handler.PollInterval = time.Millisecond
+	return handler, store
+}
+
+func encode(t *testing.T, response *Response) string {
+	t.Helper()
+	data, err := json.Marshal(response)
+	if err != nil {
+		t.Fatalf("Error encoding response: %v", err)
+	}
+	return string(data)
+}
+
+func TestHandler_Execute(t *testing.T) {
+	handler, _ := cartHandler(t)
+
+	response := handler.Execute(context.Background(), Request{
+		Query: `query Cart($id: String!) {
+			cart: cartItems(cart_id: $id) {
+				__typename
+				items { key value { quantity } }
+				totals { item_count }
+			}
+		}`,
+		Variables: map[string]interface{}{"id": cartID},
+	})
+	want := `{"data":{"cart":{"__typename":"CartProjection","items":[{"key":"apple","value":{"quantity":2}},{"key":"pear","value":{"quantity":1}}],"totals":{"item_count":3}}}}`
+	if got := encode(t, response); got != want {
+		t.Errorf("Unexpected response:\n got %s\nwant %s", got, want)
+	}
+
+	response = handler.Execute(context.Background(), Request{Query: `{ cartItems(cart_id: "missing") { cart_id } }`})
+	if len(response.Errors) != 1 || response.Errors[0].Extensions["code"] != common.CodeStreamNotFound {
+		t.Fatalf("Expected a stream not found error, got %s", encode(t, response))
+	}
+	if got := encode(t, response); !strings.HasPrefix(got, `{"data":{"cartItems":null}`) {
+		t.Errorf("Expected a null field for the failed query, got %s", got)
+	}
+}
+
+func TestHandler_ExecuteRejectsInvalidRequests(t *testing.T) {
+	handler, _ := cartHandler(t)
+
+	for query, want := range map[string]string{
+		`{ cartItems(cart_id: "x") { cart_id `:                         "Syntax Error: expected a name, found end of document",
+		`{ cartItems(cart_id: "x") { ...CartFields } }`:                "Syntax Error: fragments are not supported",
+		`{ carts { cart_id } }`:                                        `Cannot query field "carts" on type "Query".`,
+		`{ cartItems { cart_id } }`:                                    `Field "cartItems" argument "cart_id" of type "String!" is required, but it was not provided.`,
+		`{ cartItems(cart_id: "x", limit: 1) { cart_id } }`:            `Unknown argument "limit" on field "Query.cartItems".`,
+		`{ cartItems(cart_id: "x") }`:                                  `Field "cartItems" of type "CartProjection" must have a selection of subfields.`,
+		`{ cartItems(cart_id: "x") { cart_id { id } } }`:               `Field "cart_id" must not have a selection since type "String!" has no subfields.`,
+		`{ cartItems(cart_id: $id) { cart_id } }`:                      `Variable "$id" is not defined.`,
+		`query ($id: String!) { cartItems(cart_id: $id) { cart_id } }`: `Variable "$id" of required type "String!" was not provided.`,
+		`mutation { addItem }`:                                         "Mutations are not supported; send commands to the HTTP API.",
+	} {
+		response := handler.Execute(context.Background(), Request{Query: query})
+		if response.Data != nil || len(response.Errors) != 1 || response.Errors[0].Message != want {
+			t.Errorf("Expected %q for %s, got %s", want, query, encode(t, response))
+		}
+	}
+
+	response := handler.Execute(context.Background(), Request{Query: "{\n  carts { cart_id }\n}"})
+	if locations := response.Errors[0].Locations; len(locations) != 1 || locations[0] != (Location{Line: 2, Column: 3}) {
+		t.Errorf("Expected the error at 2:3, got %v", locations)
+	}
+}
+
+func TestHandler_Subscribe(t *testing.T) {
+	handler, store := cartHandler(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	responses := handler.Subscribe(ctx, Request{Query: `subscription { cartItems(cart_id: "` + cartID + `") { totals { item_count } } }`})
+	next := func() string {
+		select {
+		case response := <-responses:
+			return encode(t, response)
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for a result")
+			return ""
+		}
+	}
+
+	if got := next(); got != `{"data":{"cartItems":{"totals":{"item_count":3}}}}` {
+		t.Errorf("Unexpected initial result %s", got)
+	}
+	store.Append(cart.NewItemRemovedEvent(cartID, 5, "pear"))
+	if got := next(); got != `{"data":{"cartItems":{"totals":{"item_count":2}}}}` {
+		t.Errorf("Unexpected result after removing an item %s", got)
+	}
+
+	cancel()
+	for range responses {
+	}
+
+	responses = handler.Subscribe(context.Background(), Request{Query: `subscription { a: cartItems(cart_id: "x") { cart_id } b: cartItems(cart_id: "y") { cart_id } }`})
+	if got := <-responses; len(got.Errors) != 1 || got.Errors[0].Message != "A subscription must select exactly one field." {
+		t.Errorf("Expected subscriptions of several fields to be rejected, got %s", encode(t, got))
+	}
+	if _, open := <-responses; open {
+		t.Error("Expected the channel to be closed after the errors")
+	}
+}
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	handler, store := cartHandler(t)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	query := url.Values{"query": {`query ($id: String!) { cartItems(cart_id: $id) { cart_id } }`}, "variables": {`{"id":"` + cartID + `"}`}}
+	resp, err := http.Get(server.URL + "?" + query.Encode())
+	if err != nil {
+		t.Fatalf("Error querying: %v", err)
+	}
+	var response map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&response)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || response["data"] == nil {
+		t.Errorf("Expected data, got %d %v", resp.StatusCode, response)
+	}
+
+	resp, err = http.Post(server.URL, "application/json", strings.NewReader(`{"query": "{ cartItems(cart_id: \"`+cartID+`\") { totals { item_count } } }"}`))
+	if err != nil {
+		t.Fatalf("Error querying: %v", err)
+	}
+	json.NewDecoder(resp.Body).Decode(&response)
+	resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "application/json" || response["errors"] != nil {
+		t.Errorf("Expected a JSON response without errors, got %v", response)
+	}
+
+	body := `{"query": "subscription { cartItems(cart_id: \"` + cartID + `\") { totals { item_count } } }"}`
+	req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(body))
+	req.Header.Set("Accept", "text/event-stream")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	resp, err = http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatalf("Error subscribing: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %s", resp.Header.Get("Content-Type"))
+	}
+
+	events := bufio.NewScanner(resp.Body)
+	nextData := func() string {
+		for events.Scan() {
+			if data, isData := strings.CutPrefix(events.Text(), "data: "); isData {
+				return data
+			}
+		}
+		t.Fatalf("Event stream ended: %v", events.Err())
+		return ""
+	}
+	if got := nextData(); got != `{"data":{"cartItems":{"totals":{"item_count":3}}}}` {
+		t.Errorf("Unexpected first event %s", got)
+	}
+	store.Append(cart.NewCartClearedEvent(cartID, 5))
+	if got := nextData(); got != `{"data":{"cartItems":{"totals":{"item_count":0}}}}` {
+		t.Errorf("Unexpected event after clearing the cart %s", got)
+	}
+}
+
+// TestSchemaDocIsCurrent keeps docs/schema.graphql in sync with the cart HTTP routes.
+// Regenerate it with: go run ./cmd/sem graphql > docs/schema.graphql
+func TestSchemaDocIsCurrent(t *testing.T) {
+	handler, _ := cartHandler(t)
+
+	doc, err := os.ReadFile("../docs/schema.graphql")
+	if err != nil {
+		t.Fatalf("Error reading GraphQL schema: %v", err)
+	}
+	if string(doc) != handler.Schema().SDL() {
+		t.Error("docs/schema.graphql is stale; regenerate it with: go run ./cmd/sem graphql > docs/schema.graphql")
+	}
+}
+
+func TestFieldName(t *testing.T) {
+	for name, want := range map[string]string{"cart-items": "cartItems", "CartItems": "cartItems", "order_lines-v2": "orderLinesV2"} {
+		if got := FieldName(name); got != want {
+			t.Errorf("FieldName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,530 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Location is a position in a GraphQL document, counted from 1
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// document is a parsed executable document. Only operations made of fields are
+// supported; fragments and directives are rejected.
+type document struct {
+	operations []*operation
+}
+
+// operation is a query, mutation, or subscription
+type operation struct {
+	kind       string
+	name       string
+	variables  []*variableDefinition
+	selections []*selection
+	loc        Location
+}
+
+type variableDefinition struct {
+	name         string
+	typ          *typeRef
+	defaultValue interface{}
+	loc          Location
+}
+
+// selection is a field selection, e.g. `total: cartItems(cart_id: $id) { cart_id }`
+type selection struct {
+	alias      string
+	name       string
+	args       []*argumentValue
+	selections []*selection
+	loc        Location
+}
+
+// key is the name of the selection in the response
+func (s *selection) key() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	return s.name
+}
+
+type argumentValue struct {
+	name  string
+	value interface{}
+	loc   Location
+}
+
+// Parsed values are strings, int64s, float64s, bools, nil, enum values, variable
+// references, []interface{} lists, and map[string]interface{} objects
+type (
+	enumValue string
+	variable  string
+)
+
+// token kinds
+const (
+	tokenEOF = iota
+	tokenPunct
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind  int
+	value string
+	loc   Location
+}
+
+// lexer splits a document into tokens, skipping whitespace, commas, and comments
+type lexer struct {
+	src  string
+	pos  int
+	line int
+	// lineStart is the offset at which the current line starts
+	lineStart int
+}
+
+func (l *lexer) location() Location {
+	return Location{Line: l.line, Column: utf8.RuneCountInString(l.src[l.lineStart:l.pos]) + 1}
+}
+
+func (l *lexer) next() (token, error) {
+	l.skipIgnored()
+	loc := l.location()
+	if l.pos >= len(l.src) {
+		return token{kind: tokenEOF, loc: loc}, nil
+	}
+
+	c := l.src[l.pos]
+	switch {
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.pos += 3
+		return token{kind: tokenPunct, value: "...", loc: loc}, nil
+	case strings.IndexByte("!$()=:@[]{}|&", c) >= 0:
+		l.pos++
+		return token{kind: tokenPunct, value: string(c), loc: loc}, nil
+	case c == '_' || isLetter(c):
+		start := l.pos
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		return token{kind: tokenName, value: l.src[start:l.pos], loc: loc}, nil
+	case c == '-' || isDigit(c):
+		return l.number(loc)
+	case c == '"':
+		return l.string(loc)
+	}
+	return token{}, &Error{Message: fmt.Sprintf("Syntax Error: unexpected character %q", c), Locations: []Location{loc}}
+}
+
+func (l *lexer) skipIgnored() {
+	for l.pos < len(l.src) {
+		switch c := l.src[l.pos]; {
+		case c == '\n':
+			l.pos++
+			l.line++
+			l.lineStart = l.pos
+		case c == ' ' || c == '\t' || c == '\r' || c == ',':
+			l.pos++
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		case strings.HasPrefix(l.src[l.pos:], "\uFEFF"):
+			l.pos += len("\uFEFF")
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) number(loc Location) (token, error) {
+	start := l.pos
+	kind := tokenInt
+	if l.src[l.pos] == '-' {
+		l.pos++
+	}
+	l.digits()
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		kind = tokenFloat
+		l.pos++
+		l.digits()
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		kind = tokenFloat
+		l.pos++
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.pos++
+		}
+		l.digits()
+	}
+	value := l.src[start:l.pos]
+	if _, err := strconv.ParseFloat(value, 64); err != nil {
+		return token{}, &Error{Message: fmt.Sprintf("Syntax Error: invalid number %q", value), Locations: []Location{loc}}
+	}
+	return token{kind: kind, value: value, loc: loc}, nil
+}
+
+func (l *lexer) digits() {
+	for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+		l.pos++
+	}
+}
+
+func (l *lexer) string(loc Location) (token, error) {
+	if strings.HasPrefix(l.src[l.pos:], `"""`) {
+		end := strings.Index(l.src[l.pos+3:], `"""`)
+		if end < 0 {
+			return token{}, &Error{Message: "Syntax Error: unterminated string", Locations: []Location{loc}}
+		}
+		value := l.src[l.pos+3 : l.pos+3+end]
+		for _, c := range value {
+			if c == '\n' {
+				l.line++
+			}
+		}
+		l.pos += 3 + end + 3
+		if i := strings.LastIndexByte(l.src[:l.pos], '\n'); i >= 0 {
+			l.lineStart = i + 1
+		}
+		return token{kind: tokenString, value: value, loc: loc}, nil
+	}
+
+	// Single-line strings use the escapes of JSON
+	for end := l.pos + 1; end < len(l.src); end++ {
+		switch l.src[end] {
+		case '\\':
+			end++
+		case '\n':
+			end = len(l.src)
+		case '"':
+			value, err := strconv.Unquote(l.src[l.pos : end+1])
+			if err != nil {
+				return token{}, &Error{Message: "Syntax Error: invalid string " + l.src[l.pos:end+1], Locations: []Location{loc}}
+			}
+			l.pos = end + 1
+			return token{kind: tokenString, value: value, loc: loc}, nil
+		}
+	}
+	return token{}, &Error{Message: "Syntax Error: unterminated string", Locations: []Location{loc}}
+}
+
+func isLetter(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
+
+// parser builds a document from the tokens of a lexer, one token of lookahead
+type parser struct {
+	lexer lexer
+	tok   token
+}
+
+// parse parses an executable GraphQL document
+func parse(src string) (*document, error) {
+	p := &parser{lexer: lexer{src: src, line: 1}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	doc := &document{}
+	for p.tok.kind != tokenEOF {
+		op, err := p.operation()
+		if err != nil {
+			return nil, err
+		}
+		doc.operations = append(doc.operations, op)
+	}
+	if len(doc.operations) == 0 {
+		return nil, &Error{Message: "Syntax Error: the document has no operations", Locations: []Location{p.tok.loc}}
+	}
+	return doc, nil
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &Error{Message: "Syntax Error: " + fmt.Sprintf(format, args...), Locations: []Location{p.tok.loc}}
+}
+
+func (p *parser) describe() string {
+	switch p.tok.kind {
+	case tokenEOF:
+		return "end of document"
+	case tokenString:
+		return strconv.Quote(p.tok.value)
+	}
+	return p.tok.value
+}
+
+// peek reports whether the current token is the given punctuator
+func (p *parser) peek(punct string) bool {
+	return p.tok.kind == tokenPunct && p.tok.value == punct
+}
+
+// skip consumes the given punctuator if it is the current token
+func (p *parser) skip(punct string) (bool, error) {
+	if !p.peek(punct) {
+		return false, nil
+	}
+	return true, p.advance()
+}
+
+func (p *parser) expect(punct string) error {
+	if !p.peek(punct) {
+		return p.errorf("expected %q, found %s", punct, p.describe())
+	}
+	return p.advance()
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokenName {
+		return "", p.errorf("expected a name, found %s", p.describe())
+	}
+	name := p.tok.value
+	return name, p.advance()
+}
+
+func (p *parser) operation() (*operation, error) {
+	op := &operation{kind: "query", loc: p.tok.loc}
+	if p.peek("{") {
+		selections, err := p.selectionSet()
+		op.selections = selections
+		return op, err
+	}
+
+	if p.tok.kind == tokenName && p.tok.value == "fragment" {
+		return nil, p.errorf("fragments are not supported")
+	}
+	if p.tok.kind != tokenName || (p.tok.value != "query" && p.tok.value != "mutation" && p.tok.value != "subscription") {
+		return nil, p.errorf("expected an operation, found %s", p.describe())
+	}
+	op.kind = p.tok.value
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokenName {
+		op.name = p.tok.value
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if open, err := p.skip("("); err != nil {
+		return nil, err
+	} else if open {
+		for !p.peek(")") {
+			definition, err := p.variableDefinition()
+			if err != nil {
+				return nil, err
+			}
+			op.variables = append(op.variables, definition)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek("@") {
+		return nil, p.errorf("directives are not supported")
+	}
+
+	selections, err := p.selectionSet()
+	op.selections = selections
+	return op, err
+}
+
+func (p *parser) variableDefinition() (*variableDefinition, error) {
+	definition := &variableDefinition{loc: p.tok.loc}
+	if err := p.expect("$"); err != nil {
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	definition.name = name
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	if definition.typ, err = p.typeRef(); err != nil {
+		return nil, err
+	}
+	if hasDefault, err := p.skip("="); err != nil {
+		return nil, err
+	} else if hasDefault {
+		if definition.defaultValue, err = p.value(true); err != nil {
+			return nil, err
+		}
+	}
+	return definition, nil
+}
+
+func (p *parser) typeRef() (*typeRef, error) {
+	ref := &typeRef{}
+	if list, err := p.skip("["); err != nil {
+		return nil, err
+	} else if list {
+		if ref.elem, err = p.typeRef(); err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+	} else if ref.name, err = p.name(); err != nil {
+		return nil, err
+	}
+
+	nonNull, err := p.skip("!")
+	ref.nonNull = nonNull
+	return ref, err
+}
+
+func (p *parser) selectionSet() ([]*selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var selections []*selection
+	for !p.peek("}") {
+		if p.peek("...") {
+			return nil, p.errorf("fragments are not supported")
+		}
+		s, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, s)
+	}
+	if len(selections) == 0 {
+		return nil, p.errorf("expected a field, found \"}\"")
+	}
+	return selections, p.advance()
+}
+
+func (p *parser) selection() (*selection, error) {
+	s := &selection{loc: p.tok.loc}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	s.name = name
+	if aliased, err := p.skip(":"); err != nil {
+		return nil, err
+	} else if aliased {
+		s.alias = name
+		if s.name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+
+	if open, err := p.skip("("); err != nil {
+		return nil, err
+	} else if open {
+		for !p.peek(")") {
+			arg := &argumentValue{loc: p.tok.loc}
+			if arg.name, err = p.name(); err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if arg.value, err = p.value(false); err != nil {
+				return nil, err
+			}
+			s.args = append(s.args, arg)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek("@") {
+		return nil, p.errorf("directives are not supported")
+	}
+
+	if p.peek("{") {
+		if s.selections, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// value parses a value; constant values, such as variable defaults, may not
+// reference variables
+func (p *parser) value(constant bool) (interface{}, error) {
+	tok := p.tok
+	switch {
+	case tok.kind == tokenPunct && tok.value == "$" && !constant:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		return variable(name), err
+	case tok.kind == tokenInt:
+		n, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			return nil, p.errorf("integer %s out of range", tok.value)
+		}
+		return n, p.advance()
+	case tok.kind == tokenFloat:
+		f, _ := strconv.ParseFloat(tok.value, 64)
+		return f, p.advance()
+	case tok.kind == tokenString:
+		return tok.value, p.advance()
+	case tok.kind == tokenName:
+		var value interface{}
+		switch tok.value {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		case "null":
+			value = nil
+		default:
+			value = enumValue(tok.value)
+		}
+		return value, p.advance()
+	case tok.kind == tokenPunct && tok.value == "[":
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		list := []interface{}{}
+		for !p.peek("]") {
+			item, err := p.value(constant)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		return list, p.advance()
+	case tok.kind == tokenPunct && tok.value == "{":
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		object := map[string]interface{}{}
+		for !p.peek("}") {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if object[name], err = p.value(constant); err != nil {
+				return nil, err
+			}
+		}
+		return object, p.advance()
+	}
+	return nil, p.errorf("expected a value, found %s", p.describe())
+}
@@ -0,0 +1,300 @@
+package graphql
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"simple-event-modeling/httpapi"
+)
+
+// typeRef references a GraphQL type: a named type, or a list of another type
+type typeRef struct {
+	// name is the named type; empty for lists
+	name string
+	// elem is the element type of a list
+	elem    *typeRef
+	nonNull bool
+}
+
+func (t *typeRef) String() string {
+	s := t.name
+	if t.elem != nil {
+		s = "[" + t.elem.String() + "]"
+	}
+	if t.nonNull {
+		s += "!"
+	}
+	return s
+}
+
+// objectType is an object type generated from a Go struct, or the key/value entry
+// type standing in for a Go map, which GraphQL has no type for
+type objectType struct {
+	name   string
+	fields []*field
+	// entry marks map entry types, whose values are built from JSON objects
+	entry bool
+}
+
+func (o *objectType) field(name string) *field {
+	for _, f := range o.fields {
+		if f.name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// field is a field of an object type. Fields of the root types resolve their query
+// route; all other fields read the JSON field of the same name.
+type field struct {
+	name        string
+	description string
+	typ         *typeRef
+	args        []*argument
+	route       *httpapi.QueryRoute
+}
+
+func (f *field) argument(name string) *argument {
+	for _, arg := range f.args {
+		if arg.name == name {
+			return arg
+		}
+	}
+	return nil
+}
+
+// argument is an argument of a root field, read into the query route's params
+type argument struct {
+	name string
+	typ  *typeRef
+}
+
+// Built-in scalars, plus JSON for values without a fixed shape
+const (
+	scalarString  = "String"
+	scalarInt     = "Int"
+	scalarFloat   = "Float"
+	scalarBoolean = "Boolean"
+	scalarJSON    = "JSON"
+)
+
+// Schema is the GraphQL schema generated from the query routes of an HTTP API: each
+// route is a field of the Query type, and each route with a Version is also a field
+// of the Subscription type, pushing a new result whenever its stream changes
+type Schema struct {
+	query        *objectType
+	subscription *objectType
+	types        map[string]*objectType
+	// json records whether any field uses the JSON scalar
+	json bool
+}
+
+// NewSchema generates the schema of the given query routes
+func NewSchema(routes []httpapi.QueryRoute) *Schema {
+	s := &Schema{
+		query:        &objectType{name: "Query"},
+		subscription: &objectType{name: "Subscription"},
+		types:        make(map[string]*objectType),
+	}
+	for i := range routes {
+		route := &routes[i]
+		f := &field{
+			name:        FieldName(route.Name),
+			description: route.Description,
+			typ:         s.typeFor(reflect.TypeOf(route.Result), false),
+			route:       route,
+		}
+		for _, param := range jsonFields(reflect.TypeOf(route.Params)) {
+			f.args = append(f.args, &argument{name: param.name, typ: s.typeFor(param.typ, param.required)})
+		}
+		s.query.fields = append(s.query.fields, f)
+		if route.Version != nil {
+			s.subscription.fields = append(s.subscription.fields, f)
+		}
+	}
+	return s
+}
+
+// FieldName returns the GraphQL field name of a query route, camel-casing names
+// such as "cart-items" to "cartItems"
+func FieldName(routeName string) string {
+	words := strings.FieldsFunc(routeName, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var name strings.Builder
+	for i, word := range words {
+		if i == 0 {
+			name.WriteString(strings.ToLower(word[:1]) + word[1:])
+		} else {
+			name.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return name.String()
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// typeFor returns the GraphQL type of values encoded from t by encoding/json.
+// Values are non-null when required and never encoded as null.
+func (s *Schema) typeFor(t reflect.Type, required bool) *typeRef {
+	nullable := false
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+		nullable = true
+	}
+	ref := &typeRef{nonNull: required && !nullable}
+
+	switch {
+	case t == timeType:
+		ref.name = scalarString
+	case t.Kind() == reflect.String:
+		ref.name = scalarString
+	case t.Kind() == reflect.Bool:
+		ref.name = scalarBoolean
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		ref.name = scalarInt
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		ref.name = scalarFloat
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		// encoding/json writes byte slices as base64 strings
+		ref.name = scalarString
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		ref.elem = s.typeFor(t.Elem(), true)
+		ref.nonNull = ref.nonNull && t.Kind() == reflect.Array
+	case t.Kind() == reflect.Map && t.Key().Kind() == reflect.String && t.Elem().Kind() != reflect.Interface:
+		ref.elem = &typeRef{name: s.entryType(t.Elem()), nonNull: true}
+		ref.nonNull = false
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		ref.name = s.objectType(t)
+	default:
+		s.json = true
+		ref.name = scalarJSON
+		ref.nonNull = false
+	}
+	return ref
+}
+
+// objectType returns the name of the object type of a named struct, generating it
+// on first use
+func (s *Schema) objectType(t reflect.Type) string {
+	if _, exists := s.types[t.Name()]; exists {
+		return t.Name()
+	}
+	// Register the type first so recursive types terminate
+	object := &objectType{name: t.Name()}
+	s.types[object.name] = object
+	for _, f := range jsonFields(t) {
+		object.fields = append(object.fields, &field{name: f.name, typ: s.typeFor(f.typ, f.required)})
+	}
+	return object.name
+}
+
+// entryType returns the name of the entry type of maps with values of type t
+func (s *Schema) entryType(t reflect.Type) string {
+	value := s.typeFor(t, true)
+	base := value
+	for base.elem != nil {
+		base = base.elem
+	}
+	name := base.name + "Entry"
+	if value.elem != nil {
+		name = base.name + "ListEntry"
+	}
+	if _, exists := s.types[name]; !exists {
+		s.types[name] = &objectType{
+			name:  name,
+			entry: true,
+			fields: []*field{
+				{name: "key", typ: &typeRef{name: scalarString, nonNull: true}},
+				{name: "value", typ: value},
+			},
+		}
+	}
+	return name
+}
+
+// SDL renders the schema in the GraphQL schema definition language
+func (s *Schema) SDL() string {
+	var sdl strings.Builder
+	if s.json {
+		sdl.WriteString("\"Any JSON value\"\nscalar JSON\n\n")
+	}
+	writeObject(&sdl, s.query)
+	if len(s.subscription.fields) > 0 {
+		sdl.WriteString("\n")
+		writeObject(&sdl, s.subscription)
+	}
+
+	names := make([]string, 0, len(s.types))
+	for name := range s.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sdl.WriteString("\n")
+		writeObject(&sdl, s.types[name])
+	}
+	return sdl.String()
+}
+
+func writeObject(sdl *strings.Builder, object *objectType) {
+	fmt.Fprintf(sdl, "type %s {\n", object.name)
+	for _, f := range object.fields {
+		if f.description != "" {
+			fmt.Fprintf(sdl, "  %q\n", f.description)
+		}
+		sdl.WriteString("  " + f.name)
+		if len(f.args) > 0 {
+			args := make([]string, len(f.args))
+			for i, arg := range f.args {
+				args[i] = arg.name + ": " + arg.typ.String()
+			}
+			sdl.WriteString("(" + strings.Join(args, ", ") + ")")
+		}
+		sdl.WriteString(": " + f.typ.String() + "\n")
+	}
+	sdl.WriteString("}\n")
+}
+
+// jsonField is an exported struct field as seen through its JSON tag
+type jsonField struct {
+	name     string
+	typ      reflect.Type
+	required bool
+}
+
+// jsonFields lists the JSON-visible fields of a struct type. Fields tagged
+// `omitempty` or `omitzero` are optional; all others are required.
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name := f.Name
+		required := true
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			parts := strings.Split(tag, ",")
+			if parts[0] == "-" {
+				continue
+			}
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			for _, opt := range parts[1:] {
+				if opt == "omitempty" || opt == "omitzero" {
+					required = false
+				}
+			}
+		}
+		fields = append(fields, jsonField{name: name, typ: f.Type, required: required})
+	}
+	return fields
+}
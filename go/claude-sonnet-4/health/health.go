@@ -0,0 +1,248 @@
+// Package health reports whether a service is alive and ready to serve, aggregating
+// checks of the parts it depends on: the store backend, subscriptions that must keep
+// up with the event log (projections, process managers, outbox relays), and the
+// timer scheduler.
+//
+// Liveness checks tell an orchestrator to restart a process that cannot recover on
+// its own; readiness checks tell a load balancer to stop sending it traffic, e.g.
+// while the store is unreachable or a read model lags too far behind. Readiness also
+// runs the liveness checks. Both are served over HTTP for probes:
+//
+//	mux.Handle("/healthz", checker.LivenessHandler())
+//	mux.Handle("/readyz", checker.ReadinessHandler())
+//
+// answering 200 OK when every check passes and 503 Service Unavailable otherwise,
+// with a JSON Report listing the result of each check.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"simple-event-modeling/common"
+	"simple-event-modeling/scheduler"
+)
+
+// DefaultTimeout bounds each check when the Checker has no Timeout
+const DefaultTimeout = 2 * time.Second
+
+// Statuses of checks and reports
+const (
+	StatusPass = "pass"
+	StatusFail = "fail"
+)
+
+// Check reports a problem with a dependency by returning an error. Checks should
+// return when ctx is done; a check still running at the timeout fails.
+type Check func(ctx context.Context) error
+
+// Result is the outcome of one check
+type Result struct {
+	Name     string        `json:"name"`
+	Status   string        `json:"status"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// Report is the outcome of running a set of checks, sorted by name
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// Healthy reports whether every check passed
+func (r Report) Healthy() bool {
+	return r.Status == StatusPass
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker runs the registered liveness and readiness checks
+type Checker struct {
+	// Timeout bounds each check; 0 uses DefaultTimeout
+	Timeout time.Duration
+
+	mu        sync.Mutex
+	liveness  []namedCheck
+	readiness []namedCheck
+}
+
+// New creates a checker without checks, which reports healthy
+func New() *Checker {
+	return &Checker{}
+}
+
+// AddLiveness registers a check that fails when the process must be restarted
+func (c *Checker) AddLiveness(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.liveness = append(c.liveness, namedCheck{name, check})
+}
+
+// AddReadiness registers a check that fails while the process should not serve
+func (c *Checker) AddReadiness(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readiness = append(c.readiness, namedCheck{name, check})
+}
+
+// Liveness runs the liveness checks
+func (c *Checker) Liveness(ctx context.Context) Report {
+	c.mu.Lock()
+	checks := append([]namedCheck(nil), c.liveness...)
+	c.mu.Unlock()
+	return c.run(ctx, checks)
+}
+
+// Readiness runs the liveness and readiness checks
+func (c *Checker) Readiness(ctx context.Context) Report {
+	c.mu.Lock()
+	checks := append(append([]namedCheck(nil), c.liveness...), c.readiness...)
+	c.mu.Unlock()
+	return c.run(ctx, checks)
+}
+
+// run runs checks concurrently, each bounded by the timeout
+func (c *Checker) run(ctx context.Context, checks []namedCheck) Report {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = runCheck(ctx, check, timeout)
+		}()
+	}
+	wg.Wait()
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	report := Report{Status: StatusPass, Checks: results}
+	for _, result := range results {
+		if result.Status != StatusPass {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+func runCheck(ctx context.Context, check namedCheck, timeout time.Duration) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check.check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	result := Result{Name: check.name, Status: StatusPass, Duration: time.Since(start)}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// LivenessHandler serves the liveness report, e.g. at /healthz
+func (c *Checker) LivenessHandler() http.Handler {
+	return c.handler(c.Liveness)
+}
+
+// ReadinessHandler serves the readiness report, e.g. at /readyz
+func (c *Checker) ReadinessHandler() http.Handler {
+	return c.handler(c.Readiness)
+}
+
+func (c *Checker) handler(run func(context.Context) Report) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		report := run(r.Context())
+		status := http.StatusOK
+		if !report.Healthy() {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(report)
+	})
+}
+
+// Pinger is implemented by store backends that can check their connection, such as
+// redisstore.RedisStore
+type Pinger interface {
+	Ping() error
+}
+
+// probeStream is read by StoreCheck; it is not expected to exist
+const probeStream = "$health"
+
+// StoreCheck checks that a store answers reads, pinging backends that implement
+// Pinger
+func StoreCheck(store common.Store) Check {
+	return func(ctx context.Context) error {
+		if pinger, ok := store.(Pinger); ok {
+			if err := pinger.Ping(); err != nil {
+				return err
+			}
+		}
+		_, err := store.HeadEvent(probeStream)
+		var notFound *common.StreamNotFoundError
+		if err != nil && !errors.As(err, &notFound) {
+			return err
+		}
+		return nil
+	}
+}
+
+// Checkpointer is a subscription following the global event log, such as a
+// common.AsyncProjection or a process.Manager
+type Checkpointer interface {
+	Checkpoint() common.Checkpoint
+}
+
+// LagCheck fails when a subscription is more than maxLag events behind the end of
+// the global event log of store
+func LagCheck(store common.Store, subscription Checkpointer, maxLag int) Check {
+	return func(ctx context.Context) error {
+		checkpoint := subscription.Checkpoint()
+		if lag := len(common.EventsAfter(store.GetAllEvents(), checkpoint.Position)); lag > maxLag {
+			return fmt.Errorf("%s is %d events behind (max %d)", checkpoint.Projection, lag, maxLag)
+		}
+		return nil
+	}
+}
+
+// SchedulerCheck fails when timers were due more than grace ago without firing,
+// which means the scheduler is not running or cannot append
+func SchedulerCheck(s *scheduler.Scheduler, grace time.Duration) Check {
+	return func(ctx context.Context) error {
+		overdue := s.Overdue(grace)
+		if len(overdue) > 0 {
+			return fmt.Errorf("%d timers overdue, the oldest due at %s", len(overdue), overdue[0].DueAt.Format(time.RFC3339))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,115 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"simple-event-modeling/common"
+	"simple-event-modeling/scheduler"
+)
+
+// brokenStore fails every read, like a backend that lost its connection
+type brokenStore struct {
+	*common.EventStore
+}
+
+func (brokenStore) GetStream(string) ([]*common.Event, error) {
+	return nil, errors.New("connection refused")
+}
+
+func (brokenStore) HeadEvent(string) (*common.Event, error) {
+	return nil, errors.New("connection refused")
+}
+
+type fixedCheckpoint int
+
+func (c fixedCheckpoint) Checkpoint() common.Checkpoint {
+	return common.Checkpoint{Projection: "cart-items", Position: int(c)}
+}
+
+func TestChecker_Reports(t *testing.T) {
+	checker := New()
+	checker.Timeout = 10 * time.Millisecond
+	checker.AddLiveness("store", StoreCheck(common.NewEventStore()))
+	checker.AddReadiness("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	})
+
+	if report := checker.Liveness(context.Background()); !report.Healthy() || len(report.Checks) != 1 {
+		t.Errorf("Expected a healthy liveness report with one check, got %+v", report)
+	}
+	report := checker.Readiness(context.Background())
+	if report.Healthy() || len(report.Checks) != 2 {
+		t.Fatalf("Expected readiness to run both checks and fail, got %+v", report)
+	}
+	if slow := report.Checks[0]; slow.Name != "slow" || slow.Status != StatusFail || slow.Error != "timed out after 10ms" {
+		t.Errorf("Expected the slow check to time out, got %+v", slow)
+	}
+	if store := report.Checks[1]; store.Name != "store" || store.Status != StatusPass {
+		t.Errorf("Expected the store check to pass, got %+v", store)
+	}
+}
+
+func TestChecks(t *testing.T) {
+	store := common.NewEventStore()
+	for version := 1; version <= 5; version++ {
+		store.Append(common.NewEvent("ItemAdded", "cart-1", version, nil, nil))
+	}
+
+	if err := StoreCheck(brokenStore{store})(context.Background()); err == nil || err.Error() != "connection refused" {
+		t.Errorf("Expected the store check to fail, got %v", err)
+	}
+	if err := LagCheck(store, fixedCheckpoint(3), 2)(context.Background()); err != nil {
+		t.Errorf("Expected a lag of 2 to pass, got %v", err)
+	}
+	if err := LagCheck(store, fixedCheckpoint(2), 2)(context.Background()); err == nil || err.Error() != "cart-items is 3 events behind (max 2)" {
+		t.Errorf("Expected a lag of 3 to fail, got %v", err)
+	}
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	timers, err := scheduler.New(store, scheduler.WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatalf("Error creating scheduler: %v", err)
+	}
+	timers.Schedule("checkout-1", "PaymentTimeout", now.Add(-time.Minute), nil)
+	if err := SchedulerCheck(timers, 5*time.Minute)(context.Background()); err != nil {
+		t.Errorf("Expected a timer within the grace period to pass, got %v", err)
+	}
+	if err := SchedulerCheck(timers, 30*time.Second)(context.Background()); err == nil {
+		t.Error("Expected an overdue timer to fail")
+	}
+}
+
+func TestChecker_Handlers(t *testing.T) {
+	checker := New()
+	checker.AddReadiness("store", StoreCheck(brokenStore{common.NewEventStore()}))
+
+	rec := httptest.NewRecorder()
+	checker.LivenessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 from /healthz, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	checker.ReadinessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var report Report
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("Error decoding report: %v", err)
+	}
+	if rec.Code != http.StatusServiceUnavailable || report.Status != StatusFail || report.Checks[0].Error != "connection refused" {
+		t.Errorf("Expected 503 with the failed check, got %d %+v", rec.Code, report)
+	}
+
+	rec = httptest.NewRecorder()
+	checker.ReadinessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/readyz", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for POST, got %d", rec.Code)
+	}
+}
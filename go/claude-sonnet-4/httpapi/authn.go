@@ -0,0 +1,232 @@
+package httpapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+)
+
+// APIKeyHeader carries the API key of requests authenticated by APIKeys
+const APIKeyHeader = "X-API-Key"
+
+// MinJWTSecretLength is the shortest HMAC secret JWT accepts, in bytes: the 256 bits
+// of the SHA-256 output
+const MinJWTSecretLength = 32
+
+// ErrWeakJWTSecret is returned for a JWT whose secret is empty or shorter than
+// MinJWTSecretLength, which would let anyone forge tokens
+var ErrWeakJWTSecret = fmt.Errorf("JWT secret must be at least %d bytes", MinJWTSecretLength)
+
+// Authenticator resolves the principal making a request from its credentials. It
+// reports false when the request carries no credentials it understands, and fails
+// with a *common.UnauthenticatedError when it does but they are invalid.
+type Authenticator interface {
+	Authenticate(r *http.Request) (bus.Principal, bool, error)
+}
+
+// AuthenticatorFunc adapts a function to the Authenticator interface
+type AuthenticatorFunc func(r *http.Request) (bus.Principal, bool, error)
+
+// Authenticate calls f
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (bus.Principal, bool, error) {
+	return f(r)
+}
+
+// Authenticators tries each authenticator in turn, using the first that finds
+// credentials in the request
+type Authenticators []Authenticator
+
+// Authenticate calls the authenticators in order
+func (a Authenticators) Authenticate(r *http.Request) (bus.Principal, bool, error) {
+	for _, authenticator := range a {
+		if principal, found, err := authenticator.Authenticate(r); found || err != nil {
+			return principal, found, err
+		}
+	}
+	return bus.Principal{}, false, nil
+}
+
+// APIKeys authenticates requests by the key in their X-API-Key header, mapping each
+// key to the principal it was issued to
+type APIKeys map[string]bus.Principal
+
+// Authenticate looks up the request's API key
+func (k APIKeys) Authenticate(r *http.Request) (bus.Principal, bool, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		return bus.Principal{}, false, nil
+	}
+	for candidate, principal := range k {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(key)) == 1 {
+			return principal, true, nil
+		}
+	}
+	return bus.Principal{}, true, &common.UnauthenticatedError{Scheme: "APIKey", Reason: "unknown key"}
+}
+
+// JWT authenticates bearer tokens signed with HMAC-SHA256 (HS256). The token's
+// "sub" claim is the principal's ID and a list of strings in its roles claim are
+// the principal's roles. Secret must be at least MinJWTSecretLength bytes; Verify
+// rejects every token otherwise.
+type JWT struct {
+	Secret []byte
+	// Issuer, when set, must equal the token's "iss" claim
+	Issuer string
+	// Audience, when set, must be one of the token's "aud" claims
+	Audience string
+	// RolesClaim names the claim listing the roles; empty uses "roles"
+	RolesClaim string
+	// Now returns the time tokens are checked for expiry at; nil uses time.Now
+	Now func() time.Time
+}
+
+// NewJWT creates a JWT authenticator for tokens signed with secret, failing with
+// ErrWeakJWTSecret when the secret is too short
+func NewJWT(secret []byte) (*JWT, error) {
+	if len(secret) < MinJWTSecretLength {
+		return nil, ErrWeakJWTSecret
+	}
+	return &JWT{Secret: secret}, nil
+}
+
+// Authenticate verifies the request's bearer token
+func (j *JWT) Authenticate(r *http.Request) (bus.Principal, bool, error) {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		return bus.Principal{}, false, nil
+	}
+	principal, err := j.Verify(strings.TrimSpace(token))
+	return principal, true, err
+}
+
+// Verify checks a token's signature and claims, returning the principal it names
+func (j *JWT) Verify(token string) (bus.Principal, error) {
+	invalid := func(reason string) (bus.Principal, error) {
+		return bus.Principal{}, &common.UnauthenticatedError{Scheme: "Bearer", Reason: reason}
+	}
+	if len(j.Secret) < MinJWTSecretLength {
+		return bus.Principal{}, ErrWeakJWTSecret
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return invalid("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return invalid("malformed header")
+	}
+	if header.Alg != "HS256" {
+		return invalid("unsupported algorithm " + header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return invalid("malformed signature")
+	}
+	mac := hmac.New(sha256.New, j.Secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return invalid("bad signature")
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return invalid("malformed claims")
+	}
+	now := time.Now
+	if j.Now != nil {
+		now = j.Now
+	}
+	if exp, ok := claims["exp"].(float64); ok && !now().Before(time.Unix(int64(exp), 0)) {
+		return invalid("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now().Before(time.Unix(int64(nbf), 0)) {
+		return invalid("token not yet valid")
+	}
+	if j.Issuer != "" && claims["iss"] != j.Issuer {
+		return invalid("unexpected issuer")
+	}
+	if j.Audience != "" && !containsString(claims["aud"], j.Audience) {
+		return invalid("unexpected audience")
+	}
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return invalid("missing subject")
+	}
+
+	principal := bus.Principal{ID: subject}
+	rolesClaim := j.RolesClaim
+	if rolesClaim == "" {
+		rolesClaim = "roles"
+	}
+	if roles, ok := claims[rolesClaim].([]interface{}); ok {
+		for _, role := range roles {
+			if name, ok := role.(string); ok {
+				principal.Roles = append(principal.Roles, name)
+			}
+		}
+	}
+	return principal, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// containsString reports whether a claim, a string or a list of strings, holds want
+func containsString(claim interface{}, want string) bool {
+	switch v := claim.(type) {
+	case string:
+		return v == want
+	case []interface{}:
+		for _, item := range v {
+			if item == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Authenticate wraps a handler, such as a Server or a GraphQL or admin handler, so
+// requests run with the principal resolved by authenticator in their context (see
+// bus.PrincipalFrom), where the command bus authorization and actor metadata
+// middleware find it. Requests with invalid credentials are answered with 401
+// Unauthorized. Requests without credentials are too when required is set, and
+// otherwise continue anonymously, leaving authorizers to decide.
+func Authenticate(authenticator Authenticator, required bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, found, err := authenticator.Authenticate(r)
+		if err == nil && !found && required {
+			err = &common.UnauthenticatedError{Reason: "no credentials"}
+		}
+		if err != nil {
+			var unauthenticated *common.UnauthenticatedError
+			if errors.As(err, &unauthenticated) && unauthenticated.Scheme == "Bearer" {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			}
+			writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: err.Error(), Type: "AuthenticationError", Code: common.ErrorCodeOf(err)})
+			return
+		}
+		if found {
+			r = r.WithContext(bus.WithPrincipal(r.Context(), principal))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,187 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+
+	"simple-event-modeling/common"
+)
+
+// OpenAPIVersion is the OpenAPI specification version of generated documents
+const OpenAPIVersion = "3.0.3"
+
+// OpenAPIDocument is an OpenAPI 3 document
+type OpenAPIDocument struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       OpenAPIInfo                     `json:"info"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components OpenAPIComponents               `json:"components"`
+}
+
+// OpenAPIInfo holds the document metadata
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenAPIComponents holds the reusable schemas
+type OpenAPIComponents struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Operation describes a single HTTP operation
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary,omitempty"`
+	Tags        []string            `json:"tags"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+// Parameter describes a URL query or header parameter
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody describes a JSON request body
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes a response, its headers, and its JSON body
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// Header describes a response header
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// MediaType wraps the schema of a body
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// OpenAPI generates the OpenAPI document describing the server's registered routes.
+// Error responses are documented from the server's error mappings.
+func (s *Server) OpenAPI(info OpenAPIInfo) OpenAPIDocument {
+	builder := &schemaBuilder{components: make(map[string]*Schema)}
+	errorSchema := builder.schemaFor(reflect.TypeOf(ErrorResponse{}))
+	errorResponses := s.errorResponses(errorSchema)
+
+	doc := OpenAPIDocument{
+		OpenAPI: OpenAPIVersion,
+		Info:    info,
+		Paths:   make(map[string]map[string]Operation),
+	}
+
+	eventSchema := builder.schemaFor(reflect.TypeOf(common.Event{}))
+	for _, route := range s.CommandRoutes() {
+		created := jsonResponse("The event produced by the command", eventSchema)
+		created.Headers = map[string]Header{
+			ConsistencyTokenHeader: {Description: "Token a query can pass to observe this write", Schema: &Schema{Type: "string"}},
+			"ETag":                 {Description: "Version of the stream after this write", Schema: &Schema{Type: "string"}},
+		}
+		responses := map[string]Response{
+			"201": created,
+			"400": jsonResponse("The request body could not be decoded", errorSchema),
+			"412": jsonResponse("The stream is not at the version named by If-Match", errorSchema),
+		}
+		for status, response := range errorResponses {
+			responses[status] = response
+		}
+
+		doc.Paths[commandsPrefix+route.Name] = map[string]Operation{
+			"post": {
+				OperationID: route.Name,
+				Summary:     route.Description,
+				Tags:        []string{"commands"},
+				Parameters:  []Parameter{{Name: "If-Match", In: "header", Schema: &Schema{Type: "string"}}},
+				RequestBody: &RequestBody{
+					Required: true,
+					Content:  map[string]MediaType{"application/json": {Schema: builder.schemaFor(reflect.TypeOf(route.Payload))}},
+				},
+				Responses: responses,
+			},
+		}
+	}
+
+	for _, route := range s.QueryRoutes() {
+		ok := jsonResponse("The query result", builder.schemaFor(reflect.TypeOf(route.Result)))
+		responses := map[string]Response{
+			"200": ok,
+			"400": jsonResponse("The parameters could not be decoded", errorSchema),
+		}
+		for status, response := range errorResponses {
+			responses[status] = response
+		}
+
+		params := make([]Parameter, 0)
+		for _, f := range structFields(reflect.TypeOf(route.Params)) {
+			params = append(params, Parameter{Name: f.name, In: "query", Required: f.required, Schema: builder.schemaFor(f.typ)})
+		}
+		params = append(params, Parameter{Name: ConsistencyTokenHeader, In: "header", Schema: &Schema{Type: "string"}})
+		if route.Version != nil {
+			ok.Headers = map[string]Header{"ETag": {Description: "Version of the stream the result reflects", Schema: &Schema{Type: "string"}}}
+			responses["200"] = ok
+			responses["304"] = Response{Description: "The result has not changed since the version named by If-None-Match"}
+			params = append(params, Parameter{Name: "If-None-Match", In: "header", Schema: &Schema{Type: "string"}})
+		}
+
+		doc.Paths[queriesPrefix+route.Name] = map[string]Operation{
+			"get": {
+				OperationID: route.Name,
+				Summary:     route.Description,
+				Tags:        []string{"queries"},
+				Parameters:  params,
+				Responses:   responses,
+			},
+		}
+	}
+
+	doc.Components.Schemas = builder.components
+	return doc
+}
+
+// JSON renders the document as indented JSON
+func (d OpenAPIDocument) JSON() ([]byte, error) {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// errorResponses documents each mapped error status, plus the 500 fallback
+func (s *Server) errorResponses(errorSchema *Schema) map[string]Response {
+	responses := map[string]Response{
+		"500": jsonResponse("InternalError", errorSchema),
+	}
+	for _, mapping := range s.ErrorMappings() {
+		status := strconv.Itoa(mapping.Status)
+		description := mapping.Type
+		if existing, exists := responses[status]; exists {
+			// Several error types can share a status
+			description = existing.Description + ", " + mapping.Type
+		}
+		responses[status] = jsonResponse(description, errorSchema)
+	}
+	return responses
+}
+
+func jsonResponse(description string, schema *Schema) Response {
+	return Response{
+		Description: description,
+		Content:     map[string]MediaType{"application/json": {Schema: schema}},
+	}
+}
@@ -0,0 +1,116 @@
+package httpapi
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schema is the subset of JSON Schema used in the OpenAPI document
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// field is an exported struct field as seen through its JSON tag
+type field struct {
+	name     string
+	index    []int
+	typ      reflect.Type
+	required bool
+}
+
+// structFields lists the JSON-visible fields of a struct type. Fields tagged
+// `omitempty` or `omitzero` are optional; all others are required.
+func structFields(t reflect.Type) []field {
+	fields := make([]field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name := f.Name
+		required := true
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			parts := strings.Split(tag, ",")
+			if parts[0] == "-" {
+				continue
+			}
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			for _, opt := range parts[1:] {
+				if opt == "omitempty" || opt == "omitzero" {
+					required = false
+				}
+			}
+		}
+		fields = append(fields, field{name: name, index: f.Index, typ: f.Type, required: required})
+	}
+	return fields
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaBuilder converts Go types to schemas, collecting named structs as components
+type schemaBuilder struct {
+	components map[string]*Schema
+}
+
+func (b *schemaBuilder) schemaFor(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.String:
+		return &Schema{Type: "string"}
+	case t.Kind() == reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return &Schema{Type: "number"}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		// encoding/json writes byte slices as base64 strings
+		return &Schema{Type: "string", Format: "byte"}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return &Schema{Type: "array", Items: b.schemaFor(t.Elem())}
+	case t.Kind() == reflect.Map:
+		schema := &Schema{Type: "object"}
+		if t.Elem().Kind() != reflect.Interface {
+			schema.AdditionalProperties = b.schemaFor(t.Elem())
+		}
+		return schema
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		if _, exists := b.components[t.Name()]; !exists {
+			// Reserve the name first so recursive types terminate
+			b.components[t.Name()] = &Schema{}
+			*b.components[t.Name()] = *b.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + t.Name()}
+	case t.Kind() == reflect.Struct:
+		return b.structSchema(t)
+	default:
+		return &Schema{}
+	}
+}
+
+func (b *schemaBuilder) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for _, f := range structFields(t) {
+		schema.Properties[f.name] = b.schemaFor(f.typ)
+		if f.required {
+			schema.Required = append(schema.Required, f.name)
+		}
+	}
+	return schema
+}
@@ -0,0 +1,445 @@
+// Package httpapi exposes registered command and query handlers over HTTP.
+//
+// Routes:
+//   - POST /commands/{name}  decode the JSON body into the command payload and handle it
+//   - GET  /queries/{name}   decode the URL parameters into the query payload and execute it
+//
+// Command responses carry a Consistency-Token header naming the event they wrote.
+// Passing it back on a query makes the query observe that write (read-your-writes);
+// handlers read it with common.ConsistencyTokenFrom.
+//
+// Responses of versioned queries and of commands carry an ETag naming the stream
+// version they reflect. A query sent with a matching If-None-Match answers 304 Not
+// Modified without running; a command sent with If-Match runs only against that
+// stream version (see common.WithExpectedVersion), failing with 412 otherwise.
+//
+// Domain packages register their handlers with a Server; the same registrations drive
+// the generated OpenAPI document, so clients stay in sync with the Go types.
+//
+// Authenticate wraps a Server, or any other handler, to resolve the caller from an
+// API key or a bearer JWT into the principal the command bus authorizes.
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"simple-event-modeling/common"
+)
+
+const (
+	commandsPrefix = "/commands/"
+	queriesPrefix  = "/queries/"
+)
+
+// ConsistencyTokenHeader carries the common.ConsistencyToken of a command's event in
+// command responses and the token a query must honor in query requests
+const ConsistencyTokenHeader = "Consistency-Token"
+
+// CommandHandlerFunc handles a decoded command. The command is a pointer to a new
+// value of the registered payload type.
+type CommandHandlerFunc func(ctx context.Context, command common.Command) (*common.Event, error)
+
+// QueryHandlerFunc executes a decoded query. The params value is a pointer to a new
+// value of the registered params type.
+type QueryHandlerFunc func(ctx context.Context, params interface{}) (interface{}, error)
+
+// CommandRoute describes a command exposed over HTTP
+type CommandRoute struct {
+	Name        string
+	Description string
+	// Payload is a zero value of the command struct, e.g. AddItemCommand{}.
+	// A pointer to it must implement common.Command.
+	Payload interface{}
+	Handler CommandHandlerFunc
+}
+
+// QueryRoute describes a query exposed over HTTP
+type QueryRoute struct {
+	Name        string
+	Description string
+	// Params is a zero value of the struct the URL parameters are decoded into
+	Params interface{}
+	// Result is a zero value of the type the handler returns, used for documentation
+	Result  interface{}
+	Handler QueryHandlerFunc
+	// Version optionally returns the stream version the result would reflect. It is
+	// sent as the ETag and enables If-None-Match, so it must be cheaper than Handler.
+	Version QueryVersionFunc
+}
+
+// QueryVersionFunc returns the latest write the result of a query reflects, given
+// its decoded params
+type QueryVersionFunc func(ctx context.Context, params interface{}) (common.ConsistencyToken, error)
+
+// ErrorMapping maps an error type to the HTTP status it is reported with
+type ErrorMapping struct {
+	// Type is the name reported in the "type" field of error responses
+	Type   string
+	Status int
+	// Match reports whether err belongs to this mapping
+	Match func(err error) bool
+}
+
+// ErrorResponse is the body of every non-2xx response
+type ErrorResponse struct {
+	Error string `json:"error"`
+	Type  string `json:"type"`
+	// Code is the common.ErrorCode of the error, e.g. "concurrency_conflict"
+	Code common.ErrorCode `json:"code,omitempty"`
+	// Fields lists the invalid fields of a command rejected by validation
+	Fields []common.FieldError `json:"fields,omitempty"`
+}
+
+// DefaultErrorMappings map the common error types to HTTP statuses
+var DefaultErrorMappings = []ErrorMapping{
+	{
+		Type:   "ValidationError",
+		Status: http.StatusUnprocessableEntity,
+		Match:  func(err error) bool { return errors.Is(err, common.ErrValidation) },
+	},
+	{
+		Type:   "InvalidCommandError",
+		Status: http.StatusUnprocessableEntity,
+		Match:  func(err error) bool { return errors.Is(err, common.ErrInvalidCommand) },
+	},
+	{
+		Type:   "AuthenticationError",
+		Status: http.StatusUnauthorized,
+		Match:  func(err error) bool { return errors.Is(err, common.ErrUnauthenticated) },
+	},
+	{
+		Type:   "UnauthorizedError",
+		Status: http.StatusForbidden,
+		Match:  func(err error) bool { return errors.Is(err, common.ErrUnauthorized) },
+	},
+	{
+		Type:   "StreamAccessError",
+		Status: http.StatusForbidden,
+		Match:  func(err error) bool { return errors.Is(err, common.ErrAccessDenied) },
+	},
+	{
+		Type:   "ConcurrencyError",
+		Status: http.StatusConflict,
+		Match:  func(err error) bool { return errors.Is(err, common.ErrConcurrency) },
+	},
+	{
+		Type:   "AggregateClosedError",
+		Status: http.StatusConflict,
+		Match:  func(err error) bool { return errors.Is(err, common.ErrAggregateClosed) },
+	},
+	{
+		Type:   "StreamNotFoundError",
+		Status: http.StatusNotFound,
+		Match:  func(err error) bool { return errors.Is(err, common.ErrStreamNotFound) },
+	},
+	{
+		Type:   "RateLimitedError",
+		Status: http.StatusTooManyRequests,
+		Match:  func(err error) bool { return errors.Is(err, common.ErrRateLimited) },
+	},
+	{
+		Type:   "StaleReadError",
+		Status: http.StatusServiceUnavailable,
+		Match:  func(err error) bool { return errors.Is(err, common.ErrStaleRead) },
+	},
+}
+
+// Server routes HTTP requests to registered command and query handlers
+type Server struct {
+	mu            sync.RWMutex
+	commands      map[string]CommandRoute
+	queries       map[string]QueryRoute
+	errorMappings []ErrorMapping
+}
+
+// NewServer creates a server with the default error mappings
+func NewServer() *Server {
+	return &Server{
+		commands:      make(map[string]CommandRoute),
+		queries:       make(map[string]QueryRoute),
+		errorMappings: append([]ErrorMapping(nil), DefaultErrorMappings...),
+	}
+}
+
+// RegisterCommand exposes a command handler at POST /commands/{name}
+func (s *Server) RegisterCommand(route CommandRoute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.commands[route.Name]; exists {
+		panic(fmt.Sprintf("command route %q is already registered", route.Name))
+	}
+	if _, ok := reflect.New(reflect.TypeOf(route.Payload)).Interface().(common.Command); !ok {
+		panic(fmt.Sprintf("command route %q: *%T does not implement common.Command", route.Name, route.Payload))
+	}
+	if err := common.CheckTags(route.Payload); err != nil {
+		panic(fmt.Sprintf("command route %q: %v", route.Name, err))
+	}
+	s.commands[route.Name] = route
+}
+
+// RegisterQuery exposes a query handler at GET /queries/{name}
+func (s *Server) RegisterQuery(route QueryRoute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.queries[route.Name]; exists {
+		panic(fmt.Sprintf("query route %q is already registered", route.Name))
+	}
+	s.queries[route.Name] = route
+}
+
+// RegisterError adds an error mapping; mappings are consulted in registration order
+func (s *Server) RegisterError(mapping ErrorMapping) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.errorMappings = append(s.errorMappings, mapping)
+}
+
+// CommandRoutes returns the registered command routes, sorted by name
+func (s *Server) CommandRoutes() []CommandRoute {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	routes := make([]CommandRoute, 0, len(s.commands))
+	for _, route := range s.commands {
+		routes = append(routes, route)
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Name < routes[j].Name })
+	return routes
+}
+
+// QueryRoutes returns the registered query routes, sorted by name
+func (s *Server) QueryRoutes() []QueryRoute {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	routes := make([]QueryRoute, 0, len(s.queries))
+	for _, route := range s.queries {
+		routes = append(routes, route)
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Name < routes[j].Name })
+	return routes
+}
+
+// ErrorMappings returns the registered error mappings
+func (s *Server) ErrorMappings() []ErrorMapping {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]ErrorMapping(nil), s.errorMappings...)
+}
+
+// ServeHTTP routes requests to command and query handlers
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasPrefix(r.URL.Path, commandsPrefix):
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed", Type: "MethodNotAllowed"})
+			return
+		}
+		s.handleCommand(w, r, strings.TrimPrefix(r.URL.Path, commandsPrefix))
+	case strings.HasPrefix(r.URL.Path, queriesPrefix):
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed", Type: "MethodNotAllowed"})
+			return
+		}
+		s.handleQuery(w, r, strings.TrimPrefix(r.URL.Path, queriesPrefix))
+	default:
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not found", Type: "NotFound"})
+	}
+}
+
+func (s *Server) handleCommand(w http.ResponseWriter, r *http.Request, name string) {
+	s.mu.RLock()
+	route, exists := s.commands[name]
+	s.mu.RUnlock()
+	if !exists {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "unknown command " + name, Type: "NotFound"})
+		return
+	}
+
+	command := reflect.New(reflect.TypeOf(route.Payload)).Interface().(common.Command)
+	if err := json.NewDecoder(r.Body).Decode(command); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error(), Type: "BadRequest"})
+		return
+	}
+
+	ctx := r.Context()
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch != "" && ifMatch != "*" {
+		token, err := parseETag(ifMatch)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error(), Type: "BadRequest"})
+			return
+		}
+		if token.StreamID != command.AggregateID() {
+			message := fmt.Sprintf("If-Match names stream %s, not %s", token.StreamID, command.AggregateID())
+			writeJSON(w, http.StatusPreconditionFailed, ErrorResponse{Error: message, Type: "PreconditionFailed", Code: common.CodeConcurrency})
+			return
+		}
+		ctx = common.WithExpectedVersion(ctx, token.Version)
+	}
+
+	event, err := route.Handler(ctx, command)
+	if err != nil {
+		if ifMatch != "" && errors.Is(err, common.ErrConcurrency) {
+			writeJSON(w, http.StatusPreconditionFailed, ErrorResponse{Error: err.Error(), Type: "PreconditionFailed", Code: common.ErrorCodeOf(err)})
+			return
+		}
+		s.writeError(w, err)
+		return
+	}
+	token := common.TokenFor(event)
+	w.Header().Set(ConsistencyTokenHeader, token.String())
+	w.Header().Set("ETag", formatETag(token))
+	writeJSON(w, http.StatusCreated, event)
+}
+
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request, name string) {
+	s.mu.RLock()
+	route, exists := s.queries[name]
+	s.mu.RUnlock()
+	if !exists {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "unknown query " + name, Type: "NotFound"})
+		return
+	}
+
+	params := reflect.New(reflect.TypeOf(route.Params))
+	if err := decodeParams(r, params.Elem()); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error(), Type: "BadRequest"})
+		return
+	}
+
+	ctx := r.Context()
+	if header := r.Header.Get(ConsistencyTokenHeader); header != "" {
+		token, err := common.ParseConsistencyToken(header)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error(), Type: "BadRequest"})
+			return
+		}
+		ctx = common.WithConsistencyToken(ctx, token)
+	}
+
+	if route.Version != nil {
+		version, err := route.Version(ctx, params.Interface())
+		if err != nil {
+			s.writeError(w, err)
+			return
+		}
+		etag := formatETag(version)
+		w.Header().Set("ETag", etag)
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	result, err := route.Handler(ctx, params.Interface())
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// formatETag returns the strong entity tag of a stream version
+func formatETag(token common.ConsistencyToken) string {
+	return strconv.Quote(token.String())
+}
+
+// parseETag parses an entity tag written by formatETag
+func parseETag(etag string) (common.ConsistencyToken, error) {
+	unquoted, err := strconv.Unquote(strings.TrimSpace(etag))
+	if err != nil {
+		return common.ConsistencyToken{}, fmt.Errorf("invalid entity tag %s", etag)
+	}
+	return common.ParseConsistencyToken(unquoted)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag. Weak tags match
+// their strong form, as the weak comparison of RFC 9110 requires.
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) writeError(w http.ResponseWriter, err error) {
+	for _, mapping := range s.ErrorMappings() {
+		if mapping.Match(err) {
+			response := ErrorResponse{Error: err.Error(), Type: mapping.Type, Code: common.ErrorCodeOf(err)}
+			var validation *common.ValidationError
+			if errors.As(err, &validation) {
+				response.Fields = validation.Fields
+			}
+			var limited *common.RateLimitedError
+			if errors.As(err, &limited) {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(limited.RetryAfter.Seconds()))))
+			}
+			writeJSON(w, mapping.Status, response)
+			return
+		}
+	}
+	writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error(), Type: "InternalError", Code: common.ErrorCodeOf(err)})
+}
+
+// decodeParams copies URL query parameters into the string, integer, and boolean
+// fields of a struct, matching parameter names against the fields' JSON names
+func decodeParams(r *http.Request, value reflect.Value) error {
+	query := r.URL.Query()
+	for _, field := range structFields(value.Type()) {
+		raw := query.Get(field.name)
+		if raw == "" {
+			if field.required {
+				return fmt.Errorf("missing parameter %s", field.name)
+			}
+			continue
+		}
+
+		target := value.FieldByIndex(field.index)
+		switch target.Kind() {
+		case reflect.String:
+			target.SetString(raw)
+		case reflect.Int, reflect.Int64, reflect.Int32:
+			n, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				return fmt.Errorf("parameter %s must be an integer", field.name)
+			}
+			target.SetInt(n)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				return fmt.Errorf("parameter %s must be a boolean", field.name)
+			}
+			target.SetBool(b)
+		default:
+			return fmt.Errorf("parameter %s has unsupported type %s", field.name, target.Type())
+		}
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
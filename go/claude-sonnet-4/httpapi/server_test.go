@@ -0,0 +1,410 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+	"strings"
+	"testing"
+	"time"
+)
+
+type renameCommand struct {
+	ID   string `json:"aggregate_id"`
+	Name string `json:"name"`
+	Note string `json:"note,omitempty"`
+}
+
+func (c *renameCommand) AggregateID() string { return c.ID }
+func (c *renameCommand) CommandType() string { return "Rename" }
+
+type lookupParams struct {
+	ID    string `json:"id"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+type lookupResult struct {
+	ID    string   `json:"id"`
+	Names []string `json:"names"`
+}
+
+func testServer() *Server {
+	server := NewServer()
+	server.RegisterCommand(CommandRoute{
+		Name:    "Rename",
+		Payload: renameCommand{},
+		Handler: func(_ context.Context, command common.Command) (*common.Event, error) {
+			cmd := command.(*renameCommand)
+			if cmd.Name == "" {
+				return nil, &common.InvalidCommandError{Message: "name required"}
+			}
+			return common.NewEvent("Renamed", cmd.ID, 2, map[string]interface{}{"name": cmd.Name}, nil), nil
+		},
+	})
+	server.RegisterQuery(QueryRoute{
+		Name:   "lookup",
+		Params: lookupParams{},
+		Result: lookupResult{},
+		Handler: func(_ context.Context, params interface{}) (interface{}, error) {
+			p := params.(*lookupParams)
+			if p.ID == "missing" {
+				return nil, &common.StreamNotFoundError{StreamID: p.ID}
+			}
+			return lookupResult{ID: p.ID, Names: make([]string, p.Limit)}, nil
+		},
+	})
+	return server
+}
+
+func serve(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServer_Command(t *testing.T) {
+	server := testServer()
+
+	rec := serve(server, http.MethodPost, "/commands/Rename", `{"aggregate_id":"a-1","name":"Groceries"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var event common.Event
+	json.Unmarshal(rec.Body.Bytes(), &event)
+	if event.Type != "Renamed" || event.Data["name"] != "Groceries" {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+	if token := rec.Header().Get(ConsistencyTokenHeader); token != "a-1@2" {
+		t.Errorf("Expected consistency token a-1@2, got %q", token)
+	}
+
+	rec = serve(server, http.MethodPost, "/commands/Rename", `{"aggregate_id":"a-1"}`)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), `"type":"InvalidCommandError","code":"invalid_command"`) {
+		t.Errorf("Expected 422 InvalidCommandError, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = serve(server, http.MethodPost, "/commands/Rename", `not json`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid body, got %d", rec.Code)
+	}
+
+	rec = serve(server, http.MethodPost, "/commands/Unknown", `{}`)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown command, got %d", rec.Code)
+	}
+}
+
+func TestServer_Query(t *testing.T) {
+	server := testServer()
+
+	rec := serve(server, http.MethodGet, "/queries/lookup?id=a-1&limit=2", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var result lookupResult
+	json.Unmarshal(rec.Body.Bytes(), &result)
+	if result.ID != "a-1" || len(result.Names) != 2 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	rec = serve(server, http.MethodGet, "/queries/lookup", "")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for missing required parameter, got %d", rec.Code)
+	}
+
+	rec = serve(server, http.MethodGet, "/queries/lookup?id=missing", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for StreamNotFoundError, got %d", rec.Code)
+	}
+}
+
+func TestServer_QueryConsistencyToken(t *testing.T) {
+	server := NewServer()
+	server.RegisterQuery(QueryRoute{
+		Name:   "token",
+		Params: struct{}{},
+		Result: common.ConsistencyToken{},
+		Handler: func(ctx context.Context, _ interface{}) (interface{}, error) {
+			token, _ := common.ConsistencyTokenFrom(ctx)
+			if token.Version > 2 {
+				return nil, &common.StaleReadError{Token: token, Version: 2}
+			}
+			return token, nil
+		},
+	})
+	query := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/queries/token", nil)
+		req.Header.Set(ConsistencyTokenHeader, token)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := query("a-1@2")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `{"stream_id":"a-1","version":2}`) {
+		t.Errorf("Expected the handler to receive the token, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = query("a-1@3")
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), `"code":"stale_read"`) {
+		t.Errorf("Expected 503 StaleReadError, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = query("a-1")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid token, got %d", rec.Code)
+	}
+}
+
+func TestServer_RateLimited(t *testing.T) {
+	server := NewServer()
+	server.RegisterCommand(CommandRoute{
+		Name:    "Rename",
+		Payload: renameCommand{},
+		Handler: func(_ context.Context, command common.Command) (*common.Event, error) {
+			return nil, &common.RateLimitedError{Scope: "aggregate", Key: command.AggregateID(), CommandType: "Rename", RetryAfter: 1500 * time.Millisecond}
+		},
+	})
+
+	rec := serve(server, http.MethodPost, "/commands/Rename", `{"aggregate_id":"a-1","name":"Groceries"}`)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "2" {
+		t.Errorf("Expected 429 with Retry-After 2, got %d %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
+
+func TestServer_ConditionalRequests(t *testing.T) {
+	version := 2
+	executed := 0
+	server := NewServer()
+	server.RegisterQuery(QueryRoute{
+		Name:   "lookup",
+		Params: lookupParams{},
+		Result: lookupResult{},
+		Handler: func(_ context.Context, params interface{}) (interface{}, error) {
+			executed++
+			return lookupResult{ID: params.(*lookupParams).ID}, nil
+		},
+		Version: func(_ context.Context, params interface{}) (common.ConsistencyToken, error) {
+			return common.ConsistencyToken{StreamID: params.(*lookupParams).ID, Version: version}, nil
+		},
+	})
+	server.RegisterCommand(CommandRoute{
+		Name:    "Rename",
+		Payload: renameCommand{},
+		Handler: func(ctx context.Context, command common.Command) (*common.Event, error) {
+			if expected, ok := common.ExpectedVersionFrom(ctx); ok && expected != version {
+				return nil, &common.ConcurrencyError{StreamID: command.AggregateID(), Expected: expected, Actual: version}
+			}
+			version++
+			return common.NewEvent("Renamed", command.AggregateID(), version, nil, nil), nil
+		},
+	})
+	send := func(method, target, header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(`{"aggregate_id":"a-1","name":"Groceries"}`))
+		req.Header.Set(header, value)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send(http.MethodGet, "/queries/lookup?id=a-1", "If-None-Match", "")
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag != `"a-1@2"` {
+		t.Fatalf("Expected 200 with ETag \"a-1@2\", got %d %q", rec.Code, etag)
+	}
+	rec = send(http.MethodGet, "/queries/lookup?id=a-1", "If-None-Match", `"a-1@1", W/`+etag)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 || executed != 1 {
+		t.Errorf("Expected 304 without executing the query, got %d after %d executions", rec.Code, executed)
+	}
+
+	rec = send(http.MethodPost, "/commands/Rename", "If-Match", etag)
+	if rec.Code != http.StatusCreated || rec.Header().Get("ETag") != `"a-1@3"` {
+		t.Fatalf("Expected 201 with ETag \"a-1@3\", got %d %q", rec.Code, rec.Header().Get("ETag"))
+	}
+	rec = send(http.MethodGet, "/queries/lookup?id=a-1", "If-None-Match", etag)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 once the stream changed, got %d", rec.Code)
+	}
+
+	rec = send(http.MethodPost, "/commands/Rename", "If-Match", etag)
+	if rec.Code != http.StatusPreconditionFailed || !strings.Contains(rec.Body.String(), `"code":"concurrency_conflict"`) {
+		t.Errorf("Expected 412 for a stale If-Match, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = send(http.MethodPost, "/commands/Rename", "If-Match", `"a-2@3"`)
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 for another stream's ETag, got %d", rec.Code)
+	}
+	rec = send(http.MethodPost, "/commands/Rename", "If-Match", "a-1@3")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unquoted ETag, got %d", rec.Code)
+	}
+	rec = send(http.MethodPost, "/commands/Rename", "If-Match", "*")
+	if rec.Code != http.StatusCreated {
+		t.Errorf("Expected If-Match: * to match any version, got %d", rec.Code)
+	}
+}
+
+func TestServer_CustomErrorMapping(t *testing.T) {
+	errConflict := errors.New("conflict")
+	server := NewServer()
+	server.RegisterError(ErrorMapping{
+		Type:   "Conflict",
+		Status: http.StatusConflict,
+		Match:  func(err error) bool { return errors.Is(err, errConflict) },
+	})
+	server.RegisterCommand(CommandRoute{
+		Name:    "Fail",
+		Payload: renameCommand{},
+		Handler: func(context.Context, common.Command) (*common.Event, error) { return nil, errConflict },
+	})
+
+	rec := serve(server, http.MethodPost, "/commands/Fail", `{}`)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409, got %d", rec.Code)
+	}
+}
+
+func TestServer_OpenAPI(t *testing.T) {
+	doc := testServer().OpenAPI(OpenAPIInfo{Title: "Test", Version: "1.0.0"})
+
+	post, ok := doc.Paths["/commands/Rename"]["post"]
+	if !ok {
+		t.Fatalf("Expected POST /commands/Rename, got %v", doc.Paths)
+	}
+	if post.RequestBody.Content["application/json"].Schema.Ref != "#/components/schemas/renameCommand" {
+		t.Errorf("Unexpected request schema: %+v", post.RequestBody.Content["application/json"].Schema)
+	}
+	if _, ok := post.Responses["422"]; !ok {
+		t.Errorf("Expected 422 response documented from error mappings, got %v", post.Responses)
+	}
+
+	command := doc.Components.Schemas["renameCommand"]
+	if len(command.Required) != 2 || command.Properties["note"].Type != "string" {
+		t.Errorf("Unexpected command schema: %+v", command)
+	}
+
+	get := doc.Paths["/queries/lookup"]["get"]
+	if len(get.Parameters) != 3 || !get.Parameters[0].Required || get.Parameters[1].Required || get.Parameters[2].In != "header" {
+		t.Errorf("Unexpected query parameters: %+v", get.Parameters)
+	}
+	if _, ok := post.Responses["201"].Headers[ConsistencyTokenHeader]; !ok {
+		t.Errorf("Expected the consistency token header documented, got %+v", post.Responses["201"])
+	}
+	if doc.Components.Schemas["Event"].Properties["created_at"].Format != "date-time" {
+		t.Errorf("Expected Event.created_at to be a date-time, got %+v", doc.Components.Schemas["Event"])
+	}
+}
+
+func TestServer_RegisterCommandRequiresCommandPayload(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected registering a payload that is not a common.Command to panic")
+		}
+	}()
+	NewServer().RegisterCommand(CommandRoute{Name: "Lookup", Payload: lookupParams{}})
+}
+
+// testJWTSecret is a secret of the minimum length JWT accepts
+const testJWTSecret = "0123456789abcdef0123456789abcdef"
+
+// signJWT returns an HS256 token with the given claims
+func signJWT(secret string, claims map[string]interface{}) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payload, _ := json.Marshal(claims)
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestJWT_Verify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	jwt := &JWT{Secret: []byte(testJWTSecret), Issuer: "sem", Audience: "cart-api", Now: func() time.Time { return now }}
+	valid := map[string]interface{}{"sub": "alice", "iss": "sem", "aud": []string{"cart-api"}, "exp": now.Unix() + 60, "roles": []string{"admin"}}
+
+	principal, err := jwt.Verify(signJWT(testJWTSecret, valid))
+	if err != nil || principal.ID != "alice" || !principal.HasRole("admin") {
+		t.Errorf("Expected alice with the admin role, got %+v, %v", principal, err)
+	}
+
+	claims := func(key string, value interface{}) map[string]interface{} {
+		modified := make(map[string]interface{})
+		for k, v := range valid {
+			modified[k] = v
+		}
+		modified[key] = value
+		return modified
+	}
+	for reason, token := range map[string]string{
+		"bad signature":       signJWT("other", valid),
+		"token expired":       signJWT(testJWTSecret, claims("exp", now.Unix())),
+		"token not yet valid": signJWT(testJWTSecret, claims("nbf", now.Unix()+1)),
+		"unexpected issuer":   signJWT(testJWTSecret, claims("iss", "elsewhere")),
+		"unexpected audience": signJWT(testJWTSecret, claims("aud", "admin-api")),
+		"missing subject":     signJWT(testJWTSecret, claims("sub", "")),
+		"malformed token":     "not-a-token",
+	} {
+		_, err := jwt.Verify(token)
+		var unauthenticated *common.UnauthenticatedError
+		if !errors.As(err, &unauthenticated) || unauthenticated.Reason != reason {
+			t.Errorf("Expected %q, got %v", reason, err)
+		}
+	}
+
+	for _, secret := range []string{"", "s3cret"} {
+		if _, err := NewJWT([]byte(secret)); !errors.Is(err, ErrWeakJWTSecret) {
+			t.Errorf("Expected NewJWT to reject the secret %q, got %v", secret, err)
+		}
+		weak := &JWT{Secret: []byte(secret)}
+		if _, err := weak.Verify(signJWT(secret, valid)); !errors.Is(err, ErrWeakJWTSecret) {
+			t.Errorf("Expected Verify to reject tokens under the secret %q, got %v", secret, err)
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	authenticator := Authenticators{
+		APIKeys{"key-1": bus.Principal{ID: "ci-bot", Roles: []string{"service"}}},
+		&JWT{Secret: []byte(testJWTSecret)},
+	}
+	var principal bus.Principal
+	var authenticated bool
+	whoami := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, authenticated = bus.PrincipalFrom(r.Context())
+	})
+	send := func(required bool, header, value string) int {
+		principal, authenticated = bus.Principal{}, false
+		req := httptest.NewRequest(http.MethodGet, "/queries/lookup", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		Authenticate(authenticator, required, whoami).ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := send(false, APIKeyHeader, "key-1"); code != http.StatusOK || principal.ID != "ci-bot" {
+		t.Errorf("Expected the API key to resolve ci-bot, got %d %+v", code, principal)
+	}
+	if code := send(false, "Authorization", "Bearer "+signJWT(testJWTSecret, map[string]interface{}{"sub": "alice"})); code != http.StatusOK || principal.ID != "alice" {
+		t.Errorf("Expected the JWT to resolve alice, got %d %+v", code, principal)
+	}
+	if code := send(false, "", ""); code != http.StatusOK || authenticated {
+		t.Errorf("Expected an anonymous request to continue without a principal, got %d", code)
+	}
+	if code := send(true, "", ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 when credentials are required, got %d", code)
+	}
+	if code := send(false, APIKeyHeader, "key-2"); code != http.StatusUnauthorized || authenticated {
+		t.Errorf("Expected 401 for an unknown API key, got %d", code)
+	}
+	if code := send(false, "Authorization", "Bearer "+signJWT("other", map[string]interface{}{"sub": "alice"})); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a forged JWT, got %d", code)
+	}
+}
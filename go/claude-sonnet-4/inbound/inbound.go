@@ -0,0 +1,223 @@
+// Package inbound ingests messages from other systems and translates them into this
+// application's language, as an anti-corruption layer: external schemas stop at the
+// mappers registered with a Consumer, which turn each message into domain commands
+// dispatched on the command bus or integration events appended to the store.
+//
+// Messages arrive from a broker (see Consume and ConsumeJetStream) or over HTTP (see
+// WebhookHandler) in the CloudEvents envelope:
+//
+//	{"specversion": "1.0", "id": "...", "source": "catalog", "type": "catalog.price_changed",
+//	 "subject": "sku-42", "sequence": 7, "data": {...}}
+//
+// Brokers and webhooks deliver at least once and may reorder, so the consumer drops
+// messages it has already handled by ID, and, for messages carrying a sequence,
+// messages older than the last one handled for the same subject.
+package inbound
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+)
+
+// DefaultDedupWindow is how many message IDs a Consumer remembers
+const DefaultDedupWindow = 10000
+
+// Message is an external message in the CloudEvents envelope
+type Message struct {
+	ID     string `json:"id"`
+	Source string `json:"source"`
+	Type   string `json:"type"`
+	// Subject is what the message is about, e.g. a product ID; sequences are
+	// compared between messages of the same source and subject
+	Subject string `json:"subject,omitempty"`
+	// Sequence orders the messages of a subject; 0 means unordered
+	Sequence int64           `json:"sequence,omitempty"`
+	Data     json.RawMessage `json:"data,omitempty"`
+}
+
+// DecodeCloudEvent decodes a message in the structured JSON mode of CloudEvents
+func DecodeCloudEvent(data []byte) (Message, error) {
+	var envelope struct {
+		Message
+		SpecVersion string `json:"specversion"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return Message{}, fmt.Errorf("invalid message: %w", err)
+	}
+	msg := envelope.Message
+	if envelope.SpecVersion != "1.0" || msg.ID == "" || msg.Source == "" || msg.Type == "" {
+		return Message{}, errors.New("invalid message: specversion 1.0, id, source, and type are required")
+	}
+	return msg, nil
+}
+
+// Translation is what a mapper makes of a message
+type Translation struct {
+	// Commands are dispatched on the command bus in order
+	Commands []common.Command
+	// Events are appended to the store in order. Events with version 0 are appended
+	// at the end of their stream.
+	Events []*common.Event
+}
+
+// Mapper translates an external message. Messages a mapper cannot make sense of
+// should fail with a *common.ValidationError or *common.InvalidCommandError, which
+// the consumer drops as poison rather than retrying.
+type Mapper func(ctx context.Context, msg Message) (Translation, error)
+
+// Outcome is what a consumer did with a message
+type Outcome string
+
+// Outcomes of Consumer.Handle
+const (
+	// Handled messages were translated and their commands and events accepted
+	Handled Outcome = "handled"
+	// Duplicate messages were handled before
+	Duplicate Outcome = "duplicate"
+	// Stale messages are older than a message handled for the same subject
+	Stale Outcome = "stale"
+	// Ignored messages have a type no mapper is registered for
+	Ignored Outcome = "ignored"
+	// Rejected messages were dropped because their mapper or a command rejected them
+	Rejected Outcome = "rejected"
+)
+
+// Consumer translates external messages through the mappers registered by type.
+// The messages it has seen are remembered in memory, so deduplication spans
+// restarts only as far as the broker's redelivery does not reach back further.
+type Consumer struct {
+	store    common.Store
+	commands *bus.CommandBus
+
+	mu        sync.Mutex
+	mappers   map[string]Mapper
+	seen      map[string]bool
+	seenOrder []string
+	window    int
+	sequences map[string]int64
+}
+
+// NewConsumer creates a consumer appending integration events to store and
+// dispatching commands on commands
+func NewConsumer(store common.Store, commands *bus.CommandBus) *Consumer {
+	return &Consumer{
+		store:     store,
+		commands:  commands,
+		mappers:   make(map[string]Mapper),
+		seen:      make(map[string]bool),
+		window:    DefaultDedupWindow,
+		sequences: make(map[string]int64),
+	}
+}
+
+// Map registers the mapper for messages of a type
+func (c *Consumer) Map(messageType string, mapper Mapper) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mappers[messageType] = mapper
+}
+
+// Handle translates a message and applies its translation. Messages are handled one
+// at a time, so the translations of a subject apply in the order they arrive. An
+// error means the message should be redelivered; poison messages are Rejected
+// without one.
+func (c *Consumer) Handle(ctx context.Context, msg Message) (Outcome, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := msg.Source + "/" + msg.ID
+	subject := msg.Source + "/" + msg.Subject
+	switch {
+	case c.seen[id]:
+		return Duplicate, nil
+	case msg.Sequence > 0 && msg.Subject != "" && msg.Sequence <= c.sequences[subject]:
+		c.remember(id)
+		return Stale, nil
+	}
+
+	outcome, err := c.apply(ctx, msg)
+	if err != nil {
+		return outcome, err
+	}
+	c.remember(id)
+	if msg.Sequence > 0 && msg.Subject != "" {
+		c.sequences[subject] = msg.Sequence
+	}
+	return outcome, nil
+}
+
+func (c *Consumer) apply(ctx context.Context, msg Message) (Outcome, error) {
+	mapper, exists := c.mappers[msg.Type]
+	if !exists {
+		return Ignored, nil
+	}
+	translation, err := mapper(ctx, msg)
+	if poison(err) {
+		return Rejected, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s %s: %w", msg.Type, msg.ID, err)
+	}
+
+	for _, event := range translation.Events {
+		if event.Version == 0 {
+			event.Version = c.store.GetStreamVersion(event.AggregateID) + 1
+		}
+		if err := c.store.Append(event); err != nil {
+			return "", fmt.Errorf("%s %s: %w", msg.Type, msg.ID, err)
+		}
+	}
+	for _, command := range translation.Commands {
+		_, err := c.commands.Dispatch(ctx, command)
+		if poison(err) {
+			return Rejected, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("%s %s: %w", msg.Type, msg.ID, err)
+		}
+	}
+	return Handled, nil
+}
+
+// poison reports whether err says the message itself is wrong, so redelivering it
+// cannot help
+func poison(err error) bool {
+	var validation *common.ValidationError
+	var invalid *common.InvalidCommandError
+	return errors.As(err, &validation) || errors.As(err, &invalid)
+}
+
+// remember records a handled message ID, forgetting the oldest beyond the window
+func (c *Consumer) remember(id string) {
+	c.seen[id] = true
+	c.seenOrder = append(c.seenOrder, id)
+	if len(c.seenOrder) > c.window {
+		delete(c.seen, c.seenOrder[0])
+		c.seenOrder = c.seenOrder[1:]
+	}
+}
+
+// Consume handles the messages received on messages until the channel is closed or
+// ctx is cancelled. It stops at the first message that fails, returning its error,
+// so a broker that has not been acknowledged redelivers it.
+func (c *Consumer) Consume(ctx context.Context, messages <-chan Message) error {
+	for {
+		select {
+		case msg, open := <-messages:
+			if !open {
+				return nil
+			}
+			if _, err := c.Handle(ctx, msg); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
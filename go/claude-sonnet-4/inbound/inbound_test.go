@@ -0,0 +1,178 @@
+package inbound
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+	"simple-event-modeling/jsstore"
+)
+
+type restockCommand struct {
+	SKU string
+}
+
+func (c *restockCommand) AggregateID() string { return c.SKU }
+func (c *restockCommand) CommandType() string { return "Restock" }
+
+// newConsumer maps "stock.received" to a Restock command and "price.changed" to a
+// PriceRecorded event, recording the restocked SKUs
+func newConsumer(t *testing.T) (*Consumer, common.Store, *[]string) {
+	t.Helper()
+	store := common.NewEventStore()
+	var restocked []string
+	commands := bus.NewCommandBus()
+	commands.Register("Restock", func(_ context.Context, command common.Command) (*common.Event, error) {
+		if command.AggregateID() == "discontinued" {
+			return nil, &common.InvalidCommandError{Message: "discontinued"}
+		}
+		restocked = append(restocked, command.AggregateID())
+		return nil, nil
+	})
+
+	consumer := NewConsumer(store, commands)
+	consumer.Map("stock.received", func(_ context.Context, msg Message) (Translation, error) {
+		return Translation{Commands: []common.Command{&restockCommand{SKU: msg.Subject}}}, nil
+	})
+	consumer.Map("price.changed", func(_ context.Context, msg Message) (Translation, error) {
+		var data map[string]interface{}
+		if err := json.Unmarshal(msg.Data, &data); err != nil {
+			return Translation{}, err
+		}
+		return Translation{Events: []*common.Event{common.NewEvent("PriceRecorded", "prices", 0, data, nil)}}, nil
+	})
+	return consumer, store, &restocked
+}
+
+func TestConsumer_Handle(t *testing.T) {
+	consumer, store, restocked := newConsumer(t)
+	handle := func(msg Message) Outcome {
+		t.Helper()
+		msg.Source = "warehouse"
+		outcome, err := consumer.Handle(context.Background(), msg)
+		if err != nil {
+			t.Fatalf("Error handling %s: %v", msg.ID, err)
+		}
+		return outcome
+	}
+
+	for _, step := range []struct {
+		msg  Message
+		want Outcome
+	}{
+		{Message{ID: "1", Type: "stock.received", Subject: "sku-1", Sequence: 2}, Handled},
+		{Message{ID: "1", Type: "stock.received", Subject: "sku-1", Sequence: 2}, Duplicate},
+		{Message{ID: "2", Type: "stock.received", Subject: "sku-1", Sequence: 1}, Stale},
+		{Message{ID: "3", Type: "stock.received", Subject: "sku-2", Sequence: 1}, Handled},
+		{Message{ID: "4", Type: "stock.received", Subject: "discontinued"}, Rejected},
+		{Message{ID: "5", Type: "stock.counted", Subject: "sku-1"}, Ignored},
+		{Message{ID: "6", Type: "price.changed", Data: json.RawMessage(`{"sku":"sku-1"}`)}, Handled},
+		{Message{ID: "7", Type: "price.changed", Data: json.RawMessage(`{"sku":"sku-2"}`)}, Handled},
+	} {
+		if got := handle(step.msg); got != step.want {
+			t.Errorf("Message %s: expected %s, got %s", step.msg.ID, step.want, got)
+		}
+	}
+
+	if len(*restocked) != 2 || (*restocked)[1] != "sku-2" {
+		t.Errorf("Unexpected restocks %v", *restocked)
+	}
+	if events, _ := store.GetStream("prices"); len(events) != 2 || events[1].Version != 2 {
+		t.Errorf("Expected two integration events appended in order, got %v", events)
+	}
+
+	// A message that fails is not remembered, so its redelivery is handled
+	if _, err := consumer.Handle(context.Background(), Message{ID: "8", Source: "warehouse", Type: "price.changed", Data: json.RawMessage(`[`)}); err == nil {
+		t.Fatal("Expected a mapper error")
+	}
+	if got := handle(Message{ID: "8", Type: "price.changed", Data: json.RawMessage(`{}`)}); got != Handled {
+		t.Errorf("Expected the redelivered message to be handled, got %s", got)
+	}
+}
+
+func TestConsumer_WebhookHandler(t *testing.T) {
+	consumer, _, restocked := newConsumer(t)
+	handler := consumer.WebhookHandler()
+	post := func(body string) (int, WebhookResponse) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(body)))
+		var response WebhookResponse
+		json.NewDecoder(rec.Body).Decode(&response)
+		return rec.Code, response
+	}
+
+	body := `{"specversion":"1.0","id":"1","source":"warehouse","type":"stock.received","subject":"sku-1"}`
+	if status, response := post(body); status != http.StatusAccepted || response.Outcome != Handled {
+		t.Errorf("Expected 202 handled, got %d %+v", status, response)
+	}
+	if status, response := post(body); status != http.StatusAccepted || response.Outcome != Duplicate {
+		t.Errorf("Expected 202 duplicate, got %d %+v", status, response)
+	}
+	if status, _ := post(`{"id":"2","type":"stock.received"}`); status != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a message that is not a CloudEvent, got %d", status)
+	}
+	if len(*restocked) != 1 {
+		t.Errorf("Expected one restock, got %v", *restocked)
+	}
+}
+
+// fakeJetStream delivers fixed messages to any consumer, recording acknowledgements
+type fakeJetStream struct {
+	jsstore.Client
+	data  []string
+	acked []uint64
+}
+
+func (f *fakeJetStream) Consume(ctx context.Context, _, _ string) (<-chan jsstore.Msg, error) {
+	out := make(chan jsstore.Msg, len(f.data))
+	for i, data := range f.data {
+		sequence := uint64(i + 1)
+		out <- jsstore.Msg{Sequence: sequence, Data: []byte(data), Ack: func() error {
+			f.acked = append(f.acked, sequence)
+			return nil
+		}}
+	}
+	close(out)
+	return out, nil
+}
+
+func TestConsumer_ConsumeJetStream(t *testing.T) {
+	consumer, store, restocked := newConsumer(t)
+	client := &fakeJetStream{data: []string{
+		`{"specversion":"1.0","id":"1","source":"warehouse","type":"stock.received","subject":"sku-1"}`,
+		`not a cloud event`,
+		`{"specversion":"1.0","id":"2","source":"catalog","type":"price.changed","data":{"sku":"sku-1"}}`,
+	}}
+	if err := consumer.ConsumeJetStream(context.Background(), client, "inbound", "external.>"); err != nil {
+		t.Fatalf("Error consuming: %v", err)
+	}
+	if len(client.acked) != 3 || len(*restocked) != 1 || store.GetStreamVersion("prices") != 1 {
+		t.Errorf("Expected every message acknowledged and two handled, got acks %v", client.acked)
+	}
+
+	failing := &fakeJetStream{data: []string{`{"specversion":"1.0","id":"3","source":"catalog","type":"price.changed","data":"sku-1"}`}}
+	if err := consumer.ConsumeJetStream(context.Background(), failing, "inbound", "external.>"); err == nil {
+		t.Error("Expected the mapper error to be returned")
+	}
+	if len(failing.acked) != 0 {
+		t.Errorf("Expected the failed message to stay unacknowledged, got %v", failing.acked)
+	}
+}
+
+func TestDecodeCloudEvent(t *testing.T) {
+	msg, err := DecodeCloudEvent([]byte(`{"specversion":"1.0","id":"1","source":"catalog","type":"price.changed","sequence":4,"data":{"sku":"a"}}`))
+	if err != nil || msg.Sequence != 4 || string(msg.Data) != `{"sku":"a"}` {
+		t.Errorf("Unexpected message %+v (%v)", msg, err)
+	}
+	if _, err := DecodeCloudEvent([]byte(`{"specversion":"0.3","id":"1","source":"catalog","type":"x"}`)); err == nil {
+		t.Error("Expected other spec versions to be rejected")
+	}
+	if _, err := DecodeCloudEvent([]byte(`{`)); err == nil {
+		t.Error("Expected invalid JSON to be rejected")
+	}
+}
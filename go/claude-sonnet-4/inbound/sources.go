@@ -0,0 +1,85 @@
+package inbound
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"simple-event-modeling/jsstore"
+)
+
+// maxWebhookBody caps the size of a webhook request
+const maxWebhookBody = 1 << 20
+
+// ConsumeJetStream handles the CloudEvents delivered to a durable JetStream consumer
+// until ctx is done, acknowledging each message once handled. Messages that are not
+// CloudEvents are acknowledged and dropped; a message that fails is left
+// unacknowledged for redelivery and its error returned.
+func (c *Consumer) ConsumeJetStream(ctx context.Context, client jsstore.Client, durable, filter string) error {
+	msgs, err := client.Consume(ctx, durable, filter)
+	if err != nil {
+		return err
+	}
+	for raw := range msgs {
+		msg, err := DecodeCloudEvent(raw.Data)
+		if err == nil {
+			if _, err := c.Handle(ctx, msg); err != nil {
+				return err
+			}
+		}
+		if raw.Ack != nil {
+			if err := raw.Ack(); err != nil {
+				return err
+			}
+		}
+	}
+	return ctx.Err()
+}
+
+// WebhookResponse is the body of a webhook response
+type WebhookResponse struct {
+	Outcome Outcome `json:"outcome,omitempty"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// WebhookHandler accepts CloudEvents POSTed in structured JSON mode. Handled,
+// duplicate, stale, ignored, and rejected messages are answered with 202 Accepted
+// and the outcome, so senders do not retry them; failures with 500 so they do.
+func (c *Consumer) WebhookHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			writeJSON(w, http.StatusMethodNotAllowed, WebhookResponse{Error: "method not allowed"})
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeJSON(w, http.StatusRequestEntityTooLarge, WebhookResponse{Error: err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusBadRequest, WebhookResponse{Error: err.Error()})
+			return
+		}
+		msg, err := DecodeCloudEvent(body)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, WebhookResponse{Error: err.Error()})
+			return
+		}
+		outcome, err := c.Handle(r.Context(), msg)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, WebhookResponse{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusAccepted, WebhookResponse{Outcome: outcome})
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
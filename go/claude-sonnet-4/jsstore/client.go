@@ -0,0 +1,43 @@
+package jsstore
+
+import (
+	"context"
+	"errors"
+)
+
+// Client is the subset of the NATS JetStream API the store uses. Its methods mirror
+// publishing with the Nats-Msg-Id and Nats-Expected-Last-Subject-Sequence headers,
+// fetching with a subject filter, getting a subject's last message, and consuming through a durable consumer, with
+// the types flattened so this module does not depend on the nats.go library.
+type Client interface {
+	// Publish stores data on subject and returns its stream sequence. msgID lets the
+	// server drop duplicates. When expectLastSeq is non-nil the server rejects the
+	// message with ErrWrongLastSequence unless the last message on subject has that
+	// sequence; 0 expects no message on the subject.
+	Publish(ctx context.Context, subject string, data []byte, msgID string, expectLastSeq *uint64) (uint64, error)
+	// Fetch returns the stored messages matching a subject filter in sequence order
+	Fetch(ctx context.Context, filter string) ([]Msg, error)
+	// LastMsg returns the last message stored on subject, or ErrNoMessage when
+	// there is none
+	LastMsg(ctx context.Context, subject string) (Msg, error)
+	// Consume delivers the messages matching filter to the durable consumer named
+	// durable, resuming after the last message it acknowledged, until ctx is done
+	Consume(ctx context.Context, durable, filter string) (<-chan Msg, error)
+}
+
+// Msg is a message stored in a JetStream stream
+type Msg struct {
+	Subject  string
+	Sequence uint64
+	Data     []byte
+	// Ack acknowledges a message delivered to a durable consumer; it is nil for
+	// fetched messages
+	Ack func() error
+}
+
+// ErrWrongLastSequence is returned by Client.Publish when the subject's last
+// sequence does not match the expected one
+var ErrWrongLastSequence = errors.New("wrong last sequence")
+
+// ErrNoMessage is returned by Client.LastMsg when no message is stored on the subject
+var ErrNoMessage = errors.New("no message found")
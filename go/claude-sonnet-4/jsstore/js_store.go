@@ -0,0 +1,235 @@
+// Package jsstore provides a Store backend on NATS JetStream. Each aggregate stream
+// is a subject, <prefix>.<aggregate ID>, of one JetStream stream capturing
+// <prefix>.>, so replaying an aggregate fetches one subject and GetAllEvents
+// fetches them all in stream sequence order. Appends are published with the
+// subject's expected last sequence, which lets the server reject concurrent writers.
+//
+// Because every appended event is published to the broker, durable consumers double
+// as subscriptions for other services, and Publisher relays events recorded in
+// another store onto the same subjects.
+//
+// The store talks to the server through the Client interface, a flattened subset of
+// the JetStream API. This module does not depend on nats.go; a small wrapper around
+// a jetstream.JetStream implementing Client connects the two.
+//
+// Message bodies are the event as JSON unless the store is created WithSerializer;
+// every body names its format, so subscribers and the store itself can read
+// messages written with different serializers side by side.
+package jsstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"simple-event-modeling/common"
+	"simple-event-modeling/serialization"
+)
+
+// DefaultPrefix is the subject prefix events are published under
+const DefaultPrefix = "events"
+
+// DefaultTimeout bounds each call to the server
+const DefaultTimeout = 10 * time.Second
+
+// JetStreamStore is a Store backed by a JetStream stream
+type JetStreamStore struct {
+	client Client
+	prefix string
+	codec  serialization.Codec
+}
+
+var _ common.Store = (*JetStreamStore)(nil)
+
+// Option configures a JetStreamStore or a Publisher
+type Option func(*serialization.Codec)
+
+// WithSerializer encodes events with serializer instead of JSON. Messages already
+// published are read whatever their format, as long as the serialization registry
+// knows it.
+func WithSerializer(serializer serialization.Serializer) Option {
+	return func(c *serialization.Codec) {
+		*c = serialization.NewCodec(serializer, nil)
+	}
+}
+
+// New creates a store publishing to subjects under prefix, or DefaultPrefix when empty
+func New(client Client, prefix string, opts ...Option) *JetStreamStore {
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	s := &JetStreamStore{client: client, prefix: prefix}
+	for _, opt := range opts {
+		opt(&s.codec)
+	}
+	return s
+}
+
+// Subject returns the subject an aggregate stream is published to
+func (s *JetStreamStore) Subject(aggregateID string) string {
+	return s.prefix + "." + aggregateID
+}
+
+// Append publishes an event to its aggregate's subject. The event must directly
+// follow the stream's current version, otherwise a *common.ConcurrencyError is returned.
+func (s *JetStreamStore) Append(event *common.Event) error {
+	if err := checkSubjectToken(event.AggregateID); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	current, lastSeq := 0, uint64(0)
+	msg, err := s.client.LastMsg(ctx, s.Subject(event.AggregateID))
+	switch {
+	case errors.Is(err, ErrNoMessage):
+	case err != nil:
+		return err
+	default:
+		last, err := s.decode(msg)
+		if err != nil {
+			return err
+		}
+		current, lastSeq = last.Version, msg.Sequence
+	}
+	if err := common.CheckVersion(event, current); err != nil {
+		return err
+	}
+
+	data, err := s.codec.Encode(event)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.Publish(ctx, s.Subject(event.AggregateID), data, event.ID, &lastSeq)
+	if errors.Is(err, ErrWrongLastSequence) {
+		// Another writer published between the fetch and the publish
+		return &common.ConcurrencyError{StreamID: event.AggregateID, Expected: event.Version - 1, Actual: s.GetStreamVersion(event.AggregateID)}
+	}
+	return err
+}
+
+// GetStream retrieves all events for a given aggregate ID
+func (s *JetStreamStore) GetStream(aggregateID string) ([]*common.Event, error) {
+	events, err := s.fetch(s.Subject(aggregateID))
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, &common.StreamNotFoundError{StreamID: aggregateID}
+	}
+	return events, nil
+}
+
+// GetStreamVersion returns the current version of a stream
+func (s *JetStreamStore) GetStreamVersion(aggregateID string) int {
+	head, err := s.HeadEvent(aggregateID)
+	if err != nil {
+		return 0
+	}
+	return head.Version
+}
+
+// StreamLength returns the number of events in a stream. Subjects are never
+// truncated, so it equals the stream's version.
+func (s *JetStreamStore) StreamLength(aggregateID string) int {
+	return s.GetStreamVersion(aggregateID)
+}
+
+// HeadEvent returns the last event of a stream, fetching only the subject's last message
+func (s *JetStreamStore) HeadEvent(aggregateID string) (*common.Event, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	msg, err := s.client.LastMsg(ctx, s.Subject(aggregateID))
+	if errors.Is(err, ErrNoMessage) {
+		return nil, &common.StreamNotFoundError{StreamID: aggregateID}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.decode(msg)
+}
+
+// GetAllEvents returns every event in stream sequence order
+func (s *JetStreamStore) GetAllEvents() []*common.Event {
+	events, _ := s.fetch(s.prefix + ".>")
+	return events
+}
+
+// StreamIDs returns the identifiers of all streams in the store, sorted
+func (s *JetStreamStore) StreamIDs() []string {
+	seen := make(map[string]bool)
+	for _, event := range s.GetAllEvents() {
+		seen[event.AggregateID] = true
+	}
+	ids := make([]string, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Subscribe feeds every event to handle through the durable consumer named durable,
+// acknowledging each event once handle returns nil. Events already acknowledged
+// by the consumer are not delivered again, so a restarted subscriber resumes where
+// it stopped. It blocks until ctx is done or handle fails, returning handle's error.
+func (s *JetStreamStore) Subscribe(ctx context.Context, durable string, handle func(*common.Event) error) error {
+	msgs, err := s.client.Consume(ctx, durable, s.prefix+".>")
+	if err != nil {
+		return err
+	}
+	for msg := range msgs {
+		event, err := s.decode(msg)
+		if err != nil {
+			return err
+		}
+		if err := handle(event); err != nil {
+			return err
+		}
+		if msg.Ack != nil {
+			if err := msg.Ack(); err != nil {
+				return err
+			}
+		}
+	}
+	return ctx.Err()
+}
+
+func (s *JetStreamStore) fetch(filter string) ([]*common.Event, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	msgs, err := s.client.Fetch(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]*common.Event, 0, len(msgs))
+	for _, msg := range msgs {
+		event, err := s.decode(msg)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func (s *JetStreamStore) decode(msg Msg) (*common.Event, error) {
+	event, err := s.codec.Decode(msg.Data)
+	if err != nil {
+		return nil, fmt.Errorf("decoding message %d on %s: %w", msg.Sequence, msg.Subject, err)
+	}
+	return event, nil
+}
+
+// checkSubjectToken rejects aggregate IDs that cannot be a single subject token
+func checkSubjectToken(aggregateID string) error {
+	if aggregateID == "" || strings.ContainsAny(aggregateID, ".*> \t\r\n") {
+		return fmt.Errorf("aggregate ID %q cannot be used as a subject token", aggregateID)
+	}
+	return nil
+}
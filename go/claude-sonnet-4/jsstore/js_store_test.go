@@ -0,0 +1,160 @@
+package jsstore
+
+import (
+	"context"
+	"errors"
+	"simple-event-modeling/common"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeClient keeps a single stream in memory with JetStream's publish semantics
+type fakeClient struct {
+	mu     sync.Mutex
+	msgs   []Msg
+	msgIDs map[string]bool
+	acked  map[string]uint64
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{msgIDs: make(map[string]bool), acked: make(map[string]uint64)}
+}
+
+func (c *fakeClient) Publish(_ context.Context, subject string, data []byte, msgID string, expectLastSeq *uint64) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.msgIDs[msgID] {
+		return 0, nil
+	}
+	if expectLastSeq != nil {
+		last := uint64(0)
+		for _, msg := range c.msgs {
+			if msg.Subject == subject {
+				last = msg.Sequence
+			}
+		}
+		if last != *expectLastSeq {
+			return 0, ErrWrongLastSequence
+		}
+	}
+	seq := uint64(len(c.msgs) + 1)
+	c.msgs = append(c.msgs, Msg{Subject: subject, Sequence: seq, Data: data})
+	c.msgIDs[msgID] = true
+	return seq, nil
+}
+
+func (c *fakeClient) Fetch(_ context.Context, filter string) ([]Msg, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var out []Msg
+	for _, msg := range c.msgs {
+		if msg.Subject == filter || (strings.HasSuffix(filter, ".>") && strings.HasPrefix(msg.Subject, strings.TrimSuffix(filter, ">"))) {
+			out = append(out, msg)
+		}
+	}
+	return out, nil
+}
+
+func (c *fakeClient) LastMsg(_ context.Context, subject string) (Msg, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := len(c.msgs) - 1; i >= 0; i-- {
+		if c.msgs[i].Subject == subject {
+			return c.msgs[i], nil
+		}
+	}
+	return Msg{}, ErrNoMessage
+}
+
+func (c *fakeClient) Consume(ctx context.Context, durable, filter string) (<-chan Msg, error) {
+	msgs, _ := c.Fetch(ctx, filter)
+	out := make(chan Msg, len(msgs))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, msg := range msgs {
+		if msg.Sequence <= c.acked[durable] {
+			continue
+		}
+		seq := msg.Sequence
+		msg.Ack = func() error {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.acked[durable] = seq
+			return nil
+		}
+		out <- msg
+	}
+	close(out)
+	return out, nil
+}
+
+func TestJetStreamStore_AppendAndRead(t *testing.T) {
+	store := New(newFakeClient(), "")
+	store.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+	store.Append(common.NewEvent("CartCreated", "cart-2", 1, nil, nil))
+	store.Append(common.NewEvent("ItemAdded", "cart-1", 2, map[string]interface{}{"item": "apple"}, nil))
+
+	events, err := store.GetStream("cart-1")
+	if err != nil {
+		t.Fatalf("Error getting stream: %v", err)
+	}
+	if len(events) != 2 || events[1].Data["item"] != "apple" {
+		t.Errorf("Expected item data to round-trip, got %v", events)
+	}
+	if all := store.GetAllEvents(); len(all) != 3 || all[1].AggregateID != "cart-2" {
+		t.Errorf("Expected events in sequence order, got %v", all)
+	}
+	if ids := store.StreamIDs(); len(ids) != 2 {
+		t.Errorf("Unexpected stream IDs: %v", ids)
+	}
+
+	var notFound *common.StreamNotFoundError
+	if _, err := store.GetStream("missing"); !errors.As(err, &notFound) {
+		t.Errorf("Expected StreamNotFoundError, got %v", err)
+	}
+	if err := store.Append(common.NewEvent("CartCreated", "cart.3", 1, nil, nil)); err == nil {
+		t.Error("Expected an aggregate ID containing a dot to be rejected")
+	}
+}
+
+func TestJetStreamStore_RejectsConcurrentWriters(t *testing.T) {
+	client := newFakeClient()
+	store := New(client, "")
+	store.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+
+	var conflict *common.ConcurrencyError
+	if err := store.Append(common.NewEvent("ItemAdded", "cart-1", 1, nil, nil)); !errors.As(err, &conflict) {
+		t.Errorf("Expected ConcurrencyError for a stale version, got %v", err)
+	}
+
+	// A writer that checked the version before another one published is rejected by the server
+	last := uint64(0)
+	if _, err := client.Publish(context.Background(), store.Subject("cart-1"), []byte("{}"), "other", &last); !errors.Is(err, ErrWrongLastSequence) {
+		t.Errorf("Expected ErrWrongLastSequence, got %v", err)
+	}
+}
+
+func TestJetStreamStore_SubscribeResumesDurableConsumer(t *testing.T) {
+	client := newFakeClient()
+	store := New(client, "")
+	store.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+	store.Append(common.NewEvent("ItemAdded", "cart-1", 2, nil, nil))
+
+	var seen []string
+	collect := func(event *common.Event) error {
+		seen = append(seen, event.Type)
+		return nil
+	}
+	store.Subscribe(context.Background(), "projector", collect)
+
+	NewPublisher(client, "").Publish(context.Background(), common.NewEvent("CartCreated", "cart-2", 1, nil, nil))
+	store.Subscribe(context.Background(), "projector", collect)
+
+	if len(seen) != 3 || seen[2] != "CartCreated" {
+		t.Errorf("Expected the restarted consumer to resume after acknowledged events, got %v", seen)
+	}
+}
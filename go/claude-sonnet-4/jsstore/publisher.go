@@ -0,0 +1,42 @@
+package jsstore
+
+import (
+	"context"
+
+	"simple-event-modeling/common"
+	"simple-event-modeling/serialization"
+)
+
+// Publisher publishes events recorded in another store to the same subjects the
+// JetStreamStore uses, e.g. as the outbox.Publisher of a relay. Each event is
+// published with its ID as message ID, so the server drops events relayed twice.
+type Publisher struct {
+	client Client
+	prefix string
+	codec  serialization.Codec
+}
+
+// NewPublisher creates a publisher for subjects under prefix, or DefaultPrefix when empty
+func NewPublisher(client Client, prefix string, opts ...Option) *Publisher {
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	p := &Publisher{client: client, prefix: prefix}
+	for _, opt := range opts {
+		opt(&p.codec)
+	}
+	return p
+}
+
+// Publish sends an event to the subject of its aggregate
+func (p *Publisher) Publish(ctx context.Context, event *common.Event) error {
+	if err := checkSubjectToken(event.AggregateID); err != nil {
+		return err
+	}
+	data, err := p.codec.Encode(event)
+	if err != nil {
+		return err
+	}
+	_, err = p.client.Publish(ctx, p.prefix+"."+event.AggregateID, data, event.ID, nil)
+	return err
+}
@@ -0,0 +1,257 @@
+// Package kafkasink publishes events to Apache Kafka, as the broker behind an
+// outbox.Relay, with exactly-once-style delivery to consumers:
+//
+//   - Producers are configured as idempotent (see ProducerConfig), so the broker
+//     drops the duplicates its own retries would otherwise write.
+//   - Each message is keyed by the event's aggregate ID and sent to the partition
+//     Kafka's default partitioner picks for that key, so the events of one cart
+//     stay in order downstream.
+//   - Each message carries its stream version in a deduplication key header, so
+//     consumers drop the events a restarted relay publishes again (see
+//     Deduplicator).
+//
+// Message values are the event as JSON unless the sink is created WithSerializer,
+// e.g. with a serialization.Avro serializer writing the Confluent wire format for
+// consumers governed by a schema registry. The content-type header names the format.
+//
+// The sink talks to Kafka through the Producer interface, so this module does not
+// depend on a client library; adapt the writer of kafka-go, sarama, or
+// confluent-kafka-go to it.
+package kafkasink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"simple-event-modeling/common"
+	"simple-event-modeling/outbox"
+	"simple-event-modeling/serialization"
+)
+
+// Headers set on every message
+const (
+	HeaderEventID       = "sem-event-id"
+	HeaderEventType     = "sem-event-type"
+	HeaderStreamVersion = "sem-stream-version"
+	// HeaderDedupKey holds the DedupKey of the event
+	HeaderDedupKey = "sem-dedup-key"
+	// HeaderContentType holds the content type of the message value
+	HeaderContentType = "content-type"
+)
+
+// Header is a Kafka record header
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// Message is a Kafka record to produce
+type Message struct {
+	Topic string
+	// Partition is the partition to write to, or -1 to leave the choice to the
+	// producer's partitioner
+	Partition int32
+	Key       []byte
+	Value     []byte
+	Headers   []Header
+}
+
+// Producer is the subset of a Kafka client the sink uses. Produce writes messages
+// and returns once the broker acknowledged all of them, as kafka-go's
+// Writer.WriteMessages and sarama's SyncProducer.SendMessages do. The producer must
+// be configured with ProducerConfig.
+type Producer interface {
+	Produce(ctx context.Context, messages []Message) error
+}
+
+// ProducerConfig returns the settings, in the names of the Java client and
+// librdkafka, that make a producer idempotent: every partition's writes are
+// acknowledged by all in-sync replicas, and the broker drops retried batches it
+// already stored while keeping them in order.
+func ProducerConfig() map[string]string {
+	return map[string]string{
+		"enable.idempotence":                    "true",
+		"acks":                                  "all",
+		"max.in.flight.requests.per.connection": "5",
+		"retries":                               "2147483647",
+	}
+}
+
+// DedupKey identifies an event by its stream and version, e.g. "cart-1:3"
+func DedupKey(event *common.Event) string {
+	return event.AggregateID + ":" + strconv.Itoa(event.Version)
+}
+
+// ParseDedupKey splits a deduplication key into its stream ID and version
+func ParseDedupKey(key string) (string, int, error) {
+	i := strings.LastIndex(key, ":")
+	if i < 0 {
+		return "", 0, fmt.Errorf("invalid dedup key %q", key)
+	}
+	version, err := strconv.Atoi(key[i+1:])
+	if err != nil || version < 1 {
+		return "", 0, fmt.Errorf("invalid dedup key %q", key)
+	}
+	return key[:i], version, nil
+}
+
+// Sink publishes events to a topic. It implements outbox.Publisher and
+// outbox.BatchPublisher.
+type Sink struct {
+	producer Producer
+	topic    string
+	// partitions of the topic; 0 leaves partitioning to the producer
+	partitions int
+	serializer serialization.Serializer
+}
+
+var (
+	_ outbox.Publisher      = (*Sink)(nil)
+	_ outbox.BatchPublisher = (*Sink)(nil)
+)
+
+// NewSink creates a sink writing to topic. With partitions set to the topic's
+// partition count the sink picks each message's partition itself, as Kafka's
+// default partitioner would; with 0 the producer must partition by key.
+func NewSink(producer Producer, topic string, partitions int, opts ...Option) *Sink {
+	s := &Sink{producer: producer, topic: topic, partitions: partitions, serializer: serialization.JSON{}}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Option configures a Sink
+type Option func(*Sink)
+
+// WithSerializer encodes message values with serializer instead of JSON
+func WithSerializer(serializer serialization.Serializer) Option {
+	return func(s *Sink) {
+		s.serializer = serializer
+	}
+}
+
+// Publish sends an event
+func (s *Sink) Publish(ctx context.Context, event *common.Event) error {
+	return s.PublishBatch(ctx, []*common.Event{event})
+}
+
+// PublishBatch sends events in order in one request
+func (s *Sink) PublishBatch(ctx context.Context, events []*common.Event) error {
+	messages := make([]Message, len(events))
+	for i, event := range events {
+		message, err := s.Message(event)
+		if err != nil {
+			return err
+		}
+		messages[i] = message
+	}
+	return s.producer.Produce(ctx, messages)
+}
+
+// Message encodes an event as a Kafka record keyed by its aggregate ID
+func (s *Sink) Message(event *common.Event) (Message, error) {
+	if event.AggregateID == "" {
+		return Message{}, errors.New("kafkasink: event has no aggregate ID to key by")
+	}
+	value, err := s.serializer.Marshal(event)
+	if err != nil {
+		return Message{}, err
+	}
+	key := []byte(event.AggregateID)
+	partition := int32(-1)
+	if s.partitions > 0 {
+		partition = Partition(key, s.partitions)
+	}
+	return Message{
+		Topic:     s.topic,
+		Partition: partition,
+		Key:       key,
+		Value:     value,
+		Headers: []Header{
+			{Key: HeaderEventID, Value: []byte(event.ID)},
+			{Key: HeaderEventType, Value: []byte(event.Type)},
+			{Key: HeaderStreamVersion, Value: []byte(strconv.Itoa(event.Version))},
+			{Key: HeaderDedupKey, Value: []byte(DedupKey(event))},
+			{Key: HeaderContentType, Value: []byte(s.serializer.ContentType())},
+		},
+	}, nil
+}
+
+// Partition returns the partition Kafka's default partitioner assigns key to: the
+// murmur2 hash of the key, made positive, modulo the partition count
+func Partition(key []byte, partitions int) int32 {
+	return int32(int(murmur2(key)&0x7fffffff) % partitions)
+}
+
+// murmur2 is the 32-bit MurmurHash2 variant used by the Kafka clients
+func murmur2(data []byte) int32 {
+	const (
+		seed = 0x9747b28c
+		m    = 0x5bd1e995
+		r    = 24
+	)
+	length := len(data)
+	h := uint32(seed) ^ uint32(length)
+
+	for i := 0; i+4 <= length; i += 4 {
+		k := uint32(data[i]) | uint32(data[i+1])<<8 | uint32(data[i+2])<<16 | uint32(data[i+3])<<24
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+
+	tail := length &^ 3
+	switch length % 4 {
+	case 3:
+		h ^= uint32(data[tail+2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(data[tail+1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(data[tail])
+		h *= m
+	}
+
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return int32(h)
+}
+
+// Deduplicator drops redelivered events on the consumer side by remembering the
+// highest version seen per stream. Events of a stream arrive in version order from
+// one partition, so anything at or below that version was already handled.
+type Deduplicator struct {
+	mu   sync.Mutex
+	seen map[string]int
+}
+
+// NewDeduplicator creates a deduplicator that has seen nothing
+func NewDeduplicator() *Deduplicator {
+	return &Deduplicator{seen: make(map[string]int)}
+}
+
+// Accept reports whether the message with the given deduplication key is new, and
+// records it if so
+func (d *Deduplicator) Accept(key string) (bool, error) {
+	streamID, version, err := ParseDedupKey(key)
+	if err != nil {
+		return false, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if version <= d.seen[streamID] {
+		return false, nil
+	}
+	d.seen[streamID] = version
+	return true, nil
+}
@@ -0,0 +1,124 @@
+package kafkasink
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"simple-event-modeling/common"
+	"simple-event-modeling/outbox"
+	"simple-event-modeling/serialization"
+)
+
+type fakeProducer struct {
+	messages []Message
+}
+
+func (p *fakeProducer) Produce(_ context.Context, messages []Message) error {
+	p.messages = append(p.messages, messages...)
+	return nil
+}
+
+func header(message Message, key string) string {
+	for _, h := range message.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func TestSink_RelaysKeyedMessages(t *testing.T) {
+	store := common.NewEventStore()
+	store.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+	store.Append(common.NewEvent("CartCreated", "cart-2", 1, nil, nil))
+	store.Append(common.NewEvent("ItemAdded", "cart-1", 2, map[string]interface{}{"item": "apple"}, nil))
+
+	producer := &fakeProducer{}
+	relay := outbox.NewRelay("kafka", store, NewSink(producer, "cart-events", 12))
+	if _, err := relay.Process(context.Background()); err != nil {
+		t.Fatalf("Error relaying: %v", err)
+	}
+
+	if len(producer.messages) != 3 {
+		t.Fatalf("Expected 3 messages, got %d", len(producer.messages))
+	}
+	first, last := producer.messages[0], producer.messages[2]
+	if first.Topic != "cart-events" || string(first.Key) != "cart-1" || first.Partition != last.Partition {
+		t.Errorf("Expected the events of cart-1 keyed to one partition, got %+v and %+v", first, last)
+	}
+	if first.Partition != Partition([]byte("cart-1"), 12) {
+		t.Errorf("Expected the default partitioner's choice, got %d", first.Partition)
+	}
+	if header(last, HeaderDedupKey) != "cart-1:2" || header(last, HeaderStreamVersion) != "2" || header(last, HeaderEventType) != "ItemAdded" {
+		t.Errorf("Unexpected headers %+v", last.Headers)
+	}
+	var event common.Event
+	if err := json.Unmarshal(last.Value, &event); err != nil || event.Data["item"] != "apple" {
+		t.Errorf("Expected the event as JSON, got %s (%v)", last.Value, err)
+	}
+
+	producer.messages = nil
+	NewSink(producer, "cart-events", 0).Publish(context.Background(), &event)
+	if producer.messages[0].Partition != -1 {
+		t.Errorf("Expected partitioning left to the producer, got %d", producer.messages[0].Partition)
+	}
+}
+
+func TestSink_AvroValues(t *testing.T) {
+	registry := serialization.NewMemorySchemaRegistry()
+	avro := serialization.NewAvro(registry, nil)
+	producer := &fakeProducer{}
+	sink := NewSink(producer, "cart-events", 0, WithSerializer(avro))
+
+	event := common.NewEvent("ItemAdded", "cart-1", 2, map[string]interface{}{"item": "apple"}, nil)
+	if err := sink.Publish(context.Background(), event); err != nil {
+		t.Fatalf("Error publishing: %v", err)
+	}
+	message := producer.messages[0]
+	if header(message, HeaderContentType) != serialization.ContentTypeAvro || message.Value[0] != 0 {
+		t.Errorf("Expected a Confluent-framed Avro value, got %s % x", header(message, HeaderContentType), message.Value[:5])
+	}
+	decoded, err := serialization.NewAvro(registry, nil).Unmarshal(message.Value)
+	if err != nil || decoded.Data["item"] != "apple" {
+		t.Errorf("Expected consumers to decode the value, got %v (%v)", decoded, err)
+	}
+}
+
+func TestMurmur2MatchesKafka(t *testing.T) {
+	// Vectors from the Kafka clients' Utils tests
+	for key, want := range map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+		"abc": 479470107,
+	} {
+		if got := murmur2([]byte(key)); got != want {
+			t.Errorf("murmur2(%q) = %d, want %d", key, got, want)
+		}
+	}
+}
+
+func TestDeduplicator(t *testing.T) {
+	d := NewDeduplicator()
+	for _, step := range []struct {
+		key  string
+		want bool
+	}{
+		{"cart-1:1", true},
+		{"cart-1:2", true},
+		{"cart-1:2", false},
+		{"cart-1:1", false},
+		{"cart:with:colons:1", true},
+		{"cart-1:3", true},
+	} {
+		if got, err := d.Accept(step.key); err != nil || got != step.want {
+			t.Errorf("Accept(%q) = %v (%v), want %v", step.key, got, err, step.want)
+		}
+	}
+	if _, err := d.Accept("cart-1"); err == nil {
+		t.Error("Expected an invalid key to be rejected")
+	}
+}
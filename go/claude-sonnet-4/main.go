@@ -32,8 +32,8 @@ func main() {
 	// Add some items
 	fmt.Println("2. Adding items to cart...")
 	addCmd1 := &cart.AddItemCommand{
-		AggregateID: event.AggregateID,
-		ItemID:      "item-1",
+		CartID: event.AggregateID,
+		ItemID: "item-1",
 	}
 	event, err = cartAggregate.Handle(addCmd1)
 	if err != nil {
@@ -42,8 +42,8 @@ func main() {
 	fmt.Printf("   Added item-1 (version %d)\n", event.Version)
 
 	addCmd2 := &cart.AddItemCommand{
-		AggregateID: event.AggregateID,
-		ItemID:      "item-2",
+		CartID: event.AggregateID,
+		ItemID: "item-2",
 	}
 	event, err = cartAggregate.Handle(addCmd2)
 	if err != nil {
@@ -81,8 +81,8 @@ func main() {
 	// Try to add too many items (should fail)
 	fmt.Println("5. Trying to exceed cart limit...")
 	addCmd3 := &cart.AddItemCommand{
-		AggregateID: event.AggregateID,
-		ItemID:      "item-3",
+		CartID: event.AggregateID,
+		ItemID: "item-3",
 	}
 	event, err = cartAggregate.Handle(addCmd3)
 	if err != nil {
@@ -91,8 +91,8 @@ func main() {
 	fmt.Printf("   Added item-3 (version %d)\n", event.Version)
 
 	addCmd4 := &cart.AddItemCommand{
-		AggregateID: event.AggregateID,
-		ItemID:      "item-4",
+		CartID: event.AggregateID,
+		ItemID: "item-4",
 	}
 	_, err = cartAggregate.Handle(addCmd4)
 	if err != nil {
@@ -103,8 +103,8 @@ func main() {
 	// Remove an item
 	fmt.Println("6. Removing an item...")
 	removeCmd := &cart.RemoveItemCommand{
-		AggregateID: event.AggregateID,
-		ItemID:      "item-2",
+		CartID: event.AggregateID,
+		ItemID: "item-2",
 	}
 	event, err = cartAggregate.Handle(removeCmd)
 	if err != nil {
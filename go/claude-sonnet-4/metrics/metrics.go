@@ -0,0 +1,231 @@
+// Package metrics tracks how continuous projections and other subscriptions of the
+// global event log are keeping up: the checkpoint each has reached against the
+// store's head, its throughput, the last error it stopped at, and how long it has
+// gone without progress.
+//
+// Subscriptions report to a Projections tracker through a common.ProgressFunc:
+//
+//	tracker := metrics.NewProjections(store)
+//	projection.Progress = tracker.Track("cart-items")
+//	manager.OnProgress(tracker.Track("reservation-expiry"))
+//
+// Snapshot returns the figures for dashboards and the admin API, and the tracker
+// serves them in the Prometheus text format for scraping.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"simple-event-modeling/common"
+)
+
+// DefaultRateWindow is the period events per second are averaged over
+const DefaultRateWindow = time.Minute
+
+// ProjectionStats describes how far a subscription has processed the event log
+type ProjectionStats struct {
+	Name string `json:"name"`
+	// Checkpoint is the position of the last event applied
+	Checkpoint int `json:"checkpoint"`
+	// Head is the position of the last event in the store
+	Head int `json:"head"`
+	Lag  int `json:"lag"`
+	// EventsPerSecond is the rate events were applied at over the rate window
+	EventsPerSecond float64 `json:"events_per_second"`
+	LastError       string  `json:"last_error,omitempty"`
+	// LastErrorAt is nil when the subscription never failed
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+	// LastProgressAt is when the checkpoint last advanced, or when tracking started
+	LastProgressAt time.Time `json:"last_progress_at"`
+	// SincePoll is how long ago the subscription last reported
+	SincePoll time.Duration `json:"since_poll_ns"`
+	// SinceProgress is how long ago the checkpoint last advanced
+	SinceProgress time.Duration `json:"since_progress_ns"`
+}
+
+type sample struct {
+	at      time.Time
+	applied int
+}
+
+type projectionState struct {
+	started      time.Time
+	checkpoint   int
+	samples      []sample
+	lastError    string
+	lastErrorAt  *time.Time
+	lastPoll     time.Time
+	lastProgress time.Time
+}
+
+// Projections tracks the progress of named subscriptions of one store
+type Projections struct {
+	// RateWindow is the period events per second are averaged over; 0 uses
+	// DefaultRateWindow
+	RateWindow time.Duration
+	// Now returns the current time; nil uses time.Now
+	Now func() time.Time
+
+	store common.Store
+
+	mu      sync.Mutex
+	tracked map[string]*projectionState
+}
+
+// NewProjections creates a tracker for subscriptions of store
+func NewProjections(store common.Store) *Projections {
+	return &Projections{store: store, tracked: make(map[string]*projectionState)}
+}
+
+func (p *Projections) now() time.Time {
+	if p.Now != nil {
+		return p.Now()
+	}
+	return time.Now()
+}
+
+func (p *Projections) window() time.Duration {
+	if p.RateWindow > 0 {
+		return p.RateWindow
+	}
+	return DefaultRateWindow
+}
+
+// Track starts tracking a subscription and returns the function it reports its
+// progress to. Tracking a name again resets its figures.
+func (p *Projections) Track(name string) common.ProgressFunc {
+	now := p.now()
+	p.mu.Lock()
+	state := &projectionState{started: now, lastPoll: now, lastProgress: now}
+	p.tracked[name] = state
+	p.mu.Unlock()
+
+	return func(checkpoint common.Checkpoint, applied int, err error) {
+		now := p.now()
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		state.lastPoll = now
+		if checkpoint.Position != state.checkpoint || applied > 0 {
+			state.lastProgress = now
+		}
+		state.checkpoint = checkpoint.Position
+		if applied > 0 {
+			state.samples = append(state.samples, sample{at: now, applied: applied})
+		}
+		state.samples = prune(state.samples, now.Add(-p.window()))
+		if err != nil {
+			state.lastError = err.Error()
+			state.lastErrorAt = &now
+		}
+	}
+}
+
+// prune drops the samples taken before cutoff
+func prune(samples []sample, cutoff time.Time) []sample {
+	i := 0
+	for i < len(samples) && samples[i].at.Before(cutoff) {
+		i++
+	}
+	return samples[i:]
+}
+
+// headPosition returns the position of the last event of a global log, or 0 when
+// it is empty
+func headPosition(all []*common.Event) int {
+	if len(all) == 0 {
+		return 0
+	}
+	return common.LogPosition(all, len(all)-1)
+}
+
+// Snapshot returns the figures of every tracked subscription, sorted by name
+func (p *Projections) Snapshot() []ProjectionStats {
+	all := p.store.GetAllEvents()
+	head := headPosition(all)
+	now := p.now()
+	window := p.window()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := make([]ProjectionStats, 0, len(p.tracked))
+	for name, state := range p.tracked {
+		state.samples = prune(state.samples, now.Add(-window))
+		applied := 0
+		for _, s := range state.samples {
+			applied += s.applied
+		}
+		// Average over the time tracked when it is shorter than the window
+		period := min(window, now.Sub(state.started))
+		rate := 0.0
+		if period > 0 {
+			rate = float64(applied) / period.Seconds()
+		}
+
+		stats = append(stats, ProjectionStats{
+			Name:            name,
+			Checkpoint:      state.checkpoint,
+			Head:            head,
+			Lag:             len(common.EventsAfter(all, state.checkpoint)),
+			EventsPerSecond: rate,
+			LastError:       state.lastError,
+			LastErrorAt:     state.lastErrorAt,
+			LastProgressAt:  state.lastProgress,
+			SincePoll:       now.Sub(state.lastPoll),
+			SinceProgress:   now.Sub(state.lastProgress),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// WritePrometheus writes the snapshot in the Prometheus text exposition format
+func (p *Projections) WritePrometheus(w io.Writer) error {
+	stats := p.Snapshot()
+	gauges := []struct {
+		name, help string
+		value      func(ProjectionStats) float64
+	}{
+		{"sem_projection_checkpoint", "Position of the last event applied by the projection.", func(s ProjectionStats) float64 { return float64(s.Checkpoint) }},
+		{"sem_projection_lag_events", "Events appended to the store that the projection has not applied.", func(s ProjectionStats) float64 { return float64(s.Lag) }},
+		{"sem_projection_events_per_second", "Rate the projection applied events at over the rate window.", func(s ProjectionStats) float64 { return s.EventsPerSecond }},
+		{"sem_projection_seconds_since_progress", "Seconds since the projection's checkpoint last advanced.", func(s ProjectionStats) float64 { return s.SinceProgress.Seconds() }},
+		{"sem_projection_last_error_timestamp_seconds", "Unix time of the projection's last error, 0 if it never failed.", func(s ProjectionStats) float64 {
+			if s.LastErrorAt == nil {
+				return 0
+			}
+			return float64(s.LastErrorAt.UnixNano()) / 1e9
+		}},
+	}
+
+	var head int
+	if len(stats) > 0 {
+		head = stats[0].Head
+	} else {
+		head = headPosition(p.store.GetAllEvents())
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# HELP sem_store_head Position of the last event in the store.\n# TYPE sem_store_head gauge\nsem_store_head %d\n", head)
+	for _, gauge := range gauges {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", gauge.name, gauge.help, gauge.name)
+		for _, s := range stats {
+			fmt.Fprintf(&b, "%s{projection=%q} %g\n", gauge.name, s.Name, gauge.value(s))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format, e.g. at /metrics
+func (p *Projections) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	p.WritePrometheus(w)
+}
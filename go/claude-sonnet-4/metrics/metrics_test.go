@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"simple-event-modeling/common"
+)
+
+// failingProjection fails on its second event
+type failingProjection struct {
+	applied int
+}
+
+func (p *failingProjection) Name() string { return "failing" }
+func (p *failingProjection) On(*common.Event) error {
+	if p.applied == 1 {
+		return errors.New("unknown item")
+	}
+	p.applied++
+	return nil
+}
+func (p *failingProjection) State() interface{} { return p.applied }
+
+func TestProjections_Track(t *testing.T) {
+	store := common.NewEventStore()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := NewProjections(store)
+	tracker.Now = func() time.Time { return now }
+	tracker.RateWindow = 10 * time.Second
+
+	projection := common.NewAsyncProjection(store, &failingProjection{})
+	projection.Progress = tracker.Track("failing")
+	for version := 1; version <= 3; version++ {
+		store.Append(common.NewEvent("ItemAdded", "cart-1", version, nil, nil))
+	}
+
+	if data, _ := json.Marshal(tracker.Snapshot()); strings.Contains(string(data), "last_error_at") {
+		t.Errorf("Expected no last_error_at before any failure, got %s", data)
+	}
+
+	now = now.Add(2 * time.Second)
+	if err := projection.CatchUp(); err == nil {
+		t.Fatal("Expected the catch-up to fail")
+	}
+	stats := tracker.Snapshot()
+	if len(stats) != 1 {
+		t.Fatalf("Expected one tracked projection, got %+v", stats)
+	}
+	got := stats[0]
+	if got.Checkpoint != 1 || got.Head != 3 || got.Lag != 2 || got.EventsPerSecond != 0.5 {
+		t.Errorf("Expected checkpoint 1 of 3 at 0.5 events/s, got %+v", got)
+	}
+	if got.LastError != "unknown item" || got.LastErrorAt == nil || !got.LastErrorAt.Equal(now) || got.SinceProgress != 0 {
+		t.Errorf("Expected the error to be recorded with the progress, got %+v", got)
+	}
+
+	// A stuck projection polls without progress, and old samples leave the window
+	now = now.Add(20 * time.Second)
+	projection.CatchUp()
+	got = tracker.Snapshot()[0]
+	if got.SinceProgress != 20*time.Second || got.SincePoll != 0 || got.EventsPerSecond != 0 {
+		t.Errorf("Expected 20s without progress and no throughput, got %+v", got)
+	}
+}
+
+func TestProjections_WritePrometheus(t *testing.T) {
+	store := common.NewEventStore()
+	store.Append(common.NewEvent("ItemAdded", "cart-1", 1, nil, nil))
+	store.Append(common.NewEvent("ItemAdded", "cart-1", 2, nil, nil))
+	tracker := NewProjections(store)
+	tracker.Track("cart-items")(common.Checkpoint{Projection: "cart-items", Position: 1}, 1, nil)
+
+	var b strings.Builder
+	if err := tracker.WritePrometheus(&b); err != nil {
+		t.Fatalf("Error writing metrics: %v", err)
+	}
+	for _, want := range []string{
+		"sem_store_head 2\n",
+		"# TYPE sem_projection_lag_events gauge\n",
+		`sem_projection_lag_events{projection="cart-items"} 1` + "\n",
+		`sem_projection_last_error_timestamp_seconds{projection="cart-items"} 0` + "\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("Expected %q in:\n%s", want, b.String())
+		}
+	}
+}
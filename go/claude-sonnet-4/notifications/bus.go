@@ -0,0 +1,19 @@
+// Package notifications registers the notification command handler with a command
+// bus.
+package notifications
+
+import (
+	"context"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+)
+
+// RegisterCommands registers the SendNotification handler, delivering through
+// notifier the messages rendered from templates. Each command is handled by a fresh
+// notification hydrated from the store.
+func RegisterCommands(commands *bus.CommandBus, store common.Store, notifier Notifier, templates *Templates) {
+	commands.Register(CommandTypeSendNotification, func(ctx context.Context, command common.Command) (*common.Event, error) {
+		return NewNotification(store, notifier, templates).HandleContext(ctx, command)
+	})
+}
@@ -0,0 +1,19 @@
+// Package notifications provides command types for notifications. Commands are
+// simple record structures; their only methods expose the routing metadata required
+// by common.Command.
+package notifications
+
+// SendNotificationCommand renders a template and delivers it to a recipient on a
+// channel. Senders choose the notification ID so that sending the same notification
+// again is recognized, e.g. from the rule and event it reacts to.
+type SendNotificationCommand struct {
+	NotificationID string `json:"notification_id" validate:"required"`
+	Template       string `json:"template" validate:"required"`
+	Channel        string `json:"channel" validate:"required"`
+	To             string `json:"to" validate:"required"`
+	// Data is what the template is executed with
+	Data map[string]interface{} `json:"data,omitempty"`
+}
+
+func (c *SendNotificationCommand) AggregateID() string { return StreamID(c.NotificationID) }
+func (c *SendNotificationCommand) CommandType() string { return CommandTypeSendNotification }
@@ -0,0 +1,41 @@
+// Package notifications provides the structured rejections of notification
+// commands. They all match common.ErrInvalidCommand with errors.Is and carry the
+// rule in their code.
+package notifications
+
+import (
+	"fmt"
+
+	"simple-event-modeling/common"
+)
+
+// Rejection codes of notification commands
+const (
+	CodeNotificationAlreadySent common.ErrorCode = "notification_already_sent"
+	CodeUnknownTemplate         common.ErrorCode = "unknown_template"
+)
+
+// AlreadySentError rejects sending a notification that was delivered before
+type AlreadySentError struct {
+	NotificationID string
+}
+
+func (e *AlreadySentError) Error() string {
+	return fmt.Sprintf("notification %s was already sent", e.NotificationID)
+}
+
+func (e *AlreadySentError) Is(target error) bool   { return target == common.ErrInvalidCommand }
+func (e *AlreadySentError) Code() common.ErrorCode { return CodeNotificationAlreadySent }
+
+// UnknownTemplateError rejects a notification no template renders for its channel
+type UnknownTemplateError struct {
+	Template string
+	Channel  string
+}
+
+func (e *UnknownTemplateError) Error() string {
+	return fmt.Sprintf("no %s template for %s notifications", e.Template, e.Channel)
+}
+
+func (e *UnknownTemplateError) Is(target error) bool   { return target == common.ErrInvalidCommand }
+func (e *UnknownTemplateError) Code() common.ErrorCode { return CodeUnknownTemplate }
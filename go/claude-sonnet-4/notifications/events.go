@@ -0,0 +1,61 @@
+// Package notifications provides event types and creation functions for
+// notifications. Events are simple record structures with no behaviors.
+package notifications
+
+import (
+	"strings"
+
+	"simple-event-modeling/common"
+)
+
+// Event type constants
+const (
+	EventTypeNotificationSent   = "NotificationSent"
+	EventTypeNotificationFailed = "NotificationFailed"
+)
+
+// StreamID returns the ID of the stream of a notification
+func StreamID(notificationID string) string {
+	return Category + "-" + notificationID
+}
+
+// NotificationIDOf returns the notification whose events a stream holds
+func NotificationIDOf(streamID string) string {
+	return strings.TrimPrefix(streamID, Category+"-")
+}
+
+// DeliveryData is the payload of notification events
+type DeliveryData struct {
+	NotificationID string `json:"notification_id"`
+	Template       string `json:"template"`
+	Channel        string `json:"channel"`
+	To             string `json:"to"`
+	// Subject is set on NotificationSent events of channels with subjects
+	Subject string `json:"subject,omitempty"`
+	// Error is why a delivery failed
+	Error string `json:"error,omitempty"`
+}
+
+// NewNotificationSentEvent creates a new NotificationSent event
+func NewNotificationSentEvent(version int, msg Message, templateName string) *common.Event {
+	data := map[string]interface{}{
+		"notification_id": msg.NotificationID,
+		"template":        templateName,
+		"channel":         msg.Channel,
+		"to":              msg.To,
+		"subject":         msg.Subject,
+	}
+	return common.NewEvent(EventTypeNotificationSent, StreamID(msg.NotificationID), version, data, nil)
+}
+
+// NewNotificationFailedEvent creates a new NotificationFailed event
+func NewNotificationFailedEvent(version int, msg Message, templateName string, reason error) *common.Event {
+	data := map[string]interface{}{
+		"notification_id": msg.NotificationID,
+		"template":        templateName,
+		"channel":         msg.Channel,
+		"to":              msg.To,
+		"error":           reason.Error(),
+	}
+	return common.NewEvent(EventTypeNotificationFailed, StreamID(msg.NotificationID), version, data, nil)
+}
@@ -0,0 +1,118 @@
+// Package notifications provides the Notification aggregate delivering a message.
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"simple-event-modeling/common"
+)
+
+// Notification is a message to one recipient on one channel. It records every
+// delivery attempt and is delivered at most once successfully.
+type Notification struct {
+	*common.BaseAggregate
+	notifier  Notifier
+	templates *Templates
+	sent      bool
+	failures  int
+}
+
+// NewNotification creates a new notification aggregate delivering through notifier
+func NewNotification(store common.Store, notifier Notifier, templates *Templates) *Notification {
+	return &Notification{
+		BaseAggregate: common.NewBaseAggregate(store),
+		notifier:      notifier,
+		templates:     templates,
+	}
+}
+
+// Sent reports whether the notification was delivered
+func (n *Notification) Sent() bool {
+	return n.sent
+}
+
+// Failures returns how many deliveries of the notification failed
+func (n *Notification) Failures() int {
+	return n.failures
+}
+
+// Handle processes commands and returns resulting events
+func (n *Notification) Handle(command common.Command) (*common.Event, error) {
+	return n.HandleContext(context.Background(), command)
+}
+
+// HandleContext processes commands, delivering through the notifier with ctx, and
+// returns resulting events. A failed delivery is recorded as a NotificationFailed
+// event and its error returned.
+func (n *Notification) HandleContext(ctx context.Context, command common.Command) (*common.Event, error) {
+	if !n.IsLive() {
+		if err := n.Hydrate(command.AggregateID()); err != nil {
+			return nil, err
+		}
+	}
+
+	cmd, ok := command.(*SendNotificationCommand)
+	if !ok {
+		return nil, &common.UnknownCommandError{
+			CommandType: command.CommandType(),
+			Registered:  common.DefaultRegistry.CommandTypes(AggregateTypeNotification),
+		}
+	}
+
+	// Business rule: a notification reaches its recipient once
+	if n.sent {
+		return nil, &AlreadySentError{NotificationID: cmd.NotificationID}
+	}
+	subject, body, err := n.templates.Render(cmd.Template, cmd.Channel, cmd.Data)
+	if err != nil {
+		var unknown *UnknownTemplateError
+		if errors.As(err, &unknown) {
+			return nil, err
+		}
+		return nil, &common.InvalidCommandError{Message: fmt.Sprintf("rendering %s: %v", cmd.Template, err)}
+	}
+	msg := Message{NotificationID: cmd.NotificationID, Channel: cmd.Channel, To: cmd.To, Subject: subject, Body: body}
+
+	if sendErr := n.notifier.Send(ctx, msg); sendErr != nil {
+		if err := n.record(NewNotificationFailedEvent(n.Version()+1, msg, cmd.Template, sendErr)); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("sending notification %s: %w", cmd.NotificationID, sendErr)
+	}
+	event := NewNotificationSentEvent(n.Version()+1, msg, cmd.Template)
+	if err := n.record(event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// record applies an event and appends it to the store
+func (n *Notification) record(event *common.Event) error {
+	if err := n.On(event); err != nil {
+		return err
+	}
+	return n.Store().Append(event)
+}
+
+// On applies events to aggregate state
+func (n *Notification) On(event *common.Event) error {
+	if event.Version == 1 {
+		n.Begin(event)
+	}
+	switch event.Type {
+	case EventTypeNotificationSent:
+		n.sent = true
+	case EventTypeNotificationFailed:
+		n.failures++
+	default:
+		return errors.New("unhandled event type: " + event.Type)
+	}
+	return n.Advance(event)
+}
+
+// Hydrate rebuilds the aggregate state from its event stream
+func (n *Notification) Hydrate(id string) error {
+	return n.BaseAggregate.Hydrate(id, n.On)
+}
@@ -0,0 +1,22 @@
+// Package notifications sends email and SMS notifications through a Notifier port
+// and records every delivery as events, so what customers were told is part of the
+// event log and tests can assert on it without a mail server.
+//
+// A notification is an aggregate with its own stream, "notification-<id>". The
+// SendNotification command renders a template for a channel, hands the message to
+// the notifier, and records NotificationSent; a failed delivery records
+// NotificationFailed and returns the error so the sender retries. A notification
+// that was sent rejects being sent again, so notifications fired by a rules.Engine
+// (see Notify), which fires at least once, reach the recipient once.
+//
+// The package is organized into separate files for each major concept:
+// - notifier.go: Notifier port, channel routing, and the in-memory Fake
+// - templates.go: Templates rendered per channel with text/template
+// - commands.go: Command types (SendNotification)
+// - events.go: Event types and creation functions (NotificationSent, NotificationFailed)
+// - notification.go: Notification aggregate delivering a message once
+// - errors.go: Structured rejections of notification commands
+// - rules.go: Notify, the rules.Action sending notifications
+// - registry.go: Registration with the common registry
+// - bus.go: Registration of command handlers with a command bus
+package notifications
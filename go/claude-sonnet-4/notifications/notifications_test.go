@@ -0,0 +1,114 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+	"simple-event-modeling/rules"
+	"testing"
+)
+
+func newTemplates(t *testing.T) *Templates {
+	templates := NewTemplates()
+	if err := templates.Add("welcome", ChannelEmail, Template{Subject: "Hi {{.name}}", Body: "Welcome aboard, {{.name}}!"}); err != nil {
+		t.Fatalf("Error adding template: %v", err)
+	}
+	if err := templates.Add("welcome", "", Template{Body: "Welcome {{.name}}"}); err != nil {
+		t.Fatalf("Error adding template: %v", err)
+	}
+	return templates
+}
+
+func TestTemplates_Render(t *testing.T) {
+	templates := newTemplates(t)
+
+	subject, body, err := templates.Render("welcome", ChannelEmail, map[string]interface{}{"name": "Ada"})
+	if err != nil || subject != "Hi Ada" || body != "Welcome aboard, Ada!" {
+		t.Errorf("Unexpected email rendering %q %q (%v)", subject, body, err)
+	}
+	if _, body, _ := templates.Render("welcome", ChannelSMS, map[string]interface{}{"name": "Ada"}); body != "Welcome Ada" {
+		t.Errorf("Expected the default template for SMS, got %q", body)
+	}
+	if _, _, err := templates.Render("welcome", ChannelSMS, nil); err == nil {
+		t.Error("Expected missing data to fail rendering")
+	}
+	var unknown *UnknownTemplateError
+	if _, _, err := templates.Render("goodbye", ChannelEmail, nil); !errors.As(err, &unknown) {
+		t.Errorf("Expected an UnknownTemplateError, got %v", err)
+	}
+	if err := templates.Add("broken", "", Template{Body: "{{.name"}); err == nil {
+		t.Error("Expected an unparseable template to be rejected")
+	}
+}
+
+func TestNotification_DeliversOnce(t *testing.T) {
+	store := common.NewEventStore()
+	email, sms := NewFake(), NewFake()
+	commands := bus.NewCommandBus(bus.Validation())
+	RegisterCommands(commands, store, Channels{ChannelEmail: email, ChannelSMS: sms}, newTemplates(t))
+	ctx := context.Background()
+	send := &SendNotificationCommand{NotificationID: "n-1", Template: "welcome", Channel: ChannelEmail, To: "ada@example.com", Data: map[string]interface{}{"name": "Ada"}}
+
+	email.FailWith(errors.New("smtp unavailable"))
+	if _, err := commands.Dispatch(ctx, send); err == nil || errors.Is(err, common.ErrInvalidCommand) {
+		t.Fatalf("Expected the delivery failure to be returned for a retry, got %v", err)
+	}
+	email.FailWith(nil)
+	event, err := commands.Dispatch(ctx, send)
+	if err != nil {
+		t.Fatalf("Error sending notification: %v", err)
+	}
+	if event.Type != EventTypeNotificationSent || event.AggregateID != StreamID("n-1") || event.Data["subject"] != "Hi Ada" {
+		t.Errorf("Unexpected NotificationSent event: %+v", event)
+	}
+	if sent := email.Sent(); len(sent) != 1 || sent[0].To != "ada@example.com" || sent[0].Body != "Welcome aboard, Ada!" {
+		t.Errorf("Unexpected emails: %+v", sent)
+	}
+
+	var already *AlreadySentError
+	if _, err := commands.Dispatch(ctx, send); !errors.As(err, &already) {
+		t.Errorf("Expected an AlreadySentError, got %v", err)
+	}
+	notification := NewNotification(store, email, nil)
+	if err := notification.Hydrate(StreamID("n-1")); err != nil || !notification.Sent() || notification.Failures() != 1 {
+		t.Errorf("Expected a sent notification with 1 failure, got %v %d (%v)", notification.Sent(), notification.Failures(), err)
+	}
+	if len(sms.Sent()) != 0 {
+		t.Errorf("Expected no SMS, got %+v", sms.Sent())
+	}
+}
+
+func TestNotify_FromRules(t *testing.T) {
+	store := common.NewEventStore()
+	email, sms := NewFake(), NewFake()
+	commands := bus.NewCommandBus()
+	RegisterCommands(commands, store, Channels{ChannelEmail: email, ChannelSMS: sms}, newTemplates(t))
+	engine, _ := rules.NewEngine("notifier", store, commands)
+	engine.Add("welcome", rules.When("UserRegistered").Then(Notify("welcome", func(_ context.Context, event *common.Event) ([]Recipient, error) {
+		return []Recipient{
+			{Channel: ChannelEmail, To: event.Data["email"].(string)},
+			{Channel: ChannelSMS, To: event.Data["phone"].(string)},
+		}, nil
+	}, nil)))
+
+	store.Append(common.NewEvent("UserRegistered", "u-1", 1, map[string]interface{}{"name": "Ada", "email": "ada@example.com", "phone": "+15550100"}, nil))
+	if _, err := engine.Process(context.Background()); err != nil {
+		t.Fatalf("Error processing: %v", err)
+	}
+	if len(email.Sent()) != 1 || len(sms.Sent()) != 1 || sms.Sent()[0].Body != "Welcome Ada" {
+		t.Fatalf("Expected one email and one SMS, got %+v and %+v", email.Sent(), sms.Sent())
+	}
+
+	// A second engine starting over sends nothing again
+	restarted, _ := rules.NewEngine("other", store, commands)
+	restarted.Add("welcome", rules.When("UserRegistered").Then(Notify("welcome", func(context.Context, *common.Event) ([]Recipient, error) {
+		return []Recipient{{Channel: ChannelEmail, To: "ada@example.com"}}, nil
+	}, nil)))
+	if _, err := restarted.Process(context.Background()); err != nil {
+		t.Fatalf("Error processing: %v", err)
+	}
+	if len(email.Sent()) != 1 {
+		t.Errorf("Expected the notification not to be sent twice, got %+v", email.Sent())
+	}
+}
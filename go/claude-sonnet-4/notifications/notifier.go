@@ -0,0 +1,80 @@
+// Package notifications provides the Notifier port and its in-memory fake.
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Notification channels
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+)
+
+// Message is a rendered notification ready to deliver
+type Message struct {
+	NotificationID string
+	Channel        string
+	// To is the address on the channel, e.g. an email address or phone number
+	To string
+	// Subject is empty for channels without one, such as SMS
+	Subject string
+	Body    string
+}
+
+// Notifier delivers messages, e.g. through an email or SMS provider
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Channels is a Notifier routing each message to the notifier of its channel
+type Channels map[string]Notifier
+
+// Send delivers msg through the notifier of its channel
+func (c Channels) Send(ctx context.Context, msg Message) error {
+	notifier, ok := c[msg.Channel]
+	if !ok {
+		return fmt.Errorf("no notifier for channel %q", msg.Channel)
+	}
+	return notifier.Send(ctx, msg)
+}
+
+// Fake is an in-memory Notifier recording the messages it is given, for tests and
+// demos. It stands in for email and SMS providers alike.
+type Fake struct {
+	mu   sync.Mutex
+	sent []Message
+	err  error
+}
+
+// NewFake creates a fake notifier
+func NewFake() *Fake {
+	return &Fake{}
+}
+
+// Send records msg, or fails with the error set by FailWith
+func (f *Fake) Send(_ context.Context, msg Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+// FailWith makes Send fail with err until it is called with nil
+func (f *Fake) FailWith(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+// Sent returns the messages delivered so far, oldest first
+func (f *Fake) Sent() []Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Message(nil), f.sent...)
+}
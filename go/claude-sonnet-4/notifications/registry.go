@@ -0,0 +1,46 @@
+// Package notifications registers the notification aggregate with the common
+// registry so tooling can discover its commands and events.
+package notifications
+
+import "simple-event-modeling/common"
+
+// AggregateTypeNotification is the registered name of the notification aggregate
+const AggregateTypeNotification = "Notification"
+
+// Category is the stream category of notifications, one stream per notification
+const Category = "notification"
+
+// CommandTypeSendNotification is the command type name of SendNotificationCommand
+const CommandTypeSendNotification = "SendNotification"
+
+func init() {
+	register(common.DefaultRegistry)
+}
+
+func register(registry *common.Registry) {
+	registry.RegisterAggregate(common.AggregateInfo{Name: AggregateTypeNotification})
+	registry.RegisterCategory(common.CategoryInfo{Name: Category, Aggregate: AggregateTypeNotification})
+
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeSendNotification,
+		Aggregate: AggregateTypeNotification,
+		Produces:  []string{EventTypeNotificationSent, EventTypeNotificationFailed},
+	})
+
+	delivery := []common.FieldInfo{
+		{Name: "notification_id", Type: "string", Description: "ID of the notification"},
+		{Name: "template", Type: "string", Description: "Template the message was rendered from"},
+		{Name: "channel", Type: "string", Description: "Delivery channel, email or sms"},
+		{Name: "to", Type: "string", Description: "Address of the recipient on the channel", Sensitive: true},
+	}
+	registry.RegisterEvent(common.EventInfo{
+		Name:      EventTypeNotificationSent,
+		Aggregate: AggregateTypeNotification,
+		Payload:   append(delivery, common.FieldInfo{Name: "subject", Type: "string", Description: "Rendered subject, for channels with one"}),
+	})
+	registry.RegisterEvent(common.EventInfo{
+		Name:      EventTypeNotificationFailed,
+		Aggregate: AggregateTypeNotification,
+		Payload:   append(delivery, common.FieldInfo{Name: "error", Type: "string", Description: "Why the delivery failed"}),
+	})
+}
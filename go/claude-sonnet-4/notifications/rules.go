@@ -0,0 +1,64 @@
+// Package notifications provides the rules.Action sending notifications.
+package notifications
+
+import (
+	"context"
+
+	"simple-event-modeling/common"
+	"simple-event-modeling/rules"
+)
+
+// Recipient is an address on a channel
+type Recipient struct {
+	Channel string
+	To      string
+}
+
+// RecipientsFunc returns who is notified about an event; none skips the
+// notification
+type RecipientsFunc func(ctx context.Context, event *common.Event) ([]Recipient, error)
+
+// DataFunc returns what a template is executed with for an event
+type DataFunc func(ctx context.Context, event *common.Event) (map[string]interface{}, error)
+
+// Notify returns a rules.Action sending the template to the recipients of each
+// event. Without a data function the template is executed with the event's data
+// plus its "aggregate_id". Each notification's ID is derived from the template, the
+// event, and the channel, so a rule firing again for the same event is rejected as
+// already sent.
+func Notify(templateName string, recipients RecipientsFunc, data DataFunc) rules.Action {
+	return func(ctx context.Context, event *common.Event) ([]common.Command, error) {
+		to, err := recipients(ctx, event)
+		if err != nil || len(to) == 0 {
+			return nil, err
+		}
+		values, err := templateData(ctx, event, data)
+		if err != nil {
+			return nil, err
+		}
+
+		commands := make([]common.Command, 0, len(to))
+		for _, recipient := range to {
+			commands = append(commands, &SendNotificationCommand{
+				NotificationID: templateName + "-" + event.ID + "-" + recipient.Channel,
+				Template:       templateName,
+				Channel:        recipient.Channel,
+				To:             recipient.To,
+				Data:           values,
+			})
+		}
+		return commands, nil
+	}
+}
+
+func templateData(ctx context.Context, event *common.Event, data DataFunc) (map[string]interface{}, error) {
+	if data != nil {
+		return data(ctx, event)
+	}
+	values := make(map[string]interface{}, len(event.Data)+1)
+	for key, value := range event.Data {
+		values[key] = value
+	}
+	values["aggregate_id"] = event.AggregateID
+	return values, nil
+}
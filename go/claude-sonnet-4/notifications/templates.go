@@ -0,0 +1,92 @@
+// Package notifications provides the templates notifications are rendered from.
+package notifications
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"text/template"
+)
+
+// Template is the text of a notification, in text/template syntax. Both parts are
+// executed with the notification's data.
+type Template struct {
+	// Subject is ignored by channels without one, such as SMS
+	Subject string
+	Body    string
+}
+
+// Templates holds notification templates by name and channel. A template added for
+// the channel "" renders for every channel without one of its own.
+type Templates struct {
+	mu     sync.RWMutex
+	parsed map[string]parsedTemplate
+}
+
+type parsedTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+// NewTemplates creates an empty template set
+func NewTemplates() *Templates {
+	return &Templates{parsed: make(map[string]parsedTemplate)}
+}
+
+// Add parses and registers the template of a notification for a channel, replacing
+// any previous one
+func (t *Templates) Add(name, channel string, tmpl Template) error {
+	key := templateKey(name, channel)
+	subject, err := template.New(key + "/subject").Option("missingkey=error").Parse(tmpl.Subject)
+	if err != nil {
+		return fmt.Errorf("template %s: %w", key, err)
+	}
+	body, err := template.New(key + "/body").Option("missingkey=error").Parse(tmpl.Body)
+	if err != nil {
+		return fmt.Errorf("template %s: %w", key, err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.parsed[key] = parsedTemplate{subject: subject, body: body}
+	return nil
+}
+
+// Has reports whether a template renders the notification for channel
+func (t *Templates) Has(name, channel string) bool {
+	_, ok := t.lookup(name, channel)
+	return ok
+}
+
+// Render executes the template of a notification for a channel with data. It fails
+// with an *UnknownTemplateError when no template renders the notification.
+func (t *Templates) Render(name, channel string, data map[string]interface{}) (subject, body string, err error) {
+	parsed, ok := t.lookup(name, channel)
+	if !ok {
+		return "", "", &UnknownTemplateError{Template: name, Channel: channel}
+	}
+	var buf bytes.Buffer
+	if err := parsed.subject.Execute(&buf, data); err != nil {
+		return "", "", err
+	}
+	subject = buf.String()
+	buf.Reset()
+	if err := parsed.body.Execute(&buf, data); err != nil {
+		return "", "", err
+	}
+	return subject, buf.String(), nil
+}
+
+func (t *Templates) lookup(name, channel string) (parsedTemplate, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if parsed, ok := t.parsed[templateKey(name, channel)]; ok {
+		return parsed, true
+	}
+	parsed, ok := t.parsed[templateKey(name, "")]
+	return parsed, ok
+}
+
+func templateKey(name, channel string) string {
+	return name + "/" + channel
+}
@@ -0,0 +1,105 @@
+// Package outbox relays the events recorded in a store to a message broker. The
+// event log is the outbox: events are committed to the store first and published
+// afterwards by a Relay following the global log, so publishing never needs a
+// transaction spanning the store and the broker.
+//
+// Delivery is at least once. A relay that restarts from an older checkpoint, or
+// whose publish failed after the broker stored a message, publishes some events
+// again, so publishers attach a deduplication key (see jsstore.Publisher and
+// kafkasink.Sink) and consumers drop what they have seen.
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"simple-event-modeling/common"
+)
+
+// DefaultBatchSize caps the events handed to a BatchPublisher at once
+const DefaultBatchSize = 100
+
+// Publisher sends an event to a broker
+type Publisher interface {
+	Publish(ctx context.Context, event *common.Event) error
+}
+
+// BatchPublisher is implemented by publishers that send several events in one
+// request. The relay uses it when available.
+type BatchPublisher interface {
+	// PublishBatch sends events in order; on error none of them counts as sent
+	PublishBatch(ctx context.Context, events []*common.Event) error
+}
+
+// Relay publishes the events of the global log in order, remembering how far it got
+// (see common.Follower). A failed publish stops processing so the event is retried on the next run.
+//
+// The checkpoint is kept in memory. Callers that need to avoid republishing after a
+// restart persist Checkpoint and restore it with SetCheckpoint.
+type Relay struct {
+	// BatchSize caps the events handed to a BatchPublisher at once; 0 uses
+	// DefaultBatchSize
+	BatchSize int
+
+	follower  *common.Follower
+	publisher Publisher
+}
+
+// NewRelay creates a relay publishing the events of store
+func NewRelay(name string, store common.Store, publisher Publisher) *Relay {
+	return &Relay{follower: common.NewFollower(name, store), publisher: publisher}
+}
+
+// Name returns the name of the relay
+func (r *Relay) Name() string {
+	return r.follower.Name()
+}
+
+// Checkpoint returns the position of the last published event
+func (r *Relay) Checkpoint() common.Checkpoint {
+	return r.follower.Checkpoint()
+}
+
+// SetCheckpoint resumes publishing after the given position
+func (r *Relay) SetCheckpoint(position int) {
+	r.follower.SetCheckpoint(position)
+}
+
+// OnProgress sets a function called after every Process, e.g. to record metrics
+func (r *Relay) OnProgress(progress common.ProgressFunc) {
+	r.follower.OnProgress(progress)
+}
+
+// Process publishes the events appended since the checkpoint and returns how many
+// were published
+func (r *Relay) Process(ctx context.Context) (int, error) {
+	if batcher, ok := r.publisher.(BatchPublisher); ok {
+		size := r.BatchSize
+		if size <= 0 {
+			size = DefaultBatchSize
+		}
+		return r.follower.Process(size, func(batch []*common.Event, positions []int) error {
+			if err := batcher.PublishBatch(ctx, batch); err != nil {
+				return fmt.Errorf("%s: events %d-%d: %w", r.Name(), positions[0], positions[len(positions)-1], err)
+			}
+			return nil
+		})
+	}
+
+	return r.follower.Process(1, func(batch []*common.Event, positions []int) error {
+		if err := r.publisher.Publish(ctx, batch[0]); err != nil {
+			return fmt.Errorf("%s: event %d (%s): %w", r.Name(), positions[0], batch[0].Type, err)
+		}
+		return nil
+	})
+}
+
+// Run publishes new events every interval until ctx is cancelled. Errors are retried
+// on the next tick.
+func (r *Relay) Run(ctx context.Context, interval time.Duration) {
+	common.Poll(ctx, interval, func() error {
+		r.Process(ctx)
+		return nil
+	})
+}
@@ -0,0 +1,80 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"simple-event-modeling/common"
+)
+
+// recorder publishes into a slice, failing events of the type in failOn
+type recorder struct {
+	published []string
+	failOn    string
+}
+
+func (r *recorder) Publish(_ context.Context, event *common.Event) error {
+	if event.Type == r.failOn {
+		return errors.New("broker unavailable")
+	}
+	r.published = append(r.published, event.Type)
+	return nil
+}
+
+// batchRecorder records the size of every batch
+type batchRecorder struct {
+	recorder
+	batches []int
+}
+
+func (r *batchRecorder) PublishBatch(ctx context.Context, events []*common.Event) error {
+	r.batches = append(r.batches, len(events))
+	for _, event := range events {
+		r.Publish(ctx, event)
+	}
+	return nil
+}
+
+func TestRelay_Process(t *testing.T) {
+	store := common.NewEventStore()
+	store.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+	store.Append(common.NewEvent("ItemAdded", "cart-1", 2, nil, nil))
+	store.Append(common.NewEvent("CartCleared", "cart-1", 3, nil, nil))
+
+	publisher := &recorder{failOn: "ItemAdded"}
+	relay := NewRelay("outbox", store, publisher)
+	var reported common.Checkpoint
+	relay.OnProgress(func(checkpoint common.Checkpoint, _ int, _ error) { reported = checkpoint })
+
+	published, err := relay.Process(context.Background())
+	if err == nil || published != 1 || reported.Position != 1 {
+		t.Fatalf("Expected to stop at the failing event, got %d (%v) at %+v", published, err, reported)
+	}
+
+	publisher.failOn = ""
+	if published, err := relay.Process(context.Background()); err != nil || published != 2 {
+		t.Fatalf("Expected the retry to publish the rest, got %d (%v)", published, err)
+	}
+	if len(publisher.published) != 3 || publisher.published[1] != "ItemAdded" || relay.Checkpoint().Position != 3 {
+		t.Errorf("Expected every event published once in order, got %v", publisher.published)
+	}
+}
+
+func TestRelay_Batches(t *testing.T) {
+	store := common.NewEventStore()
+	for version := 1; version <= 5; version++ {
+		store.Append(common.NewEvent("ItemAdded", "cart-1", version, nil, nil))
+	}
+
+	publisher := &batchRecorder{}
+	relay := NewRelay("outbox", store, publisher)
+	relay.BatchSize = 2
+	relay.SetCheckpoint(1)
+	if published, err := relay.Process(context.Background()); err != nil || published != 4 {
+		t.Fatalf("Expected 4 events published, got %d (%v)", published, err)
+	}
+	if len(publisher.batches) != 2 || publisher.batches[0] != 2 || publisher.batches[1] != 2 {
+		t.Errorf("Expected two batches of 2, got %v", publisher.batches)
+	}
+}
@@ -0,0 +1,125 @@
+// Package process provides process managers: subscribers that react to events by
+// dispatching commands, coordinating flows that span several aggregates or time,
+// such as releasing a seat hold when its expiry timer elapses.
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+)
+
+// Reaction decides which commands to dispatch in response to an event
+type Reaction func(ctx context.Context, event *common.Event) ([]common.Command, error)
+
+// Manager follows the global event log (see common.Follower) and dispatches the commands its reactions
+// return. Commands rejected by a business rule (common.ErrInvalidCommand) or by a
+// closed aggregate (common.ErrAggregateClosed) are expected when reacting to stale
+// facts (e.g. expiring a hold that was already confirmed) and are skipped; any
+// other error stops processing so the event is retried on the next run.
+//
+// Delivery is at-least-once: the checkpoint advances after an event's commands are
+// dispatched, so an event whose later command fails, or that was handled just before
+// a crash, is reacted to again, and reactions must return commands that are safe to
+// repeat. The checkpoint is kept in memory; callers resuming across restarts
+// persist Checkpoint and restore it with SetCheckpoint.
+type Manager struct {
+	follower *common.Follower
+	commands *bus.CommandBus
+
+	mu        sync.Mutex
+	reactions map[string][]Reaction
+}
+
+// NewManager creates a process manager dispatching to the command bus
+func NewManager(name string, store common.Store, commands *bus.CommandBus) *Manager {
+	return &Manager{
+		follower:  common.NewFollower(name, store),
+		commands:  commands,
+		reactions: make(map[string][]Reaction),
+	}
+}
+
+// Name returns the name of the process manager
+func (m *Manager) Name() string {
+	return m.follower.Name()
+}
+
+// On registers a reaction to an event type
+func (m *Manager) On(eventType string, reaction Reaction) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reactions[eventType] = append(m.reactions[eventType], reaction)
+}
+
+// Consumes returns the event types the manager reacts to
+func (m *Manager) Consumes() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	types := make([]string, 0, len(m.reactions))
+	for eventType := range m.reactions {
+		types = append(types, eventType)
+	}
+	return types
+}
+
+// Checkpoint returns the position of the last processed event
+func (m *Manager) Checkpoint() common.Checkpoint {
+	return m.follower.Checkpoint()
+}
+
+// OnProgress sets a function called after every Process, e.g. to record metrics
+func (m *Manager) OnProgress(progress common.ProgressFunc) {
+	m.follower.OnProgress(progress)
+}
+
+// SetCheckpoint resumes processing after the given position
+func (m *Manager) SetCheckpoint(position int) {
+	m.follower.SetCheckpoint(position)
+}
+
+// Process reacts to the events appended since the checkpoint and returns how many
+// events were processed
+func (m *Manager) Process(ctx context.Context) (int, error) {
+	return m.follower.Process(1, func(batch []*common.Event, positions []int) error {
+		event := batch[0]
+		m.mu.Lock()
+		reactions := m.reactions[event.Type]
+		m.mu.Unlock()
+		for _, reaction := range reactions {
+			if err := m.react(ctx, reaction, event); err != nil {
+				return fmt.Errorf("%s: event %d (%s): %w", m.Name(), positions[0], event.Type, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Run processes new events every interval until ctx is cancelled. Errors are retried
+// on the next tick.
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	common.Poll(ctx, interval, func() error {
+		m.Process(ctx)
+		return nil
+	})
+}
+
+func (m *Manager) react(ctx context.Context, reaction Reaction, event *common.Event) error {
+	commands, err := reaction(ctx, event)
+	if err != nil {
+		return err
+	}
+	for _, command := range commands {
+		_, err := m.commands.Dispatch(ctx, command)
+		if err != nil && !errors.Is(err, common.ErrInvalidCommand) && !errors.Is(err, common.ErrAggregateClosed) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+	"testing"
+)
+
+type notifyCommand struct {
+	ID string
+}
+
+func (c *notifyCommand) AggregateID() string { return c.ID }
+func (c *notifyCommand) CommandType() string { return "Notify" }
+
+func TestManager_DispatchesReactions(t *testing.T) {
+	store := common.NewEventStore()
+	var notified []string
+	commands := bus.NewCommandBus()
+	commands.Register("Notify", func(_ context.Context, command common.Command) (*common.Event, error) {
+		switch command.AggregateID() {
+		case "rejected":
+			return nil, &common.InvalidCommandError{Message: "already notified"}
+		case "closed":
+			return nil, &common.AggregateClosedError{StreamID: "closed", ClosedBy: "OrderCancelled", Version: 2}
+		}
+		notified = append(notified, command.AggregateID())
+		event := common.NewEvent("Notified", "notifications", store.GetStreamVersion("notifications")+1, nil, nil)
+		return event, store.Append(event)
+	})
+
+	m := NewManager("notifier", store, commands)
+	m.On("OrderPlaced", func(_ context.Context, event *common.Event) ([]common.Command, error) {
+		return []common.Command{&notifyCommand{ID: event.AggregateID}}, nil
+	})
+
+	store.Append(common.NewEvent("OrderPlaced", "o-1", 1, nil, nil))
+	store.Append(common.NewEvent("OrderShipped", "o-1", 2, nil, nil))
+	store.Append(common.NewEvent("OrderPlaced", "rejected", 1, nil, nil))
+	store.Append(common.NewEvent("OrderPlaced", "closed", 1, nil, nil))
+
+	processed, err := m.Process(context.Background())
+	if err != nil || processed != 4 {
+		t.Fatalf("Expected 4 processed events, got %d (%v)", processed, err)
+	}
+	if len(notified) != 1 || notified[0] != "o-1" {
+		t.Errorf("Unexpected notifications: %v", notified)
+	}
+
+	// Notified events are new, but the manager does not react to them
+	if processed, _ := m.Process(context.Background()); processed != 1 {
+		t.Errorf("Expected only the Notified event to be processed, got %d", processed)
+	}
+	if m.Checkpoint().Position != 5 {
+		t.Errorf("Expected checkpoint 5, got %d", m.Checkpoint().Position)
+	}
+}
+
+func TestManager_StopsOnFailure(t *testing.T) {
+	store := common.NewEventStore()
+	failing := true
+	m := NewManager("flaky", store, bus.NewCommandBus())
+	m.On("OrderPlaced", func(context.Context, *common.Event) ([]common.Command, error) {
+		if failing {
+			return nil, errors.New("downstream unavailable")
+		}
+		return nil, nil
+	})
+	var reported error
+	m.OnProgress(func(_ common.Checkpoint, _ int, err error) { reported = err })
+	store.Append(common.NewEvent("OrderPlaced", "o-1", 1, nil, nil))
+
+	if _, err := m.Process(context.Background()); err == nil || reported != err {
+		t.Fatalf("Expected the reaction error to be returned and reported, got %v and %v", err, reported)
+	}
+	if m.Checkpoint().Position != 0 {
+		t.Errorf("Expected the failed event to be retried, checkpoint is %d", m.Checkpoint().Position)
+	}
+
+	failing = false
+	if processed, err := m.Process(context.Background()); err != nil || processed != 1 {
+		t.Errorf("Expected the retry to succeed, got %d (%v)", processed, err)
+	}
+}
@@ -0,0 +1,129 @@
+// Package quota provides command bus middleware enforcing quotas and entitlements,
+// such as at most 5 open carts per customer or 100 items added per day. A Checker
+// decides whether a command fits within its quota before it is handled; Limit is a
+// checker comparing what a command costs with the usage a read model, usually a
+// projection, reports for the key the command counts against.
+//
+// Quotas are checked against read models, not claimed in the event stream, so the
+// check is not atomic with the write: commands racing each other can overshoot a
+// limit by their number. Use the unique package where a limit must never be
+// exceeded.
+package quota
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+)
+
+// CodeQuotaExceeded is the rejection code of a command exceeding a quota
+const CodeQuotaExceeded common.ErrorCode = "quota_exceeded"
+
+// QuotaExceededError rejects a command that would take a key over its quota
+type QuotaExceededError struct {
+	// Quota names the limit, e.g. "open-carts"
+	Quota string
+	// Key is who the command counts against, e.g. the customer
+	Key   string
+	Limit int
+	// Used is how much of the quota the key had used before the command
+	Used      int
+	Requested int
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%s quota of %s exceeded: %d of %d used, %d requested", e.Quota, e.Key, e.Used, e.Limit, e.Requested)
+}
+
+func (e *QuotaExceededError) Is(target error) bool   { return target == common.ErrInvalidCommand }
+func (e *QuotaExceededError) Code() common.ErrorCode { return CodeQuotaExceeded }
+
+// Checker decides whether a command fits within a quota, failing with a
+// *QuotaExceededError if it does not
+type Checker interface {
+	Check(ctx context.Context, command common.Command) error
+}
+
+// CheckerFunc adapts a function to a Checker
+type CheckerFunc func(ctx context.Context, command common.Command) error
+
+// Check calls f
+func (f CheckerFunc) Check(ctx context.Context, command common.Command) error {
+	return f(ctx, command)
+}
+
+// Middleware returns middleware rejecting commands any of checkers refuses, in
+// order, before they reach their handler
+func Middleware(checkers ...Checker) bus.Middleware {
+	return func(next bus.Handler) bus.Handler {
+		return func(ctx context.Context, command common.Command) (*common.Event, error) {
+			for _, checker := range checkers {
+				if err := checker.Check(ctx, command); err != nil {
+					return nil, err
+				}
+			}
+			return next(ctx, command)
+		}
+	}
+}
+
+// UsageFunc returns how much of a quota key has used at now
+type UsageFunc func(ctx context.Context, key string, now time.Time) (int, error)
+
+// Limit is a Checker allowing each key to use up to Max of a quota
+type Limit struct {
+	// Quota names the limit in errors, e.g. "open-carts"
+	Quota string
+	// Max is the quota of keys without an entitlement; 0 allows nothing
+	Max int
+	// Key returns who command counts against; commands returning "" are not limited
+	Key func(ctx context.Context, command common.Command) string
+	// Cost returns how much of the quota command uses; commands costing 0 are not
+	// limited. Nil costs every command 1.
+	Cost func(command common.Command) int
+	// Usage reads what a key has used, usually from a projection
+	Usage UsageFunc
+	// Entitlement, when set, returns a key's own quota, e.g. from its plan, which
+	// replaces Max if ok
+	Entitlement func(ctx context.Context, key string) (max int, ok bool)
+	// Now returns the current time; nil uses time.Now
+	Now func() time.Time
+}
+
+// Check fails with a *QuotaExceededError if command would take its key over the
+// quota
+func (l Limit) Check(ctx context.Context, command common.Command) error {
+	key := l.Key(ctx, command)
+	if key == "" {
+		return nil
+	}
+	cost := 1
+	if l.Cost != nil {
+		cost = l.Cost(command)
+	}
+	if cost <= 0 {
+		return nil
+	}
+
+	max := l.Max
+	if l.Entitlement != nil {
+		if entitled, ok := l.Entitlement(ctx, key); ok {
+			max = entitled
+		}
+	}
+	now := time.Now
+	if l.Now != nil {
+		now = l.Now
+	}
+	used, err := l.Usage(ctx, key, now())
+	if err != nil {
+		return fmt.Errorf("reading %s quota of %s: %w", l.Quota, key, err)
+	}
+	if used+cost > max {
+		return &QuotaExceededError{Quota: l.Quota, Key: key, Limit: max, Used: used, Requested: cost}
+	}
+	return nil
+}
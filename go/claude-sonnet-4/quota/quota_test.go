@@ -0,0 +1,80 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+)
+
+type orderCommand struct{ Lines int }
+
+func (c *orderCommand) CommandType() string { return "PlaceOrder" }
+func (c *orderCommand) AggregateID() string { return "" }
+
+func TestLimit_RejectsCommandsOverQuota(t *testing.T) {
+	used := map[string]int{"alice": 3, "bob": 3}
+	limit := Limit{
+		Quota: "order-lines",
+		Max:   5,
+		Key: func(ctx context.Context, _ common.Command) string {
+			principal, _ := bus.PrincipalFrom(ctx)
+			return principal.ID
+		},
+		Cost: func(command common.Command) int { return command.(*orderCommand).Lines },
+		Usage: func(_ context.Context, key string, _ time.Time) (int, error) {
+			return used[key], nil
+		},
+		Entitlement: func(_ context.Context, key string) (int, bool) {
+			return 10, key == "bob"
+		},
+	}
+	commands := bus.NewCommandBus(Middleware(limit))
+	handled := 0
+	commands.Register("PlaceOrder", func(context.Context, common.Command) (*common.Event, error) {
+		handled++
+		return common.NewEvent("OrderPlaced", "order-1", 1, nil, nil), nil
+	})
+	alice := bus.WithPrincipal(context.Background(), bus.Principal{ID: "alice"})
+	bob := bus.WithPrincipal(context.Background(), bus.Principal{ID: "bob"})
+
+	if _, err := commands.Dispatch(alice, &orderCommand{Lines: 2}); err != nil {
+		t.Errorf("Expected a command within the quota to be accepted, got %v", err)
+	}
+	_, err := commands.Dispatch(alice, &orderCommand{Lines: 3})
+	var exceeded *QuotaExceededError
+	if !errors.As(err, &exceeded) || exceeded.Key != "alice" || exceeded.Limit != 5 || exceeded.Used != 3 || exceeded.Requested != 3 {
+		t.Fatalf("Expected a *QuotaExceededError, got %v", err)
+	}
+	if !errors.Is(err, common.ErrInvalidCommand) || common.ErrorCodeOf(err) != CodeQuotaExceeded {
+		t.Errorf("Expected an invalid command coded %s, got %v", CodeQuotaExceeded, err)
+	}
+	if _, err := commands.Dispatch(bob, &orderCommand{Lines: 3}); err != nil {
+		t.Errorf("Expected an entitlement to raise the quota, got %v", err)
+	}
+	if _, err := commands.Dispatch(context.Background(), &orderCommand{Lines: 50}); err != nil {
+		t.Errorf("Expected commands without a key not to be limited, got %v", err)
+	}
+	if _, err := commands.Dispatch(alice, &orderCommand{}); err != nil {
+		t.Errorf("Expected commands costing nothing not to be limited, got %v", err)
+	}
+	if handled != 4 {
+		t.Errorf("Expected 4 commands handled, got %d", handled)
+	}
+}
+
+func TestLimit_ReportsUsageErrors(t *testing.T) {
+	unavailable := errors.New("read model unavailable")
+	limit := Limit{
+		Quota: "orders",
+		Max:   1,
+		Key:   func(context.Context, common.Command) string { return "alice" },
+		Usage: func(context.Context, string, time.Time) (int, error) { return 0, unavailable },
+	}
+	if err := limit.Check(context.Background(), &orderCommand{}); !errors.Is(err, unavailable) {
+		t.Errorf("Expected the usage error, got %v", err)
+	}
+}
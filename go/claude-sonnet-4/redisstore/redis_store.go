@@ -0,0 +1,278 @@
+// Package redisstore provides a durable Store backend on Redis Streams. Each aggregate
+// stream maps to a Redis stream holding one entry per event, and a second Redis stream
+// records every event in append order for GetAllEvents. Appends run as a Lua script so
+// the expected-version check and both writes happen atomically on the server, which
+// gives several processes sharing one Redis optimistic concurrency control.
+//
+// It is a lightweight option for demos and small services; the package speaks the
+// Redis protocol itself and needs no client library.
+//
+// Entries hold the event as JSON unless the store is opened WithSerializer; every
+// entry names its format, so entries written with different serializers can be
+// read side by side.
+package redisstore
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"simple-event-modeling/common"
+	"simple-event-modeling/serialization"
+)
+
+// DefaultPrefix namespaces the keys written by the store
+const DefaultPrefix = "sem"
+
+// appendScript appends an event if its stream is at the expected version.
+// KEYS: stream, global log, stream ID set. ARGV: expected version, encoded event, stream ID.
+// Versions start at 1 and have no gaps, so a stream's version is its length.
+const appendScript = `
+local current = redis.call('XLEN', KEYS[1])
+if current ~= tonumber(ARGV[1]) then
+  return redis.error_reply('CONFLICT ' .. current)
+end
+redis.call('XADD', KEYS[1], '*', 'event', ARGV[2])
+redis.call('XADD', KEYS[2], '*', 'event', ARGV[2])
+redis.call('SADD', KEYS[3], ARGV[3])
+return current + 1
+`
+
+// RedisStore is a Store backed by Redis Streams
+type RedisStore struct {
+	conn   *conn
+	prefix string
+	codec  serialization.Codec
+}
+
+var _ common.Store = (*RedisStore)(nil)
+
+type config struct {
+	prefix     string
+	password   string
+	db         int
+	timeout    time.Duration
+	ioTimeout  time.Duration
+	serializer serialization.Serializer
+}
+
+// Option configures a RedisStore
+type Option func(*config)
+
+// WithPrefix namespaces keys with prefix instead of DefaultPrefix, so several
+// stores can share one Redis database
+func WithPrefix(prefix string) Option {
+	return func(c *config) {
+		c.prefix = prefix
+	}
+}
+
+// WithPassword authenticates with the server
+func WithPassword(password string) Option {
+	return func(c *config) {
+		c.password = password
+	}
+}
+
+// WithDB selects a numbered Redis database
+func WithDB(db int) Option {
+	return func(c *config) {
+		c.db = db
+	}
+}
+
+// WithDialTimeout limits how long connecting to the server may take (default 5s)
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.timeout = timeout
+	}
+}
+
+// WithIOTimeout limits how long a command may take to be sent and answered
+// (default 5s). A command running out of time fails, and the next one reconnects.
+func WithIOTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.ioTimeout = timeout
+	}
+}
+
+// WithSerializer encodes events with serializer instead of JSON. Entries already in
+// the store are read whatever their format, as long as the serialization registry
+// knows it.
+func WithSerializer(serializer serialization.Serializer) Option {
+	return func(c *config) {
+		c.serializer = serializer
+	}
+}
+
+// Open connects to the Redis server at addr, e.g. "localhost:6379". The store
+// reconnects by itself after the connection fails.
+func Open(addr string, opts ...Option) (*RedisStore, error) {
+	cfg := config{prefix: DefaultPrefix, timeout: 5 * time.Second, ioTimeout: 5 * time.Second}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	var setup [][]string
+	if cfg.password != "" {
+		setup = append(setup, []string{"AUTH", cfg.password})
+	}
+	if cfg.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(cfg.db)})
+	}
+	dial := func() (net.Conn, error) {
+		return net.DialTimeout("tcp", addr, cfg.timeout)
+	}
+	c := newConn(dial, cfg.ioTimeout, setup...)
+	if _, err := c.do("PING"); err != nil {
+		c.close()
+		return nil, err
+	}
+	return &RedisStore{conn: c, prefix: cfg.prefix, codec: serialization.NewCodec(cfg.serializer, nil)}, nil
+}
+
+// Close closes the connection to the server
+func (rs *RedisStore) Close() error {
+	return rs.conn.close()
+}
+
+// Ping checks that the server answers
+func (rs *RedisStore) Ping() error {
+	_, err := rs.conn.do("PING")
+	return err
+}
+
+// Append adds an event to its stream and the global log. The event must directly
+// follow the stream's current version, otherwise a *common.ConcurrencyError is returned.
+func (rs *RedisStore) Append(event *common.Event) error {
+	data, err := rs.codec.Encode(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = rs.conn.do("EVAL", appendScript, "3",
+		rs.streamKey(event.AggregateID), rs.allKey(), rs.streamsKey(),
+		strconv.Itoa(event.Version-1), string(data), event.AggregateID)
+	if replyErr, ok := err.(redisError); ok && strings.HasPrefix(string(replyErr), "CONFLICT ") {
+		actual, _ := strconv.Atoi(strings.TrimPrefix(string(replyErr), "CONFLICT "))
+		return &common.ConcurrencyError{StreamID: event.AggregateID, Expected: event.Version - 1, Actual: actual}
+	}
+	return err
+}
+
+// GetStream retrieves all events for a given aggregate ID
+func (rs *RedisStore) GetStream(aggregateID string) ([]*common.Event, error) {
+	events, err := rs.readRange(rs.streamKey(aggregateID))
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, &common.StreamNotFoundError{StreamID: aggregateID}
+	}
+	return events, nil
+}
+
+// GetStreamVersion returns the current version of a stream
+func (rs *RedisStore) GetStreamVersion(aggregateID string) int {
+	reply, err := rs.conn.do("XLEN", rs.streamKey(aggregateID))
+	if err != nil {
+		return 0
+	}
+	length, _ := reply.(int64)
+	return int(length)
+}
+
+// StreamLength returns the number of events in a stream, which is its version
+func (rs *RedisStore) StreamLength(aggregateID string) int {
+	return rs.GetStreamVersion(aggregateID)
+}
+
+// HeadEvent reads only the last entry of a stream
+func (rs *RedisStore) HeadEvent(aggregateID string) (*common.Event, error) {
+	key := rs.streamKey(aggregateID)
+	reply, err := rs.conn.do("XREVRANGE", key, "+", "-", "COUNT", "1")
+	if err != nil {
+		return nil, err
+	}
+	events, err := rs.decodeEntries(key, reply)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, &common.StreamNotFoundError{StreamID: aggregateID}
+	}
+	return events[0], nil
+}
+
+// GetAllEvents returns every event in append order
+func (rs *RedisStore) GetAllEvents() []*common.Event {
+	events, _ := rs.readRange(rs.allKey())
+	return events
+}
+
+// StreamIDs returns the identifiers of all streams in the store, sorted
+func (rs *RedisStore) StreamIDs() []string {
+	reply, err := rs.conn.do("SMEMBERS", rs.streamsKey())
+	if err != nil {
+		return nil
+	}
+	members, _ := reply.([]interface{})
+	ids := make([]string, 0, len(members))
+	for _, member := range members {
+		if id, ok := member.(string); ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// readRange decodes every entry of a Redis stream
+func (rs *RedisStore) readRange(key string) ([]*common.Event, error) {
+	reply, err := rs.conn.do("XRANGE", key, "-", "+")
+	if err != nil {
+		return nil, err
+	}
+	return rs.decodeEntries(key, reply)
+}
+
+// decodeEntries decodes the events of an XRANGE or XREVRANGE reply on key
+func (rs *RedisStore) decodeEntries(key string, reply interface{}) ([]*common.Event, error) {
+	entries, _ := reply.([]interface{})
+	events := make([]*common.Event, 0, len(entries))
+	for _, entry := range entries {
+		// Each entry is [id, [field, value, ...]]
+		parts, _ := entry.([]interface{})
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("malformed entry in %s", key)
+		}
+		fields, _ := parts[1].([]interface{})
+		for i := 0; i+1 < len(fields); i += 2 {
+			value, ok := fields[i+1].(string)
+			if fields[i] != "event" || !ok {
+				continue
+			}
+			event, err := rs.codec.Decode([]byte(value))
+			if err != nil {
+				return nil, fmt.Errorf("decoding entry %v in %s: %w", parts[0], key, err)
+			}
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (rs *RedisStore) streamKey(aggregateID string) string {
+	return rs.prefix + ":stream:" + aggregateID
+}
+
+func (rs *RedisStore) allKey() string {
+	return rs.prefix + ":all"
+}
+
+func (rs *RedisStore) streamsKey() string {
+	return rs.prefix + ":streams"
+}
@@ -0,0 +1,246 @@
+package redisstore
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"simple-event-modeling/common"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis serves the handful of commands the store sends. EVAL runs the append
+// script's logic in Go rather than interpreting Lua.
+type fakeRedis struct {
+	mu      sync.Mutex
+	streams map[string][]string
+	sets    map[string]map[string]bool
+	conns   []net.Conn
+	// stall makes the server read commands without answering them
+	stall bool
+}
+
+func startFakeRedis(t *testing.T) string {
+	_, addr := startFake(t)
+	return addr
+}
+
+func startFake(t *testing.T) (*fakeRedis, string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	fake := &fakeRedis{streams: make(map[string][]string), sets: make(map[string]map[string]bool)}
+	go func() {
+		for {
+			c, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			fake.mu.Lock()
+			fake.conns = append(fake.conns, c)
+			fake.mu.Unlock()
+			go fake.serve(c)
+		}
+	}()
+	return fake, listener.Addr().String()
+}
+
+// drop closes the connections accepted so far, as a server restart would
+func (f *fakeRedis) drop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.conns {
+		c.Close()
+	}
+	f.conns = nil
+}
+
+func (f *fakeRedis) serve(c net.Conn) {
+	defer c.Close()
+	reader := bufio.NewReader(c)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		args := make([]string, n)
+		for i := range args {
+			header, _ := reader.ReadString('\n')
+			size, _ := strconv.Atoi(strings.TrimSpace(header[1:]))
+			arg := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, arg); err != nil {
+				return
+			}
+			args[i] = string(arg[:size])
+		}
+		f.mu.Lock()
+		stall := f.stall
+		f.mu.Unlock()
+		if !stall {
+			fmt.Fprint(c, f.handle(args))
+		}
+	}
+}
+
+func (f *fakeRedis) handle(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "XLEN":
+		return fmt.Sprintf(":%d\r\n", len(f.streams[args[1]]))
+	case "SMEMBERS":
+		reply := fmt.Sprintf("*%d\r\n", len(f.sets[args[1]]))
+		for member := range f.sets[args[1]] {
+			reply += bulk(member)
+		}
+		return reply
+	case "XRANGE":
+		entries := f.streams[args[1]]
+		reply := fmt.Sprintf("*%d\r\n", len(entries))
+		for i, entry := range entries {
+			reply += "*2\r\n" + bulk(fmt.Sprintf("%d-0", i+1)) + "*2\r\n" + bulk("event") + bulk(entry)
+		}
+		return reply
+	case "XREVRANGE":
+		// Only the store's "+ - COUNT 1" form, reading the last entry
+		entries := f.streams[args[1]]
+		if len(entries) == 0 {
+			return "*0\r\n"
+		}
+		last := len(entries)
+		return "*1\r\n*2\r\n" + bulk(fmt.Sprintf("%d-0", last)) + "*2\r\n" + bulk("event") + bulk(entries[last-1])
+	case "EVAL":
+		keys, argv := args[3:6], args[6:]
+		current := len(f.streams[keys[0]])
+		if expected, _ := strconv.Atoi(argv[0]); expected != current {
+			return fmt.Sprintf("-CONFLICT %d\r\n", current)
+		}
+		f.streams[keys[0]] = append(f.streams[keys[0]], argv[1])
+		f.streams[keys[1]] = append(f.streams[keys[1]], argv[1])
+		if f.sets[keys[2]] == nil {
+			f.sets[keys[2]] = make(map[string]bool)
+		}
+		f.sets[keys[2]][argv[2]] = true
+		return fmt.Sprintf(":%d\r\n", current+1)
+	default:
+		return "-ERR unknown command '" + args[0] + "'\r\n"
+	}
+}
+
+func bulk(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+// testStore returns a store against $SEM_REDIS_ADDR when set, or the fake server
+func testStore(t *testing.T) *RedisStore {
+	t.Helper()
+	addr := os.Getenv("SEM_REDIS_ADDR")
+	if addr == "" {
+		addr = startFakeRedis(t)
+	}
+	store, err := Open(addr, WithPrefix(fmt.Sprintf("sem-test-%d", time.Now().UnixNano())))
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestRedisStore_AppendAndRead(t *testing.T) {
+	store := testStore(t)
+	if err := store.Ping(); err != nil {
+		t.Fatalf("Error pinging server: %v", err)
+	}
+	store.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+	store.Append(common.NewEvent("ItemAdded", "cart-1", 2, map[string]interface{}{"item": "apple"}, nil))
+	store.Append(common.NewEvent("CartCreated", "cart-2", 1, nil, nil))
+
+	if version := store.GetStreamVersion("cart-1"); version != 2 {
+		t.Errorf("Expected cart-1 version 2, got %d", version)
+	}
+	events, err := store.GetStream("cart-1")
+	if err != nil {
+		t.Fatalf("Error getting stream: %v", err)
+	}
+	if len(events) != 2 || events[1].Data["item"] != "apple" {
+		t.Errorf("Expected item data to round-trip, got %v", events)
+	}
+	if all := store.GetAllEvents(); len(all) != 3 || all[2].AggregateID != "cart-2" {
+		t.Errorf("Expected 3 events in append order, got %v", all)
+	}
+	if head, err := store.HeadEvent("cart-1"); err != nil || head.Version != 2 || store.StreamLength("cart-1") != 2 {
+		t.Errorf("Expected cart-1 headed by version 2 of 2 events, got %v, %v", head, err)
+	}
+	if _, err := store.HeadEvent("cart-9"); !errors.Is(err, common.ErrStreamNotFound) || store.StreamLength("cart-9") != 0 {
+		t.Errorf("Expected StreamNotFoundError for a stream without events, got %v", err)
+	}
+	if ids := store.StreamIDs(); len(ids) != 2 || ids[0] != "cart-1" {
+		t.Errorf("Unexpected stream IDs: %v", ids)
+	}
+
+	var notFound *common.StreamNotFoundError
+	if _, err := store.GetStream("missing"); !errors.As(err, &notFound) {
+		t.Errorf("Expected StreamNotFoundError, got %v", err)
+	}
+}
+
+func TestRedisStore_RejectsConflictingAppend(t *testing.T) {
+	store := testStore(t)
+	store.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+
+	err := store.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+	var conflict *common.ConcurrencyError
+	if !errors.As(err, &conflict) || conflict.Actual != 1 || conflict.Expected != 0 {
+		t.Errorf("Expected ConcurrencyError at version 1, got %v", err)
+	}
+	if len(store.GetAllEvents()) != 1 {
+		t.Error("Expected the rejected event not to be stored")
+	}
+}
+
+func TestRedisStore_Reconnects(t *testing.T) {
+	fake, addr := startFake(t)
+	store, err := Open(addr, WithIOTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+	defer store.Close()
+	store.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+
+	fake.drop()
+	store.Ping() // may fail on the dropped connection
+	if err := store.Ping(); err != nil {
+		t.Fatalf("Expected the store to reconnect after the connection dropped, got %v", err)
+	}
+
+	fake.mu.Lock()
+	fake.stall = true
+	fake.mu.Unlock()
+	started := time.Now()
+	if err := store.Ping(); err == nil || time.Since(started) > time.Second {
+		t.Fatalf("Expected the stalled command to time out, got %v after %v", err, time.Since(started))
+	}
+	fake.mu.Lock()
+	fake.stall = false
+	fake.mu.Unlock()
+	if err := store.Ping(); err != nil {
+		t.Errorf("Expected a fresh connection after the timeout, got %v", err)
+	}
+	if version := store.GetStreamVersion("cart-1"); version != 1 {
+		t.Errorf("Expected cart-1 at version 1 after reconnecting, got %d", version)
+	}
+}
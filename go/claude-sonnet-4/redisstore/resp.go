@@ -0,0 +1,192 @@
+package redisstore
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// redisError is an error reply sent by the server
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// conn is a minimal client for the Redis serialization protocol (RESP2). Commands
+// are sent one at a time; the store needs nothing more, so the package gets by
+// without a client library.
+//
+// Every command must complete within the I/O timeout. An I/O or protocol error
+// leaves the connection in an unknown state, e.g. with half a reply unread, so it
+// is closed and the next command dials a new one, replaying the setup commands
+// (AUTH, SELECT). The failed command is not retried, since it may have run.
+type conn struct {
+	dial      func() (net.Conn, error)
+	setup     [][]string
+	ioTimeout time.Duration
+
+	mu      sync.Mutex
+	netConn net.Conn
+	reader  *bufio.Reader
+	writer  *bufio.Writer
+	closed  bool
+}
+
+func newConn(dial func() (net.Conn, error), ioTimeout time.Duration, setup ...[]string) *conn {
+	return &conn{dial: dial, setup: setup, ioTimeout: ioTimeout}
+}
+
+// errConnClosed is returned by commands sent after close
+var errConnClosed = errors.New("redis connection is closed")
+
+// do sends a command and returns its reply: a string for simple and bulk strings,
+// an int64 for integers, an []interface{} for arrays, and nil for null replies.
+// Error replies are returned as redisError.
+func (c *conn) do(args ...string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, errConnClosed
+	}
+	if c.netConn == nil {
+		if err := c.connect(); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := c.roundTrip(args)
+	if err != nil {
+		c.disconnect()
+		return nil, err
+	}
+	if replyErr, ok := reply.(redisError); ok {
+		return nil, replyErr
+	}
+	return reply, nil
+}
+
+// connect dials the server and runs the setup commands. Callers hold c.mu.
+func (c *conn) connect() error {
+	netConn, err := c.dial()
+	if err != nil {
+		return err
+	}
+	c.netConn = netConn
+	c.reader = bufio.NewReader(netConn)
+	c.writer = bufio.NewWriter(netConn)
+	for _, args := range c.setup {
+		reply, err := c.roundTrip(args)
+		if err == nil {
+			if replyErr, ok := reply.(redisError); ok {
+				err = replyErr
+			}
+		}
+		if err != nil {
+			c.disconnect()
+			return err
+		}
+	}
+	return nil
+}
+
+// roundTrip writes a command and reads its reply within the I/O timeout. Callers
+// hold c.mu.
+func (c *conn) roundTrip(args []string) (interface{}, error) {
+	if c.ioTimeout > 0 {
+		if err := c.netConn.SetDeadline(time.Now().Add(c.ioTimeout)); err != nil {
+			return nil, err
+		}
+	}
+	fmt.Fprintf(c.writer, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.writer, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := c.writer.Flush(); err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+// disconnect drops a connection in an unknown state. Callers hold c.mu.
+func (c *conn) disconnect() {
+	if c.netConn != nil {
+		c.netConn.Close()
+		c.netConn, c.reader, c.writer = nil, nil, nil
+	}
+}
+
+func (c *conn) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	if c.netConn == nil {
+		return nil
+	}
+	err := c.netConn.Close()
+	c.netConn, c.reader, c.writer = nil, nil, nil
+	return err
+}
+
+func (c *conn) readReply() (interface{}, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return redisError(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			item, err := c.readReply()
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unexpected reply %q", line)
+	}
+}
+
+// readLine reads a CRLF-terminated line without the terminator
+func (c *conn) readLine() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("malformed reply line %q", line)
+	}
+	return line[:len(line)-2], nil
+}
@@ -0,0 +1,166 @@
+// Package replication copies the events of a primary store to a follower store in
+// the background, so read replicas, e.g. in another region, can serve projections
+// and queries without load on the primary.
+//
+// A Replicator tails the primary's global log from its checkpoint and appends each
+// event, unchanged, to the follower, which must receive no other writes. The
+// follower's log is then a prefix of the primary's in the same order, which the
+// replicator checks: a follower holding an event the primary does not have at that
+// position has diverged, e.g. after being written to directly or pointed at the
+// wrong primary, and replication stops with a *DivergenceError rather than mixing
+// the two histories. Deleting a stream from the primary is not replicated, so it
+// makes the follower diverge too.
+//
+// Reads from a follower lag the primary; callers needing their own writes pass a
+// consistency token (see common.ConsistencyToken), which replica-backed queries turn
+// into a *common.StaleReadError while the write has not arrived yet.
+package replication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"simple-event-modeling/common"
+)
+
+// ErrDiverged is matched by *DivergenceError
+var ErrDiverged = errors.New("follower diverged from primary")
+
+// DivergenceError reports the first position at which the follower's log differs
+// from the primary's
+type DivergenceError struct {
+	// Position is the 1-based index in the follower's global log
+	Position int
+	// PrimaryEventID is empty when the primary has no event at Position
+	PrimaryEventID  string
+	FollowerEventID string
+}
+
+func (e *DivergenceError) Error() string {
+	if e.PrimaryEventID == "" {
+		return fmt.Sprintf("follower diverged from primary: event %s at position %d is not in the primary", e.FollowerEventID, e.Position)
+	}
+	return fmt.Sprintf("follower diverged from primary at position %d: primary has event %s, follower has %s", e.Position, e.PrimaryEventID, e.FollowerEventID)
+}
+
+func (e *DivergenceError) Is(target error) bool { return target == ErrDiverged }
+
+// Replicator applies the events of a primary store to a follower store, following
+// the primary's global log with a common.Follower
+type Replicator struct {
+	primary  common.Store
+	follower common.Store
+	tail     *common.Follower
+
+	mu       sync.Mutex
+	verified bool
+	// copied is the length of the follower's log as of the last pass
+	copied int
+}
+
+// NewReplicator creates a replicator from primary to follower. It resumes after the
+// events the follower already holds, once Verify has checked them.
+func NewReplicator(name string, primary, follower common.Store) *Replicator {
+	return &Replicator{primary: primary, follower: follower, tail: common.NewFollower(name, primary)}
+}
+
+// Name returns the name of the replicator
+func (r *Replicator) Name() string {
+	return r.tail.Name()
+}
+
+// Checkpoint returns the position of the last event applied to the follower
+func (r *Replicator) Checkpoint() common.Checkpoint {
+	return r.tail.Checkpoint()
+}
+
+// OnProgress sets a function called after every Process, e.g. to record metrics
+func (r *Replicator) OnProgress(progress common.ProgressFunc) {
+	r.tail.OnProgress(progress)
+}
+
+// Verify compares the follower's whole log with the primary's, failing with a
+// *DivergenceError unless it is a prefix of it, and moves the checkpoint to the end
+// of the follower's log
+func (r *Replicator) Verify() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.verify()
+}
+
+func (r *Replicator) verify() error {
+	primary := r.primary.GetAllEvents()
+	follower := r.follower.GetAllEvents()
+	for i, event := range follower {
+		if i >= len(primary) {
+			return &DivergenceError{Position: i + 1, FollowerEventID: event.ID}
+		}
+		if primary[i].ID != event.ID {
+			return &DivergenceError{Position: i + 1, PrimaryEventID: primary[i].ID, FollowerEventID: event.ID}
+		}
+	}
+	position := 0
+	if len(follower) > 0 {
+		position = common.LogPosition(primary, len(follower)-1)
+	}
+	r.tail.SetCheckpoint(position)
+	r.copied = len(follower)
+	r.verified = true
+	return nil
+}
+
+// Process applies the events appended to the primary since the checkpoint and
+// returns how many were applied. The first call verifies the follower.
+func (r *Replicator) Process(ctx context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Something else changed the follower since the last pass: recheck it, resuming
+	// from its end if it is still a prefix of the primary
+	if !r.verified || len(r.follower.GetAllEvents()) != r.copied {
+		if err := r.verify(); err != nil {
+			r.tail.Report(0, err)
+			return 0, err
+		}
+	}
+
+	conflicted := false
+	applied, err := r.tail.Process(1, func(batch []*common.Event, positions []int) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// The follower stamps its own positions, if any
+		copied := *batch[0]
+		copied.Position = 0
+		if err := r.follower.Append(&copied); err != nil {
+			var conflict *common.ConcurrencyError
+			conflicted = errors.As(err, &conflict)
+			return fmt.Errorf("%s: event %d (%s): %w", r.Name(), positions[0], copied.Type, err)
+		}
+		r.copied++
+		return nil
+	})
+	if conflicted {
+		// Find out whether the follower was written to; if not, the next pass
+		// resumes from where it ends
+		r.verified = false
+		if err := r.verify(); err != nil {
+			return applied, err
+		}
+	}
+	return applied, err
+}
+
+// Run applies new events every interval until ctx is cancelled. Divergence stops
+// replication and is returned; other errors are retried on the next tick.
+func (r *Replicator) Run(ctx context.Context, interval time.Duration) error {
+	return common.Poll(ctx, interval, func() error {
+		if _, err := r.Process(ctx); errors.Is(err, ErrDiverged) {
+			return err
+		}
+		return nil
+	})
+}
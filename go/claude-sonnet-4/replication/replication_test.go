@@ -0,0 +1,79 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"simple-event-modeling/common"
+)
+
+func seed(store common.Store, events ...*common.Event) {
+	for _, event := range events {
+		store.Append(event)
+	}
+}
+
+func TestReplicator_Process(t *testing.T) {
+	primary, follower := common.NewEventStore(), common.NewEventStore()
+	seed(primary,
+		common.NewEvent("CartCreated", "cart-1", 1, nil, nil),
+		common.NewEvent("CartCreated", "cart-2", 1, nil, nil),
+		common.NewEvent("ItemAdded", "cart-1", 2, map[string]interface{}{"item": "apple"}, nil),
+	)
+
+	replicator := NewReplicator("eu-west", primary, follower)
+	var reported common.Checkpoint
+	replicator.OnProgress(func(checkpoint common.Checkpoint, _ int, _ error) { reported = checkpoint })
+	if applied, err := replicator.Process(context.Background()); err != nil || applied != 3 {
+		t.Fatalf("Expected 3 events applied, got %d (%v)", applied, err)
+	}
+	seed(primary, common.NewEvent("ItemAdded", "cart-2", 2, nil, nil))
+	if applied, err := replicator.Process(context.Background()); err != nil || applied != 1 || reported.Position != 4 {
+		t.Fatalf("Expected the new event applied, got %d (%v) at %+v", applied, err, reported)
+	}
+
+	primaryLog, followerLog := primary.GetAllEvents(), follower.GetAllEvents()
+	if len(followerLog) != len(primaryLog) {
+		t.Fatalf("Expected %d events on the follower, got %d", len(primaryLog), len(followerLog))
+	}
+	for i := range primaryLog {
+		if followerLog[i].ID != primaryLog[i].ID || followerLog[i].Version != primaryLog[i].Version {
+			t.Errorf("Position %d: expected %s, got %s", i+1, primaryLog[i].ID, followerLog[i].ID)
+		}
+	}
+
+	// A new replicator resumes after the events the follower holds
+	resumed := NewReplicator("eu-west", primary, follower)
+	if applied, err := resumed.Process(context.Background()); err != nil || applied != 0 || resumed.Checkpoint().Position != 4 {
+		t.Errorf("Expected the replicator to resume at 4, got %d (%v)", applied, err)
+	}
+}
+
+func TestReplicator_DetectsDivergence(t *testing.T) {
+	primary, follower := common.NewEventStore(), common.NewEventStore()
+	created := common.NewEvent("CartCreated", "cart-1", 1, nil, nil)
+	seed(primary, created)
+	replicator := NewReplicator("eu-west", primary, follower)
+	replicator.Process(context.Background())
+
+	// Written to directly, the follower holds an event the primary lacks
+	rogue := common.NewEvent("ItemAdded", "cart-1", 2, nil, nil)
+	seed(follower, rogue)
+	seed(primary, common.NewEvent("CartCleared", "cart-1", 2, nil, nil))
+
+	_, err := replicator.Process(context.Background())
+	var diverged *DivergenceError
+	if !errors.As(err, &diverged) || !errors.Is(err, ErrDiverged) {
+		t.Fatalf("Expected a divergence error, got %v", err)
+	}
+	if diverged.Position != 2 || diverged.FollowerEventID != rogue.ID || diverged.PrimaryEventID == "" {
+		t.Errorf("Expected divergence at position 2, got %+v", diverged)
+	}
+
+	other := common.NewEventStore()
+	seed(other, common.NewEvent("CartCreated", "cart-9", 1, nil, nil))
+	if err := NewReplicator("wrong", primary, other).Verify(); !errors.Is(err, ErrDiverged) {
+		t.Errorf("Expected a follower of another primary to diverge, got %v", err)
+	}
+}
@@ -0,0 +1,22 @@
+// Package reservations registers the ledger command handlers with a command bus.
+package reservations
+
+import (
+	"context"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+)
+
+// RegisterCommands registers a handler for every reservation command.
+// Each command is handled by a fresh ledger hydrated from the store, so a bus
+// using bus.RetryOnConflict re-runs a losing reservation against the winner's hold.
+func RegisterCommands(commands *bus.CommandBus, store common.Store) {
+	handle := func(_ context.Context, command common.Command) (*common.Event, error) {
+		return NewLedger(store).Handle(command)
+	}
+	commands.Register(CommandTypeReserve, handle)
+	commands.Register(CommandTypeConfirmReservation, handle)
+	commands.Register(CommandTypeCancelReservation, handle)
+	commands.Register(CommandTypeExpireReservation, handle)
+}
@@ -0,0 +1,51 @@
+// Package reservations provides command types for reservations. Commands are simple
+// record structures; their only methods expose the routing metadata required by
+// common.Command.
+package reservations
+
+import "time"
+
+// ReserveCommand holds units of a resource for an owner until the hold is
+// confirmed, cancelled, or expires. The reservation ID is generated.
+type ReserveCommand struct {
+	Resource string `json:"resource" validate:"required"`
+	// Owner identifies who holds the units, e.g. the ID of an order
+	Owner    string `json:"owner" validate:"required"`
+	Quantity int    `json:"quantity" validate:"positive"`
+	// Limit is the number of units the resource has. Callers pass the same limit for
+	// every reservation of a resource, e.g. the stock on hand of a product.
+	Limit int `json:"limit" validate:"positive"`
+	// HoldFor is how long the hold lasts unconfirmed; zero uses DefaultHoldDuration
+	HoldFor time.Duration `json:"hold_for,omitempty"`
+}
+
+// ConfirmReservationCommand keeps the units of a held reservation for good
+type ConfirmReservationCommand struct {
+	Resource      string `json:"resource" validate:"required"`
+	ReservationID string `json:"reservation_id" validate:"required"`
+}
+
+// CancelReservationCommand frees the units of a held or confirmed reservation
+type CancelReservationCommand struct {
+	Resource      string `json:"resource" validate:"required"`
+	ReservationID string `json:"reservation_id" validate:"required"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+// ExpireReservationCommand frees the units of a hold that was not confirmed in time
+type ExpireReservationCommand struct {
+	Resource      string `json:"resource" validate:"required"`
+	ReservationID string `json:"reservation_id" validate:"required"`
+}
+
+func (c *ReserveCommand) AggregateID() string { return StreamID(c.Resource) }
+func (c *ReserveCommand) CommandType() string { return CommandTypeReserve }
+
+func (c *ConfirmReservationCommand) AggregateID() string { return StreamID(c.Resource) }
+func (c *ConfirmReservationCommand) CommandType() string { return CommandTypeConfirmReservation }
+
+func (c *CancelReservationCommand) AggregateID() string { return StreamID(c.Resource) }
+func (c *CancelReservationCommand) CommandType() string { return CommandTypeCancelReservation }
+
+func (c *ExpireReservationCommand) AggregateID() string { return StreamID(c.Resource) }
+func (c *ExpireReservationCommand) CommandType() string { return CommandTypeExpireReservation }
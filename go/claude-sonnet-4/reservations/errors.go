@@ -0,0 +1,61 @@
+// Package reservations provides the structured rejections of reservation commands.
+// They all match common.ErrInvalidCommand with errors.Is and carry the rule in
+// their code.
+package reservations
+
+import (
+	"fmt"
+
+	"simple-event-modeling/common"
+)
+
+// Rejection codes of reservation commands
+const (
+	CodeCapacityExceeded     common.ErrorCode = "capacity_exceeded"
+	CodeReservationNotFound  common.ErrorCode = "reservation_not_found"
+	CodeReservationConfirmed common.ErrorCode = "reservation_confirmed"
+)
+
+// CapacityExceededError rejects a reservation that would take the units held and
+// confirmed for a resource past its limit
+type CapacityExceededError struct {
+	Resource  string
+	Limit     int
+	Reserved  int
+	Requested int
+}
+
+func (e *CapacityExceededError) Error() string {
+	return fmt.Sprintf("cannot reserve %d of %s: %d of %d already reserved", e.Requested, e.Resource, e.Reserved, e.Limit)
+}
+
+func (e *CapacityExceededError) Is(target error) bool   { return target == common.ErrInvalidCommand }
+func (e *CapacityExceededError) Code() common.ErrorCode { return CodeCapacityExceeded }
+
+// ReservationNotFoundError rejects a command for a reservation that was never made,
+// or was cancelled or expired
+type ReservationNotFoundError struct {
+	Resource      string
+	ReservationID string
+}
+
+func (e *ReservationNotFoundError) Error() string {
+	return fmt.Sprintf("reservation %s of %s is not active", e.ReservationID, e.Resource)
+}
+
+func (e *ReservationNotFoundError) Is(target error) bool   { return target == common.ErrInvalidCommand }
+func (e *ReservationNotFoundError) Code() common.ErrorCode { return CodeReservationNotFound }
+
+// ReservationConfirmedError rejects confirming or expiring a reservation that is
+// already confirmed
+type ReservationConfirmedError struct {
+	Resource      string
+	ReservationID string
+}
+
+func (e *ReservationConfirmedError) Error() string {
+	return fmt.Sprintf("reservation %s of %s is already confirmed", e.ReservationID, e.Resource)
+}
+
+func (e *ReservationConfirmedError) Is(target error) bool   { return target == common.ErrInvalidCommand }
+func (e *ReservationConfirmedError) Code() common.ErrorCode { return CodeReservationConfirmed }
@@ -0,0 +1,79 @@
+// Package reservations provides event types and creation functions for reservations.
+// Events are simple record structures with no behaviors.
+package reservations
+
+import (
+	"strings"
+	"time"
+
+	"simple-event-modeling/common"
+)
+
+// Event type constants
+const (
+	EventTypeResourceReserved     = "ResourceReserved"
+	EventTypeReservationConfirmed = "ReservationConfirmed"
+	EventTypeReservationCancelled = "ReservationCancelled"
+	EventTypeReservationExpired   = "ReservationExpired"
+)
+
+// StreamID returns the ID of the stream holding the reservations of a resource
+func StreamID(resource string) string {
+	return Category + "-" + resource
+}
+
+// ResourceOf returns the resource whose reservations a stream holds
+func ResourceOf(streamID string) string {
+	return strings.TrimPrefix(streamID, Category+"-")
+}
+
+// ReservationData is the payload of reservation events
+type ReservationData struct {
+	ReservationID string `json:"reservation_id"`
+	// Owner identifies who holds the units, e.g. the ID of an order
+	Owner string `json:"owner"`
+	// Quantity and ExpiresAt are set on ResourceReserved events
+	Quantity  int        `json:"quantity,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Reason is why a reservation was cancelled
+	Reason string `json:"reason,omitempty"`
+}
+
+// NewResourceReservedEvent creates a new ResourceReserved event
+func NewResourceReservedEvent(streamID string, version int, reservationID, owner string, quantity int, expiresAt time.Time) *common.Event {
+	data := map[string]interface{}{
+		"reservation_id": reservationID,
+		"owner":          owner,
+		"quantity":       quantity,
+		"expires_at":     expiresAt.UTC().Format(time.RFC3339Nano),
+	}
+	return common.NewEvent(EventTypeResourceReserved, streamID, version, data, nil)
+}
+
+// NewReservationConfirmedEvent creates a new ReservationConfirmed event
+func NewReservationConfirmedEvent(streamID string, version int, reservationID, owner string) *common.Event {
+	data := map[string]interface{}{
+		"reservation_id": reservationID,
+		"owner":          owner,
+	}
+	return common.NewEvent(EventTypeReservationConfirmed, streamID, version, data, nil)
+}
+
+// NewReservationCancelledEvent creates a new ReservationCancelled event
+func NewReservationCancelledEvent(streamID string, version int, reservationID, owner, reason string) *common.Event {
+	data := map[string]interface{}{
+		"reservation_id": reservationID,
+		"owner":          owner,
+		"reason":         reason,
+	}
+	return common.NewEvent(EventTypeReservationCancelled, streamID, version, data, nil)
+}
+
+// NewReservationExpiredEvent creates a new ReservationExpired event
+func NewReservationExpiredEvent(streamID string, version int, reservationID, owner string) *common.Event {
+	data := map[string]interface{}{
+		"reservation_id": reservationID,
+		"owner":          owner,
+	}
+	return common.NewEvent(EventTypeReservationExpired, streamID, version, data, nil)
+}
@@ -0,0 +1,49 @@
+// Package reservations provides the process manager that expires unconfirmed holds.
+package reservations
+
+import (
+	"context"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+	"simple-event-modeling/process"
+	"simple-event-modeling/scheduler"
+)
+
+// TimeoutStream is the stream hold expiry timers fire into
+const TimeoutStream = "$reservation-timeouts"
+
+// HoldExpiredTimer is the name of the timer scheduled for every hold
+const HoldExpiredTimer = "ReservationHoldExpired"
+
+// NewExpiryManager creates a process manager that schedules a timer for every new
+// hold and expires the hold when the timer elapses. Timers of holds that were
+// confirmed or cancelled in the meantime still fire; their expiry is rejected by
+// the ledger and skipped by the process manager.
+func NewExpiryManager(store common.Store, commands *bus.CommandBus, timers *scheduler.Scheduler) *process.Manager {
+	m := process.NewManager("reservation-expiry", store, commands)
+
+	m.On(EventTypeResourceReserved, func(_ context.Context, event *common.Event) ([]common.Command, error) {
+		reserved, err := common.AsTyped[ReservationData](event)
+		if err != nil || reserved.Data.ExpiresAt == nil {
+			// A hold without an expiry is kept until confirmed or cancelled
+			return nil, err
+		}
+		_, err = timers.Schedule(TimeoutStream, HoldExpiredTimer, *reserved.Data.ExpiresAt, map[string]interface{}{
+			"resource":       ResourceOf(event.AggregateID),
+			"reservation_id": reserved.Data.ReservationID,
+		})
+		return nil, err
+	})
+
+	m.On(scheduler.EventTypeTimeoutElapsed, func(_ context.Context, event *common.Event) ([]common.Command, error) {
+		if event.AggregateID != TimeoutStream || event.Data["name"] != HoldExpiredTimer {
+			return nil, nil
+		}
+		resource, _ := event.Data["resource"].(string)
+		reservationID, _ := event.Data["reservation_id"].(string)
+		return []common.Command{&ExpireReservationCommand{Resource: resource, ReservationID: reservationID}}, nil
+	})
+
+	return m
+}
@@ -0,0 +1,209 @@
+// Package reservations provides the Ledger aggregate guarding the units of a resource.
+package reservations
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"simple-event-modeling/common"
+
+	"github.com/google/uuid"
+)
+
+// DefaultHoldDuration is how long units stay held unconfirmed before the hold expires
+const DefaultHoldDuration = 10 * time.Minute
+
+// Reservation is an active reservation of a resource
+type Reservation struct {
+	ID       string
+	Owner    string
+	Quantity int
+	// ExpiresAt is when an unconfirmed hold expires, zero if it never does
+	ExpiresAt time.Time
+	Confirmed bool
+}
+
+// Ledger guards the units of one resource. Held and confirmed reservations count
+// against the resource's limit; cancelled and expired ones free their units.
+type Ledger struct {
+	*common.BaseAggregate
+	now          func() time.Time
+	reservations map[string]*Reservation
+}
+
+// NewLedger creates a new ledger aggregate
+func NewLedger(store common.Store) *Ledger {
+	return &Ledger{
+		BaseAggregate: common.NewBaseAggregate(store),
+		now:           time.Now,
+		reservations:  make(map[string]*Reservation),
+	}
+}
+
+// Resource returns the resource the ledger guards
+func (l *Ledger) Resource() string {
+	return ResourceOf(l.ID())
+}
+
+// Reserved returns the number of units held or confirmed
+func (l *Ledger) Reserved() int {
+	reserved := 0
+	for _, reservation := range l.reservations {
+		reserved += reservation.Quantity
+	}
+	return reserved
+}
+
+// Reservation returns an active reservation
+func (l *Ledger) Reservation(id string) (Reservation, bool) {
+	reservation, ok := l.reservations[id]
+	if !ok {
+		return Reservation{}, false
+	}
+	return *reservation, true
+}
+
+// OwnedBy returns the active reservations of an owner, sorted by ID
+func (l *Ledger) OwnedBy(owner string) []Reservation {
+	out := make([]Reservation, 0)
+	for _, reservation := range l.Reservations() {
+		if reservation.Owner == owner {
+			out = append(out, reservation)
+		}
+	}
+	return out
+}
+
+// Reservations returns the active reservations, sorted by ID
+func (l *Ledger) Reservations() []Reservation {
+	out := make([]Reservation, 0, len(l.reservations))
+	for _, reservation := range l.reservations {
+		out = append(out, *reservation)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// Handle processes commands and returns resulting events
+func (l *Ledger) Handle(command common.Command) (*common.Event, error) {
+	if !l.IsLive() {
+		if err := l.Hydrate(command.AggregateID()); err != nil {
+			return nil, err
+		}
+	}
+
+	var event *common.Event
+	var err error
+	switch cmd := command.(type) {
+	case *ReserveCommand:
+		event, err = l.handleReserve(cmd)
+	case *ConfirmReservationCommand:
+		event, err = l.handleConfirm(cmd)
+	case *CancelReservationCommand:
+		event, err = l.handleCancel(cmd)
+	case *ExpireReservationCommand:
+		event, err = l.handleExpire(cmd)
+	default:
+		return nil, &common.UnknownCommandError{
+			CommandType: command.CommandType(),
+			Registered:  common.DefaultRegistry.CommandTypes(AggregateTypeLedger),
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := l.On(event); err != nil {
+		return nil, err
+	}
+	if err := l.Store().Append(event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// On applies events to aggregate state
+func (l *Ledger) On(event *common.Event) error {
+	typed, err := common.AsTyped[ReservationData](event)
+	if err != nil {
+		return err
+	}
+	data := typed.Data
+	switch event.Type {
+	case EventTypeResourceReserved:
+		if event.Version == 1 {
+			l.Begin(event)
+		}
+		reservation := &Reservation{ID: data.ReservationID, Owner: data.Owner, Quantity: data.Quantity}
+		if data.ExpiresAt != nil {
+			reservation.ExpiresAt = *data.ExpiresAt
+		}
+		l.reservations[data.ReservationID] = reservation
+	case EventTypeReservationConfirmed:
+		if reservation, ok := l.reservations[data.ReservationID]; ok {
+			reservation.Confirmed = true
+		}
+	case EventTypeReservationCancelled, EventTypeReservationExpired:
+		delete(l.reservations, data.ReservationID)
+	default:
+		return errors.New("unhandled event type: " + event.Type)
+	}
+	return l.Advance(event)
+}
+
+// Hydrate rebuilds the aggregate state from its event stream
+func (l *Ledger) Hydrate(id string) error {
+	return l.BaseAggregate.Hydrate(id, l.On)
+}
+
+// Command handlers
+
+func (l *Ledger) handleReserve(cmd *ReserveCommand) (*common.Event, error) {
+	// Business rule: held and confirmed units never exceed the limit
+	if reserved := l.Reserved(); reserved+cmd.Quantity > cmd.Limit {
+		return nil, &CapacityExceededError{Resource: cmd.Resource, Limit: cmd.Limit, Reserved: reserved, Requested: cmd.Quantity}
+	}
+
+	holdFor := cmd.HoldFor
+	if holdFor <= 0 {
+		holdFor = DefaultHoldDuration
+	}
+	return NewResourceReservedEvent(StreamID(cmd.Resource), l.Version()+1, uuid.New().String(), cmd.Owner, cmd.Quantity, l.now().Add(holdFor)), nil
+}
+
+func (l *Ledger) handleConfirm(cmd *ConfirmReservationCommand) (*common.Event, error) {
+	reservation, err := l.held(cmd.Resource, cmd.ReservationID)
+	if err != nil {
+		return nil, err
+	}
+	return NewReservationConfirmedEvent(l.ID(), l.Version()+1, cmd.ReservationID, reservation.Owner), nil
+}
+
+func (l *Ledger) handleCancel(cmd *CancelReservationCommand) (*common.Event, error) {
+	reservation, ok := l.reservations[cmd.ReservationID]
+	if !ok {
+		return nil, &ReservationNotFoundError{Resource: cmd.Resource, ReservationID: cmd.ReservationID}
+	}
+	return NewReservationCancelledEvent(l.ID(), l.Version()+1, cmd.ReservationID, reservation.Owner, cmd.Reason), nil
+}
+
+func (l *Ledger) handleExpire(cmd *ExpireReservationCommand) (*common.Event, error) {
+	reservation, err := l.held(cmd.Resource, cmd.ReservationID)
+	if err != nil {
+		return nil, err
+	}
+	return NewReservationExpiredEvent(l.ID(), l.Version()+1, cmd.ReservationID, reservation.Owner), nil
+}
+
+// held returns a reservation that is active and not yet confirmed
+func (l *Ledger) held(resource, reservationID string) (*Reservation, error) {
+	reservation, ok := l.reservations[reservationID]
+	if !ok {
+		return nil, &ReservationNotFoundError{Resource: resource, ReservationID: reservationID}
+	}
+	if reservation.Confirmed {
+		return nil, &ReservationConfirmedError{Resource: resource, ReservationID: reservationID}
+	}
+	return reservation, nil
+}
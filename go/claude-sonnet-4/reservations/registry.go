@@ -0,0 +1,73 @@
+// Package reservations registers the ledger with the common registry so tooling can
+// discover its commands, events, and aggregate.
+package reservations
+
+import "simple-event-modeling/common"
+
+// AggregateTypeLedger is the registered name of the ledger aggregate
+const AggregateTypeLedger = "ReservationLedger"
+
+// Category is the stream category of ledgers, one stream per resource
+const Category = "resource"
+
+// Command type names
+const (
+	CommandTypeReserve            = "Reserve"
+	CommandTypeConfirmReservation = "ConfirmReservation"
+	CommandTypeCancelReservation  = "CancelReservation"
+	CommandTypeExpireReservation  = "ExpireReservation"
+)
+
+func init() {
+	register(common.DefaultRegistry)
+}
+
+func register(registry *common.Registry) {
+	registry.RegisterAggregate(common.AggregateInfo{Name: AggregateTypeLedger})
+	registry.RegisterCategory(common.CategoryInfo{Name: Category, Aggregate: AggregateTypeLedger})
+
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeReserve,
+		Aggregate: AggregateTypeLedger,
+		Produces:  []string{EventTypeResourceReserved},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeConfirmReservation,
+		Aggregate: AggregateTypeLedger,
+		Produces:  []string{EventTypeReservationConfirmed},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeCancelReservation,
+		Aggregate: AggregateTypeLedger,
+		Produces:  []string{EventTypeReservationCancelled},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeExpireReservation,
+		Aggregate: AggregateTypeLedger,
+		Produces:  []string{EventTypeReservationExpired},
+	})
+
+	reservationField := common.FieldInfo{Name: "reservation_id", Type: "string", Description: "ID of the reservation"}
+	ownerField := common.FieldInfo{Name: "owner", Type: "string", Description: "Who holds the units, e.g. an order ID"}
+	registry.RegisterEvent(common.EventInfo{
+		Name:      EventTypeResourceReserved,
+		Aggregate: AggregateTypeLedger,
+		Payload: []common.FieldInfo{
+			reservationField,
+			ownerField,
+			{Name: "quantity", Type: "integer", Description: "Number of units held"},
+			{Name: "expires_at", Type: "string", Description: "RFC 3339 time the hold expires unless confirmed"},
+		},
+	})
+	registry.RegisterEvent(common.EventInfo{Name: EventTypeReservationConfirmed, Aggregate: AggregateTypeLedger, Payload: []common.FieldInfo{reservationField, ownerField}})
+	registry.RegisterEvent(common.EventInfo{
+		Name:      EventTypeReservationCancelled,
+		Aggregate: AggregateTypeLedger,
+		Payload: []common.FieldInfo{
+			reservationField,
+			ownerField,
+			{Name: "reason", Type: "string", Description: "Why the reservation was cancelled"},
+		},
+	})
+	registry.RegisterEvent(common.EventInfo{Name: EventTypeReservationExpired, Aggregate: AggregateTypeLedger, Payload: []common.FieldInfo{reservationField, ownerField}})
+}
@@ -0,0 +1,27 @@
+// Package reservations provides a two-phase reservation pattern for invariants that
+// span aggregates, such as usernames that must be unique or stock that concurrent
+// orders must not oversell. An aggregate cannot see the state of others, so instead
+// of checking such an invariant itself it reserves units of a shared resource:
+//
+//  1. Reserve holds units of a resource for an owner, and is rejected with a
+//     *CapacityExceededError when the resource's limit would be passed
+//  2. The owner records its own events, then confirms the reservation, or cancels it
+//     when the operation is abandoned; cancelling frees the units again
+//  3. The expiry manager expires holds that are neither confirmed nor cancelled when
+//     their hold duration elapses, so an owner that never comes back frees them too
+//
+// Each resource is a Ledger with its own stream, "resource-<resource>". Every
+// reservation of a resource goes through that stream, so two owners racing for the
+// last units conflict on append and bus.RetryOnConflict re-runs the loser against
+// the winner's hold. A uniqueness constraint is a resource with a limit of 1, e.g.
+// "username:alice".
+//
+// The package is organized into separate files for each major concept:
+// - commands.go: Command types (Reserve, ConfirmReservation, CancelReservation, ExpireReservation)
+// - events.go: Event types and creation functions (ResourceReserved, ReservationConfirmed, etc.)
+// - ledger.go: Ledger aggregate enforcing a resource's limit
+// - errors.go: Structured rejections of reservation commands
+// - expiry.go: Process manager expiring unconfirmed holds
+// - registry.go: Registration with the common registry
+// - bus.go: Registration of command handlers with a command bus
+package reservations
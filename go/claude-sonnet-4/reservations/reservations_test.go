@@ -0,0 +1,156 @@
+package reservations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+	"simple-event-modeling/scheduler"
+)
+
+func newBus(store common.Store) *bus.CommandBus {
+	commands := bus.NewCommandBus(bus.RetryOnConflict(20), bus.Validation())
+	RegisterCommands(commands, store)
+	return commands
+}
+
+func reserve(t *testing.T, commands *bus.CommandBus, resource, owner string, quantity, limit int) string {
+	t.Helper()
+	event, err := commands.Dispatch(context.Background(), &ReserveCommand{Resource: resource, Owner: owner, Quantity: quantity, Limit: limit})
+	if err != nil {
+		t.Fatalf("Error reserving %d of %s: %v", quantity, resource, err)
+	}
+	return event.Data["reservation_id"].(string)
+}
+
+func TestLedger_UniqueResourceUnderContention(t *testing.T) {
+	store := common.NewEventStore()
+	commands := newBus(store)
+
+	const owners = 10
+	var wg sync.WaitGroup
+	errs := make([]error, owners)
+	for i := 0; i < owners; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = commands.Dispatch(context.Background(), &ReserveCommand{Resource: "username:alice", Owner: "user", Quantity: 1, Limit: 1})
+		}(i)
+	}
+	wg.Wait()
+
+	reserved, rejected := 0, 0
+	for _, err := range errs {
+		var exceeded *CapacityExceededError
+		switch {
+		case err == nil:
+			reserved++
+		case errors.As(err, &exceeded) && errors.Is(err, common.ErrInvalidCommand):
+			rejected++
+		default:
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+	if reserved != 1 || rejected != owners-1 {
+		t.Errorf("Expected exactly one reservation, got %d reserved and %d rejected", reserved, rejected)
+	}
+}
+
+func TestLedger_QuotaFreedByCancel(t *testing.T) {
+	store := common.NewEventStore()
+	commands := newBus(store)
+	ctx := context.Background()
+
+	first := reserve(t, commands, "sku-1", "order-1", 3, 5)
+	_, err := commands.Dispatch(ctx, &ReserveCommand{Resource: "sku-1", Owner: "order-2", Quantity: 3, Limit: 5})
+	var exceeded *CapacityExceededError
+	if !errors.As(err, &exceeded) || exceeded.Reserved != 3 || common.ErrorCodeOf(err) != CodeCapacityExceeded {
+		t.Fatalf("Expected the second reservation to exceed the limit, got %v", err)
+	}
+
+	if _, err := commands.Dispatch(ctx, &ConfirmReservationCommand{Resource: "sku-1", ReservationID: first}); err != nil {
+		t.Fatalf("Error confirming: %v", err)
+	}
+	var confirmed *ReservationConfirmedError
+	if _, err := commands.Dispatch(ctx, &ConfirmReservationCommand{Resource: "sku-1", ReservationID: first}); !errors.As(err, &confirmed) {
+		t.Errorf("Expected a second confirmation to be rejected, got %v", err)
+	}
+	if _, err := commands.Dispatch(ctx, &CancelReservationCommand{Resource: "sku-1", ReservationID: first, Reason: "returned"}); err != nil {
+		t.Fatalf("Error cancelling a confirmed reservation: %v", err)
+	}
+	reserve(t, commands, "sku-1", "order-2", 3, 5)
+
+	ledger := NewLedger(store)
+	if err := ledger.Hydrate(StreamID("sku-1")); err != nil {
+		t.Fatalf("Error hydrating: %v", err)
+	}
+	if ledger.Resource() != "sku-1" || ledger.Reserved() != 3 || len(ledger.OwnedBy("order-2")) != 1 || len(ledger.OwnedBy("order-1")) != 0 {
+		t.Errorf("Expected only order-2 to hold units, got %+v", ledger.Reservations())
+	}
+	var notFound *ReservationNotFoundError
+	if _, err := commands.Dispatch(ctx, &CancelReservationCommand{Resource: "sku-1", ReservationID: first}); !errors.As(err, &notFound) {
+		t.Errorf("Expected a cancelled reservation to be gone, got %v", err)
+	}
+}
+
+func TestExpiryManager_ExpiresUnconfirmedHolds(t *testing.T) {
+	store := common.NewEventStore()
+	commands := newBus(store)
+	now := time.Now()
+	timers, err := scheduler.New(store, scheduler.WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatalf("Error creating scheduler: %v", err)
+	}
+	expiry := NewExpiryManager(store, commands, timers)
+	ctx := context.Background()
+
+	confirmed := reserve(t, commands, "sku-1", "order-1", 1, 2)
+	reserve(t, commands, "sku-1", "order-2", 1, 2)
+	if _, err := commands.Dispatch(ctx, &ConfirmReservationCommand{Resource: "sku-1", ReservationID: confirmed}); err != nil {
+		t.Fatalf("Error confirming: %v", err)
+	}
+	if _, err := expiry.Process(ctx); err != nil {
+		t.Fatalf("Error processing: %v", err)
+	}
+	if pending := timers.Pending(); len(pending) != 2 {
+		t.Fatalf("Expected a timer per hold, got %d", len(pending))
+	}
+
+	now = now.Add(DefaultHoldDuration + time.Second)
+	if fired, _ := timers.FireDue(); fired != 2 {
+		t.Fatalf("Expected both timers to fire, got %d", fired)
+	}
+	if _, err := expiry.Process(ctx); err != nil {
+		t.Fatalf("Error processing timeouts: %v", err)
+	}
+
+	ledger := NewLedger(store)
+	ledger.Hydrate(StreamID("sku-1"))
+	if held := ledger.Reservations(); len(held) != 1 || held[0].ID != confirmed || !held[0].Confirmed {
+		t.Errorf("Expected only the confirmed reservation to remain, got %+v", held)
+	}
+	stream, _ := store.GetStream(StreamID("sku-1"))
+	if last := stream[len(stream)-1]; last.Type != EventTypeReservationExpired || last.Data["owner"] != "order-2" {
+		t.Errorf("Expected order-2's hold to expire, got %s %v", last.Type, last.Data)
+	}
+}
+
+func TestReservationData_ExpiresAt(t *testing.T) {
+	data, _ := json.Marshal(ReservationData{ReservationID: "r-1", Owner: "order-1"})
+	if strings.Contains(string(data), "expires_at") {
+		t.Errorf("Expected no expires_at without an expiry, got %s", data)
+	}
+
+	expiresAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	event := NewResourceReservedEvent(StreamID("room-1"), 1, "r-1", "order-1", 1, expiresAt)
+	reserved, err := common.AsTyped[ReservationData](event)
+	if err != nil || reserved.Data.ExpiresAt == nil || !reserved.Data.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("Expected the expiry to round-trip, got %+v (%v)", reserved.Data, err)
+	}
+}
@@ -0,0 +1,307 @@
+// Package rules hosts lightweight automations declared as "when X then Y" rules,
+// for reactions too small to deserve a process manager:
+//
+//	engine.Add("almost-full", rules.When(cart.EventTypeItemAdded).
+//		If(cart.ItemCountIs(store, cart.MaxItems)).
+//		Then(notifyAlmostFull))
+//
+// An Engine follows the global event log and fires every rule whose event type
+// matches and whose conditions hold, dispatching the commands its action returns.
+//
+// Firings are recorded as RuleFired events in a stream of the store, keyed by rule
+// and event, and an engine never fires a rule twice for the same event, even when
+// it restarts from an older checkpoint. An engine stopping after a rule's commands
+// were dispatched but before the firing was recorded fires it again, so delivery
+// is at least once; actions that must not repeat dispatch idempotent commands.
+package rules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+)
+
+// DefaultStream is the stream rule firings are recorded in
+const DefaultStream = "$rules"
+
+// EventTypeRuleFired is the type of the events recording rule firings
+const EventTypeRuleFired = "RuleFired"
+
+// Condition decides whether a rule fires for an event
+type Condition func(ctx context.Context, event *common.Event) (bool, error)
+
+// Action returns the commands a rule dispatches when it fires
+type Action func(ctx context.Context, event *common.Event) ([]common.Command, error)
+
+// Rule reacts to events of one type, firing its action when all its conditions
+// hold
+type Rule struct {
+	eventType  string
+	conditions []Condition
+	action     Action
+}
+
+// When starts a rule reacting to events of a type
+func When(eventType string) *Rule {
+	return &Rule{eventType: eventType}
+}
+
+// If adds a condition to the rule; a rule with several conditions fires when all
+// of them hold
+func (r *Rule) If(condition Condition) *Rule {
+	r.conditions = append(r.conditions, condition)
+	return r
+}
+
+// Then sets the action of the rule
+func (r *Rule) Then(action Action) *Rule {
+	r.action = action
+	return r
+}
+
+// EventType returns the event type the rule reacts to
+func (r *Rule) EventType() string {
+	return r.eventType
+}
+
+// matches reports whether all conditions of the rule hold for event
+func (r *Rule) matches(ctx context.Context, event *common.Event) (bool, error) {
+	for _, condition := range r.conditions {
+		ok, err := condition(ctx, event)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// DataEquals is a condition holding for events whose Data[key] equals value
+func DataEquals(key string, value interface{}) Condition {
+	return func(_ context.Context, event *common.Event) (bool, error) {
+		return event.Data[key] == value, nil
+	}
+}
+
+// Not is a condition holding when condition does not
+func Not(condition Condition) Condition {
+	return func(ctx context.Context, event *common.Event) (bool, error) {
+		ok, err := condition(ctx, event)
+		return !ok, err
+	}
+}
+
+// FeatureEnabled is a condition holding while a feature flag is on for the tenant
+// an event concerns, e.g. to roll an automation out tenant by tenant. A nil tenant
+// function checks the flag for the "" tenant.
+func FeatureEnabled(flags common.FeatureFlags, flag string, tenant func(event *common.Event) string) Condition {
+	return func(ctx context.Context, event *common.Event) (bool, error) {
+		var tenantID string
+		if tenant != nil {
+			tenantID = tenant(event)
+		}
+		return flags.Enabled(ctx, flag, tenantID), nil
+	}
+}
+
+// Engine fires rules in reaction to the global event log (see common.Follower).
+// Commands rejected by a
+// business rule (common.ErrInvalidCommand) or by a closed aggregate
+// (common.ErrAggregateClosed) count as fired, as they do for process managers; any
+// other error stops processing so the event is retried on the next run.
+//
+// The checkpoint is kept in memory; the firings recorded in the store keep rules
+// from firing twice when an engine starts over.
+type Engine struct {
+	name     string
+	store    common.Store
+	follower *common.Follower
+	commands *bus.CommandBus
+	stream   string
+
+	mu    sync.Mutex
+	rules map[string]*Rule
+	order []string
+	fired map[string]bool
+}
+
+// Option configures an Engine
+type Option func(*Engine)
+
+// WithStream records firings in the given stream instead of DefaultStream
+func WithStream(streamID string) Option {
+	return func(e *Engine) {
+		e.stream = streamID
+	}
+}
+
+// NewEngine creates an engine dispatching to the command bus and restores the
+// firings recorded in the store
+func NewEngine(name string, store common.Store, commands *bus.CommandBus, opts ...Option) (*Engine, error) {
+	e := &Engine{
+		name:     name,
+		store:    store,
+		follower: common.NewFollower(name, store),
+		commands: commands,
+		stream:   DefaultStream,
+		rules:    make(map[string]*Rule),
+		fired:    make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if err := e.load(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Name returns the name of the engine
+func (e *Engine) Name() string {
+	return e.name
+}
+
+// Add registers a rule under a name identifying its firings. Names must be unique
+// and stable across restarts.
+func (e *Engine) Add(name string, rule *Rule) error {
+	if rule.eventType == "" || rule.action == nil {
+		return fmt.Errorf("rule %s needs an event type and an action", name)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, exists := e.rules[name]; exists {
+		return fmt.Errorf("rule %s is already registered", name)
+	}
+	e.rules[name] = rule
+	e.order = append(e.order, name)
+	return nil
+}
+
+// Consumes returns the event types the rules react to
+func (e *Engine) Consumes() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var types []string
+	for _, name := range e.order {
+		if eventType := e.rules[name].eventType; !seen[eventType] {
+			seen[eventType] = true
+			types = append(types, eventType)
+		}
+	}
+	return types
+}
+
+// Fired reports whether a rule has fired for an event
+func (e *Engine) Fired(rule, eventID string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.fired[firingKey(rule, eventID)]
+}
+
+// Checkpoint returns the position of the last processed event
+func (e *Engine) Checkpoint() common.Checkpoint {
+	return e.follower.Checkpoint()
+}
+
+// OnProgress sets a function called after every Process, e.g. to record metrics
+func (e *Engine) OnProgress(progress common.ProgressFunc) {
+	e.follower.OnProgress(progress)
+}
+
+// SetCheckpoint resumes processing after the given position
+func (e *Engine) SetCheckpoint(position int) {
+	e.follower.SetCheckpoint(position)
+}
+
+// Process fires the rules matching the events appended since the checkpoint and
+// returns how many events were processed
+func (e *Engine) Process(ctx context.Context) (int, error) {
+	return e.follower.Process(1, func(batch []*common.Event, positions []int) error {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+
+		event := batch[0]
+		for _, name := range e.order {
+			rule := e.rules[name]
+			if rule.eventType != event.Type || e.fired[firingKey(name, event.ID)] {
+				continue
+			}
+			if err := e.fire(ctx, name, rule, event); err != nil {
+				return fmt.Errorf("%s: rule %s: event %d (%s): %w", e.name, name, positions[0], event.Type, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Run processes new events every interval until ctx is cancelled. Errors are retried
+// on the next tick.
+func (e *Engine) Run(ctx context.Context, interval time.Duration) {
+	common.Poll(ctx, interval, func() error {
+		e.Process(ctx)
+		return nil
+	})
+}
+
+// fire dispatches the commands of a rule whose conditions hold for event and
+// records the firing
+func (e *Engine) fire(ctx context.Context, name string, rule *Rule, event *common.Event) error {
+	ok, err := rule.matches(ctx, event)
+	if err != nil || !ok {
+		return err
+	}
+	commands, err := rule.action(ctx, event)
+	if err != nil {
+		return err
+	}
+	for _, command := range commands {
+		_, err := e.commands.Dispatch(ctx, command)
+		if err != nil && !errors.Is(err, common.ErrInvalidCommand) && !errors.Is(err, common.ErrAggregateClosed) {
+			return err
+		}
+	}
+
+	if err := e.store.Append(common.NewEvent(EventTypeRuleFired, e.stream, e.store.GetStreamVersion(e.stream)+1, map[string]interface{}{
+		"engine":   e.name,
+		"rule":     name,
+		"event_id": event.ID,
+		"commands": len(commands),
+	}, nil)); err != nil {
+		return fmt.Errorf("recording firing: %w", err)
+	}
+	e.fired[firingKey(name, event.ID)] = true
+	return nil
+}
+
+// load restores the firings of this engine from the firing stream
+func (e *Engine) load() error {
+	events, err := e.store.GetStream(e.stream)
+	if err != nil {
+		var notFound *common.StreamNotFoundError
+		if errors.As(err, &notFound) {
+			return nil
+		}
+		return err
+	}
+
+	for _, event := range events {
+		if event.Type != EventTypeRuleFired || event.Data["engine"] != e.name {
+			continue
+		}
+		rule, _ := event.Data["rule"].(string)
+		eventID, _ := event.Data["event_id"].(string)
+		e.fired[firingKey(rule, eventID)] = true
+	}
+	return nil
+}
+
+func firingKey(rule, eventID string) string {
+	return rule + "/" + eventID
+}
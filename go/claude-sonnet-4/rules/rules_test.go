@@ -0,0 +1,146 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+	"testing"
+)
+
+type notifyCommand struct {
+	OrderID string
+}
+
+func (c *notifyCommand) AggregateID() string { return c.OrderID }
+func (c *notifyCommand) CommandType() string { return "Notify" }
+
+func newNotifyBus(notified *[]string) *bus.CommandBus {
+	commands := bus.NewCommandBus()
+	commands.Register("Notify", func(_ context.Context, command common.Command) (*common.Event, error) {
+		if command.AggregateID() == "rejected" {
+			return nil, &common.InvalidCommandError{Message: "already notified"}
+		}
+		*notified = append(*notified, command.AggregateID())
+		return nil, nil
+	})
+	return commands
+}
+
+func bigOrder() *Rule {
+	return When("OrderPlaced").
+		If(func(_ context.Context, event *common.Event) (bool, error) {
+			total, _ := event.Data["total"].(int)
+			return total >= 100, nil
+		}).
+		Then(func(_ context.Context, event *common.Event) ([]common.Command, error) {
+			return []common.Command{&notifyCommand{OrderID: event.AggregateID}}, nil
+		})
+}
+
+func TestEngine_FiresOncePerEvent(t *testing.T) {
+	store := common.NewEventStore()
+	var notified []string
+	commands := newNotifyBus(&notified)
+	ctx := context.Background()
+
+	store.Append(common.NewEvent("OrderPlaced", "o-1", 1, map[string]interface{}{"total": 150}, nil))
+	store.Append(common.NewEvent("OrderPlaced", "o-2", 1, map[string]interface{}{"total": 20}, nil))
+	store.Append(common.NewEvent("OrderShipped", "o-1", 2, nil, nil))
+	store.Append(common.NewEvent("OrderPlaced", "rejected", 1, map[string]interface{}{"total": 500}, nil))
+
+	engine, err := NewEngine("order-automations", store, commands)
+	if err != nil {
+		t.Fatalf("Error creating engine: %v", err)
+	}
+	if err := engine.Add("big-order", bigOrder()); err != nil {
+		t.Fatalf("Error adding rule: %v", err)
+	}
+	if err := engine.Add("big-order", bigOrder()); err == nil {
+		t.Error("Expected a duplicate rule name to be rejected")
+	}
+	if err := engine.Add("incomplete", When("OrderPlaced")); err == nil {
+		t.Error("Expected a rule without an action to be rejected")
+	}
+
+	if processed, err := engine.Process(ctx); err != nil || processed != 4 {
+		t.Fatalf("Expected 4 processed events, got %d (%v)", processed, err)
+	}
+	if len(notified) != 1 || notified[0] != "o-1" {
+		t.Fatalf("Expected one notification for o-1, got %v", notified)
+	}
+	fired, _ := store.GetStream(DefaultStream)
+	if len(fired) != 2 || fired[0].Data["rule"] != "big-order" {
+		t.Fatalf("Expected both firings to be recorded, got %+v", fired)
+	}
+
+	// A new engine starting over does not fire the rule again
+	restarted, err := NewEngine("order-automations", store, commands)
+	if err != nil {
+		t.Fatalf("Error restoring engine: %v", err)
+	}
+	restarted.Add("big-order", bigOrder())
+	if _, err := restarted.Process(ctx); err != nil {
+		t.Fatalf("Error processing: %v", err)
+	}
+	if len(notified) != 1 {
+		t.Errorf("Expected the rule not to fire again, got %v", notified)
+	}
+	placed, _ := store.GetStream("o-1")
+	if !restarted.Fired("big-order", placed[0].ID) {
+		t.Error("Expected the firing for o-1 to be restored")
+	}
+}
+
+func TestEngine_RetriesFailedActions(t *testing.T) {
+	store := common.NewEventStore()
+	engine, _ := NewEngine("flaky", store, bus.NewCommandBus())
+	failing := true
+	fired := 0
+	engine.Add("vip-order", When("OrderPlaced").
+		If(DataEquals("tier", "vip")).
+		If(Not(DataEquals("test", true))).
+		Then(func(context.Context, *common.Event) ([]common.Command, error) {
+			if failing {
+				return nil, errors.New("downstream unavailable")
+			}
+			fired++
+			return nil, nil
+		}))
+	store.Append(common.NewEvent("OrderPlaced", "o-1", 1, map[string]interface{}{"tier": "vip"}, nil))
+	store.Append(common.NewEvent("OrderPlaced", "o-2", 1, map[string]interface{}{"tier": "basic"}, nil))
+	store.Append(common.NewEvent("OrderPlaced", "o-3", 1, map[string]interface{}{"tier": "vip", "test": true}, nil))
+
+	if _, err := engine.Process(context.Background()); err == nil {
+		t.Fatal("Expected the action error to be returned")
+	}
+	if engine.Checkpoint().Position != 0 {
+		t.Errorf("Expected the failed event to be retried, checkpoint is %d", engine.Checkpoint().Position)
+	}
+
+	failing = false
+	if processed, err := engine.Process(context.Background()); err != nil || processed != 3 {
+		t.Fatalf("Expected the retry to succeed, got %d (%v)", processed, err)
+	}
+	if fired != 1 {
+		t.Errorf("Expected the rule to fire for o-1 only, fired %d times", fired)
+	}
+}
+
+func TestFeatureEnabled(t *testing.T) {
+	flags := common.NewStaticFeatureFlags()
+	flags.Enable("automations", "acme")
+	condition := FeatureEnabled(flags, "automations", func(event *common.Event) string {
+		tenant, _ := event.Metadata["tenant"].(string)
+		return tenant
+	})
+
+	acme := common.NewEvent("OrderPlaced", "o-1", 1, nil, map[string]interface{}{"tenant": "acme"})
+	globex := common.NewEvent("OrderPlaced", "o-2", 1, nil, map[string]interface{}{"tenant": "globex"})
+	if ok, _ := condition(context.Background(), acme); !ok {
+		t.Error("Expected the condition to hold for acme")
+	}
+	if ok, _ := condition(context.Background(), globex); ok {
+		t.Error("Expected the condition not to hold for globex")
+	}
+}
@@ -0,0 +1,178 @@
+// Package scaffold generates the skeleton of a new domain package following the layout
+// of the cart package: commands, events, an aggregate, a query, registry wiring, and tests.
+package scaffold
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// Spec describes the domain to generate
+type Spec struct {
+	// Name is the domain name, used as the package name (e.g. "order")
+	Name string
+	// Module is the import path of the module containing common; detected from go.mod when empty
+	Module string
+	// Commands are the command names without the "Command" suffix (e.g. "Create", "AddLine")
+	Commands []string
+	// Events are the event type names (e.g. "OrderCreated", "LineAdded")
+	Events []string
+}
+
+// templateData is the view of a Spec used by the templates
+type templateData struct {
+	Package   string
+	Aggregate string
+	Module    string
+	Commands  []string
+	Events    []string
+	// FirstEvent is the event that establishes the aggregate's identity
+	FirstEvent string
+}
+
+// Generate writes the domain package into dir/<name>, refusing to overwrite existing files.
+// It returns the paths of the files it created.
+func Generate(spec Spec, dir string) ([]string, error) {
+	data, err := prepare(spec, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	pkgDir := filepath.Join(dir, data.Package)
+	if err := os.MkdirAll(pkgDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	files := []struct {
+		name string
+		tmpl *template.Template
+	}{
+		{data.Package + ".go", docTemplate},
+		{"commands.go", commandsTemplate},
+		{"events.go", eventsTemplate},
+		{"aggregate.go", aggregateTemplate},
+		{data.Package + "_query.go", queryTemplate},
+		{"registry.go", registryTemplate},
+		{data.Package + "_test.go", testTemplate},
+	}
+
+	created := make([]string, 0, len(files))
+	for _, file := range files {
+		path := filepath.Join(pkgDir, file.name)
+		if _, err := os.Stat(path); err == nil {
+			return created, fmt.Errorf("%s already exists", path)
+		}
+
+		var buf bytes.Buffer
+		if err := file.tmpl.Execute(&buf, data); err != nil {
+			return created, err
+		}
+		src, err := format.Source(buf.Bytes())
+		if err != nil {
+			return created, fmt.Errorf("formatting %s: %w", file.name, err)
+		}
+		if err := os.WriteFile(path, src, 0o644); err != nil {
+			return created, err
+		}
+		created = append(created, path)
+	}
+	return created, nil
+}
+
+func prepare(spec Spec, dir string) (templateData, error) {
+	if !isIdentifier(spec.Name) {
+		return templateData{}, fmt.Errorf("domain name %q is not a valid Go identifier", spec.Name)
+	}
+	if len(spec.Commands) == 0 {
+		return templateData{}, errors.New("at least one command is required")
+	}
+	if len(spec.Events) == 0 {
+		return templateData{}, errors.New("at least one event is required")
+	}
+	for _, name := range append(append([]string(nil), spec.Commands...), spec.Events...) {
+		if !isIdentifier(name) {
+			return templateData{}, fmt.Errorf("%q is not a valid Go identifier", name)
+		}
+	}
+
+	module := spec.Module
+	if module == "" {
+		detected, err := findModulePath(dir)
+		if err != nil {
+			return templateData{}, err
+		}
+		module = detected
+	}
+
+	commands := make([]string, len(spec.Commands))
+	for i, name := range spec.Commands {
+		commands[i] = exported(name)
+	}
+	events := make([]string, len(spec.Events))
+	for i, name := range spec.Events {
+		events[i] = exported(name)
+	}
+
+	return templateData{
+		Package:    strings.ToLower(spec.Name),
+		Aggregate:  exported(spec.Name),
+		Module:     module,
+		Commands:   commands,
+		Events:     events,
+		FirstEvent: events[0],
+	}, nil
+}
+
+// findModulePath walks up from dir to the nearest go.mod and returns its module path
+func findModulePath(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		f, err := os.Open(filepath.Join(abs, "go.mod"))
+		if err == nil {
+			defer f.Close()
+			scanner := bufio.NewScanner(f)
+			for scanner.Scan() {
+				line := strings.TrimSpace(scanner.Text())
+				if strings.HasPrefix(line, "module ") {
+					return strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "module ")), `"`), nil
+				}
+			}
+			return "", fmt.Errorf("no module directive in %s", f.Name())
+		}
+
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			return "", errors.New("no go.mod found; pass the module path explicitly")
+		}
+		abs = parent
+	}
+}
+
+func exported(name string) string {
+	runes := []rune(name)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+func isIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if !(unicode.IsLetter(r) || r == '_' || i > 0 && unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package scaffold
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerate_CreatesParsablePackage(t *testing.T) {
+	dir := t.TempDir()
+	spec := Spec{
+		Name:     "order",
+		Module:   "example.com/shop",
+		Commands: []string{"Create", "addLine"},
+		Events:   []string{"OrderCreated", "LineAdded"},
+	}
+
+	created, err := Generate(spec, dir)
+	if err != nil {
+		t.Fatalf("Error generating domain: %v", err)
+	}
+	if len(created) != 7 {
+		t.Errorf("Expected 7 files, got %d: %v", len(created), created)
+	}
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, filepath.Join(dir, "order"), nil, 0)
+	if err != nil {
+		t.Fatalf("Generated code does not parse: %v", err)
+	}
+	if _, ok := pkgs["order"]; !ok {
+		t.Errorf("Expected package order, got %v", pkgs)
+	}
+
+	aggregate, err := os.ReadFile(filepath.Join(dir, "order", "aggregate.go"))
+	if err != nil {
+		t.Fatalf("Error reading aggregate: %v", err)
+	}
+	for _, want := range []string{
+		`"example.com/shop/common"`,
+		"type OrderAggregate struct",
+		"case *AddLineCommand:",
+		"func (a *OrderAggregate) onLineAdded(event *common.Event) error",
+	} {
+		if !strings.Contains(string(aggregate), want) {
+			t.Errorf("Expected aggregate.go to contain %q", want)
+		}
+	}
+}
+
+func TestGenerate_RefusesToOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	spec := Spec{Name: "order", Module: "example.com/shop", Commands: []string{"Create"}, Events: []string{"OrderCreated"}}
+
+	if _, err := Generate(spec, dir); err != nil {
+		t.Fatalf("Error generating domain: %v", err)
+	}
+	if _, err := Generate(spec, dir); err == nil {
+		t.Error("Expected error when files already exist")
+	}
+}
+
+func TestGenerate_ValidatesSpec(t *testing.T) {
+	cases := map[string]Spec{
+		"invalid name":  {Name: "my-domain", Module: "m", Commands: []string{"Create"}, Events: []string{"Created"}},
+		"no commands":   {Name: "order", Module: "m", Events: []string{"Created"}},
+		"no events":     {Name: "order", Module: "m", Commands: []string{"Create"}},
+		"invalid event": {Name: "order", Module: "m", Commands: []string{"Create"}, Events: []string{"1Created"}},
+	}
+	for name, spec := range cases {
+		if _, err := Generate(spec, t.TempDir()); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestFindModulePath(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/shop\n\ngo 1.21\n"), 0o644)
+	nested := filepath.Join(dir, "internal", "domains")
+	os.MkdirAll(nested, 0o755)
+
+	module, err := findModulePath(nested)
+	if err != nil {
+		t.Fatalf("Error finding module: %v", err)
+	}
+	if module != "example.com/shop" {
+		t.Errorf("Expected example.com/shop, got %s", module)
+	}
+}
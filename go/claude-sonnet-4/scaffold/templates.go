@@ -0,0 +1,255 @@
+package scaffold
+
+import "text/template"
+
+var docTemplate = template.Must(template.New("doc").Parse(`// Package {{.Package}} provides the {{.Package}} domain implementation for the SimpleEventModeling framework.
+// It includes Commands, Events, and the {{.Aggregate}} Aggregate.
+//
+// The package is organized into separate files for each major concept:
+// - commands.go: Command types
+// - events.go: Event types and creation functions
+// - aggregate.go: {{.Aggregate}}Aggregate implementation with business logic
+// - {{.Package}}_query.go: {{.Aggregate}}Query for read models
+// - registry.go: Registration with the common registry
+package {{.Package}}
+`))
+
+var commandsTemplate = template.Must(template.New("commands").Parse(`// Package {{.Package}} provides command types for the {{.Package}} domain.
+// Commands are simple record structures; their only methods expose the routing
+// metadata required by common.Command.
+package {{.Package}}
+{{range .Commands}}
+// {{.}}Command represents a {{.}} command
+type {{.}}Command struct {
+	{{$.Aggregate}}ID string ` + "`json:\"aggregate_id\"`" + `
+}
+
+func (c *{{.}}Command) AggregateID() string { return c.{{$.Aggregate}}ID }
+func (c *{{.}}Command) CommandType() string { return CommandType{{.}} }
+{{end}}`))
+
+var eventsTemplate = template.Must(template.New("events").Parse(`// Package {{.Package}} provides event types and creation functions for the {{.Package}} domain.
+// Events are simple record structures with no behaviors.
+package {{.Package}}
+
+import "{{.Module}}/common"
+
+// Event type constants
+const (
+{{- range .Events}}
+	EventType{{.}} = "{{.}}"
+{{- end}}
+)
+{{range .Events}}
+// New{{.}}Event creates a new {{.}} event
+func New{{.}}Event(aggregateID string, version int, data map[string]interface{}) *common.Event {
+	return common.NewEvent(EventType{{.}}, aggregateID, version, data, nil)
+}
+{{end}}`))
+
+var aggregateTemplate = template.Must(template.New("aggregate").Parse(`// Package {{.Package}} provides the {{.Aggregate}}Aggregate implementation for the {{.Package}} domain.
+package {{.Package}}
+
+import (
+	"errors"
+	"{{.Module}}/common"
+)
+
+// {{.Aggregate}}Aggregate handles command validation and appends events to the store
+// if commands are valid. It hydrates by replaying its event stream.
+type {{.Aggregate}}Aggregate struct {
+	*common.BaseAggregate
+}
+
+// New{{.Aggregate}}Aggregate creates a new {{.Package}} aggregate
+func New{{.Aggregate}}Aggregate(store common.Store) *{{.Aggregate}}Aggregate {
+	return &{{.Aggregate}}Aggregate{
+		BaseAggregate: common.NewBaseAggregate(store),
+	}
+}
+
+// Handle processes commands and returns resulting events
+func (a *{{.Aggregate}}Aggregate) Handle(command common.Command) (*common.Event, error) {
+	if aggregateID := command.AggregateID(); aggregateID != "" && !a.IsLive() {
+		if err := a.Hydrate(aggregateID); err != nil {
+			return nil, err
+		}
+	}
+
+	switch cmd := command.(type) {
+{{- range .Commands}}
+	case *{{.}}Command:
+		return a.handle{{.}}(cmd)
+{{- end}}
+	default:
+		return nil, &common.UnknownCommandError{
+			CommandType: command.CommandType(),
+			Registered:  common.DefaultRegistry.CommandTypes(AggregateType{{.Aggregate}}),
+		}
+	}
+}
+
+// On applies events to aggregate state
+func (a *{{.Aggregate}}Aggregate) On(event *common.Event) error {
+	switch event.Type {
+{{- range .Events}}
+	case EventType{{.}}:
+		return a.on{{.}}(event)
+{{- end}}
+	default:
+		return errors.New("unhandled event type: " + event.Type)
+	}
+}
+
+// Hydrate rebuilds the aggregate state from its event stream
+func (a *{{.Aggregate}}Aggregate) Hydrate(id string) error {
+	return a.BaseAggregate.Hydrate(id, a.On)
+}
+
+// Event handlers
+{{range .Events}}
+func (a *{{$.Aggregate}}Aggregate) on{{.}}(event *common.Event) error {
+{{- if eq . $.FirstEvent}}
+	a.Begin(event)
+{{- end}}
+	return a.Advance(event)
+}
+{{end}}
+// Command handlers
+{{range .Commands}}
+func (a *{{$.Aggregate}}Aggregate) handle{{.}}(cmd *{{.}}Command) (*common.Event, error) {
+	// TODO: validate cmd against the aggregate state, then create the event,
+	// apply it with a.On, and append it with a.Store().Append.
+	return nil, &common.InvalidCommandError{Message: "{{.}} is not implemented"}
+}
+{{end}}`))
+
+var queryTemplate = template.Must(template.New("query").Parse(`// Package {{.Package}} provides query objects for projecting {{.Package}} state from event streams.
+package {{.Package}}
+
+import "{{.Module}}/common"
+
+// {{.Aggregate}}Query projects the state of a single {{.Package}} from its event stream
+type {{.Aggregate}}Query struct {
+	AggregateID string
+	Store       common.Store
+	Projection  *{{.Aggregate}}Projection
+}
+
+// {{.Aggregate}}Projection represents a read model projection of {{.Package}} state
+type {{.Aggregate}}Projection struct {
+	ID      string ` + "`json:\"id\"`" + `
+	Version int    ` + "`json:\"version\"`" + `
+}
+
+// New{{.Aggregate}}Query creates a new query for projecting {{.Package}} state
+func New{{.Aggregate}}Query(aggregateID string, store common.Store) *{{.Aggregate}}Query {
+	return &{{.Aggregate}}Query{
+		AggregateID: aggregateID,
+		Store:       store,
+		Projection:  &{{.Aggregate}}Projection{},
+	}
+}
+
+// Execute runs the query and returns the projected state
+func (q *{{.Aggregate}}Query) Execute() (*{{.Aggregate}}Projection, error) {
+	events, err := q.Store.GetStream(q.AggregateID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, event := range events {
+		if err := q.On(event); err != nil {
+			return nil, err
+		}
+	}
+	return q.Projection, nil
+}
+
+// On applies events to build the projection
+func (q *{{.Aggregate}}Query) On(event *common.Event) error {
+	switch event.Type {
+{{- range .Events}}
+	case EventType{{.}}:
+{{- if eq . $.FirstEvent}}
+		q.Projection.ID = event.AggregateID
+{{- end}}
+		q.Projection.Version = event.Version
+{{- end}}
+	}
+	return nil
+}
+`))
+
+var registryTemplate = template.Must(template.New("registry").Parse(`// Package {{.Package}} registers the {{.Package}} domain with the common registry so tooling
+// can discover its commands, events, and aggregate.
+package {{.Package}}
+
+import "{{.Module}}/common"
+
+// AggregateType{{.Aggregate}} is the registered name of the {{.Package}} aggregate
+const AggregateType{{.Aggregate}} = "{{.Aggregate}}"
+
+// Command type names
+const (
+{{- range .Commands}}
+	CommandType{{.}} = "{{.}}"
+{{- end}}
+)
+
+func init() {
+	register(common.DefaultRegistry)
+}
+
+func register(registry *common.Registry) {
+	registry.RegisterAggregate(common.AggregateInfo{Name: AggregateType{{.Aggregate}}})
+{{range .Commands}}
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandType{{.}},
+		Aggregate: AggregateType{{$.Aggregate}},
+		// TODO: list the event types this command produces
+		Produces: nil,
+	})
+{{- end}}
+{{range .Events}}
+	registry.RegisterEvent(common.EventInfo{Name: EventType{{.}}, Aggregate: AggregateType{{$.Aggregate}}})
+{{- end}}
+}
+`))
+
+var testTemplate = template.Must(template.New("test").Parse(`package {{.Package}}
+
+import (
+	"{{.Module}}/common"
+	"testing"
+)
+
+func Test{{.Aggregate}}Aggregate_Hydrate(t *testing.T) {
+	store := common.NewEventStore()
+	store.Append(New{{.FirstEvent}}Event("{{.Package}}-1", 1, nil))
+
+	aggregate := New{{.Aggregate}}Aggregate(store)
+	if err := aggregate.Hydrate("{{.Package}}-1"); err != nil {
+		t.Fatalf("Error hydrating {{.Package}}: %v", err)
+	}
+	if aggregate.ID() != "{{.Package}}-1" {
+		t.Errorf("Expected ID {{.Package}}-1, got %s", aggregate.ID())
+	}
+	if aggregate.Version() != 1 {
+		t.Errorf("Expected version 1, got %d", aggregate.Version())
+	}
+}
+
+func Test{{.Aggregate}}Query_Execute(t *testing.T) {
+	store := common.NewEventStore()
+	store.Append(New{{.FirstEvent}}Event("{{.Package}}-1", 1, nil))
+
+	projection, err := New{{.Aggregate}}Query("{{.Package}}-1", store).Execute()
+	if err != nil {
+		t.Fatalf("Error executing query: %v", err)
+	}
+	if projection.ID != "{{.Package}}-1" {
+		t.Errorf("Expected ID {{.Package}}-1, got %s", projection.ID)
+	}
+}
+`))
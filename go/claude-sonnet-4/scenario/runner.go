@@ -0,0 +1,207 @@
+package scenario
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+)
+
+// Domain tells the runner how to build and handle the commands of a domain
+type Domain struct {
+	// Commands creates an empty command for every command type scenarios may dispatch.
+	// The fixture payload is decoded into it.
+	Commands map[string]func() common.Command
+	// Register registers the domain's command handlers, e.g. cart.RegisterCommands
+	Register func(commands *bus.CommandBus, store common.Store)
+	// Middleware is installed on the bus commands are dispatched through
+	Middleware []bus.Middleware
+}
+
+// Run executes a scenario against a fresh in-memory store and returns an error
+// describing the first expectation that was not met
+func Run(domain Domain, scenario Scenario) error {
+	store := common.NewEventStore()
+	for i, given := range scenario.Given {
+		event := common.NewEvent(given.Type, given.AggregateID, given.Version, given.Data, given.Metadata)
+		if err := store.Append(event); err != nil {
+			return fmt.Errorf("given event %d: %w", i+1, err)
+		}
+	}
+	before := len(store.GetAllEvents())
+
+	newCommand, exists := domain.Commands[scenario.When.Type]
+	if !exists {
+		return fmt.Errorf("command type %q is not known to the domain", scenario.When.Type)
+	}
+	command := newCommand()
+	if len(scenario.When.Payload) > 0 {
+		if err := json.Unmarshal(scenario.When.Payload, command); err != nil {
+			return fmt.Errorf("decoding %s payload: %w", scenario.When.Type, err)
+		}
+	}
+
+	commands := bus.NewCommandBus(domain.Middleware...)
+	domain.Register(commands, store)
+	_, err := commands.Dispatch(context.Background(), command)
+	appended := store.GetAllEvents()[before:]
+
+	if scenario.Error != nil {
+		if err := checkError(err, scenario.Error); err != nil {
+			return err
+		}
+		if len(appended) > 0 {
+			return fmt.Errorf("expected a failed command to append no events, got %d", len(appended))
+		}
+	} else if err != nil {
+		return fmt.Errorf("unexpected error: %w", err)
+	}
+
+	if len(scenario.Then) > 0 {
+		if err := checkEvents(appended, scenario.Then); err != nil {
+			return err
+		}
+	}
+
+	if scenario.Projection != nil {
+		if err := checkProjection(store, scenario.Projection); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunDir runs every scenario in the fixture files of dir as a subtest
+func RunDir(t *testing.T, dir string, domain Domain) {
+	t.Helper()
+
+	scenarios, err := LoadDir(dir)
+	if err != nil {
+		t.Fatalf("Error loading scenarios: %v", err)
+	}
+	if len(scenarios) == 0 {
+		t.Fatalf("No scenarios found in %s", dir)
+	}
+	for _, scenario := range scenarios {
+		scenario := scenario
+		t.Run(scenario.Name, func(t *testing.T) {
+			if err := Run(domain, scenario); err != nil {
+				t.Errorf("%s: %v", scenario.File, err)
+			}
+		})
+	}
+}
+
+func checkError(err error, expected *ErrorFixture) error {
+	if err == nil {
+		return fmt.Errorf("expected %s, got no error", expected.Type)
+	}
+	if !hasErrorType(err, expected.Type) {
+		return fmt.Errorf("expected %s, got %T: %v", expected.Type, err, err)
+	}
+	if !strings.Contains(strings.ToLower(err.Error()), strings.ToLower(expected.Message)) {
+		return fmt.Errorf("expected error message to contain %q, got %q", expected.Message, err.Error())
+	}
+	return nil
+}
+
+// commonErrors lets fixtures name a common error type to match every error of its
+// kind, such as a domain rejection that matches common.ErrInvalidCommand
+var commonErrors = map[string]error{
+	"InvalidCommandError": common.ErrInvalidCommand,
+	"ValidationError":     common.ErrValidation,
+	"ConcurrencyError":    common.ErrConcurrency,
+	"StreamNotFoundError": common.ErrStreamNotFound,
+	"UnauthorizedError":   common.ErrUnauthorized,
+	"StreamAccessError":   common.ErrAccessDenied,
+}
+
+// hasErrorType reports whether err or an error it wraps has the named type, or
+// matches the sentinel of a named common error type
+func hasErrorType(err error, name string) bool {
+	if sentinel, ok := commonErrors[name]; ok && errors.Is(err, sentinel) {
+		return true
+	}
+	for ; err != nil; err = errors.Unwrap(err) {
+		t := reflect.TypeOf(err)
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+func checkEvents(actual []*common.Event, expected []EventFixture) error {
+	if len(actual) != len(expected) {
+		return fmt.Errorf("expected %d events, got %d: %s", len(expected), len(actual), describe(actual))
+	}
+	for i, want := range expected {
+		got := actual[i]
+		switch {
+		case got.Type != want.Type:
+			return fmt.Errorf("event %d: expected type %s, got %s", i+1, want.Type, got.Type)
+		case want.AggregateID != "" && got.AggregateID != want.AggregateID:
+			return fmt.Errorf("event %d: expected aggregate %s, got %s", i+1, want.AggregateID, got.AggregateID)
+		case want.Version != 0 && got.Version != want.Version:
+			return fmt.Errorf("event %d: expected version %d, got %d", i+1, want.Version, got.Version)
+		case want.Data != nil && !sameJSON(got.Data, want.Data):
+			return fmt.Errorf("event %d: expected data %v, got %v", i+1, want.Data, got.Data)
+		case want.Metadata != nil && !sameJSON(got.Metadata, want.Metadata):
+			return fmt.Errorf("event %d: expected metadata %v, got %v", i+1, want.Metadata, got.Metadata)
+		}
+	}
+	return nil
+}
+
+func checkProjection(store common.Store, expected *ProjectionFixture) error {
+	projection, err := common.DefaultRegistry.NewProjection(expected.Name)
+	if err != nil {
+		return err
+	}
+	if _, err := common.ReplayProjection(store, projection, 0, nil); err != nil {
+		return fmt.Errorf("replaying %s: %w", expected.Name, err)
+	}
+
+	var want interface{}
+	if err := json.Unmarshal(expected.State, &want); err != nil {
+		return fmt.Errorf("decoding expected %s state: %w", expected.Name, err)
+	}
+	if !sameJSON(projection.State(), want) {
+		got, _ := json.Marshal(projection.State())
+		return fmt.Errorf("expected %s state %s, got %s", expected.Name, expected.State, got)
+	}
+	return nil
+}
+
+// sameJSON compares two values by their JSON encoding, so numbers and nested maps
+// compare equal whether they were built in Go or decoded from a fixture
+func sameJSON(a, b interface{}) bool {
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+func normalize(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	json.Unmarshal(data, &out)
+	return out
+}
+
+func describe(events []*common.Event) string {
+	types := make([]string, len(events))
+	for i, event := range events {
+		types[i] = fmt.Sprintf("%s@%d", event.Type, event.Version)
+	}
+	return "[" + strings.Join(types, ", ") + "]"
+}
@@ -0,0 +1,100 @@
+package scenario
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"simple-event-modeling/bus"
+	"simple-event-modeling/cart"
+	"simple-event-modeling/common"
+	"strings"
+	"testing"
+)
+
+var cartDomain = Domain{
+	Commands: map[string]func() common.Command{
+		cart.CommandTypeAddItem:    func() common.Command { return &cart.AddItemCommand{} },
+		cart.CommandTypeRemoveItem: func() common.Command { return &cart.RemoveItemCommand{} },
+	},
+	Register: func(commands *bus.CommandBus, store common.Store) { cart.RegisterCommands(commands, store) },
+}
+
+func removeItem(item string) Scenario {
+	return Scenario{
+		Name: "remove",
+		Given: []EventFixture{
+			{Type: cart.EventTypeCartCreated, AggregateID: "cart-1", Version: 1},
+			{Type: cart.EventTypeItemAdded, AggregateID: "cart-1", Version: 2, Data: map[string]interface{}{"item": "apple"}},
+		},
+		When: CommandFixture{Type: cart.CommandTypeRemoveItem, Payload: json.RawMessage(`{"aggregate_id":"cart-1","item_id":"` + item + `"}`)},
+	}
+}
+
+func TestRun_ReportsUnmetExpectations(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(s *Scenario)
+		want   string
+	}{
+		{"wrong version", func(s *Scenario) {
+			s.Then = []EventFixture{{Type: cart.EventTypeItemRemoved, Version: 2}}
+		}, "expected version 2, got 3"},
+		{"wrong data", func(s *Scenario) {
+			s.Then = []EventFixture{{Type: cart.EventTypeItemRemoved, Data: map[string]interface{}{"item": "pear"}}}
+		}, "expected data"},
+		{"missing error", func(s *Scenario) {
+			s.Error = &ErrorFixture{Type: "InvalidCommandError"}
+		}, "got no error"},
+		{"wrong projection", func(s *Scenario) {
+			s.Projection = &ProjectionFixture{Name: cart.CartItemsProjectionName, State: json.RawMessage(`{}`)}
+		}, "expected cart-items state"},
+		{"unknown command", func(s *Scenario) {
+			s.When.Type = "Checkout"
+		}, "not known"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := removeItem("apple")
+			tt.modify(&s)
+			if err := Run(cartDomain, s); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	s := removeItem("pear")
+	s.Error = &ErrorFixture{Type: "ItemNotInCartError", Message: "NOT IN THE CART"}
+	if err := Run(cartDomain, s); err != nil {
+		t.Errorf("Expected the error type and message to match, got %v", err)
+	}
+	s.Error.Type = "InvalidCommandError"
+	if err := Run(cartDomain, s); err != nil {
+		t.Errorf("Expected a common error type to match errors of its kind, got %v", err)
+	}
+	s.Error.Type = "ConcurrencyError"
+	if err := Run(cartDomain, s); err == nil {
+		t.Error("Expected a different error kind not to match")
+	}
+	if err := Run(cartDomain, removeItem("pear")); err == nil || !strings.Contains(err.Error(), "unexpected error") {
+		t.Errorf("Expected an unexpected error to be reported, got %v", err)
+	}
+}
+
+func TestLoad_SingleScenarioOrArray(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.json"), []byte(`{"name":"one","when":{"type":"AddItem"}}`), 0o644)
+	os.WriteFile(filepath.Join(dir, "b.json"), []byte(`[{"name":"two","when":{"type":"AddItem"}},{"name":"three","when":{"type":"AddItem"}}]`), 0o644)
+
+	scenarios, err := LoadDir(dir)
+	if err != nil {
+		t.Fatalf("Error loading: %v", err)
+	}
+	if len(scenarios) != 3 || scenarios[0].Name != "one" || scenarios[2].File != filepath.Join(dir, "b.json") {
+		t.Errorf("Unexpected scenarios: %+v", scenarios)
+	}
+
+	os.WriteFile(filepath.Join(dir, "c.json"), []byte(`{"name":"no command"}`), 0o644)
+	if _, err := LoadDir(dir); err == nil {
+		t.Error("Expected a scenario without a command to be rejected")
+	}
+}
@@ -0,0 +1,271 @@
+// Package scheduler provides durable timers that append TimeoutElapsed events to a
+// stream once their due time has passed. Process managers follow those streams to
+// react to deadlines, e.g. cancelling a checkout whose payment never arrived.
+//
+// Timers are recorded as events in their own stream of the store, so with a durable
+// backend pending timers survive restarts: New rebuilds them from the timer stream and
+// skips timers whose TimeoutElapsed event was already appended.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"simple-event-modeling/common"
+
+	"github.com/google/uuid"
+)
+
+// DefaultStream is the stream timers are recorded in
+const DefaultStream = "$timers"
+
+// Event types written by the scheduler
+const (
+	EventTypeTimeoutScheduled = "TimeoutScheduled"
+	EventTypeTimeoutCancelled = "TimeoutCancelled"
+	EventTypeTimeoutElapsed   = "TimeoutElapsed"
+)
+
+// ErrTimerNotFound is returned when cancelling a timer that is not pending
+var ErrTimerNotFound = errors.New("timer not found")
+
+// ReservedDataKeys are the keys the scheduler sets in TimeoutElapsed events, which
+// timer data may not use
+var ReservedDataKeys = []string{"timer_id", "name", "due_at"}
+
+// Timer is a pending timeout
+type Timer struct {
+	ID string
+	// StreamID is the stream the TimeoutElapsed event is appended to
+	StreamID string
+	// Name tells handlers which deadline elapsed, e.g. "PaymentTimeout"
+	Name  string
+	DueAt time.Time
+	// Data is copied into the TimeoutElapsed event, next to the timer's ID, name and
+	// due time, so it may not hold any of ReservedDataKeys
+	Data map[string]interface{}
+}
+
+// Scheduler fires durable timers
+type Scheduler struct {
+	store  common.Store
+	stream string
+	now    func() time.Time
+
+	mu      sync.Mutex
+	pending map[string]Timer
+}
+
+// Option configures a Scheduler
+type Option func(*Scheduler)
+
+// WithClock replaces time.Now, e.g. with a simulated clock in tests
+func WithClock(now func() time.Time) Option {
+	return func(s *Scheduler) {
+		s.now = now
+	}
+}
+
+// WithStream records timers in the given stream instead of DefaultStream
+func WithStream(streamID string) Option {
+	return func(s *Scheduler) {
+		s.stream = streamID
+	}
+}
+
+// New creates a scheduler and restores the timers still pending in the store
+func New(store common.Store, opts ...Option) (*Scheduler, error) {
+	s := &Scheduler{store: store, stream: DefaultStream, now: time.Now, pending: make(map[string]Timer)}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Schedule records a timer that appends a TimeoutElapsed event to streamID at dueAt.
+// Data holding one of ReservedDataKeys is rejected.
+func (s *Scheduler) Schedule(streamID, name string, dueAt time.Time, data map[string]interface{}) (Timer, error) {
+	for _, key := range ReservedDataKeys {
+		if _, reserved := data[key]; reserved {
+			return Timer{}, fmt.Errorf("timer data key %q is reserved by the scheduler", key)
+		}
+	}
+	timer := Timer{ID: uuid.New().String(), StreamID: streamID, Name: name, DueAt: dueAt.UTC(), Data: data}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.record(EventTypeTimeoutScheduled, map[string]interface{}{
+		"timer_id":  timer.ID,
+		"stream_id": timer.StreamID,
+		"name":      timer.Name,
+		"due_at":    timer.DueAt.Format(time.RFC3339Nano),
+		"data":      timer.Data,
+	}); err != nil {
+		return Timer{}, err
+	}
+	s.pending[timer.ID] = timer
+	return timer, nil
+}
+
+// Cancel removes a pending timer
+func (s *Scheduler) Cancel(timerID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.pending[timerID]; !ok {
+		return ErrTimerNotFound
+	}
+	if err := s.record(EventTypeTimeoutCancelled, map[string]interface{}{"timer_id": timerID}); err != nil {
+		return err
+	}
+	delete(s.pending, timerID)
+	return nil
+}
+
+// Pending returns the pending timers, earliest first
+func (s *Scheduler) Pending() []Timer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	timers := make([]Timer, 0, len(s.pending))
+	for _, timer := range s.pending {
+		timers = append(timers, timer)
+	}
+	sort.Slice(timers, func(i, j int) bool { return timers[i].DueAt.Before(timers[j].DueAt) })
+	return timers
+}
+
+// Overdue returns the pending timers that were due more than grace ago, earliest
+// first. Timers stay overdue while FireDue is not running or keeps failing.
+func (s *Scheduler) Overdue(grace time.Duration) []Timer {
+	cutoff := s.now().Add(-grace)
+	var overdue []Timer
+	for _, timer := range s.Pending() {
+		if !timer.DueAt.Before(cutoff) {
+			break
+		}
+		overdue = append(overdue, timer)
+	}
+	return overdue
+}
+
+// FireDue appends a TimeoutElapsed event for every timer due by now and returns
+// how many fired
+func (s *Scheduler) FireDue() (int, error) {
+	now := s.now()
+	fired := 0
+	for _, timer := range s.Pending() {
+		if timer.DueAt.After(now) {
+			break
+		}
+
+		s.mu.Lock()
+		if _, ok := s.pending[timer.ID]; !ok {
+			// Cancelled since Pending was read
+			s.mu.Unlock()
+			continue
+		}
+		err := s.store.Append(common.NewEvent(
+			EventTypeTimeoutElapsed,
+			timer.StreamID,
+			s.store.GetStreamVersion(timer.StreamID)+1,
+			elapsedData(timer),
+			nil,
+		))
+		if err == nil {
+			delete(s.pending, timer.ID)
+		}
+		s.mu.Unlock()
+
+		if err != nil {
+			return fired, fmt.Errorf("firing timer %s: %w", timer.ID, err)
+		}
+		fired++
+	}
+	return fired, nil
+}
+
+// Run fires due timers every interval until ctx is cancelled. Errors are retried on
+// the next tick.
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) {
+	common.Poll(ctx, interval, func() error {
+		s.FireDue()
+		return nil
+	})
+}
+
+func (s *Scheduler) record(eventType string, data map[string]interface{}) error {
+	return s.store.Append(common.NewEvent(eventType, s.stream, s.store.GetStreamVersion(s.stream)+1, data, nil))
+}
+
+// load rebuilds the pending timers from the timer stream
+func (s *Scheduler) load() error {
+	events, err := s.store.GetStream(s.stream)
+	if err != nil {
+		var notFound *common.StreamNotFoundError
+		if errors.As(err, &notFound) {
+			return nil
+		}
+		return err
+	}
+
+	for _, event := range events {
+		id, _ := event.Data["timer_id"].(string)
+		switch event.Type {
+		case EventTypeTimeoutScheduled:
+			timer, err := timerFromEvent(id, event)
+			if err != nil {
+				return err
+			}
+			s.pending[id] = timer
+		case EventTypeTimeoutCancelled:
+			delete(s.pending, id)
+		}
+	}
+
+	// Drop timers that already fired
+	for id, timer := range s.pending {
+		elapsed, err := s.store.GetStream(timer.StreamID)
+		if err != nil {
+			continue
+		}
+		for _, event := range elapsed {
+			if event.Type == EventTypeTimeoutElapsed && event.Data["timer_id"] == id {
+				delete(s.pending, id)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func timerFromEvent(id string, event *common.Event) (Timer, error) {
+	raw, _ := event.Data["due_at"].(string)
+	dueAt, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		return Timer{}, fmt.Errorf("timer %s: invalid due_at %q", id, raw)
+	}
+	streamID, _ := event.Data["stream_id"].(string)
+	name, _ := event.Data["name"].(string)
+	data, _ := event.Data["data"].(map[string]interface{})
+	return Timer{ID: id, StreamID: streamID, Name: name, DueAt: dueAt, Data: data}, nil
+}
+
+func elapsedData(timer Timer) map[string]interface{} {
+	data := make(map[string]interface{}, len(timer.Data)+3)
+	for k, v := range timer.Data {
+		data[k] = v
+	}
+	data["timer_id"] = timer.ID
+	data["name"] = timer.Name
+	data["due_at"] = timer.DueAt.Format(time.RFC3339Nano)
+	return data
+}
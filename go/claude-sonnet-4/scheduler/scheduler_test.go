@@ -0,0 +1,104 @@
+package scheduler
+
+import (
+	"errors"
+	"path/filepath"
+	"simple-event-modeling/common"
+	"simple-event-modeling/filestore"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func TestScheduler_FireDue(t *testing.T) {
+	store := common.NewEventStore()
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	s, err := New(store, WithClock(clock.Now))
+	if err != nil {
+		t.Fatalf("Error creating scheduler: %v", err)
+	}
+
+	payment, _ := s.Schedule("checkout-1", "PaymentTimeout", clock.now.Add(15*time.Minute), map[string]interface{}{"order": "o-1"})
+	reminder, _ := s.Schedule("checkout-1", "Reminder", clock.now.Add(5*time.Minute), nil)
+	s.Schedule("checkout-2", "PaymentTimeout", clock.now.Add(time.Hour), nil)
+
+	if _, err := s.Schedule("checkout-1", "Reminder", clock.now, map[string]interface{}{"name": "mine"}); err == nil {
+		t.Error("Expected timer data using a reserved key to be rejected")
+	}
+
+	if err := s.Cancel(reminder.ID); err != nil {
+		t.Fatalf("Error cancelling timer: %v", err)
+	}
+	if err := s.Cancel(reminder.ID); !errors.Is(err, ErrTimerNotFound) {
+		t.Errorf("Expected ErrTimerNotFound, got %v", err)
+	}
+
+	clock.now = clock.now.Add(20 * time.Minute)
+	if overdue := s.Overdue(time.Minute); len(overdue) != 1 || overdue[0].ID != payment.ID {
+		t.Errorf("Expected the payment timer to be overdue, got %+v", overdue)
+	}
+	if overdue := s.Overdue(10 * time.Minute); len(overdue) != 0 {
+		t.Errorf("Expected no timer overdue by 10 minutes, got %+v", overdue)
+	}
+	fired, err := s.FireDue()
+	if err != nil || fired != 1 {
+		t.Fatalf("Expected 1 timer to fire, got %d (%v)", fired, err)
+	}
+
+	events, _ := store.GetStream("checkout-1")
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 event in checkout-1, got %d", len(events))
+	}
+	elapsed := events[0]
+	if elapsed.Type != EventTypeTimeoutElapsed || elapsed.Data["timer_id"] != payment.ID ||
+		elapsed.Data["name"] != "PaymentTimeout" || elapsed.Data["order"] != "o-1" {
+		t.Errorf("Unexpected TimeoutElapsed event: %+v", elapsed)
+	}
+	if pending := s.Pending(); len(pending) != 1 || pending[0].StreamID != "checkout-2" {
+		t.Errorf("Expected only the checkout-2 timer to be pending, got %+v", pending)
+	}
+}
+
+func TestScheduler_SurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+
+	store, err := filestore.Open(path)
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+	s, _ := New(store, WithClock(clock.Now))
+	s.Schedule("checkout-1", "PaymentTimeout", clock.now.Add(time.Minute), nil)
+	s.Schedule("checkout-2", "PaymentTimeout", clock.now.Add(time.Hour), map[string]interface{}{"order": "o-2"})
+
+	clock.now = clock.now.Add(2 * time.Minute)
+	if fired, _ := s.FireDue(); fired != 1 {
+		t.Fatalf("Expected 1 timer to fire, got %d", fired)
+	}
+
+	reopened, err := filestore.Open(path)
+	if err != nil {
+		t.Fatalf("Error reopening store: %v", err)
+	}
+	restarted, err := New(reopened, WithClock(clock.Now))
+	if err != nil {
+		t.Fatalf("Error restoring scheduler: %v", err)
+	}
+	pending := restarted.Pending()
+	if len(pending) != 1 || pending[0].StreamID != "checkout-2" || pending[0].Data["order"] != "o-2" {
+		t.Fatalf("Expected the checkout-2 timer to be restored, got %+v", pending)
+	}
+
+	clock.now = clock.now.Add(time.Hour)
+	if fired, _ := restarted.FireDue(); fired != 1 {
+		t.Errorf("Expected the restored timer to fire, got %d", fired)
+	}
+	if version := reopened.GetStreamVersion("checkout-1"); version != 1 {
+		t.Errorf("Expected checkout-1 timer to fire only once, got version %d", version)
+	}
+}
@@ -0,0 +1,167 @@
+// Package semdiff produces structural diffs of events and of aggregate state, e.g. the
+// items added to and removed from a cart between versions 3 and 7 of its stream.
+// Values are compared by their JSON encoding, so a diff reads in terms of the same
+// field names the events and read models are serialized with. The diffs back the
+// `sem diff` command and give tests readable failure messages.
+package semdiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"simple-event-modeling/common"
+)
+
+// Op is the kind of a change
+type Op string
+
+// Change kinds
+const (
+	Added   Op = "added"
+	Removed Op = "removed"
+	Changed Op = "changed"
+)
+
+// Change is a single difference between two values
+type Change struct {
+	// Path locates the value, e.g. "items.apple.quantity" or "lines[2]"; it is
+	// empty for the root value
+	Path   string      `json:"path"`
+	Op     Op          `json:"op"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// String formats the change as "+ path: after", "- path: before", or "~ path: before -> after"
+func (c Change) String() string {
+	path := c.Path
+	if path == "" {
+		path = "(root)"
+	}
+	switch c.Op {
+	case Added:
+		return fmt.Sprintf("+ %s: %s", path, encode(c.After))
+	case Removed:
+		return fmt.Sprintf("- %s: %s", path, encode(c.Before))
+	default:
+		return fmt.Sprintf("~ %s: %s -> %s", path, encode(c.Before), encode(c.After))
+	}
+}
+
+// Format renders changes one per line, or "(no changes)"
+func Format(changes []Change) string {
+	if len(changes) == 0 {
+		return "(no changes)"
+	}
+	lines := make([]string, len(changes))
+	for i, change := range changes {
+		lines[i] = change.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Diff returns the changes that turn before into after, sorted by path.
+// A nil value compares as an empty object or array when the other side is one.
+func Diff(before, after interface{}) []Change {
+	var changes []Change
+	diffValues("", normalize(before), normalize(after), &changes)
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// DiffEvents compares two events by type, aggregate, version, data, and metadata.
+// IDs and creation times are ignored, as they differ between otherwise equal events.
+func DiffEvents(before, after *common.Event) []Change {
+	return Diff(eventFields(before), eventFields(after))
+}
+
+func eventFields(event *common.Event) map[string]interface{} {
+	if event == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"type":         event.Type,
+		"aggregate_id": event.AggregateID,
+		"version":      event.Version,
+		"data":         event.Data,
+		"metadata":     event.Metadata,
+	}
+}
+
+func diffValues(path string, before, after interface{}, changes *[]Change) {
+	beforeMap, beforeIsMap := before.(map[string]interface{})
+	afterMap, afterIsMap := after.(map[string]interface{})
+	if (beforeIsMap || before == nil) && (afterIsMap || after == nil) && (beforeIsMap || afterIsMap) {
+		diffMaps(path, beforeMap, afterMap, changes)
+		return
+	}
+
+	beforeList, beforeIsList := before.([]interface{})
+	afterList, afterIsList := after.([]interface{})
+	if (beforeIsList || before == nil) && (afterIsList || after == nil) && (beforeIsList || afterIsList) {
+		diffLists(path, beforeList, afterList, changes)
+		return
+	}
+
+	if encode(before) != encode(after) {
+		*changes = append(*changes, Change{Path: path, Op: Changed, Before: before, After: after})
+	}
+}
+
+func diffMaps(path string, before, after map[string]interface{}, changes *[]Change) {
+	for key, value := range before {
+		if _, exists := after[key]; !exists {
+			*changes = append(*changes, Change{Path: joinKey(path, key), Op: Removed, Before: value})
+		}
+	}
+	for key, value := range after {
+		old, exists := before[key]
+		if !exists {
+			*changes = append(*changes, Change{Path: joinKey(path, key), Op: Added, After: value})
+			continue
+		}
+		diffValues(joinKey(path, key), old, value, changes)
+	}
+}
+
+func diffLists(path string, before, after []interface{}, changes *[]Change) {
+	for i := 0; i < len(before) || i < len(after); i++ {
+		indexPath := fmt.Sprintf("%s[%d]", path, i)
+		switch {
+		case i >= len(after):
+			*changes = append(*changes, Change{Path: indexPath, Op: Removed, Before: before[i]})
+		case i >= len(before):
+			*changes = append(*changes, Change{Path: indexPath, Op: Added, After: after[i]})
+		default:
+			diffValues(indexPath, before[i], after[i], changes)
+		}
+	}
+}
+
+func joinKey(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// normalize converts a value to its generic JSON form
+func normalize(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	var out interface{}
+	json.Unmarshal(data, &out)
+	return out
+}
+
+func encode(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,80 @@
+package semdiff
+
+import (
+	"simple-event-modeling/cart"
+	"simple-event-modeling/common"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	before := map[string]interface{}{
+		"items": map[string]int{"apple": 1, "pear": 2},
+		"tags":  []string{"a"},
+		"name":  "old",
+	}
+	after := map[string]interface{}{
+		"items": map[string]int{"apple": 3, "plum": 1},
+		"tags":  []string{"a", "b"},
+		"name":  "old",
+	}
+
+	got := Format(Diff(before, after))
+	want := strings.Join([]string{
+		"~ items.apple: 1 -> 3",
+		"- items.pear: 2",
+		"+ items.plum: 1",
+		"+ tags[1]: \"b\"",
+	}, "\n")
+	if got != want {
+		t.Errorf("Unexpected diff:\n%s\nwant:\n%s", got, want)
+	}
+	if Format(Diff(before, before)) != "(no changes)" {
+		t.Error("Expected no changes between equal values")
+	}
+}
+
+func TestDiffEvents(t *testing.T) {
+	a := common.NewEvent("ItemAdded", "cart-1", 2, map[string]interface{}{"item": "apple"}, nil)
+	b := common.NewEvent("ItemAdded", "cart-1", 3, map[string]interface{}{"item": "apple"}, nil)
+
+	changes := DiffEvents(a, b)
+	if len(changes) != 1 || changes[0].String() != "~ version: 2 -> 3" {
+		t.Errorf("Expected only the version to differ, got %v", changes)
+	}
+}
+
+func TestStreamDiff(t *testing.T) {
+	store := common.NewEventStore()
+	store.Append(cart.NewCartCreatedEvent("cart-1"))
+	store.Append(cart.NewItemAddedEvent("cart-1", 2, "apple"))
+	store.Append(cart.NewItemAddedEvent("cart-1", 3, "pear"))
+	store.Append(cart.NewItemRemovedEvent("cart-1", 4, "apple"))
+	store.Append(cart.NewItemAddedEvent("cart-1", 5, "pear"))
+
+	items := func(events []*common.Event) (interface{}, error) {
+		aggregate := cart.NewCartAggregate(store)
+		for _, event := range events {
+			if err := aggregate.On(event); err != nil {
+				return nil, err
+			}
+		}
+		return aggregate.Items(), nil
+	}
+
+	changes, err := StreamDiff(store, "cart-1", 2, 5, items)
+	if err != nil {
+		t.Fatalf("Error diffing: %v", err)
+	}
+	if got := Format(changes); got != "- apple: 1\n+ pear: 2" {
+		t.Errorf("Unexpected diff:\n%s", got)
+	}
+
+	events, _ := EventsBetween(store, "cart-1", 2, 4)
+	if len(events) != 2 || events[0].Version != 3 {
+		t.Errorf("Expected versions 3 and 4, got %v", events)
+	}
+	if _, err := StreamDiff(store, "cart-1", 1, 9, items); err == nil {
+		t.Error("Expected a version beyond the stream to be rejected")
+	}
+}
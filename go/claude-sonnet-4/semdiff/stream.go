@@ -0,0 +1,85 @@
+package semdiff
+
+import (
+	"fmt"
+
+	"simple-event-modeling/common"
+)
+
+// StateFunc folds the events of a stream, oldest first, into the state to diff
+type StateFunc func(events []*common.Event) (interface{}, error)
+
+// ProjectionState folds events through a fresh projection and returns its State
+func ProjectionState(factory common.ProjectionFactory) StateFunc {
+	return func(events []*common.Event) (interface{}, error) {
+		projection := factory()
+		for _, event := range events {
+			if err := projection.On(event); err != nil {
+				return nil, err
+			}
+		}
+		return projection.State(), nil
+	}
+}
+
+// StateAt returns the state of a stream as of version. Version 0 folds no events.
+func StateAt(store common.Store, streamID string, version int, state StateFunc) (interface{}, error) {
+	events, err := eventsUpTo(store, streamID, version)
+	if err != nil {
+		return nil, err
+	}
+	return state(events)
+}
+
+// StreamDiff returns the changes to the state of a stream between versions from and to
+func StreamDiff(store common.Store, streamID string, from, to int, state StateFunc) ([]Change, error) {
+	if from < 0 || from > to {
+		return nil, fmt.Errorf("invalid version range %d..%d", from, to)
+	}
+	before, err := StateAt(store, streamID, from, state)
+	if err != nil {
+		return nil, err
+	}
+	after, err := StateAt(store, streamID, to, state)
+	if err != nil {
+		return nil, err
+	}
+	return Diff(before, after), nil
+}
+
+// EventsBetween returns the events of a stream after version from up to and including to
+func EventsBetween(store common.Store, streamID string, from, to int) ([]*common.Event, error) {
+	events, err := eventsUpTo(store, streamID, to)
+	if err != nil {
+		return nil, err
+	}
+	between := make([]*common.Event, 0, len(events))
+	for _, event := range events {
+		if event.Version > from {
+			between = append(between, event)
+		}
+	}
+	return between, nil
+}
+
+func eventsUpTo(store common.Store, streamID string, version int) ([]*common.Event, error) {
+	stream, err := store.GetStream(streamID)
+	if err != nil {
+		return nil, err
+	}
+	current := 0
+	if len(stream) > 0 {
+		current = stream[len(stream)-1].Version
+	}
+	if version > current {
+		return nil, fmt.Errorf("stream %s is at version %d, before %d", streamID, current, version)
+	}
+
+	events := make([]*common.Event, 0, version)
+	for _, event := range stream {
+		if event.Version <= version {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
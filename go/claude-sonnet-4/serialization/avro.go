@@ -0,0 +1,395 @@
+// Package serialization provides the Avro serializer, which writes events in the
+// Confluent wire format so events relayed to Kafka can be consumed by pipelines
+// governed by a schema registry: a zero magic byte, the big-endian 4-byte ID of
+// the writer schema in the registry, and the Avro binary encoding of the event.
+//
+// Each event type gets its own record schema, derived from the payload fields
+// registered for it in a common.Registry:
+//
+//	string -> string, integer -> long, number -> double, boolean -> boolean
+//
+// and arrays and objects as strings holding their JSON. Optional fields are
+// nullable. Data keys not registered for the event type are kept in an extra_data
+// map, and metadata in a metadata map, of nulls, booleans, doubles and strings;
+// other values in those maps are stored as their JSON and read back as strings.
+// Timestamps are stored in microseconds.
+//
+// Records are decoded with the writer schema named in them, fetched from the
+// registry, and mapped to events by field name, so records written under an
+// older schema of an event type stay readable after its payload changes.
+package serialization
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"simple-event-modeling/common"
+)
+
+// ContentTypeAvro is the content type of the Avro serializer
+const ContentTypeAvro = "application/avro"
+
+// DefaultAvroNamespace is the namespace of the record schemas of events
+const DefaultAvroNamespace = "simple_event_modeling.events"
+
+// avroMagic starts every record in the Confluent wire format
+const avroMagic = 0
+
+// avroPrimitives is the type of the extra_data and metadata map values
+var avroPrimitives = []interface{}{"null", "boolean", "double", "string"}
+
+var avroInvalidName = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// Avro serializes events with Avro schemas kept in a schema registry. It is safe
+// for concurrent use.
+type Avro struct {
+	registry  SchemaRegistry
+	events    *common.Registry
+	namespace string
+	subject   func(recordName string) string
+
+	mu      sync.Mutex
+	writers map[string]int // event type -> schema ID
+	schemas map[int]*avroSchema
+}
+
+var _ Serializer = (*Avro)(nil)
+
+// AvroOption configures an Avro serializer
+type AvroOption func(*Avro)
+
+// WithAvroNamespace names the record schemas of events in namespace instead of
+// DefaultAvroNamespace
+func WithAvroNamespace(namespace string) AvroOption {
+	return func(a *Avro) {
+		a.namespace = namespace
+	}
+}
+
+// WithSubjectStrategy registers the schema of an event type under the subject
+// subject returns for its full record name. The default is the record name
+// strategy, the full record name itself, which lets one topic carry every event
+// type; TopicRecordNameStrategy scopes the subjects to a topic.
+func WithSubjectStrategy(subject func(recordName string) string) AvroOption {
+	return func(a *Avro) {
+		a.subject = subject
+	}
+}
+
+// TopicRecordNameStrategy returns the subject strategy naming subjects
+// "<topic>-<record name>"
+func TopicRecordNameStrategy(topic string) func(recordName string) string {
+	return func(recordName string) string {
+		return topic + "-" + recordName
+	}
+}
+
+// NewAvro creates an Avro serializer registering the schemas of the event types of
+// events, or common.DefaultRegistry when nil, with registry
+func NewAvro(registry SchemaRegistry, events *common.Registry, opts ...AvroOption) *Avro {
+	if events == nil {
+		events = common.DefaultRegistry
+	}
+	a := &Avro{
+		registry:  registry,
+		events:    events,
+		namespace: DefaultAvroNamespace,
+		subject:   func(recordName string) string { return recordName },
+		writers:   make(map[string]int),
+		schemas:   make(map[int]*avroSchema),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// ContentType returns ContentTypeAvro
+func (a *Avro) ContentType() string { return ContentTypeAvro }
+
+// Schema returns the Avro schema of an event type, in JSON
+func (a *Avro) Schema(eventType string) string {
+	schema, _ := json.Marshal(a.schemaOf(eventType))
+	return string(schema)
+}
+
+// RecordName returns the full name of the record schema of an event type
+func (a *Avro) RecordName(eventType string) string {
+	return a.namespace + "." + avroName(eventType)
+}
+
+// Marshal registers the schema of the event's type, if needed, and encodes the
+// event with it
+func (a *Avro) Marshal(event *common.Event) ([]byte, error) {
+	id, schema, err := a.writer(context.Background(), event.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]interface{})
+	extra := make(map[string]interface{})
+	for key, value := range event.Data {
+		if _, declared := schemaField(schema, "data", key); declared {
+			data[key] = value
+		} else {
+			extra[key] = avroPrimitive(value)
+		}
+	}
+	metadata := make(map[string]interface{}, len(event.Metadata))
+	for key, value := range event.Metadata {
+		metadata[key] = avroPrimitive(value)
+	}
+	var effectiveAt, payload interface{}
+	if event.EffectiveAt != nil {
+		effectiveAt = event.EffectiveAt.UnixMicro()
+	}
+	if len(event.Payload) > 0 {
+		payload = event.Payload
+	}
+
+	record := binary.BigEndian.AppendUint32([]byte{avroMagic}, uint32(id))
+	record, err = appendAvro(record, schema, map[string]interface{}{
+		"id":           event.ID,
+		"type":         event.Type,
+		"created_at":   event.CreatedAt.UnixMicro(),
+		"effective_at": effectiveAt,
+		"aggregate_id": event.AggregateID,
+		"version":      int64(event.Version),
+		"data":         data,
+		"extra_data":   extra,
+		"metadata":     metadata,
+		"payload":      payload,
+		"content_type": event.ContentType,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encoding %s: %w", event.Type, err)
+	}
+	return record, nil
+}
+
+// Unmarshal decodes a record with the writer schema it names
+func (a *Avro) Unmarshal(record []byte) (*common.Event, error) {
+	if len(record) < 5 || record[0] != avroMagic {
+		return nil, errors.New("avro: not in the Confluent wire format")
+	}
+	id := int(binary.BigEndian.Uint32(record[1:5]))
+	schema, err := a.schemaByID(context.Background(), id)
+	if err != nil {
+		return nil, err
+	}
+	value, _, err := readAvro(record[5:], schema)
+	if err != nil {
+		return nil, err
+	}
+	fields, _ := value.(map[string]interface{})
+
+	event := &common.Event{Data: make(map[string]interface{}), Metadata: make(map[string]interface{})}
+	event.ID, _ = fields["id"].(string)
+	event.Type, _ = fields["type"].(string)
+	event.AggregateID, _ = fields["aggregate_id"].(string)
+	event.ContentType, _ = fields["content_type"].(string)
+	event.Payload, _ = fields["payload"].([]byte)
+	if version, ok := fields["version"].(int64); ok {
+		event.Version = int(version)
+	}
+	if micros, ok := fields["created_at"].(int64); ok {
+		event.CreatedAt = time.UnixMicro(micros).UTC()
+	}
+	if micros, ok := fields["effective_at"].(int64); ok {
+		effectiveAt := time.UnixMicro(micros).UTC()
+		event.EffectiveAt = &effectiveAt
+	}
+	for _, name := range []string{"extra_data", "data"} {
+		values, _ := fields[name].(map[string]interface{})
+		for key, value := range values {
+			if value != nil || name == "extra_data" {
+				event.Data[key] = jsonNumbers(value)
+			}
+		}
+	}
+	metadata, _ := fields["metadata"].(map[string]interface{})
+	for key, value := range metadata {
+		event.Metadata[key] = jsonNumbers(value)
+	}
+	return event, nil
+}
+
+// writer returns the ID and schema of the current schema of an event type,
+// registering it on first use
+func (a *Avro) writer(ctx context.Context, eventType string) (int, *avroSchema, error) {
+	a.mu.Lock()
+	id, ok := a.writers[eventType]
+	schema := a.schemas[id]
+	a.mu.Unlock()
+	if ok {
+		return id, schema, nil
+	}
+
+	text := a.Schema(eventType)
+	schema, err := parseAvroSchema(text)
+	if err != nil {
+		return 0, nil, err
+	}
+	id, err = a.registry.Register(ctx, a.subject(a.RecordName(eventType)), text)
+	if err != nil {
+		return 0, nil, fmt.Errorf("registering schema of %s: %w", eventType, err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.writers[eventType] = id
+	a.schemas[id] = schema
+	return id, schema, nil
+}
+
+// schemaByID returns the parsed schema with an ID, fetching it on first use
+func (a *Avro) schemaByID(ctx context.Context, id int) (*avroSchema, error) {
+	a.mu.Lock()
+	schema, ok := a.schemas[id]
+	a.mu.Unlock()
+	if ok {
+		return schema, nil
+	}
+
+	text, err := a.registry.SchemaByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("fetching schema %d: %w", id, err)
+	}
+	if schema, err = parseAvroSchema(text); err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.schemas[id] = schema
+	return schema, nil
+}
+
+// schemaOf builds the record schema of an event type
+func (a *Avro) schemaOf(eventType string) map[string]interface{} {
+	name := avroName(eventType)
+	dataFields := []interface{}{}
+	if info, ok := a.events.Event(eventType); ok {
+		for _, field := range info.Payload {
+			var fieldType interface{}
+			switch field.Type {
+			case "string":
+				fieldType = "string"
+			case "integer":
+				fieldType = "long"
+			case "number":
+				fieldType = "double"
+			case "boolean":
+				fieldType = "boolean"
+			default:
+				fieldType = map[string]interface{}{"type": "string", "sem.json": true}
+			}
+			if field.Optional {
+				dataFields = append(dataFields, avroFieldSchema(field.Name, []interface{}{"null", fieldType}, nil, field.Description))
+			} else {
+				dataFields = append(dataFields, avroFieldSchema(field.Name, fieldType, nil, field.Description))
+			}
+		}
+	}
+	timestamp := map[string]interface{}{"type": "long", "logicalType": "timestamp-micros"}
+	primitives := map[string]interface{}{"type": "map", "values": avroPrimitives}
+
+	return map[string]interface{}{
+		"type":      "record",
+		"name":      name,
+		"namespace": a.namespace,
+		"fields": []interface{}{
+			avroFieldSchema("id", "string", nil, ""),
+			avroFieldSchema("type", "string", nil, ""),
+			avroFieldSchema("created_at", timestamp, nil, ""),
+			avroFieldSchema("effective_at", []interface{}{"null", timestamp}, nil, ""),
+			avroFieldSchema("aggregate_id", "string", nil, ""),
+			avroFieldSchema("version", "long", nil, ""),
+			avroFieldSchema("data", map[string]interface{}{"type": "record", "name": name + "Data", "fields": dataFields}, nil, ""),
+			avroFieldSchema("extra_data", primitives, map[string]interface{}{}, ""),
+			avroFieldSchema("metadata", primitives, map[string]interface{}{}, ""),
+			avroFieldSchema("payload", []interface{}{"null", "bytes"}, nil, ""),
+			avroFieldSchema("content_type", "string", "", ""),
+		},
+	}
+}
+
+// avroFieldSchema describes a record field. Fields of union types default to null,
+// others to def when it is not nil.
+func avroFieldSchema(name string, fieldType, def interface{}, doc string) map[string]interface{} {
+	field := map[string]interface{}{"name": name, "type": fieldType}
+	if _, union := fieldType.([]interface{}); union {
+		field["default"] = nil
+	} else if def != nil {
+		field["default"] = def
+	}
+	if doc != "" {
+		field["doc"] = doc
+	}
+	return field
+}
+
+// schemaField finds a field of the record held by a field of record
+func schemaField(record *avroSchema, parent, name string) (avroField, bool) {
+	for _, field := range record.Fields {
+		if field.Name != parent {
+			continue
+		}
+		for _, child := range field.Schema.Fields {
+			if child.Name == name {
+				return child, true
+			}
+		}
+	}
+	return avroField{}, false
+}
+
+// avroName turns an event type into a valid Avro name
+func avroName(eventType string) string {
+	name := avroInvalidName.ReplaceAllString(eventType, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+// avroPrimitive returns value if it fits the extra_data and metadata maps, and its
+// JSON otherwise
+func avroPrimitive(value interface{}) interface{} {
+	switch value.(type) {
+	case nil, bool, string:
+		return value
+	}
+	if _, ok := toFloat(value); ok {
+		return value
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
+
+// jsonNumbers converts the longs of a decoded value to float64, as encoding/json
+// decodes numbers
+func jsonNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int64:
+		return float64(v)
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = jsonNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = jsonNumbers(item)
+		}
+	}
+	return value
+}
@@ -0,0 +1,408 @@
+// Package serialization provides the Avro schemas and binary encoding behind the
+// Avro serializer. Only what event envelopes need is supported: the primitive
+// types, records, arrays, maps and unions; enums and fixed types are rejected.
+package serialization
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// avroSchema is a parsed Avro schema
+type avroSchema struct {
+	Type        string
+	Name        string
+	LogicalType string
+	// JSON marks string fields holding the JSON encoding of an array or object
+	JSON   bool
+	Fields []avroField
+	Items  *avroSchema
+	Values *avroSchema
+	Union  []*avroSchema
+}
+
+type avroField struct {
+	Name       string
+	Schema     *avroSchema
+	Default    interface{}
+	HasDefault bool
+}
+
+var errAvroTruncated = errors.New("avro: truncated data")
+
+// parseAvroSchema parses the JSON form of a schema
+func parseAvroSchema(schema string) (*avroSchema, error) {
+	var raw interface{}
+	if err := json.Unmarshal([]byte(schema), &raw); err != nil {
+		return nil, fmt.Errorf("avro: parsing schema: %w", err)
+	}
+	return parseAvroNode(raw, make(map[string]*avroSchema), "")
+}
+
+func parseAvroNode(raw interface{}, names map[string]*avroSchema, namespace string) (*avroSchema, error) {
+	switch node := raw.(type) {
+	case string:
+		switch node {
+		case "null", "boolean", "int", "long", "float", "double", "bytes", "string":
+			return &avroSchema{Type: node}, nil
+		}
+		if named, ok := names[node]; ok {
+			return named, nil
+		}
+		if named, ok := names[namespace+"."+node]; ok {
+			return named, nil
+		}
+		return nil, fmt.Errorf("avro: unknown type %q", node)
+	case []interface{}:
+		union := &avroSchema{Type: "union"}
+		for _, branch := range node {
+			schema, err := parseAvroNode(branch, names, namespace)
+			if err != nil {
+				return nil, err
+			}
+			union.Union = append(union.Union, schema)
+		}
+		return union, nil
+	case map[string]interface{}:
+		return parseAvroComplex(node, names, namespace)
+	}
+	return nil, fmt.Errorf("avro: invalid schema node %v", raw)
+}
+
+func parseAvroComplex(node map[string]interface{}, names map[string]*avroSchema, namespace string) (*avroSchema, error) {
+	typeName, _ := node["type"].(string)
+	logicalType, _ := node["logicalType"].(string)
+	isJSON, _ := node["sem.json"].(bool)
+
+	switch typeName {
+	case "record":
+		name, _ := node["name"].(string)
+		if ns, ok := node["namespace"].(string); ok {
+			namespace = ns
+		}
+		schema := &avroSchema{Type: "record", Name: name}
+		names[name] = schema
+		if namespace != "" {
+			names[namespace+"."+name] = schema
+		}
+		fields, _ := node["fields"].([]interface{})
+		for _, f := range fields {
+			field, _ := f.(map[string]interface{})
+			fieldName, _ := field["name"].(string)
+			fieldSchema, err := parseAvroNode(field["type"], names, namespace)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", name, fieldName, err)
+			}
+			def, hasDefault := field["default"]
+			schema.Fields = append(schema.Fields, avroField{Name: fieldName, Schema: fieldSchema, Default: def, HasDefault: hasDefault})
+		}
+		return schema, nil
+	case "array":
+		items, err := parseAvroNode(node["items"], names, namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &avroSchema{Type: "array", Items: items}, nil
+	case "map":
+		values, err := parseAvroNode(node["values"], names, namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &avroSchema{Type: "map", Values: values}, nil
+	case "enum", "fixed":
+		return nil, fmt.Errorf("avro: %s types are not supported", typeName)
+	}
+
+	schema, err := parseAvroNode(node["type"], names, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if schema.Type == "record" || schema.Type == "union" {
+		return schema, nil
+	}
+	annotated := *schema
+	annotated.LogicalType = logicalType
+	annotated.JSON = isJSON
+	return &annotated, nil
+}
+
+// appendAvro encodes value, of the shapes encoding/json decodes into, against schema
+func appendAvro(buf []byte, schema *avroSchema, value interface{}) ([]byte, error) {
+	switch schema.Type {
+	case "null":
+		if value != nil {
+			return nil, fmt.Errorf("avro: expected null, got %T", value)
+		}
+		return buf, nil
+	case "boolean":
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("avro: expected boolean, got %T", value)
+		}
+		if b {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+	case "int", "long":
+		if i, ok := value.(int64); ok {
+			return binary.AppendVarint(buf, i), nil
+		}
+		n, ok := toFloat(value)
+		if !ok || n != math.Trunc(n) {
+			return nil, fmt.Errorf("avro: expected %s, got %v", schema.Type, value)
+		}
+		return binary.AppendVarint(buf, int64(n)), nil
+	case "float":
+		n, ok := toFloat(value)
+		if !ok {
+			return nil, fmt.Errorf("avro: expected float, got %T", value)
+		}
+		return binary.LittleEndian.AppendUint32(buf, math.Float32bits(float32(n))), nil
+	case "double":
+		n, ok := toFloat(value)
+		if !ok {
+			return nil, fmt.Errorf("avro: expected double, got %T", value)
+		}
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(n)), nil
+	case "bytes":
+		b, ok := value.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("avro: expected bytes, got %T", value)
+		}
+		buf = binary.AppendVarint(buf, int64(len(b)))
+		return append(buf, b...), nil
+	case "string":
+		if schema.JSON {
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			value = string(encoded)
+		}
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("avro: expected string, got %T", value)
+		}
+		buf = binary.AppendVarint(buf, int64(len(s)))
+		return append(buf, s...), nil
+	case "record":
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("avro: expected record %s, got %T", schema.Name, value)
+		}
+		for _, field := range schema.Fields {
+			fieldValue, present := fields[field.Name]
+			if !present && field.HasDefault {
+				fieldValue = field.Default
+			}
+			var err error
+			if buf, err = appendAvro(buf, field.Schema, fieldValue); err != nil {
+				return nil, fmt.Errorf("%s: %w", field.Name, err)
+			}
+		}
+		return buf, nil
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("avro: expected array, got %T", value)
+		}
+		if len(items) > 0 {
+			buf = binary.AppendVarint(buf, int64(len(items)))
+			for _, item := range items {
+				var err error
+				if buf, err = appendAvro(buf, schema.Items, item); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return append(buf, 0), nil
+	case "map":
+		entries, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("avro: expected map, got %T", value)
+		}
+		if len(entries) > 0 {
+			buf = binary.AppendVarint(buf, int64(len(entries)))
+			for key, entry := range entries {
+				buf = binary.AppendVarint(buf, int64(len(key)))
+				buf = append(buf, key...)
+				var err error
+				if buf, err = appendAvro(buf, schema.Values, entry); err != nil {
+					return nil, fmt.Errorf("%s: %w", key, err)
+				}
+			}
+		}
+		return append(buf, 0), nil
+	case "union":
+		index := unionBranch(schema, value)
+		if index < 0 {
+			return nil, fmt.Errorf("avro: no union branch for %T", value)
+		}
+		buf = binary.AppendVarint(buf, int64(index))
+		return appendAvro(buf, schema.Union[index], value)
+	}
+	return nil, fmt.Errorf("avro: unsupported type %s", schema.Type)
+}
+
+// unionBranch returns the index of the first branch of a union value fits
+func unionBranch(schema *avroSchema, value interface{}) int {
+	for i, branch := range schema.Union {
+		switch branch.Type {
+		case "null":
+			if value == nil {
+				return i
+			}
+		case "boolean":
+			if _, ok := value.(bool); ok {
+				return i
+			}
+		case "int", "long", "float", "double":
+			if _, ok := toFloat(value); ok {
+				return i
+			}
+		case "bytes":
+			if _, ok := value.([]byte); ok {
+				return i
+			}
+		case "string":
+			if _, ok := value.(string); ok || (branch.JSON && value != nil) {
+				return i
+			}
+		case "record", "map":
+			if _, ok := value.(map[string]interface{}); ok {
+				return i
+			}
+		case "array":
+			if _, ok := value.([]interface{}); ok {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// readAvro decodes a value of schema from data and returns the rest of data. Longs
+// decode as int64, other numbers as float64, and records and maps as
+// map[string]interface{}.
+func readAvro(data []byte, schema *avroSchema) (interface{}, []byte, error) {
+	switch schema.Type {
+	case "null":
+		return nil, data, nil
+	case "boolean":
+		if len(data) < 1 {
+			return nil, nil, errAvroTruncated
+		}
+		return data[0] != 0, data[1:], nil
+	case "int", "long":
+		n, size := binary.Varint(data)
+		if size <= 0 {
+			return nil, nil, errAvroTruncated
+		}
+		return n, data[size:], nil
+	case "float":
+		if len(data) < 4 {
+			return nil, nil, errAvroTruncated
+		}
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(data))), data[4:], nil
+	case "double":
+		if len(data) < 8 {
+			return nil, nil, errAvroTruncated
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(data)), data[8:], nil
+	case "bytes", "string":
+		b, rest, err := readAvroBytes(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		if schema.Type == "bytes" {
+			return append([]byte(nil), b...), rest, nil
+		}
+		if schema.JSON {
+			var value interface{}
+			if err := json.Unmarshal(b, &value); err != nil {
+				return nil, nil, err
+			}
+			return value, rest, nil
+		}
+		return string(b), rest, nil
+	case "record":
+		fields := make(map[string]interface{}, len(schema.Fields))
+		for _, field := range schema.Fields {
+			value, rest, err := readAvro(data, field.Schema)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", field.Name, err)
+			}
+			fields[field.Name], data = value, rest
+		}
+		return fields, data, nil
+	case "array":
+		items := []interface{}{}
+		err := readAvroBlocks(&data, func() error {
+			item, rest, err := readAvro(data, schema.Items)
+			items, data = append(items, item), rest
+			return err
+		})
+		return items, data, err
+	case "map":
+		entries := make(map[string]interface{})
+		err := readAvroBlocks(&data, func() error {
+			key, rest, err := readAvroBytes(data)
+			if err != nil {
+				return err
+			}
+			value, rest, err := readAvro(rest, schema.Values)
+			entries[string(key)], data = value, rest
+			return err
+		})
+		return entries, data, err
+	case "union":
+		index, size := binary.Varint(data)
+		if size <= 0 {
+			return nil, nil, errAvroTruncated
+		}
+		if index < 0 || int(index) >= len(schema.Union) {
+			return nil, nil, fmt.Errorf("avro: union branch %d out of range", index)
+		}
+		return readAvro(data[size:], schema.Union[index])
+	}
+	return nil, nil, fmt.Errorf("avro: unsupported type %s", schema.Type)
+}
+
+func readAvroBytes(data []byte) ([]byte, []byte, error) {
+	length, size := binary.Varint(data)
+	if size <= 0 || length < 0 || int64(len(data)-size) < length {
+		return nil, nil, errAvroTruncated
+	}
+	return data[size : size+int(length)], data[size+int(length):], nil
+}
+
+// readAvroBlocks calls item for every item of the blocks of an array or map
+func readAvroBlocks(data *[]byte, item func() error) error {
+	for {
+		count, size := binary.Varint(*data)
+		if size <= 0 {
+			return errAvroTruncated
+		}
+		*data = (*data)[size:]
+		if count == 0 {
+			return nil
+		}
+		if count < 0 {
+			// A negative count is followed by the size of the block in bytes
+			count = -count
+			if _, size := binary.Varint(*data); size > 0 {
+				*data = (*data)[size:]
+			} else {
+				return errAvroTruncated
+			}
+		}
+		for ; count > 0; count-- {
+			if err := item(); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,467 @@
+// Package serialization provides the MessagePack serializer, a compact binary
+// alternative to JSON for stores where record size and decode speed matter more
+// than being able to read the records. Events are encoded as a map with the keys
+// of their JSON form; timestamps use the MessagePack timestamp extension, payloads
+// the bin type.
+//
+// Data and metadata decode as they do from JSON: numbers as float64, and values of
+// other Go types than maps, slices, strings, booleans and numbers as their JSON
+// form.
+package serialization
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"simple-event-modeling/common"
+)
+
+// ContentTypeMessagePack is the content type of the MessagePack serializer
+const ContentTypeMessagePack = "application/msgpack"
+
+// MessagePack serializes events in MessagePack
+type MessagePack struct{}
+
+// ContentType returns ContentTypeMessagePack
+func (MessagePack) ContentType() string { return ContentTypeMessagePack }
+
+// msgpackTimestamp is the extension type of timestamps
+const msgpackTimestamp = -1
+
+var errMsgpackTruncated = errors.New("msgpack: truncated data")
+
+// Marshal encodes event in MessagePack
+func (MessagePack) Marshal(event *common.Event) ([]byte, error) {
+	fields := 7
+	if event.EffectiveAt != nil {
+		fields++
+	}
+	if len(event.Payload) > 0 {
+		fields++
+	}
+	if event.ContentType != "" {
+		fields++
+	}
+
+	buf := appendMsgpackMapHeader(make([]byte, 0, 128), fields)
+	buf = appendMsgpackString(appendMsgpackString(buf, "id"), event.ID)
+	buf = appendMsgpackString(appendMsgpackString(buf, "type"), event.Type)
+	buf = appendMsgpackTime(appendMsgpackString(buf, "created_at"), event.CreatedAt)
+	if event.EffectiveAt != nil {
+		buf = appendMsgpackTime(appendMsgpackString(buf, "effective_at"), *event.EffectiveAt)
+	}
+	buf = appendMsgpackString(appendMsgpackString(buf, "aggregate_id"), event.AggregateID)
+	buf = appendMsgpackInt(appendMsgpackString(buf, "version"), int64(event.Version))
+	var err error
+	if buf, err = appendMsgpack(appendMsgpackString(buf, "data"), event.Data); err != nil {
+		return nil, fmt.Errorf("msgpack: data: %w", err)
+	}
+	if buf, err = appendMsgpack(appendMsgpackString(buf, "metadata"), event.Metadata); err != nil {
+		return nil, fmt.Errorf("msgpack: metadata: %w", err)
+	}
+	if len(event.Payload) > 0 {
+		buf = appendMsgpackBytes(appendMsgpackString(buf, "payload"), event.Payload)
+	}
+	if event.ContentType != "" {
+		buf = appendMsgpackString(appendMsgpackString(buf, "content_type"), event.ContentType)
+	}
+	return buf, nil
+}
+
+// Unmarshal decodes a MessagePack event. The envelope is read field by field, so
+// only data and metadata are decoded into maps.
+func (MessagePack) Unmarshal(data []byte) (*common.Event, error) {
+	if len(data) == 0 {
+		return nil, errMsgpackTruncated
+	}
+	var n int
+	var err error
+	switch b := data[0]; {
+	case b&0xf0 == 0x80:
+		n, data = int(b&0x0f), data[1:]
+	case b == 0xde || b == 0xdf:
+		if n, data, err = readMsgpackLength(data[1:], b-0xde+1); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("msgpack: expected a map, got format 0x%02x", b)
+	}
+
+	event := &common.Event{}
+	for i := 0; i < n; i++ {
+		key, rest, err := readMsgpackKey(data)
+		if err != nil {
+			return nil, err
+		}
+		value, rest, err := readMsgpack(rest)
+		if err != nil {
+			return nil, fmt.Errorf("msgpack: %s: %w", key, err)
+		}
+		data = rest
+
+		switch string(key) {
+		case "id":
+			event.ID, _ = value.(string)
+		case "type":
+			event.Type, _ = value.(string)
+		case "created_at":
+			event.CreatedAt, _ = value.(time.Time)
+		case "effective_at":
+			if effectiveAt, ok := value.(time.Time); ok {
+				event.EffectiveAt = &effectiveAt
+			}
+		case "aggregate_id":
+			event.AggregateID, _ = value.(string)
+		case "version":
+			version, _ := value.(float64)
+			event.Version = int(version)
+		case "data":
+			event.Data, _ = value.(map[string]interface{})
+		case "metadata":
+			event.Metadata, _ = value.(map[string]interface{})
+		case "payload":
+			event.Payload, _ = value.([]byte)
+		case "content_type":
+			event.ContentType, _ = value.(string)
+		}
+	}
+	return event, nil
+}
+
+// appendMsgpack encodes a value of the shapes encoding/json decodes into
+func appendMsgpack(buf []byte, value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return append(buf, 0xc0), nil
+	case bool:
+		if v {
+			return append(buf, 0xc3), nil
+		}
+		return append(buf, 0xc2), nil
+	case string:
+		return appendMsgpackString(buf, v), nil
+	case []byte:
+		return appendMsgpackBytes(buf, v), nil
+	case int64:
+		return appendMsgpackInt(buf, v), nil
+	case map[string]interface{}:
+		buf = appendMsgpackMapHeader(buf, len(v))
+		for key, item := range v {
+			buf = appendMsgpackString(buf, key)
+			var err error
+			if buf, err = appendMsgpack(buf, item); err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+		}
+		return buf, nil
+	case []interface{}:
+		buf = appendMsgpackArrayHeader(buf, len(v))
+		for i, item := range v {
+			var err error
+			if buf, err = appendMsgpack(buf, item); err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+		}
+		return buf, nil
+	}
+	if n, ok := toFloat(value); ok {
+		// Whole numbers are stored as integers, which are shorter and decode to the
+		// same float64
+		if n == math.Trunc(n) && math.Abs(n) < 1<<53 {
+			return appendMsgpackInt(buf, int64(n)), nil
+		}
+		buf = append(buf, 0xcb)
+		return binary.BigEndian.AppendUint64(buf, math.Float64bits(n)), nil
+	}
+
+	// Any other value is encoded as its JSON form, e.g. time.Time as a string
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(encoded, &generic); err != nil {
+		return nil, err
+	}
+	return appendMsgpack(buf, generic)
+}
+
+func appendMsgpackInt(buf []byte, n int64) []byte {
+	switch {
+	case n >= 0 && n <= 0x7f:
+		return append(buf, byte(n))
+	case n < 0 && n >= -32:
+		return append(buf, byte(n))
+	case n >= math.MinInt8 && n <= math.MaxInt8:
+		return append(buf, 0xd0, byte(n))
+	case n >= math.MinInt16 && n <= math.MaxInt16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xd1), uint16(n))
+	case n >= math.MinInt32 && n <= math.MaxInt32:
+		return binary.BigEndian.AppendUint32(append(buf, 0xd2), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(buf, 0xd3), uint64(n))
+}
+
+func appendMsgpackString(buf []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		buf = append(buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		buf = append(buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		buf = binary.BigEndian.AppendUint16(append(buf, 0xda), uint16(n))
+	default:
+		buf = binary.BigEndian.AppendUint32(append(buf, 0xdb), uint32(n))
+	}
+	return append(buf, s...)
+}
+
+func appendMsgpackBytes(buf []byte, b []byte) []byte {
+	switch n := len(b); {
+	case n <= math.MaxUint8:
+		buf = append(buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		buf = binary.BigEndian.AppendUint16(append(buf, 0xc5), uint16(n))
+	default:
+		buf = binary.BigEndian.AppendUint32(append(buf, 0xc6), uint32(n))
+	}
+	return append(buf, b...)
+}
+
+func appendMsgpackMapHeader(buf []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(buf, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xde), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(buf, 0xdf), uint32(n))
+}
+
+func appendMsgpackArrayHeader(buf []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(buf, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xdc), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(buf, 0xdd), uint32(n))
+}
+
+// appendMsgpackTime encodes t with the timestamp extension, in its 96-bit form
+func appendMsgpackTime(buf []byte, t time.Time) []byte {
+	buf = append(buf, 0xc7, 12, byte(0xff)) // ext 8, 12 bytes, type -1
+	buf = binary.BigEndian.AppendUint32(buf, uint32(t.Nanosecond()))
+	return binary.BigEndian.AppendUint64(buf, uint64(t.Unix()))
+}
+
+// readMsgpack decodes a value and returns the rest of data. Numbers decode as
+// float64, timestamps as time.Time and bin as []byte.
+func readMsgpack(data []byte) (interface{}, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, errMsgpackTruncated
+	}
+	b, data := data[0], data[1:]
+	switch {
+	case b <= 0x7f:
+		return float64(b), data, nil
+	case b >= 0xe0:
+		return float64(int8(b)), data, nil
+	case b&0xe0 == 0xa0:
+		return readMsgpackString(data, int(b&0x1f))
+	case b&0xf0 == 0x80:
+		return readMsgpackMap(data, int(b&0x0f))
+	case b&0xf0 == 0x90:
+		return readMsgpackArray(data, int(b&0x0f))
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, data, nil
+	case 0xc2:
+		return false, data, nil
+	case 0xc3:
+		return true, data, nil
+	case 0xcc, 0xcd, 0xce, 0xcf, 0xd0, 0xd1, 0xd2, 0xd3, 0xca, 0xcb:
+		return readMsgpackNumber(b, data)
+	case 0xd9, 0xda, 0xdb:
+		n, rest, err := readMsgpackLength(data, b-0xd9)
+		if err != nil {
+			return nil, nil, err
+		}
+		return readMsgpackString(rest, n)
+	case 0xc4, 0xc5, 0xc6:
+		n, rest, err := readMsgpackLength(data, b-0xc4)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(rest) < n {
+			return nil, nil, errMsgpackTruncated
+		}
+		return append([]byte(nil), rest[:n]...), rest[n:], nil
+	case 0xdc, 0xdd:
+		n, rest, err := readMsgpackLength(data, b-0xdc+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		return readMsgpackArray(rest, n)
+	case 0xde, 0xdf:
+		n, rest, err := readMsgpackLength(data, b-0xde+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		return readMsgpackMap(rest, n)
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return readMsgpackExt(data, 1<<(b-0xd4))
+	case 0xc7, 0xc8, 0xc9:
+		n, rest, err := readMsgpackLength(data, b-0xc7)
+		if err != nil {
+			return nil, nil, err
+		}
+		return readMsgpackExt(rest, n)
+	}
+	return nil, nil, fmt.Errorf("msgpack: unsupported format 0x%02x", b)
+}
+
+// readMsgpackLength reads a length of 1, 2 or 4 bytes, for size 0, 1 or 2
+func readMsgpackLength(data []byte, size byte) (int, []byte, error) {
+	switch size {
+	case 0:
+		if len(data) < 1 {
+			return 0, nil, errMsgpackTruncated
+		}
+		return int(data[0]), data[1:], nil
+	case 1:
+		if len(data) < 2 {
+			return 0, nil, errMsgpackTruncated
+		}
+		return int(binary.BigEndian.Uint16(data)), data[2:], nil
+	}
+	if len(data) < 4 {
+		return 0, nil, errMsgpackTruncated
+	}
+	return int(binary.BigEndian.Uint32(data)), data[4:], nil
+}
+
+func readMsgpackNumber(format byte, data []byte) (interface{}, []byte, error) {
+	var size int
+	switch format {
+	case 0xcc, 0xd0:
+		size = 1
+	case 0xcd, 0xd1:
+		size = 2
+	case 0xce, 0xd2, 0xca:
+		size = 4
+	default:
+		size = 8
+	}
+	if len(data) < size {
+		return nil, nil, errMsgpackTruncated
+	}
+	raw, rest := data[:size], data[size:]
+	switch format {
+	case 0xcc:
+		return float64(raw[0]), rest, nil
+	case 0xcd:
+		return float64(binary.BigEndian.Uint16(raw)), rest, nil
+	case 0xce:
+		return float64(binary.BigEndian.Uint32(raw)), rest, nil
+	case 0xcf:
+		return float64(binary.BigEndian.Uint64(raw)), rest, nil
+	case 0xd0:
+		return float64(int8(raw[0])), rest, nil
+	case 0xd1:
+		return float64(int16(binary.BigEndian.Uint16(raw))), rest, nil
+	case 0xd2:
+		return float64(int32(binary.BigEndian.Uint32(raw))), rest, nil
+	case 0xd3:
+		return float64(int64(binary.BigEndian.Uint64(raw))), rest, nil
+	case 0xca:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(raw))), rest, nil
+	}
+	return math.Float64frombits(binary.BigEndian.Uint64(raw)), rest, nil
+}
+
+// readMsgpackKey reads a string without copying it
+func readMsgpackKey(data []byte) ([]byte, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, errMsgpackTruncated
+	}
+	b, data := data[0], data[1:]
+	n := int(b & 0x1f)
+	switch {
+	case b&0xe0 == 0xa0:
+	case b >= 0xd9 && b <= 0xdb:
+		var err error
+		if n, data, err = readMsgpackLength(data, b-0xd9); err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, fmt.Errorf("msgpack: expected a string key, got format 0x%02x", b)
+	}
+	if len(data) < n {
+		return nil, nil, errMsgpackTruncated
+	}
+	return data[:n], data[n:], nil
+}
+
+func readMsgpackString(data []byte, n int) (interface{}, []byte, error) {
+	if len(data) < n {
+		return nil, nil, errMsgpackTruncated
+	}
+	return string(data[:n]), data[n:], nil
+}
+
+func readMsgpackArray(data []byte, n int) (interface{}, []byte, error) {
+	items := make([]interface{}, 0, min(n, len(data)))
+	for i := 0; i < n; i++ {
+		item, rest, err := readMsgpack(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		items, data = append(items, item), rest
+	}
+	return items, data, nil
+}
+
+func readMsgpackMap(data []byte, n int) (interface{}, []byte, error) {
+	entries := make(map[string]interface{}, min(n, len(data)))
+	for i := 0; i < n; i++ {
+		key, rest, err := readMsgpackKey(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		value, rest, err := readMsgpack(rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		entries[string(key)], data = value, rest
+	}
+	return entries, data, nil
+}
+
+// readMsgpackExt decodes an extension value of n bytes; only timestamps are known
+func readMsgpackExt(data []byte, n int) (interface{}, []byte, error) {
+	if len(data) < 1+n {
+		return nil, nil, errMsgpackTruncated
+	}
+	extType, body, rest := int8(data[0]), data[1:1+n], data[1+n:]
+	if extType != msgpackTimestamp {
+		return nil, nil, fmt.Errorf("msgpack: unsupported extension type %d", extType)
+	}
+	switch n {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(body)), 0).UTC(), rest, nil
+	case 8:
+		v := binary.BigEndian.Uint64(body)
+		return time.Unix(int64(v&(1<<34-1)), int64(v>>34)).UTC(), rest, nil
+	case 12:
+		nanos := binary.BigEndian.Uint32(body)
+		return time.Unix(int64(binary.BigEndian.Uint64(body[4:])), int64(nanos)).UTC(), rest, nil
+	}
+	return nil, nil, fmt.Errorf("msgpack: invalid timestamp of %d bytes", n)
+}
@@ -0,0 +1,367 @@
+// Package serialization provides the Protobuf serializer. It hand-rolls the wire
+// format of the message below rather than depending on generated code, so records
+// can be read by any protobuf implementation given this schema:
+//
+//	message Event {
+//	  string id = 1;
+//	  string type = 2;
+//	  google.protobuf.Timestamp created_at = 3;
+//	  google.protobuf.Timestamp effective_at = 4;
+//	  string aggregate_id = 5;
+//	  int64 version = 6;
+//	  google.protobuf.Struct data = 7;
+//	  google.protobuf.Struct metadata = 8;
+//	  bytes payload = 9;
+//	  string content_type = 10;
+//	}
+//
+// Data and metadata values follow the google.protobuf.Value mapping, the same as
+// JSON's: numbers decode as float64, and values of other Go types are encoded as
+// their JSON form.
+package serialization
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"simple-event-modeling/common"
+)
+
+// Protobuf serializes events in protobuf wire format
+type Protobuf struct{}
+
+// ContentType returns ContentTypeProtobuf
+func (Protobuf) ContentType() string { return ContentTypeProtobuf }
+
+// Wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("protobuf: truncated message")
+
+// Marshal encodes event in protobuf wire format
+func (Protobuf) Marshal(event *common.Event) ([]byte, error) {
+	var buf []byte
+	buf = appendString(buf, 1, event.ID)
+	buf = appendString(buf, 2, event.Type)
+	buf = appendMessage(buf, 3, appendTimestamp(nil, event.CreatedAt))
+	if event.EffectiveAt != nil {
+		buf = appendMessage(buf, 4, appendTimestamp(nil, *event.EffectiveAt))
+	}
+	buf = appendString(buf, 5, event.AggregateID)
+	if event.Version != 0 {
+		buf = appendTag(buf, 6, wireVarint)
+		buf = binary.AppendUvarint(buf, uint64(event.Version))
+	}
+	data, err := appendStruct(nil, event.Data)
+	if err != nil {
+		return nil, fmt.Errorf("protobuf: data: %w", err)
+	}
+	buf = appendMessage(buf, 7, data)
+	metadata, err := appendStruct(nil, event.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("protobuf: metadata: %w", err)
+	}
+	buf = appendMessage(buf, 8, metadata)
+	if len(event.Payload) > 0 {
+		buf = appendMessage(buf, 9, event.Payload)
+	}
+	buf = appendString(buf, 10, event.ContentType)
+	return buf, nil
+}
+
+// Unmarshal decodes an event in protobuf wire format
+func (Protobuf) Unmarshal(data []byte) (*common.Event, error) {
+	event := &common.Event{Data: map[string]interface{}{}, Metadata: map[string]interface{}{}}
+	err := eachField(data, func(field int, wire int, value uint64, bytes []byte) error {
+		var err error
+		switch field {
+		case 1:
+			event.ID = string(bytes)
+		case 2:
+			event.Type = string(bytes)
+		case 3:
+			event.CreatedAt, err = readTimestamp(bytes)
+		case 4:
+			var effectiveAt time.Time
+			effectiveAt, err = readTimestamp(bytes)
+			event.EffectiveAt = &effectiveAt
+		case 5:
+			event.AggregateID = string(bytes)
+		case 6:
+			event.Version = int(int64(value))
+		case 7:
+			event.Data, err = readStruct(bytes)
+		case 8:
+			event.Metadata, err = readStruct(bytes)
+		case 9:
+			event.Payload = append([]byte(nil), bytes...)
+		case 10:
+			event.ContentType = string(bytes)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+func appendTag(buf []byte, field, wire int) []byte {
+	return binary.AppendUvarint(buf, uint64(field)<<3|uint64(wire))
+}
+
+func appendString(buf []byte, field int, s string) []byte {
+	if s == "" {
+		return buf
+	}
+	buf = appendTag(buf, field, wireBytes)
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendMessage(buf []byte, field int, message []byte) []byte {
+	buf = appendTag(buf, field, wireBytes)
+	buf = binary.AppendUvarint(buf, uint64(len(message)))
+	return append(buf, message...)
+}
+
+// appendTimestamp encodes a google.protobuf.Timestamp
+func appendTimestamp(buf []byte, t time.Time) []byte {
+	if seconds := t.Unix(); seconds != 0 {
+		buf = appendTag(buf, 1, wireVarint)
+		buf = binary.AppendUvarint(buf, uint64(seconds))
+	}
+	if nanos := t.Nanosecond(); nanos != 0 {
+		buf = appendTag(buf, 2, wireVarint)
+		buf = binary.AppendUvarint(buf, uint64(nanos))
+	}
+	return buf
+}
+
+// appendStruct encodes a google.protobuf.Struct, whose fields are a map<string, Value>
+func appendStruct(buf []byte, fields map[string]interface{}) ([]byte, error) {
+	for key, value := range fields {
+		encoded, err := appendValue(nil, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		entry := appendString(nil, 1, key)
+		entry = appendMessage(entry, 2, encoded)
+		buf = appendMessage(buf, 1, entry)
+	}
+	return buf, nil
+}
+
+// appendValue encodes a google.protobuf.Value
+func appendValue(buf []byte, value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		buf = appendTag(buf, 1, wireVarint)
+		return append(buf, 0), nil
+	case string:
+		buf = appendTag(buf, 3, wireBytes)
+		buf = binary.AppendUvarint(buf, uint64(len(v)))
+		return append(buf, v...), nil
+	case bool:
+		buf = appendTag(buf, 4, wireVarint)
+		if v {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+	case map[string]interface{}:
+		fields, err := appendStruct(nil, v)
+		if err != nil {
+			return nil, err
+		}
+		return appendMessage(buf, 5, fields), nil
+	case []interface{}:
+		var list []byte
+		for i, item := range v {
+			encoded, err := appendValue(nil, item)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			list = appendMessage(list, 1, encoded)
+		}
+		return appendMessage(buf, 6, list), nil
+	}
+	if number, ok := toFloat(value); ok {
+		buf = appendTag(buf, 2, wireFixed64)
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(number)), nil
+	}
+
+	// Any other value is encoded as its JSON form, e.g. time.Time as a string
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(encoded, &generic); err != nil {
+		return nil, err
+	}
+	return appendValue(buf, generic)
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// eachField calls fn for every field of a message, with the value of varint and
+// fixed fields or the bytes of length-delimited ones
+func eachField(data []byte, fn func(field, wire int, value uint64, bytes []byte) error) error {
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errTruncated
+		}
+		data = data[n:]
+		field, wire := int(tag>>3), int(tag&7)
+
+		var value uint64
+		var bytes []byte
+		switch wire {
+		case wireVarint:
+			value, n = binary.Uvarint(data)
+			if n <= 0 {
+				return errTruncated
+			}
+			data = data[n:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return errTruncated
+			}
+			value, data = binary.LittleEndian.Uint64(data), data[8:]
+		case wireFixed32:
+			if len(data) < 4 {
+				return errTruncated
+			}
+			value, data = uint64(binary.LittleEndian.Uint32(data)), data[4:]
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				return errTruncated
+			}
+			bytes, data = data[n:n+int(length)], data[n+int(length):]
+		default:
+			return fmt.Errorf("protobuf: unsupported wire type %d", wire)
+		}
+		if err := fn(field, wire, value, bytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readTimestamp(data []byte) (time.Time, error) {
+	var seconds, nanos int64
+	err := eachField(data, func(field, _ int, value uint64, _ []byte) error {
+		switch field {
+		case 1:
+			seconds = int64(value)
+		case 2:
+			nanos = int64(value)
+		}
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	if seconds == 0 && nanos == 0 {
+		return time.Time{}, nil
+	}
+	return time.Unix(seconds, nanos).UTC(), nil
+}
+
+func readStruct(data []byte) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	err := eachField(data, func(field, _ int, _ uint64, entry []byte) error {
+		if field != 1 {
+			return nil
+		}
+		var key string
+		var value interface{}
+		err := eachField(entry, func(field, _ int, _ uint64, bytes []byte) error {
+			var err error
+			switch field {
+			case 1:
+				key = string(bytes)
+			case 2:
+				value, err = readValue(bytes)
+			}
+			return err
+		})
+		fields[key] = value
+		return err
+	})
+	return fields, err
+}
+
+func readValue(data []byte) (interface{}, error) {
+	var value interface{}
+	err := eachField(data, func(field, _ int, raw uint64, bytes []byte) error {
+		var err error
+		switch field {
+		case 1:
+			value = nil
+		case 2:
+			value = math.Float64frombits(raw)
+		case 3:
+			value = string(bytes)
+		case 4:
+			value = raw != 0
+		case 5:
+			value, err = readStruct(bytes)
+		case 6:
+			list := []interface{}{}
+			err = eachField(bytes, func(field, _ int, _ uint64, item []byte) error {
+				if field != 1 {
+					return nil
+				}
+				decoded, err := readValue(item)
+				list = append(list, decoded)
+				return err
+			})
+			value = list
+		}
+		return err
+	})
+	return value, err
+}
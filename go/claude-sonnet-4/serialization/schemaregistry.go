@@ -0,0 +1,219 @@
+// Package serialization provides clients of Confluent-compatible schema
+// registries, which the Avro serializer registers writer schemas with and looks
+// them up from by ID.
+package serialization
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SchemaRegistry stores schemas under subjects and identifies each distinct schema
+// by a global ID, as the Confluent Schema Registry does
+type SchemaRegistry interface {
+	// Register adds schema to subject, if it isn't there yet, and returns its ID
+	Register(ctx context.Context, subject, schema string) (int, error)
+	// SchemaByID returns the schema with an ID
+	SchemaByID(ctx context.Context, id int) (string, error)
+}
+
+// SchemaRegistryError is an error response of a schema registry
+type SchemaRegistryError struct {
+	StatusCode int
+	ErrorCode  int    `json:"error_code"`
+	Message    string `json:"message"`
+}
+
+func (e *SchemaRegistryError) Error() string {
+	return fmt.Sprintf("schema registry: %s (%d)", e.Message, e.ErrorCode)
+}
+
+// schemaRegistryContentType is the media type of the registry's REST API
+const schemaRegistryContentType = "application/vnd.schemaregistry.v1+json"
+
+// RegistryClient talks to a Confluent-compatible schema registry over its REST API.
+// Schemas are immutable once registered, so IDs and schemas are cached for the
+// life of the client. It is safe for concurrent use.
+type RegistryClient struct {
+	baseURL  string
+	client   *http.Client
+	username string
+	password string
+
+	mu      sync.RWMutex
+	ids     map[string]int // subject + "\x00" + schema -> ID
+	schemas map[int]string
+}
+
+var _ SchemaRegistry = (*RegistryClient)(nil)
+
+// RegistryOption configures a RegistryClient
+type RegistryOption func(*RegistryClient)
+
+// WithBasicAuth authenticates with the registry, e.g. with a Confluent Cloud API key
+func WithBasicAuth(username, password string) RegistryOption {
+	return func(c *RegistryClient) {
+		c.username, c.password = username, password
+	}
+}
+
+// WithHTTPClient sends requests with client instead of one timing out after 10s
+func WithHTTPClient(client *http.Client) RegistryOption {
+	return func(c *RegistryClient) {
+		c.client = client
+	}
+}
+
+// NewRegistryClient creates a client of the registry at baseURL, e.g.
+// "http://localhost:8081"
+func NewRegistryClient(baseURL string, opts ...RegistryOption) *RegistryClient {
+	c := &RegistryClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+		ids:     make(map[string]int),
+		schemas: make(map[int]string),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Register adds schema to subject; registering a schema again returns its ID
+func (c *RegistryClient) Register(ctx context.Context, subject, schema string) (int, error) {
+	key := subject + "\x00" + schema
+	c.mu.RLock()
+	id, ok := c.ids[key]
+	c.mu.RUnlock()
+	if ok {
+		return id, nil
+	}
+
+	body, err := json.Marshal(map[string]string{"schema": schema})
+	if err != nil {
+		return 0, err
+	}
+	var response struct {
+		ID int `json:"id"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/subjects/"+url.PathEscape(subject)+"/versions", body, &response); err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ids[key] = response.ID
+	c.schemas[response.ID] = schema
+	return response.ID, nil
+}
+
+// SchemaByID fetches the schema with an ID
+func (c *RegistryClient) SchemaByID(ctx context.Context, id int) (string, error) {
+	c.mu.RLock()
+	schema, ok := c.schemas[id]
+	c.mu.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	var response struct {
+		Schema string `json:"schema"`
+	}
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/schemas/ids/%d", id), nil, &response); err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.schemas[id] = response.Schema
+	return response.Schema, nil
+}
+
+func (c *RegistryClient) do(ctx context.Context, method, path string, body []byte, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", schemaRegistryContentType)
+	if body != nil {
+		req.Header.Set("Content-Type", schemaRegistryContentType)
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		regErr := &SchemaRegistryError{StatusCode: resp.StatusCode}
+		if json.NewDecoder(resp.Body).Decode(regErr) != nil || regErr.Message == "" {
+			regErr.Message = resp.Status
+		}
+		return regErr
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// MemorySchemaRegistry is an in-process SchemaRegistry for tests and demos. Like
+// the Confluent registry it gives a schema the same ID under every subject.
+type MemorySchemaRegistry struct {
+	mu       sync.Mutex
+	ids      map[string]int
+	schemas  []string
+	subjects map[string][]int
+}
+
+var _ SchemaRegistry = (*MemorySchemaRegistry)(nil)
+
+// NewMemorySchemaRegistry creates an empty registry
+func NewMemorySchemaRegistry() *MemorySchemaRegistry {
+	return &MemorySchemaRegistry{ids: make(map[string]int), subjects: make(map[string][]int)}
+}
+
+// Register adds schema to subject and returns its ID
+func (r *MemorySchemaRegistry) Register(_ context.Context, subject, schema string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, ok := r.ids[schema]
+	if !ok {
+		r.schemas = append(r.schemas, schema)
+		id = len(r.schemas)
+		r.ids[schema] = id
+	}
+	for _, registered := range r.subjects[subject] {
+		if registered == id {
+			return id, nil
+		}
+	}
+	r.subjects[subject] = append(r.subjects[subject], id)
+	return id, nil
+}
+
+// SchemaByID returns the schema with an ID
+func (r *MemorySchemaRegistry) SchemaByID(_ context.Context, id int) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id < 1 || id > len(r.schemas) {
+		return "", &SchemaRegistryError{StatusCode: http.StatusNotFound, ErrorCode: 40403, Message: "Schema not found"}
+	}
+	return r.schemas[id-1], nil
+}
+
+// Versions returns the IDs of the schemas registered under subject, oldest first
+func (r *MemorySchemaRegistry) Versions(subject string) []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int(nil), r.subjects[subject]...)
+}
@@ -0,0 +1,207 @@
+// Package serialization provides the pluggable formats persistent backends store
+// events in. A Serializer turns a whole event into bytes and back; JSON is the
+// default, while Protobuf, MessagePack and Avro trade readability for compactness.
+//
+// Every stored record names the content type it was written with, so a store can
+// hold records of several formats at once: a backend opened with a new serializer
+// keeps reading the records written with the old one, and events can be migrated
+// from one format to another gradually, or not at all. JSON records are the plain
+// JSON of the event, exactly as stores wrote them before serializers were
+// pluggable; records of other formats are framed as a NUL byte, the content type,
+// another NUL byte, and the serialized event.
+package serialization
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"simple-event-modeling/common"
+)
+
+// Content types of the built-in serializers
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+)
+
+// Serializer encodes whole events, envelope and payload, in one format
+type Serializer interface {
+	// ContentType names the format, e.g. "application/json"
+	ContentType() string
+	Marshal(event *common.Event) ([]byte, error)
+	Unmarshal(data []byte) (*common.Event, error)
+}
+
+// JSON is the default Serializer, encoding events as JSON
+type JSON struct{}
+
+// ContentType returns ContentTypeJSON
+func (JSON) ContentType() string { return ContentTypeJSON }
+
+// Marshal encodes event as JSON
+func (JSON) Marshal(event *common.Event) ([]byte, error) {
+	return json.Marshal(event)
+}
+
+// Unmarshal decodes a JSON event
+func (JSON) Unmarshal(data []byte) (*common.Event, error) {
+	var event common.Event
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// UnknownContentTypeError is returned when decoding a record written by a
+// serializer the registry does not know
+type UnknownContentTypeError struct {
+	ContentType string
+	Known       []string
+}
+
+func (e *UnknownContentTypeError) Error() string {
+	return fmt.Sprintf("no serializer for content type %q (known: %v)", e.ContentType, e.Known)
+}
+
+// Registry holds the serializers records can be decoded with, by content type. It
+// is safe for concurrent use.
+type Registry struct {
+	mu          sync.RWMutex
+	serializers map[string]Serializer
+}
+
+// NewRegistry creates a registry of JSON and the given serializers
+func NewRegistry(serializers ...Serializer) *Registry {
+	r := &Registry{serializers: map[string]Serializer{ContentTypeJSON: JSON{}}}
+	for _, serializer := range serializers {
+		r.Register(serializer)
+	}
+	return r
+}
+
+// DefaultRegistry knows the built-in serializers; packages adding a format
+// register it here
+var DefaultRegistry = NewRegistry(Protobuf{}, MessagePack{})
+
+// Register adds a serializer, replacing any of the same content type
+func (r *Registry) Register(serializer Serializer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.serializers[serializer.ContentType()] = serializer
+}
+
+// Lookup returns the serializer of a content type
+func (r *Registry) Lookup(contentType string) (Serializer, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	serializer, ok := r.serializers[contentType]
+	return serializer, ok
+}
+
+// ContentTypes returns the registered content types, sorted
+func (r *Registry) ContentTypes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	types := make([]string, 0, len(r.serializers))
+	for contentType := range r.serializers {
+		types = append(types, contentType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Decode decodes a record written by Encode with any registered serializer
+func (r *Registry) Decode(record []byte) (*common.Event, error) {
+	contentType, body, err := Split(record)
+	if err != nil {
+		return nil, err
+	}
+	serializer, ok := r.Lookup(contentType)
+	if !ok {
+		return nil, &UnknownContentTypeError{ContentType: contentType, Known: r.ContentTypes()}
+	}
+	return serializer.Unmarshal(body)
+}
+
+// Encode serializes event into a record tagged with the serializer's content type
+func Encode(serializer Serializer, event *common.Event) ([]byte, error) {
+	body, err := serializer.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	return Frame(serializer.ContentType(), body), nil
+}
+
+// Frame tags an event serialized in a content type, making it a record
+func Frame(contentType string, body []byte) []byte {
+	if contentType == ContentTypeJSON {
+		return body
+	}
+	record := make([]byte, 0, len(contentType)+2+len(body))
+	record = append(record, 0)
+	record = append(record, contentType...)
+	record = append(record, 0)
+	return append(record, body...)
+}
+
+// Split separates the content type of a record from the serialized event
+func Split(record []byte) (contentType string, body []byte, err error) {
+	if len(record) == 0 || record[0] != 0 {
+		return ContentTypeJSON, record, nil
+	}
+	end := bytes.IndexByte(record[1:], 0)
+	if end < 0 {
+		return "", nil, fmt.Errorf("malformed record: unterminated content type")
+	}
+	return string(record[1 : end+1]), record[end+2:], nil
+}
+
+// Codec writes records with one serializer and reads records written with any
+// serializer of a registry. The zero Codec writes and reads JSON.
+type Codec struct {
+	writer  Serializer
+	readers *Registry
+}
+
+// NewCodec creates a codec writing with writer, or JSON when nil, and reading with
+// readers, or DefaultRegistry when nil. Records of the writer's own format are
+// always readable.
+func NewCodec(writer Serializer, readers *Registry) Codec {
+	return Codec{writer: writer, readers: readers}
+}
+
+// ContentType returns the content type records are written with
+func (c Codec) ContentType() string {
+	return c.serializer().ContentType()
+}
+
+// Encode serializes event into a record
+func (c Codec) Encode(event *common.Event) ([]byte, error) {
+	return Encode(c.serializer(), event)
+}
+
+// Decode decodes a record written in any format the codec reads
+func (c Codec) Decode(record []byte) (*common.Event, error) {
+	contentType, body, err := Split(record)
+	if err != nil {
+		return nil, err
+	}
+	if writer := c.serializer(); contentType == writer.ContentType() {
+		return writer.Unmarshal(body)
+	}
+	readers := c.readers
+	if readers == nil {
+		readers = DefaultRegistry
+	}
+	return readers.Decode(record)
+}
+
+func (c Codec) serializer() Serializer {
+	if c.writer == nil {
+		return JSON{}
+	}
+	return c.writer
+}
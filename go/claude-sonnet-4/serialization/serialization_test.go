@@ -0,0 +1,319 @@
+package serialization
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"simple-event-modeling/common"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func sampleEvent() *common.Event {
+	event := common.NewEvent("ItemAdded", "cart-1", 3, map[string]interface{}{
+		"item":   "apple",
+		"price":  1.25,
+		"count":  2,
+		"gift":   false,
+		"note":   nil,
+		"tags":   []interface{}{"fruit", 1.0},
+		"labels": map[string]interface{}{"color": "red"},
+	}, map[string]interface{}{common.ActorIDKey: "alice"})
+	effectiveAt := time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC)
+	event.EffectiveAt = &effectiveAt
+	return event
+}
+
+func TestSerializers_RoundTrip(t *testing.T) {
+	for _, serializer := range []Serializer{JSON{}, Protobuf{}, MessagePack{}} {
+		t.Run(serializer.ContentType(), func(t *testing.T) {
+			event := sampleEvent()
+			data, err := serializer.Marshal(event)
+			if err != nil {
+				t.Fatalf("Error marshaling: %v", err)
+			}
+			decoded, err := serializer.Unmarshal(data)
+			if err != nil {
+				t.Fatalf("Error unmarshaling: %v", err)
+			}
+
+			if decoded.ID != event.ID || decoded.Type != event.Type || decoded.AggregateID != event.AggregateID || decoded.Version != 3 {
+				t.Errorf("Expected the envelope to round trip, got %+v", decoded)
+			}
+			if !decoded.CreatedAt.Equal(event.CreatedAt) || decoded.EffectiveAt == nil || !decoded.EffectiveAt.Equal(*event.EffectiveAt) {
+				t.Errorf("Expected the timestamps to round trip, got %v and %v", decoded.CreatedAt, decoded.EffectiveAt)
+			}
+			want := map[string]interface{}{
+				"item":   "apple",
+				"price":  1.25,
+				"count":  2.0,
+				"gift":   false,
+				"note":   nil,
+				"tags":   []interface{}{"fruit", 1.0},
+				"labels": map[string]interface{}{"color": "red"},
+			}
+			if !reflect.DeepEqual(decoded.Data, want) {
+				t.Errorf("Expected data %v, got %v", want, decoded.Data)
+			}
+			if decoded.Metadata[common.ActorIDKey] != "alice" {
+				t.Errorf("Expected the metadata to round trip, got %v", decoded.Metadata)
+			}
+		})
+	}
+}
+
+func TestProtobuf_KeepsPayload(t *testing.T) {
+	event := common.NewEvent("ItemAdded", "cart-1", 1, nil, nil)
+	event.Payload = []byte{0, 1, 2, 0xff}
+	event.ContentType = "application/x-protobuf; messageType=cart.ItemAdded"
+
+	data, _ := Protobuf{}.Marshal(event)
+	decoded, err := Protobuf{}.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Error unmarshaling: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.Payload, event.Payload) || decoded.ContentType != event.ContentType {
+		t.Errorf("Expected the payload to round trip, got %v (%s)", decoded.Payload, decoded.ContentType)
+	}
+	if decoded.EffectiveAt != nil {
+		t.Errorf("Expected no effective time, got %v", decoded.EffectiveAt)
+	}
+	if _, err := (Protobuf{}).Unmarshal(data[:len(data)-1]); err == nil {
+		t.Error("Expected a truncated message to be rejected")
+	}
+}
+
+func TestMessagePack_Formats(t *testing.T) {
+	long := strings.Repeat("x", 70000)
+	many := make([]interface{}, 20)
+	wide := make(map[string]interface{})
+	for i := range many {
+		many[i] = float64(i * 1000)
+		wide[string(rune('a'+i))] = -float64(i)
+	}
+	data := map[string]interface{}{
+		"long":     long,
+		"medium":   strings.Repeat("y", 300),
+		"many":     many,
+		"wide":     wide,
+		"negative": -100000.0,
+		"big":      1e15,
+		"fraction": 0.5,
+	}
+	event := common.NewEvent("ItemAdded", "cart-1", 1000, data, nil)
+	event.Payload = []byte(long)
+
+	record, err := MessagePack{}.Marshal(event)
+	if err != nil {
+		t.Fatalf("Error marshaling: %v", err)
+	}
+	decoded, err := MessagePack{}.Unmarshal(record)
+	if err != nil {
+		t.Fatalf("Error unmarshaling: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.Data, data) || len(decoded.Payload) != len(long) || decoded.Version != 1000 {
+		t.Errorf("Expected the event to round trip, got version %d and data %v", decoded.Version, decoded.Data)
+	}
+	if !decoded.CreatedAt.Equal(event.CreatedAt) {
+		t.Errorf("Expected created at %v, got %v", event.CreatedAt, decoded.CreatedAt)
+	}
+	if jsonRecord, _ := (JSON{}).Marshal(event); len(record) >= len(jsonRecord) {
+		t.Errorf("Expected MessagePack to be more compact than JSON, got %d >= %d bytes", len(record), len(jsonRecord))
+	}
+
+	// Timestamps written by other encoders in the 64-bit form
+	stamp := []byte{0x81, 0xaa, 'c', 'r', 'e', 'a', 't', 'e', 'd', '_', 'a', 't', 0xd7, 0xff}
+	stamp = binary.BigEndian.AppendUint64(stamp, 5<<34|1700000000)
+	if decoded, err := (MessagePack{}).Unmarshal(stamp); err != nil || !decoded.CreatedAt.Equal(time.Unix(1700000000, 5)) {
+		t.Errorf("Expected the 64-bit timestamp to decode, got %v (%v)", decoded, err)
+	}
+	if _, err := (MessagePack{}).Unmarshal(record[:len(record)-1]); err == nil {
+		t.Error("Expected truncated data to be rejected")
+	}
+}
+
+func TestCodec_ReadsMixedFormats(t *testing.T) {
+	jsonCodec := NewCodec(nil, nil)
+	protoCodec := NewCodec(Protobuf{}, NewRegistry())
+
+	jsonRecord, _ := jsonCodec.Encode(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+	protoRecord, _ := protoCodec.Encode(common.NewEvent("ItemAdded", "cart-1", 2, nil, nil))
+	if jsonRecord[0] != '{' {
+		t.Errorf("Expected JSON records to stay plain JSON, got %q", jsonRecord)
+	}
+	if contentType, _, _ := Split(protoRecord); contentType != ContentTypeProtobuf {
+		t.Errorf("Expected the record to name its content type, got %q", contentType)
+	}
+
+	for _, codec := range []Codec{jsonCodec, protoCodec} {
+		for _, record := range [][]byte{jsonRecord, protoRecord} {
+			if _, err := codec.Decode(record); err != nil {
+				t.Errorf("Error decoding %q with the %s codec: %v", record, codec.ContentType(), err)
+			}
+		}
+	}
+
+	var unknown *UnknownContentTypeError
+	if _, err := jsonCodec.Decode(Frame("application/x-unknown", []byte("?"))); !errors.As(err, &unknown) {
+		t.Errorf("Expected an UnknownContentTypeError, got %v", err)
+	}
+}
+
+func avroEvents() *common.Registry {
+	events := common.NewRegistry()
+	events.RegisterEvent(common.EventInfo{Name: "ItemAdded", Aggregate: "Cart", Payload: []common.FieldInfo{
+		{Name: "item", Type: "string"},
+		{Name: "price", Type: "number"},
+		{Name: "count", Type: "integer"},
+		{Name: "gift", Type: "boolean"},
+		{Name: "tags", Type: "array"},
+		{Name: "note", Type: "string", Optional: true},
+		{Name: "labels", Type: "object", Optional: true},
+	}})
+	return events
+}
+
+func TestAvro_RoundTrip(t *testing.T) {
+	registry := NewMemorySchemaRegistry()
+	avro := NewAvro(registry, avroEvents())
+	event := sampleEvent()
+	event.Data["unregistered"] = "kept"
+
+	record, err := avro.Marshal(event)
+	if err != nil {
+		t.Fatalf("Error marshaling: %v", err)
+	}
+	if record[0] != 0 || binary.BigEndian.Uint32(record[1:5]) != 1 {
+		t.Errorf("Expected the Confluent wire format with schema ID 1, got % x", record[:5])
+	}
+	if versions := registry.Versions(DefaultAvroNamespace + ".ItemAdded"); len(versions) != 1 {
+		t.Errorf("Expected the schema to be registered under the record name, got %v", versions)
+	}
+
+	// A consumer with its own cache resolves the writer schema through the registry
+	decoded, err := NewAvro(registry, common.NewRegistry()).Unmarshal(record)
+	if err != nil {
+		t.Fatalf("Error unmarshaling: %v", err)
+	}
+	want := map[string]interface{}{
+		"item":         "apple",
+		"price":        1.25,
+		"count":        2.0,
+		"gift":         false,
+		"tags":         []interface{}{"fruit", 1.0},
+		"labels":       map[string]interface{}{"color": "red"},
+		"unregistered": "kept",
+	}
+	if !reflect.DeepEqual(decoded.Data, want) {
+		t.Errorf("Expected data %v, got %v", want, decoded.Data)
+	}
+	if decoded.ID != event.ID || decoded.Version != 3 || decoded.Metadata[common.ActorIDKey] != "alice" {
+		t.Errorf("Expected the envelope to round trip, got %+v", decoded)
+	}
+	if !decoded.CreatedAt.Equal(event.CreatedAt.Truncate(time.Microsecond)) || decoded.EffectiveAt == nil || !decoded.EffectiveAt.Equal(event.EffectiveAt.Truncate(time.Microsecond)) {
+		t.Errorf("Expected timestamps to microsecond precision, got %v and %v", decoded.CreatedAt, decoded.EffectiveAt)
+	}
+
+	delete(event.Data, "item")
+	if _, err := avro.Marshal(event); err == nil {
+		t.Error("Expected an event missing a required field to be rejected")
+	}
+}
+
+func TestAvro_ReadsOlderSchemas(t *testing.T) {
+	registry := NewMemorySchemaRegistry()
+	v1 := common.NewRegistry()
+	v1.RegisterEvent(common.EventInfo{Name: "ItemAdded", Payload: []common.FieldInfo{{Name: "item", Type: "string"}}})
+	old, err := NewAvro(registry, v1).Marshal(common.NewEvent("ItemAdded", "cart-1", 1, map[string]interface{}{"item": "apple"}, nil))
+	if err != nil {
+		t.Fatalf("Error marshaling: %v", err)
+	}
+
+	current := NewAvro(registry, avroEvents())
+	record, err := current.Marshal(sampleEvent())
+	if err != nil {
+		t.Fatalf("Error marshaling: %v", err)
+	}
+	if binary.BigEndian.Uint32(record[1:5]) != 2 {
+		t.Errorf("Expected the new schema to get a new ID, got %d", binary.BigEndian.Uint32(record[1:5]))
+	}
+	decoded, err := current.Unmarshal(old)
+	if err != nil {
+		t.Fatalf("Error unmarshaling the old record: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.Data, map[string]interface{}{"item": "apple"}) {
+		t.Errorf("Expected the old record's data, got %v", decoded.Data)
+	}
+
+	codec := NewCodec(current, NewRegistry(current))
+	framed, _ := codec.Encode(sampleEvent())
+	if _, err := codec.Decode(framed); err != nil {
+		t.Errorf("Error decoding through a codec: %v", err)
+	}
+}
+
+// fakeRegistryServer implements the schema registry endpoints the client uses
+func fakeRegistryServer(t *testing.T) (*httptest.Server, *int) {
+	var mu sync.Mutex
+	var schemas []string
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if user, pass, _ := r.BasicAuth(); user != "key" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error_code":401,"message":"Unauthorized"}`))
+			return
+		}
+		switch {
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/versions"):
+			var body struct{ Schema string }
+			json.NewDecoder(r.Body).Decode(&body)
+			schemas = append(schemas, body.Schema)
+			json.NewEncoder(w).Encode(map[string]int{"id": len(schemas)})
+		case r.Method == http.MethodGet && r.URL.Path == "/schemas/ids/1" && len(schemas) > 0:
+			json.NewEncoder(w).Encode(map[string]string{"schema": schemas[0]})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error_code":40403,"message":"Schema not found"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestRegistryClient(t *testing.T) {
+	server, requests := fakeRegistryServer(t)
+	ctx := context.Background()
+
+	if _, err := NewRegistryClient(server.URL).Register(ctx, "s", `"string"`); err == nil {
+		t.Error("Expected an unauthenticated request to fail")
+	}
+
+	client := NewRegistryClient(server.URL+"/", WithBasicAuth("key", "secret"))
+	record, err := NewAvro(client, avroEvents()).Marshal(sampleEvent())
+	if err != nil {
+		t.Fatalf("Error marshaling: %v", err)
+	}
+	fresh := NewRegistryClient(server.URL, WithBasicAuth("key", "secret"))
+	if _, err := NewAvro(fresh, nil).Unmarshal(record); err != nil {
+		t.Fatalf("Error unmarshaling with a fresh client: %v", err)
+	}
+	before := *requests
+	fresh.SchemaByID(ctx, 1)
+	if *requests != before {
+		t.Error("Expected the schema to be cached")
+	}
+
+	var regErr *SchemaRegistryError
+	if _, err := fresh.SchemaByID(ctx, 42); !errors.As(err, &regErr) || regErr.ErrorCode != 40403 {
+		t.Errorf("Expected a 40403 registry error, got %v", err)
+	}
+}
@@ -0,0 +1,316 @@
+// Package simulator replays a recorded event history through projections, process
+// managers, and timers on a simulated clock, so behavior driven by the passage of
+// time, such as expiring abandoned carts or releasing gift card reservations whose
+// payment never came, can be tested in milliseconds instead of hours.
+//
+// The history is appended to a store of its own, each event at its recorded
+// CreatedAt. Between events the clock jumps from one instant of interest to the
+// next: the due time of the earliest pending timer, the next Step, or the next event.
+// At every instant the simulator runs the tick hooks, then fires due timers, runs
+// the process managers, and catches the projections up until nothing moves, so the
+// simulation only depends on the history and the configuration:
+//
+//	clock := simulator.NewClock(history[0].CreatedAt)
+//	timers, _ := scheduler.New(store, scheduler.WithClock(clock.Now))
+//	sim := simulator.New(store, clock, simulator.Config{Step: time.Hour})
+//	sim.AddScheduler(timers)
+//	sim.AddManager(giftcard.NewCartManager(store, commands, timers, ttl))
+//	report, err := sim.Replay(ctx, history)
+//
+// While a simulation runs, common.DefaultClock follows the simulated clock, so the
+// events handlers create are stamped with simulated time. Simulations must therefore
+// not run concurrently with each other or with code creating events for real.
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"simple-event-modeling/common"
+	"simple-event-modeling/process"
+	"simple-event-modeling/scheduler"
+)
+
+// MaxSettleRounds bounds the rounds of timers and process managers run at one
+// instant, catching reactions that keep triggering each other
+const MaxSettleRounds = 100
+
+// Clock is a simulated clock: it only moves when told to and never goes back. It is
+// safe for concurrent use.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewClock creates a clock reading start
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+// Now returns the simulated time
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d
+func (c *Clock) Advance(d time.Duration) {
+	c.AdvanceTo(c.Now().Add(d))
+}
+
+// AdvanceTo moves the clock to t; earlier times leave it where it is
+func (c *Clock) AdvanceTo(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t.After(c.now) {
+		c.now = t
+	}
+}
+
+// Tick is a hook run at every simulated instant, e.g. a sweep expiring the carts
+// inactive for longer than a threshold as of now
+type Tick func(ctx context.Context, now time.Time) error
+
+// Config selects how simulated time passes. The zero value replays instantly and
+// runs the hooks only at event times and timer due times.
+type Config struct {
+	// Speed scales simulated time to wall time: 60 replays an hour of history in a
+	// minute. 0 replays instantly.
+	Speed float64
+	// Step makes the simulator stop at least every Step of simulated time, for tick
+	// hooks such as periodic sweeps; 0 only stops at events and due timers
+	Step time.Duration
+}
+
+// Report summarizes a simulation
+type Report struct {
+	// Replayed is the number of history events appended
+	Replayed int
+	// Fired is the number of timers that fired
+	Fired int
+	// Reacted is the number of events the process managers processed
+	Reacted int
+	// Instants is the number of simulated instants the hooks ran at
+	Instants int
+	// Start and End are the simulated times the simulation covered
+	Start time.Time
+	End   time.Time
+}
+
+// Simulator replays histories on a simulated clock
+type Simulator struct {
+	store  common.Store
+	clock  *Clock
+	config Config
+	sleep  func(ctx context.Context, d time.Duration) error
+
+	projections []*common.AsyncProjection
+	managers    []*process.Manager
+	schedulers  []*scheduler.Scheduler
+	ticks       []Tick
+	lastStep    time.Time
+}
+
+// New creates a simulator appending to store, which should start empty, and reading
+// time from clock
+func New(store common.Store, clock *Clock, config Config) *Simulator {
+	return &Simulator{store: store, clock: clock, config: config, sleep: sleep}
+}
+
+// AddProjection feeds a projection from the simulated store, recomputing it as of
+// the simulated time if it is a common.Recomputer. The returned AsyncProjection
+// gives access to its checkpoint.
+func (s *Simulator) AddProjection(projection common.Projection) *common.AsyncProjection {
+	async := common.NewAsyncProjection(s.store, projection)
+	async.Now = s.clock.Now
+	s.projections = append(s.projections, async)
+	return async
+}
+
+// AddManager runs a process manager over the simulated store
+func (s *Simulator) AddManager(manager *process.Manager) {
+	s.managers = append(s.managers, manager)
+}
+
+// AddScheduler fires the due timers of a scheduler. The scheduler must read the
+// simulator's clock (scheduler.WithClock(clock.Now)).
+func (s *Simulator) AddScheduler(timers *scheduler.Scheduler) {
+	s.schedulers = append(s.schedulers, timers)
+}
+
+// OnTick adds a hook run at every simulated instant
+func (s *Simulator) OnTick(tick Tick) {
+	s.ticks = append(s.ticks, tick)
+}
+
+// Replay appends the events of history at their recorded times, in order, letting
+// timers, process managers, and projections react in between. Events of system
+// streams, whose IDs start with "$", such as recorded timers, are skipped: the
+// simulation derives them again. An event is appended after whatever the simulation
+// already wrote to its stream, so its version may differ from the recorded one.
+func (s *Simulator) Replay(ctx context.Context, history []*common.Event) (Report, error) {
+	report := Report{Start: s.clock.Now()}
+	err := s.simulate(func() error {
+		for _, recorded := range history {
+			if strings.HasPrefix(recorded.AggregateID, "$") {
+				continue
+			}
+			if err := s.advanceTo(ctx, recorded.CreatedAt, &report); err != nil {
+				return err
+			}
+			event := *recorded
+			event.Version = s.store.GetStreamVersion(event.AggregateID) + 1
+			if err := s.store.Append(&event); err != nil {
+				return fmt.Errorf("replaying %s v%d (%s): %w", recorded.AggregateID, recorded.Version, recorded.Type, err)
+			}
+			report.Replayed++
+			if err := s.settle(ctx, &report); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	report.End = s.clock.Now()
+	return report, err
+}
+
+// RunUntil lets simulated time pass until t without new events, firing the timers
+// due by then
+func (s *Simulator) RunUntil(ctx context.Context, t time.Time) (Report, error) {
+	report := Report{Start: s.clock.Now()}
+	err := s.simulate(func() error {
+		return s.advanceTo(ctx, t, &report)
+	})
+	report.End = s.clock.Now()
+	return report, err
+}
+
+// RunFor lets d of simulated time pass, like RunUntil
+func (s *Simulator) RunFor(ctx context.Context, d time.Duration) (Report, error) {
+	return s.RunUntil(ctx, s.clock.Now().Add(d))
+}
+
+// simulate runs f with common.DefaultClock following the simulated clock
+func (s *Simulator) simulate(f func() error) error {
+	defer func(clock *common.HLC) { common.DefaultClock = clock }(common.DefaultClock)
+	common.DefaultClock = common.NewHLC(s.clock.Now)
+	if s.lastStep.IsZero() {
+		s.lastStep = s.clock.Now()
+	}
+	return f()
+}
+
+// advanceTo moves the clock to target, stopping at every instant of interest on the
+// way
+func (s *Simulator) advanceTo(ctx context.Context, target time.Time, report *Report) error {
+	for s.clock.Now().Before(target) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		next := s.nextInstant(target)
+		if s.config.Speed > 0 {
+			wait := time.Duration(float64(next.Sub(s.clock.Now())) / s.config.Speed)
+			if err := s.sleep(ctx, wait); err != nil {
+				return err
+			}
+		}
+		s.clock.AdvanceTo(next)
+		if s.config.Step > 0 && !next.Before(s.lastStep.Add(s.config.Step)) {
+			s.lastStep = next
+		}
+		if err := s.settle(ctx, report); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextInstant returns the earliest of target, the next step, and the due time of
+// the earliest timer pending after now
+func (s *Simulator) nextInstant(target time.Time) time.Time {
+	now := s.clock.Now()
+	next := target
+	if s.config.Step > 0 {
+		if step := s.lastStep.Add(s.config.Step); step.After(now) && step.Before(next) {
+			next = step
+		}
+	}
+	for _, timers := range s.schedulers {
+		for _, timer := range timers.Pending() {
+			if timer.DueAt.After(now) {
+				if timer.DueAt.Before(next) {
+					next = timer.DueAt
+				}
+				break
+			}
+		}
+	}
+	return next
+}
+
+// settle runs the tick hooks once, then timers, process managers, and projections
+// until none of them moves
+func (s *Simulator) settle(ctx context.Context, report *Report) error {
+	now := s.clock.Now()
+	report.Instants++
+	for _, tick := range s.ticks {
+		if err := tick(ctx, now); err != nil {
+			return fmt.Errorf("tick at %s: %w", now.Format(time.RFC3339), err)
+		}
+	}
+
+	for round := 0; round < MaxSettleRounds; round++ {
+		moved := 0
+		for _, timers := range s.schedulers {
+			fired, err := timers.FireDue()
+			report.Fired += fired
+			moved += fired
+			if err != nil {
+				return err
+			}
+		}
+		for _, manager := range s.managers {
+			processed, err := manager.Process(ctx)
+			report.Reacted += processed
+			moved += processed
+			if err != nil {
+				return err
+			}
+		}
+		if moved == 0 {
+			return s.catchUp()
+		}
+	}
+	return fmt.Errorf("simulation did not settle at %s after %d rounds", now.Format(time.RFC3339), MaxSettleRounds)
+}
+
+func (s *Simulator) catchUp() error {
+	for _, projection := range s.projections {
+		if err := projection.CatchUp(); err != nil {
+			return err
+		}
+		if err := projection.Recompute(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sleep waits for d of wall time or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,165 @@
+package simulator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/cart"
+	"simple-event-modeling/common"
+	"simple-event-modeling/giftcard"
+	"simple-event-modeling/scheduler"
+)
+
+var start = time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+
+const (
+	cardID = "6f1c2b7e-0d7a-4c55-9e43-1a2b3c4d5e01"
+	cartA  = "6f1c2b7e-0d7a-4c55-9e43-1a2b3c4d5e0a"
+	cartB  = "6f1c2b7e-0d7a-4c55-9e43-1a2b3c4d5e0b"
+)
+
+// at stamps an event with a recorded time after start
+func at(offset time.Duration, event *common.Event) *common.Event {
+	event.CreatedAt = start.Add(offset)
+	return event
+}
+
+// history is four and a half hours of shopping: cart-a pays with a gift card and is
+// abandoned, cart-b gets an item every hour and a half until the end
+func history() []*common.Event {
+	return []*common.Event{
+		at(0, giftcard.NewGiftCardIssuedEvent(cardID, 50)),
+		at(time.Minute, cart.NewCartCreatedEvent(cartA)),
+		at(2*time.Minute, cart.NewItemAddedEvent(cartA, 2, "apple")),
+		at(3*time.Minute, cart.NewGiftCardAppliedEvent(cartA, 3, cardID, 20)),
+		at(10*time.Minute, cart.NewCartCreatedEvent(cartB)),
+		at(11*time.Minute, cart.NewItemAddedEvent(cartB, 2, "pear")),
+		at(90*time.Minute, cart.NewItemAddedEvent(cartB, 3, "plum")),
+		at(3*time.Hour, cart.NewItemAddedEvent(cartB, 4, "fig")),
+		at(270*time.Minute, cart.NewItemAddedEvent(cartB, 5, "kiwi")),
+	}
+}
+
+// simulation wires the gift card process manager, with 30 minute reservations, and
+// an hourly sweep expiring carts inactive for two hours
+func simulation(t *testing.T, config Config) (*Simulator, common.Store) {
+	t.Helper()
+	store := common.NewEventStore()
+	clock := NewClock(start)
+	commands := bus.NewCommandBus(bus.RetryOnConflict(20), bus.Validation())
+	giftcard.RegisterCommands(commands, store)
+	cart.RegisterCommands(commands, store)
+	timers, err := scheduler.New(store, scheduler.WithClock(clock.Now))
+	if err != nil {
+		t.Fatalf("Error creating scheduler: %v", err)
+	}
+
+	sim := New(store, clock, config)
+	sim.AddScheduler(timers)
+	sim.AddManager(giftcard.NewCartManager(store, commands, timers, 30*time.Minute))
+	abandoned := cart.NewAbandonedCartsProjection()
+	sim.AddProjection(abandoned)
+	sim.OnTick(func(ctx context.Context, now time.Time) error {
+		_, err := cart.ExpireAbandoned(ctx, commands, abandoned.Inactive(2*time.Hour, now))
+		return err
+	})
+	return sim, store
+}
+
+func typesOf(store common.Store, streamID string) []string {
+	events, _ := store.GetStream(streamID)
+	types := make([]string, len(events))
+	for i, event := range events {
+		types[i] = event.Type
+	}
+	return types
+}
+
+func TestSimulator_ReplaysTimeoutsInstantly(t *testing.T) {
+	sim, store := simulation(t, Config{Step: time.Hour})
+
+	began := time.Now()
+	report, err := sim.Replay(context.Background(), history())
+	if err != nil {
+		t.Fatalf("Error replaying: %v", err)
+	}
+	if wall := time.Since(began); wall > time.Second {
+		t.Errorf("Expected hours of simulated time to replay instantly, took %s", wall)
+	}
+	if report.Replayed != 9 || report.Fired != 1 || !report.End.Equal(start.Add(270*time.Minute)) {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+
+	a := typesOf(store, cartA)
+	if len(a) != 6 || a[3] != cart.EventTypeGiftCardConfirmed || a[4] != cart.EventTypeGiftCardRemoved || a[5] != cart.EventTypeCartExpired {
+		t.Errorf("Expected cart-a's reservation to be confirmed, then expire, and the cart after it, got %v", a)
+	}
+	events, _ := store.GetStream(cartA)
+	if removed := events[4].CreatedAt; removed.Sub(start) < 33*time.Minute || removed.Sub(start) > 34*time.Minute {
+		t.Errorf("Expected the gift card removed when the reservation timed out, at 33m, got %s", removed.Sub(start))
+	}
+	if expired := events[5].CreatedAt.Sub(start); expired < 2*time.Hour+33*time.Minute || expired > 3*time.Hour+time.Second {
+		t.Errorf("Expected cart-a expired at the first sweep two hours after its last activity, got %s", expired)
+	}
+	if b := typesOf(store, cartB); len(b) != 5 {
+		t.Errorf("Expected the active cart-b untouched, got %v", b)
+	}
+
+	card := giftcard.NewGiftCardAggregate(store)
+	card.Hydrate(cardID)
+	if card.Available() != 50 {
+		t.Errorf("Expected the expired reservation released, got %v available", card.Available())
+	}
+
+	if _, err := sim.RunFor(context.Background(), 3*time.Hour); err != nil {
+		t.Fatalf("Error running on: %v", err)
+	}
+	if b := typesOf(store, cartB); len(b) != 6 || b[5] != cart.EventTypeCartExpired {
+		t.Errorf("Expected cart-b expired once left alone for the sweep, got %v", b)
+	}
+}
+
+func TestSimulator_IsDeterministic(t *testing.T) {
+	timeline := func() []string {
+		sim, store := simulation(t, Config{Step: 15 * time.Minute})
+		if _, err := sim.Replay(context.Background(), history()); err != nil {
+			t.Fatalf("Error replaying: %v", err)
+		}
+		var lines []string
+		for _, event := range store.GetAllEvents() {
+			lines = append(lines, event.CreatedAt.Format(time.RFC3339Nano)+" "+event.Type)
+		}
+		return lines
+	}
+
+	first, second := timeline(), timeline()
+	if len(first) != len(second) {
+		t.Fatalf("Expected the same timeline twice, got %d and %d events", len(first), len(second))
+	}
+	for i := range first {
+		if first[i] != second[i] {
+			t.Errorf("Expected the same event %d, got %q and %q", i, first[i], second[i])
+		}
+	}
+	if clock := common.DefaultClock.Now(); clock.Before(time.Now().Add(-time.Minute)) {
+		t.Errorf("Expected the default clock restored after the simulation, got %s", clock)
+	}
+}
+
+func TestSimulator_ScalesTime(t *testing.T) {
+	sim, _ := simulation(t, Config{Speed: 3600})
+	var waited time.Duration
+	sim.sleep = func(_ context.Context, d time.Duration) error {
+		waited += d
+		return nil
+	}
+
+	if _, err := sim.Replay(context.Background(), history()); err != nil {
+		t.Fatalf("Error replaying: %v", err)
+	}
+	if waited < 4499*time.Millisecond || waited > 4501*time.Millisecond {
+		t.Errorf("Expected four and a half hours replayed in four and a half seconds at 3600x, got %s", waited)
+	}
+}
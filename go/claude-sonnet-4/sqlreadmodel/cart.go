@@ -0,0 +1,199 @@
+// Package sqlreadmodel provides the cart summary and cart history read models kept
+// in SQL tables.
+package sqlreadmodel
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+
+	"simple-event-modeling/cart"
+	"simple-event-modeling/common"
+)
+
+//go:embed migrations
+var migrations embed.FS
+
+// CartSummariesName is the name the cart summaries are migrated and checkpointed
+// under
+const CartSummariesName = "cart_summaries"
+
+// CartHistoryName is the name the cart history is migrated and checkpointed under
+const CartHistoryName = "cart_history"
+
+// Statuses of the cart_summaries table
+const (
+	StatusOpen       = "open"
+	StatusCheckedOut = "checked_out"
+	StatusExpired    = "expired"
+)
+
+const (
+	insertSummarySQL    = `INSERT INTO cart_summaries (cart_id, status, version, created_at, updated_at) VALUES (?, 'open', ?, ?, ?)`
+	touchSummarySQL     = `UPDATE cart_summaries SET version = ?, updated_at = ? WHERE cart_id = ?`
+	renameSummarySQL    = `UPDATE cart_summaries SET name = ? WHERE cart_id = ?`
+	setStatusSQL        = `UPDATE cart_summaries SET status = ? WHERE cart_id = ?`
+	setDeletedSQL       = `UPDATE cart_summaries SET deleted = ? WHERE cart_id = ?`
+	addItemCountSQL     = `UPDATE cart_summaries SET item_count = item_count + ? WHERE cart_id = ?`
+	clearItemCountSQL   = `UPDATE cart_summaries SET item_count = 0 WHERE cart_id = ?`
+	addLineSQL          = `INSERT INTO cart_summary_lines (cart_id, line, item, quantity) VALUES (?, ?, ?, 1) ON CONFLICT (cart_id, line) DO UPDATE SET quantity = quantity + 1`
+	removeLineUnitSQL   = `UPDATE cart_summary_lines SET quantity = quantity - 1 WHERE cart_id = ? AND line = ?`
+	deleteEmptyLinesSQL = `DELETE FROM cart_summary_lines WHERE cart_id = ? AND quantity <= 0`
+	deleteLinesSQL      = `DELETE FROM cart_summary_lines WHERE cart_id = ?`
+	insertHistorySQL    = `INSERT INTO cart_history (cart_id, version, event_id, event_type, data, occurred_at) VALUES (?, ?, ?, ?, ?, ?)`
+)
+
+// CartSummaries keeps a row per cart in the cart_summaries table, with its name,
+// status, soft-deletion flag, item count and last version, and a row per line of
+// its items in cart_summary_lines. Status is open, checked_out or expired; deleted
+// is 1 while the cart is soft-deleted.
+//
+// Open carts with items, for instance:
+//
+//	SELECT cart_id, item_count FROM cart_summaries
+//	WHERE status = 'open' AND deleted = 0 AND item_count > 0
+type CartSummaries struct{}
+
+// NewCartSummaries creates the cart summaries projection
+func NewCartSummaries() *CartSummaries {
+	return &CartSummaries{}
+}
+
+// Name returns the name of the projection
+func (p *CartSummaries) Name() string {
+	return CartSummariesName
+}
+
+// Consumes returns the event types the projection applies
+func (p *CartSummaries) Consumes() []string {
+	return []string{
+		cart.EventTypeCartCreated, cart.EventTypeItemAdded, cart.EventTypeItemRemoved, cart.EventTypeCartCleared,
+		cart.EventTypeCartRenamed, cart.EventTypeCartCheckedOut, cart.EventTypeCartExpired,
+		cart.EventTypeCartDeleted, cart.EventTypeCartRestored,
+	}
+}
+
+// Migrations returns the migrations creating the cart_summaries and
+// cart_summary_lines tables
+func (p *CartSummaries) Migrations() ([]Migration, error) {
+	return LoadMigrations(migrations, "migrations/cart_summaries")
+}
+
+// Apply writes a cart event to the summary of its cart
+func (p *CartSummaries) Apply(ctx context.Context, tx *sql.Tx, event *common.Event) error {
+	cartID := event.AggregateID
+	at := formatTime(event.CreatedAt)
+	if event.Type == cart.EventTypeCartCreated {
+		_, err := tx.ExecContext(ctx, insertSummarySQL, cartID, event.Version, at, at)
+		return err
+	}
+
+	var err error
+	switch event.Type {
+	case cart.EventTypeItemAdded:
+		err = p.addItem(ctx, tx, event)
+	case cart.EventTypeItemRemoved:
+		err = p.removeItem(ctx, tx, event)
+	case cart.EventTypeCartCleared:
+		if _, err = tx.ExecContext(ctx, deleteLinesSQL, cartID); err == nil {
+			_, err = tx.ExecContext(ctx, clearItemCountSQL, cartID)
+		}
+	case cart.EventTypeCartRenamed:
+		var renamed *common.TypedEvent[cart.NameData]
+		if renamed, err = common.AsTyped[cart.NameData](event); err == nil {
+			_, err = tx.ExecContext(ctx, renameSummarySQL, renamed.Data.Name, cartID)
+		}
+	case cart.EventTypeCartCheckedOut:
+		_, err = tx.ExecContext(ctx, setStatusSQL, StatusCheckedOut, cartID)
+	case cart.EventTypeCartExpired:
+		_, err = tx.ExecContext(ctx, setStatusSQL, StatusExpired, cartID)
+	case cart.EventTypeCartDeleted:
+		_, err = tx.ExecContext(ctx, setDeletedSQL, 1, cartID)
+	case cart.EventTypeCartRestored:
+		_, err = tx.ExecContext(ctx, setDeletedSQL, 0, cartID)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, touchSummarySQL, event.Version, at, cartID)
+	return err
+}
+
+func (p *CartSummaries) addItem(ctx context.Context, tx *sql.Tx, event *common.Event) error {
+	added, err := common.AsTyped[cart.ItemData](event)
+	if err != nil || added.Data.Item == "" {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, addLineSQL, event.AggregateID, added.Data.Line(), added.Data.Item); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, addItemCountSQL, 1, event.AggregateID)
+	return err
+}
+
+// removeItem takes a unit off the item's line, dropping the line once empty; a
+// line the cart does not hold leaves the item count alone
+func (p *CartSummaries) removeItem(ctx context.Context, tx *sql.Tx, event *common.Event) error {
+	removed, err := common.AsTyped[cart.ItemData](event)
+	if err != nil || removed.Data.Item == "" {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, removeLineUnitSQL, event.AggregateID, removed.Data.Line())
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, deleteEmptyLinesSQL, event.AggregateID); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, addItemCountSQL, -1, event.AggregateID)
+	return err
+}
+
+// CartHistory keeps every event of every cart in the cart_history table, keyed by
+// cart and version, with the event data as a JSON object so other tools can read
+// it with SQLite's JSON functions:
+//
+//	SELECT cart_id, occurred_at FROM cart_history
+//	WHERE event_type = 'ItemAdded' AND json_extract(data, '$.item') = 'sku-42'
+type CartHistory struct {
+	consumes []string
+}
+
+// NewCartHistory creates the cart history projection, recording the events
+// registered for the Cart aggregate in registry
+func NewCartHistory(registry *common.Registry) *CartHistory {
+	return &CartHistory{consumes: registry.EventTypes(cart.AggregateTypeCart)}
+}
+
+// Name returns the name of the projection
+func (p *CartHistory) Name() string {
+	return CartHistoryName
+}
+
+// Consumes returns the event types the projection applies
+func (p *CartHistory) Consumes() []string {
+	return p.consumes
+}
+
+// Migrations returns the migrations creating the cart_history table
+func (p *CartHistory) Migrations() ([]Migration, error) {
+	return LoadMigrations(migrations, "migrations/cart_history")
+}
+
+// Apply records a cart event in the history
+func (p *CartHistory) Apply(ctx context.Context, tx *sql.Tx, event *common.Event) error {
+	payload := event.Data
+	if payload == nil {
+		payload = map[string]interface{}{}
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, insertHistorySQL, event.AggregateID, event.Version, event.ID, event.Type, string(data), formatTime(event.CreatedAt))
+	return err
+}
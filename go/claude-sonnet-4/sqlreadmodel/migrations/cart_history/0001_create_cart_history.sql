@@ -0,0 +1,12 @@
+-- Every event of every cart, data as JSON
+CREATE TABLE cart_history (
+    cart_id     TEXT NOT NULL,
+    version     INTEGER NOT NULL,
+    event_id    TEXT NOT NULL,
+    event_type  TEXT NOT NULL,
+    data        TEXT NOT NULL,
+    occurred_at TEXT NOT NULL,
+    PRIMARY KEY (cart_id, version)
+);
+
+CREATE INDEX cart_history_event_type ON cart_history (event_type, occurred_at);
@@ -0,0 +1,19 @@
+-- One row per cart, and one per line of its items
+CREATE TABLE cart_summaries (
+    cart_id    TEXT PRIMARY KEY,
+    name       TEXT NOT NULL DEFAULT '',
+    status     TEXT NOT NULL DEFAULT 'open',
+    deleted    INTEGER NOT NULL DEFAULT 0,
+    item_count INTEGER NOT NULL DEFAULT 0,
+    version    INTEGER NOT NULL,
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL
+);
+
+CREATE TABLE cart_summary_lines (
+    cart_id  TEXT NOT NULL,
+    line     TEXT NOT NULL,
+    item     TEXT NOT NULL,
+    quantity INTEGER NOT NULL,
+    PRIMARY KEY (cart_id, line)
+);
@@ -0,0 +1 @@
+CREATE INDEX cart_summaries_status ON cart_summaries (status, deleted);
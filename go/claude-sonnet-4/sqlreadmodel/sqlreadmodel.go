@@ -0,0 +1,310 @@
+// Package sqlreadmodel persists read models in SQL tables, so they survive restarts
+// and can be queried with SQL by other tools. Every Projection owns its tables and
+// ships the schema migrations creating them; a Runner applies the migrations and
+// then folds the global event log into the tables, recording its checkpoint in the
+// same transaction as the rows it writes, so a restarted runner resumes exactly
+// where the last committed batch ended.
+//
+// Statements are written for SQLite. The package goes through database/sql and
+// links no driver: programs import one, e.g. modernc.org/sqlite or
+// github.com/mattn/go-sqlite3, and hand the opened *sql.DB to NewRunner.
+//
+// Migrations are SQL files named <version>_<name>.sql, e.g.
+// 0001_create_cart_summaries.sql, usually embedded with go:embed and read with
+// LoadMigrations. They are applied in version order, each in its own transaction,
+// and recorded in the sem_migrations table so every version runs once.
+package sqlreadmodel
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"simple-event-modeling/common"
+)
+
+// DefaultBatchSize is the number of events a Runner applies per transaction when
+// no batch size is given
+const DefaultBatchSize = 500
+
+// Migration is a versioned change to the schema of a projection's tables
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// Projection is a read model kept in SQL tables
+type Projection interface {
+	// Name identifies the projection's migrations and checkpoint
+	Name() string
+	// Consumes returns the event types the projection applies
+	Consumes() []string
+	// Migrations returns the migrations creating the projection's tables
+	Migrations() ([]Migration, error)
+	// Apply writes an event to the projection's tables within tx
+	Apply(ctx context.Context, tx *sql.Tx, event *common.Event) error
+}
+
+const (
+	createMigrationsTableSQL = `CREATE TABLE IF NOT EXISTS sem_migrations (projection TEXT NOT NULL, version INTEGER NOT NULL, name TEXT NOT NULL, applied_at TEXT NOT NULL, PRIMARY KEY (projection, version))`
+	selectMigrationsSQL      = `SELECT version FROM sem_migrations WHERE projection = ?`
+	insertMigrationSQL       = `INSERT INTO sem_migrations (projection, version, name, applied_at) VALUES (?, ?, ?, ?)`
+	createCheckpointsSQL     = `CREATE TABLE IF NOT EXISTS sem_checkpoints (projection TEXT PRIMARY KEY, position INTEGER NOT NULL)`
+	selectCheckpointSQL      = `SELECT position FROM sem_checkpoints WHERE projection = ?`
+	upsertCheckpointSQL      = `INSERT INTO sem_checkpoints (projection, position) VALUES (?, ?) ON CONFLICT (projection) DO UPDATE SET position = excluded.position`
+)
+
+// LoadMigrations reads the migrations in a directory of fsys, sorted by version.
+// Files not ending in .sql are ignored.
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	versions := make(map[int]string)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		prefix, name, _ := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: file name must start with a positive version", entry.Name())
+		}
+		if other, exists := versions[version]; exists {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", other, entry.Name())
+		}
+		versions[version] = entry.Name()
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(data)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrate applies the migrations of a projection that have not been applied yet and
+// returns how many were applied
+func Migrate(ctx context.Context, db *sql.DB, projection string, migrations []Migration) (int, error) {
+	if _, err := db.ExecContext(ctx, createMigrationsTableSQL); err != nil {
+		return 0, fmt.Errorf("creating migrations table: %w", err)
+	}
+	applied, err := appliedVersions(ctx, db, projection)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, migration := range migrations {
+		if applied[migration.Version] {
+			continue
+		}
+		if err := migrate(ctx, db, projection, migration); err != nil {
+			return count, fmt.Errorf("%s: migration %d (%s): %w", projection, migration.Version, migration.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+func appliedVersions(ctx context.Context, db *sql.DB, projection string) (map[int]bool, error) {
+	rows, err := db.QueryContext(ctx, selectMigrationsSQL, projection)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// migrate runs the statements of one migration and records it in one transaction
+func migrate(ctx context.Context, db *sql.DB, projection string, migration Migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range splitStatements(migration.SQL) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, insertMigrationSQL, projection, migration.Version, migration.Name, formatTime(time.Now())); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// splitStatements splits a migration into its statements, which end with a
+// semicolon at the end of a line. Comment lines are dropped, since not every driver
+// accepts a statement made of comments only.
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
+
+// Runner folds the global event log into the tables of a projection, following it
+// with a common.Follower. The
+// checkpoint is stored in the sem_checkpoints table and advanced in the
+// transaction applying each batch, so the tables never hold an event twice or miss
+// one, whenever the runner stops.
+type Runner struct {
+	db         *sql.DB
+	follower   *common.Follower
+	projection Projection
+	consumes   map[string]bool
+	batchSize  int
+}
+
+// Option configures a Runner
+type Option func(*Runner)
+
+// WithBatchSize applies up to size events per transaction instead of
+// DefaultBatchSize
+func WithBatchSize(size int) Option {
+	return func(r *Runner) {
+		if size > 0 {
+			r.batchSize = size
+		}
+	}
+}
+
+// NewRunner migrates the projection's tables and restores its checkpoint
+func NewRunner(ctx context.Context, db *sql.DB, store common.Store, projection Projection, opts ...Option) (*Runner, error) {
+	r := &Runner{
+		db:         db,
+		follower:   common.NewFollower(projection.Name(), store),
+		projection: projection,
+		consumes:   make(map[string]bool),
+		batchSize:  DefaultBatchSize,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	for _, eventType := range projection.Consumes() {
+		r.consumes[eventType] = true
+	}
+
+	migrations, err := projection.Migrations()
+	if err != nil {
+		return nil, fmt.Errorf("%s: loading migrations: %w", projection.Name(), err)
+	}
+	if _, err := Migrate(ctx, db, projection.Name(), migrations); err != nil {
+		return nil, err
+	}
+	if _, err := db.ExecContext(ctx, createCheckpointsSQL); err != nil {
+		return nil, fmt.Errorf("creating checkpoints table: %w", err)
+	}
+	var position int
+	err = db.QueryRowContext(ctx, selectCheckpointSQL, projection.Name()).Scan(&position)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s: reading checkpoint: %w", projection.Name(), err)
+	}
+	r.follower.SetCheckpoint(position)
+	return r, nil
+}
+
+// Name returns the name of the projection
+func (r *Runner) Name() string {
+	return r.projection.Name()
+}
+
+// Consumes returns the event types the projection applies
+func (r *Runner) Consumes() []string {
+	return r.projection.Consumes()
+}
+
+// Checkpoint returns the position of the last committed event
+func (r *Runner) Checkpoint() common.Checkpoint {
+	return r.follower.Checkpoint()
+}
+
+// OnProgress sets a function called after every Process, e.g. to record metrics
+func (r *Runner) OnProgress(progress common.ProgressFunc) {
+	r.follower.OnProgress(progress)
+}
+
+// Process applies the events appended since the checkpoint and returns how many
+// events were processed. Events of types the projection does not consume advance
+// the checkpoint without touching its tables.
+func (r *Runner) Process(ctx context.Context) (int, error) {
+	return r.follower.Process(r.batchSize, func(batch []*common.Event, positions []int) error {
+		return r.applyBatch(ctx, batch, positions)
+	})
+}
+
+// applyBatch applies a batch of events at the given positions and advances the
+// checkpoint past them in one transaction
+func (r *Runner) applyBatch(ctx context.Context, batch []*common.Event, positions []int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i, event := range batch {
+		if !r.consumes[event.Type] {
+			continue
+		}
+		if err := r.projection.Apply(ctx, tx, event); err != nil {
+			return fmt.Errorf("%s: event %d (%s): %w", r.projection.Name(), positions[i], event.Type, err)
+		}
+	}
+	end := positions[len(positions)-1]
+	if _, err := tx.ExecContext(ctx, upsertCheckpointSQL, r.projection.Name(), end); err != nil {
+		return fmt.Errorf("%s: recording checkpoint: %w", r.projection.Name(), err)
+	}
+	return tx.Commit()
+}
+
+// Run processes new events every interval until ctx is cancelled. Errors are retried
+// on the next tick.
+func (r *Runner) Run(ctx context.Context, interval time.Duration) {
+	common.Poll(ctx, interval, func() error {
+		r.Process(ctx)
+		return nil
+	})
+}
+
+// formatTime formats times as SQLite's date functions expect them
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
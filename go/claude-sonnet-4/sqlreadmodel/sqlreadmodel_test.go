@@ -0,0 +1,238 @@
+package sqlreadmodel
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"simple-event-modeling/cart"
+	"simple-event-modeling/common"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	_ "modernc.org/sqlite"
+)
+
+// openDB opens the SQLite database file of a test, which outlives the *sql.DB so
+// tests can restart runners against the same tables
+func openDB(t *testing.T, path string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+type summaryRow struct {
+	name, status string
+	deleted      int
+	itemCount    int
+	version      int
+}
+
+// summary reads the summary row of a cart, nil when it has none
+func summary(t *testing.T, db *sql.DB, cartID string) *summaryRow {
+	t.Helper()
+	row := &summaryRow{}
+	err := db.QueryRow(`SELECT name, status, deleted, item_count, version FROM cart_summaries WHERE cart_id = ?`, cartID).
+		Scan(&row.name, &row.status, &row.deleted, &row.itemCount, &row.version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("Error reading summary of %s: %v", cartID, err)
+	}
+	return row
+}
+
+// query returns the rows of a query as strings
+func query(t *testing.T, db *sql.DB, statement string, args ...interface{}) [][]string {
+	t.Helper()
+	rows, err := db.Query(statement, args...)
+	if err != nil {
+		t.Fatalf("Error querying: %v", err)
+	}
+	defer rows.Close()
+	columns, _ := rows.Columns()
+	var result [][]string
+	for rows.Next() {
+		values := make([]string, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			t.Fatalf("Error scanning: %v", err)
+		}
+		result = append(result, values)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("Error reading rows: %v", err)
+	}
+	return result
+}
+
+// schemaObjects counts the tables and indexes the migrations created
+func schemaObjects(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	return len(query(t, db, `SELECT name FROM sqlite_master WHERE type IN ('table', 'index') AND name NOT LIKE 'sem_%' AND name NOT LIKE 'sqlite_%'`))
+}
+
+func newRunners(t *testing.T, db *sql.DB, store common.Store) (*Runner, *Runner) {
+	t.Helper()
+	summaries, err := NewRunner(context.Background(), db, store, NewCartSummaries(), WithBatchSize(2))
+	if err != nil {
+		t.Fatalf("Error creating summaries runner: %v", err)
+	}
+	history, err := NewRunner(context.Background(), db, store, NewCartHistory(common.DefaultRegistry))
+	if err != nil {
+		t.Fatalf("Error creating history runner: %v", err)
+	}
+	return summaries, history
+}
+
+func TestRunner_ProjectsCartsAndResumesAfterRestart(t *testing.T) {
+	ctx := context.Background()
+	store := common.NewEventStore()
+	store.Append(cart.NewCartCreatedEvent("cart-1"))
+	store.Append(cart.NewItemAddedEvent("cart-1", 2, "bread"))
+	store.Append(cart.NewItemAddedEvent("cart-1", 3, "bread"))
+	store.Append(cart.NewVariantAddedEvent("cart-1", 4, "shirt", map[string]string{"size": "M"}))
+	store.Append(cart.NewItemPriceChangedEvent(1, "bread", 2.5))
+	store.Append(cart.NewCartRenamedEvent("cart-1", 5, "Weekly shop"))
+	store.Append(cart.NewCartCreatedEvent("cart-2"))
+
+	path := filepath.Join(t.TempDir(), "readmodels.db")
+	db := openDB(t, path)
+	summaries, history := newRunners(t, db, store)
+	if objects := schemaObjects(t, db); objects != 5 {
+		t.Fatalf("Expected 3 tables and 2 indexes, got %d", objects)
+	}
+	if processed, err := summaries.Process(ctx); err != nil || processed != 7 {
+		t.Fatalf("Expected 7 processed events, got %d (%v)", processed, err)
+	}
+	if _, err := history.Process(ctx); err != nil {
+		t.Fatalf("Error processing history: %v", err)
+	}
+
+	row := summary(t, db, "cart-1")
+	if row == nil || row.name != "Weekly shop" || row.itemCount != 3 || row.version != 5 || row.status != StatusOpen {
+		t.Fatalf("Unexpected summary: %+v", row)
+	}
+	lines := query(t, db, `SELECT line, quantity FROM cart_summary_lines WHERE cart_id = ? ORDER BY line`, "cart-1")
+	if !reflect.DeepEqual(lines, [][]string{{"bread", "2"}, {"shirt[size=M]", "1"}}) {
+		t.Errorf("Unexpected lines: %v", lines)
+	}
+	rows := query(t, db, `SELECT data FROM cart_history ORDER BY rowid`)
+	if len(rows) != 6 || rows[1][0] != `{"item":"bread"}` || rows[5][0] != "{}" {
+		t.Errorf("Expected the 6 cart events without the catalog price, got %v", rows)
+	}
+	// The documented query over the JSON data
+	breads := query(t, db, `SELECT cart_id FROM cart_history WHERE event_type = 'ItemAdded' AND json_extract(data, '$.item') = 'bread'`)
+	if len(breads) != 2 {
+		t.Errorf("Expected 2 bread additions, got %v", breads)
+	}
+
+	// Restarted runners migrate nothing and resume after the checkpoint
+	store.Append(cart.NewItemRemovedEvent("cart-1", 6, "bread"))
+	store.Append(cart.NewVariantRemovedEvent("cart-1", 7, "shirt", map[string]string{"size": "M"}))
+	store.Append(cart.NewItemRemovedEvent("cart-1", 8, "milk"))
+	store.Append(cart.NewCartCheckedOutEvent("cart-1", 9))
+	store.Append(cart.NewCartDeletedEvent("cart-2", 2))
+	db.Close()
+	db = openDB(t, path)
+	summaries, history = newRunners(t, db, store)
+	if migrations := query(t, db, `SELECT projection, version FROM sem_migrations`); len(migrations) != 3 {
+		t.Fatalf("Expected migrations to run once, got %v", migrations)
+	}
+	if summaries.Checkpoint().Position != 7 {
+		t.Fatalf("Expected the checkpoint to be restored, got %+v", summaries.Checkpoint())
+	}
+	if processed, err := summaries.Process(ctx); err != nil || processed != 5 {
+		t.Fatalf("Expected 5 processed events, got %d (%v)", processed, err)
+	}
+	if _, err := history.Process(ctx); err != nil {
+		t.Fatalf("Error processing history: %v", err)
+	}
+
+	row = summary(t, db, "cart-1")
+	if row.itemCount != 1 || row.status != StatusCheckedOut || row.version != 9 {
+		t.Errorf("Unexpected summary after restart: %+v", row)
+	}
+	lines = query(t, db, `SELECT line, quantity FROM cart_summary_lines WHERE cart_id = ?`, "cart-1")
+	if !reflect.DeepEqual(lines, [][]string{{"bread", "1"}}) {
+		t.Errorf("Expected one bread left, got %v", lines)
+	}
+	if summary(t, db, "cart-2").deleted != 1 {
+		t.Error("Expected cart-2 to be marked deleted")
+	}
+	rows = query(t, db, `SELECT COUNT(*) FROM cart_history`)
+	checkpoint := query(t, db, `SELECT position FROM sem_checkpoints WHERE projection = ?`, CartHistoryName)
+	if rows[0][0] != "11" || checkpoint[0][0] != "12" {
+		t.Errorf("Expected 11 history rows up to position 12, got %v rows, checkpoint %v", rows, checkpoint)
+	}
+}
+
+func TestRunner_RollsBackFailedBatches(t *testing.T) {
+	ctx := context.Background()
+	store := common.NewEventStore()
+	store.Append(cart.NewCartCreatedEvent("cart-1"))
+	store.Append(cart.NewItemAddedEvent("cart-1", 2, "bread"))
+	store.Append(cart.NewItemAddedEvent("cart-1", 3, "jam"))
+
+	db := openDB(t, filepath.Join(t.TempDir(), "readmodels.db"))
+	summaries, _ := newRunners(t, db, store)
+	// Fail the first line written, after the summary row was inserted
+	if _, err := db.Exec(`CREATE TRIGGER fail_lines BEFORE INSERT ON cart_summary_lines BEGIN SELECT RAISE(ABORT, 'disk full'); END`); err != nil {
+		t.Fatalf("Error creating trigger: %v", err)
+	}
+	if _, err := summaries.Process(ctx); err == nil || !strings.Contains(err.Error(), "event 2 (ItemAdded)") {
+		t.Fatalf("Expected the failing event to be reported, got %v", err)
+	}
+	if summaries.Checkpoint().Position != 0 || summary(t, db, "cart-1") != nil {
+		t.Fatalf("Expected the failed batch to be rolled back, checkpoint %+v", summaries.Checkpoint())
+	}
+	if _, err := db.Exec(`DROP TRIGGER fail_lines`); err != nil {
+		t.Fatalf("Error dropping trigger: %v", err)
+	}
+
+	if processed, err := summaries.Process(ctx); err != nil || processed != 3 {
+		t.Fatalf("Expected the retry to process 3 events, got %d (%v)", processed, err)
+	}
+	if row := summary(t, db, "cart-1"); row.itemCount != 2 {
+		t.Errorf("Expected each item counted once, got %+v", row)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"ok/0002_add_index.sql":   {Data: []byte("CREATE INDEX i ON t (a);")},
+		"ok/0001_create.sql":      {Data: []byte("-- the table\nCREATE TABLE t (\n  a TEXT\n);\n\nCREATE TABLE u (b TEXT);\n")},
+		"ok/README.md":            {Data: []byte("not a migration")},
+		"unnumbered/create.sql":   {Data: []byte("CREATE TABLE t (a TEXT);")},
+		"duplicate/0001_a.sql":    {Data: []byte("CREATE TABLE a (a TEXT);")},
+		"duplicate/001_again.sql": {Data: []byte("CREATE TABLE b (b TEXT);")},
+	}
+
+	migrations, err := LoadMigrations(fsys, "ok")
+	if err != nil {
+		t.Fatalf("Error loading migrations: %v", err)
+	}
+	if len(migrations) != 2 || migrations[0].Version != 1 || migrations[0].Name != "create" || migrations[1].Version != 2 {
+		t.Fatalf("Unexpected migrations: %+v", migrations)
+	}
+	statements := splitStatements(migrations[0].SQL)
+	if len(statements) != 2 || statements[0] != "CREATE TABLE t (\n  a TEXT\n)" || statements[1] != "CREATE TABLE u (b TEXT)" {
+		t.Errorf("Unexpected statements: %q", statements)
+	}
+	if _, err := LoadMigrations(fsys, "unnumbered"); err == nil {
+		t.Error("Expected a migration without a version to be rejected")
+	}
+	if _, err := LoadMigrations(fsys, "duplicate"); err == nil {
+		t.Error("Expected migrations with the same version to be rejected")
+	}
+}
@@ -0,0 +1,147 @@
+// Package typedstore provides a Store decorator rejecting events appended to a
+// stream of another aggregate, catching cross-domain pollution such as an order
+// event landing in a cart stream before it is stored.
+//
+// The aggregate of a stream comes from the registry: a stream named after a
+// registered category ("cart-<id>") belongs to the category's aggregate; any other
+// stream belongs to the aggregate of its first event. Only the event types
+// registered for that aggregate may then be appended to it:
+//
+//	store := typedstore.New(common.NewEventStore(), common.DefaultRegistry)
+//	aggregate := cart.NewCartAggregate(store)
+//
+// Streams whose first event type is not registered, such as timer streams, and
+// system streams, whose IDs start with "$", are left unchecked.
+package typedstore
+
+import (
+	"strings"
+	"sync"
+
+	"simple-event-modeling/common"
+)
+
+// Store is a Store that checks the type of every appended event against the
+// aggregate of its stream
+type Store struct {
+	common.Store
+	registry *common.Registry
+
+	mu         sync.Mutex
+	aggregates map[string]string // stream ID -> aggregate, "" for unchecked streams
+}
+
+var (
+	_ common.Store         = (*Store)(nil)
+	_ common.BatchAppender = (*Store)(nil)
+)
+
+// New wraps store, checking appends against the categories and events of registry
+func New(store common.Store, registry *common.Registry) *Store {
+	return &Store{Store: store, registry: registry, aggregates: make(map[string]string)}
+}
+
+// Append stores the event if its type is allowed in its stream, returning a
+// *common.EventNotAllowedError otherwise
+func (s *Store) Append(event *common.Event) error {
+	if err := s.Check(event); err != nil {
+		return err
+	}
+	return s.Store.Append(event)
+}
+
+// AppendBatch checks every event before appending any, then appends them atomically
+// when the underlying store is a BatchAppender, one by one otherwise
+func (s *Store) AppendBatch(events []*common.Event) error {
+	pending := make(map[string]string)
+	for _, event := range events {
+		if _, known := pending[event.AggregateID]; !known && event.Version == 1 {
+			pending[event.AggregateID] = s.aggregateOfNew(event)
+		}
+		aggregate, known := pending[event.AggregateID]
+		if !known {
+			aggregate = s.AggregateOf(event.AggregateID)
+		}
+		if err := s.check(event, aggregate); err != nil {
+			return err
+		}
+	}
+
+	batch, ok := s.Store.(common.BatchAppender)
+	if !ok {
+		for _, event := range events {
+			if err := s.Store.Append(event); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return batch.AppendBatch(events)
+}
+
+// Check returns a *common.EventNotAllowedError if event may not be appended to its
+// stream. A stream's first event is always allowed unless the stream is named after
+// a category of another aggregate.
+func (s *Store) Check(event *common.Event) error {
+	if s.Store.GetStreamVersion(event.AggregateID) == 0 {
+		return s.check(event, s.aggregateOfNew(event))
+	}
+	return s.check(event, s.AggregateOf(event.AggregateID))
+}
+
+// AggregateOf returns the aggregate an existing stream belongs to, or "" when its
+// appends are not checked
+func (s *Store) AggregateOf(streamID string) string {
+	if strings.HasPrefix(streamID, "$") {
+		return ""
+	}
+	if category, ok := s.registry.CategoryOf(streamID); ok {
+		return category.Aggregate
+	}
+
+	s.mu.Lock()
+	aggregate, cached := s.aggregates[streamID]
+	s.mu.Unlock()
+	if cached {
+		return aggregate
+	}
+
+	events, err := s.Store.GetStream(streamID)
+	if err != nil || len(events) == 0 {
+		return ""
+	}
+	if info, ok := s.registry.Event(events[0].Type); ok {
+		aggregate = info.Aggregate
+	}
+	s.mu.Lock()
+	s.aggregates[streamID] = aggregate
+	s.mu.Unlock()
+	return aggregate
+}
+
+// aggregateOfNew returns the aggregate a stream started by event belongs to
+func (s *Store) aggregateOfNew(event *common.Event) string {
+	if strings.HasPrefix(event.AggregateID, "$") {
+		return ""
+	}
+	if category, ok := s.registry.CategoryOf(event.AggregateID); ok {
+		return category.Aggregate
+	}
+	info, _ := s.registry.Event(event.Type)
+	return info.Aggregate
+}
+
+func (s *Store) check(event *common.Event, aggregate string) error {
+	if aggregate == "" {
+		return nil
+	}
+	if info, ok := s.registry.Event(event.Type); ok && info.Aggregate == aggregate {
+		return nil
+	}
+	return &common.EventNotAllowedError{
+		StreamID:  event.AggregateID,
+		EventType: event.Type,
+		Aggregate: aggregate,
+		Allowed:   s.registry.EventTypes(aggregate),
+	}
+}
@@ -0,0 +1,93 @@
+package typedstore
+
+import (
+	"errors"
+	"testing"
+
+	"simple-event-modeling/common"
+)
+
+func testRegistry() *common.Registry {
+	registry := common.NewRegistry()
+	registry.RegisterCategory(common.CategoryInfo{Name: "cart", Aggregate: "Cart"})
+	registry.RegisterCategory(common.CategoryInfo{Name: "order", Aggregate: "Order"})
+	registry.RegisterEvent(common.EventInfo{Name: "CartCreated", Aggregate: "Cart"})
+	registry.RegisterEvent(common.EventInfo{Name: "ItemAdded", Aggregate: "Cart"})
+	registry.RegisterEvent(common.EventInfo{Name: "OrderPlaced", Aggregate: "Order"})
+	return registry
+}
+
+func TestStore_RejectsEventsOfOtherAggregates(t *testing.T) {
+	store := New(common.NewEventStore(), testRegistry())
+
+	if err := store.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil)); err != nil {
+		t.Fatalf("Expected a cart event in a cart stream, got %v", err)
+	}
+	var notAllowed *common.EventNotAllowedError
+	err := store.Append(common.NewEvent("OrderPlaced", "cart-1", 2, nil, nil))
+	if !errors.As(err, &notAllowed) || notAllowed.Aggregate != "Cart" || len(notAllowed.Allowed) != 2 {
+		t.Errorf("Expected EventNotAllowedError for an order event in a cart stream, got %v", err)
+	}
+	if !errors.Is(err, common.ErrEventNotAllowed) || common.ErrorCodeOf(err) != common.CodeEventNotAllowed {
+		t.Errorf("Expected the error to match ErrEventNotAllowed, got %v", err)
+	}
+	if version := store.GetStreamVersion("cart-1"); version != 1 {
+		t.Errorf("Expected the rejected event not stored, got version %d", version)
+	}
+
+	if err := store.Append(common.NewEvent("CartCreated", "order-1", 1, nil, nil)); !errors.As(err, &notAllowed) {
+		t.Errorf("Expected EventNotAllowedError starting an order stream with a cart event, got %v", err)
+	}
+	if err := store.Append(common.NewEvent("Unregistered", "cart-1", 2, nil, nil)); !errors.As(err, &notAllowed) {
+		t.Errorf("Expected EventNotAllowedError for an unregistered event in a cart stream, got %v", err)
+	}
+}
+
+func TestStore_TypesUncategorizedStreamsByFirstEvent(t *testing.T) {
+	inner := common.NewEventStore()
+	inner.Append(common.NewEvent("CartCreated", "3f2a", 1, nil, nil))
+	store := New(inner, testRegistry())
+
+	if aggregate := store.AggregateOf("3f2a"); aggregate != "Cart" {
+		t.Errorf("Expected a stream started by CartCreated to be a cart, got %q", aggregate)
+	}
+	if err := store.Append(common.NewEvent("ItemAdded", "3f2a", 2, nil, nil)); err != nil {
+		t.Errorf("Expected ItemAdded allowed, got %v", err)
+	}
+	if err := store.Append(common.NewEvent("OrderPlaced", "3f2a", 3, nil, nil)); !errors.Is(err, common.ErrEventNotAllowed) {
+		t.Errorf("Expected OrderPlaced rejected, got %v", err)
+	}
+
+	if err := store.Append(common.NewEvent("TimerFired", "timeouts", 1, nil, nil)); err != nil {
+		t.Errorf("Expected any event to start an uncategorized stream, got %v", err)
+	}
+	if err := store.Append(common.NewEvent("OrderPlaced", "timeouts", 2, nil, nil)); err != nil {
+		t.Errorf("Expected a stream of unregistered events to be unchecked, got %v", err)
+	}
+	if err := store.Append(common.NewEvent("OrderPlaced", "$commands", 1, nil, nil)); err != nil {
+		t.Errorf("Expected system streams to be unchecked, got %v", err)
+	}
+}
+
+func TestStore_AppendBatchIsAllOrNothing(t *testing.T) {
+	store := New(common.NewEventStore(), testRegistry())
+
+	err := store.AppendBatch([]*common.Event{
+		common.NewEvent("CartCreated", "7c1d", 1, nil, nil),
+		common.NewEvent("ItemAdded", "7c1d", 2, nil, nil),
+		common.NewEvent("OrderPlaced", "7c1d", 3, nil, nil),
+	})
+	if !errors.Is(err, common.ErrEventNotAllowed) {
+		t.Errorf("Expected the batch rejected, got %v", err)
+	}
+	if version := store.GetStreamVersion("7c1d"); version != 0 {
+		t.Errorf("Expected no event of a rejected batch stored, got version %d", version)
+	}
+
+	if err := store.AppendBatch([]*common.Event{
+		common.NewEvent("CartCreated", "7c1d", 1, nil, nil),
+		common.NewEvent("ItemAdded", "7c1d", 2, nil, nil),
+	}); err != nil {
+		t.Errorf("Expected the batch appended, got %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+// Package unique provides the command middleware consulting an index.
+package unique
+
+import (
+	"context"
+	"errors"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+
+	"github.com/google/uuid"
+)
+
+// pendingPrefix marks the owner of a key claimed for a command creating its
+// aggregate, until the handler returns the aggregate's ID
+const pendingPrefix = "pending:"
+
+// KeyFunc returns the key a command claims, or "" when it claims none
+type KeyFunc func(ctx context.Context, command common.Command) string
+
+// Middleware enforces the index on the commands of a bus.
+//
+// A command whose type is in claims claims the key its KeyFunc returns before it is
+// handled, and is rejected with a *KeyTakenError when another owner holds the key.
+// The owner is the aggregate the command targets; for a command creating its
+// aggregate, the key is held by a pending claim and transferred to the new
+// aggregate once the handler returns its event. A key newly claimed for a command
+// that fails is released again; a pending claim left behind by a process that died
+// mid-command expires after the index's pending TTL (see WithPendingTTL).
+//
+// A command whose type is in releasing, such as one closing its aggregate, releases
+// every key its aggregate holds once it is handled.
+//
+// An index error after a successful handler is returned along with the event.
+func (i *Index) Middleware(claims map[string]KeyFunc, releasing ...string) bus.Middleware {
+	releases := make(map[string]bool, len(releasing))
+	for _, commandType := range releasing {
+		releases[commandType] = true
+	}
+
+	return func(next bus.Handler) bus.Handler {
+		return func(ctx context.Context, command common.Command) (*common.Event, error) {
+			if releases[command.CommandType()] {
+				event, err := next(ctx, command)
+				if err != nil {
+					return nil, err
+				}
+				return event, i.ReleaseOwner(event.AggregateID)
+			}
+
+			keyOf, ok := claims[command.CommandType()]
+			if !ok {
+				return next(ctx, command)
+			}
+			key := keyOf(ctx, command)
+			if key == "" {
+				return next(ctx, command)
+			}
+
+			owner := command.AggregateID()
+			if owner == "" {
+				owner = pendingPrefix + uuid.New().String()
+			}
+			claimed, err := i.claim(key, owner)
+			if err != nil {
+				return nil, err
+			}
+
+			event, err := next(ctx, command)
+			if err != nil {
+				if claimed {
+					err = errors.Join(err, i.Release(key, owner))
+				}
+				return nil, err
+			}
+			if event.AggregateID != owner {
+				return event, i.Transfer(key, owner, event.AggregateID)
+			}
+			return event, nil
+		}
+	}
+}
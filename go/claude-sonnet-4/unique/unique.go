@@ -0,0 +1,317 @@
+// Package unique provides an event-sourced uniqueness index for set validation that
+// no single aggregate can do, such as allowing one open cart per customer or one
+// account per email address. An Index claims keys for owners, usually aggregate IDs,
+// by appending to its own stream, "$unique-<name>". Every claim of an index goes
+// through that stream, so two processes claiming the same key conflict on append,
+// and the loser sees the winner's claim when it retries.
+//
+// Command middleware consults the index before commands are handled (see
+// Index.Middleware), rejecting a command whose key another owner holds.
+package unique
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"simple-event-modeling/common"
+)
+
+// StreamPrefix prefixes the name of an index to form its stream ID
+const StreamPrefix = "$unique-"
+
+// Event types written by an index
+const (
+	EventTypeKeyClaimed  = "UniqueKeyClaimed"
+	EventTypeKeyReleased = "UniqueKeyReleased"
+)
+
+// CodeKeyTaken is the rejection code of a command claiming a key another owner holds
+const CodeKeyTaken common.ErrorCode = "unique_key_taken"
+
+// DefaultPendingTTL is how long a pending claim holds its key before another owner
+// may take it over, when no TTL is given
+const DefaultPendingTTL = time.Minute
+
+// maxAttempts bounds how often an append losing a race with another process is retried
+const maxAttempts = 10
+
+// KeyTakenError rejects claiming a key another owner holds
+type KeyTakenError struct {
+	Index string
+	Key   string
+	Owner string
+}
+
+func (e *KeyTakenError) Error() string {
+	return fmt.Sprintf("%s %q is already held by %s", e.Index, e.Key, e.Owner)
+}
+
+func (e *KeyTakenError) Is(target error) bool   { return target == common.ErrInvalidCommand }
+func (e *KeyTakenError) Code() common.ErrorCode { return CodeKeyTaken }
+
+// KeyNotHeldError rejects releasing or transferring a key its owner does not hold
+type KeyNotHeldError struct {
+	Index string
+	Key   string
+	Owner string
+}
+
+func (e *KeyNotHeldError) Error() string {
+	return fmt.Sprintf("%s %q is not held by %s", e.Index, e.Key, e.Owner)
+}
+
+func (e *KeyNotHeldError) Is(target error) bool { return target == common.ErrInvalidCommand }
+
+// Index maps keys to the owners holding them. Its state is rebuilt from its stream,
+// catching up before every read and decision, so several processes may share one
+// index. It is safe for concurrent use.
+//
+// A pending claim, held for a command creating its aggregate (see Middleware),
+// expires once it is older than the index's pending TTL, so a process dying before
+// it transfers or releases the claim does not hold the key forever. An expired
+// pending claim counts as free: the next claim of its key releases it first.
+type Index struct {
+	store      common.Store
+	name       string
+	pendingTTL time.Duration
+	now        func() time.Time
+
+	mu        sync.Mutex
+	version   int
+	owners    map[string]string    // key -> owner
+	claimedAt map[string]time.Time // key -> time of the claim
+}
+
+// Option configures an Index
+type Option func(*Index)
+
+// WithPendingTTL sets how long pending claims hold their key (default
+// DefaultPendingTTL). It must exceed the time commands take to be handled.
+func WithPendingTTL(ttl time.Duration) Option {
+	return func(i *Index) {
+		i.pendingTTL = ttl
+	}
+}
+
+// WithClock replaces time.Now, e.g. with a simulated clock in tests
+func WithClock(now func() time.Time) Option {
+	return func(i *Index) {
+		i.now = now
+	}
+}
+
+// NewIndex creates an index named name kept in store, e.g. "open-cart-per-customer"
+func NewIndex(store common.Store, name string, opts ...Option) *Index {
+	i := &Index{
+		store:      store,
+		name:       name,
+		pendingTTL: DefaultPendingTTL,
+		now:        time.Now,
+		owners:     make(map[string]string),
+		claimedAt:  make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// StreamID returns the ID of the stream holding the index's claims
+func (i *Index) StreamID() string {
+	return StreamPrefix + i.name
+}
+
+// Owner returns the owner holding key, or "" when it is free
+func (i *Index) Owner(key string) (string, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if err := i.catchUp(); err != nil {
+		return "", err
+	}
+	return i.holder(key), nil
+}
+
+// Keys returns the keys an owner holds, sorted
+func (i *Index) Keys(owner string) ([]string, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if err := i.catchUp(); err != nil {
+		return nil, err
+	}
+	return i.keysOf(owner), nil
+}
+
+// Claim records that owner holds key. It fails with a *KeyTakenError when another
+// owner holds it, and does nothing when owner already does.
+func (i *Index) Claim(key, owner string) error {
+	_, err := i.claim(key, owner)
+	return err
+}
+
+// Release frees a key its owner holds, failing with a *KeyNotHeldError otherwise
+func (i *Index) Release(key, owner string) error {
+	return i.decide(func() ([]*common.Event, error) {
+		if i.owners[key] != owner {
+			return nil, &KeyNotHeldError{Index: i.name, Key: key, Owner: owner}
+		}
+		return []*common.Event{i.event(EventTypeKeyReleased, 1, key, owner)}, nil
+	})
+}
+
+// ReleaseOwner frees every key an owner holds, e.g. when the aggregate holding them
+// is closed
+func (i *Index) ReleaseOwner(owner string) error {
+	return i.decide(func() ([]*common.Event, error) {
+		keys := i.keysOf(owner)
+		events := make([]*common.Event, len(keys))
+		for n, key := range keys {
+			events[n] = i.event(EventTypeKeyReleased, n+1, key, owner)
+		}
+		return events, nil
+	})
+}
+
+// Transfer hands a key from the owner holding it to another, failing with a
+// *KeyNotHeldError when from does not hold it
+func (i *Index) Transfer(key, from, to string) error {
+	return i.decide(func() ([]*common.Event, error) {
+		if i.owners[key] != from {
+			return nil, &KeyNotHeldError{Index: i.name, Key: key, Owner: from}
+		}
+		return []*common.Event{i.event(EventTypeKeyClaimed, 1, key, to)}, nil
+	})
+}
+
+// claim records that owner holds key and reports whether the key was newly claimed
+func (i *Index) claim(key, owner string) (bool, error) {
+	claimed := false
+	err := i.decide(func() ([]*common.Event, error) {
+		switch current := i.holder(key); current {
+		case owner:
+			claimed = false
+			return nil, nil
+		case "":
+			claimed = true
+			if expired := i.owners[key]; expired != "" {
+				return []*common.Event{
+					i.event(EventTypeKeyReleased, 1, key, expired),
+					i.event(EventTypeKeyClaimed, 2, key, owner),
+				}, nil
+			}
+			return []*common.Event{i.event(EventTypeKeyClaimed, 1, key, owner)}, nil
+		default:
+			return nil, &KeyTakenError{Index: i.name, Key: key, Owner: current}
+		}
+	})
+	return claimed, err
+}
+
+// holder returns the owner holding key, or "" when it is free or held by an expired
+// pending claim
+func (i *Index) holder(key string) string {
+	owner := i.owners[key]
+	if strings.HasPrefix(owner, pendingPrefix) && i.now().Sub(i.claimedAt[key]) > i.pendingTTL {
+		return ""
+	}
+	return owner
+}
+
+// decide catches up with the stream and appends the events returned by decision,
+// deciding again against fresh state when another process appended first
+func (i *Index) decide(decision func() ([]*common.Event, error)) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	var conflict *common.ConcurrencyError
+	for attempt := 1; ; attempt++ {
+		if err := i.catchUp(); err != nil {
+			return err
+		}
+		events, err := decision()
+		if err != nil || len(events) == 0 {
+			return err
+		}
+
+		uow := common.NewUnitOfWork(i.store, nil)
+		for _, event := range events {
+			if err = uow.Append(event); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = uow.Commit()
+		}
+		if err == nil {
+			for _, event := range events {
+				i.apply(event)
+			}
+			return nil
+		}
+		if !errors.As(err, &conflict) || attempt >= maxAttempts {
+			return err
+		}
+	}
+}
+
+// catchUp applies the events appended to the stream since the index last read it
+func (i *Index) catchUp() error {
+	head, err := i.store.HeadEvent(i.StreamID())
+	if errors.Is(err, common.ErrStreamNotFound) || (err == nil && head.Version == i.version) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	events, err := i.store.GetStream(i.StreamID())
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		if event.Version > i.version {
+			i.apply(event)
+		}
+	}
+	return nil
+}
+
+func (i *Index) apply(event *common.Event) {
+	key, _ := event.Data["key"].(string)
+	owner, _ := event.Data["owner"].(string)
+	switch event.Type {
+	case EventTypeKeyClaimed:
+		i.owners[key] = owner
+		i.claimedAt[key] = event.CreatedAt
+	case EventTypeKeyReleased:
+		delete(i.owners, key)
+		delete(i.claimedAt, key)
+	}
+	i.version = event.Version
+}
+
+// event creates the n-th event of a decision
+func (i *Index) event(eventType string, n int, key, owner string) *common.Event {
+	data := map[string]interface{}{
+		"key":   key,
+		"owner": owner,
+	}
+	event := common.NewEvent(eventType, i.StreamID(), i.version+n, data, nil)
+	event.CreatedAt = i.now().UTC()
+	return event
+}
+
+func (i *Index) keysOf(owner string) []string {
+	keys := make([]string, 0)
+	for key, holder := range i.owners {
+		if holder == owner {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
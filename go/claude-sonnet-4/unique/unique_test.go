@@ -0,0 +1,148 @@
+package unique
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+)
+
+func TestIndex_ClaimsSharedAcrossInstances(t *testing.T) {
+	store := common.NewEventStore()
+	// Two instances stand in for two processes sharing the store
+	first, second := NewIndex(store, "emails"), NewIndex(store, "emails")
+
+	if err := first.Claim("alice@example.com", "account-1"); err != nil {
+		t.Fatalf("Error claiming: %v", err)
+	}
+	if err := first.Claim("alice@example.com", "account-1"); err != nil {
+		t.Errorf("Expected claiming a held key again to be a no-op, got %v", err)
+	}
+	var taken *KeyTakenError
+	err := second.Claim("alice@example.com", "account-2")
+	if !errors.As(err, &taken) || taken.Owner != "account-1" || !errors.Is(err, common.ErrInvalidCommand) {
+		t.Fatalf("Expected the second instance to see the claim, got %v", err)
+	}
+
+	var notHeld *KeyNotHeldError
+	if err := second.Release("alice@example.com", "account-2"); !errors.As(err, &notHeld) {
+		t.Errorf("Expected only the owner to release the key, got %v", err)
+	}
+	second.Claim("alice@work.example", "account-1")
+	if err := second.ReleaseOwner("account-1"); err != nil {
+		t.Fatalf("Error releasing owner: %v", err)
+	}
+	if keys, _ := first.Keys("account-1"); len(keys) != 0 {
+		t.Errorf("Expected every key of the owner to be released, got %v", keys)
+	}
+	if err := first.Claim("alice@example.com", "account-2"); err != nil {
+		t.Errorf("Expected a released key to be claimable, got %v", err)
+	}
+	if owner, _ := second.Owner("alice@example.com"); owner != "account-2" {
+		t.Errorf("Expected account-2 to hold the key, got %q", owner)
+	}
+	if version := store.GetStreamVersion(first.StreamID()); version != 5 {
+		t.Errorf("Expected 5 index events, got %d", version)
+	}
+}
+
+// createHandler creates an aggregate, failing for IDs starting with "fail"
+func createHandler(_ context.Context, command common.Command) (*common.Event, error) {
+	id := command.(*createCommand).id
+	if strings.HasPrefix(id, "fail") {
+		return nil, &common.InvalidCommandError{Message: "refused"}
+	}
+	return common.NewEvent("Created", id, 1, nil, nil), nil
+}
+
+type createCommand struct {
+	id, email string
+	target    string
+}
+
+func (c *createCommand) AggregateID() string { return c.target }
+func (c *createCommand) CommandType() string { return "Create" }
+
+type closeCommand struct{ id string }
+
+func (c *closeCommand) AggregateID() string { return c.id }
+func (c *closeCommand) CommandType() string { return "Close" }
+
+func TestIndex_Middleware(t *testing.T) {
+	store := common.NewEventStore()
+	index := NewIndex(store, "emails")
+	email := func(_ context.Context, command common.Command) string { return command.(*createCommand).email }
+	commands := bus.NewCommandBus(index.Middleware(map[string]KeyFunc{"Create": email}, "Close"))
+	commands.Register("Create", createHandler)
+	commands.Register("Close", func(_ context.Context, command common.Command) (*common.Event, error) {
+		return common.NewEvent("Closed", command.AggregateID(), 2, nil, nil), nil
+	})
+	ctx := context.Background()
+
+	if _, err := commands.Dispatch(ctx, &createCommand{id: "fail-1", email: "a@example.com"}); err == nil {
+		t.Fatal("Expected the handler to fail")
+	}
+	if owner, _ := index.Owner("a@example.com"); owner != "" {
+		t.Errorf("Expected a failed command to release its claim, got %q", owner)
+	}
+
+	if _, err := commands.Dispatch(ctx, &createCommand{id: "account-1", email: "a@example.com"}); err != nil {
+		t.Fatalf("Error creating: %v", err)
+	}
+	if owner, _ := index.Owner("a@example.com"); owner != "account-1" {
+		t.Errorf("Expected the pending claim to pass to the created aggregate, got %q", owner)
+	}
+	var taken *KeyTakenError
+	if _, err := commands.Dispatch(ctx, &createCommand{id: "account-2", email: "a@example.com"}); !errors.As(err, &taken) {
+		t.Errorf("Expected a duplicate to be rejected, got %v", err)
+	}
+	if _, err := commands.Dispatch(ctx, &createCommand{id: "fail-2", email: "a@example.com", target: "account-1"}); err == nil {
+		t.Fatal("Expected the handler to fail")
+	}
+	if owner, _ := index.Owner("a@example.com"); owner != "account-1" {
+		t.Errorf("Expected a failed command to keep a claim it did not make, got %q", owner)
+	}
+
+	if _, err := commands.Dispatch(ctx, &closeCommand{id: "account-1"}); err != nil {
+		t.Fatalf("Error closing: %v", err)
+	}
+	if _, err := commands.Dispatch(ctx, &createCommand{id: "account-2", email: "a@example.com"}); err != nil {
+		t.Errorf("Expected the key to be free once its owner closed, got %v", err)
+	}
+}
+
+func TestIndex_PendingClaimsExpire(t *testing.T) {
+	store := common.NewEventStore()
+	now := time.Unix(1700000000, 0)
+	clock := func() time.Time { return now }
+	crashed := NewIndex(store, "emails", WithClock(clock), WithPendingTTL(time.Minute))
+	if err := crashed.Claim("a@example.com", pendingPrefix+"1"); err != nil {
+		t.Fatalf("Error claiming: %v", err)
+	}
+
+	// Another process finds the key held while the claim may still be transferred
+	index := NewIndex(store, "emails", WithClock(clock), WithPendingTTL(time.Minute))
+	var taken *KeyTakenError
+	if err := index.Claim("a@example.com", "account-1"); !errors.As(err, &taken) {
+		t.Fatalf("Expected a fresh pending claim to hold the key, got %v", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if owner, _ := index.Owner("a@example.com"); owner != "" {
+		t.Errorf("Expected the expired pending claim to count as free, got %q", owner)
+	}
+	if err := index.Claim("a@example.com", "account-1"); err != nil {
+		t.Fatalf("Expected the expired pending claim to be taken over, got %v", err)
+	}
+	if owner, _ := crashed.Owner("a@example.com"); owner != "account-1" {
+		t.Errorf("Expected every instance to see the new owner, got %q", owner)
+	}
+	var notHeld *KeyNotHeldError
+	if err := crashed.Transfer("a@example.com", pendingPrefix+"1", "account-2"); !errors.As(err, &notHeld) {
+		t.Errorf("Expected the late transfer of the expired claim to fail, got %v", err)
+	}
+}
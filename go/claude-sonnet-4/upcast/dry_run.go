@@ -0,0 +1,171 @@
+// Package upcast checks the registered upcaster pipeline against a whole store before
+// a schema migration is rolled out. A dry run upcasts every stored event without
+// writing anything back and reports which events would change, which would fail,
+// and which are stuck at an old schema version because an upcaster is missing.
+package upcast
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"simple-event-modeling/common"
+	"simple-event-modeling/semdiff"
+)
+
+// Outcome classifies what the pipeline would do to an event
+type Outcome string
+
+// Outcomes
+const (
+	Unchanged Outcome = "unchanged"
+	Changed   Outcome = "changed"
+	Failed    Outcome = "failed"
+	Missing   Outcome = "missing"
+)
+
+// Result is the dry-run outcome of a single event that is not unchanged
+type Result struct {
+	// Position is the position of the event in the global log (see
+	// common.LogPosition)
+	Position   int              `json:"position"`
+	EventID    string           `json:"event_id"`
+	EventType  string           `json:"event_type"`
+	StreamID   string           `json:"stream_id"`
+	Version    int              `json:"version"`
+	Outcome    Outcome          `json:"outcome"`
+	FromSchema int              `json:"from_schema"`
+	ToSchema   int              `json:"to_schema"`
+	Changes    []semdiff.Change `json:"changes,omitempty"`
+	Error      string           `json:"error,omitempty"`
+}
+
+// TypeCoverage counts the outcomes for one event type
+type TypeCoverage struct {
+	EventType     string `json:"event_type"`
+	CurrentSchema int    `json:"current_schema"`
+	Events        int    `json:"events"`
+	Unchanged     int    `json:"unchanged"`
+	Changed       int    `json:"changed"`
+	Failed        int    `json:"failed"`
+	Missing       int    `json:"missing"`
+}
+
+// Report is the result of a dry run
+type Report struct {
+	Scanned int `json:"scanned"`
+	// Coverage holds one entry per event type found in the store, sorted by type
+	Coverage []TypeCoverage `json:"coverage"`
+	// Results lists every event that would change, fail, or lacks an upcaster, in log order
+	Results []Result `json:"results"`
+}
+
+// OK reports whether every event can be upcast to its current schema
+func (r *Report) OK() bool {
+	for _, coverage := range r.Coverage {
+		if coverage.Failed > 0 || coverage.Missing > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// DryRun runs every event in the store through the registry's upcaster pipeline.
+// Nothing is written to the store.
+func DryRun(store common.Store, registry *common.Registry) *Report {
+	report := &Report{Results: make([]Result, 0)}
+	coverage := make(map[string]*TypeCoverage)
+
+	all := store.GetAllEvents()
+	for i, event := range all {
+		report.Scanned++
+		typeCoverage, exists := coverage[event.Type]
+		if !exists {
+			typeCoverage = &TypeCoverage{EventType: event.Type, CurrentSchema: registry.CurrentSchemaVersion(event.Type)}
+			coverage[event.Type] = typeCoverage
+		}
+		typeCoverage.Events++
+
+		result := Result{
+			Position:   common.LogPosition(all, i),
+			EventID:    event.ID,
+			EventType:  event.Type,
+			StreamID:   event.AggregateID,
+			Version:    event.Version,
+			FromSchema: common.SchemaVersion(event),
+			ToSchema:   typeCoverage.CurrentSchema,
+		}
+
+		upcast, err := registry.Upcast(event)
+		var missing *common.MissingUpcasterError
+		switch {
+		case errors.As(err, &missing):
+			result.Outcome = Missing
+			result.Error = err.Error()
+			typeCoverage.Missing++
+		case err != nil:
+			result.Outcome = Failed
+			result.Error = err.Error()
+			typeCoverage.Failed++
+		case upcast == event:
+			typeCoverage.Unchanged++
+			continue
+		default:
+			result.Outcome = Changed
+			result.Changes = semdiff.DiffEvents(event, upcast)
+			typeCoverage.Changed++
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	report.Coverage = make([]TypeCoverage, 0, len(coverage))
+	for _, typeCoverage := range coverage {
+		report.Coverage = append(report.Coverage, *typeCoverage)
+	}
+	sort.Slice(report.Coverage, func(i, j int) bool { return report.Coverage[i].EventType < report.Coverage[j].EventType })
+	return report
+}
+
+// WriteText writes the coverage table followed by the events that would change,
+// fail, or lack an upcaster. Changes are listed only when verbose is set.
+func (r *Report) WriteText(out io.Writer, verbose bool) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "EVENT TYPE\tSCHEMA\tEVENTS\tUNCHANGED\tCHANGED\tFAILED\tMISSING")
+	for _, c := range r.Coverage {
+		fmt.Fprintf(w, "%s\tv%d\t%d\t%d\t%d\t%d\t%d\n", c.EventType, c.CurrentSchema, c.Events, c.Unchanged, c.Changed, c.Failed, c.Missing)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, result := range r.Results {
+		fmt.Fprintf(out, "\n#%d %s %s@%d v%d->v%d: %s", result.Position, result.EventType, result.StreamID, result.Version,
+			result.FromSchema, result.ToSchema, result.Outcome)
+		if result.Error != "" {
+			fmt.Fprintf(out, ": %s", result.Error)
+		}
+		fmt.Fprintln(out)
+		if verbose {
+			for _, change := range result.Changes {
+				fmt.Fprintf(out, "  %s\n", change)
+			}
+		}
+	}
+
+	status := "ok"
+	if !r.OK() {
+		status = "FAILED"
+	}
+	_, err := fmt.Fprintf(out, "\n%d events scanned, %d would change: %s\n", r.Scanned, r.changed(), status)
+	return err
+}
+
+func (r *Report) changed() int {
+	changed := 0
+	for _, c := range r.Coverage {
+		changed += c.Changed
+	}
+	return changed
+}
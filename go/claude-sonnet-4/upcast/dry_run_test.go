@@ -0,0 +1,65 @@
+package upcast
+
+import (
+	"bytes"
+	"errors"
+	"simple-event-modeling/common"
+	"strings"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	registry := common.NewRegistry()
+	registry.RegisterEvent(common.EventInfo{Name: "ItemAdded", SchemaVersion: 2})
+	registry.RegisterEvent(common.EventInfo{Name: "CartCleared", SchemaVersion: 2})
+	registry.RegisterUpcaster(common.Upcaster{EventType: "ItemAdded", FromVersion: 1, Upcast: func(event *common.Event) (*common.Event, error) {
+		item, ok := event.Data["item"].(string)
+		if !ok {
+			return nil, errors.New("item is missing")
+		}
+		event.Data["item_id"] = item
+		delete(event.Data, "item")
+		return event, nil
+	}})
+
+	store := common.NewEventStore()
+	store.Append(common.NewEvent("CartCreated", "cart-1", 1, nil, nil))
+	store.Append(common.NewEvent("ItemAdded", "cart-1", 2, map[string]interface{}{"item": "apple"}, nil))
+	store.Append(common.NewEvent("ItemAdded", "cart-1", 3, nil, nil))
+	store.Append(common.NewEvent("ItemAdded", "cart-1", 4, map[string]interface{}{"item_id": "pear"}, map[string]interface{}{common.SchemaVersionKey: 2}))
+	store.Append(common.NewEvent("CartCleared", "cart-1", 5, nil, nil))
+
+	report := DryRun(store, registry)
+	if report.Scanned != 5 || report.OK() {
+		t.Errorf("Expected 5 events scanned and a failing report, got %+v", report)
+	}
+
+	outcomes := make([]Outcome, len(report.Results))
+	for i, result := range report.Results {
+		outcomes[i] = result.Outcome
+	}
+	want := []Outcome{Changed, Failed, Missing}
+	if len(outcomes) != len(want) || outcomes[0] != want[0] || outcomes[1] != want[1] || outcomes[2] != want[2] {
+		t.Fatalf("Expected outcomes %v, got %v", want, outcomes)
+	}
+	if report.Results[0].Position != 2 || len(report.Results[0].Changes) == 0 {
+		t.Errorf("Expected the changed event's diff, got %+v", report.Results[0])
+	}
+
+	itemAdded := report.Coverage[2]
+	if itemAdded.EventType != "ItemAdded" || itemAdded.Events != 3 || itemAdded.Unchanged != 1 || itemAdded.Changed != 1 || itemAdded.Failed != 1 {
+		t.Errorf("Unexpected ItemAdded coverage: %+v", itemAdded)
+	}
+
+	if got := store.GetAllEvents()[1].Data["item"]; got != "apple" {
+		t.Errorf("Expected the dry run to leave the store untouched, got %v", got)
+	}
+
+	var out bytes.Buffer
+	report.WriteText(&out, true)
+	for _, want := range []string{"ItemAdded", "+ data.item_id: \"apple\"", "item is missing", "no upcaster for CartCleared", "FAILED"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected report to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
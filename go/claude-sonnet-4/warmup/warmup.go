@@ -0,0 +1,196 @@
+// Package warmup prepares a process for traffic before it takes any: at start, a
+// Warmer hydrates the aggregates requests are likely to touch into their
+// repositories' caches and catches projections up with the event log, so the first
+// requests after a deploy don't pay for replays.
+//
+// Which streams to warm comes from a StreamSource, either a fixed list (Streams) or
+// a heuristic such as the streams written to recently (RecentlyActive). Register
+// Warmer.Check as a readiness check (see package health) to keep load balancers away
+// until warm-up has finished.
+package warmup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"simple-event-modeling/common"
+)
+
+// ErrWarmingUp is returned by Warmer.Check until warm-up has finished
+var ErrWarmingUp = errors.New("warming up")
+
+// Task warms one cache
+type Task func(ctx context.Context) error
+
+// StreamSource lists the streams to warm
+type StreamSource func() []string
+
+// Streams warms a fixed list of streams
+func Streams(ids ...string) StreamSource {
+	return func() []string { return ids }
+}
+
+// RecentlyActive lists the streams with events created within window, most
+// recently written first, up to limit of them. With aggregateType set, only
+// streams whose events are registered to that aggregate type in
+// common.DefaultRegistry are listed, e.g. cart.AggregateTypeCart. System streams,
+// whose IDs start with "$", are skipped.
+func RecentlyActive(store common.Store, window time.Duration, limit int, aggregateType string) StreamSource {
+	return func() []string {
+		cutoff := time.Now().Add(-window)
+		all := store.GetAllEvents()
+
+		ids := make([]string, 0)
+		seen := make(map[string]bool)
+		for i := len(all) - 1; i >= 0 && (limit <= 0 || len(ids) < limit); i-- {
+			event := all[i]
+			if seen[event.AggregateID] || strings.HasPrefix(event.AggregateID, "$") {
+				continue
+			}
+			if event.CreatedAt.Before(cutoff) {
+				// The log is in append order, so older events hold no newer streams
+				// unless clocks disagree; stop at the first one out of the window
+				break
+			}
+			if aggregateType != "" {
+				info, registered := common.DefaultRegistry.Event(event.Type)
+				if !registered || info.Aggregate != aggregateType {
+					continue
+				}
+			}
+			seen[event.AggregateID] = true
+			ids = append(ids, event.AggregateID)
+		}
+		return ids
+	}
+}
+
+// Aggregates hydrates the aggregates of the listed streams into the repository's
+// cache. Streams that no longer exist are skipped.
+func Aggregates[A common.Aggregate](repository *common.Repository[A], streams StreamSource) Task {
+	return func(ctx context.Context) error {
+		_, err := repository.LoadMany(streams())
+		var failed *common.LoadManyError
+		if errors.As(err, &failed) {
+			for id, loadErr := range failed.Errors {
+				if errors.Is(loadErr, common.ErrStreamNotFound) {
+					delete(failed.Errors, id)
+				}
+			}
+			if len(failed.Errors) == 0 {
+				return nil
+			}
+		}
+		return err
+	}
+}
+
+// Projection catches an asynchronous projection up with the event log
+func Projection(projection *common.AsyncProjection) Task {
+	return func(ctx context.Context) error {
+		return projection.CatchUp()
+	}
+}
+
+// Result is the outcome of one warm-up task
+type Result struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+type namedTask struct {
+	name string
+	task Task
+}
+
+// Warmer runs warm-up tasks concurrently at process start
+type Warmer struct {
+	// Timeout bounds each task; 0 leaves them to ctx
+	Timeout time.Duration
+
+	mu      sync.Mutex
+	tasks   []namedTask
+	done    bool
+	results []Result
+}
+
+// New creates a warmer with no tasks
+func New() *Warmer {
+	return &Warmer{}
+}
+
+// Add registers a task to run
+func (w *Warmer) Add(name string, task Task) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.tasks = append(w.tasks, namedTask{name: name, task: task})
+}
+
+// Run runs every task concurrently and returns their results in the order they
+// were added, with the errors of the failed ones joined. Warm-up only saves time,
+// so the warmer counts as done even when tasks fail; callers decide whether a
+// failure should stop the process.
+func (w *Warmer) Run(ctx context.Context) ([]Result, error) {
+	w.mu.Lock()
+	tasks := append([]namedTask(nil), w.tasks...)
+	w.mu.Unlock()
+
+	results := make([]Result, len(tasks))
+	errs := make([]error, len(tasks))
+	var wg sync.WaitGroup
+	for i, task := range tasks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			taskCtx := ctx
+			if w.Timeout > 0 {
+				var cancel context.CancelFunc
+				taskCtx, cancel = context.WithTimeout(ctx, w.Timeout)
+				defer cancel()
+			}
+
+			start := time.Now()
+			err := task.task(taskCtx)
+			results[i] = Result{Name: task.name, Duration: time.Since(start)}
+			if err != nil {
+				results[i].Error = err.Error()
+				errs[i] = fmt.Errorf("%s: %w", task.name, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	w.mu.Lock()
+	w.done = true
+	w.results = results
+	w.mu.Unlock()
+	return results, errors.Join(errs...)
+}
+
+// Done reports whether Run has finished
+func (w *Warmer) Done() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.done
+}
+
+// Results returns the results of the finished run, or nil before it finishes
+func (w *Warmer) Results() []Result {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.results
+}
+
+// Check fails with ErrWarmingUp until Run has finished. It has the signature of a
+// health.Check.
+func (w *Warmer) Check(ctx context.Context) error {
+	if !w.Done() {
+		return ErrWarmingUp
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package warmup
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"simple-event-modeling/cart"
+	"simple-event-modeling/common"
+)
+
+func TestRecentlyActive(t *testing.T) {
+	store := common.NewEventStore()
+	old := cart.NewCartCreatedEvent("cart-old")
+	old.CreatedAt = time.Now().Add(-48 * time.Hour)
+	store.Append(old)
+	store.Append(cart.NewCartCreatedEvent("cart-1"))
+	store.Append(cart.NewCartCreatedEvent("cart-2"))
+	store.Append(cart.NewItemAddedEvent("cart-1", 2, "apple"))
+	store.Append(common.NewEvent("CommandReceived", "$commands", 1, nil, nil))
+	store.Append(cart.NewItemPriceChangedEvent(1, "apple", 1.5))
+
+	ids := RecentlyActive(store, time.Hour, 0, cart.AggregateTypeCart)()
+	if len(ids) != 2 || ids[0] != "cart-1" || ids[1] != "cart-2" {
+		t.Errorf("Expected recent carts, most recent first, got %v", ids)
+	}
+	if ids := RecentlyActive(store, time.Hour, 1, "")(); len(ids) != 1 || ids[0] != cart.CatalogPricesStream {
+		t.Errorf("Expected the single most recent stream of any type, got %v", ids)
+	}
+}
+
+func TestWarmer_WarmsAggregatesAndProjections(t *testing.T) {
+	store := common.NewEventStore()
+	store.Append(cart.NewCartCreatedEvent("cart-1"))
+	store.Append(cart.NewItemAddedEvent("cart-1", 2, "apple"))
+
+	repository := common.NewRepository(store, cart.NewCartAggregate)
+	projection := common.NewAsyncProjection(store, cart.NewCartItemsProjection())
+
+	warmer := New()
+	warmer.Add("carts", Aggregates(repository, Streams("cart-1", "cart-gone")))
+	warmer.Add("cart-items", Projection(projection))
+	if err := warmer.Check(context.Background()); !errors.Is(err, ErrWarmingUp) {
+		t.Errorf("Expected ErrWarmingUp before the run, got %v", err)
+	}
+
+	results, err := warmer.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Error warming up: %v", err)
+	}
+	if len(results) != 2 || results[0].Name != "carts" || results[1].Name != "cart-items" {
+		t.Errorf("Expected results in the order added, got %+v", results)
+	}
+	if err := warmer.Check(context.Background()); err != nil {
+		t.Errorf("Expected ready after the run, got %v", err)
+	}
+
+	if _, changed, err := repository.LoadIfChanged("cart-1", 2); err != nil || changed {
+		t.Errorf("Expected cart-1 cached at version 2, got changed=%v, %v", changed, err)
+	}
+	if checkpoint := projection.Checkpoint(); checkpoint.Position != 2 {
+		t.Errorf("Expected the projection caught up, got %+v", checkpoint)
+	}
+}
+
+func TestWarmer_ReportsFailuresAndFinishes(t *testing.T) {
+	warmer := New()
+	warmer.Timeout = 10 * time.Millisecond
+	warmer.Add("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	warmer.Add("fine", func(ctx context.Context) error { return nil })
+
+	results, err := warmer.Run(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the slow task to time out, got %v", err)
+	}
+	if results[0].Error == "" || results[1].Error != "" {
+		t.Errorf("Expected only the slow task to fail, got %+v", results)
+	}
+	if !warmer.Done() || len(warmer.Results()) != 2 {
+		t.Error("Expected the warmer done despite the failure")
+	}
+}
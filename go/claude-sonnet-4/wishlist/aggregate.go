@@ -0,0 +1,201 @@
+// Package wishlist provides the WishlistAggregate implementation for the wishlist domain.
+package wishlist
+
+import (
+	"errors"
+	"sort"
+
+	"simple-event-modeling/cart"
+	"simple-event-modeling/common"
+
+	"github.com/google/uuid"
+)
+
+// savedItem is an item in the wishlist
+type savedItem struct {
+	data ItemData
+	// moving is the ID of the move taking the item to cartID, if one is under way
+	moving string
+	cartID string
+}
+
+// WishlistAggregate guards the items of a wishlist. An item being moved to a cart
+// can't be moved again until the move completes or is cancelled.
+type WishlistAggregate struct {
+	*common.BaseAggregate
+	items map[string]*savedItem // line key (see cart.LineKey) -> item
+	moves map[string]string     // move ID -> line key
+}
+
+// NewWishlistAggregate creates a new wishlist aggregate
+func NewWishlistAggregate(store common.Store) *WishlistAggregate {
+	return &WishlistAggregate{
+		BaseAggregate: common.NewBaseAggregate(store),
+		items:         make(map[string]*savedItem),
+		moves:         make(map[string]string),
+	}
+}
+
+// Items returns the line keys of the saved items, sorted
+func (a *WishlistAggregate) Items() []string {
+	lines := make([]string, 0, len(a.items))
+	for line := range a.items {
+		lines = append(lines, line)
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// Moving returns whether a saved item is being moved to a cart
+func (a *WishlistAggregate) Moving(line string) bool {
+	item, ok := a.items[line]
+	return ok && item.moving != ""
+}
+
+// Handle processes commands and returns resulting events
+func (a *WishlistAggregate) Handle(command common.Command) (*common.Event, error) {
+	if aggregateID := command.AggregateID(); aggregateID != "" && !a.IsLive() {
+		if err := a.Hydrate(aggregateID); err != nil {
+			return nil, err
+		}
+	}
+
+	var event *common.Event
+	var err error
+	switch cmd := command.(type) {
+	case *CreateWishlistCommand:
+		event = NewWishlistCreatedEvent(uuid.New().String())
+	case *SaveForLaterCommand:
+		event, err = a.handleSaveForLater(cmd)
+	case *MoveToCartCommand:
+		event, err = a.handleMoveToCart(cmd)
+	case *CompleteMoveCommand:
+		event, err = a.handleCompleteMove(cmd)
+	case *CancelMoveCommand:
+		event, err = a.handleCancelMove(cmd)
+	default:
+		return nil, &common.UnknownCommandError{
+			CommandType: command.CommandType(),
+			Registered:  common.DefaultRegistry.CommandTypes(AggregateTypeWishlist),
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.On(event); err != nil {
+		return nil, err
+	}
+	if err := a.Store().Append(event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// On applies events to aggregate state
+func (a *WishlistAggregate) On(event *common.Event) error {
+	if event.Type == EventTypeWishlistCreated {
+		a.Begin(event)
+		return a.Advance(event)
+	}
+
+	typed, err := common.AsTyped[ItemData](event)
+	if err != nil {
+		return err
+	}
+	data := typed.Data
+	switch event.Type {
+	case EventTypeItemSavedForLater:
+		a.items[data.Line()] = &savedItem{data: ItemData{Item: data.Item, Options: data.Options}}
+	case EventTypeMoveToCartStarted:
+		if item, ok := a.items[data.Line()]; ok {
+			item.moving = data.MoveID
+			item.cartID = data.CartID
+			a.moves[data.MoveID] = data.Line()
+		}
+	case EventTypeItemMovedToCart:
+		delete(a.items, data.Line())
+		delete(a.moves, data.MoveID)
+	case EventTypeMoveCancelled:
+		if item, ok := a.items[data.Line()]; ok {
+			item.moving = ""
+			item.cartID = ""
+		}
+		delete(a.moves, data.MoveID)
+	default:
+		return errors.New("unhandled event type: " + event.Type)
+	}
+	return a.Advance(event)
+}
+
+// Hydrate rebuilds the aggregate state from its event stream
+func (a *WishlistAggregate) Hydrate(id string) error {
+	return a.BaseAggregate.Hydrate(id, a.On)
+}
+
+// Command handlers
+
+func (a *WishlistAggregate) handleSaveForLater(cmd *SaveForLaterCommand) (*common.Event, error) {
+	if err := a.checkCreated(); err != nil {
+		return nil, err
+	}
+	line := cart.LineKey(cmd.ItemID, cmd.Options)
+	if _, saved := a.items[line]; saved {
+		return nil, &common.InvalidCommandError{Message: "item " + line + " is already saved"}
+	}
+
+	moveID := ""
+	if cmd.CartID != "" {
+		moveID = uuid.New().String()
+	}
+	return NewItemSavedForLaterEvent(a.ID(), a.Version()+1, cmd.ItemID, cmd.Options, cmd.CartID, moveID), nil
+}
+
+func (a *WishlistAggregate) handleMoveToCart(cmd *MoveToCartCommand) (*common.Event, error) {
+	if err := a.checkCreated(); err != nil {
+		return nil, err
+	}
+	line := cart.LineKey(cmd.ItemID, cmd.Options)
+	item, saved := a.items[line]
+	if !saved {
+		return nil, &common.InvalidCommandError{Message: "item " + line + " is not saved"}
+	}
+	if item.moving != "" {
+		return nil, &common.InvalidCommandError{Message: "item " + line + " is already being moved"}
+	}
+	return NewMoveToCartStartedEvent(a.ID(), a.Version()+1, cmd.ItemID, cmd.Options, cmd.CartID, uuid.New().String()), nil
+}
+
+func (a *WishlistAggregate) handleCompleteMove(cmd *CompleteMoveCommand) (*common.Event, error) {
+	item, err := a.checkMove(cmd.MoveID)
+	if err != nil {
+		return nil, err
+	}
+	return NewItemMovedToCartEvent(a.ID(), a.Version()+1, item.data.Item, item.data.Options, item.cartID, cmd.MoveID), nil
+}
+
+func (a *WishlistAggregate) handleCancelMove(cmd *CancelMoveCommand) (*common.Event, error) {
+	item, err := a.checkMove(cmd.MoveID)
+	if err != nil {
+		return nil, err
+	}
+	return NewMoveCancelledEvent(a.ID(), a.Version()+1, item.data.Item, item.data.Options, item.cartID, cmd.MoveID, cmd.Reason), nil
+}
+
+func (a *WishlistAggregate) checkCreated() error {
+	if !a.IsLive() || a.ID() == "" {
+		return &common.InvalidCommandError{Message: "wishlist not created"}
+	}
+	return nil
+}
+
+func (a *WishlistAggregate) checkMove(moveID string) (*savedItem, error) {
+	if err := a.checkCreated(); err != nil {
+		return nil, err
+	}
+	line, ok := a.moves[moveID]
+	if !ok {
+		return nil, &common.InvalidCommandError{Message: "move " + moveID + " is not under way"}
+	}
+	return a.items[line], nil
+}
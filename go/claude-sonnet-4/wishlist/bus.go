@@ -0,0 +1,22 @@
+// Package wishlist registers the wishlist command handlers with a command bus.
+package wishlist
+
+import (
+	"context"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/common"
+)
+
+// RegisterCommands registers a handler for every wishlist command.
+// Each command is handled by a fresh aggregate hydrated from the store.
+func RegisterCommands(commands *bus.CommandBus, store common.Store) {
+	handle := func(_ context.Context, command common.Command) (*common.Event, error) {
+		return NewWishlistAggregate(store).Handle(command)
+	}
+	commands.Register(CommandTypeCreateWishlist, handle)
+	commands.Register(CommandTypeSaveForLater, handle)
+	commands.Register(CommandTypeMoveToCart, handle)
+	commands.Register(CommandTypeCompleteMove, handle)
+	commands.Register(CommandTypeCancelMove, handle)
+}
@@ -0,0 +1,53 @@
+// Package wishlist provides command types for the wishlist domain.
+// Commands are simple record structures; their only methods expose the routing
+// metadata required by common.Command.
+package wishlist
+
+// CreateWishlistCommand creates an empty wishlist. The wishlist ID is generated.
+type CreateWishlistCommand struct{}
+
+// SaveForLaterCommand saves an item in the wishlist. With a cart ID the item is
+// moved out of that cart.
+type SaveForLaterCommand struct {
+	WishlistID string            `json:"aggregate_id" validate:"required,uuid"`
+	ItemID     string            `json:"item_id" validate:"required"`
+	Options    map[string]string `json:"options,omitempty"`
+	CartID     string            `json:"cart_id,omitempty" validate:"omitempty,streamid"`
+}
+
+// MoveToCartCommand starts moving a saved item to a cart. The item stays in the
+// wishlist until the cart has accepted it.
+type MoveToCartCommand struct {
+	WishlistID string            `json:"aggregate_id" validate:"required,uuid"`
+	ItemID     string            `json:"item_id" validate:"required"`
+	Options    map[string]string `json:"options,omitempty"`
+	CartID     string            `json:"cart_id" validate:"required,streamid"`
+}
+
+// CompleteMoveCommand removes an item from the wishlist once its cart accepted it
+type CompleteMoveCommand struct {
+	WishlistID string `json:"aggregate_id" validate:"required,uuid"`
+	MoveID     string `json:"move_id" validate:"required"`
+}
+
+// CancelMoveCommand keeps an item in the wishlist after its cart rejected it
+type CancelMoveCommand struct {
+	WishlistID string `json:"aggregate_id" validate:"required,uuid"`
+	MoveID     string `json:"move_id" validate:"required"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+func (c *CreateWishlistCommand) AggregateID() string { return "" }
+func (c *CreateWishlistCommand) CommandType() string { return CommandTypeCreateWishlist }
+
+func (c *SaveForLaterCommand) AggregateID() string { return c.WishlistID }
+func (c *SaveForLaterCommand) CommandType() string { return CommandTypeSaveForLater }
+
+func (c *MoveToCartCommand) AggregateID() string { return c.WishlistID }
+func (c *MoveToCartCommand) CommandType() string { return CommandTypeMoveToCart }
+
+func (c *CompleteMoveCommand) AggregateID() string { return c.WishlistID }
+func (c *CompleteMoveCommand) CommandType() string { return CommandTypeCompleteMove }
+
+func (c *CancelMoveCommand) AggregateID() string { return c.WishlistID }
+func (c *CancelMoveCommand) CommandType() string { return CommandTypeCancelMove }
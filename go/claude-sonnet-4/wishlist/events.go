@@ -0,0 +1,77 @@
+// Package wishlist provides event types and creation functions for the wishlist domain.
+// Events are simple record structures with no behaviors.
+package wishlist
+
+import (
+	"simple-event-modeling/cart"
+	"simple-event-modeling/common"
+)
+
+// Event type constants
+const (
+	EventTypeWishlistCreated   = "WishlistCreated"
+	EventTypeItemSavedForLater = "ItemSavedForLater"
+	EventTypeMoveToCartStarted = "MoveToCartStarted"
+	EventTypeItemMovedToCart   = "ItemMovedToCart"
+	EventTypeMoveCancelled     = "MoveCancelled"
+)
+
+// ItemData is the payload of the wishlist's item events
+type ItemData struct {
+	Item    string            `json:"item"`
+	Options map[string]string `json:"options,omitempty"`
+	// CartID is the cart the item moves out of or into
+	CartID string `json:"cart_id,omitempty"`
+	// MoveID identifies a move between the wishlist and a cart (see MoveIDKey)
+	MoveID string `json:"move_id,omitempty"`
+	// Reason is why a move was cancelled
+	Reason string `json:"reason,omitempty"`
+}
+
+// Line returns the key of the item in the wishlist, which is its cart line key
+func (d ItemData) Line() string {
+	return cart.LineKey(d.Item, d.Options)
+}
+
+// NewWishlistCreatedEvent creates a new WishlistCreated event
+func NewWishlistCreatedEvent(wishlistID string) *common.Event {
+	return common.NewEvent(EventTypeWishlistCreated, wishlistID, 1, nil, nil)
+}
+
+// NewItemSavedForLaterEvent creates a new ItemSavedForLater event; cartID and
+// moveID are empty when the item isn't moved out of a cart
+func NewItemSavedForLaterEvent(wishlistID string, version int, itemID string, options map[string]string, cartID, moveID string) *common.Event {
+	return common.NewEvent(EventTypeItemSavedForLater, wishlistID, version, moveData(itemID, options, cartID, moveID), nil)
+}
+
+// NewMoveToCartStartedEvent creates a new MoveToCartStarted event
+func NewMoveToCartStartedEvent(wishlistID string, version int, itemID string, options map[string]string, cartID, moveID string) *common.Event {
+	return common.NewEvent(EventTypeMoveToCartStarted, wishlistID, version, moveData(itemID, options, cartID, moveID), nil)
+}
+
+// NewItemMovedToCartEvent creates a new ItemMovedToCart event
+func NewItemMovedToCartEvent(wishlistID string, version int, itemID string, options map[string]string, cartID, moveID string) *common.Event {
+	return common.NewEvent(EventTypeItemMovedToCart, wishlistID, version, moveData(itemID, options, cartID, moveID), nil)
+}
+
+// NewMoveCancelledEvent creates a new MoveCancelled event
+func NewMoveCancelledEvent(wishlistID string, version int, itemID string, options map[string]string, cartID, moveID, reason string) *common.Event {
+	data := moveData(itemID, options, cartID, moveID)
+	data["reason"] = reason
+	return common.NewEvent(EventTypeMoveCancelled, wishlistID, version, data, nil)
+}
+
+// moveData returns the event data of an item's move, leaving out empty fields
+func moveData(itemID string, options map[string]string, cartID, moveID string) map[string]interface{} {
+	data := map[string]interface{}{"item": itemID}
+	if len(options) > 0 {
+		data["options"] = options
+	}
+	if cartID != "" {
+		data["cart_id"] = cartID
+	}
+	if moveID != "" {
+		data["move_id"] = moveID
+	}
+	return data
+}
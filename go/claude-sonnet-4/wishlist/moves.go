@@ -0,0 +1,90 @@
+// Package wishlist provides the process manager moving items between wishlists and carts.
+package wishlist
+
+import (
+	"context"
+	"errors"
+
+	"simple-event-modeling/bus"
+	"simple-event-modeling/cart"
+	"simple-event-modeling/common"
+	"simple-event-modeling/process"
+)
+
+// MoveIDKey is the event metadata key tagging the cart event a move produced
+const MoveIDKey = "move_id"
+
+// NewMoveManager creates a process manager applying the cart half of every move
+// the wishlist records. The bus must have both the wishlist and the cart commands
+// registered, and the cart commands must keep context metadata on their events
+// (see cart.RegisterCommands).
+//   - ItemSavedForLater with a cart removes one unit of the item from the cart; a
+//     cart that no longer holds it leaves nothing to undo
+//   - MoveToCartStarted adds the item to the cart, then completes the move, or
+//     cancels it when the cart rejects the item, e.g. when the cart is full
+//
+// Before touching the cart the manager looks for an event tagged with the move's
+// ID in the cart's stream, so a reaction retried after a failure never removes or
+// adds the item twice.
+func NewMoveManager(store common.Store, commands *bus.CommandBus) *process.Manager {
+	m := process.NewManager("wishlist-moves", store, commands)
+
+	m.On(EventTypeItemSavedForLater, func(ctx context.Context, event *common.Event) ([]common.Command, error) {
+		saved, err := common.AsTyped[ItemData](event)
+		if err != nil || saved.Data.CartID == "" {
+			return nil, err
+		}
+		_, err = applyToCart(ctx, store, commands, saved.Data, &cart.RemoveItemCommand{
+			CartID:  saved.Data.CartID,
+			ItemID:  saved.Data.Item,
+			Options: saved.Data.Options,
+		})
+		return nil, err
+	})
+
+	m.On(EventTypeMoveToCartStarted, func(ctx context.Context, event *common.Event) ([]common.Command, error) {
+		started, err := common.AsTyped[ItemData](event)
+		if err != nil {
+			return nil, err
+		}
+		rejection, err := applyToCart(ctx, store, commands, started.Data, &cart.AddItemCommand{
+			CartID:  started.Data.CartID,
+			ItemID:  started.Data.Item,
+			Options: started.Data.Options,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if rejection != nil {
+			return []common.Command{&CancelMoveCommand{WishlistID: event.AggregateID, MoveID: started.Data.MoveID, Reason: rejection.Error()}}, nil
+		}
+		return []common.Command{&CompleteMoveCommand{WishlistID: event.AggregateID, MoveID: started.Data.MoveID}}, nil
+	})
+
+	return m
+}
+
+// applyToCart dispatches the cart half of a move, tagging its event with the move
+// ID, unless the cart's stream already has an event tagged with it. It returns the
+// cart's rejection of the command separately from failures worth retrying.
+func applyToCart(ctx context.Context, store common.Store, commands *bus.CommandBus, move ItemData, command common.Command) (rejection, err error) {
+	events, err := store.GetStream(move.CartID)
+	if err != nil && !errors.Is(err, common.ErrStreamNotFound) {
+		return nil, err
+	}
+	for _, event := range events {
+		if event.Metadata[MoveIDKey] == move.MoveID {
+			return nil, nil
+		}
+	}
+
+	_, err = commands.Dispatch(common.WithEventMetadata(ctx, MoveIDKey, move.MoveID), command)
+	switch {
+	case err == nil:
+		return nil, nil
+	case errors.Is(err, common.ErrInvalidCommand), errors.Is(err, common.ErrAggregateClosed), errors.Is(err, common.ErrStreamNotFound):
+		return err, nil
+	default:
+		return nil, err
+	}
+}
@@ -0,0 +1,70 @@
+// Package wishlist registers the wishlist domain with the common registry so
+// tooling can discover its commands, events, and aggregate.
+package wishlist
+
+import "simple-event-modeling/common"
+
+// AggregateTypeWishlist is the registered name of the wishlist aggregate
+const AggregateTypeWishlist = "Wishlist"
+
+// Command type names
+const (
+	CommandTypeCreateWishlist = "CreateWishlist"
+	CommandTypeSaveForLater   = "SaveForLater"
+	CommandTypeMoveToCart     = "MoveToCart"
+	CommandTypeCompleteMove   = "CompleteMove"
+	CommandTypeCancelMove     = "CancelMove"
+)
+
+func init() {
+	register(common.DefaultRegistry)
+}
+
+func register(registry *common.Registry) {
+	registry.RegisterAggregate(common.AggregateInfo{Name: AggregateTypeWishlist})
+
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeCreateWishlist,
+		Aggregate: AggregateTypeWishlist,
+		Produces:  []string{EventTypeWishlistCreated},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeSaveForLater,
+		Aggregate: AggregateTypeWishlist,
+		Produces:  []string{EventTypeItemSavedForLater},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeMoveToCart,
+		Aggregate: AggregateTypeWishlist,
+		Produces:  []string{EventTypeMoveToCartStarted},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeCompleteMove,
+		Aggregate: AggregateTypeWishlist,
+		Produces:  []string{EventTypeItemMovedToCart},
+	})
+	registry.RegisterCommand(common.CommandInfo{
+		Name:      CommandTypeCancelMove,
+		Aggregate: AggregateTypeWishlist,
+		Produces:  []string{EventTypeMoveCancelled},
+	})
+
+	itemFields := []common.FieldInfo{
+		{Name: "item", Type: "string", Description: "ID of the item"},
+		{Name: "options", Type: "object", Description: "Variant options of the item, e.g. size and color", Optional: true},
+		{Name: "cart_id", Type: "string", Description: "Cart the item moves out of or into", Optional: true},
+		{Name: "move_id", Type: "string", Description: "ID of the move between the wishlist and a cart", Optional: true},
+	}
+	registry.RegisterEvent(common.EventInfo{Name: EventTypeWishlistCreated, Aggregate: AggregateTypeWishlist})
+	registry.RegisterEvent(common.EventInfo{Name: EventTypeItemSavedForLater, Aggregate: AggregateTypeWishlist, Payload: itemFields})
+	registry.RegisterEvent(common.EventInfo{Name: EventTypeMoveToCartStarted, Aggregate: AggregateTypeWishlist, Payload: itemFields})
+	registry.RegisterEvent(common.EventInfo{Name: EventTypeItemMovedToCart, Aggregate: AggregateTypeWishlist, Payload: itemFields})
+	registry.RegisterEvent(common.EventInfo{
+		Name:      EventTypeMoveCancelled,
+		Aggregate: AggregateTypeWishlist,
+		Payload:   append(append([]common.FieldInfo(nil), itemFields...), common.FieldInfo{Name: "reason", Type: "string", Description: "Why the cart rejected the item"}),
+	})
+	for _, eventType := range []string{EventTypeItemSavedForLater, EventTypeMoveToCartStarted, EventTypeItemMovedToCart, EventTypeMoveCancelled} {
+		common.RegisterPayload[ItemData](registry, eventType)
+	}
+}
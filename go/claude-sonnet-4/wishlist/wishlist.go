@@ -0,0 +1,17 @@
+// Package wishlist provides a wishlist domain built on the common framework.
+// Shoppers save cart items for later and move them back to a cart when they are
+// ready to buy. It showcases moving an item between two event-sourced aggregates
+// without a transaction spanning them:
+//   - the wishlist records the move first (ItemSavedForLater, MoveToCartStarted)
+//   - a process manager applies the other half to the cart, tagging the cart's
+//     event with the move ID so a retried reaction never applies it twice
+//   - a move to a cart that rejects the item is cancelled, keeping it in the wishlist
+//
+// The package is organized into separate files for each major concept:
+// - commands.go: Command types (CreateWishlist, SaveForLater, MoveToCart, etc.)
+// - events.go: Event types and creation functions (WishlistCreated, ItemSavedForLater, etc.)
+// - aggregate.go: WishlistAggregate implementation with the move rules
+// - moves.go: Process manager moving items between wishlists and carts
+// - registry.go: Registration with the common registry
+// - bus.go: Registration of command handlers with a command bus
+package wishlist
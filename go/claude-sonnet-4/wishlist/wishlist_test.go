@@ -0,0 +1,126 @@
+package wishlist
+
+import (
+	"context"
+	"errors"
+	"simple-event-modeling/bus"
+	"simple-event-modeling/cart"
+	"simple-event-modeling/common"
+	"testing"
+)
+
+func newBus(store common.Store) *bus.CommandBus {
+	commands := bus.NewCommandBus(bus.Validation())
+	RegisterCommands(commands, store)
+	cart.RegisterCommands(commands, store)
+	return commands
+}
+
+func dispatch(t *testing.T, commands *bus.CommandBus, command common.Command) *common.Event {
+	t.Helper()
+	event, err := commands.Dispatch(context.Background(), command)
+	if err != nil {
+		t.Fatalf("Error handling %s: %v", command.CommandType(), err)
+	}
+	return event
+}
+
+func cartItems(t *testing.T, store common.Store, cartID string) map[string]int {
+	t.Helper()
+	aggregate := cart.NewCartAggregate(store)
+	if err := aggregate.Hydrate(cartID); err != nil {
+		t.Fatalf("Error hydrating cart: %v", err)
+	}
+	return aggregate.Items()
+}
+
+func wishlistOf(t *testing.T, store common.Store, wishlistID string) *WishlistAggregate {
+	t.Helper()
+	aggregate := NewWishlistAggregate(store)
+	if err := aggregate.Hydrate(wishlistID); err != nil {
+		t.Fatalf("Error hydrating wishlist: %v", err)
+	}
+	return aggregate
+}
+
+func TestMoveManager_SavesForLaterAndMovesBack(t *testing.T) {
+	store := common.NewEventStore()
+	commands := newBus(store)
+	manager := NewMoveManager(store, commands)
+	ctx := context.Background()
+
+	cartID := dispatch(t, commands, &cart.CreateCartCommand{}).AggregateID
+	dispatch(t, commands, &cart.AddItemCommand{CartID: cartID, ItemID: "shirt", Options: map[string]string{"size": "M"}})
+	wishlistID := dispatch(t, commands, &CreateWishlistCommand{}).AggregateID
+
+	dispatch(t, commands, &SaveForLaterCommand{WishlistID: wishlistID, ItemID: "shirt", Options: map[string]string{"size": "M"}, CartID: cartID})
+	if _, err := manager.Process(ctx); err != nil {
+		t.Fatalf("Error processing: %v", err)
+	}
+	if items := cartItems(t, store, cartID); len(items) != 0 {
+		t.Errorf("Expected the shirt moved out of the cart, got %v", items)
+	}
+
+	// A retried reaction finds the move's event in the cart and leaves it alone
+	dispatch(t, commands, &cart.AddItemCommand{CartID: cartID, ItemID: "shirt", Options: map[string]string{"size": "M"}})
+	manager.SetCheckpoint(0)
+	if _, err := manager.Process(ctx); err != nil {
+		t.Fatalf("Error reprocessing: %v", err)
+	}
+	if items := cartItems(t, store, cartID); items["shirt[size=M]"] != 1 {
+		t.Errorf("Expected the replayed move not to remove the shirt again, got %v", items)
+	}
+
+	dispatch(t, commands, &MoveToCartCommand{WishlistID: wishlistID, ItemID: "shirt", Options: map[string]string{"size": "M"}, CartID: cartID})
+	if _, err := commands.Dispatch(ctx, &MoveToCartCommand{WishlistID: wishlistID, ItemID: "shirt", Options: map[string]string{"size": "M"}, CartID: cartID}); !errors.Is(err, common.ErrInvalidCommand) {
+		t.Errorf("Expected a second move of the same item to be rejected, got %v", err)
+	}
+	if !wishlistOf(t, store, wishlistID).Moving("shirt[size=M]") {
+		t.Error("Expected the shirt to stay in the wishlist until the cart accepts it")
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := manager.Process(ctx); err != nil {
+			t.Fatalf("Error processing: %v", err)
+		}
+	}
+	if items := cartItems(t, store, cartID); items["shirt[size=M]"] != 2 {
+		t.Errorf("Expected the shirt moved back into the cart, got %v", items)
+	}
+	if items := wishlistOf(t, store, wishlistID).Items(); len(items) != 0 {
+		t.Errorf("Expected the wishlist empty after the move, got %v", items)
+	}
+}
+
+func TestMoveManager_CancelsMovesTheCartRejects(t *testing.T) {
+	store := common.NewEventStore()
+	commands := newBus(store)
+	manager := NewMoveManager(store, commands)
+	ctx := context.Background()
+
+	cartID := dispatch(t, commands, &cart.CreateCartCommand{}).AggregateID
+	for i := 0; i < cart.MaxItems; i++ {
+		dispatch(t, commands, &cart.AddItemCommand{CartID: cartID, ItemID: "apple"})
+	}
+	wishlistID := dispatch(t, commands, &CreateWishlistCommand{}).AggregateID
+	dispatch(t, commands, &SaveForLaterCommand{WishlistID: wishlistID, ItemID: "mug"})
+
+	dispatch(t, commands, &MoveToCartCommand{WishlistID: wishlistID, ItemID: "mug", CartID: cartID})
+	for i := 0; i < 2; i++ {
+		if _, err := manager.Process(ctx); err != nil {
+			t.Fatalf("Error processing: %v", err)
+		}
+	}
+
+	wishlist := wishlistOf(t, store, wishlistID)
+	if items := wishlist.Items(); len(items) != 1 || wishlist.Moving("mug") {
+		t.Errorf("Expected the mug kept in the wishlist and free to move again, got %v", items)
+	}
+	events, _ := store.GetStream(wishlistID)
+	cancelled := events[len(events)-1]
+	if cancelled.Type != EventTypeMoveCancelled || cancelled.Data["reason"] == "" {
+		t.Errorf("Expected the move cancelled with the cart's reason, got %s %v", cancelled.Type, cancelled.Data)
+	}
+	if items := cartItems(t, store, cartID); items["apple"] != cart.MaxItems || len(items) != 1 {
+		t.Errorf("Expected the full cart unchanged, got %v", items)
+	}
+}
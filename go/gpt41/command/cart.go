@@ -1,9 +1,13 @@
+// Package command provides the gpt41 cart commands.
+//
+// Deprecated: use the canonical module in go/claude-sonnet-4 (module simple-event-modeling)
+// or its compat/gpt41 adapter.
 package command
 
 import (
 	"errors"
 
-	"../eventstore"
+	"simpleeventmodeling/eventstore"
 )
 
 type Command interface {
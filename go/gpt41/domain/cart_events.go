@@ -1,3 +1,7 @@
+// Package domain provides the gpt41 cart event type names.
+//
+// Deprecated: use the canonical module in go/claude-sonnet-4 (module simple-event-modeling)
+// or its compat/gpt41 adapter.
 package domain
 
 // Domain event type constants
@@ -1,3 +1,7 @@
+// Package event provides the gpt41 event record.
+//
+// Deprecated: use the canonical module in go/claude-sonnet-4 (module simple-event-modeling)
+// or its compat/gpt41 adapter.
 package event
 
 import "time"
@@ -1,17 +1,24 @@
+// Package eventstore provides the gpt41 in-memory event store.
+//
+// Deprecated: use the canonical module in go/claude-sonnet-4 (module simple-event-modeling)
+// or its compat/gpt41 adapter.
 package eventstore
 
 import (
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 
-	"../event"
+	"simpleeventmodeling/event"
 )
 
 // EventStore is an in-memory event store for streams.
 type EventStore struct {
 	streams map[string][]event.Event
 	lock    sync.RWMutex
+	seq     uint64
+	last    time.Time
 }
 
 // NewEventStore creates a new EventStore.
@@ -27,22 +34,31 @@ func (es *EventStore) AppendEvent(streamID string, eventType string, data map[st
 	defer es.lock.Unlock()
 	events := es.streams[streamID]
 	version := len(events) + 1
+	id := es.generateEventID()
 	e := event.Event{
-		ID:          generateEventID(),
+		ID:          id,
 		AggregateID: streamID,
 		Type:        eventType,
 		Data:        data,
 		Version:     version,
 		Metadata:    map[string]interface{}{},
-		CreatedAt:   time.Now(),
+		CreatedAt:   es.last,
 	}
 	es.streams[streamID] = append(events, e)
 	return e
 }
 
-// generateEventID creates a unique event ID (simple placeholder).
-func generateEventID() string {
-	return time.Now().Format("20060102150405.000000000")
+// generateEventID creates a unique event ID and advances es.last. Timestamps never go
+// back or repeat, and the sequence suffix keeps IDs unique even if they did.
+// Callers must hold es.lock.
+func (es *EventStore) generateEventID() string {
+	now := time.Now()
+	if !now.After(es.last) {
+		now = es.last.Add(time.Nanosecond)
+	}
+	es.last = now
+	es.seq++
+	return fmt.Sprintf("%s-%d", now.Format("20060102150405.000000000"), es.seq)
 }
 
 // GetEvents returns all events for a stream.
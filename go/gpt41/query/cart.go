@@ -1,7 +1,11 @@
+// Package query provides the gpt41 cart queries.
+//
+// Deprecated: use the canonical module in go/claude-sonnet-4 (module simple-event-modeling)
+// or its compat/gpt41 adapter.
 package query
 
 import (
-	"../eventstore"
+	"simpleeventmodeling/eventstore"
 )
 
 // GetCart returns the current state of the cart (list of items).
@@ -1,5 +1,9 @@
 // Package simpleeventmodeling provides a minimal event store for event-driven modeling.
 // Equivalent to the Ruby SimpleEventModeling library.
+//
+// Deprecated: the gpt41 port is superseded by the canonical module in go/claude-sonnet-4
+// (module simple-event-modeling). The compat/gpt41 package there offers the same
+// command and query API on top of the canonical cart while callers migrate.
 package simpleeventmodeling
 
 import (
@@ -4,7 +4,9 @@ import (
 	"reflect"
 	"testing"
 
-	"../eventstore"
+	"simpleeventmodeling/command"
+	"simpleeventmodeling/eventstore"
+	"simpleeventmodeling/query"
 )
 
 func TestAppendEventAndGetEvents(t *testing.T) {
@@ -86,7 +88,7 @@ func TestCartCommandsAndQueries(t *testing.T) {
 	cartID := "cart-100"
 
 	// CreateCart
-	create := &CreateCart{CartID: cartID}
+	create := &command.CreateCart{CartID: cartID}
 	if err := create.Execute(es); err != nil {
 		t.Fatalf("CreateCart failed: %v", err)
 	}
@@ -95,35 +97,35 @@ func TestCartCommandsAndQueries(t *testing.T) {
 	}
 
 	// AddItem
-	add := &AddItem{CartID: cartID, Item: "apple"}
+	add := &command.AddItem{CartID: cartID, Item: "apple"}
 	if err := add.Execute(es); err != nil {
 		t.Fatalf("AddItem failed: %v", err)
 	}
-	add2 := &AddItem{CartID: cartID, Item: "banana"}
+	add2 := &command.AddItem{CartID: cartID, Item: "banana"}
 	if err := add2.Execute(es); err != nil {
 		t.Fatalf("AddItem failed: %v", err)
 	}
 
 	// RemoveItem
-	remove := &RemoveItem{CartID: cartID, Item: "apple"}
+	remove := &command.RemoveItem{CartID: cartID, Item: "apple"}
 	if err := remove.Execute(es); err != nil {
 		t.Fatalf("RemoveItem failed: %v", err)
 	}
 
 	// Add another apple
-	add3 := &AddItem{CartID: cartID, Item: "apple"}
+	add3 := &command.AddItem{CartID: cartID, Item: "apple"}
 	if err := add3.Execute(es); err != nil {
 		t.Fatalf("AddItem failed: %v", err)
 	}
 
 	// ClearCart
-	clear := &ClearCart{CartID: cartID}
+	clear := &command.ClearCart{CartID: cartID}
 	if err := clear.Execute(es); err != nil {
 		t.Fatalf("ClearCart failed: %v", err)
 	}
 
 	// Query: GetCart
-	items, err := GetCart(es, cartID)
+	items, err := query.GetCart(es, cartID)
 	if err != nil {
 		t.Fatalf("GetCart failed: %v", err)
 	}
@@ -132,9 +134,9 @@ func TestCartCommandsAndQueries(t *testing.T) {
 	}
 
 	// Add items again
-	_ = (&AddItem{CartID: cartID, Item: "pear"}).Execute(es)
-	_ = (&AddItem{CartID: cartID, Item: "grape"}).Execute(es)
-	items, err = GetCart(es, cartID)
+	_ = (&command.AddItem{CartID: cartID, Item: "pear"}).Execute(es)
+	_ = (&command.AddItem{CartID: cartID, Item: "grape"}).Execute(es)
+	items, err = query.GetCart(es, cartID)
 	if err != nil {
 		t.Fatalf("GetCart failed: %v", err)
 	}
@@ -1,4 +1,8 @@
 // Package cart implements a simple shopping cart domain using the common
 // SimpleEventModeling primitives. Commands and events are data-only; the
 // aggregate validates and emits events, hydrating by replay.
+//
+// Deprecated: the gpt5 port is superseded by the canonical module in go/claude-sonnet-4
+// (module simple-event-modeling). The compat/gpt5 package there offers this API on top
+// of the canonical cart while callers migrate.
 package cart
@@ -1,4 +1,8 @@
 // Package common provides the foundational components for the Go port of the
 // SimpleEventModeling library. It includes Event, EventStore, Aggregate
 // contracts and implementations for an in-memory, event-sourced model.
+//
+// Deprecated: the gpt5 port is superseded by the canonical module in go/claude-sonnet-4
+// (module simple-event-modeling). The compat/gpt5 package there offers this API on top
+// of the canonical cart while callers migrate.
 package common